@@ -18,12 +18,13 @@ import (
 	"github.com/tsuru/tsuru/api/types"
 	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/app/job"
+	"github.com/tsuru/tsuru/app/metrics"
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/event"
 	tsuruIo "github.com/tsuru/tsuru/io"
-	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/pool"
@@ -44,7 +45,7 @@ func getAppFromContext(name string, r *http.Request) (app.App, error) {
 	var err error
 	a := context.GetApp(r)
 	if a == nil {
-		a, err = getApp(name)
+		a, err = getReadableApp(name, context.GetAuthToken(r))
 		if err != nil {
 			return app.App{}, err
 		}
@@ -53,6 +54,20 @@ func getAppFromContext(name string, r *http.Request) (app.App, error) {
 	return *a, nil
 }
 
+// getReadableApp is like getApp, but in hard isolation mode it returns the
+// same not found error for apps that don't exist and for apps the user can't
+// read.
+func getReadableApp(name string, t auth.Token) (*app.App, error) {
+	a, err := getApp(name)
+	if !permission.HardIsolation() {
+		return a, err
+	}
+	if err != nil || (t != nil && !permission.Check(t, permission.PermAppRead, contextsForApp(a)...)) {
+		return nil, errIsolatedNotFound
+	}
+	return a, nil
+}
+
 func getApp(name string) (*app.App, error) {
 	a, err := app.GetByName(name)
 	if err != nil {
@@ -75,11 +90,12 @@ func appDelete(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 	if err != nil {
 		return err
 	}
-	canDelete := permission.Check(t, permission.PermAppDelete,
-		contextsForApp(&a)...,
-	)
-	if !canDelete {
-		return permission.ErrUnauthorized
+	if isDryRun(r) {
+		actions, actionsErr := app.DeleteActions(&a)
+		if actionsErr != nil {
+			return actionsErr
+		}
+		return writeDryRunActions(w, actions)
 	}
 	evt, err := event.New(&event.Opts{
 		Target:     appTarget(a.Name),
@@ -96,23 +112,88 @@ func appDelete(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 	defer keepAliveWriter.Stop()
 	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
 	w.Header().Set("Content-Type", "application/x-json-stream")
-	return app.Delete(&a, writer)
+	if app.DeleteGracePeriod() > 0 {
+		return app.SoftDelete(&a, t.GetUserName(), writer)
+	}
+	err = app.Delete(&a, writer)
+	if err != nil {
+		return err
+	}
+	return job.RemoveAll(a.Name)
+}
+
+// title: restore app
+// path: /apps/{name}/restore
+// method: POST
+// produce: application/x-json-stream
+// responses:
+//   200: App restored
+//   401: Unauthorized
+//   404: Not found
+func appRestore(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	appName := r.URL.Query().Get(":app")
+	a, err := app.GetDeletedByName(appName)
+	if err == app.ErrAppNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf("No removed app %s pending deletion.", appName)}
+	}
+	if err != nil {
+		return err
+	}
+	canRestore := permission.Check(t, permission.PermAppUpdateRestore,
+		contextsForApp(a)...,
+	)
+	if !canRestore {
+		return permission.ErrUnauthorized
+	}
+	// The lock middleware doesn't find removed apps, so the route skips it
+	// and the lock is taken here.
+	locked, err := app.AcquireApplicationLockWait(a.Name, lockOwner(t), fmt.Sprintf("%s %s", r.Method, r.URL.Path), lockWaitDuration)
+	if err != nil {
+		return err
+	}
+	if !locked {
+		return &errors.HTTP{Code: http.StatusConflict, Message: fmt.Sprintf("%s: %s", a.Name, &a.Lock)}
+	}
+	defer app.ReleaseApplicationLock(a.Name)
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppUpdateRestore,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
+	defer keepAliveWriter.Stop()
+	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	w.Header().Set("Content-Type", "application/x-json-stream")
+	err = app.Restore(a, writer)
+	if err == app.ErrAppNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf("No removed app %s pending deletion.", appName)}
+	}
+	return err
 }
 
 // miniApp is a minimal representation of the app, created to make appList
 // faster and transmit less data.
 type miniApp struct {
-	Name      string               `json:"name"`
-	Pool      string               `json:"pool"`
-	TeamOwner string               `json:"teamowner"`
-	Plan      appTypes.Plan        `json:"plan"`
-	Units     []provision.Unit     `json:"units"`
-	CName     []string             `json:"cname"`
-	IP        string               `json:"ip"`
-	Routers   []appTypes.AppRouter `json:"routers"`
-	Lock      provision.AppLock    `json:"lock"`
-	Tags      []string             `json:"tags"`
-	Error     string               `json:"error,omitempty"`
+	Name       string                   `json:"name"`
+	Pool       string                   `json:"pool"`
+	TeamOwner  string                   `json:"teamowner"`
+	Plan       appTypes.Plan            `json:"plan"`
+	Units      []provision.Unit         `json:"units"`
+	UnitCounts map[provision.Status]int `json:"unitcounts"`
+	LastDeploy *time.Time               `json:"lastdeploy,omitempty"`
+	CName      []string                 `json:"cname"`
+	IP         string                   `json:"ip"`
+	Routers    []appTypes.AppRouter     `json:"routers"`
+	Lock       provision.AppLock        `json:"lock"`
+	Tags       []string                 `json:"tags"`
+	Error      string                   `json:"error,omitempty"`
 }
 
 func minifyApp(app app.App) (miniApp, error) {
@@ -121,17 +202,22 @@ func minifyApp(app app.App) (miniApp, error) {
 	if err != nil {
 		errorStr = fmt.Sprintf("unable to list app units: %+v", err)
 	}
+	unitCounts := map[provision.Status]int{}
+	for _, u := range units {
+		unitCounts[u.Status]++
+	}
 	ma := miniApp{
-		Name:      app.Name,
-		Pool:      app.Pool,
-		Plan:      app.Plan,
-		TeamOwner: app.TeamOwner,
-		Units:     units,
-		CName:     app.CName,
-		Routers:   app.Routers,
-		Lock:      &app.Lock,
-		Tags:      app.Tags,
-		Error:     errorStr,
+		Name:       app.Name,
+		Pool:       app.Pool,
+		Plan:       app.Plan,
+		TeamOwner:  app.TeamOwner,
+		Units:      units,
+		UnitCounts: unitCounts,
+		CName:      app.CName,
+		Routers:    app.Routers,
+		Lock:       &app.Lock,
+		Tags:       app.Tags,
+		Error:      errorStr,
 	}
 	if len(ma.Routers) > 0 {
 		ma.IP = ma.Routers[0].Address
@@ -195,28 +281,42 @@ func appList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if tags, ok := r.URL.Query()["tag"]; ok {
 		filter.Tags = tags
 	}
-	contexts := permission.ContextsForPermission(t, permission.PermAppRead)
+	contexts := permission.ContextsForPermission(t, permission.PermAppReadInfo)
 	if len(contexts) == 0 {
 		w.WriteHeader(http.StatusNoContent)
 		return nil
 	}
+	cacheKey := listCacheKey(t, r)
+	cached, generation := appListCache.serve(w, cacheKey)
+	if cached {
+		return nil
+	}
 	apps, err := app.List(appFilterByContext(contexts, filter))
 	if err != nil {
 		return err
 	}
 	if len(apps) == 0 {
-		w.WriteHeader(http.StatusNoContent)
-		return nil
+		return appListCache.store(w, cacheKey, generation, nil)
+	}
+	appNames := make([]string, len(apps))
+	for i := range apps {
+		appNames[i] = apps[i].Name
+	}
+	lastDeploys, err := app.LastDeploys(appNames)
+	if err != nil {
+		return err
 	}
-	w.Header().Set("Content-Type", "application/json")
 	miniApps := make([]miniApp, len(apps))
 	for i, app := range apps {
 		miniApps[i], err = minifyApp(app)
 		if err != nil {
 			return err
 		}
+		if lastDeploy, ok := lastDeploys[app.Name]; ok {
+			miniApps[i].LastDeploy = &lastDeploy
+		}
 	}
-	return json.NewEncoder(w).Encode(miniApps)
+	return appListCache.store(w, cacheKey, generation, miniApps)
 }
 
 // title: app info
@@ -232,12 +332,6 @@ func appInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if err != nil {
 		return err
 	}
-	canRead := permission.Check(t, permission.PermAppRead,
-		contextsForApp(&a)...,
-	)
-	if !canRead {
-		return permission.ErrUnauthorized
-	}
 	w.Header().Set("Content-Type", "application/json")
 	return json.NewEncoder(w).Encode(&a)
 }
@@ -285,6 +379,17 @@ func createApp(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 		Router:      ia.Router,
 		Tags:        r.Form["tag"],
 	}
+	var tpl *app.Template
+	if templateName := r.FormValue("template"); templateName != "" {
+		tpl, err = app.GetTemplate(templateName)
+		if err != nil {
+			if err == app.ErrTemplateNotFound {
+				return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+			}
+			return err
+		}
+		tpl.Apply(&a)
+	}
 	if a.TeamOwner == "" {
 		a.TeamOwner, err = permission.TeamForPermission(t, permission.PermAppCreate)
 		if err != nil {
@@ -307,6 +412,12 @@ func createApp(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 	if !canCreate {
 		return permission.ErrUnauthorized
 	}
+	if tpl != nil {
+		err = checkTemplateServices(t, tpl, a.TeamOwner)
+		if err != nil {
+			return err
+		}
+	}
 	u, err := t.User()
 	if err != nil {
 		return err
@@ -334,31 +445,19 @@ func createApp(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 	if err != nil {
 		return err
 	}
-	defer func() { evt.Done(err) }()
+	var doneData interface{}
+	defer func() { evt.DoneCustomData(err, doneData) }()
 	err = app.CreateApp(&a, u)
 	if err != nil {
-		log.Errorf("Got error while creating app: %s", err)
-		if _, ok := err.(app.NoTeamsError); ok {
-			return &errors.HTTP{
-				Code:    http.StatusBadRequest,
-				Message: "In order to create an app, you should be member of at least one team",
-			}
-		}
-		if e, ok := err.(*app.AppCreationError); ok {
-			if e.Err == app.ErrAppAlreadyExists {
-				return &errors.HTTP{Code: http.StatusConflict, Message: e.Error()}
-			}
-			if _, ok := e.Err.(*quota.QuotaExceededError); ok {
-				return &errors.HTTP{
-					Code:    http.StatusForbidden,
-					Message: "Quota exceeded",
-				}
-			}
-		}
-		if err == appTypes.ErrInvalidPlatform {
-			return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+		return appCreationError(err)
+	}
+	if tpl != nil {
+		var result *app.TemplateResult
+		result, err = tpl.Provision(&a, u, requestIDHeader(r), nil)
+		doneData = result
+		if err != nil {
+			return err
 		}
-		return err
 	}
 	repo, err := repository.Manager().GetRepository(a.Name)
 	if err != nil {
@@ -375,6 +474,9 @@ func createApp(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 	if len(addrs) > 0 {
 		msg["ip"] = addrs[0]
 	}
+	if tpl != nil {
+		msg["template"] = tpl.Name
+	}
 	jsonMsg, err := json.Marshal(msg)
 	if err != nil {
 		return err
@@ -385,6 +487,133 @@ func createApp(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 	return nil
 }
 
+func appCreationError(err error) error {
+	logger.Errorf("Got error while creating app: %s", err)
+	if _, ok := err.(app.NoTeamsError); ok {
+		return &errors.HTTP{
+			Code:    http.StatusBadRequest,
+			Message: "In order to create an app, you should be member of at least one team",
+		}
+	}
+	if e, ok := err.(*app.AppCreationError); ok {
+		if e.Err == app.ErrAppAlreadyExists {
+			return &errors.HTTP{Code: http.StatusConflict, Message: e.Error()}
+		}
+		if _, ok := e.Err.(*quota.QuotaExceededError); ok {
+			return &errors.HTTP{
+				Code:    http.StatusForbidden,
+				Message: "Quota exceeded",
+			}
+		}
+	}
+	if err == appTypes.ErrInvalidPlatform {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return err
+}
+
+// title: app clone
+// path: /apps/{app}/clone
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/json
+// responses:
+//   201: App created
+//   400: Invalid data
+//   401: Unauthorized
+//   403: Quota exceeded
+//   404: Not found
+//   409: App already exists
+func appClone(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	err = r.ParseForm()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	src, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	name := r.FormValue("name")
+	if name == "" {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "name is required"}
+	}
+	bindServices, _ := strconv.ParseBool(r.FormValue("bindServices"))
+	canRead := permission.Check(t, permission.PermAppRead,
+		contextsForApp(&src)...,
+	)
+	if !canRead {
+		return permission.ErrUnauthorized
+	}
+	canCreate := permission.Check(t, permission.PermAppCreate,
+		permission.Context(permission.CtxTeam, src.TeamOwner),
+	)
+	if !canCreate {
+		return permission.ErrUnauthorized
+	}
+	opts := app.CloneOptions{Name: name}
+	if bindServices {
+		opts.Instances, err = service.GetServiceInstancesBoundToApp(src.Name)
+		if err != nil {
+			return err
+		}
+		for _, instance := range opts.Instances {
+			allowed := permission.Check(t, permission.PermServiceInstanceUpdateBind,
+				append(permission.Contexts(permission.CtxTeam, instance.Teams),
+					permission.Context(permission.CtxServiceInstance, instance.Name),
+				)...,
+			)
+			if !allowed {
+				return permission.ErrUnauthorized
+			}
+		}
+	}
+	u, err := t.User()
+	if err != nil {
+		return err
+	}
+	newApp := app.App{Name: name, TeamOwner: src.TeamOwner, Pool: src.Pool}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(name),
+		Kind:       permission.PermAppCreate,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&newApp)...),
+	})
+	if err != nil {
+		return err
+	}
+	var result *app.CloneResult
+	defer func() { evt.DoneCustomData(err, result) }()
+	a, result, err := app.Clone(&src, opts, u)
+	if err != nil {
+		if a == nil {
+			return appCreationError(err)
+		}
+		return err
+	}
+	repo, err := repository.Manager().GetRepository(a.Name)
+	if err != nil {
+		return err
+	}
+	msg := map[string]interface{}{
+		"status":         "success",
+		"repository_url": repo.ReadWriteURL,
+		"cloned_from":    src.Name,
+		"envs":           result.Envs,
+		"instances":      result.Instances,
+	}
+	addrs, err := a.GetAddresses()
+	if err != nil {
+		return err
+	}
+	if len(addrs) > 0 {
+		msg["ip"] = addrs[0]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(msg)
+}
+
 // title: app update
 // path: /apps/{name}
 // method: PUT
@@ -447,8 +676,16 @@ func updateApp(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 	if updateData.UpdatePlatform {
 		wantedPerms = append(wantedPerms, permission.PermAppUpdateImageReset)
 	}
+	if spreadStr := r.FormValue("spread"); spreadStr != "" {
+		spread, errParse := strconv.ParseBool(spreadStr)
+		if errParse != nil {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "Invalid value for spread: it must be a boolean."}
+		}
+		updateData.Spread = &spread
+		wantedPerms = append(wantedPerms, permission.PermAppUpdateSpread)
+	}
 	if len(wantedPerms) == 0 {
-		msg := "Neither the description, plan, pool, team owner or platform were set. You must define at least one."
+		msg := "Neither the description, plan, pool, team owner, platform or spread were set. You must define at least one."
 		return &errors.HTTP{Code: http.StatusBadRequest, Message: msg}
 	}
 	for _, perm := range wantedPerms {
@@ -523,12 +760,6 @@ func addUnits(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 	if err != nil {
 		return err
 	}
-	allowed := permission.Check(t, permission.PermAppUpdateUnitAdd,
-		contextsForApp(&a)...,
-	)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	evt, err := event.New(&event.Opts{
 		Target:     appTarget(appName),
 		Kind:       permission.PermAppUpdateUnitAdd,
@@ -567,12 +798,6 @@ func removeUnits(w http.ResponseWriter, r *http.Request, t auth.Token) (err erro
 	if err != nil {
 		return err
 	}
-	allowed := permission.Check(t, permission.PermAppUpdateUnitRemove,
-		contextsForApp(&a)...,
-	)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	evt, err := event.New(&event.Opts{
 		Target:     appTarget(appName),
 		Kind:       permission.PermAppUpdateUnitRemove,
@@ -686,12 +911,6 @@ func grantAppAccess(w http.ResponseWriter, r *http.Request, t auth.Token) (err e
 	if err != nil {
 		return err
 	}
-	allowed := permission.Check(t, permission.PermAppUpdateGrant,
-		contextsForApp(&a)...,
-	)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	evt, err := event.New(&event.Opts{
 		Target:     appTarget(appName),
 		Kind:       permission.PermAppUpdateGrant,
@@ -730,12 +949,6 @@ func revokeAppAccess(w http.ResponseWriter, r *http.Request, t auth.Token) (err
 	if err != nil {
 		return err
 	}
-	allowed := permission.Check(t, permission.PermAppUpdateRevoke,
-		contextsForApp(&a)...,
-	)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	evt, err := event.New(&event.Opts{
 		Target:     appTarget(appName),
 		Kind:       permission.PermAppUpdateRevoke,
@@ -788,12 +1001,6 @@ func runCommand(w http.ResponseWriter, r *http.Request, t auth.Token) (err error
 	if err != nil {
 		return err
 	}
-	allowed := permission.Check(t, permission.PermAppRun,
-		contextsForApp(&a)...,
-	)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	evt, err := event.New(&event.Opts{
 		Target:     appTarget(appName),
 		Kind:       permission.PermAppRun,
@@ -838,23 +1045,61 @@ func getEnv(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 			contextsForApp(&a)...,
 		)
 		if !allowed {
-			return permission.ErrUnauthorized
+			allowed = permission.Check(t, permission.PermAppReadEnvPublic,
+				contextsForApp(&a)...,
+			)
+			if !allowed {
+				return permission.ErrUnauthorized
+			}
+			return writePublicEnvVars(w, &a, variables...)
+		}
+	}
+	return writeEnvVars(w, &a, t.IsAppToken(), variables...)
+}
+
+// writePublicEnvVars writes only the public environment variables of the app,
+// private variables and secrets are omitted.
+func writePublicEnvVars(w http.ResponseWriter, a *app.App, variables ...string) error {
+	wanted := make(map[string]bool, len(variables))
+	for _, v := range variables {
+		wanted[v] = true
+	}
+	result := []bind.EnvVar{}
+	for name, v := range a.Envs() {
+		if !v.Public || (len(wanted) > 0 && !wanted[name]) {
+			continue
 		}
+		if _, isSecret := a.Secrets[name]; isSecret {
+			continue
+		}
+		result = append(result, v)
 	}
-	return writeEnvVars(w, &a, variables...)
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(result)
 }
 
-func writeEnvVars(w http.ResponseWriter, a *app.App, variables ...string) error {
+// writeEnvVars writes the environment variables of the app. Secret values are
+// only written when withSecrets is true, otherwise only their names are
+// listed.
+func writeEnvVars(w http.ResponseWriter, a *app.App, withSecrets bool, variables ...string) error {
 	var result []bind.EnvVar
 	w.Header().Set("Content-Type", "application/json")
+	envs := a.Envs()
+	if !withSecrets {
+		for name := range a.Secrets {
+			envs[name] = bind.EnvVar{Name: name}
+		}
+	}
 	if len(variables) > 0 {
 		for _, variable := range variables {
 			if v, ok := a.Env[variable]; ok {
 				result = append(result, v)
+			} else if _, ok = a.Secrets[variable]; ok {
+				result = append(result, envs[variable])
 			}
 		}
 	} else {
-		for _, v := range a.Envs() {
+		for _, v := range envs {
 			result = append(result, v)
 		}
 	}
@@ -883,7 +1128,7 @@ func setEnv(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	if err != nil {
 		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
 	}
-	if len(e.Envs) == 0 {
+	if len(e.Envs) == 0 && len(e.Unset) == 0 {
 		msg := "You must provide the list of environment variables"
 		return &errors.HTTP{Code: http.StatusBadRequest, Message: msg}
 	}
@@ -898,6 +1143,14 @@ func setEnv(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
+	if len(e.Unset) > 0 {
+		allowed = permission.Check(t, permission.PermAppUpdateEnvUnset,
+			contextsForApp(&a)...,
+		)
+		if !allowed {
+			return permission.ErrUnauthorized
+		}
+	}
 	if e.Private {
 		for i := 0; i < len(e.Envs); i++ {
 			r.Form.Set(fmt.Sprintf("Envs.%d.Value", i), "*****")
@@ -914,18 +1167,17 @@ func setEnv(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 		return err
 	}
 	defer func() { evt.Done(err) }()
-	envs := map[string]string{}
 	variables := []bind.EnvVar{}
 	for _, v := range e.Envs {
-		envs[v.Name] = v.Value
-		variables = append(variables, bind.EnvVar{Name: v.Name, Value: v.Value, Public: !e.Private})
+		variables = append(variables, bind.EnvVar{Name: v.Name, Value: v.Value, Public: !e.Private, Interpolate: e.Interpolate})
 	}
 	w.Header().Set("Content-Type", "application/x-json-stream")
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer keepAliveWriter.Stop()
 	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
-	return a.SetEnvs(bind.SetEnvArgs{
-		Envs:          variables,
+	return a.UpdateEnvs(app.UpdateEnvsArgs{
+		Set:           variables,
+		Unset:         e.Unset,
 		ShouldRestart: !e.NoRestart,
 		Writer:        writer,
 	})
@@ -956,12 +1208,6 @@ func unsetEnv(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 	if err != nil {
 		return err
 	}
-	allowed := permission.Check(t, permission.PermAppUpdateEnvUnset,
-		contextsForApp(&a)...,
-	)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	evt, err := event.New(&event.Opts{
 		Target:     appTarget(appName),
 		Kind:       permission.PermAppUpdateEnvUnset,
@@ -1009,12 +1255,6 @@ func setCName(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 	if err != nil {
 		return err
 	}
-	allowed := permission.Check(t, permission.PermAppUpdateCnameAdd,
-		contextsForApp(&a)...,
-	)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	evt, err := event.New(&event.Opts{
 		Target:     appTarget(appName),
 		Kind:       permission.PermAppUpdateCnameAdd,
@@ -1029,7 +1269,7 @@ func setCName(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 	if err = a.AddCName(cnames...); err == nil {
 		return nil
 	}
-	if err.Error() == "Invalid cname" {
+	if errors.IsValidation(err) {
 		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
 	}
 	return err
@@ -1055,12 +1295,6 @@ func unsetCName(w http.ResponseWriter, r *http.Request, t auth.Token) (err error
 	if err != nil {
 		return err
 	}
-	allowed := permission.Check(t, permission.PermAppUpdateCnameRemove,
-		contextsForApp(&a)...,
-	)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	evt, err := event.New(&event.Opts{
 		Target:     appTarget(appName),
 		Kind:       permission.PermAppUpdateCnameRemove,
@@ -1075,7 +1309,7 @@ func unsetCName(w http.ResponseWriter, r *http.Request, t auth.Token) (err error
 	if err = a.RemoveCName(cnames...); err == nil {
 		return nil
 	}
-	if err.Error() == "Invalid cname" {
+	if errors.IsValidation(err) {
 		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
 	}
 	return err
@@ -1112,12 +1346,6 @@ func appLog(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if err != nil {
 		return err
 	}
-	allowed := permission.Check(t, permission.PermAppReadLog,
-		contextsForApp(&a)...,
-	)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	logs, err := a.LastLogs(lines, filterLog)
 	if err != nil {
 		return err
@@ -1165,6 +1393,73 @@ func appLog(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	return nil
 }
 
+// title: app log stream
+// path: /apps/{app}/log/stream
+// method: GET
+// produce: application/json
+// responses:
+//   101: Switching protocols
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App not found
+func appLogStream(conn *wsConn, r *http.Request, t auth.Token) error {
+	var lines int
+	if l := r.URL.Query().Get("lines"); l != "" {
+		var err error
+		lines, err = strconv.Atoi(l)
+		if err != nil {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: `Parameter "lines" must be an integer.`}
+		}
+	}
+	appName := r.URL.Query().Get(":app")
+	filterLog := app.Applog{Source: r.URL.Query().Get("source"), Unit: r.URL.Query().Get("unit")}
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppReadLog,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	l, err := app.NewLogListener(&a, filterLog)
+	if err != nil {
+		return err
+	}
+	logTracker.add(l)
+	defer func() {
+		logTracker.remove(l)
+		l.Close()
+	}()
+	if lines > 0 {
+		logs, err := a.LastLogs(lines, filterLog)
+		if err != nil {
+			return err
+		}
+		for _, logMsg := range logs {
+			if err = conn.SendJSON(logMsg); err != nil {
+				return nil
+			}
+		}
+	}
+	go conn.CloseOnClientEOF()
+	logChan := l.ListenChan()
+	for {
+		select {
+		case <-conn.Done():
+			return nil
+		case logMsg, ok := <-logChan:
+			if !ok {
+				return nil
+			}
+			if err = conn.SendJSON(logMsg); err != nil {
+				return nil
+			}
+		}
+	}
+}
+
 func getServiceInstance(serviceName, instanceName, appName string) (*service.ServiceInstance, *app.App, error) {
 	var app app.App
 	conn, err := db.Conn()
@@ -1184,6 +1479,10 @@ func getServiceInstance(serviceName, instanceName, appName string) (*service.Ser
 	return instance, &app, nil
 }
 
+// maxBindWait is the longest time, in seconds, a bind waits for the service
+// instance to become ready.
+const maxBindWait = 600
+
 // title: bind service instance
 // path: /services/{service}/instances/{instance}/{app}
 // method: PUT
@@ -1193,12 +1492,21 @@ func getServiceInstance(serviceName, instanceName, appName string) (*service.Ser
 //   200: Ok
 //   400: Invalid data
 //   401: Unauthorized
+//   403: Service not allowed in the app pool
 //   404: App not found
 func bindServiceInstance(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	instanceName := r.URL.Query().Get(":instance")
 	appName := r.URL.Query().Get(":app")
 	serviceName := r.URL.Query().Get(":service")
 	noRestart, _ := strconv.ParseBool(r.FormValue("noRestart"))
+	var wait int
+	if waitStr := r.FormValue("wait"); waitStr != "" {
+		wait, err = strconv.Atoi(waitStr)
+		if err != nil || wait < 0 || wait > maxBindWait {
+			msg := fmt.Sprintf("wait must be a number of seconds between 0 and %d", maxBindWait)
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: msg}
+		}
+	}
 	instance, a, err := getServiceInstance(serviceName, instanceName, appName)
 	if err != nil {
 		return err
@@ -1220,7 +1528,11 @@ func bindServiceInstance(w http.ResponseWriter, r *http.Request, t auth.Token) (
 	err = a.ValidateService(serviceName)
 	if err != nil {
 		if err == pool.ErrPoolHasNoService {
-			return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+			msg := fmt.Sprintf("service %q is not available for pool %q: the pool constraints do not allow binding any services", serviceName, a.Pool)
+			return &errors.HTTP{Code: http.StatusForbidden, Message: msg}
+		}
+		if errors.IsValidation(err) {
+			return &errors.HTTP{Code: http.StatusForbidden, Message: err.Error()}
 		}
 		return err
 	}
@@ -1239,7 +1551,7 @@ func bindServiceInstance(w http.ResponseWriter, r *http.Request, t auth.Token) (
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer keepAliveWriter.Stop()
 	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
-	err = instance.BindApp(a, !noRestart, writer)
+	err = instance.BindAppWaitReady(a, !noRestart, writer, time.Duration(wait)*time.Second)
 	if err != nil {
 		return err
 	}
@@ -1325,12 +1637,6 @@ func restart(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	if err != nil {
 		return err
 	}
-	allowed := permission.Check(t, permission.PermAppUpdateRestart,
-		contextsForApp(&a)...,
-	)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	evt, err := event.New(&event.Opts{
 		Target:     appTarget(appName),
 		Kind:       permission.PermAppUpdateRestart,
@@ -1349,6 +1655,133 @@ func restart(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	return a.Restart(process, writer)
 }
 
+func inspectAppUnit(a *app.App, unitID string) (*provision.UnitInfo, error) {
+	info, err := a.InspectUnit(unitID)
+	if err != nil {
+		switch err.(type) {
+		case *provision.UnitNotFoundError:
+			return nil, &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+		case provision.ProvisionerNotSupported:
+			return nil, &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+		}
+		return nil, err
+	}
+	return info, nil
+}
+
+// title: unit info
+// path: /apps/{app}/units/{unit}
+// method: GET
+// produce: application/json
+// responses:
+//   200: Ok
+//   400: Not supported by provisioner
+//   401: Unauthorized
+//   404: App or unit not found
+func unitInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	info, err := inspectAppUnit(&a, r.URL.Query().Get(":unit"))
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(info)
+}
+
+// title: unit restart
+// path: /apps/{app}/units/{unit}/restart
+// method: POST
+// produce: application/x-json-stream
+// responses:
+//   200: Ok
+//   400: Not supported by provisioner
+//   401: Unauthorized
+//   404: App or unit not found
+func unitRestart(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	appName := r.URL.Query().Get(":app")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return err
+	}
+	info, err := inspectAppUnit(&a, r.URL.Query().Get(":unit"))
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppUpdateRestart,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.URL.Query()),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	w.Header().Set("Content-Type", "application/x-json-stream")
+	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
+	defer keepAliveWriter.Stop()
+	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	return a.RestartUnit(info.Unit.ID, writer)
+}
+
+// title: unit resources set
+// path: /apps/{app}/units/{unit}/resources
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Ok
+//   400: Invalid data or not supported by provisioner
+//   401: Unauthorized
+//   404: App or unit not found
+func unitResourcesSet(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	err = r.ParseForm()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	appName := r.URL.Query().Get(":app")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return err
+	}
+	var resources provision.UnitResources
+	if memory := r.FormValue("memory"); memory != "" {
+		resources.Memory = getSize(memory)
+		if resources.Memory <= 0 {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid memory, it must be a positive size, like 512M or 1G"}
+		}
+	}
+	if cpuShare := r.FormValue("cpushare"); cpuShare != "" {
+		resources.CpuShare, err = strconv.Atoi(cpuShare)
+		if err != nil || resources.CpuShare <= 0 {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid cpushare, it must be a positive number"}
+		}
+	}
+	info, err := inspectAppUnit(&a, r.URL.Query().Get(":unit"))
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppAdminResources,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = a.SetUnitResources(info.Unit.ID, resources)
+	if _, ok := err.(provision.ProvisionerNotSupported); ok {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return err
+}
+
 // title: app sleep
 // path: /apps/{app}/sleep
 // method: POST
@@ -1372,15 +1805,9 @@ func sleep(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	}
 	proxyURL, err := url.Parse(proxy)
 	if err != nil {
-		log.Errorf("Invalid url for proxy param: %v", proxy)
+		logger.Errorf("Invalid url for proxy param: %v", proxy)
 		return err
 	}
-	allowed := permission.Check(t, permission.PermAppUpdateSleep,
-		contextsForApp(&a)...,
-	)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	evt, err := event.New(&event.Opts{
 		Target:     appTarget(appName),
 		Kind:       permission.PermAppUpdateSleep,
@@ -1556,12 +1983,6 @@ func start(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	if err != nil {
 		return err
 	}
-	allowed := permission.Check(t, permission.PermAppUpdateStart,
-		contextsForApp(&a)...,
-	)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	evt, err := event.New(&event.Opts{
 		Target:     appTarget(appName),
 		Kind:       permission.PermAppUpdateStart,
@@ -1596,12 +2017,6 @@ func stop(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	if err != nil {
 		return err
 	}
-	allowed := permission.Check(t, permission.PermAppUpdateStop,
-		contextsForApp(&a)...,
-	)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	evt, err := event.New(&event.Opts{
 		Target:     appTarget(appName),
 		Kind:       permission.PermAppUpdateStop,
@@ -1635,12 +2050,6 @@ func forceDeleteLock(w http.ResponseWriter, r *http.Request, t auth.Token) (err
 	if err != nil {
 		return err
 	}
-	allowed := permission.Check(t, permission.PermAppAdminUnlock,
-		contextsForApp(&a)...,
-	)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	evt, err := event.New(&event.Opts{
 		Target:     appTarget(appName),
 		Kind:       permission.PermAppAdminUnlock,
@@ -1706,7 +2115,7 @@ func registerUnit(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 		}
 		return err
 	}
-	return writeEnvVars(w, a)
+	return writeEnvVars(w, a, true)
 }
 
 // title: metric envs
@@ -1722,12 +2131,6 @@ func appMetricEnvs(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if err != nil {
 		return err
 	}
-	allowed := permission.Check(t, permission.PermAppReadMetric,
-		contextsForApp(&a)...,
-	)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	w.Header().Set("Content-Type", "application/json")
 	metricMap, err := a.MetricEnvs()
 	if err != nil {
@@ -1736,6 +2139,46 @@ func appMetricEnvs(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	return json.NewEncoder(w).Encode(metricMap)
 }
 
+// title: app metrics
+// path: /apps/{app}/metrics
+// method: GET
+// produce: application/json
+// responses:
+//   200: Ok
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App not found
+//   412: Metrics backend not configured
+func appMetrics(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	opts := metrics.Options{Metrics: r.URL.Query()["metric"]}
+	for name, value := range map[string]*time.Duration{"window": &opts.Window, "interval": &opts.Interval} {
+		raw := r.URL.Query().Get(name)
+		if raw == "" {
+			continue
+		}
+		*value, err = time.ParseDuration(raw)
+		if err != nil {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("invalid %s: %s", name, err)}
+		}
+	}
+	series, err := metrics.AppMetrics(a.Name, opts)
+	if err == metrics.ErrNotConfigured {
+		return &errors.HTTP{Code: http.StatusPreconditionFailed, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	if series == nil {
+		series = []metrics.Series{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(series)
+}
+
 // title: rebuild routes
 // path: /apps/{app}/routes
 // method: POST
@@ -1749,12 +2192,6 @@ func appRebuildRoutes(w http.ResponseWriter, r *http.Request, t auth.Token) (err
 	if err != nil {
 		return err
 	}
-	allowed := permission.Check(t, permission.PermAppAdminRoutes,
-		contextsForApp(&a)...,
-	)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	dry, _ := strconv.ParseBool(r.FormValue("dry"))
 	evt, err := event.New(&event.Opts{
 		Target:     appTarget(a.Name),
@@ -1790,12 +2227,6 @@ func setCertificate(w http.ResponseWriter, r *http.Request, t auth.Token) (err e
 	if err != nil {
 		return err
 	}
-	allowed := permission.Check(t, permission.PermAppUpdateCertificateSet,
-		contextsForApp(&a)...,
-	)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	err = r.ParseForm()
 	if err != nil {
 		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
@@ -1839,12 +2270,6 @@ func unsetCertificate(w http.ResponseWriter, r *http.Request, t auth.Token) (err
 	if err != nil {
 		return err
 	}
-	allowed := permission.Check(t, permission.PermAppUpdateCertificateUnset,
-		contextsForApp(&a)...,
-	)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	err = r.ParseForm()
 	if err != nil {
 		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
@@ -1884,12 +2309,6 @@ func listCertificates(w http.ResponseWriter, r *http.Request, t auth.Token) erro
 	if err != nil {
 		return err
 	}
-	allowed := permission.Check(t, permission.PermAppReadCertificate,
-		contextsForApp(&a)...,
-	)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	w.Header().Set("Content-Type", "application/json")
 	result, err := a.GetCertificates()
 	if err != nil {
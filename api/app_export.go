@@ -0,0 +1,125 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ajg/form"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/quota"
+	"github.com/tsuru/tsuru/service"
+)
+
+// title: app export
+// path: /apps/{app}/export
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: App not found
+func appExport(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	exp, err := a.Export()
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(exp)
+}
+
+// title: app import
+// path: /apps/import
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/json
+// responses:
+//   201: App imported
+//   400: Invalid data
+//   401: Unauthorized
+//   403: Quota exceeded
+//   409: App already exists
+func appImport(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	err = r.ParseForm()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	var exp app.AppExport
+	err = json.Unmarshal([]byte(r.FormValue("export")), &exp)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid export: " + err.Error()}
+	}
+	var opts app.ImportOptions
+	dec := form.NewDecoder(nil)
+	dec.IgnoreCase(true)
+	dec.IgnoreUnknownKeys(true)
+	err = dec.DecodeValues(&opts, r.Form)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	teamOwner := opts.TeamOwner
+	if teamOwner == "" {
+		teamOwner = exp.TeamOwner
+	}
+	if !permission.Check(t, permission.PermAppCreate, permission.Context(permission.CtxTeam, teamOwner)) {
+		return permission.ErrUnauthorized
+	}
+	for _, b := range exp.Binds {
+		si, errGet := service.GetServiceInstance(b.Service, b.Instance)
+		if errGet == service.ErrServiceInstanceNotFound {
+			continue
+		}
+		if errGet != nil {
+			return errGet
+		}
+		if !permission.Check(t, permission.PermServiceInstanceUpdateBind, contextsForServiceInstance(si, b.Service)...) {
+			return permission.ErrUnauthorized
+		}
+	}
+	u, err := t.User()
+	if err != nil {
+		return err
+	}
+	appName := opts.Name
+	if appName == "" {
+		appName = exp.Name
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppCreate,
+		Owner:      t,
+		CustomData: map[string]interface{}{"source": exp.Name, "image": exp.Image, "options": opts},
+		Allowed:    event.Allowed(permission.PermAppReadEvents, permission.Context(permission.CtxTeam, teamOwner), permission.Context(permission.CtxApp, appName)),
+	})
+	if err != nil {
+		return err
+	}
+	var result *app.ImportResult
+	defer func() { evt.DoneCustomData(err, result) }()
+	result, err = app.ImportApp(&exp, opts, u, nil)
+	if err != nil {
+		if e, ok := err.(*app.AppCreationError); ok {
+			if e.Err == app.ErrAppAlreadyExists {
+				return &errors.HTTP{Code: http.StatusConflict, Message: e.Error()}
+			}
+			if _, ok := e.Err.(*quota.QuotaExceededError); ok {
+				return &errors.HTTP{Code: http.StatusForbidden, Message: "Quota exceeded"}
+			}
+		}
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(result)
+}
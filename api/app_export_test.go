@@ -0,0 +1,109 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAppExport(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name, Env: map[string]bind.EnvVar{
+		"LOG_LEVEL": {Name: "LOG_LEVEL", Value: "info", Public: true},
+		"DB_PASS":   {Name: "DB_PASS", Value: "s3cr3t"},
+	}}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/apps/myapp/export", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var exp app.AppExport
+	err = json.NewDecoder(recorder.Body).Decode(&exp)
+	c.Assert(err, check.IsNil)
+	c.Assert(exp.Name, check.Equals, "myapp")
+	c.Assert(exp.Platform, check.Equals, "zend")
+	c.Assert(exp.Env, check.DeepEquals, []bind.EnvVar{{Name: "LOG_LEVEL", Value: "info", Public: true}})
+	c.Assert(exp.PrivateEnvs, check.DeepEquals, []string{"DB_PASS"})
+	c.Assert(recorder.Body.String(), check.Not(check.Matches), `(?s).*s3cr3t.*`)
+}
+
+func (s *S) TestAppExportWithoutPermission(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppRead,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	request, err := http.NewRequest("GET", "/apps/myapp/export", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestAppImport(c *check.C) {
+	data, err := json.Marshal(app.AppExport{
+		Version:   app.ExportVersion,
+		Name:      "myapp",
+		Platform:  "zend",
+		TeamOwner: "team-from-other-installation",
+		Env:       []bind.EnvVar{{Name: "LOG_LEVEL", Value: "info", Public: true}},
+	})
+	c.Assert(err, check.IsNil)
+	values := url.Values{"export": {string(data)}, "name": {"newapp"}, "teamowner": {s.team.Name}}
+	request, err := http.NewRequest("POST", "/apps/import", strings.NewReader(values.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	var result app.ImportResult
+	err = json.NewDecoder(recorder.Body).Decode(&result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, app.ImportResult{App: "newapp", Envs: 1})
+	a, err := app.GetByName("newapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(a.TeamOwner, check.Equals, s.team.Name)
+	c.Assert(a.Env["LOG_LEVEL"].Value, check.Equals, "info")
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget("newapp"),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.create",
+	}, eventtest.HasEvent)
+	recorder = httptest.NewRecorder()
+	request, err = http.NewRequest("POST", "/apps/import", strings.NewReader(values.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+}
+
+func (s *S) TestAppImportInvalidExport(c *check.C) {
+	request, err := http.NewRequest("POST", "/apps/import", strings.NewReader("export=not-json"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Matches, "invalid export: .*\n")
+}
@@ -0,0 +1,157 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ajg/form"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+)
+
+// title: app template list
+// path: /app-templates
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+func appTemplateList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermAppTemplateRead) {
+		return permission.ErrUnauthorized
+	}
+	templates, err := app.ListTemplates()
+	if err != nil {
+		return err
+	}
+	if len(templates) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(templates)
+}
+
+// title: app template info
+// path: /app-templates/{name}
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: Template not found
+func appTemplateInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermAppTemplateRead) {
+		return permission.ErrUnauthorized
+	}
+	tpl, err := app.GetTemplate(r.URL.Query().Get(":name"))
+	if err == app.ErrTemplateNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(tpl)
+}
+
+// title: app template update
+// path: /app-templates/{name}
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: OK
+//   400: Invalid data
+//   401: Unauthorized
+func appTemplateUpdate(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermAppTemplateUpdate) {
+		return permission.ErrUnauthorized
+	}
+	err = r.ParseForm()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	var tpl app.Template
+	dec := form.NewDecoder(nil)
+	dec.IgnoreUnknownKeys(true)
+	dec.IgnoreCase(true)
+	err = dec.DecodeValues(&tpl, r.Form)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	tpl.Name = r.URL.Query().Get(":name")
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeAppTemplate, Value: tpl.Name},
+		Kind:       permission.PermAppTemplateUpdate,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppTemplateReadEvents),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return app.SaveTemplate(tpl)
+}
+
+// title: app template delete
+// path: /app-templates/{name}
+// method: DELETE
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: Template not found
+func appTemplateDelete(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermAppTemplateDelete) {
+		return permission.ErrUnauthorized
+	}
+	name := r.URL.Query().Get(":name")
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeAppTemplate, Value: name},
+		Kind:       permission.PermAppTemplateDelete,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppTemplateReadEvents),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = app.RemoveTemplate(name)
+	if err == app.ErrTemplateNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
+
+// checkTemplateServices checks whether the user is allowed to create the
+// service instances of the template for the given team.
+func checkTemplateServices(t auth.Token, tpl *app.Template, team string) error {
+	if len(tpl.Services) == 0 {
+		return nil
+	}
+	allowed := permission.Check(t, permission.PermServiceInstanceCreate,
+		permission.Context(permission.CtxTeam, team),
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	for _, s := range tpl.Services {
+		srv, err := getService(s.Service)
+		if err != nil {
+			return err
+		}
+		if isRestricted(&srv) && !permission.Check(t, permission.PermServiceRead, contextsForService(&srv)...) {
+			return permission.ErrUnauthorized
+		}
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/service"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) createTemplateService(c *check.C) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"DATABASE_HOST":"localhost"}`))
+	}))
+	srvc := service.Service{Name: "mysql", Endpoint: map[string]string{"production": ts.URL}, Password: "abcde", OwnerTeams: []string{s.team.Name}}
+	err := srvc.Create()
+	c.Assert(err, check.IsNil)
+	return ts
+}
+
+func (s *S) TestAppTemplateUpdate(c *check.C) {
+	ts := s.createTemplateService(c)
+	defer ts.Close()
+	body := strings.NewReader("description=python+stack&platform=python&env.LOG_LEVEL=info&services.0.service=mysql&services.0.plan=small")
+	request, err := http.NewRequest("PUT", "/app-templates/python-stack", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	tpl, err := app.GetTemplate("python-stack")
+	c.Assert(err, check.IsNil)
+	c.Assert(tpl, check.DeepEquals, &app.Template{
+		Name:        "python-stack",
+		Description: "python stack",
+		Platform:    "python",
+		Env:         map[string]string{"LOG_LEVEL": "info"},
+		Services:    []app.TemplateService{{Service: "mysql", Plan: "small"}},
+	})
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeAppTemplate, Value: "python-stack"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "app-template.update",
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestAppTemplateUpdateInvalid(c *check.C) {
+	body := strings.NewReader("services.0.service=unknown")
+	request, err := http.NewRequest("PUT", "/app-templates/python-stack", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Matches, `(?s).*service "unknown" not found.*`)
+}
+
+func (s *S) TestAppTemplateUpdateWithoutPermission(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppTemplateRead,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	request, err := http.NewRequest("PUT", "/app-templates/python-stack", strings.NewReader("platform=python"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestAppTemplateListAndInfo(c *check.C) {
+	err := app.SaveTemplate(app.Template{Name: "b-stack", Platform: "python"})
+	c.Assert(err, check.IsNil)
+	err = app.SaveTemplate(app.Template{Name: "a-stack", Platform: "go"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/app-templates", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var templates []app.Template
+	err = json.Unmarshal(recorder.Body.Bytes(), &templates)
+	c.Assert(err, check.IsNil)
+	c.Assert(templates, check.HasLen, 2)
+	c.Assert(templates[0].Name, check.Equals, "a-stack")
+	c.Assert(templates[1].Name, check.Equals, "b-stack")
+	request, err = http.NewRequest("GET", "/app-templates/b-stack", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var tpl app.Template
+	err = json.Unmarshal(recorder.Body.Bytes(), &tpl)
+	c.Assert(err, check.IsNil)
+	c.Assert(tpl, check.DeepEquals, app.Template{Name: "b-stack", Platform: "python"})
+}
+
+func (s *S) TestAppTemplateListEmpty(c *check.C) {
+	request, err := http.NewRequest("GET", "/app-templates", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestAppTemplateInfoNotFound(c *check.C) {
+	request, err := http.NewRequest("GET", "/app-templates/unknown", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestAppTemplateDelete(c *check.C) {
+	err := app.SaveTemplate(app.Template{Name: "python-stack"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", "/app-templates/python-stack", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	_, err = app.GetTemplate("python-stack")
+	c.Assert(err, check.Equals, app.ErrTemplateNotFound)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeAppTemplate, Value: "python-stack"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "app-template.delete",
+	}, eventtest.HasEvent)
+	recorder = httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestCreateAppFromTemplate(c *check.C) {
+	ts := s.createTemplateService(c)
+	defer ts.Close()
+	err := app.SaveTemplate(app.Template{
+		Name:     "php-stack",
+		Platform: "zend",
+		Env:      map[string]string{"LOG_LEVEL": "info"},
+		Services: []app.TemplateService{{Service: "mysql"}},
+	})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppCreate,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	}, permission.Permission{
+		Scheme:  permission.PermServiceInstanceCreate,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	request, err := http.NewRequest("POST", "/apps?template=php-stack", strings.NewReader("name=someapp"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated, check.Commentf("body: %s", recorder.Body.String()))
+	var obtained map[string]string
+	err = json.Unmarshal(recorder.Body.Bytes(), &obtained)
+	c.Assert(err, check.IsNil)
+	c.Assert(obtained["template"], check.Equals, "php-stack")
+	var gotApp app.App
+	err = s.conn.Apps().Find(bson.M{"name": "someapp"}).One(&gotApp)
+	c.Assert(err, check.IsNil)
+	c.Assert(gotApp.Platform, check.Equals, "zend")
+	envs := gotApp.Envs()
+	c.Assert(envs["LOG_LEVEL"], check.DeepEquals, bind.EnvVar{Name: "LOG_LEVEL", Value: "info", Public: true})
+	c.Assert(envs["DATABASE_HOST"].Value, check.Equals, "localhost")
+	var instance service.ServiceInstance
+	err = s.conn.ServiceInstances().Find(bson.M{"name": "someapp-mysql", "service_name": "mysql"}).One(&instance)
+	c.Assert(err, check.IsNil)
+	c.Assert(instance.TeamOwner, check.Equals, s.team.Name)
+	c.Assert(instance.Apps, check.DeepEquals, []string{"someapp"})
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget("someapp"),
+		Owner:  token.GetUserName(),
+		Kind:   "app.create",
+		EndCustomData: map[string]interface{}{
+			"template":  "php-stack",
+			"envs":      []string{"LOG_LEVEL"},
+			"instances": []string{"mysql/someapp-mysql"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestCreateAppFromTemplateNotFound(c *check.C) {
+	request, err := http.NewRequest("POST", "/apps?template=unknown", strings.NewReader("name=someapp"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, app.ErrTemplateNotFound.Error()+"\n")
+}
+
+func (s *S) TestCreateAppFromTemplateWithoutServicePermission(c *check.C) {
+	ts := s.createTemplateService(c)
+	defer ts.Close()
+	err := app.SaveTemplate(app.Template{Name: "php-stack", Services: []app.TemplateService{{Service: "mysql"}}})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppCreate,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	request, err := http.NewRequest("POST", "/apps?template=php-stack", strings.NewReader("name=someapp"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	_, err = app.GetByName("someapp")
+	c.Assert(err, check.Equals, app.ErrAppNotFound)
+}
@@ -25,9 +25,11 @@ import (
 	"github.com/tsuru/tsuru/api/types"
 	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/app/metrics"
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/event/eventtest"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/permission/permissiontest"
@@ -43,6 +45,7 @@ import (
 	"github.com/tsuru/tsuru/service"
 	appTypes "github.com/tsuru/tsuru/types/app"
 	authTypes "github.com/tsuru/tsuru/types/auth"
+	"golang.org/x/net/websocket"
 	"gopkg.in/check.v1"
 	"gopkg.in/mgo.v2/bson"
 )
@@ -613,6 +616,54 @@ func (s *S) TestAppListUnitsError(c *check.C) {
 	c.Assert(apps[0].Error, check.Equals, "unable to list app units: some units error")
 }
 
+func (s *S) TestAppListUnitCountsAndLastDeploy(c *check.C) {
+	app1 := app.App{Name: "app1", TeamOwner: s.team.Name}
+	err := app.CreateApp(&app1, s.user)
+	c.Assert(err, check.IsNil)
+	app2 := app.App{Name: "app2", TeamOwner: s.team.Name}
+	err = app.CreateApp(&app2, s.user)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddUnits(&app1, 3, "web", nil)
+	c.Assert(err, check.IsNil)
+	units, err := s.provisioner.Units(&app1)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.SetUnitStatus(units[0], provision.StatusError)
+	c.Assert(err, check.IsNil)
+	evt, err := event.New(&event.Opts{
+		Target:  appTarget(app1.Name),
+		Kind:    permission.PermAppDeploy,
+		Owner:   s.token,
+		Allowed: event.Allowed(permission.PermAppReadEvents, contextsForApp(&app1)...),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/apps", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var apps []struct {
+		Name       string
+		UnitCounts map[provision.Status]int
+		LastDeploy *time.Time
+	}
+	err = json.Unmarshal(recorder.Body.Bytes(), &apps)
+	c.Assert(err, check.IsNil)
+	c.Assert(apps, check.HasLen, 2)
+	c.Assert(apps[0].Name, check.Equals, app1.Name)
+	c.Assert(apps[0].UnitCounts, check.DeepEquals, map[provision.Status]int{
+		provision.StatusStarted: 2,
+		provision.StatusError:   1,
+	})
+	c.Assert(apps[0].LastDeploy, check.NotNil)
+	c.Assert(apps[0].LastDeploy.Unix(), check.Equals, evt.EndTime.Unix())
+	c.Assert(apps[1].Name, check.Equals, app2.Name)
+	c.Assert(apps[1].UnitCounts, check.DeepEquals, map[provision.Status]int{})
+	c.Assert(apps[1].LastDeploy, check.IsNil)
+}
+
 func (s *S) TestAppListShouldListAllAppsOfAllTeamsThatTheUserHasPermission(c *check.C) {
 	team := authTypes.Team{Name: "angra"}
 	err := auth.TeamService().Insert(team)
@@ -689,6 +740,245 @@ func (s *S) TestDelete(c *check.C) {
 	c.Assert(err, check.NotNil)
 }
 
+func (s *S) TestDeleteWithGracePeriod(c *check.C) {
+	config.Set("apps:delete-grace-period", 3600)
+	defer config.Unset("apps:delete-grace-period")
+	myApp := &app.App{
+		Name:      "myapptodelete",
+		Platform:  "zend",
+		TeamOwner: s.team.Name,
+	}
+	err := app.CreateApp(myApp, s.user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", "/apps/"+myApp.Name, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), check.Matches, `(?s).*Application \\"myapptodelete\\" removed, it may be restored until .*`)
+	_, err = app.GetByName(myApp.Name)
+	c.Assert(err, check.Equals, app.ErrAppNotFound)
+	deleted, err := app.GetDeletedByName(myApp.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(deleted.DeletedBy, check.Equals, s.token.GetUserName())
+	_, err = repository.Manager().GetRepository(myApp.Name)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestRestore(c *check.C) {
+	config.Set("apps:delete-grace-period", 3600)
+	defer config.Unset("apps:delete-grace-period")
+	myApp := &app.App{
+		Name:      "myapptorestore",
+		Platform:  "zend",
+		TeamOwner: s.team.Name,
+	}
+	err := app.CreateApp(myApp, s.user)
+	c.Assert(err, check.IsNil)
+	err = app.SoftDelete(myApp, s.user.Email, nil)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/apps/"+myApp.Name+"/restore", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/x-json-stream")
+	_, err = app.GetByName(myApp.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(myApp.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.restore",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":app", "value": myApp.Name},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestRestoreLocked(c *check.C) {
+	config.Set("apps:delete-grace-period", 3600)
+	defer config.Unset("apps:delete-grace-period")
+	myApp := &app.App{
+		Name:      "myapptorestore",
+		Platform:  "zend",
+		TeamOwner: s.team.Name,
+	}
+	err := app.CreateApp(myApp, s.user)
+	c.Assert(err, check.IsNil)
+	err = app.SoftDelete(myApp, s.user.Email, nil)
+	c.Assert(err, check.IsNil)
+	locked, err := app.AcquireApplicationLock(myApp.Name, "someone", "POST /apps/myapptorestore/restore")
+	c.Assert(err, check.IsNil)
+	c.Assert(locked, check.Equals, true)
+	defer app.ReleaseApplicationLock(myApp.Name)
+	oldDuration := lockWaitDuration
+	lockWaitDuration = 0
+	defer func() { lockWaitDuration = oldDuration }()
+	request, err := http.NewRequest("POST", "/apps/"+myApp.Name+"/restore", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+	_, err = app.GetDeletedByName(myApp.Name)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestRestoreNotDeleted(c *check.C) {
+	myApp := &app.App{
+		Name:      "myapptorestore",
+		Platform:  "zend",
+		TeamOwner: s.team.Name,
+	}
+	err := app.CreateApp(myApp, s.user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/apps/"+myApp.Name+"/restore", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestRestoreWithoutPermission(c *check.C) {
+	myApp := &app.App{
+		Name:      "myapptorestore",
+		Platform:  "zend",
+		TeamOwner: s.team.Name,
+	}
+	err := app.CreateApp(myApp, s.user)
+	c.Assert(err, check.IsNil)
+	err = app.SoftDelete(myApp, s.user.Email, nil)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppRead,
+		Context: permission.Context(permission.CtxApp, myApp.Name),
+	})
+	request, err := http.NewRequest("POST", "/apps/"+myApp.Name+"/restore", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestAppClone(c *check.C) {
+	myApp := &app.App{
+		Name:      "myapptoclone",
+		Platform:  "zend",
+		TeamOwner: s.team.Name,
+	}
+	err := app.CreateApp(myApp, s.user)
+	c.Assert(err, check.IsNil)
+	err = myApp.SetEnvs(bind.SetEnvArgs{Envs: []bind.EnvVar{
+		{Name: "PUBLIC_VAR", Value: "public", Public: true},
+		{Name: "PRIVATE_VAR", Value: "private"},
+	}})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/apps/"+myApp.Name+"/clone?name=myapptoclone-review", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var msg map[string]interface{}
+	err = json.Unmarshal(recorder.Body.Bytes(), &msg)
+	c.Assert(err, check.IsNil)
+	c.Assert(msg["cloned_from"], check.Equals, myApp.Name)
+	c.Assert(msg["envs"], check.DeepEquals, []interface{}{"PUBLIC_VAR"})
+	clone, err := app.GetByName("myapptoclone-review")
+	c.Assert(err, check.IsNil)
+	c.Assert(clone.Platform, check.Equals, "zend")
+	c.Assert(clone.TeamOwner, check.Equals, s.team.Name)
+	c.Assert(clone.Env["PUBLIC_VAR"].Value, check.Equals, "public")
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget("myapptoclone-review"),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.create",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":app", "value": myApp.Name},
+			{"name": "name", "value": "myapptoclone-review"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestAppCloneWithoutName(c *check.C) {
+	myApp := &app.App{Name: "myapptoclone", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(myApp, s.user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/apps/"+myApp.Name+"/clone", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+}
+
+func (s *S) TestAppCloneAlreadyExists(c *check.C) {
+	myApp := &app.App{Name: "myapptoclone", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(myApp, s.user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/apps/"+myApp.Name+"/clone?name="+myApp.Name, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+}
+
+func (s *S) TestAppCloneWithoutPermission(c *check.C) {
+	myApp := &app.App{Name: "myapptoclone", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(myApp, s.user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppRead,
+		Context: permission.Context(permission.CtxApp, myApp.Name),
+	})
+	request, err := http.NewRequest("POST", "/apps/"+myApp.Name+"/clone?name=myapptoclone-review", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestDeleteDryRun(c *check.C) {
+	myApp := &app.App{
+		Name:      "myapptodelete",
+		Platform:  "zend",
+		TeamOwner: s.team.Name,
+	}
+	err := app.CreateApp(myApp, s.user)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddUnits(myApp, 1, "web", nil)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", "/apps/"+myApp.Name+"?dry-run=true", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var actions []string
+	err = json.Unmarshal(recorder.Body.Bytes(), &actions)
+	c.Assert(err, check.IsNil)
+	c.Assert(actions, check.HasLen, 9)
+	c.Assert(actions[0], check.Equals, `destroy unit "myapptodelete-0" in provisioner "fake"`)
+	c.Assert(actions[7], check.Equals, `remove app "myapptodelete" from database`)
+	c.Assert(actions[8], check.Equals, `remove jobs of app "myapptodelete"`)
+	_, err = app.GetByName(myApp.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.provisioner.GetUnits(myApp), check.HasLen, 1)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(myApp.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.delete",
+	}, check.Not(eventtest.HasEvent))
+}
+
 func (s *S) TestDeleteShouldReturnForbiddenIfTheGivenUserDoesNotHaveAccessToTheApp(c *check.C) {
 	myApp := app.App{Name: "app-to-delete", Platform: "zend"}
 	err := s.conn.Apps().Insert(myApp)
@@ -774,6 +1064,45 @@ func (s *S) TestAppInfoReturnsForbiddenWhenTheUserDoesNotHaveAccessToTheApp(c *c
 	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
 }
 
+func (s *S) TestAppInfoHardIsolationNotReadable(c *check.C) {
+	config.Set("multi-tenancy:hard-isolation", true)
+	defer config.Unset("multi-tenancy")
+	expectedApp := app.App{Name: "new-app", Platform: "zend"}
+	err := s.conn.Apps().Insert(expectedApp)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppRead,
+		Context: permission.Context(permission.CtxApp, "-other-app-"),
+	})
+	for _, name := range []string{"new-app", "unknown-app"} {
+		request, err := http.NewRequest("GET", "/apps/"+name, nil)
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Authorization", "b "+token.GetValue())
+		recorder := httptest.NewRecorder()
+		s.testServer.ServeHTTP(recorder, request)
+		c.Check(recorder.Code, check.Equals, http.StatusNotFound)
+		c.Check(recorder.Body.String(), check.Equals, "Not found.\n")
+	}
+}
+
+func (s *S) TestAppRestartHardIsolationReadableKeepsForbidden(c *check.C) {
+	config.Set("multi-tenancy:hard-isolation", true)
+	defer config.Unset("multi-tenancy")
+	a := app.App{Name: "new-app", Platform: "zend"}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppRead,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	request, err := http.NewRequest("POST", "/apps/"+a.Name+"/restart", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
 func (s *S) TestAppInfoReturnsNotFoundWhenAppDoesNotExist(c *check.C) {
 	myApp := app.App{Name: "SomeApp"}
 	request, err := http.NewRequest("GET", "/apps/"+myApp.Name+"?:app="+myApp.Name, nil)
@@ -1597,6 +1926,39 @@ func (s *S) TestUpdateAppImageReset(c *check.C) {
 	c.Assert(dbApp.UpdatePlatform, check.Equals, true)
 }
 
+func (s *S) TestUpdateAppSpread(c *check.C) {
+	a := app.App{Name: "myappx", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("spread=false")
+	request, err := http.NewRequest("PUT", "/apps/myappx", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var dbApp app.App
+	err = s.conn.Apps().Find(bson.M{"name": a.Name}).One(&dbApp)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.SpreadUnits(), check.Equals, false)
+}
+
+func (s *S) TestUpdateAppSpreadInvalid(c *check.C) {
+	a := app.App{Name: "myappx", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("spread=sometimes")
+	request, err := http.NewRequest("PUT", "/apps/myappx", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "Invalid value for spread: it must be a boolean.\n")
+}
+
 func (s *S) TestUpdateAppWithPoolOnly(c *check.C) {
 	a := app.App{Name: "myappx", Platform: "zend", TeamOwner: s.team.Name}
 	err := app.CreateApp(&a, s.user)
@@ -1710,7 +2072,7 @@ func (s *S) TestUpdateAppWithoutFlag(c *check.C) {
 	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	recorder := httptest.NewRecorder()
 	s.testServer.ServeHTTP(recorder, request)
-	errorMessage := "Neither the description, plan, pool, team owner or platform were set. You must define at least one.\n"
+	errorMessage := "Neither the description, plan, pool, team owner, platform or spread were set. You must define at least one.\n"
 	c.Check(recorder.Code, check.Equals, http.StatusBadRequest)
 	c.Check(recorder.Body.String(), check.Equals, errorMessage)
 }
@@ -1991,14 +2353,12 @@ func (s *S) TestRemoveUnitsReturns403IfTheUserDoesNotHaveAccessToTheApp(c *check
 		Scheme:  permission.PermAppUpdateUnitRemove,
 		Context: permission.Context(permission.CtxApp, "-invalid-"),
 	})
-	request, err := http.NewRequest("DELETE", "/apps/fetisha/units?:app=fetisha&units=1&process=web", nil)
+	request, err := http.NewRequest("DELETE", "/apps/fetisha/units?units=1&process=web", nil)
 	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
 	recorder := httptest.NewRecorder()
-	err = removeUnits(recorder, request, token)
-	c.Assert(err, check.NotNil)
-	e, ok := err.(*errors.HTTP)
-	c.Assert(ok, check.Equals, true)
-	c.Assert(e.Code, check.Equals, http.StatusForbidden)
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
 }
 
 func (s *S) TestRemoveUnitsReturns400IfNumberOfUnitsIsOmitted(c *check.C) {
@@ -2815,9 +3175,9 @@ func (s *S) TestGetEnv(c *check.C) {
 	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
 }
 
-func (s *S) TestGetEnvMultipleVariables(c *check.C) {
+func (s *S) TestGetEnvPublicOnly(c *check.C) {
 	a := app.App{
-		Name:      "four-sticks",
+		Name:      "everything-i-want",
 		Platform:  "zend",
 		TeamOwner: s.team.Name,
 		Env: map[string]bind.EnvVar{
@@ -2828,8 +3188,97 @@ func (s *S) TestGetEnvMultipleVariables(c *check.C) {
 	}
 	err := app.CreateApp(&a, s.user)
 	c.Assert(err, check.IsNil)
-	url := fmt.Sprintf("/apps/%s/env?env=DATABASE_HOST&env=DATABASE_USER", a.Name)
-	request, err := http.NewRequest("GET", url, nil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadEnvPublic,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	url := fmt.Sprintf("/apps/%s/env", a.Name)
+	request, err := http.NewRequest("GET", url, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result []bind.EnvVar
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	c.Assert(result, check.DeepEquals, []bind.EnvVar{
+		{Name: "DATABASE_HOST", Value: "localhost", Public: true},
+		{Name: "DATABASE_USER", Value: "root", Public: true},
+	})
+	url = fmt.Sprintf("/apps/%s/env?env=DATABASE_PASSWORD&env=DATABASE_USER", a.Name)
+	request, err = http.NewRequest("GET", url, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	recorder = httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, []bind.EnvVar{
+		{Name: "DATABASE_USER", Value: "root", Public: true},
+	})
+}
+
+func (s *S) TestAppViewerPermissions(c *check.C) {
+	a := app.App{
+		Name:      "viewed-app",
+		Platform:  "zend",
+		TeamOwner: s.team.Name,
+		Env: map[string]bind.EnvVar{
+			"DATABASE_HOST": {Name: "DATABASE_HOST", Value: "localhost", Public: true},
+		},
+	}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	ctx := permission.Context(permission.CtxApp, a.Name)
+	token := userWithPermission(c,
+		permission.Permission{Scheme: permission.PermAppReadInfo, Context: ctx},
+		permission.Permission{Scheme: permission.PermAppReadEnvPublic, Context: ctx},
+		permission.Permission{Scheme: permission.PermAppReadLog, Context: ctx},
+	)
+	tests := []struct {
+		method string
+		url    string
+		body   string
+		code   int
+	}{
+		{method: "GET", url: "/apps/viewed-app", code: http.StatusOK},
+		{method: "GET", url: "/apps", code: http.StatusOK},
+		{method: "GET", url: "/apps/viewed-app/env", code: http.StatusOK},
+		{method: "GET", url: "/apps/viewed-app/log?lines=10", code: http.StatusOK},
+		{method: "POST", url: "/apps/viewed-app/env", body: "Envs.0.Name=FOO&Envs.0.Value=bar", code: http.StatusForbidden},
+		{method: "POST", url: "/apps/viewed-app/restart", code: http.StatusForbidden},
+		{method: "POST", url: "/apps/viewed-app/deploy", body: "image=tsuru/app", code: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		request, err := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.Header.Set("Authorization", "b "+token.GetValue())
+		recorder := httptest.NewRecorder()
+		s.testServer.ServeHTTP(recorder, request)
+		c.Check(recorder.Code, check.Equals, tt.code, check.Commentf("%s %s: %s", tt.method, tt.url, recorder.Body.String()))
+	}
+}
+
+func (s *S) TestGetEnvMultipleVariables(c *check.C) {
+	a := app.App{
+		Name:      "four-sticks",
+		Platform:  "zend",
+		TeamOwner: s.team.Name,
+		Env: map[string]bind.EnvVar{
+			"DATABASE_HOST":     {Name: "DATABASE_HOST", Value: "localhost", Public: true},
+			"DATABASE_USER":     {Name: "DATABASE_USER", Value: "root", Public: true},
+			"DATABASE_PASSWORD": {Name: "DATABASE_PASSWORD", Value: "secret", Public: false},
+		},
+	}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	url := fmt.Sprintf("/apps/%s/env?env=DATABASE_HOST&env=DATABASE_USER", a.Name)
+	request, err := http.NewRequest("GET", url, nil)
 	c.Assert(err, check.IsNil)
 	request.Header.Set("Authorization", "b "+s.token.GetValue())
 	recorder := httptest.NewRecorder()
@@ -2952,6 +3401,35 @@ func (s *S) TestSetEnvPublicEnvironmentVariableInTheApp(c *check.C) {
 	}, eventtest.HasEvent)
 }
 
+func (s *S) TestSetEnvInterpolated(c *check.C) {
+	a := app.App{Name: "black-dog", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	d := types.Envs{
+		Envs: []struct{ Name, Value string }{
+			{"DATABASE_URL", "mysql://${DATABASE_HOST}/db"},
+		},
+		Interpolate: true,
+	}
+	v, err := form.EncodeToValues(&d)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/apps/"+a.Name+"/env", strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Env["DATABASE_URL"], check.DeepEquals, bind.EnvVar{
+		Name:        "DATABASE_URL",
+		Value:       "mysql://${DATABASE_HOST}/db",
+		Public:      true,
+		Interpolate: true,
+	})
+}
+
 func (s *S) TestSetEnvHandlerShouldSetAPrivateEnvironmentVariableInTheApp(c *check.C) {
 	a := app.App{Name: "black-dog", Platform: "zend", TeamOwner: s.team.Name}
 	err := app.CreateApp(&a, s.user)
@@ -3290,6 +3768,113 @@ func (s *S) TestSetEnvHandlerReturnsForbiddenIfTheGivenUserDoesNotHaveAccessToTh
 	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
 }
 
+func (s *S) TestSetEnvHandlerSetAndUnset(c *check.C) {
+	a := app.App{
+		Name:      "black-dog",
+		Platform:  "zend",
+		TeamOwner: s.team.Name,
+		Env: map[string]bind.EnvVar{
+			"OLD_FLAG": {Name: "OLD_FLAG", Value: "1", Public: true},
+		},
+	}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	d := types.Envs{
+		Envs: []struct{ Name, Value string }{
+			{"DATABASE_HOST", "localhost"},
+			{"DATABASE_USER", "root"},
+		},
+		Unset: []string{"OLD_FLAG"},
+	}
+	v, err := form.EncodeToValues(&d)
+	c.Assert(err, check.IsNil)
+	url := fmt.Sprintf("/apps/%s/env", a.Name)
+	request, err := http.NewRequest("POST", url, strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), check.Equals,
+		`{"Message":"---- Setting 2 and unsetting 1 environment variables ----\n"}
+`)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Env, check.DeepEquals, map[string]bind.EnvVar{
+		"DATABASE_HOST": {Name: "DATABASE_HOST", Value: "localhost", Public: true},
+		"DATABASE_USER": {Name: "DATABASE_USER", Value: "root", Public: true},
+	})
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.env.set",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":app", "value": a.Name},
+			{"name": "Envs.0.Name", "value": "DATABASE_HOST"},
+			{"name": "Envs.0.Value", "value": "localhost"},
+			{"name": "Envs.1.Name", "value": "DATABASE_USER"},
+			{"name": "Envs.1.Value", "value": "root"},
+			{"name": "Unset.0", "value": "OLD_FLAG"},
+			{"name": "NoRestart", "value": ""},
+			{"name": "Private", "value": ""},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestSetEnvHandlerInvalidNameChangesNothing(c *check.C) {
+	a := app.App{Name: "black-dog", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	d := types.Envs{
+		Envs: []struct{ Name, Value string }{
+			{"DATABASE_HOST", "localhost"},
+			{"DATABASE-USER", "root"},
+		},
+	}
+	v, err := form.EncodeToValues(&d)
+	c.Assert(err, check.IsNil)
+	url := fmt.Sprintf("/apps/%s/env", a.Name)
+	request, err := http.NewRequest("POST", url, strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Matches, `(?s).*invalid environment variable name "DATABASE-USER".*`)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Env, check.HasLen, 0)
+	c.Assert(s.provisioner.Restarts(dbApp, ""), check.Equals, 0)
+}
+
+func (s *S) TestSetEnvHandlerUnsetWithoutPermission(c *check.C) {
+	a := app.App{Name: "black-dog", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateEnvSet,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	d := types.Envs{
+		Envs: []struct{ Name, Value string }{
+			{"DATABASE_HOST", "localhost"},
+		},
+		Unset: []string{"OLD_FLAG"},
+	}
+	v, err := form.EncodeToValues(&d)
+	c.Assert(err, check.IsNil)
+	url := fmt.Sprintf("/apps/%s/env", a.Name)
+	request, err := http.NewRequest("POST", url, strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
 func (s *S) TestUnsetEnv(c *check.C) {
 	a := app.App{
 		Name:     "swift",
@@ -3757,15 +4342,13 @@ func (s *S) TestAppLogReturnsForbiddenIfTheGivenUserDoesNotHaveAccessToTheApp(c
 		Scheme:  permission.PermAppReadLog,
 		Context: permission.Context(permission.CtxTeam, "no-access"),
 	})
-	url := fmt.Sprintf("/apps/%s/log/?:app=%s&lines=10", a.Name, a.Name)
+	url := fmt.Sprintf("/apps/%s/log?lines=10", a.Name)
 	request, err := http.NewRequest("GET", url, nil)
 	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
 	recorder := httptest.NewRecorder()
-	err = appLog(recorder, request, token)
-	c.Assert(err, check.NotNil)
-	e, ok := err.(*errors.HTTP)
-	c.Assert(ok, check.Equals, true)
-	c.Assert(e.Code, check.Equals, http.StatusForbidden)
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
 }
 
 func (s *S) TestAppLogReturnsBadRequestIfNumberOfLinesIsMissing(c *check.C) {
@@ -3899,6 +4482,66 @@ func (s *S) TestAppLogFollowWithFilter(c *check.C) {
 	wg.Wait()
 }
 
+func (s *S) TestAppLogStream(c *check.C) {
+	a := app.App{Name: "lost3", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.Log("old", "web", "")
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadLog,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	server := httptest.NewServer(s.testServer)
+	defer server.Close()
+	testServerURL, err := url.Parse(server.URL)
+	c.Assert(err, check.IsNil)
+	wsURL := fmt.Sprintf("ws://%s/apps/%s/log/stream?lines=10&source=web", testServerURL.Host, a.Name)
+	config, err := websocket.NewConfig(wsURL, "ws://localhost/")
+	c.Assert(err, check.IsNil)
+	config.Header.Set("Authorization", "bearer "+token.GetValue())
+	wsConn, err := websocket.DialConfig(config)
+	c.Assert(err, check.IsNil)
+	defer wsConn.Close()
+	var logMsg app.Applog
+	err = websocket.JSON.Receive(wsConn, &logMsg)
+	c.Assert(err, check.IsNil)
+	c.Assert(logMsg.Message, check.Equals, "old")
+	err = a.Log("ignored", "worker", "")
+	c.Assert(err, check.IsNil)
+	err = a.Log("x", "web", "")
+	c.Assert(err, check.IsNil)
+	err = websocket.JSON.Receive(wsConn, &logMsg)
+	c.Assert(err, check.IsNil)
+	c.Assert(logMsg.Message, check.Equals, "x")
+	c.Assert(logMsg.Source, check.Equals, "web")
+}
+
+func (s *S) TestAppLogStreamForbidden(c *check.C) {
+	a := app.App{Name: "lost4", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadLog,
+		Context: permission.Context(permission.CtxApp, "other-app"),
+	})
+	server := httptest.NewServer(s.testServer)
+	defer server.Close()
+	testServerURL, err := url.Parse(server.URL)
+	c.Assert(err, check.IsNil)
+	wsURL := fmt.Sprintf("ws://%s/apps/%s/log/stream", testServerURL.Host, a.Name)
+	config, err := websocket.NewConfig(wsURL, "ws://localhost/")
+	c.Assert(err, check.IsNil)
+	config.Header.Set("Authorization", "bearer "+token.GetValue())
+	wsConn, err := websocket.DialConfig(config)
+	c.Assert(err, check.IsNil)
+	defer wsConn.Close()
+	var msg errMsg
+	err = websocket.JSON.Receive(wsConn, &msg)
+	c.Assert(err, check.IsNil)
+	c.Assert(msg.Error, check.Equals, permission.ErrUnauthorized.Message)
+}
+
 func (s *S) TestAppLogShouldHaveContentType(c *check.C) {
 	a := app.App{Name: "lost", Platform: "zend", TeamOwner: s.team.Name}
 	err := app.CreateApp(&a, s.user)
@@ -4184,7 +4827,7 @@ func (s *S) TestBindHandler(c *check.C) {
 	}, eventtest.HasEvent)
 }
 
-func (s *S) TestBindHandlerReturns400IfServiceIsBlacklistedAndItsTheOnlyService(c *check.C) {
+func (s *S) TestBindHandlerReturns403IfServiceIsBlacklistedAndItsTheOnlyService(c *check.C) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(`{}`)) }))
 	defer ts.Close()
 	srvc := service.Service{Name: "mysql", Endpoint: map[string]string{"production": ts.URL}, Password: "demacia", OwnerTeams: []string{s.team.Name}}
@@ -4212,11 +4855,12 @@ func (s *S) TestBindHandlerReturns400IfServiceIsBlacklistedAndItsTheOnlyService(
 	request.Header.Set("Authorization", "b "+s.token.GetValue())
 	recorder := httptest.NewRecorder()
 	s.testServer.ServeHTTP(recorder, request)
-	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	c.Assert(recorder.Body.String(), check.Equals, "service \"mysql\" is not available for pool \"test1\": the pool constraints do not allow binding any services\n")
 	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "text/plain; charset=utf-8")
 }
 
-func (s *S) TestBindHandlerReturns400IfServiceIsBlacklistedAndMoreServicesAvailable(c *check.C) {
+func (s *S) TestBindHandlerReturns403IfServiceIsBlacklistedAndMoreServicesAvailable(c *check.C) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(`{}`)) }))
 	defer ts.Close()
 	srvc := service.Service{Name: "mysql", Endpoint: map[string]string{"production": ts.URL}, Password: "demacia", OwnerTeams: []string{s.team.Name}}
@@ -4247,7 +4891,7 @@ func (s *S) TestBindHandlerReturns400IfServiceIsBlacklistedAndMoreServicesAvaila
 	request.Header.Set("Authorization", "b "+s.token.GetValue())
 	recorder := httptest.NewRecorder()
 	s.testServer.ServeHTTP(recorder, request)
-	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
 	c.Assert(recorder.Body.String(), check.Equals, "service \"mysql\" is not available for pool \"test1\". Available services are: \"varus\"\n")
 	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "text/plain; charset=utf-8")
 }
@@ -4312,6 +4956,35 @@ func (s *S) TestBindHandlerWithoutEnvsDontRestartTheApp(c *check.C) {
 	c.Assert(s.provisioner.Restarts(&a, ""), check.Equals, 0)
 }
 
+func (s *S) TestBindHandlerInvalidWait(c *check.C) {
+	srvc := service.Service{Name: "mysql", Endpoint: map[string]string{"production": "http://localhost:1234"}, Password: "abcde", OwnerTeams: []string{s.team.Name}}
+	err := srvc.Create()
+	c.Assert(err, check.IsNil)
+	instance := service.ServiceInstance{
+		Name:        "my-mysql",
+		ServiceName: "mysql",
+		Teams:       []string{s.team.Name},
+	}
+	err = s.conn.ServiceInstances().Insert(instance)
+	c.Assert(err, check.IsNil)
+	a := app.App{Name: "painkiller", Platform: "zend", TeamOwner: s.team.Name}
+	err = app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	for _, wait := range []string{"abc", "-1", "601"} {
+		u := fmt.Sprintf("/services/%s/instances/%s/%s", instance.ServiceName, instance.Name, a.Name)
+		v := url.Values{}
+		v.Set("wait", wait)
+		request, err := http.NewRequest("PUT", u, strings.NewReader(v.Encode()))
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.Header.Set("Authorization", "b "+s.token.GetValue())
+		recorder := httptest.NewRecorder()
+		s.testServer.ServeHTTP(recorder, request)
+		c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+		c.Assert(recorder.Body.String(), check.Equals, "wait must be a number of seconds between 0 and 600\n")
+	}
+}
+
 func (s *S) TestBindHandlerReturns404IfTheInstanceDoesNotExist(c *check.C) {
 	a := app.App{Name: "serviceapp", Platform: "zend", TeamOwner: s.team.Name}
 	err := app.CreateApp(&a, s.user)
@@ -4908,15 +5581,188 @@ func (s *S) TestRestartHandlerReturns403IfTheUserDoesNotHaveAccessToTheApp(c *ch
 		Scheme:  permission.PermAppUpdateRestart,
 		Context: permission.Context(permission.CtxApp, "-invalid-"),
 	})
-	url := fmt.Sprintf("/apps/%s/restart?:app=%s", a.Name, a.Name)
+	url := fmt.Sprintf("/apps/%s/restart", a.Name)
+	request, err := http.NewRequest("POST", url, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestUnitRestartHandler(c *check.C) {
+	a := app.App{Name: "stress", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddUnits(&a, 2, "web", nil)
+	c.Assert(err, check.IsNil)
+	units, err := s.provisioner.Units(&a)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateRestart,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	url := fmt.Sprintf("/1.6/apps/%s/units/%s/restart", a.Name, units[0].ID)
+	request, err := http.NewRequest("POST", url, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/x-json-stream")
+	c.Assert(recorder.Body.String(), check.Matches, `(?s).*---- Restarting unit \\"`+units[0].ID+`\\" ----.*`)
+	c.Assert(s.provisioner.UnitRestarts(&a, units[0].ID), check.Equals, 1)
+	c.Assert(s.provisioner.UnitRestarts(&a, units[1].ID), check.Equals, 0)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  token.GetUserName(),
+		Kind:   "app.update.restart",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":app", "value": a.Name},
+			{"name": ":unit", "value": units[0].ID},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestUnitRestartHandlerUnitNotFound(c *check.C) {
+	a := app.App{Name: "stress", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/1.6/apps/stress/units/unknown/restart", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	c.Assert(recorder.Body.String(), check.Equals, "unit \"unknown\" not found\n")
+}
+
+func (s *S) TestUnitResourcesSetHandler(c *check.C) {
+	a := app.App{Name: "stress", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddUnits(&a, 2, "web", nil)
+	c.Assert(err, check.IsNil)
+	units, err := s.provisioner.Units(&a)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppAdminResources,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	url := fmt.Sprintf("/1.6/apps/%s/units/%s/resources", a.Name, units[0].ID)
+	body := strings.NewReader("memory=1G&cpushare=200")
+	request, err := http.NewRequest("PUT", url, body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(s.provisioner.UnitResources(&a, units[0].ID), check.DeepEquals, provision.UnitResources{
+		Memory:   1024 * 1024 * 1024,
+		CpuShare: 200,
+	})
+	c.Assert(s.provisioner.UnitResources(&a, units[1].ID).IsZero(), check.Equals, true)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  token.GetUserName(),
+		Kind:   "app.admin.resources",
+		StartCustomData: []map[string]interface{}{
+			{"name": "memory", "value": "1G"},
+			{"name": "cpushare", "value": "200"},
+			{"name": ":app", "value": a.Name},
+			{"name": ":unit", "value": units[0].ID},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestUnitResourcesSetHandlerInvalidMemory(c *check.C) {
+	a := app.App{Name: "stress", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddUnits(&a, 1, "web", nil)
+	c.Assert(err, check.IsNil)
+	units, err := s.provisioner.Units(&a)
+	c.Assert(err, check.IsNil)
+	url := fmt.Sprintf("/1.6/apps/%s/units/%s/resources", a.Name, units[0].ID)
+	request, err := http.NewRequest("PUT", url, strings.NewReader("memory=lots"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "invalid memory, it must be a positive size, like 512M or 1G\n")
+}
+
+func (s *S) TestUnitResourcesSetHandlerWithoutPermission(c *check.C) {
+	a := app.App{Name: "stress", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddUnits(&a, 1, "web", nil)
+	c.Assert(err, check.IsNil)
+	units, err := s.provisioner.Units(&a)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateRestart,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	url := fmt.Sprintf("/1.6/apps/%s/units/%s/resources", a.Name, units[0].ID)
+	request, err := http.NewRequest("PUT", url, strings.NewReader("memory=1G"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestUnitInfoHandler(c *check.C) {
+	a := app.App{Name: "stress", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddUnits(&a, 1, "web", nil)
+	c.Assert(err, check.IsNil)
+	units, err := s.provisioner.Units(&a)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppRead,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	url := fmt.Sprintf("/1.6/apps/%s/units/%s", a.Name, units[0].ID)
 	request, err := http.NewRequest("GET", url, nil)
 	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+token.GetValue())
 	recorder := httptest.NewRecorder()
-	err = restart(recorder, request, token)
-	c.Assert(err, check.NotNil)
-	e, ok := err.(*errors.HTTP)
-	c.Assert(ok, check.Equals, true)
-	c.Assert(e.Code, check.Equals, http.StatusForbidden)
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var info struct {
+		Unit        map[string]interface{}
+		Node        string
+		LastHealing *time.Time
+	}
+	err = json.NewDecoder(recorder.Body).Decode(&info)
+	c.Assert(err, check.IsNil)
+	c.Assert(info.Unit["ID"], check.Equals, units[0].ID)
+	c.Assert(info.Node, check.Equals, units[0].IP)
+	c.Assert(info.LastHealing, check.IsNil)
+}
+
+func (s *S) TestUnitInfoHandlerForbidden(c *check.C) {
+	a := app.App{Name: "stress", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppRead,
+		Context: permission.Context(permission.CtxApp, "-invalid-"),
+	})
+	request, err := http.NewRequest("GET", "/1.6/apps/stress/units/someunit", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
 }
 
 func (s *S) TestSleepHandler(c *check.C) {
@@ -4991,15 +5837,13 @@ func (s *S) TestSleepHandlerReturns403IfTheUserDoesNotHaveAccessToTheApp(c *chec
 		Scheme:  permission.PermAppUpdateSleep,
 		Context: permission.Context(permission.CtxApp, "-invalid-"),
 	})
-	url := fmt.Sprintf("/apps/%s/sleep?:app=%s&proxy=http://example.com", a.Name, a.Name)
+	url := fmt.Sprintf("/apps/%s/sleep?proxy=http://example.com", a.Name)
 	request, err := http.NewRequest("POST", url, nil)
 	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
 	recorder := httptest.NewRecorder()
-	err = sleep(recorder, request, token)
-	c.Assert(err, check.NotNil)
-	e, ok := err.(*errors.HTTP)
-	c.Assert(ok, check.Equals, true)
-	c.Assert(e.Code, check.Equals, http.StatusForbidden)
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
 }
 
 type LogList []app.Applog
@@ -5579,6 +6423,82 @@ func (s *S) TestMEtricEnvsWhenAppDoesNotExist(c *check.C) {
 	c.Assert(recorder.Body.String(), check.Matches, "^App .* not found.\n$")
 }
 
+func (s *S) TestAppMetrics(c *check.C) {
+	var body map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		c.Check(r.URL.Path, check.Equals, "/.measure-tsuru-*/mem_max/_search")
+		fmt.Fprint(w, `{"aggregations":{"units":{"buckets":[{"key":"unit1","interval":{"buckets":[{"key":1514800800000,"value":{"value":1024}}]}}]}}}`)
+	}))
+	defer srv.Close()
+	config.Set("metrics:elasticsearch:host", srv.URL)
+	defer config.Unset("metrics")
+	a := app.App{Name: "myappx", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/apps/myappx/metrics?metric=memory&window=30m&interval=5m", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result []metrics.Series
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, []metrics.Series{{
+		Metric: "memory",
+		Unit:   "unit1",
+		Points: []metrics.Point{{Timestamp: time.Date(2018, 1, 1, 10, 0, 0, 0, time.UTC), Value: 1024}},
+	}})
+	histogram := body["aggs"].(map[string]interface{})["units"].(map[string]interface{})["aggs"].(map[string]interface{})["interval"].(map[string]interface{})
+	c.Assert(histogram["date_histogram"].(map[string]interface{})["interval"], check.Equals, "300s")
+}
+
+func (s *S) TestAppMetricsInvalidWindow(c *check.C) {
+	config.Set("metrics:elasticsearch:host", "http://localhost:9200")
+	defer config.Unset("metrics")
+	a := app.App{Name: "myappx", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/apps/myappx/metrics?window=xyz", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Matches, "invalid window: .*\n")
+}
+
+func (s *S) TestAppMetricsNotConfigured(c *check.C) {
+	a := app.App{Name: "myappx", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/apps/myappx/metrics", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusPreconditionFailed)
+	c.Assert(recorder.Body.String(), check.Equals, "metrics backend is not configured\n")
+}
+
+func (s *S) TestAppMetricsWhenUserDoesNotHaveAccess(c *check.C) {
+	a := app.App{Name: "myappx", Platform: "zend"}
+	err := s.conn.Apps().Insert(&a)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadMetric,
+		Context: permission.Context(permission.CtxApp, "-invalid-"),
+	})
+	request, err := http.NewRequest("GET", "/apps/myappx/metrics", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
 func (s *S) TestRebuildRoutes(c *check.C) {
 	a := app.App{Name: "myappx", Platform: "zend", TeamOwner: s.team.Name, Router: "fake"}
 	err := app.CreateApp(&a, s.user)
@@ -10,6 +10,8 @@ import (
 	"net/http"
 	"reflect"
 	"runtime"
+	"sort"
+	"time"
 
 	"github.com/ajg/form"
 	"github.com/tsuru/config"
@@ -17,7 +19,6 @@ import (
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/event"
-	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/provision/pool"
 	"github.com/tsuru/tsuru/repository"
@@ -239,6 +240,8 @@ var teamRenameFns = []func(oldName, newName string) error{
 	service.RenameServiceInstanceTeam,
 	volume.RenameTeam,
 	pool.RenamePoolTeam,
+	app.RenameTeamPolicy,
+	app.RenameDeployFreeze,
 }
 
 // title: team update
@@ -267,12 +270,6 @@ func updateTeam(w http.ResponseWriter, r *http.Request, t auth.Token) (err error
 	if changeRequest.NewName == "" {
 		return &errors.HTTP{Code: http.StatusBadRequest, Message: "new team name cannot be empty"}
 	}
-	allowed := permission.Check(t, permission.PermTeamUpdate,
-		permission.Context(permission.CtxTeam, name),
-	)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	_, err = auth.GetTeam(name)
 	if err != nil {
 		if err == authTypes.ErrTeamNotFound {
@@ -306,13 +303,13 @@ func updateTeam(w http.ResponseWriter, r *http.Request, t auth.Token) (err error
 		}
 		rollbackErr := auth.RemoveTeam(changeRequest.NewName)
 		if rollbackErr != nil {
-			log.Errorf("error rolling back team creation from %v to %v", name, changeRequest.NewName)
+			logger.Errorf("error rolling back team creation from %v to %v", name, changeRequest.NewName)
 		}
 		for _, rollbackFn := range toRollback {
 			rollbackErr := rollbackFn(changeRequest.NewName, name)
 			if rollbackErr != nil {
 				fnName := runtime.FuncForPC(reflect.ValueOf(rollbackFn).Pointer()).Name()
-				log.Errorf("error rolling back team name change in %v from %q to %q", fnName, name, changeRequest.NewName)
+				logger.Errorf("error rolling back team name change in %v from %q to %q", fnName, name, changeRequest.NewName)
 			}
 		}
 	}()
@@ -334,6 +331,7 @@ func updateTeam(w http.ResponseWriter, r *http.Request, t auth.Token) (err error
 //   201: Team created
 //   400: Invalid data
 //   401: Unauthorized
+//   403: Team creation not allowed
 //   409: Team already exists
 func createTeam(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	allowed := permission.Check(t, permission.PermTeamCreate)
@@ -344,6 +342,21 @@ func createTeam(w http.ResponseWriter, r *http.Request, t auth.Token) (err error
 	if name == "" {
 		return &errors.HTTP{Code: http.StatusBadRequest, Message: authTypes.ErrInvalidTeamName.Error()}
 	}
+	u, err := t.User()
+	if err != nil {
+		return err
+	}
+	policy, err := auth.GetTeamCreationPolicy()
+	if err != nil {
+		return err
+	}
+	err = policy.Check(u, permission.Check(t, permission.PermAll))
+	if err != nil {
+		if _, ok := err.(*auth.TeamCreationDeniedError); ok {
+			return &errors.HTTP{Code: http.StatusForbidden, Message: err.Error()}
+		}
+		return err
+	}
 	evt, err := event.New(&event.Opts{
 		Target:     teamTarget(name),
 		Kind:       permission.PermTeamCreate,
@@ -355,10 +368,6 @@ func createTeam(w http.ResponseWriter, r *http.Request, t auth.Token) (err error
 		return err
 	}
 	defer func() { evt.Done(err) }()
-	u, err := t.User()
-	if err != nil {
-		return err
-	}
 	err = auth.CreateTeam(name, u)
 	switch err {
 	case authTypes.ErrInvalidTeamName:
@@ -372,22 +381,46 @@ func createTeam(w http.ResponseWriter, r *http.Request, t auth.Token) (err error
 	return err
 }
 
+// teamTransferFns reassign the resources of a team to another team before
+// it's removed.
+var teamTransferFns = []func(oldName, newName string) error{
+	app.RenameTeam,
+	service.RenameServiceTeam,
+	service.RenameServiceInstanceTeam,
+}
+
+type teamStillUsed struct {
+	Message string `json:"message"`
+	*auth.ErrTeamStillUsed
+}
+
 // title: remove team
 // path: /teams/{name}
 // method: DELETE
+// produce: application/json
 // responses:
 //   200: Team removed
+//   400: Invalid data
 //   401: Unauthorized
-//   403: Forbidden
 //   404: Not found
+//   409: Team still in use
 func removeTeam(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	r.ParseForm()
 	name := r.URL.Query().Get(":name")
-	allowed := permission.Check(t, permission.PermTeamDelete,
-		permission.Context(permission.CtxTeam, name),
-	)
-	if !allowed {
-		return &errors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf(`Team "%s" not found.`, name)}
+	transferTo := r.FormValue("transfer-to")
+	if transferTo != "" {
+		err = checkTeamTransfer(t, name, transferTo)
+		if err != nil {
+			return err
+		}
+	} else {
+		refs, refsErr := auth.TeamReferences(name)
+		if refsErr != nil {
+			return refsErr
+		}
+		if refs != nil {
+			return writeTeamStillUsed(w, refs)
+		}
 	}
 	evt, err := event.New(&event.Opts{
 		Target:     teamTarget(name),
@@ -400,18 +433,64 @@ func removeTeam(w http.ResponseWriter, r *http.Request, t auth.Token) (err error
 		return err
 	}
 	defer func() { evt.Done(err) }()
+	if transferTo != "" {
+		for _, fn := range teamTransferFns {
+			err = fn(name, transferTo)
+			if err != nil {
+				return err
+			}
+		}
+	}
 	err = auth.RemoveTeam(name)
 	if err != nil {
 		if _, ok := err.(*auth.ErrTeamStillUsed); ok {
 			msg := fmt.Sprintf("This team cannot be removed because there are still references to it:\n%s", err)
-			return &errors.HTTP{Code: http.StatusForbidden, Message: msg}
+			return &errors.HTTP{Code: http.StatusConflict, Message: msg}
 		}
 		if err == authTypes.ErrTeamNotFound {
 			return &errors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf(`Team "%s" not found.`, name)}
 		}
 		return err
 	}
-	return nil
+	err = app.RemoveTeamPolicy(name)
+	if err != nil && err != app.ErrTeamPolicyNotFound {
+		return err
+	}
+	err = app.RemoveDeployFreeze(name)
+	if err == app.ErrDeployFreezeNotFound {
+		return nil
+	}
+	return err
+}
+
+// checkTeamTransfer checks whether the resources of a team may be transferred
+// to another team, which must exist and be updatable by the user.
+func checkTeamTransfer(t auth.Token, name, transferTo string) error {
+	if transferTo == name {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "cannot transfer resources to the team being removed"}
+	}
+	allowed := permission.Check(t, permission.PermTeamUpdate,
+		permission.Context(permission.CtxTeam, transferTo),
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	_, err := auth.GetTeam(transferTo)
+	if err == authTypes.ErrTeamNotFound {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf(`Team "%s" to transfer resources to not found.`, transferTo)}
+	}
+	return err
+}
+
+// writeTeamStillUsed writes a conflict response listing the resources that
+// would be left without the team.
+func writeTeamStillUsed(w http.ResponseWriter, refs *auth.ErrTeamStillUsed) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	return json.NewEncoder(w).Encode(teamStillUsed{
+		Message:          "This team cannot be removed because there are still references to it",
+		ErrTeamStillUsed: refs,
+	})
 }
 
 // title: team list
@@ -627,7 +706,7 @@ func removeUser(w http.ResponseWriter, r *http.Request, t auth.Token) (err error
 		manager.RevokeAccess(name, u.Email)
 	}
 	if err := manager.RemoveUser(u.Email); err != nil {
-		log.Errorf("Failed to remove user from repository manager: %s", err)
+		logger.Errorf("Failed to remove user from repository manager: %s", err)
 	}
 	return app.AuthScheme.Remove(u)
 }
@@ -737,6 +816,52 @@ type apiUser struct {
 	Email       string
 	Roles       []rolePermissionData
 	Permissions []rolePermissionData
+	Teams       []string      `json:",omitempty"`
+	Token       *apiTokenInfo `json:",omitempty"`
+}
+
+// apiTokenInfo describes the token used in a request. Kind is "app" for app
+// tokens, limited to the app, "api-key" for the API key of the user and
+// "session" for the tokens created by logging in. All but app tokens carry
+// every permission of the user.
+type apiTokenInfo struct {
+	Kind    string
+	App     string     `json:",omitempty"`
+	Expires *time.Time `json:",omitempty"`
+}
+
+func tokenInfo(t auth.Token) *apiTokenInfo {
+	info := apiTokenInfo{Kind: "session"}
+	if t.IsAppToken() {
+		info.Kind = "app"
+		info.App = t.GetAppName()
+	} else if _, ok := t.(*auth.APIToken); ok {
+		info.Kind = "api-key"
+	}
+	if expirable, ok := t.(auth.ExpirableToken); ok {
+		if expires := expirable.GetExpireTime(); !expires.IsZero() {
+			expires = expires.UTC()
+			info.Expires = &expires
+		}
+	}
+	return &info
+}
+
+// userTeams returns the names of the teams the user is a member of, the ones
+// in the context of its roles.
+func userTeams(roles []rolePermissionData) []string {
+	teamSet := map[string]struct{}{}
+	for _, r := range roles {
+		if r.ContextType == string(permission.CtxTeam) && r.ContextValue != "" {
+			teamSet[r.ContextValue] = struct{}{}
+		}
+	}
+	teams := make([]string, 0, len(teamSet))
+	for team := range teamSet {
+		teams = append(teams, team)
+	}
+	sort.Strings(teams)
+	return teams
 }
 
 func createAPIUser(perms []permission.Permission, user *auth.User, roleMap map[string]*permission.Role, includeAll bool) (*apiUser, error) {
@@ -889,6 +1014,8 @@ func userInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if err != nil {
 		return err
 	}
+	userData.Teams = userTeams(userData.Roles)
+	userData.Token = tokenInfo(t)
 	w.Header().Add("Content-Type", "application/json")
 	return json.NewEncoder(w).Encode(userData)
 }
@@ -421,6 +421,75 @@ func (s *AuthSuite) TestCreateTeamAlreadyExists(c *check.C) {
 	c.Assert(recorder.Body.String(), check.Equals, "team already exists\n")
 }
 
+func (s *AuthSuite) teamCreatorToken(c *check.C) auth.Token {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "creator", permission.Permission{
+		Scheme:  permission.PermTeamCreate,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	return token
+}
+
+func (s *AuthSuite) createTeamAs(c *check.C, token auth.Token, name string) *httptest.ResponseRecorder {
+	request, err := http.NewRequest("POST", "/teams", strings.NewReader("name="+name))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func (s *AuthSuite) TestCreateTeamPolicyAdminOnly(c *check.C) {
+	config.Set("team:creation:policy", "admin-only")
+	defer config.Unset("team:creation")
+	token := s.teamCreatorToken(c)
+	recorder := s.createTeamAs(c, token, "timeredbull")
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	c.Assert(recorder.Body.String(), check.Equals, "team creation not allowed: only administrators may create teams\n")
+	_, err := auth.TeamService().FindByName("timeredbull")
+	c.Assert(err, check.Equals, authTypes.ErrTeamNotFound)
+	request, err := http.NewRequest("POST", "/teams", strings.NewReader("name=timeredbull"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+}
+
+func (s *AuthSuite) TestCreateTeamPolicyDomain(c *check.C) {
+	config.Set("team:creation:policy", "domain")
+	config.Set("team:creation:allowed-domains", []interface{}{"example.com"})
+	defer config.Unset("team:creation")
+	token := s.teamCreatorToken(c)
+	recorder := s.createTeamAs(c, token, "timeredbull")
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	c.Assert(recorder.Body.String(), check.Equals, "team creation not allowed: only users with emails in example.com may create teams\n")
+	config.Set("team:creation:allowed-domains", []interface{}{"example.com", "GroundControl.com"})
+	recorder = s.createTeamAs(c, token, "timeredbull")
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+}
+
+func (s *AuthSuite) TestCreateTeamPolicyMaxPerUser(c *check.C) {
+	config.Set("team:creation:max-per-user", 1)
+	defer config.Unset("team:creation")
+	token := s.teamCreatorToken(c)
+	recorder := s.createTeamAs(c, token, "timeredbull")
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	recorder = s.createTeamAs(c, token, "anotherteam")
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	c.Assert(recorder.Body.String(), check.Equals, "team creation not allowed: users may create at most 1 teams\n")
+}
+
+func (s *AuthSuite) TestCreateTeamPolicyInvalid(c *check.C) {
+	config.Set("team:creation:policy", "closed")
+	defer config.Unset("team:creation")
+	token := s.teamCreatorToken(c)
+	recorder := s.createTeamAs(c, token, "timeredbull")
+	c.Assert(recorder.Code, check.Equals, http.StatusInternalServerError)
+	c.Assert(recorder.Body.String(), check.Matches, `invalid team:creation:policy "closed".*\n`)
+}
+
 func (s *AuthSuite) TestRemoveTeam(c *check.C) {
 	team := authTypes.Team{Name: "painofsalvation"}
 	err := auth.TeamService().Insert(team)
@@ -443,6 +512,21 @@ func (s *AuthSuite) TestRemoveTeam(c *check.C) {
 	}, eventtest.HasEvent)
 }
 
+func (s *AuthSuite) TestRemoveTeamRemovesPolicy(c *check.C) {
+	team := authTypes.Team{Name: "painofsalvation"}
+	err := auth.TeamService().Insert(team)
+	c.Assert(err, check.IsNil)
+	err = app.SaveTeamPolicy(app.TeamPolicy{Team: team.Name})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", fmt.Sprintf("/teams/%s?:name=%s", team.Name, team.Name), nil)
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	err = removeTeam(recorder, request, s.token)
+	c.Assert(err, check.IsNil)
+	_, err = app.GetTeamPolicy(team.Name)
+	c.Assert(err, check.Equals, app.ErrTeamPolicyNotFound)
+}
+
 func (s *AuthSuite) TestRemoveTeamAsAdmin(c *check.C) {
 	team := authTypes.Team{Name: "thegathering"}
 	err := auth.TeamService().Insert(team)
@@ -485,18 +569,18 @@ func (s *AuthSuite) TestRemoveTeamGives404WhenUserDoesNotHaveAccessToTheTeam(c *
 	team := authTypes.Team{Name: "painofsalvation"}
 	err := auth.TeamService().Insert(team)
 	c.Assert(err, check.IsNil)
-	request, err := http.NewRequest("DELETE", fmt.Sprintf("/teams/%s?:name=%s", team.Name, team.Name), nil)
+	request, err := http.NewRequest("DELETE", "/teams/"+team.Name, nil)
 	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
 	recorder := httptest.NewRecorder()
-	err = removeTeam(recorder, request, token)
-	c.Assert(err, check.NotNil)
-	e, ok := err.(*errors.HTTP)
-	c.Assert(ok, check.Equals, true)
-	c.Assert(e.Code, check.Equals, http.StatusNotFound)
-	c.Assert(e.Message, check.Equals, `Team "painofsalvation" not found.`)
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	c.Assert(recorder.Body.String(), check.Equals, `Team "painofsalvation" not found.`+"\n")
+	_, err = auth.GetTeam(team.Name)
+	c.Assert(err, check.IsNil)
 }
 
-func (s *AuthSuite) TestRemoveTeamGives403WhenTeamHasAccessToAnyApp(c *check.C) {
+func (s *AuthSuite) TestRemoveTeamGives409WhenTeamHasAccessToAnyApp(c *check.C) {
 	team := authTypes.Team{Name: "evergrey"}
 	err := auth.TeamService().Insert(team)
 	c.Assert(err, check.IsNil)
@@ -507,16 +591,21 @@ func (s *AuthSuite) TestRemoveTeamGives403WhenTeamHasAccessToAnyApp(c *check.C)
 	c.Assert(err, check.IsNil)
 	recorder := httptest.NewRecorder()
 	err = removeTeam(recorder, request, s.token)
-	c.Assert(err, check.NotNil)
-	e, ok := err.(*errors.HTTP)
-	c.Assert(ok, check.Equals, true)
-	c.Assert(e.Code, check.Equals, http.StatusForbidden)
-	expected := `This team cannot be removed because there are still references to it:
-Apps: i-should`
-	c.Assert(e.Message, check.Equals, expected)
+	c.Assert(err, check.IsNil)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result map[string]interface{}
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, map[string]interface{}{
+		"message": "This team cannot be removed because there are still references to it",
+		"apps":    []interface{}{"i-should"},
+	})
+	_, err = auth.GetTeam(team.Name)
+	c.Assert(err, check.IsNil)
 }
 
-func (s *AuthSuite) TestRemoveTeamGives403WhenTeamHasAccessToAnyServiceInstance(c *check.C) {
+func (s *AuthSuite) TestRemoveTeamGives409WhenTeamHasAccessToAnyServiceInstance(c *check.C) {
 	team := authTypes.Team{Name: "evergrey"}
 	err := auth.TeamService().Insert(team)
 	c.Assert(err, check.IsNil)
@@ -526,17 +615,98 @@ func (s *AuthSuite) TestRemoveTeamGives403WhenTeamHasAccessToAnyServiceInstance(
 	si2 := service.ServiceInstance{Name: "my_nosql-2", ServiceName: "nosql-service", Teams: []string{team.Name}}
 	err = s.conn.ServiceInstances().Insert(si2)
 	c.Assert(err, check.IsNil)
+	srv := service.Service{Name: "nosql-service", OwnerTeams: []string{team.Name}}
+	err = s.conn.Services().Insert(srv)
+	c.Assert(err, check.IsNil)
 	request, err := http.NewRequest("DELETE", fmt.Sprintf("/teams/%s?:name=%s", team.Name, team.Name), nil)
 	c.Assert(err, check.IsNil)
 	recorder := httptest.NewRecorder()
 	err = removeTeam(recorder, request, s.token)
+	c.Assert(err, check.IsNil)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+	var result map[string]interface{}
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, map[string]interface{}{
+		"message":          "This team cannot be removed because there are still references to it",
+		"services":         []interface{}{"nosql-service"},
+		"serviceInstances": []interface{}{"my_nosql", "my_nosql-2"},
+	})
+}
+
+func (s *AuthSuite) TestRemoveTeamTransferringResources(c *check.C) {
+	team := authTypes.Team{Name: "evergrey"}
+	err := auth.TeamService().Insert(team)
+	c.Assert(err, check.IsNil)
+	a := app.App{Name: "i-should", Platform: "python", TeamOwner: team.Name}
+	err = app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	si := service.ServiceInstance{Name: "my_nosql", ServiceName: "nosql-service", TeamOwner: team.Name, Teams: []string{team.Name, s.team.Name}}
+	err = s.conn.ServiceInstances().Insert(si)
+	c.Assert(err, check.IsNil)
+	srv := service.Service{Name: "nosql-service", OwnerTeams: []string{team.Name}}
+	err = s.conn.Services().Insert(srv)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", fmt.Sprintf("/teams/%s?transfer-to=%s", team.Name, s.team.Name), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Add("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	_, err = auth.GetTeam(team.Name)
+	c.Assert(err, check.Equals, authTypes.ErrTeamNotFound)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.TeamOwner, check.Equals, s.team.Name)
+	c.Assert(dbApp.Teams, check.DeepEquals, []string{s.team.Name})
+	dbInstance, err := service.GetServiceInstance("nosql-service", "my_nosql")
+	c.Assert(err, check.IsNil)
+	c.Assert(dbInstance.TeamOwner, check.Equals, s.team.Name)
+	c.Assert(dbInstance.Teams, check.DeepEquals, []string{s.team.Name})
+	dbService := service.Service{Name: "nosql-service"}
+	err = dbService.Get()
+	c.Assert(err, check.IsNil)
+	c.Assert(dbService.OwnerTeams, check.DeepEquals, []string{s.team.Name})
+	c.Assert(eventtest.EventDesc{
+		Target: teamTarget(team.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "team.delete",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":name", "value": team.Name},
+			{"name": "transfer-to", "value": s.team.Name},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *AuthSuite) TestRemoveTeamTransferringToUnknownTeam(c *check.C) {
+	team := authTypes.Team{Name: "evergrey"}
+	err := auth.TeamService().Insert(team)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", fmt.Sprintf("/teams/%s?:name=%s&transfer-to=unknown", team.Name, team.Name), nil)
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	err = removeTeam(recorder, request, s.token)
 	c.Assert(err, check.NotNil)
 	e, ok := err.(*errors.HTTP)
 	c.Assert(ok, check.Equals, true)
-	c.Assert(e.Code, check.Equals, http.StatusForbidden)
-	expected := `This team cannot be removed because there are still references to it:
-Service instances: my_nosql, my_nosql-2`
-	c.Assert(e.Message, check.Equals, expected)
+	c.Assert(e.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(e.Message, check.Equals, `Team "unknown" to transfer resources to not found.`)
+	_, err = auth.GetTeam(team.Name)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *AuthSuite) TestRemoveTeamTransferringToItself(c *check.C) {
+	team := authTypes.Team{Name: "evergrey"}
+	err := auth.TeamService().Insert(team)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", fmt.Sprintf("/teams/%s?:name=%s&transfer-to=%s", team.Name, team.Name, team.Name), nil)
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	err = removeTeam(recorder, request, s.token)
+	c.Assert(err, check.NotNil)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.Code, check.Equals, http.StatusBadRequest)
 }
 
 func (s *AuthSuite) TestListTeamsListsAllTeamsThatTheUserHasAccess(c *check.C) {
@@ -1487,6 +1657,8 @@ func (s *AuthSuite) TestUserInfo(c *check.C) {
 	var got apiUser
 	err = json.NewDecoder(recorder.Body).Decode(&got)
 	c.Assert(err, check.IsNil)
+	c.Assert(got.Token, check.NotNil)
+	expected.Token = &apiTokenInfo{Kind: "session", Expires: got.Token.Expires}
 	c.Assert(got, check.DeepEquals, expected)
 }
 
@@ -1511,6 +1683,8 @@ func (s *AuthSuite) TestUserInfoWithoutRoles(c *check.C) {
 	c.Assert(err, check.IsNil)
 	sort.Sort(rolePermList(got.Permissions))
 	sort.Sort(rolePermList(got.Roles))
+	c.Assert(got.Token, check.NotNil)
+	expected.Token = &apiTokenInfo{Kind: "session", Expires: got.Token.Expires}
 	c.Assert(got, check.DeepEquals, expected)
 }
 
@@ -1554,15 +1728,62 @@ func (s *AuthSuite) TestUserInfoWithRoles(c *check.C) {
 			{Name: "app.deploy", ContextType: "team", ContextValue: "a"},
 			{Name: "app.deploy", ContextType: "team", ContextValue: "b"},
 		},
+		Teams: []string{"a", "b"},
 	}
 	var got apiUser
 	err = json.NewDecoder(recorder.Body).Decode(&got)
 	c.Assert(err, check.IsNil)
 	sort.Sort(rolePermList(got.Permissions))
 	sort.Sort(rolePermList(got.Roles))
+	c.Assert(got.Token, check.NotNil)
+	expected.Token = &apiTokenInfo{Kind: "session", Expires: got.Token.Expires}
 	c.Assert(got, check.DeepEquals, expected)
 }
 
+func (s *AuthSuite) TestUserInfoTokenExpiration(c *check.C) {
+	token := userWithPermission(c)
+	request, err := http.NewRequest("GET", "/users/info", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Add("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	handler := RunServer(true)
+	handler.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var got apiUser
+	err = json.NewDecoder(recorder.Body).Decode(&got)
+	c.Assert(err, check.IsNil)
+	c.Assert(got.Token, check.NotNil)
+	c.Assert(got.Token.Kind, check.Equals, "session")
+	c.Assert(got.Token.Expires, check.NotNil)
+	expires := token.(auth.ExpirableToken).GetExpireTime()
+	c.Assert(got.Token.Expires.Sub(expires) < time.Second, check.Equals, true)
+	c.Assert(expires.Sub(*got.Token.Expires) < time.Second, check.Equals, true)
+}
+
+func (s *AuthSuite) TestUserInfoWithAPIKey(c *check.C) {
+	user := auth.User{Email: "para@xmen.com", APIKey: "347r3487rh3489hr34897rh487hr0377rg308rg32"}
+	err := s.conn.Users().Insert(&user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/users/info", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Add("Authorization", "bearer "+user.APIKey)
+	recorder := httptest.NewRecorder()
+	handler := RunServer(true)
+	handler.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var got apiUser
+	err = json.NewDecoder(recorder.Body).Decode(&got)
+	c.Assert(err, check.IsNil)
+	c.Assert(got.Email, check.Equals, "para@xmen.com")
+	c.Assert(got.Token, check.DeepEquals, &apiTokenInfo{Kind: "api-key"})
+}
+
+func (s *AuthSuite) TestTokenInfoAppToken(c *check.C) {
+	token, err := nativeScheme.AppLogin("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(tokenInfo(token), check.DeepEquals, &apiTokenInfo{Kind: "app", App: "myapp"})
+}
+
 func (s *AuthSuite) BenchmarkListUsersManyUsers(c *check.C) {
 	c.StopTimer()
 	perm := permission.Permission{
@@ -1648,6 +1869,26 @@ func (s *AuthSuite) TestUpdateTeam(c *check.C) {
 	c.Assert(err, check.IsNil)
 }
 
+func (s *AuthSuite) TestUpdateTeamRenamesPolicy(c *check.C) {
+	err := auth.TeamService().Insert(authTypes.Team{Name: "team1"})
+	c.Assert(err, check.IsNil)
+	err = app.SaveTeamPolicy(app.TeamPolicy{Team: "team1"})
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("newname=team9000")
+	request, err := http.NewRequest("POST", "/teams/team1", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	handler := RunServer(true)
+	handler.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK, check.Commentf("body: %q", recorder.Body.String()))
+	_, err = app.GetTeamPolicy("team1")
+	c.Assert(err, check.Equals, app.ErrTeamPolicyNotFound)
+	_, err = app.GetTeamPolicy("team9000")
+	c.Assert(err, check.IsNil)
+}
+
 func (s *AuthSuite) TestUpdateTeamNotFound(c *check.C) {
 	body := strings.NewReader("newname=team9000")
 	request, err := http.NewRequest("POST", "/teams/team1", body)
@@ -0,0 +1,159 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/tsuru/tsuru/api/context"
+	"github.com/tsuru/tsuru/auth"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/permission"
+)
+
+// errIsolatedNotFound is returned in hard isolation mode both for resources
+// that don't exist and for resources the user can't read, so users can't tell
+// whether resources of other teams exist.
+var errIsolatedNotFound = &tsuruErrors.HTTP{Code: http.StatusNotFound, Message: "Not found."}
+
+// permissionScope derives from the request the contexts in which the
+// permission of a route is required.
+type permissionScope struct {
+	name     string
+	contexts func(r *http.Request) ([]permission.PermissionContext, error)
+	// denied returns the error for requests without the permission. When
+	// it's nil, permission.ErrUnauthorized is returned.
+	denied func(r *http.Request) error
+}
+
+// appScope requires the permission in the contexts of the app in the {app}
+// or {appname} path variable.
+var appScope = &permissionScope{
+	name: "app",
+	contexts: func(r *http.Request) ([]permission.PermissionContext, error) {
+		appName := r.URL.Query().Get(":app")
+		if appName == "" {
+			appName = r.URL.Query().Get(":appname")
+		}
+		a, err := getReadableApp(appName, context.GetAuthToken(r))
+		if err != nil {
+			return nil, err
+		}
+		return contextsForApp(a), nil
+	},
+}
+
+// serviceScope requires the permission in the contexts of the service in the
+// {name} or {service} path variable.
+var serviceScope = &permissionScope{
+	name: "service",
+	contexts: func(r *http.Request) ([]permission.PermissionContext, error) {
+		serviceName := r.URL.Query().Get(":name")
+		if serviceName == "" {
+			serviceName = r.URL.Query().Get(":service")
+		}
+		s, err := getReadableService(context.GetAuthToken(r), serviceName)
+		if err != nil {
+			return nil, err
+		}
+		return contextsForServiceProvision(&s), nil
+	},
+}
+
+// serviceInstanceScope requires the permission in the contexts of the
+// instance in the {instance} path variable. The service is taken from the
+// {service} path variable or, for routes without it, from the service query
+// string parameter, which may be omitted when the instance name is unique.
+var serviceInstanceScope = &permissionScope{
+	name: "service-instance",
+	contexts: func(r *http.Request) ([]permission.PermissionContext, error) {
+		instanceName := r.URL.Query().Get(":instance")
+		serviceName := r.URL.Query().Get(":service")
+		if serviceName == "" {
+			var err error
+			serviceName, err = serviceNameForInstance(instanceName, r.URL.Query().Get("service"))
+			if err != nil {
+				return nil, err
+			}
+		}
+		si, err := getReadableServiceInstance(context.GetAuthToken(r), serviceName, instanceName)
+		if err != nil {
+			return nil, err
+		}
+		return contextsForServiceInstance(si, serviceName), nil
+	},
+}
+
+// teamScope requires the permission in the context of the team in the {name}
+// path variable.
+var teamScope = &permissionScope{
+	name: "team",
+	contexts: func(r *http.Request) ([]permission.PermissionContext, error) {
+		return []permission.PermissionContext{
+			permission.Context(permission.CtxTeam, r.URL.Query().Get(":name")),
+		}, nil
+	},
+}
+
+// hiddenTeamScope is like teamScope, but users without the permission get
+// the same not found error returned for teams that don't exist.
+var hiddenTeamScope = &permissionScope{
+	name:     "team",
+	contexts: teamScope.contexts,
+	denied: func(r *http.Request) error {
+		return &tsuruErrors.HTTP{Code: http.StatusNotFound, Message: fmt.Sprintf(`Team "%s" not found.`, r.URL.Query().Get(":name"))}
+	},
+}
+
+// poolScope requires the permission in the context of the pool in the {name}
+// path variable.
+var poolScope = &permissionScope{
+	name: "pool",
+	contexts: func(r *http.Request) ([]permission.PermissionContext, error) {
+		return []permission.PermissionContext{
+			permission.Context(permission.CtxPool, r.URL.Query().Get(":name")),
+		}, nil
+	},
+}
+
+// scopedHandler requires the permission of a route declaring a scope before
+// calling the route handler. Requests without a token are left to the
+// handler, which rejects them.
+type scopedHandler struct {
+	handler    http.Handler
+	permission *permission.PermissionScheme
+	scope      *permissionScope
+}
+
+func (h *scopedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t := context.GetAuthToken(r)
+	if t == nil {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+	err := h.authorize(r, t)
+	if err != nil {
+		context.AddRequestError(r, err)
+		return
+	}
+	h.handler.ServeHTTP(w, r)
+}
+
+// authorize returns an error unless the token has the route permission in
+// the contexts derived from the request by the route scope.
+func (h *scopedHandler) authorize(r *http.Request, t auth.Token) error {
+	contexts, err := h.scope.contexts(r)
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, h.permission, contexts...) {
+		if h.scope.denied != nil {
+			return h.scope.denied(r)
+		}
+		return permission.ErrUnauthorized
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/tsuru/api/context"
+	apiRouter "github.com/tsuru/tsuru/api/router"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision/pool"
+	"github.com/tsuru/tsuru/service"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestRouteTableScopes(c *check.C) {
+	for _, r := range routeTable {
+		if r.scope == nil {
+			continue
+		}
+		c.Check(r.permission, check.NotNil, check.Commentf("route %s %s has a scope without permission", r.method, r.path))
+		var vars []string
+		switch r.scope {
+		case appScope:
+			vars = []string{"{app}", "{appname}"}
+		case serviceScope:
+			vars = []string{"{name}", "{service}"}
+		case serviceInstanceScope:
+			vars = []string{"{instance}"}
+		case teamScope, hiddenTeamScope, poolScope:
+			vars = []string{"{name}"}
+		}
+		var hasVar bool
+		for _, v := range vars {
+			hasVar = hasVar || strings.Contains(r.path, v)
+		}
+		c.Check(hasVar, check.Equals, true, check.Commentf("route %s %s has the %s scope without its path variable", r.method, r.path, r.scope.name))
+	}
+}
+
+func (s *S) TestScopedHandlerAppScope(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadInfo,
+		Context: permission.Context(permission.CtxApp, "otherapp"),
+	})
+	request, err := http.NewRequest("GET", "/apps/myapp", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	c.Assert(recorder.Body.String(), check.Equals, permission.ErrUnauthorized.Error()+"\n")
+	token = userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadInfo,
+		Context: permission.Context(permission.CtxApp, "myapp"),
+	})
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder = httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+}
+
+func (s *S) TestScopedHandlerAppNotFound(c *check.C) {
+	request, err := http.NewRequest("GET", "/apps/unknown/secrets", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	c.Assert(recorder.Body.String(), check.Equals, "App unknown not found.\n")
+}
+
+func (s *S) TestScopedHandlerServiceScope(c *check.C) {
+	srv := service.Service{Name: "mysql", Endpoint: map[string]string{"production": "mysql.com"}, OwnerTeams: []string{s.team.Name}}
+	err := srv.Create()
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermServiceUpdateDoc,
+		Context: permission.Context(permission.CtxTeam, "otherteam"),
+	})
+	request, err := http.NewRequest("PUT", "/services/mysql/doc", strings.NewReader("doc=some+doc"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	c.Assert(recorder.Body.String(), check.Equals, permission.ErrUnauthorized.Error()+"\n")
+	err = srv.Get()
+	c.Assert(err, check.IsNil)
+	c.Assert(srv.Doc, check.Equals, "")
+}
+
+func (s *S) TestScopedHandlerPoolScope(c *check.C) {
+	err := pool.AddPool(pool.AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermPoolDelete,
+		Context: permission.Context(permission.CtxPool, "pool2"),
+	})
+	request, err := http.NewRequest("DELETE", "/pools/pool1", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	_, err = pool.GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	token = userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermPoolDelete,
+		Context: permission.Context(permission.CtxPool, "pool1"),
+	})
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder = httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+}
+
+func (s *S) TestScopedHandlerWithoutToken(c *check.C) {
+	request, err := http.NewRequest("GET", "/apps/myapp/secrets", nil)
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusUnauthorized)
+}
+
+func (s *S) TestScopedHandlerRoutesSharingHandler(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	var called int
+	handler := AuthorizationRequiredHandler(func(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+		called++
+		return nil
+	})
+	m := apiRouter.NewRouter()
+	readRoute := route{version: "1.0", method: "GET", path: "/apps/{app}/shared", handler: handler, permission: permission.PermAppRead, scope: appScope}
+	updateRoute := route{version: "1.0", method: "POST", path: "/apps/{app}/shared", handler: handler, permission: permission.PermAppUpdate, scope: appScope}
+	readHandler := readRoute.register(m)
+	updateHandler := updateRoute.register(m)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppRead,
+		Context: permission.Context(permission.CtxApp, "myapp"),
+	})
+	request, err := http.NewRequest("GET", "/apps/myapp/shared?:app=myapp", nil)
+	c.Assert(err, check.IsNil)
+	context.SetAuthToken(request, token)
+	readHandler.ServeHTTP(httptest.NewRecorder(), request)
+	c.Assert(context.GetRequestError(request), check.IsNil)
+	c.Assert(called, check.Equals, 1)
+	request, err = http.NewRequest("POST", "/apps/myapp/shared?:app=myapp", nil)
+	c.Assert(err, check.IsNil)
+	context.SetAuthToken(request, token)
+	updateHandler.ServeHTTP(httptest.NewRecorder(), request)
+	c.Assert(context.GetRequestError(request), check.Equals, permission.ErrUnauthorized)
+	c.Assert(called, check.Equals, 1)
+}
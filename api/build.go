@@ -71,6 +71,13 @@ func build(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 			return &tsuruErrors.HTTP{Code: http.StatusForbidden, Message: "User does not have permission to do this action in this app"}
 		}
 	}
+	writer := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "please wait...")
+	defer writer.Stop()
+	done, err := waitDeployTurn(r, appName, lockOwner(t), writer)
+	if err != nil {
+		return err
+	}
+	defer done()
 	evt, err := event.New(&event.Opts{
 		Target:        appTarget(appName),
 		Kind:          permission.PermAppBuild,
@@ -86,8 +93,6 @@ func build(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	var imageID string
 	defer func() { evt.DoneCustomData(err, map[string]string{"image": imageID}) }()
 	opts.Event = evt
-	writer := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "please wait...")
-	defer writer.Stop()
 	opts.OutputStream = writer
 	imageID, err = app.Build(opts)
 	if err == nil {
@@ -132,8 +137,16 @@ func prepareToBuild(r *http.Request) (opts app.DeployOptions, err error) {
 			}
 		}
 	}
+	buildArgs, err := app.ParseBuildArgs(r.Form["build-arg"])
+	if err != nil {
+		return opts, &tsuruErrors.HTTP{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		}
+	}
 	opts.FileSize = fileSize
 	opts.File = file
+	opts.BuildArgs = buildArgs
 	opts.ArchiveURL = archiveURL
 	opts.Image = image
 	opts.Build = build
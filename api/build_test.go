@@ -6,11 +6,14 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io/ioutil"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"time"
 
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/app"
@@ -227,3 +230,47 @@ func (s *BuildSuite) TestBuildWithoutTag(c *check.C) {
 	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
 	c.Assert(recorder.Body.String(), check.Equals, "you must specify the image tag.\n")
 }
+
+func (s *BuildSuite) TestBuildWaitsForRunningDeploy(c *check.C) {
+	config.Set("queue:mongo-polling-interval", 0.01)
+	defer config.Unset("queue:mongo-polling-interval")
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	running, err := app.JoinDeployQueue(a.Name, "me")
+	c.Assert(err, check.IsNil)
+	err = app.WaitDeployTurn(context.Background(), running, ioutil.Discard)
+	c.Assert(err, check.IsNil)
+	defer app.LeaveDeployQueue(running)
+	request, err := http.NewRequest("POST", "/apps/"+a.Name+"/build", strings.NewReader("tag=mytag&archive-url=http://something.tar.gz"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.testServer.ServeHTTP(recorder, request)
+	}()
+	var deploys []app.QueuedDeploy
+	timeout := time.After(5 * time.Second)
+	for len(deploys) < 2 {
+		select {
+		case <-timeout:
+			c.Fatal("timeout waiting for build to be queued")
+		case <-time.After(10 * time.Millisecond):
+		}
+		deploys, err = app.ListQueuedDeploys(a.Name)
+		c.Assert(err, check.IsNil)
+	}
+	c.Assert(deploys[1].Position, check.Equals, 1)
+	err = app.CancelQueuedDeploy(a.Name, deploys[1].ID, "me")
+	c.Assert(err, check.IsNil)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		c.Fatal("timeout waiting for build to be canceled")
+	}
+	c.Assert(recorder.Body.String(), check.Matches, `(?s).*Deploy queued at position 1.*deploy canceled while waiting in queue.*`)
+}
@@ -5,10 +5,14 @@
 package api
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"runtime/pprof"
 
 	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/permission"
 )
 
@@ -23,3 +27,75 @@ func dumpGoroutines(w http.ResponseWriter, r *http.Request, t auth.Token) error
 	}
 	return pprof.Lookup("goroutine").WriteTo(w, 2)
 }
+
+type logLevels struct {
+	Default    log.Level            `json:"default"`
+	Subsystems map[string]log.Level `json:"subsystems"`
+}
+
+func currentLogLevels() logLevels {
+	base, levels := log.Levels()
+	result := logLevels{Default: base, Subsystems: map[string]log.Level{}}
+	for _, name := range log.Subsystems() {
+		result.Subsystems[name] = base
+	}
+	for name, level := range levels {
+		result.Subsystems[name] = level
+	}
+	return result
+}
+
+// title: log levels
+// path: /debug/log-levels
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   401: Unauthorized
+func logLevelsList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermDebug) {
+		return permission.ErrUnauthorized
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(currentLogLevels())
+}
+
+// title: set log level
+// path: /debug/log-levels
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/json
+// responses:
+//   200: OK
+//   400: Invalid level or subsystem
+//   401: Unauthorized
+func logLevelsSet(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermDebug) {
+		return permission.ErrUnauthorized
+	}
+	subsystem := r.FormValue("subsystem")
+	if subsystem != "" {
+		var found bool
+		for _, name := range log.Subsystems() {
+			if name == subsystem {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("unknown log subsystem %q", subsystem)}
+		}
+	}
+	levelName := r.FormValue("level")
+	if levelName == "" && subsystem != "" {
+		log.UnsetLevel(subsystem)
+	} else {
+		level, err := log.ParseLevel(levelName)
+		if err != nil {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+		}
+		log.SetLevel(subsystem, level)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(currentLogLevels())
+}
@@ -5,9 +5,12 @@
 package api
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 
+	"github.com/tsuru/tsuru/log"
 	"gopkg.in/check.v1"
 )
 
@@ -20,3 +23,80 @@ func (s *S) TestDumpGoroutines(c *check.C) {
 	c.Assert(recorder.Code, check.Equals, http.StatusOK)
 	c.Assert(recorder.Body.String(), check.Matches, `(?s)goroutine \d+ \[running\]:.*`)
 }
+
+func (s *S) TestLogLevelsList(c *check.C) {
+	log.Subsystem("queue")
+	log.SetLevel("queue", log.ErrorLevel)
+	defer log.UnsetLevel("queue")
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/debug/log-levels", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result logLevels
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Subsystems["queue"], check.Equals, log.ErrorLevel)
+}
+
+func (s *S) TestLogLevelsSet(c *check.C) {
+	log.Subsystem("queue")
+	defer log.UnsetLevel("queue")
+	recorder := httptest.NewRecorder()
+	body := strings.NewReader("subsystem=queue&level=debug")
+	request, err := http.NewRequest("POST", "/debug/log-levels", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var result logLevels
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Subsystems["queue"], check.Equals, log.DebugLevel)
+	_, levels := log.Levels()
+	c.Assert(levels["queue"], check.Equals, log.DebugLevel)
+}
+
+func (s *S) TestLogLevelsSetUnsetSubsystem(c *check.C) {
+	log.Subsystem("queue")
+	log.SetLevel("queue", log.ErrorLevel)
+	defer log.UnsetLevel("queue")
+	recorder := httptest.NewRecorder()
+	body := strings.NewReader("subsystem=queue")
+	request, err := http.NewRequest("POST", "/debug/log-levels", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	_, levels := log.Levels()
+	_, ok := levels["queue"]
+	c.Assert(ok, check.Equals, false)
+}
+
+func (s *S) TestLogLevelsSetInvalidLevel(c *check.C) {
+	recorder := httptest.NewRecorder()
+	body := strings.NewReader("level=verbose")
+	request, err := http.NewRequest("POST", "/debug/log-levels", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "invalid log level \"verbose\", it must be one of: debug, info, error\n")
+}
+
+func (s *S) TestLogLevelsSetUnknownSubsystem(c *check.C) {
+	recorder := httptest.NewRecorder()
+	body := strings.NewReader("subsystem=unknown&level=debug")
+	request, err := http.NewRequest("POST", "/debug/log-levels", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "unknown log subsystem \"unknown\"\n")
+}
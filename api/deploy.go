@@ -5,8 +5,10 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"time"
@@ -18,6 +20,7 @@ import (
 	tsuruIo "github.com/tsuru/tsuru/io"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/repository"
+	"gopkg.in/mgo.v2/bson"
 )
 
 // title: app deploy
@@ -29,6 +32,7 @@ import (
 //   400: Invalid data
 //   403: Forbidden
 //   404: Not found
+//   409: Deploys frozen
 func deploy(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	opts, err := prepareToBuild(r)
 	if err != nil {
@@ -86,12 +90,33 @@ func deploy(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	opts.Origin = origin
 	opts.Message = message
 	opts.GetKind()
+	opts.Emergency, err = deployEmergency(r)
+	if err != nil {
+		return err
+	}
 	if t.GetAppName() != app.InternalAppName {
 		canDeploy := permission.Check(t, permSchemeForDeploy(opts), contextsForApp(instance)...)
 		if !canDeploy {
 			return &tsuruErrors.HTTP{Code: http.StatusForbidden, Message: "User does not have permission to do this action in this app"}
 		}
 	}
+	writer := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "please wait...")
+	defer writer.Stop()
+	if t.GetAppName() != app.InternalAppName {
+		err = checkDeployFreeze(t, instance, opts.Emergency, writer)
+		if err != nil {
+			return err
+		}
+		err = waitDeployApproval(r.Context(), opts, writer)
+		if err != nil {
+			return err
+		}
+	}
+	done, err := waitDeployTurn(r, appName, lockOwner(t), writer)
+	if err != nil {
+		return err
+	}
+	defer done()
 	var imageID string
 	evt, err := event.New(&event.Opts{
 		Target:        appTarget(appName),
@@ -107,8 +132,6 @@ func deploy(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	}
 	defer func() { evt.DoneCustomData(err, map[string]string{"image": imageID}) }()
 	opts.Event = evt
-	writer := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "please wait...")
-	defer writer.Stop()
 	opts.OutputStream = writer
 	imageID, err = app.Deploy(opts)
 	if err == nil {
@@ -117,6 +140,53 @@ func deploy(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	return err
 }
 
+// waitDeployTurn puts the deploy in the app's deploy queue, reporting its
+// position to w while it waits, and acquires the app lock once it's the
+// deploy's turn to run. The returned function releases both and must be
+// called after the deploy finishes.
+func waitDeployTurn(r *http.Request, appName, owner string, w io.Writer) (func(), error) {
+	return waitDeployTurnContext(r.Context(), appName, owner, fmt.Sprintf("%s %s", r.Method, r.URL.Path), w)
+}
+
+// waitDeployTurnContext is like waitDeployTurn, for deploys not bound to the
+// lifetime of a request. reason is recorded in the app lock.
+func waitDeployTurnContext(ctx context.Context, appName, owner, reason string, w io.Writer) (func(), error) {
+	slot, err := app.JoinDeployQueue(appName, owner)
+	if err != nil {
+		return nil, err
+	}
+	err = app.WaitDeployTurn(ctx, slot, w)
+	if err != nil {
+		return nil, err
+	}
+	locked, err := app.AcquireApplicationLockWait(appName, owner, reason, lockWaitDuration)
+	if err == nil && !locked {
+		err = appLockedError(appName)
+	}
+	if err != nil {
+		app.LeaveDeployQueue(slot)
+		return nil, err
+	}
+	return func() {
+		app.ReleaseApplicationLock(appName)
+		app.LeaveDeployQueue(slot)
+	}, nil
+}
+
+// deployEmergency returns whether the deploy request asks to go through the
+// deploy freeze windows.
+func deployEmergency(r *http.Request) (bool, error) {
+	emergency := r.FormValue("emergency")
+	if emergency == "" {
+		return false, nil
+	}
+	value, err := strconv.ParseBool(emergency)
+	if err != nil {
+		return false, &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: "emergency must be a boolean"}
+	}
+	return value, nil
+}
+
 func permSchemeForDeploy(opts app.DeployOptions) *permission.PermissionScheme {
 	switch opts.GetKind() {
 	case app.DeployGit:
@@ -165,9 +235,7 @@ func diffDeploy(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if err != nil {
 		return err
 	}
-	return evt.SetOtherCustomData(map[string]string{
-		"diff": diff,
-	})
+	return evt.SetOtherCustomDataKey("diff", diff)
 }
 
 // title: rollback
@@ -180,6 +248,7 @@ func diffDeploy(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 //   400: Invalid data
 //   403: Forbidden
 //   404: Not found
+//   409: Deploys frozen
 func deployRollback(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	appName := r.URL.Query().Get(":appname")
 	instance, err := app.GetByName(appName)
@@ -214,11 +283,28 @@ func deployRollback(w http.ResponseWriter, r *http.Request, t auth.Token) error
 		Origin:       origin,
 		Rollback:     true,
 	}
+	opts.Emergency, err = deployEmergency(r)
+	if err != nil {
+		return err
+	}
 	opts.GetKind()
 	canRollback := permission.Check(t, permSchemeForDeploy(opts), contextsForApp(instance)...)
 	if !canRollback {
 		return &tsuruErrors.HTTP{Code: http.StatusForbidden, Message: permission.ErrUnauthorized.Error()}
 	}
+	err = checkDeployFreeze(t, instance, opts.Emergency, writer)
+	if err != nil {
+		return err
+	}
+	err = waitDeployApproval(r.Context(), opts, writer)
+	if err != nil {
+		return err
+	}
+	done, err := waitDeployTurn(r, appName, lockOwner(t), writer)
+	if err != nil {
+		return err
+	}
+	defer done()
 	var imageID string
 	evt, err := event.New(&event.Opts{
 		Target:        appTarget(appName),
@@ -241,6 +327,112 @@ func deployRollback(w http.ResponseWriter, r *http.Request, t auth.Token) error
 	return nil
 }
 
+// title: deploy queue list
+// path: /apps/{app}/deploy/queue
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+//   404: App not found
+func deployQueueList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	deploys, err := app.ListQueuedDeploys(a.Name)
+	if err != nil {
+		return err
+	}
+	if len(deploys) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(deploys)
+}
+
+// title: deploy queue cancel
+// path: /apps/{app}/deploy/queue/{id}
+// method: DELETE
+// responses:
+//   200: Queued deploy canceled
+//   401: Unauthorized
+//   404: App or queued deploy not found
+//   409: Deploy already running
+func deployQueueCancel(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	appName := r.URL.Query().Get(":app")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppUpdateDeployCancel,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = app.CancelQueuedDeploy(a.Name, r.URL.Query().Get(":id"), t.GetUserName())
+	switch err {
+	case app.ErrQueuedDeployNotFound:
+		return &tsuruErrors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	case app.ErrQueuedDeployNotWaiting:
+		return &tsuruErrors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	}
+	return err
+}
+
+// title: cancel deploy
+// path: /apps/{app}/deploy/{id}/cancel
+// method: POST
+// consume: application/x-www-form-urlencoded
+// responses:
+//   204: Cancel requested
+//   400: Invalid id
+//   401: Unauthorized
+//   404: App or deploy not found
+//   409: Deploy not running or cancel already requested
+func deployCancel(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	appName := r.URL.Query().Get(":app")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return err
+	}
+	id := r.URL.Query().Get(":id")
+	if !bson.IsObjectIdHex(id) {
+		return &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("invalid deploy id: %s", id)}
+	}
+	evt, err := event.GetByID(bson.ObjectIdHex(id))
+	if err == event.ErrEventNotFound ||
+		(err == nil && (evt.Target != appTarget(a.Name) || evt.Kind.Name != permission.PermAppDeploy.FullName())) {
+		return &tsuruErrors.HTTP{Code: http.StatusNotFound, Message: "Deploy not found."}
+	}
+	if err != nil {
+		return err
+	}
+	reason := r.FormValue("reason")
+	if reason == "" {
+		reason = "canceled by user request"
+	}
+	err = evt.TryCancel(reason, t.GetUserName())
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	case event.ErrNotCancelable:
+		return &tsuruErrors.HTTP{Code: http.StatusConflict, Message: "Deploy is not running."}
+	case event.ErrCancelAlreadyRequested:
+		return &tsuruErrors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	}
+	return err
+}
+
 // title: deploy list
 // path: /deploys
 // method: GET
@@ -301,6 +493,40 @@ func deployInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	return json.NewEncoder(w).Encode(deploy)
 }
 
+// title: app deploy info
+// path: /apps/{app}/deploys/{id}
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: Not found
+func appDeployInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	appName := r.URL.Query().Get(":app")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return err
+	}
+	id := r.URL.Query().Get(":id")
+	if !bson.IsObjectIdHex(id) {
+		return &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("invalid deploy id: %s", id)}
+	}
+	evt, err := event.GetByID(bson.ObjectIdHex(id))
+	if err == event.ErrEventNotFound ||
+		(err == nil && (evt.Target != appTarget(a.Name) || evt.Kind.Name != permission.PermAppDeploy.FullName())) {
+		return &tsuruErrors.HTTP{Code: http.StatusNotFound, Message: "Deploy not found."}
+	}
+	if err != nil {
+		return err
+	}
+	deploy, err := app.GetDeploy(id)
+	if err != nil {
+		return err
+	}
+	w.Header().Add("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(deploy)
+}
+
 // title: rebuild
 // path: /apps/{appname}/deploy/rebuild
 // method: POST
@@ -311,6 +537,7 @@ func deployInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 //   400: Invalid data
 //   403: Forbidden
 //   404: Not found
+//   409: Deploys frozen
 func deployRebuild(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	appName := r.URL.Query().Get(":appname")
 	instance, err := app.GetByName(appName)
@@ -335,10 +562,27 @@ func deployRebuild(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 		Origin:       origin,
 		Kind:         app.DeployRebuild,
 	}
+	opts.Emergency, err = deployEmergency(r)
+	if err != nil {
+		return err
+	}
 	canDeploy := permission.Check(t, permSchemeForDeploy(opts), contextsForApp(instance)...)
 	if !canDeploy {
 		return &tsuruErrors.HTTP{Code: http.StatusForbidden, Message: permission.ErrUnauthorized.Error()}
 	}
+	err = checkDeployFreeze(t, instance, opts.Emergency, writer)
+	if err != nil {
+		return err
+	}
+	err = waitDeployApproval(r.Context(), opts, writer)
+	if err != nil {
+		return err
+	}
+	done, err := waitDeployTurn(r, appName, lockOwner(t), writer)
+	if err != nil {
+		return err
+	}
+	defer done()
 	var imageID string
 	evt, err := event.New(&event.Opts{
 		Target:        appTarget(appName),
@@ -0,0 +1,169 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+)
+
+// waitDeployApproval holds deploys of apps requiring approval until another
+// user approves them. It must be called before the deploy joins the deploy
+// queue, so waiting deploys don't block the queue.
+func waitDeployApproval(ctx context.Context, opts app.DeployOptions, w io.Writer) error {
+	if !opts.App.DeployApproval {
+		return nil
+	}
+	approval, err := app.RequestDeployApproval(opts)
+	if err != nil {
+		return err
+	}
+	return app.WaitDeployApproval(ctx, approval, w)
+}
+
+// title: deploy approval set
+// path: /apps/{app}/deploy/approval
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Approval setting updated
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App not found
+func deployApprovalSet(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	required, err := strconv.ParseBool(r.FormValue("required"))
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "required must be a boolean"}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppUpdateDeployApproval,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return a.SetDeployApproval(required)
+}
+
+// title: deploy approval list
+// path: /apps/{app}/deploy/approvals
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+//   404: App not found
+func deployApprovalList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	approvals, err := app.ListDeployApprovals(a.Name, r.URL.Query().Get("status"))
+	if err != nil {
+		return err
+	}
+	if len(approvals) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(approvals)
+}
+
+// title: deploy approve
+// path: /apps/{app}/deploy/approvals/{id}/approve
+// method: POST
+// produce: application/json
+// responses:
+//   200: Deploy approved
+//   401: Unauthorized
+//   403: Deploy requested by the same user
+//   404: App or approval not found
+//   409: Deploy not waiting for approval
+func deployApprove(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:      appTarget(a.Name),
+		Kind:        permission.PermAppDeployApprove,
+		Owner:       t,
+		CustomData:  map[string]interface{}{"approval": r.URL.Query().Get(":id"), "approve": true},
+		DisableLock: true,
+		Allowed:     event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return decideDeployApproval(w, r, t, true)
+}
+
+// title: deploy reject
+// path: /apps/{app}/deploy/approvals/{id}/reject
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/json
+// responses:
+//   200: Deploy rejected
+//   401: Unauthorized
+//   403: Deploy requested by the same user
+//   404: App or approval not found
+//   409: Deploy not waiting for approval
+func deployReject(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:      appTarget(a.Name),
+		Kind:        permission.PermAppDeployApprove,
+		Owner:       t,
+		CustomData:  map[string]interface{}{"approval": r.URL.Query().Get(":id"), "approve": false, "reason": r.FormValue("reason")},
+		DisableLock: true,
+		Allowed:     event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return decideDeployApproval(w, r, t, false)
+}
+
+func decideDeployApproval(w http.ResponseWriter, r *http.Request, t auth.Token, approve bool) error {
+	approval, err := app.DecideDeployApproval(r.URL.Query().Get(":app"), r.URL.Query().Get(":id"), t.GetUserName(), approve, r.FormValue("reason"))
+	switch err {
+	case nil:
+	case app.ErrDeployApprovalNotFound:
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	case app.ErrDeployApprovalSelf:
+		return &errors.HTTP{Code: http.StatusForbidden, Message: err.Error()}
+	case app.ErrDeployApprovalDecided:
+		return &errors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	default:
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(approval)
+}
@@ -0,0 +1,207 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *DeploySuite) TestDeployApprovalSet(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("PUT", "/1.6/apps/"+a.Name+"/deploy/approval", strings.NewReader("required=true"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateDeployApproval,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	request, err = http.NewRequest("PUT", "/1.6/apps/"+a.Name+"/deploy/approval", strings.NewReader("required=true"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder = httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.DeployApproval, check.Equals, true)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  token.GetUserName(),
+		Kind:   "app.update.deploy.approval",
+		StartCustomData: []map[string]interface{}{
+			{"name": "required", "value": "true"},
+			{"name": ":app", "value": a.Name},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *DeploySuite) TestDeployApprovalList(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/1.6/apps/"+a.Name+"/deploy/approvals", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+	approval, err := app.RequestDeployApproval(app.DeployOptions{App: &a, User: "me", Image: "registry.example.com/app:v1"})
+	c.Assert(err, check.IsNil)
+	request, err = http.NewRequest("GET", "/1.6/apps/"+a.Name+"/deploy/approvals?status=pending", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var approvals []app.DeployApproval
+	err = json.Unmarshal(recorder.Body.Bytes(), &approvals)
+	c.Assert(err, check.IsNil)
+	c.Assert(approvals, check.HasLen, 1)
+	c.Assert(approvals[0].ID, check.Equals, approval.ID)
+	c.Assert(approvals[0].Requester, check.Equals, "me")
+	c.Assert(approvals[0].Status, check.Equals, app.DeployApprovalPending)
+}
+
+func (s *DeploySuite) TestDeployApprove(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	approval, err := app.RequestDeployApproval(app.DeployOptions{App: &a, User: "me", Image: "registry.example.com/app:v1"})
+	c.Assert(err, check.IsNil)
+	path := "/1.6/apps/" + a.Name + "/deploy/approvals/" + approval.ID.Hex() + "/approve"
+	request, err := http.NewRequest("POST", path, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var result app.DeployApproval
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Status, check.Equals, app.DeployApprovalApproved)
+	c.Assert(result.Decider, check.Equals, s.token.GetUserName())
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.deploy.approve",
+	}, eventtest.HasEvent)
+	request, err = http.NewRequest("POST", path, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+}
+
+func (s *DeploySuite) TestDeployApproveOwnDeploy(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	approval, err := app.RequestDeployApproval(app.DeployOptions{App: &a, User: s.token.GetUserName(), Image: "registry.example.com/app:v1"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/1.6/apps/"+a.Name+"/deploy/approvals/"+approval.ID.Hex()+"/approve", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	c.Assert(recorder.Body.String(), check.Equals, app.ErrDeployApprovalSelf.Error()+"\n")
+}
+
+func (s *DeploySuite) TestDeployReject(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	approval, err := app.RequestDeployApproval(app.DeployOptions{App: &a, User: "me", Image: "registry.example.com/app:v1"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/1.6/apps/"+a.Name+"/deploy/approvals/"+approval.ID.Hex()+"/reject", strings.NewReader("reason=not+today"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	dbApproval, err := app.GetDeployApproval(a.Name, approval.ID.Hex())
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApproval.Status, check.Equals, app.DeployApprovalRejected)
+	c.Assert(dbApproval.Reason, check.Equals, "not today")
+}
+
+func (s *DeploySuite) TestDeployApproveNotFound(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/1.6/apps/"+a.Name+"/deploy/approvals/invalid/approve", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *DeploySuite) TestDeployRollbackAndRebuildWaitApproval(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	err = a.SetDeployApproval(true)
+	c.Assert(err, check.IsNil)
+	server := RunServer(true)
+	for _, tt := range []struct {
+		path string
+		kind app.DeployKind
+		form url.Values
+	}{
+		{path: "rollback", kind: app.DeployRollback, form: url.Values{"origin": {"rollback"}, "image": {"my-image-123:v1"}}},
+		{path: "rebuild", kind: app.DeployRebuild, form: url.Values{"origin": {"rebuild"}}},
+	} {
+		request, err := http.NewRequest("POST", "/apps/"+a.Name+"/deploy/"+tt.path, strings.NewReader(tt.form.Encode()))
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		recorder := httptest.NewRecorder()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			server.ServeHTTP(recorder, request)
+		}()
+		var approvals []app.DeployApproval
+		for i := 0; i < 100 && len(approvals) == 0; i++ {
+			approvals, err = app.ListDeployApprovals(a.Name, app.DeployApprovalPending)
+			c.Assert(err, check.IsNil)
+			time.Sleep(50 * time.Millisecond)
+		}
+		c.Assert(approvals, check.HasLen, 1)
+		c.Assert(approvals[0].Kind, check.Equals, tt.kind)
+		_, err = app.DecideDeployApproval(a.Name, approvals[0].ID.Hex(), "approver@example.com", false, "not today")
+		c.Assert(err, check.IsNil)
+		<-done
+		c.Assert(recorder.Body.String(), check.Matches, "(?s).*deploy rejected by approver@example.com: not today.*")
+		c.Assert(recorder.Body.String(), check.Not(check.Matches), "(?s).*deploy called.*")
+	}
+}
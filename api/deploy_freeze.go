@@ -0,0 +1,129 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ajg/form"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	authTypes "github.com/tsuru/tsuru/types/auth"
+)
+
+// checkDeployFreeze rejects deploys during the freeze windows of the team
+// owning the app. Emergency deploys go through, as long as the token is
+// allowed to override freezes, with a notice written to w.
+func checkDeployFreeze(t auth.Token, a *app.App, emergency bool, w io.Writer) error {
+	err := a.CheckDeployFreeze(time.Now())
+	frozenErr, ok := err.(*app.DeployFrozenError)
+	if !ok {
+		return err
+	}
+	if !emergency {
+		return &errors.HTTP{Code: http.StatusConflict, Message: frozenErr.Error()}
+	}
+	if t == nil || !permission.Check(t, permission.PermAppAdminDeployFreeze, contextsForApp(a)...) {
+		return &errors.HTTP{Code: http.StatusForbidden, Message: "User does not have permission to do emergency deploys in this app"}
+	}
+	fmt.Fprintf(w, "---- Emergency deploy during freeze window of team %q ----\n", frozenErr.Team)
+	return nil
+}
+
+// title: deploy freeze info
+// path: /teams/{name}/deploy-freeze
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: Deploy freeze not found
+func deployFreezeInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	name := r.URL.Query().Get(":name")
+	freeze, err := app.GetDeployFreeze(name)
+	if err == app.ErrDeployFreezeNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(freeze)
+}
+
+// title: deploy freeze update
+// path: /teams/{name}/deploy-freeze
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: OK
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Team not found
+func deployFreezeUpdate(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	name := r.URL.Query().Get(":name")
+	err = r.ParseForm()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	var freeze app.DeployFreeze
+	dec := form.NewDecoder(nil)
+	dec.IgnoreUnknownKeys(true)
+	err = dec.DecodeValues(&freeze, r.Form)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	freeze.Team = name
+	evt, err := event.New(&event.Opts{
+		Target:     teamTarget(name),
+		Kind:       permission.PermTeamDeployFreezeUpdate,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermTeamReadEvents, permission.Context(permission.CtxTeam, name)),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = app.SaveDeployFreeze(freeze)
+	if err == authTypes.ErrTeamNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
+
+// title: deploy freeze delete
+// path: /teams/{name}/deploy-freeze
+// method: DELETE
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: Deploy freeze not found
+func deployFreezeDelete(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	name := r.URL.Query().Get(":name")
+	evt, err := event.New(&event.Opts{
+		Target:     teamTarget(name),
+		Kind:       permission.PermTeamDeployFreezeDelete,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermTeamReadEvents, permission.Context(permission.CtxTeam, name)),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = app.RemoveDeployFreeze(name)
+	if err == app.ErrDeployFreezeNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
@@ -0,0 +1,259 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+// currentFreezeWindow returns a freeze window including the current time.
+func currentFreezeWindow() app.FreezeWindow {
+	now := time.Now().UTC()
+	return app.FreezeWindow{
+		Start:  now.Add(-time.Hour).Format("Mon 15:04"),
+		End:    now.Add(time.Hour).Format("Mon 15:04"),
+		Reason: "release party",
+	}
+}
+
+func (s *S) TestDeployFreezeUpdate(c *check.C) {
+	body := strings.NewReader("Windows.0.Start=Fri 18:00&Windows.0.End=Mon 08:00&Windows.0.Timezone=America/Sao_Paulo")
+	request, err := http.NewRequest("PUT", "/teams/"+s.team.Name+"/deploy-freeze", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK, check.Commentf("body: %s", recorder.Body.String()))
+	freeze, err := app.GetDeployFreeze(s.team.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(freeze, check.DeepEquals, &app.DeployFreeze{
+		Team:    s.team.Name,
+		Windows: []app.FreezeWindow{{Start: "Fri 18:00", End: "Mon 08:00", Timezone: "America/Sao_Paulo"}},
+	})
+	c.Assert(eventtest.EventDesc{
+		Target: teamTarget(s.team.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "team.deploy-freeze.update",
+		StartCustomData: []map[string]interface{}{
+			{"name": "Windows.0.Start", "value": "Fri 18:00"},
+			{"name": "Windows.0.End", "value": "Mon 08:00"},
+			{"name": "Windows.0.Timezone", "value": "America/Sao_Paulo"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestDeployFreezeUpdateInvalid(c *check.C) {
+	body := strings.NewReader("Windows.0.Start=Fri 18:00&Windows.0.End=Someday 08:00")
+	request, err := http.NewRequest("PUT", "/teams/"+s.team.Name+"/deploy-freeze", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "invalid weekday \"Someday\"\n")
+}
+
+func (s *S) TestDeployFreezeUpdateWithoutPermission(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermTeamDeployFreezeRead,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	body := strings.NewReader("Windows.0.Start=Fri 18:00&Windows.0.End=Mon 08:00")
+	request, err := http.NewRequest("PUT", "/teams/"+s.team.Name+"/deploy-freeze", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestDeployFreezeInfo(c *check.C) {
+	window := app.FreezeWindow{Start: "Fri 18:00", End: "Mon 08:00"}
+	err := app.SaveDeployFreeze(app.DeployFreeze{Team: s.team.Name, Windows: []app.FreezeWindow{window}})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermTeamDeployFreezeRead,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	request, err := http.NewRequest("GET", "/teams/"+s.team.Name+"/deploy-freeze", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var freeze app.DeployFreeze
+	err = json.NewDecoder(recorder.Body).Decode(&freeze)
+	c.Assert(err, check.IsNil)
+	c.Assert(freeze, check.DeepEquals, app.DeployFreeze{Team: s.team.Name, Windows: []app.FreezeWindow{window}})
+}
+
+func (s *S) TestDeployFreezeInfoNotFound(c *check.C) {
+	request, err := http.NewRequest("GET", "/teams/"+s.team.Name+"/deploy-freeze", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestDeployFreezeDelete(c *check.C) {
+	err := app.SaveDeployFreeze(app.DeployFreeze{Team: s.team.Name, Windows: []app.FreezeWindow{{Start: "Fri 18:00", End: "Mon 08:00"}}})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", "/teams/"+s.team.Name+"/deploy-freeze", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	_, err = app.GetDeployFreeze(s.team.Name)
+	c.Assert(err, check.Equals, app.ErrDeployFreezeNotFound)
+	c.Assert(eventtest.EventDesc{
+		Target: teamTarget(s.team.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "team.deploy-freeze.delete",
+	}, eventtest.HasEvent)
+}
+
+func (s *DeploySuite) TestDeployFrozen(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name, Router: "fake"}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	err = app.SaveDeployFreeze(app.DeployFreeze{Team: s.team.Name, Windows: []app.FreezeWindow{currentFreezeWindow()}})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/apps/"+a.Name+"/repository/clone", strings.NewReader("archive-url=http://something.tar.gz"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+	c.Assert(recorder.Body.String(), check.Matches, `deploys of apps owned by team "tsuruteam" are frozen until .*: release party\. .*emergency flag\n`)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Kind:   "app.deploy",
+	}, check.Not(eventtest.HasEvent))
+}
+
+func (s *DeploySuite) TestDeployFrozenEmergencyWithoutPermission(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name, Router: "fake"}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	err = app.SaveDeployFreeze(app.DeployFreeze{Team: s.team.Name, Windows: []app.FreezeWindow{currentFreezeWindow()}})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/apps/"+a.Name+"/repository/clone", strings.NewReader("archive-url=http://something.tar.gz&emergency=true"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	c.Assert(recorder.Body.String(), check.Equals, "User does not have permission to do emergency deploys in this app\n")
+}
+
+func (s *DeploySuite) TestDeployFrozenEmergency(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name, Router: "fake"}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	err = app.SaveDeployFreeze(app.DeployFreeze{Team: s.team.Name, Windows: []app.FreezeWindow{currentFreezeWindow()}})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppDeploy,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	}, permission.Permission{
+		Scheme:  permission.PermAppAdminDeployFreeze,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	request, err := http.NewRequest("POST", "/apps/"+a.Name+"/repository/clone", strings.NewReader("archive-url=http://something.tar.gz&emergency=true"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), check.Equals, "---- Emergency deploy during freeze window of team \"tsuruteam\" ----\nBuilder deploy called\nOK\n")
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  token.GetUserName(),
+		Kind:   "app.deploy",
+		StartCustomData: map[string]interface{}{
+			"app.name":  a.Name,
+			"emergency": true,
+		},
+		EndCustomData: map[string]interface{}{
+			"image": "app-image",
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *DeploySuite) TestDeployRollbackAndRebuildFrozen(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name, Router: "fake"}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	err = app.SaveDeployFreeze(app.DeployFreeze{Team: s.team.Name, Windows: []app.FreezeWindow{currentFreezeWindow()}})
+	c.Assert(err, check.IsNil)
+	for _, path := range []string{"rollback?origin=rollback&image=my-image-123:v1", "rebuild?origin=rebuild"} {
+		request, err := http.NewRequest("POST", "/apps/"+a.Name+"/deploy/"+path, nil)
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		recorder := httptest.NewRecorder()
+		s.testServer.ServeHTTP(recorder, request)
+		c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+		c.Assert(recorder.Body.String(), check.Matches, `deploys of apps owned by team "tsuruteam" are frozen until .*: release party\. .*emergency flag\n`)
+	}
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Kind:   "app.deploy",
+	}, check.Not(eventtest.HasEvent))
+}
+
+func (s *DeploySuite) TestDeployRollbackFrozenEmergency(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name, Router: "fake"}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	err = app.SaveDeployFreeze(app.DeployFreeze{Team: s.team.Name, Windows: []app.FreezeWindow{currentFreezeWindow()}})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppDeploy,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	}, permission.Permission{
+		Scheme:  permission.PermAppAdminDeployFreeze,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	request, err := http.NewRequest("POST", "/apps/"+a.Name+"/deploy/rollback?origin=rollback&image=my-image-123:v1&emergency=true", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), check.Equals, "{\"Message\":\"---- Emergency deploy during freeze window of team \\\"tsuruteam\\\" ----\\n\"}\n{\"Message\":\"Rollback deploy called\"}\n")
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  token.GetUserName(),
+		Kind:   "app.deploy",
+		StartCustomData: map[string]interface{}{
+			"app.name":  a.Name,
+			"rollback":  true,
+			"emergency": true,
+		},
+	}, eventtest.HasEvent)
+}
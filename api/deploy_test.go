@@ -6,6 +6,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -226,6 +227,23 @@ func (s *DeploySuite) TestDeployInvalidOrigin(c *check.C) {
 	c.Assert(recorder.Body.String(), check.Equals, "Invalid deployment origin\n")
 }
 
+func (s *DeploySuite) TestDeployInvalidBuildArg(c *check.C) {
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	user, _ := s.token.User()
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	url := fmt.Sprintf("/apps/%s/deploy", a.Name)
+	request, err := http.NewRequest("POST", url, strings.NewReader("archive-url=http://something.tar.gz&build-arg=NPM_TOKEN"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "invalid build argument \"NPM_TOKEN\", it must be in the NAME=value format\n")
+}
+
 func (s *DeploySuite) TestDeployOriginImage(c *check.C) {
 	user, _ := s.token.User()
 	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
@@ -997,6 +1015,62 @@ func (s *DeploySuite) TestDeployInfoDiff(c *check.C) {
 	c.Assert(result, check.DeepEquals, lastDeploy)
 }
 
+func (s *DeploySuite) TestAppDeployInfo(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "g1", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	depData := []app.DeployData{
+		{App: "g1", Timestamp: time.Now(), Commit: "e82nn93nd93mm12o2ueh83dhbd3iu112", Origin: "git", Diff: "fake-diff", Log: "building"},
+	}
+	evts := insertDeploysAsEvents(depData, c)
+	changes := app.DeployChanges{
+		PreviousCommit: "e293e3e3me03ejm3puejmp3ej3iejop32",
+		EnvsChanged:    []string{"DATABASE_URL"},
+		ServicesBound:  []string{"mysql/mydb"},
+	}
+	err = evts[0].SetOtherCustomDataKey("changes", changes)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", fmt.Sprintf("/apps/g1/deploys/%s", evts[0].UniqueID.Hex()), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result app.DeployData
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.ID, check.Equals, evts[0].UniqueID)
+	c.Assert(result.Diff, check.Equals, "fake-diff")
+	c.Assert(result.Log, check.Equals, "building\n")
+	c.Assert(result.Changes, check.DeepEquals, &changes)
+}
+
+func (s *DeploySuite) TestAppDeployInfoOtherApp(c *check.C) {
+	user, _ := s.token.User()
+	for _, name := range []string{"g1", "g2"} {
+		a := app.App{Name: name, Platform: "python", TeamOwner: s.team.Name}
+		err := app.CreateApp(&a, user)
+		c.Assert(err, check.IsNil)
+	}
+	evts := insertDeploysAsEvents([]app.DeployData{{App: "g1", Timestamp: time.Now()}}, c)
+	request, err := http.NewRequest("GET", fmt.Sprintf("/apps/g2/deploys/%s", evts[0].UniqueID.Hex()), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	request, err = http.NewRequest("GET", "/apps/g2/deploys/invalid", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+}
+
 func (s *DeploySuite) TestDeployInfoByNonAdminUser(c *check.C) {
 	user, _ := s.token.User()
 	a := app.App{Name: "g1", Platform: "python", TeamOwner: s.team.Name}
@@ -1533,3 +1607,273 @@ func (s *DeploySuite) TestRollbackUpdateErrNoPerms(c *check.C) {
 	c.Assert(recorder.Body.String(), check.Equals, "User does not have permission to do this action in this app\n")
 	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
 }
+
+func (s *DeploySuite) TestDeployQueueList(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	first, err := app.JoinDeployQueue(a.Name, "me")
+	c.Assert(err, check.IsNil)
+	defer app.LeaveDeployQueue(first)
+	second, err := app.JoinDeployQueue(a.Name, "you")
+	c.Assert(err, check.IsNil)
+	defer app.LeaveDeployQueue(second)
+	request, err := http.NewRequest("GET", "/1.6/apps/"+a.Name+"/deploy/queue", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var deploys []app.QueuedDeploy
+	err = json.Unmarshal(recorder.Body.Bytes(), &deploys)
+	c.Assert(err, check.IsNil)
+	c.Assert(deploys, check.HasLen, 2)
+	c.Assert(deploys[0].ID, check.Equals, first.ID.Hex())
+	c.Assert(deploys[0].Owner, check.Equals, "me")
+	c.Assert(deploys[0].Position, check.Equals, 1)
+	c.Assert(deploys[1].ID, check.Equals, second.ID.Hex())
+	c.Assert(deploys[1].Position, check.Equals, 2)
+}
+
+func (s *DeploySuite) TestDeployQueueListEmpty(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/1.6/apps/"+a.Name+"/deploy/queue", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *DeploySuite) TestDeployQueueCancel(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	slot, err := app.JoinDeployQueue(a.Name, "me")
+	c.Assert(err, check.IsNil)
+	defer app.LeaveDeployQueue(slot)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateDeployCancel,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	request, err := http.NewRequest("DELETE", "/1.6/apps/"+a.Name+"/deploy/queue/"+slot.ID.Hex(), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	deploys, err := app.ListQueuedDeploys(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(deploys, check.HasLen, 0)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  token.GetUserName(),
+		Kind:   "app.update.deploy.cancel",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":app", "value": a.Name},
+			{"name": ":id", "value": slot.ID.Hex()},
+		},
+	}, eventtest.HasEvent)
+	recorder = httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *DeploySuite) TestDeployQueueCancelForbidden(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	slot, err := app.JoinDeployQueue(a.Name, "me")
+	c.Assert(err, check.IsNil)
+	defer app.LeaveDeployQueue(slot)
+	request, err := http.NewRequest("DELETE", "/1.6/apps/"+a.Name+"/deploy/queue/"+slot.ID.Hex(), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *DeploySuite) newRunningDeploy(c *check.C, appName string) *event.Event {
+	evt, err := event.New(&event.Opts{
+		Target:        appTarget(appName),
+		Kind:          permission.PermAppDeploy,
+		Owner:         s.token,
+		Allowed:       event.Allowed(permission.PermAppReadEvents),
+		AllowedCancel: event.Allowed(permission.PermAppUpdateEvents),
+		Cancelable:    true,
+	})
+	c.Assert(err, check.IsNil)
+	return evt
+}
+
+func (s *DeploySuite) TestDeployCancel(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	evt := s.newRunningDeploy(c, a.Name)
+	defer evt.Done(nil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateDeployCancel,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	cancelURL := fmt.Sprintf("/1.6/apps/%s/deploy/%s/cancel", a.Name, evt.UniqueID.Hex())
+	request, err := http.NewRequest("POST", cancelURL, strings.NewReader("reason=wrong+branch"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+	evt, err = event.GetByID(evt.UniqueID)
+	c.Assert(err, check.IsNil)
+	c.Assert(evt.CancelInfo.Asked, check.Equals, true)
+	c.Assert(evt.CancelInfo.Reason, check.Equals, "wrong branch")
+	c.Assert(evt.CancelInfo.Owner, check.Equals, token.GetUserName())
+	recorder = httptest.NewRecorder()
+	request, err = http.NewRequest("POST", cancelURL, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+}
+
+func (s *DeploySuite) TestDeployCancelDefaultReason(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	evt := s.newRunningDeploy(c, a.Name)
+	defer evt.Done(nil)
+	request, err := http.NewRequest("POST", "/1.6/apps/"+a.Name+"/deploy/"+evt.UniqueID.Hex()+"/cancel", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+	evt, err = event.GetByID(evt.UniqueID)
+	c.Assert(err, check.IsNil)
+	c.Assert(evt.CancelInfo.Reason, check.Equals, "canceled by user request")
+}
+
+func (s *DeploySuite) TestDeployCancelNotRunning(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	evt := s.newRunningDeploy(c, a.Name)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/1.6/apps/"+a.Name+"/deploy/"+evt.UniqueID.Hex()+"/cancel", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+	c.Assert(recorder.Body.String(), check.Equals, "Deploy is not running.\n")
+}
+
+func (s *DeploySuite) TestDeployCancelOtherApp(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	evt := s.newRunningDeploy(c, "anotherapp")
+	defer evt.Done(nil)
+	request, err := http.NewRequest("POST", "/1.6/apps/"+a.Name+"/deploy/"+evt.UniqueID.Hex()+"/cancel", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	c.Assert(recorder.Body.String(), check.Equals, "Deploy not found.\n")
+}
+
+func (s *DeploySuite) TestDeployCancelInvalidID(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/1.6/apps/"+a.Name+"/deploy/abc/cancel", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "invalid deploy id: abc\n")
+}
+
+func (s *DeploySuite) TestDeployCancelForbidden(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	evt := s.newRunningDeploy(c, a.Name)
+	defer evt.Done(nil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadDeploy,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	request, err := http.NewRequest("POST", "/1.6/apps/"+a.Name+"/deploy/"+evt.UniqueID.Hex()+"/cancel", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *DeploySuite) TestDeployWaitsForRunningDeploy(c *check.C) {
+	config.Set("queue:mongo-polling-interval", 0.01)
+	defer config.Unset("queue:mongo-polling-interval")
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	running, err := app.JoinDeployQueue(a.Name, "me")
+	c.Assert(err, check.IsNil)
+	err = app.WaitDeployTurn(context.Background(), running, ioutil.Discard)
+	c.Assert(err, check.IsNil)
+	defer app.LeaveDeployQueue(running)
+	request, err := http.NewRequest("POST", "/apps/"+a.Name+"/deploy", strings.NewReader("archive-url=http://something.tar.gz"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.testServer.ServeHTTP(recorder, request)
+	}()
+	var deploys []app.QueuedDeploy
+	timeout := time.After(5 * time.Second)
+	for len(deploys) < 2 {
+		select {
+		case <-timeout:
+			c.Fatal("timeout waiting for deploy to be queued")
+		case <-time.After(10 * time.Millisecond):
+		}
+		deploys, err = app.ListQueuedDeploys(a.Name)
+		c.Assert(err, check.IsNil)
+	}
+	c.Assert(deploys[0].Running, check.Equals, true)
+	c.Assert(deploys[1].Position, check.Equals, 1)
+	err = app.CancelQueuedDeploy(a.Name, deploys[1].ID, "me")
+	c.Assert(err, check.IsNil)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		c.Fatal("timeout waiting for deploy to be canceled")
+	}
+	c.Assert(recorder.Body.String(), check.Matches, `(?s).*Deploy queued at position 1.*deploy canceled while waiting in queue.*`)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Deploys, check.Equals, uint(0))
+}
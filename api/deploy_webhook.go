@@ -0,0 +1,322 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+)
+
+const maxWebhookPayloadSize = 5 * 1024 * 1024
+
+// webhookPush is a push notification sent by a git provider.
+type webhookPush struct {
+	Provider   string
+	Branch     string
+	Commit     string
+	Message    string
+	Pusher     string
+	ArchiveURL string
+}
+
+var errInvalidWebhookSignature = &errors.HTTP{Code: http.StatusUnauthorized, Message: "invalid webhook signature"}
+
+// title: deploy webhook set
+// path: /apps/{app}/deploy/webhook
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// produce: application/json
+// responses:
+//   200: Webhook configured
+//   401: Unauthorized
+//   404: App not found
+func deployWebhookSet(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	hook := app.DeployWebhook{
+		Branch: r.FormValue("branch"),
+		Secret: r.FormValue("secret"),
+	}
+	delete(r.Form, "secret")
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppUpdateDeployWebhook,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	result, err := a.SetDeployWebhook(hook)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(result)
+}
+
+// title: deploy webhook remove
+// path: /apps/{app}/deploy/webhook
+// method: DELETE
+// responses:
+//   200: Webhook removed
+//   401: Unauthorized
+//   404: App or webhook not found
+//   409: Deploys frozen
+func deployWebhookRemove(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppUpdateDeployWebhook,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = a.RemoveDeployWebhook()
+	if err == app.ErrDeployWebhookNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
+
+// title: deploy webhook
+// path: /apps/{app}/deploy/webhook
+// method: POST
+// consume: application/json
+// responses:
+//   200: Push ignored
+//   202: Deploy triggered
+//   400: Invalid payload
+//   401: Invalid signature or replayed delivery
+//   404: App or webhook not found
+//   409: Deploys frozen
+func deployWebhook(w http.ResponseWriter, r *http.Request) error {
+	appName := r.URL.Query().Get(":app")
+	a, err := app.GetByName(appName)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if a.DeployWebhook == nil {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: app.ErrDeployWebhookNotFound.Error()}
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookPayloadSize))
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	push, err := parseWebhookPush(r.Header, body, a.DeployWebhook.Secret)
+	if err != nil {
+		return err
+	}
+	deploy := push != nil && push.Branch == a.DeployWebhook.Branch
+	if deploy {
+		err = checkDeployFreeze(nil, a, false, w)
+		if err != nil {
+			return err
+		}
+	}
+	// Deliveries are only recorded once accepted, so the ones rejected, like
+	// during deploy freezes, may be redelivered.
+	nonceScope, nonce := "webhook/"+a.Name, webhookDeliveryNonce(body)
+	err = useNonce(nonceScope, nonce, webhookDeliveryRetention)
+	if err != nil {
+		return err
+	}
+	if !deploy {
+		fmt.Fprintln(w, "push ignored")
+		return nil
+	}
+	opts := app.DeployOptions{
+		App:        a,
+		ArchiveURL: push.ArchiveURL,
+		User:       push.Pusher,
+		Origin:     push.Provider + "-webhook",
+		Message:    push.Message,
+	}
+	opts.GetKind()
+	evt, err := event.New(&event.Opts{
+		Target:        appTarget(a.Name),
+		Kind:          permission.PermAppDeploy,
+		RawOwner:      event.Owner{Type: event.OwnerTypeInternal, Name: opts.Origin},
+		CustomData:    opts,
+		Allowed:       event.Allowed(permission.PermAppReadEvents, contextsForApp(a)...),
+		AllowedCancel: event.Allowed(permission.PermAppUpdateEvents, contextsForApp(a)...),
+		Cancelable:    true,
+	})
+	if err != nil {
+		releaseNonce(nonceScope, nonce)
+		return err
+	}
+	go runWebhookDeploy(evt, opts, push.Commit)
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, "deploy of %s triggered\n", push.Commit)
+	return nil
+}
+
+func runWebhookDeploy(evt *event.Event, opts app.DeployOptions, commit string) {
+	var err error
+	var imageID string
+	defer func() { evt.DoneCustomData(err, map[string]string{"image": imageID, "commit": commit}) }()
+	opts.Event = evt
+	opts.OutputStream = ioutil.Discard
+	err = waitDeployApproval(context.Background(), opts, evt)
+	if err != nil {
+		logger.Errorf("[deploy-webhook] unable to deploy app %q: %s", opts.App.Name, err)
+		return
+	}
+	done, err := waitDeployTurnContext(context.Background(), opts.App.Name, opts.Origin, "deploy webhook", evt)
+	if err != nil {
+		logger.Errorf("[deploy-webhook] unable to deploy app %q: %s", opts.App.Name, err)
+		return
+	}
+	defer done()
+	imageID, err = app.Deploy(opts)
+	if err != nil {
+		logger.Errorf("[deploy-webhook] unable to deploy app %q: %s", opts.App.Name, err)
+	}
+}
+
+// parseWebhookPush validates the signature of a webhook payload and decodes
+// it. A nil push is returned for valid notifications that must not trigger
+// deploys, like pings and branch removals.
+func parseWebhookPush(header http.Header, body []byte, secret string) (*webhookPush, error) {
+	switch {
+	case header.Get("X-GitHub-Event") != "":
+		return parseGitHubPush(header, body, secret)
+	case header.Get("X-Gitlab-Event") != "":
+		return parseGitLabPush(header, body, secret)
+	}
+	return nil, &errors.HTTP{Code: http.StatusBadRequest, Message: "unknown webhook provider"}
+}
+
+func parseGitHubPush(header http.Header, body []byte, secret string) (*webhookPush, error) {
+	if !validGitHubSignature(header, body, secret) {
+		return nil, errInvalidWebhookSignature
+	}
+	if header.Get("X-GitHub-Event") != "push" {
+		return nil, nil
+	}
+	var payload struct {
+		Ref        string
+		After      string
+		Deleted    bool
+		HeadCommit struct {
+			Message string
+		} `json:"head_commit"`
+		Pusher struct {
+			Name string
+		}
+		Repository struct {
+			HTMLURL string `json:"html_url"`
+		}
+	}
+	err := json.Unmarshal(body, &payload)
+	if err != nil {
+		return nil, &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	if payload.Deleted || !strings.HasPrefix(payload.Ref, "refs/heads/") {
+		return nil, nil
+	}
+	return &webhookPush{
+		Provider:   "github",
+		Branch:     strings.TrimPrefix(payload.Ref, "refs/heads/"),
+		Commit:     payload.After,
+		Message:    payload.HeadCommit.Message,
+		Pusher:     payload.Pusher.Name,
+		ArchiveURL: fmt.Sprintf("%s/archive/%s.tar.gz", payload.Repository.HTMLURL, payload.After),
+	}, nil
+}
+
+func validGitHubSignature(header http.Header, body []byte, secret string) bool {
+	if sig := header.Get("X-Hub-Signature-256"); sig != "" {
+		return validHMAC(sha256.New, "sha256=", sig, body, secret)
+	}
+	return validHMAC(sha1.New, "sha1=", header.Get("X-Hub-Signature"), body, secret)
+}
+
+func validHMAC(h func() hash.Hash, prefix, signature string, body []byte, secret string) bool {
+	if !strings.HasPrefix(signature, prefix) {
+		return false
+	}
+	received, err := hex.DecodeString(strings.TrimPrefix(signature, prefix))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(h, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(received, mac.Sum(nil))
+}
+
+// parseGitLabPush authenticates GitLab deliveries by the secret token sent
+// in the X-Gitlab-Token header. GitLab neither signs the payload nor sends a
+// signed timestamp, so there's no age window for these deliveries: replays
+// are only rejected by the nonce derived from the payload.
+func parseGitLabPush(header http.Header, body []byte, secret string) (*webhookPush, error) {
+	token := header.Get("X-Gitlab-Token")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+		return nil, errInvalidWebhookSignature
+	}
+	if header.Get("X-Gitlab-Event") != "Push Hook" {
+		return nil, nil
+	}
+	var payload struct {
+		Ref          string
+		CheckoutSHA  string `json:"checkout_sha"`
+		UserUsername string `json:"user_username"`
+		Project      struct {
+			WebURL string `json:"web_url"`
+		}
+		Commits []struct {
+			ID      string
+			Message string
+		}
+	}
+	err := json.Unmarshal(body, &payload)
+	if err != nil {
+		return nil, &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	if payload.CheckoutSHA == "" || !strings.HasPrefix(payload.Ref, "refs/heads/") {
+		return nil, nil
+	}
+	push := &webhookPush{
+		Provider:   "gitlab",
+		Branch:     strings.TrimPrefix(payload.Ref, "refs/heads/"),
+		Commit:     payload.CheckoutSHA,
+		Pusher:     payload.UserUsername,
+		ArchiveURL: fmt.Sprintf("%s/repository/archive.tar.gz?sha=%s", payload.Project.WebURL, payload.CheckoutSHA),
+	}
+	for _, commit := range payload.Commits {
+		if commit.ID == payload.CheckoutSHA {
+			push.Message = commit.Message
+		}
+	}
+	return push, nil
+}
@@ -0,0 +1,308 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+const gitHubPushPayload = `{
+	"ref": "refs/heads/master",
+	"after": "0d1a26e67d8f5eaf1f6ba5c57fc3c7d91ac0fd1c",
+	"head_commit": {"message": "fix login"},
+	"pusher": {"name": "octocat"},
+	"repository": {"html_url": "https://github.com/octocat/hello"}
+}`
+
+func gitHubSignature(body, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *DeploySuite) TestParseGitHubPush(c *check.C) {
+	header := http.Header{}
+	header.Set("X-GitHub-Event", "push")
+	header.Set("X-Hub-Signature-256", gitHubSignature(gitHubPushPayload, "s3cr3t"))
+	push, err := parseWebhookPush(header, []byte(gitHubPushPayload), "s3cr3t")
+	c.Assert(err, check.IsNil)
+	c.Assert(push, check.DeepEquals, &webhookPush{
+		Provider:   "github",
+		Branch:     "master",
+		Commit:     "0d1a26e67d8f5eaf1f6ba5c57fc3c7d91ac0fd1c",
+		Message:    "fix login",
+		Pusher:     "octocat",
+		ArchiveURL: "https://github.com/octocat/hello/archive/0d1a26e67d8f5eaf1f6ba5c57fc3c7d91ac0fd1c.tar.gz",
+	})
+	_, err = parseWebhookPush(header, []byte(gitHubPushPayload), "other")
+	c.Assert(err, check.Equals, errInvalidWebhookSignature)
+	header.Set("X-GitHub-Event", "ping")
+	push, err = parseWebhookPush(header, []byte(gitHubPushPayload), "s3cr3t")
+	c.Assert(err, check.IsNil)
+	c.Assert(push, check.IsNil)
+}
+
+func (s *DeploySuite) TestParseGitLabPush(c *check.C) {
+	body := `{
+		"ref": "refs/heads/production",
+		"checkout_sha": "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+		"user_username": "jsmith",
+		"project": {"web_url": "https://gitlab.com/jsmith/hello"},
+		"commits": [
+			{"id": "b6568db1bc1dcd7f8b4d5a946b0b91f9dacd7327", "message": "first"},
+			{"id": "da1560886d4f094c3e6c9ef40349f7d38b5d27d7", "message": "second"}
+		]
+	}`
+	header := http.Header{}
+	header.Set("X-Gitlab-Event", "Push Hook")
+	header.Set("X-Gitlab-Token", "s3cr3t")
+	push, err := parseWebhookPush(header, []byte(body), "s3cr3t")
+	c.Assert(err, check.IsNil)
+	c.Assert(push, check.DeepEquals, &webhookPush{
+		Provider:   "gitlab",
+		Branch:     "production",
+		Commit:     "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+		Message:    "second",
+		Pusher:     "jsmith",
+		ArchiveURL: "https://gitlab.com/jsmith/hello/repository/archive.tar.gz?sha=da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+	})
+	header.Set("X-Gitlab-Token", "other")
+	_, err = parseWebhookPush(header, []byte(body), "s3cr3t")
+	c.Assert(err, check.Equals, errInvalidWebhookSignature)
+}
+
+func (s *DeploySuite) TestParseWebhookPushUnknownProvider(c *check.C) {
+	_, err := parseWebhookPush(http.Header{}, []byte("{}"), "s3cr3t")
+	c.Assert(err, check.ErrorMatches, "unknown webhook provider")
+}
+
+func (s *DeploySuite) TestDeployWebhookSet(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateDeployWebhook,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	request, err := http.NewRequest("PUT", "/1.6/apps/"+a.Name+"/deploy/webhook", strings.NewReader("branch=production"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var hook app.DeployWebhook
+	err = json.NewDecoder(recorder.Body).Decode(&hook)
+	c.Assert(err, check.IsNil)
+	c.Assert(hook.Branch, check.Equals, "production")
+	c.Assert(hook.Secret, check.HasLen, 40)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.DeployWebhook, check.DeepEquals, &hook)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  token.GetUserName(),
+		Kind:   "app.update.deploy.webhook",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":app", "value": a.Name},
+			{"name": "branch", "value": "production"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *DeploySuite) TestDeployWebhookRemove(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	_, err = a.SetDeployWebhook(app.DeployWebhook{})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateDeployWebhook,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	request, err := http.NewRequest("DELETE", "/1.6/apps/"+a.Name+"/deploy/webhook", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.DeployWebhook, check.IsNil)
+	recorder = httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *DeploySuite) TestDeployWebhookSetForbidden(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppDeploy,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	request, err := http.NewRequest("PUT", "/1.6/apps/"+a.Name+"/deploy/webhook", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *DeploySuite) TestDeployWebhookTriggersDeploy(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	_, err = a.SetDeployWebhook(app.DeployWebhook{Secret: "s3cr3t"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/1.6/apps/"+a.Name+"/deploy/webhook", bytes.NewBufferString(gitHubPushPayload))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-GitHub-Event", "push")
+	request.Header.Set("X-Hub-Signature-256", gitHubSignature(gitHubPushPayload, "s3cr3t"))
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusAccepted)
+	timeout := time.After(5 * time.Second)
+	for {
+		evts, err := event.List(&event.Filter{Target: appTarget(a.Name), KindNames: []string{"app.deploy"}})
+		c.Assert(err, check.IsNil)
+		if len(evts) == 1 && !evts[0].Running {
+			c.Assert(evts[0].Error, check.Equals, "")
+			c.Assert(evts[0].Owner.Name, check.Equals, "github-webhook")
+			break
+		}
+		select {
+		case <-timeout:
+			c.Fatal("timeout waiting for webhook deploy")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Deploys, check.Equals, uint(1))
+}
+
+func (s *DeploySuite) TestDeployWebhookIgnoresOtherBranches(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	_, err = a.SetDeployWebhook(app.DeployWebhook{Secret: "s3cr3t", Branch: "production"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/1.6/apps/"+a.Name+"/deploy/webhook", bytes.NewBufferString(gitHubPushPayload))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("X-GitHub-Event", "push")
+	request.Header.Set("X-Hub-Signature-256", gitHubSignature(gitHubPushPayload, "s3cr3t"))
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), check.Equals, "push ignored\n")
+}
+
+func (s *DeploySuite) TestDeployWebhookInvalidSignature(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	_, err = a.SetDeployWebhook(app.DeployWebhook{Secret: "s3cr3t"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/1.6/apps/"+a.Name+"/deploy/webhook", bytes.NewBufferString(gitHubPushPayload))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("X-GitHub-Event", "push")
+	request.Header.Set("X-Hub-Signature-256", gitHubSignature(gitHubPushPayload, "wrong"))
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusUnauthorized)
+}
+
+func (s *DeploySuite) TestDeployWebhookReplayedDelivery(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	_, err = a.SetDeployWebhook(app.DeployWebhook{Secret: "s3cr3t", Branch: "production"})
+	c.Assert(err, check.IsNil)
+	for _, expected := range []int{http.StatusOK, http.StatusUnauthorized} {
+		request, err := http.NewRequest("POST", "/1.6/apps/"+a.Name+"/deploy/webhook", bytes.NewBufferString(gitHubPushPayload))
+		c.Assert(err, check.IsNil)
+		request.Header.Set("X-GitHub-Event", "push")
+		request.Header.Set("X-GitHub-Delivery", fmt.Sprintf("delivery-%d", expected))
+		request.Header.Set("X-Hub-Signature-256", gitHubSignature(gitHubPushPayload, "s3cr3t"))
+		recorder := httptest.NewRecorder()
+		s.testServer.ServeHTTP(recorder, request)
+		c.Assert(recorder.Code, check.Equals, expected)
+	}
+}
+
+func (s *DeploySuite) TestDeployWebhookRedeliveredAfterFreeze(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	_, err = a.SetDeployWebhook(app.DeployWebhook{Secret: "s3cr3t"})
+	c.Assert(err, check.IsNil)
+	err = app.SaveDeployFreeze(app.DeployFreeze{Team: s.team.Name, Windows: []app.FreezeWindow{currentFreezeWindow()}})
+	c.Assert(err, check.IsNil)
+	for _, expected := range []int{http.StatusConflict, http.StatusAccepted} {
+		request, err := http.NewRequest("POST", "/1.6/apps/"+a.Name+"/deploy/webhook", bytes.NewBufferString(gitHubPushPayload))
+		c.Assert(err, check.IsNil)
+		request.Header.Set("X-GitHub-Event", "push")
+		request.Header.Set("X-Hub-Signature-256", gitHubSignature(gitHubPushPayload, "s3cr3t"))
+		recorder := httptest.NewRecorder()
+		s.testServer.ServeHTTP(recorder, request)
+		c.Assert(recorder.Code, check.Equals, expected)
+		if expected == http.StatusConflict {
+			err = app.RemoveDeployFreeze(s.team.Name)
+			c.Assert(err, check.IsNil)
+		}
+	}
+	timeout := time.After(5 * time.Second)
+	for {
+		evts, err := event.List(&event.Filter{Target: appTarget(a.Name), KindNames: []string{"app.deploy"}})
+		c.Assert(err, check.IsNil)
+		if len(evts) == 1 && !evts[0].Running {
+			break
+		}
+		select {
+		case <-timeout:
+			c.Fatal("timeout waiting for webhook deploy")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func (s *DeploySuite) TestDeployWebhookNotConfigured(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/1.6/apps/"+a.Name+"/deploy/webhook", bytes.NewBufferString(gitHubPushPayload))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("X-GitHub-Event", "push")
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
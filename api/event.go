@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/ajg/form"
 	"github.com/tsuru/tsuru/auth"
@@ -18,6 +19,8 @@ import (
 	"gopkg.in/mgo.v2/bson"
 )
 
+var eventStreamInterval = time.Second
+
 // title: event list
 // path: /events
 // method: GET
@@ -26,9 +29,100 @@ import (
 //   200: OK
 //   204: No content
 func eventList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	filter, err := eventFilterFromRequest(r, t)
+	if err != nil {
+		return err
+	}
+	events, err := event.List(filter)
+	if err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Add("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(events)
+}
+
+// title: event stream
+// path: /events/stream
+// method: GET
+// produce: application/json
+// responses:
+//   101: Switching protocols
+//   400: Invalid filters
+//   401: Unauthorized
+func eventStream(conn *wsConn, r *http.Request, t auth.Token) error {
+	filter, err := eventFilterFromRequest(r, t)
+	if err != nil {
+		return err
+	}
+	if filter.Since.IsZero() {
+		filter.Since = time.Now().UTC()
+	}
+	filter.Sort = "starttime"
+	go conn.CloseOnClientEOF()
+	// running holds the events sent while still running, they are sent
+	// again once finished.
+	running := map[bson.ObjectId]struct{}{}
+	sent := map[bson.ObjectId]struct{}{}
+	ticker := time.NewTicker(eventStreamInterval)
+	defer ticker.Stop()
+	for {
+		events, err := event.List(filter)
+		if err != nil {
+			return err
+		}
+		lastSent := sent
+		sent = map[bson.ObjectId]struct{}{}
+		for i := range events {
+			evt := &events[i]
+			if evt.StartTime.After(filter.Since) {
+				filter.Since = evt.StartTime
+				lastSent = nil
+			}
+			if evt.StartTime.Equal(filter.Since) {
+				sent[evt.UniqueID] = struct{}{}
+			}
+			if _, ok := lastSent[evt.UniqueID]; ok {
+				continue
+			}
+			if _, ok := running[evt.UniqueID]; ok {
+				continue
+			}
+			if err = conn.SendJSON(evt); err != nil {
+				return nil
+			}
+			if evt.Running {
+				running[evt.UniqueID] = struct{}{}
+			}
+		}
+		for id := range running {
+			evt, err := event.GetByID(id)
+			if err != nil {
+				return err
+			}
+			if evt.Running {
+				continue
+			}
+			delete(running, id)
+			if err = conn.SendJSON(evt); err != nil {
+				return nil
+			}
+		}
+		select {
+		case <-conn.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func eventFilterFromRequest(r *http.Request, t auth.Token) (*event.Filter, error) {
 	err := r.ParseForm()
 	if err != nil {
-		return &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("unable to parse event filters: %s", err)}
+		return nil, &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("unable to parse event filters: %s", err)}
 	}
 	var filter *event.Filter
 	dec := form.NewDecoder(nil)
@@ -36,24 +130,15 @@ func eventList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	dec.IgnoreCase(true)
 	err = dec.DecodeValues(&filter, r.Form)
 	if err != nil {
-		return &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("unable to parse event filters: %s", err)}
+		return nil, &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("unable to parse event filters: %s", err)}
 	}
 	filter.LoadKindNames(r.Form)
 	filter.PruneUserValues()
 	filter.Permissions, err = t.Permissions()
 	if err != nil {
-		return err
-	}
-	events, err := event.List(filter)
-	if err != nil {
-		return err
+		return nil, err
 	}
-	if len(events) == 0 {
-		w.WriteHeader(http.StatusNoContent)
-		return nil
-	}
-	w.Header().Add("Content-Type", "application/json")
-	return json.NewEncoder(w).Encode(events)
+	return filter, nil
 }
 
 // title: kind list
@@ -261,3 +346,34 @@ func eventBlockRemove(w http.ResponseWriter, r *http.Request, t auth.Token) (err
 	}
 	return err
 }
+
+// title: prune events
+// path: /events/prune
+// method: POST
+// produce: application/json
+// responses:
+//   200: OK
+//   401: Unauthorized
+func eventPrune(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermEventRetentionPrune) {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:      event.Target{Type: event.TargetTypeGlobal},
+		Kind:        permission.PermEventRetentionPrune,
+		Owner:       t,
+		DisableLock: true,
+		Allowed:     event.Allowed(permission.PermEventRetentionReadEvents),
+	})
+	if err != nil {
+		return err
+	}
+	var result *event.PruneResult
+	defer func() { evt.DoneCustomData(err, result) }()
+	result, err = event.Prune(time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(result)
+}
@@ -9,7 +9,9 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 
@@ -28,6 +30,7 @@ import (
 	_ "github.com/tsuru/tsuru/storage/mongodb"
 	appTypes "github.com/tsuru/tsuru/types/app"
 	authTypes "github.com/tsuru/tsuru/types/auth"
+	"golang.org/x/net/websocket"
 	"gopkg.in/check.v1"
 	"gopkg.in/mgo.v2/bson"
 )
@@ -206,6 +209,41 @@ func (s *EventSuite) TestEventListFilterRunning(c *check.C) {
 	c.Assert(result, check.HasLen, 1)
 }
 
+func (s *EventSuite) TestEventStream(c *check.C) {
+	evts, err := s.insertEvents("app", nil, c)
+	c.Assert(err, check.IsNil)
+	since := evts[len(evts)-1].StartTime.Add(time.Millisecond)
+	srv := httptest.NewServer(RunServer(true))
+	defer srv.Close()
+	testServerURL, err := url.Parse(srv.URL)
+	c.Assert(err, check.IsNil)
+	wsURL := fmt.Sprintf("ws://%s/events/stream?target.type=app&since=%s", testServerURL.Host, url.QueryEscape(since.Format(time.RFC3339Nano)))
+	config, err := websocket.NewConfig(wsURL, "ws://localhost/")
+	c.Assert(err, check.IsNil)
+	config.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	wsConn, err := websocket.DialConfig(config)
+	c.Assert(err, check.IsNil)
+	defer wsConn.Close()
+	evt, err := event.New(&event.Opts{
+		Target:  event.Target{Type: event.TargetTypeApp, Value: "new-app"},
+		Owner:   s.token,
+		Kind:    permission.PermAppDeploy,
+		Allowed: event.Allowed(permission.PermAppReadEvents, permission.Context(permission.CtxTeam, s.team.Name)),
+	})
+	c.Assert(err, check.IsNil)
+	var result event.Event
+	err = websocket.JSON.Receive(wsConn, &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.UniqueID, check.Equals, evt.UniqueID)
+	c.Assert(result.Running, check.Equals, true)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	err = websocket.JSON.Receive(wsConn, &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.UniqueID, check.Equals, evt.UniqueID)
+	c.Assert(result.Running, check.Equals, false)
+}
+
 func (s *EventSuite) TestEventListFilterByKinds(c *check.C) {
 	kinds := []*permission.PermissionScheme{permission.PermAppCreate, permission.PermAppDeploy}
 	_, err := s.insertEvents("app", kinds, c)
@@ -688,3 +726,47 @@ func addBlocks(c *check.C) []*event.Block {
 	}
 	return blocks
 }
+
+func (s *EventSuite) TestEventPrune(c *check.C) {
+	config.Set("event:retention:max-age", "24h")
+	defer config.Unset("event:retention")
+	_, err := s.insertEvents("app", nil, c)
+	c.Assert(err, check.IsNil)
+	_, err = s.conn.Events().UpdateAll(bson.M{"running": false}, bson.M{"$set": bson.M{"starttime": time.Now().UTC().Add(-48 * time.Hour)}})
+	c.Assert(err, check.IsNil)
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
+		Scheme:  permission.PermEventRetentionPrune,
+		Context: permission.PermissionContext{CtxType: permission.CtxGlobal},
+	})
+	request, err := http.NewRequest("POST", "/events/prune", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result event.PruneResult
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Removed, check.DeepEquals, map[string]int{"app.deploy": 1})
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeGlobal},
+		Owner:  token.GetUserName(),
+		Kind:   "event-retention.prune",
+	}, eventtest.HasEvent)
+}
+
+func (s *EventSuite) TestEventPruneUnauthorized(c *check.C) {
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "myuser", permission.Permission{
+		Scheme:  permission.PermEventBlockRead,
+		Context: permission.PermissionContext{CtxType: permission.CtxGlobal},
+	})
+	request, err := http.NewRequest("POST", "/events/prune", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
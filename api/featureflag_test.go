@@ -0,0 +1,131 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/ajg/form"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/featureflag"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestFeatureFlagList(c *check.C) {
+	err := featureflag.Save(featureflag.Flag{Name: "new-bind-flow", Teams: []string{"team1"}})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermFeatureFlagRead,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	request, err := http.NewRequest("GET", "/feature-flags", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var flags []featureflag.Flag
+	err = json.Unmarshal(recorder.Body.Bytes(), &flags)
+	c.Assert(err, check.IsNil)
+	c.Assert(flags, check.DeepEquals, []featureflag.Flag{{Name: "new-bind-flow", Teams: []string{"team1"}}})
+}
+
+func (s *S) TestFeatureFlagListEmpty(c *check.C) {
+	request, err := http.NewRequest("GET", "/feature-flags", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestFeatureFlagUpdate(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermFeatureFlagUpdate,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	values, err := form.EncodeToValues(featureflag.Flag{Teams: []string{"team1"}, Percentage: 20})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("PUT", "/feature-flags/new-bind-flow", strings.NewReader(values.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	flag, err := featureflag.Get("new-bind-flow")
+	c.Assert(err, check.IsNil)
+	c.Assert(*flag, check.DeepEquals, featureflag.Flag{Name: "new-bind-flow", Teams: []string{"team1"}, Percentage: 20})
+	c.Assert(featureflag.IsEnabled("new-bind-flow", "team1"), check.Equals, true)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeFeatureFlag, Value: "new-bind-flow"},
+		Owner:  token.GetUserName(),
+		Kind:   "feature-flag.update",
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestFeatureFlagUpdateInvalid(c *check.C) {
+	request, err := http.NewRequest("PUT", "/feature-flags/new-bind-flow", strings.NewReader("Percentage=200"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "percentage must be between 0 and 100\n")
+}
+
+func (s *S) TestFeatureFlagUpdateWithoutPermission(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermFeatureFlagRead,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	request, err := http.NewRequest("PUT", "/feature-flags/new-bind-flow", strings.NewReader("Enabled=true"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	_, err = featureflag.Get("new-bind-flow")
+	c.Assert(err, check.Equals, featureflag.ErrFlagNotFound)
+}
+
+func (s *S) TestFeatureFlagDelete(c *check.C) {
+	err := featureflag.Save(featureflag.Flag{Name: "new-bind-flow", Enabled: true})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermFeatureFlagDelete,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	request, err := http.NewRequest("DELETE", "/feature-flags/new-bind-flow", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	_, err = featureflag.Get("new-bind-flow")
+	c.Assert(err, check.Equals, featureflag.ErrFlagNotFound)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeFeatureFlag, Value: "new-bind-flow"},
+		Owner:  token.GetUserName(),
+		Kind:   "feature-flag.delete",
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestFeatureFlagDeleteNotFound(c *check.C) {
+	request, err := http.NewRequest("DELETE", "/feature-flags/unknown", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
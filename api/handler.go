@@ -5,7 +5,9 @@
 package api
 
 import (
+	"encoding/json"
 	"net/http"
+	"strconv"
 
 	"github.com/tsuru/tsuru/api/context"
 	"github.com/tsuru/tsuru/auth"
@@ -36,3 +38,15 @@ func (fn AuthorizationRequiredHandler) ServeHTTP(w http.ResponseWriter, r *http.
 		context.AddRequestError(r, fn(w, r, t))
 	}
 }
+
+// isDryRun reports whether the request asks for the actions of a destructive
+// operation to be listed instead of executed.
+func isDryRun(r *http.Request) bool {
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry-run"))
+	return dryRun
+}
+
+func writeDryRunActions(w http.ResponseWriter, actions []string) error {
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(actions)
+}
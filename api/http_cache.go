@@ -0,0 +1,40 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/tsuru/config"
+)
+
+// staticMaxAge returns for how long clients may use rarely changing
+// responses, like service docs, without revalidating them.
+func staticMaxAge() time.Duration {
+	seconds, err := config.GetInt("server:static-cache-max-age")
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// writeCacheable writes data along with Cache-Control, ETag and, when
+// modTime isn't zero, Last-Modified headers, answering conditional requests
+// matching them with 304. Responses are private as they may depend on the
+// permissions of the user.
+func writeCacheable(w http.ResponseWriter, r *http.Request, contentType string, modTime time.Time, data []byte) {
+	cacheControl := "private, no-cache"
+	if maxAge := staticMaxAge(); maxAge > 0 {
+		cacheControl = fmt.Sprintf("private, max-age=%d", int(maxAge.Seconds()))
+	}
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", fmt.Sprintf(`"%x"`, sha1.Sum(data)))
+	http.ServeContent(w, r, "", modTime, bytes.NewReader(data))
+}
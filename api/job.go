@@ -0,0 +1,256 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/ajg/form"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/app/job"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type inputJob struct {
+	Name     string
+	Schedule string
+	Command  string
+}
+
+// title: job list
+// path: /apps/{app}/jobs
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+//   404: App not found
+func jobList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	jobs, err := job.List(a.Name)
+	if err != nil {
+		return err
+	}
+	if len(jobs) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(jobs)
+}
+
+// title: job create
+// path: /apps/{app}/jobs
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/json
+// responses:
+//   201: Job created
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App not found
+//   409: Job already exists
+func jobCreate(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	err = r.ParseForm()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	var input inputJob
+	dec := form.NewDecoder(nil)
+	dec.IgnoreUnknownKeys(true)
+	dec.IgnoreCase(true)
+	err = dec.DecodeValues(&input, r.Form)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	appName := r.URL.Query().Get(":app")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppUpdateJobAdd,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	j := job.Job{
+		Name:     input.Name,
+		App:      a.Name,
+		Schedule: input.Schedule,
+		Command:  input.Command,
+	}
+	err = job.Create(&j)
+	if err == job.ErrJobAlreadyExists {
+		return &errors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(j)
+}
+
+// title: job remove
+// path: /apps/{app}/jobs/{job}
+// method: DELETE
+// responses:
+//   200: Job removed
+//   401: Unauthorized
+//   404: App or job not found
+func jobRemove(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	appName := r.URL.Query().Get(":app")
+	jobName := r.URL.Query().Get(":job")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppUpdateJobRemove,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = job.Remove(a.Name, jobName)
+	if err == job.ErrJobNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
+
+// title: job run
+// path: /apps/{app}/jobs/{job}/run
+// method: POST
+// produce: application/json
+// responses:
+//   202: Job run enqueued
+//   401: Unauthorized
+//   404: App or job not found
+func jobRun(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	appName := r.URL.Query().Get(":app")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return err
+	}
+	j, err := getJob(&a, r.URL.Query().Get(":job"))
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppUpdateJobRun,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	run, err := job.Trigger(j, job.TriggerManual, t.GetUserName())
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	return json.NewEncoder(w).Encode(run)
+}
+
+// title: job run list
+// path: /apps/{app}/jobs/{job}/runs
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   400: Invalid limit
+//   401: Unauthorized
+//   404: App or job not found
+func jobRunList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	var limit int
+	if l := r.URL.Query().Get("limit"); l != "" {
+		var err error
+		limit, err = strconv.Atoi(l)
+		if err != nil {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: `Parameter "limit" must be an integer.`}
+		}
+	}
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	j, err := getJob(&a, r.URL.Query().Get(":job"))
+	if err != nil {
+		return err
+	}
+	runs, err := job.Runs(j.App, j.Name, limit)
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(runs)
+}
+
+// title: job run info
+// path: /apps/{app}/jobs/{job}/runs/{id}
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   400: Invalid id
+//   401: Unauthorized
+//   404: App, job or run not found
+func jobRunInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	id := r.URL.Query().Get(":id")
+	if !bson.IsObjectIdHex(id) {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid job run id"}
+	}
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	run, err := job.GetRun(a.Name, r.URL.Query().Get(":job"), bson.ObjectIdHex(id))
+	if err == job.ErrRunNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(run)
+}
+
+func getJob(a *app.App, name string) (*job.Job, error) {
+	j, err := job.Get(a.Name, name)
+	if err == job.ErrJobNotFound {
+		return nil, &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return j, err
+}
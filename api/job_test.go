@@ -0,0 +1,212 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/app/job"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) createJobApp(c *check.C) *app.App {
+	a := app.App{Name: "myjobapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	return &a
+}
+
+func (s *S) TestJobCreate(c *check.C) {
+	a := s.createJobApp(c)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateJobAdd,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	body := strings.NewReader("name=backup&schedule=0+3+*+*+*&command=./backup.sh")
+	request, err := http.NewRequest("POST", "/1.6/apps/"+a.Name+"/jobs", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated, check.Commentf("body: %q", recorder.Body.String()))
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result job.Job
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Name, check.Equals, "backup")
+	c.Assert(result.NextRun.Hour(), check.Equals, 3)
+	dbJob, err := job.Get(a.Name, "backup")
+	c.Assert(err, check.IsNil)
+	c.Assert(dbJob.Command, check.Equals, "./backup.sh")
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  token.GetUserName(),
+		Kind:   "app.update.job.add",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":app", "value": a.Name},
+			{"name": "name", "value": "backup"},
+			{"name": "schedule", "value": "0 3 * * *"},
+			{"name": "command", "value": "./backup.sh"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestJobCreateInvalid(c *check.C) {
+	a := s.createJobApp(c)
+	body := strings.NewReader("name=backup&schedule=invalid&command=./backup.sh")
+	request, err := http.NewRequest("POST", "/1.6/apps/"+a.Name+"/jobs", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+}
+
+func (s *S) TestJobCreateAlreadyExists(c *check.C) {
+	a := s.createJobApp(c)
+	err := job.Create(&job.Job{Name: "backup", App: a.Name, Schedule: "@daily", Command: "ls"})
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("name=backup&schedule=@daily&command=ls")
+	request, err := http.NewRequest("POST", "/1.6/apps/"+a.Name+"/jobs", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+}
+
+func (s *S) TestJobCreateForbidden(c *check.C) {
+	a := s.createJobApp(c)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadJob,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	body := strings.NewReader("name=backup&schedule=@daily&command=ls")
+	request, err := http.NewRequest("POST", "/1.6/apps/"+a.Name+"/jobs", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestJobList(c *check.C) {
+	a := s.createJobApp(c)
+	err := job.Create(&job.Job{Name: "backup", App: a.Name, Schedule: "@daily", Command: "ls"})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadJob,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	request, err := http.NewRequest("GET", "/1.6/apps/"+a.Name+"/jobs", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var result []job.Job
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.HasLen, 1)
+	c.Assert(result[0].Name, check.Equals, "backup")
+}
+
+func (s *S) TestJobListEmpty(c *check.C) {
+	a := s.createJobApp(c)
+	request, err := http.NewRequest("GET", "/1.6/apps/"+a.Name+"/jobs", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestJobRemove(c *check.C) {
+	a := s.createJobApp(c)
+	err := job.Create(&job.Job{Name: "backup", App: a.Name, Schedule: "@daily", Command: "ls"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", "/1.6/apps/"+a.Name+"/jobs/backup", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	_, err = job.Get(a.Name, "backup")
+	c.Assert(err, check.Equals, job.ErrJobNotFound)
+	recorder = httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestJobRunAndRuns(c *check.C) {
+	a := s.createJobApp(c)
+	err := job.Create(&job.Job{Name: "backup", App: a.Name, Schedule: "@daily", Command: "ls"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/1.6/apps/"+a.Name+"/jobs/backup/run", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusAccepted)
+	var run job.Run
+	err = json.Unmarshal(recorder.Body.Bytes(), &run)
+	c.Assert(err, check.IsNil)
+	c.Assert(run.Trigger, check.Equals, job.TriggerManual)
+	c.Assert(run.Owner, check.Equals, s.token.GetUserName())
+	request, err = http.NewRequest("GET", "/1.6/apps/"+a.Name+"/jobs/backup/runs", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var runs []job.Run
+	err = json.Unmarshal(recorder.Body.Bytes(), &runs)
+	c.Assert(err, check.IsNil)
+	c.Assert(runs, check.HasLen, 1)
+	c.Assert(runs[0].ID, check.Equals, run.ID)
+	request, err = http.NewRequest("GET", "/1.6/apps/"+a.Name+"/jobs/backup/runs/"+run.ID.Hex(), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+}
+
+func (s *S) TestJobRunNotFound(c *check.C) {
+	a := s.createJobApp(c)
+	request, err := http.NewRequest("POST", "/1.6/apps/"+a.Name+"/jobs/backup/run", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestJobRunInfoNotFound(c *check.C) {
+	a := s.createJobApp(c)
+	request, err := http.NewRequest("GET", "/1.6/apps/"+a.Name+"/jobs/backup/runs/"+bson.NewObjectId().Hex(), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	request, err = http.NewRequest("GET", "/1.6/apps/"+a.Name+"/jobs/backup/runs/invalid", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+}
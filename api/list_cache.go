@@ -0,0 +1,164 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/event"
+)
+
+var (
+	appListCache = newListCache(
+		event.TargetTypeGlobal,
+		event.TargetTypeApp,
+		event.TargetTypeContainer,
+		event.TargetTypeNode,
+		event.TargetTypePool,
+		event.TargetTypeProject,
+		event.TargetTypeRole,
+		event.TargetTypeTeam,
+		event.TargetTypeUser,
+	)
+	serviceCatalogCache = newListCache(
+		event.TargetTypeGlobal,
+		event.TargetTypeService,
+		event.TargetTypeRole,
+		event.TargetTypeTeam,
+		event.TargetTypeUser,
+	)
+)
+
+func init() {
+	event.OnDone(func(evt *event.Event) {
+		appListCache.invalidate(evt.Target.Type)
+		serviceCatalogCache.invalidate(evt.Target.Type)
+	})
+}
+
+// listCacheTTL returns for how long responses of hot list endpoints are
+// cached, zero meaning they're not cached.
+func listCacheTTL() time.Duration {
+	seconds, err := config.GetInt("server:list-cache-ttl")
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+type listCacheEntry struct {
+	data    []byte
+	expires time.Time
+}
+
+// listCache keeps the responses of a list endpoint in memory, per user and
+// query, for up to listCacheTTL. Entries are dropped whenever an event with
+// one of the target types the list depends on finishes in this API
+// instance; the TTL bounds the staleness caused by changes made through
+// other instances or outside of events.
+type listCache struct {
+	mu          sync.Mutex
+	targetTypes map[event.TargetType]bool
+	entries     map[string]listCacheEntry
+	generation  uint64
+}
+
+func newListCache(targetTypes ...event.TargetType) *listCache {
+	c := &listCache{
+		targetTypes: make(map[event.TargetType]bool, len(targetTypes)),
+		entries:     make(map[string]listCacheEntry),
+	}
+	for _, t := range targetTypes {
+		c.targetTypes[t] = true
+	}
+	return c
+}
+
+func listCacheKey(t auth.Token, r *http.Request) string {
+	return t.GetUserName() + "?" + r.URL.Query().Encode()
+}
+
+// get returns the cached response for key and the current generation of the
+// cache, which must be given to set.
+func (c *listCache) get(key string) ([]byte, bool, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if ok && time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		ok = false
+	}
+	return entry.data, ok, c.generation
+}
+
+// set caches the response for key, unless the cache was invalidated after
+// generation was read, which means the response may already be stale.
+func (c *listCache) set(key string, data []byte, generation uint64) {
+	ttl := listCacheTTL()
+	if ttl == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.generation {
+		return
+	}
+	c.entries[key] = listCacheEntry{data: data, expires: time.Now().Add(ttl)}
+}
+
+func (c *listCache) invalidate(targetType event.TargetType) {
+	if !c.targetTypes[targetType] {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	c.entries = make(map[string]listCacheEntry)
+}
+
+// serve writes the cached response for key, returning false when there's
+// none. Cached empty responses are written as 204.
+func (c *listCache) serve(w http.ResponseWriter, key string) (bool, uint64) {
+	if listCacheTTL() == 0 {
+		return false, 0
+	}
+	data, ok, generation := c.get(key)
+	if !ok {
+		return false, generation
+	}
+	writeListResponse(w, data)
+	return true, generation
+}
+
+// store caches and writes the list response v. Nil values are written as
+// 204.
+func (c *listCache) store(w http.ResponseWriter, key string, generation uint64, v interface{}) error {
+	var data []byte
+	if v != nil {
+		var err error
+		data, err = json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		data = append(data, '\n')
+	}
+	c.set(key, data, generation)
+	writeListResponse(w, data)
+	return nil
+}
+
+func writeListResponse(w http.ResponseWriter, data []byte) {
+	if data == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
@@ -0,0 +1,82 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) listApps(c *check.C) []app.App {
+	request, err := http.NewRequest("GET", "/apps", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var apps []app.App
+	err = json.NewDecoder(recorder.Body).Decode(&apps)
+	c.Assert(err, check.IsNil)
+	return apps
+}
+
+func (s *S) TestAppListCachedInvalidatedByEvents(c *check.C) {
+	config.Set("server:list-cache-ttl", 60)
+	defer config.Unset("server:list-cache-ttl")
+	defer appListCache.invalidate(event.TargetTypeApp)
+	err := app.CreateApp(&app.App{Name: "app1", Platform: "zend", TeamOwner: s.team.Name}, s.user)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.listApps(c), check.HasLen, 1)
+	err = app.CreateApp(&app.App{Name: "app2", Platform: "zend", TeamOwner: s.team.Name}, s.user)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.listApps(c), check.HasLen, 1)
+	evt, err := event.New(&event.Opts{
+		Target:  event.Target{Type: event.TargetTypeApp, Value: "app2"},
+		Kind:    permission.PermAppCreate,
+		Owner:   s.token,
+		Allowed: event.Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.listApps(c), check.HasLen, 2)
+}
+
+func (s *S) TestAppListNotCachedByDefault(c *check.C) {
+	err := app.CreateApp(&app.App{Name: "app1", Platform: "zend", TeamOwner: s.team.Name}, s.user)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.listApps(c), check.HasLen, 1)
+	err = app.CreateApp(&app.App{Name: "app2", Platform: "zend", TeamOwner: s.team.Name}, s.user)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.listApps(c), check.HasLen, 2)
+}
+
+func (s *S) TestListCacheInvalidate(c *check.C) {
+	config.Set("server:list-cache-ttl", 60)
+	defer config.Unset("server:list-cache-ttl")
+	cache := newListCache(event.TargetTypeService)
+	_, _, generation := cache.get("key")
+	cache.set("key", []byte("data"), generation)
+	data, ok, _ := cache.get("key")
+	c.Assert(ok, check.Equals, true)
+	c.Assert(string(data), check.Equals, "data")
+	cache.invalidate(event.TargetTypeApp)
+	_, ok, _ = cache.get("key")
+	c.Assert(ok, check.Equals, true)
+	cache.invalidate(event.TargetTypeService)
+	_, ok, _ = cache.get("key")
+	c.Assert(ok, check.Equals, false)
+	cache.set("key", []byte("stale"), generation)
+	_, ok, _ = cache.get("key")
+	c.Assert(ok, check.Equals, false)
+}
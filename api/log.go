@@ -7,14 +7,13 @@ package api
 import (
 	"encoding/json"
 	"io"
+	"net/http"
 	"sync"
 
 	"github.com/pkg/errors"
 	"github.com/tsuru/config"
-	"github.com/tsuru/tsuru/api/context"
 	"github.com/tsuru/tsuru/app"
-	"github.com/tsuru/tsuru/log"
-	"golang.org/x/net/websocket"
+	"github.com/tsuru/tsuru/auth"
 )
 
 var (
@@ -33,31 +32,11 @@ func getDispatcher() *app.LogDispatcher {
 	return globalDispatcher
 }
 
-func addLogs(ws *websocket.Conn) {
-	var err error
-	defer func() {
-		msg := &errMsg{}
-		if err != nil {
-			msg.Error = err.Error()
-			log.Errorf("failure in logs webservice: %s", err)
-		}
-		websocket.JSON.Send(ws, msg)
-		ws.Close()
-	}()
-	req := ws.Request()
-	t := context.GetAuthToken(req)
-	if t == nil {
-		err = errors.Errorf("wslogs: no token")
-		return
-	}
+func addLogs(conn *wsConn, r *http.Request, t auth.Token) error {
 	if t.GetAppName() != app.InternalAppName {
-		err = errors.Errorf("wslogs: invalid token app name: %q", t.GetAppName())
-		return
-	}
-	err = scanLogs(ws)
-	if err != nil {
-		return
+		return errors.Errorf("wslogs: invalid token app name: %q", t.GetAppName())
 	}
+	return scanLogs(conn)
 }
 
 func scanLogs(stream io.Reader) error {
@@ -0,0 +1,119 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+)
+
+// title: log drain list
+// path: /apps/{app}/log/drains
+// method: GET
+// produce: application/json
+// responses:
+//   200: List log drains
+//   204: No content
+//   401: Unauthorized
+//   404: App not found
+func logDrainList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	if len(a.LogDrains) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(a.LogDrains)
+}
+
+// title: log drain add
+// path: /apps/{app}/log/drains
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/json
+// responses:
+//   201: Log drain added
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App not found
+//   409: Log drain already exists
+func logDrainAdd(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	drainURL := r.FormValue("url")
+	if drainURL == "" {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "url is required"}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppUpdateLogDrainAdd,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	drain, err := a.AddLogDrain(drainURL)
+	if err != nil {
+		if errors.IsValidation(err) {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+		}
+		if err == app.ErrLogDrainAlreadyExists {
+			return &errors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+		}
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(drain)
+}
+
+// title: log drain remove
+// path: /apps/{app}/log/drains
+// method: DELETE
+// responses:
+//   200: Log drain removed
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App or log drain not found
+func logDrainRemove(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	drainURL := r.URL.Query().Get("url")
+	if drainURL == "" {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "url is required"}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppUpdateLogDrainRemove,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.URL.Query()),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = a.RemoveLogDrain(drainURL)
+	if err == app.ErrLogDrainNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
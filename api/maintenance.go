@@ -0,0 +1,193 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ajg/form"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/maintenance"
+	"github.com/tsuru/tsuru/permission"
+)
+
+// title: maintenance info
+// path: /maintenance
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   401: Unauthorized
+func maintenanceInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermMaintenanceRead) {
+		return permission.ErrUnauthorized
+	}
+	mode, err := maintenance.Get()
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(mode)
+}
+
+// title: enable maintenance
+// path: /maintenance
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// produce: application/json
+// responses:
+//   200: Maintenance mode enabled
+//   401: Unauthorized
+func maintenanceEnable(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermMaintenanceUpdate) {
+		return permission.ErrUnauthorized
+	}
+	r.ParseForm()
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeGlobal},
+		Kind:       permission.PermMaintenanceUpdate,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermMaintenanceReadEvents),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	mode, err := maintenance.Enable(r.FormValue("message"), t.GetUserName())
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(mode)
+}
+
+// title: disable maintenance
+// path: /maintenance
+// method: DELETE
+// responses:
+//   200: Maintenance mode disabled
+//   401: Unauthorized
+func maintenanceDisable(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermMaintenanceUpdate) {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeGlobal},
+		Kind:       permission.PermMaintenanceUpdate,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermMaintenanceReadEvents),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return maintenance.Disable()
+}
+
+// title: maintenance window list
+// path: /maintenance/windows
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+func maintenanceWindowList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermMaintenanceRead) {
+		return permission.ErrUnauthorized
+	}
+	windows, err := maintenance.ListWindows()
+	if err != nil {
+		return err
+	}
+	if len(windows) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(windows)
+}
+
+// title: maintenance window add
+// path: /maintenance/windows
+// method: POST
+// consume: application/x-www-form-urlencoded
+// responses:
+//   201: Maintenance window scheduled
+//   400: Invalid data
+//   401: Unauthorized
+//   409: Maintenance window already exists
+func maintenanceWindowAdd(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermMaintenanceUpdate) {
+		return permission.ErrUnauthorized
+	}
+	err = r.ParseForm()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	var window maintenance.Window
+	dec := form.NewDecoder(nil)
+	dec.IgnoreUnknownKeys(true)
+	dec.IgnoreCase(true)
+	err = dec.DecodeValues(&window, r.Form)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	window.Owner = t.GetUserName()
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeGlobal},
+		Kind:       permission.PermMaintenanceUpdate,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermMaintenanceReadEvents),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = maintenance.AddWindow(window)
+	if err == maintenance.ErrWindowAlreadyExists {
+		return &errors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusCreated)
+	return nil
+}
+
+// title: maintenance window remove
+// path: /maintenance/windows/{name}
+// method: DELETE
+// responses:
+//   200: Maintenance window removed
+//   401: Unauthorized
+//   404: Maintenance window not found
+func maintenanceWindowRemove(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermMaintenanceUpdate) {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeGlobal},
+		Kind:       permission.PermMaintenanceUpdate,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermMaintenanceReadEvents),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = maintenance.RemoveWindow(r.URL.Query().Get(":name"))
+	if err == maintenance.ErrWindowNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
@@ -0,0 +1,221 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/maintenance"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestMaintenanceEnable(c *check.C) {
+	defer maintenance.Disable()
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermMaintenanceUpdate,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	request, err := http.NewRequest("PUT", "/maintenance", strings.NewReader("message=upgrading+mongodb"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var mode maintenance.Mode
+	err = json.Unmarshal(recorder.Body.Bytes(), &mode)
+	c.Assert(err, check.IsNil)
+	c.Assert(mode.Enabled, check.Equals, true)
+	c.Assert(mode.Message, check.Equals, "upgrading mongodb")
+	c.Assert(mode.Owner, check.Equals, token.GetUserName())
+	c.Assert(maintenance.Current().Enabled, check.Equals, true)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeGlobal},
+		Owner:  token.GetUserName(),
+		Kind:   "maintenance.update",
+		StartCustomData: []map[string]interface{}{
+			{"name": "message", "value": "upgrading mongodb"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestMaintenanceEnableWithoutPermission(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermMaintenanceRead,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	request, err := http.NewRequest("PUT", "/maintenance", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	c.Assert(maintenance.Current().Enabled, check.Equals, false)
+}
+
+func (s *S) TestMaintenanceDisable(c *check.C) {
+	_, err := maintenance.Enable("upgrading mongodb", "admin@example.com")
+	c.Assert(err, check.IsNil)
+	defer maintenance.Disable()
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermMaintenanceUpdate,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	request, err := http.NewRequest("DELETE", "/maintenance", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(maintenance.Current().Enabled, check.Equals, false)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeGlobal},
+		Owner:  token.GetUserName(),
+		Kind:   "maintenance.update",
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestMaintenanceInfo(c *check.C) {
+	_, err := maintenance.Enable("upgrading mongodb", "admin@example.com")
+	c.Assert(err, check.IsNil)
+	defer maintenance.Disable()
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermMaintenanceRead,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	request, err := http.NewRequest("GET", "/maintenance", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var mode maintenance.Mode
+	err = json.Unmarshal(recorder.Body.Bytes(), &mode)
+	c.Assert(err, check.IsNil)
+	c.Assert(mode.Enabled, check.Equals, true)
+	c.Assert(mode.Message, check.Equals, "upgrading mongodb")
+	c.Assert(mode.Owner, check.Equals, "admin@example.com")
+}
+
+func (s *S) TestMaintenanceRejectsRequestsFromServer(c *check.C) {
+	_, err := maintenance.Enable("upgrading mongodb", "admin@example.com")
+	c.Assert(err, check.IsNil)
+	defer maintenance.Disable()
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermTeam,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	request, err := http.NewRequest("POST", "/teams", strings.NewReader("name=newteam"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusServiceUnavailable)
+	c.Assert(recorder.Body.String(), check.Equals, "upgrading mongodb\n")
+	request, err = http.NewRequest("GET", "/teams", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder = httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+}
+
+func (s *S) TestMaintenanceWindowAddAndList(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermMaintenanceUpdate,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	}, permission.Permission{
+		Scheme:  permission.PermMaintenanceRead,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	start := time.Now().UTC().Truncate(time.Second)
+	end := start.Add(2 * time.Hour)
+	values := url.Values{
+		"name":      {"black-friday"},
+		"pools.0":   {"pool1"},
+		"actions.0": {maintenance.ActionAutoscaleDown},
+		"start":     {start.Format(time.RFC3339)},
+		"end":       {end.Format(time.RFC3339)},
+		"reason":    {"change freeze"},
+	}
+	request, err := http.NewRequest("POST", "/maintenance/windows", strings.NewReader(values.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeGlobal},
+		Owner:  token.GetUserName(),
+		Kind:   "maintenance.update",
+	}, eventtest.HasEvent)
+	request, err = http.NewRequest("GET", "/maintenance/windows", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder = httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var windows []maintenance.Window
+	err = json.Unmarshal(recorder.Body.Bytes(), &windows)
+	c.Assert(err, check.IsNil)
+	c.Assert(windows, check.HasLen, 1)
+	c.Assert(windows[0].Name, check.Equals, "black-friday")
+	c.Assert(windows[0].Pools, check.DeepEquals, []string{"pool1"})
+	c.Assert(windows[0].Actions, check.DeepEquals, []string{maintenance.ActionAutoscaleDown})
+	c.Assert(windows[0].Start.Equal(start), check.Equals, true)
+	c.Assert(windows[0].Owner, check.Equals, token.GetUserName())
+}
+
+func (s *S) TestMaintenanceWindowAddInvalid(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermMaintenanceUpdate,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	request, err := http.NewRequest("POST", "/maintenance/windows", strings.NewReader("name=freeze"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Matches, "(?s).*at least one app or pool is required.*")
+}
+
+func (s *S) TestMaintenanceWindowRemove(c *check.C) {
+	err := maintenance.AddWindow(maintenance.Window{
+		Name:  "freeze",
+		Apps:  []string{"myapp"},
+		Start: time.Now(),
+		End:   time.Now().Add(time.Hour),
+	})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermMaintenanceUpdate,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	request, err := http.NewRequest("DELETE", "/maintenance/windows/freeze", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	windows, err := maintenance.ListWindows()
+	c.Assert(err, check.IsNil)
+	c.Assert(windows, check.HasLen, 0)
+	recorder = httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
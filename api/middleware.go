@@ -12,6 +12,7 @@ import (
 	"os"
 	"reflect"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/codegangsta/negroni"
@@ -24,7 +25,8 @@ import (
 	"github.com/tsuru/tsuru/cmd"
 	tsuruErrors "github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/io"
-	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/maintenance"
+	"github.com/tsuru/tsuru/permission"
 )
 
 const (
@@ -84,7 +86,7 @@ func setRequestIDHeaderMiddleware(w http.ResponseWriter, r *http.Request, next h
 	if requestID == "" {
 		unparsedID, err := uuid.NewV4()
 		if err != nil {
-			log.Errorf("unable to generate request id: %s", err)
+			logger.Errorf("unable to generate request id: %s", err)
 			next(w, r)
 			return
 		}
@@ -106,13 +108,8 @@ func errorHandlingMiddleware(w http.ResponseWriter, r *http.Request, next http.H
 	err := context.GetRequestError(r)
 	if err != nil {
 		verbosity, _ := strconv.Atoi(r.Header.Get(cmd.VerbosityHeader))
-		code := http.StatusInternalServerError
-		switch t := errors.Cause(err).(type) {
-		case *tsuruErrors.ValidationError:
-			code = http.StatusBadRequest
-		case *tsuruErrors.HTTP:
-			code = t.Code
-		}
+		code := tsuruErrors.StatusCode(err)
+		origErr := err
 		if verbosity == 0 {
 			err = fmt.Errorf("%s", err)
 		} else {
@@ -128,13 +125,22 @@ func errorHandlingMiddleware(w http.ResponseWriter, r *http.Request, next http.H
 			} else {
 				fmt.Fprintln(w, err)
 			}
+		} else if verr, ok := tsuruErrors.Cause(origErr).(*tsuruErrors.ValidationError); ok && len(verr.Fields) > 0 && acceptsJSON(r) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(code)
+			json.NewEncoder(w).Encode(verr)
 		} else {
 			http.Error(w, err.Error(), code)
 		}
-		log.Errorf("failure running HTTP request %s %s (%d): %s", r.Method, r.URL.Path, code, err)
+		logger.Errorf("failure running HTTP request %s %s (%d): %s", r.Method, r.URL.Path, code, err)
 	}
 }
 
+// acceptsJSON reports whether the client accepts JSON responses.
+func acceptsJSON(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
 func authTokenMiddleware(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 	token := r.Header.Get("Authorization")
 	if token != "" {
@@ -144,7 +150,7 @@ func authTokenMiddleware(w http.ResponseWriter, r *http.Request, next http.Handl
 				context.AddRequestError(r, err)
 				return
 			}
-			log.Debugf("Ignored invalid token for %s: %s", r.URL.Path, err.Error())
+			logger.Debugf("Ignored invalid token for %s: %s", r.URL.Path, err.Error())
 		} else {
 			context.SetAuthToken(r, t)
 		}
@@ -163,15 +169,9 @@ func (m *appLockMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request, ne
 		next(w, r)
 		return
 	}
-	currentHandler := context.GetDelayedHandler(r)
-	if currentHandler != nil {
-		currentHandlerPtr := reflect.ValueOf(currentHandler).Pointer()
-		for _, h := range m.excludedHandlers {
-			if reflect.ValueOf(h).Pointer() == currentHandlerPtr {
-				next(w, r)
-				return
-			}
-		}
+	if isExcludedHandler(r, m.excludedHandlers) {
+		next(w, r)
+		return
 	}
 	appName := r.URL.Query().Get(":app")
 	if appName == "" {
@@ -182,19 +182,30 @@ func (m *appLockMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request, ne
 		return
 	}
 	t := context.GetAuthToken(r)
-	var owner string
-	if t != nil {
-		if t.IsAppToken() {
-			owner = t.GetAppName()
-		} else {
-			owner = t.GetUserName()
+	// The app is resolved and the permission of scoped routes is checked
+	// before taking the lock, so users don't lock apps they can't change nor
+	// learn, in hard isolation mode, about apps they can't read.
+	if permission.HardIsolation() {
+		_, err := getReadableApp(appName, t)
+		if err != nil {
+			context.AddRequestError(r, err)
+			return
+		}
+	} else {
+		_, err := app.GetByName(appName)
+		if err == app.ErrAppNotFound {
+			context.AddRequestError(r, &tsuruErrors.HTTP{Code: http.StatusNotFound, Message: err.Error()})
+			return
 		}
 	}
-	_, err := app.GetByName(appName)
-	if err == app.ErrAppNotFound {
-		context.AddRequestError(r, &tsuruErrors.HTTP{Code: http.StatusNotFound, Message: err.Error()})
-		return
+	if h, ok := context.GetDelayedHandler(r).(*scopedHandler); ok && t != nil {
+		err := h.authorize(r, t)
+		if err != nil {
+			context.AddRequestError(r, err)
+			return
+		}
 	}
+	owner := lockOwner(t)
 	ok, err := app.AcquireApplicationLockWait(appName, owner, fmt.Sprintf("%s %s", r.Method, r.URL.Path), lockWaitDuration)
 	if err != nil {
 		context.AddRequestError(r, errors.Wrap(err, "Error trying to acquire application lock"))
@@ -209,23 +220,73 @@ func (m *appLockMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request, ne
 		next(w, r)
 		return
 	}
+	context.AddRequestError(r, appLockedError(appName))
+}
+
+func lockOwner(t auth.Token) string {
+	if t == nil {
+		return ""
+	}
+	if t.IsAppToken() {
+		return t.GetAppName()
+	}
+	return t.GetUserName()
+}
+
+// appLockedError describes the failure to acquire the lock of an app.
+func appLockedError(appName string) error {
 	a, err := app.GetByName(appName)
 	if err != nil {
 		if err == app.ErrAppNotFound {
-			err = &tsuruErrors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
-		} else {
-			err = errors.Wrap(err, "Error to get application")
+			return &tsuruErrors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
 		}
+		return errors.Wrap(err, "Error to get application")
+	}
+	httpErr := &tsuruErrors.HTTP{Code: http.StatusConflict}
+	if a.Lock.Locked {
+		httpErr.Message = a.Lock.String()
 	} else {
-		httpErr := &tsuruErrors.HTTP{Code: http.StatusConflict}
-		if a.Lock.Locked {
-			httpErr.Message = a.Lock.String()
-		} else {
-			httpErr.Message = "Not locked anymore, please try again."
+		httpErr.Message = "Not locked anymore, please try again."
+	}
+	return httpErr
+}
+
+func isExcludedHandler(r *http.Request, excludedHandlers []http.Handler) bool {
+	currentHandler := context.GetDelayedHandler(r)
+	if currentHandler == nil {
+		return false
+	}
+	currentHandlerPtr := reflect.ValueOf(currentHandler).Pointer()
+	for _, h := range excludedHandlers {
+		if reflect.ValueOf(h).Pointer() == currentHandlerPtr {
+			return true
 		}
-		err = httpErr
 	}
-	context.AddRequestError(r, err)
+	return false
+}
+
+// maintenanceMiddleware rejects mutating requests while the maintenance mode
+// is enabled, unless they come from users allowed to manage it.
+type maintenanceMiddleware struct {
+	excludedHandlers []http.Handler
+}
+
+func (m *maintenanceMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if r.Method == "GET" || r.Method == "HEAD" || r.Method == "OPTIONS" || isExcludedHandler(r, m.excludedHandlers) {
+		next(w, r)
+		return
+	}
+	mode := maintenance.Current()
+	if !mode.Enabled {
+		next(w, r)
+		return
+	}
+	t := context.GetAuthToken(r)
+	if t != nil && permission.Check(t, permission.PermMaintenanceUpdate) {
+		next(w, r)
+		return
+	}
+	context.AddRequestError(r, &tsuruErrors.HTTP{Code: http.StatusServiceUnavailable, Message: mode.Message})
 }
 
 func runDelayedHandler(w http.ResponseWriter, r *http.Request) {
@@ -6,6 +6,7 @@ package api
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
@@ -23,6 +24,8 @@ import (
 	"github.com/tsuru/tsuru/cmd"
 	tsuruErrors "github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/io"
+	"github.com/tsuru/tsuru/maintenance"
+	"github.com/tsuru/tsuru/permission"
 	"gopkg.in/check.v1"
 	"gopkg.in/mgo.v2/bson"
 )
@@ -156,6 +159,18 @@ func (s *S) TestErrorHandlingMiddlewareWithHTTPError(c *check.C) {
 	c.Assert(recorder.Code, check.Equals, 403)
 }
 
+func (s *S) TestErrorHandlingMiddlewareHardIsolationKeepsForbidden(c *check.C) {
+	config.Set("multi-tenancy:hard-isolation", true)
+	defer config.Unset("multi-tenancy")
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	h, _ := doHandler()
+	context.AddRequestError(request, permission.ErrUnauthorized)
+	errorHandlingMiddleware(recorder, request, h)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
 func (s *S) TestErrorHandlingMiddlewareWithValidationError(c *check.C) {
 	recorder := httptest.NewRecorder()
 	request, err := http.NewRequest("GET", "/", nil)
@@ -180,6 +195,53 @@ func (s *S) TestErrorHandlingMiddlewareWithCauseValidationError(c *check.C) {
 	c.Assert(recorder.Body.String(), check.DeepEquals, "invalid request\n")
 }
 
+func (s *S) TestErrorHandlingMiddlewareWithWrappedKindError(c *check.C) {
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	h, log := doHandler()
+	context.AddRequestError(request, tsuruErrors.Wrap(&tsuruErrors.NotFoundError{Message: "app not found"}, "unable to deploy"))
+	errorHandlingMiddleware(recorder, request, h)
+	c.Assert(log.called, check.Equals, true)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	c.Assert(recorder.Body.String(), check.DeepEquals, "unable to deploy: app not found\n")
+}
+
+func (s *S) TestErrorHandlingMiddlewareWithValidationFields(c *check.C) {
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	h, log := doHandler()
+	verr := &tsuruErrors.ValidationError{}
+	verr.Add("name", "invalid name")
+	verr.Add("team", "team not found")
+	context.AddRequestError(request, verr)
+	errorHandlingMiddleware(recorder, request, h)
+	c.Assert(log.called, check.Equals, true)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "invalid name\nteam not found\n")
+}
+
+func (s *S) TestErrorHandlingMiddlewareWithValidationFieldsJSON(c *check.C) {
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Accept", "application/json")
+	h, log := doHandler()
+	verr := &tsuruErrors.ValidationError{}
+	verr.Add("name", "invalid name")
+	verr.Add("team", "team not found")
+	context.AddRequestError(request, errors.WithStack(verr))
+	errorHandlingMiddleware(recorder, request, h)
+	c.Assert(log.called, check.Equals, true)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result tsuruErrors.ValidationError
+	err = json.NewDecoder(recorder.Body).Decode(&result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, *verr)
+}
+
 func (s *S) TestErrorHandlingMiddlewareWithVerbosity(c *check.C) {
 	recorder := httptest.NewRecorder()
 	request, err := http.NewRequest("GET", "/", nil)
@@ -416,6 +478,65 @@ func (s *S) TestAppLockMiddlewareOnLockedApp(c *check.C) {
 	c.Assert(httpErr.Message, check.Matches, "App locked by someone, running /app/my-app/deploy. Acquired in 2048-11-10.*")
 }
 
+func (s *S) TestAppLockMiddlewareHardIsolation(c *check.C) {
+	config.Set("multi-tenancy:hard-isolation", true)
+	defer config.Unset("multi-tenancy")
+	myApp := app.App{
+		Name:      "my-app",
+		TeamOwner: "otherteam",
+		Lock: app.AppLock{
+			Locked:      true,
+			Reason:      "/app/my-app/deploy",
+			Owner:       "someone",
+			AcquireDate: time.Date(2048, time.November, 10, 10, 0, 0, 0, time.UTC),
+		},
+	}
+	err := s.conn.Apps().Insert(myApp)
+	c.Assert(err, check.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": myApp.Name})
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdate,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	m := &appLockMiddleware{}
+	for _, name := range []string{"my-app", "unknown-app"} {
+		request, err := http.NewRequest("POST", "/?:app="+name, nil)
+		c.Assert(err, check.IsNil)
+		context.SetAuthToken(request, token)
+		h, log := doHandler()
+		m.ServeHTTP(httptest.NewRecorder(), request, h)
+		c.Assert(log.called, check.Equals, false)
+		c.Assert(context.GetRequestError(request), check.Equals, errIsolatedNotFound)
+	}
+}
+
+func (s *S) TestAppLockMiddlewareChecksScopeBeforeLocking(c *check.C) {
+	myApp := app.App{Name: "my-app", TeamOwner: s.team.Name}
+	err := s.conn.Apps().Insert(myApp)
+	c.Assert(err, check.IsNil)
+	defer s.conn.Apps().Remove(bson.M{"name": myApp.Name})
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateStop,
+		Context: permission.Context(permission.CtxApp, "-invalid-"),
+	})
+	request, err := http.NewRequest("POST", "/?:app=my-app", nil)
+	c.Assert(err, check.IsNil)
+	context.SetAuthToken(request, token)
+	context.SetDelayedHandler(request, &scopedHandler{
+		handler:    AuthorizationRequiredHandler(stop),
+		permission: permission.PermAppUpdateStop,
+		scope:      appScope,
+	})
+	m := &appLockMiddleware{}
+	m.ServeHTTP(httptest.NewRecorder(), request, func(w http.ResponseWriter, r *http.Request) {
+		c.Fatal("handler called without permission")
+	})
+	c.Assert(context.GetRequestError(request), check.Equals, permission.ErrUnauthorized)
+	a, err := app.GetByName(myApp.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(a.Lock.Locked, check.Equals, false)
+}
+
 func (s *S) TestAppLockMiddlewareLocksAndUnlocks(c *check.C) {
 	myApp := app.App{
 		Name: "my-app",
@@ -479,6 +600,82 @@ func (s *S) TestAppLockMiddlewareDoesNothingForExcludedHandlers(c *check.C) {
 	c.Assert(log.called, check.Equals, true)
 }
 
+func (s *S) TestMaintenanceMiddlewareDisabled(c *check.C) {
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("POST", "/apps", nil)
+	c.Assert(err, check.IsNil)
+	h, log := doHandler()
+	m := &maintenanceMiddleware{}
+	m.ServeHTTP(recorder, request, h)
+	c.Assert(log.called, check.Equals, true)
+}
+
+func (s *S) TestMaintenanceMiddlewareRejectsMutatingRequests(c *check.C) {
+	_, err := maintenance.Enable("upgrading mongodb", "admin@example.com")
+	c.Assert(err, check.IsNil)
+	defer maintenance.Disable()
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppCreate,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	for _, method := range []string{"POST", "PUT", "DELETE"} {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(method, "/apps", nil)
+		c.Assert(err, check.IsNil)
+		context.SetAuthToken(request, token)
+		h, log := doHandler()
+		m := &maintenanceMiddleware{}
+		m.ServeHTTP(recorder, request, h)
+		c.Assert(log.called, check.Equals, false)
+		httpErr := context.GetRequestError(request).(*tsuruErrors.HTTP)
+		c.Assert(httpErr.Code, check.Equals, http.StatusServiceUnavailable)
+		c.Assert(httpErr.Message, check.Equals, "upgrading mongodb")
+	}
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/apps", nil)
+	c.Assert(err, check.IsNil)
+	context.SetAuthToken(request, token)
+	h, log := doHandler()
+	m := &maintenanceMiddleware{}
+	m.ServeHTTP(recorder, request, h)
+	c.Assert(log.called, check.Equals, true)
+}
+
+func (s *S) TestMaintenanceMiddlewareAllowsAdmins(c *check.C) {
+	_, err := maintenance.Enable("upgrading mongodb", "admin@example.com")
+	c.Assert(err, check.IsNil)
+	defer maintenance.Disable()
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermMaintenanceUpdate,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("POST", "/apps", nil)
+	c.Assert(err, check.IsNil)
+	context.SetAuthToken(request, token)
+	h, log := doHandler()
+	m := &maintenanceMiddleware{}
+	m.ServeHTTP(recorder, request, h)
+	c.Assert(log.called, check.Equals, true)
+}
+
+func (s *S) TestMaintenanceMiddlewareDoesNothingForExcludedHandlers(c *check.C) {
+	_, err := maintenance.Enable("upgrading mongodb", "admin@example.com")
+	c.Assert(err, check.IsNil)
+	defer maintenance.Disable()
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("POST", "/auth/login", nil)
+	c.Assert(err, check.IsNil)
+	finalHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	context.SetDelayedHandler(request, finalHandler)
+	h, log := doHandler()
+	m := &maintenanceMiddleware{
+		excludedHandlers: []http.Handler{finalHandler},
+	}
+	m.ServeHTTP(recorder, request, h)
+	c.Assert(log.called, check.Equals, true)
+}
+
 func (s *S) TestAppLockMiddlewareWaitForLock(c *check.C) {
 	myApp := app.App{
 		Name: "my-app",
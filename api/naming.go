@@ -0,0 +1,181 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ajg/form"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/naming"
+	"github.com/tsuru/tsuru/permission"
+	authTypes "github.com/tsuru/tsuru/types/auth"
+)
+
+// title: name reservation list
+// path: /naming/reservations
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+func nameReservationList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermNamingRead) {
+		return permission.ErrUnauthorized
+	}
+	reservations, err := naming.ListReservations(r.URL.Query().Get("kind"))
+	if err != nil {
+		return err
+	}
+	if len(reservations) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(reservations)
+}
+
+// title: name reservation add
+// path: /naming/reservations
+// method: POST
+// consume: application/x-www-form-urlencoded
+// responses:
+//   201: Name reserved
+//   400: Invalid data
+//   401: Unauthorized
+//   409: Name already reserved
+func nameReservationAdd(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermNamingUpdate) {
+		return permission.ErrUnauthorized
+	}
+	reservation := naming.Reservation{
+		Kind:   r.FormValue("kind"),
+		Name:   r.FormValue("name"),
+		Team:   r.FormValue("team"),
+		Reason: r.FormValue("reason"),
+		Owner:  t.GetUserName(),
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeGlobal},
+		Kind:       permission.PermNamingUpdate,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermNamingReadEvents),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = naming.Reserve(reservation)
+	if err == naming.ErrReservationAlreadyExists {
+		return &errors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusCreated)
+	return nil
+}
+
+// title: name reservation remove
+// path: /naming/reservations/{kind}/{name}
+// method: DELETE
+// responses:
+//   200: Reservation removed
+//   401: Unauthorized
+//   404: Reservation not found
+func nameReservationRemove(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermNamingUpdate) {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeGlobal},
+		Kind:       permission.PermNamingUpdate,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermNamingReadEvents),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = naming.Release(r.URL.Query().Get(":kind"), r.URL.Query().Get(":name"))
+	if err == naming.ErrReservationNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
+
+// title: naming prefixes list
+// path: /naming/prefixes
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+func namingPrefixesList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermNamingRead) {
+		return permission.ErrUnauthorized
+	}
+	prefixes, err := naming.ListTeamPrefixes()
+	if err != nil {
+		return err
+	}
+	if len(prefixes) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(prefixes)
+}
+
+// title: naming prefixes set
+// path: /naming/prefixes/{team}
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Prefixes set
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Team not found
+func namingPrefixesSet(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermNamingUpdate) {
+		return permission.ErrUnauthorized
+	}
+	err = r.ParseForm()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	var data naming.TeamPrefixes
+	dec := form.NewDecoder(nil)
+	dec.IgnoreUnknownKeys(true)
+	dec.IgnoreCase(true)
+	err = dec.DecodeValues(&data, r.Form)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	team := r.URL.Query().Get(":team")
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeGlobal},
+		Kind:       permission.PermNamingUpdate,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermNamingReadEvents),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = naming.SetTeamPrefixes(team, data.Prefixes)
+	if err == authTypes.ErrTeamNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
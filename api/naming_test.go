@@ -0,0 +1,99 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/naming"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestNameReservationAddAndList(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermNaming,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	body := strings.NewReader("kind=app&name=www&team=" + s.team.Name + "&reason=company+website")
+	request, err := http.NewRequest("POST", "/naming/reservations", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeGlobal},
+		Owner:  token.GetUserName(),
+		Kind:   "naming.update",
+	}, eventtest.HasEvent)
+	request, err = http.NewRequest("GET", "/naming/reservations?kind=app", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder = httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var reservations []naming.Reservation
+	err = json.Unmarshal(recorder.Body.Bytes(), &reservations)
+	c.Assert(err, check.IsNil)
+	c.Assert(reservations, check.HasLen, 1)
+	c.Assert(reservations[0].Name, check.Equals, "www")
+	c.Assert(reservations[0].Team, check.Equals, s.team.Name)
+	c.Assert(reservations[0].Owner, check.Equals, token.GetUserName())
+}
+
+func (s *S) TestNameReservationAddWithoutPermission(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermNamingRead,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	request, err := http.NewRequest("POST", "/naming/reservations", strings.NewReader("kind=app&name=www"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestNameReservationRemove(c *check.C) {
+	err := naming.Reserve(naming.Reservation{Kind: naming.KindServiceInstance, Name: "api"})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", "/naming/reservations/service-instance/api", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	recorder = httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestNamingPrefixesSet(c *check.C) {
+	request, err := http.NewRequest("PUT", "/naming/prefixes/"+s.team.Name, strings.NewReader("prefixes.0=pay-&prefixes.1=billing-"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	prefixes, err := naming.GetTeamPrefixes(s.team.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(prefixes, check.DeepEquals, []string{"pay-", "billing-"})
+	request, err = http.NewRequest("PUT", "/naming/prefixes/unknown", strings.NewReader("prefixes.0=pay-"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
@@ -7,6 +7,8 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -15,6 +17,7 @@ import (
 
 	"github.com/ajg/form"
 	"github.com/pkg/errors"
+	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/auth"
 	tsuruErrors "github.com/tsuru/tsuru/errors"
@@ -46,20 +49,31 @@ func validateNodeAddress(address string) error {
 	return nil
 }
 
-func addNodeForParams(p provision.NodeProvisioner, params provision.AddNodeOptions) (string, map[string]string, error) {
-	response := make(map[string]string)
-	var address string
+func addNodeForParams(p provision.NodeProvisioner, params provision.AddNodeOptions, w io.Writer) (address string, response map[string]string, err error) {
+	response = make(map[string]string)
+	var m *iaas.Machine
+	defer func() {
+		if err == nil || m == nil {
+			return
+		}
+		fmt.Fprintf(w, "---- Destroying machine %q ----\n", m.Id)
+		if destroyErr := m.Destroy(); destroyErr != nil {
+			logger.Errorf("unable to destroy machine %q after failing to add node: %s", m.Id, destroyErr)
+		}
+	}()
 	if params.Register {
 		address = params.Metadata["address"]
 		delete(params.Metadata, "address")
 	} else {
 		desc, _ := iaas.Describe(params.Metadata["iaas"])
 		response["description"] = desc
-		m, err := iaas.CreateMachine(params.Metadata)
+		fmt.Fprintln(w, "---- Creating machine ----")
+		m, err = iaas.CreateMachine(params.Metadata)
 		if err != nil {
 			return address, response, err
 		}
 		address = m.FormatNodeAddress()
+		fmt.Fprintf(w, "---- Machine %q created with address %s ----\n", m.Id, address)
 		params.CaCert = m.CaCert
 		params.ClientCert = m.ClientCert
 		params.ClientKey = m.ClientKey
@@ -77,8 +91,23 @@ func addNodeForParams(p provision.NodeProvisioner, params provision.AddNodeOptio
 		return address, response, err
 	}
 	params.Address = address
+	if params.WaitTO != 0 {
+		fmt.Fprintf(w, "---- Waiting up to %s for node to be ready ----\n", params.WaitTO)
+	}
 	err = p.AddNode(params)
-	return address, response, err
+	if err != nil {
+		return address, response, err
+	}
+	fmt.Fprintf(w, "---- Node %s registered in pool %q ----\n", address, params.Pool)
+	return address, response, nil
+}
+
+func nodeCreateWaitTimeout() time.Duration {
+	waitSecs, err := config.GetInt("iaas:wait-new-time")
+	if err != nil {
+		waitSecs = 300
+	}
+	return time.Duration(waitSecs) * time.Second
 }
 
 // title: add node
@@ -88,6 +117,7 @@ func addNodeForParams(p provision.NodeProvisioner, params provision.AddNodeOptio
 // produce: application/x-json-stream
 // responses:
 //   201: Ok
+//   400: Invalid data
 //   401: Unauthorized
 //   404: Not found
 func addNodeHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
@@ -95,6 +125,7 @@ func addNodeHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (err e
 	if err != nil {
 		return &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
 	}
+	create, _ := strconv.ParseBool(r.URL.Query().Get("create"))
 	var params provision.AddNodeOptions
 	dec := form.NewDecoder(nil)
 	dec.IgnoreUnknownKeys(true)
@@ -115,6 +146,14 @@ func addNodeHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (err e
 	if params.Pool == "" {
 		return &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: "pool is required"}
 	}
+	if create {
+		if params.Register {
+			return &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: "create and register are mutually exclusive"}
+		}
+		if params.WaitTO == 0 {
+			params.WaitTO = nodeCreateWaitTimeout()
+		}
+	}
 	if !permission.Check(t, permission.PermNodeCreate, permission.Context(permission.CtxPool, params.Pool)) {
 		return permission.ErrUnauthorized
 	}
@@ -146,7 +185,11 @@ func addNodeHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (err e
 	w.WriteHeader(http.StatusCreated)
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 15*time.Second, "")
 	defer keepAliveWriter.Stop()
-	addr, response, err := addNodeForParams(nodeProv, params)
+	var progress io.Writer = ioutil.Discard
+	if create {
+		progress = &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	}
+	addr, response, err := addNodeForParams(nodeProv, params, progress)
 	evt.Target.Value = addr
 	if err != nil {
 		if desc := response["description"]; desc != "" {
@@ -188,6 +231,26 @@ func removeNodeHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (er
 	if !allowedNodeRemove {
 		return permission.ErrUnauthorized
 	}
+	noRebalance, _ := strconv.ParseBool(r.URL.Query().Get("no-rebalance"))
+	removeIaaS, _ := strconv.ParseBool(r.URL.Query().Get("remove-iaas"))
+	if isDryRun(r) {
+		var actions []string
+		if !noRebalance {
+			var units []provision.Unit
+			units, err = node.Units()
+			if err != nil {
+				return err
+			}
+			for _, u := range units {
+				actions = append(actions, fmt.Sprintf("move unit %q of app %q to another node", u.ID, u.AppName))
+			}
+		}
+		actions = append(actions, fmt.Sprintf("remove node %q", node.Address()))
+		if removeIaaS {
+			actions = append(actions, fmt.Sprintf("destroy IaaS machine of node %q", node.Address()))
+		}
+		return writeDryRunActions(w, actions)
+	}
 	evt, err := event.New(&event.Opts{
 		Target:     event.Target{Type: event.TargetTypeNode, Value: node.Address()},
 		Kind:       permission.PermNodeDelete,
@@ -199,7 +262,6 @@ func removeNodeHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (er
 		return err
 	}
 	defer func() { evt.Done(err) }()
-	noRebalance, _ := strconv.ParseBool(r.URL.Query().Get("no-rebalance"))
 	err = nodeProv.RemoveNode(provision.RemoveNodeOptions{
 		Address:   address,
 		Rebalance: !noRebalance,
@@ -208,7 +270,6 @@ func removeNodeHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (er
 	if err != nil {
 		return err
 	}
-	removeIaaS, _ := strconv.ParseBool(r.URL.Query().Get("remove-iaas"))
 	if removeIaaS {
 		var m iaas.Machine
 		m, err = iaas.FindMachineByIdOrAddress(node.IaaSID(), net.URLToHost(address))
@@ -598,6 +659,9 @@ func rebalanceNodesHandler(w http.ResponseWriter, r *http.Request, t auth.Token)
 		}
 	}
 	params.Force = true
+	if isDryRun(r) {
+		params.Dry = true
+	}
 	var permContexts []permission.PermissionContext
 	var ok bool
 	evtTarget := event.Target{Type: event.TargetTypeGlobal}
@@ -7,6 +7,7 @@ package api
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -164,6 +165,128 @@ func (s *S) TestAddNodeHandlerCreatingAnIaasMachine(c *check.C) {
 	}, eventtest.HasEvent)
 }
 
+func (s *S) TestAddNodeHandlerCreateAndRegisterIaasMachine(c *check.C) {
+	iaas.RegisterIaasProvider("test-iaas", newTestIaaS)
+	opts := pool.AddPoolOptions{Name: "pool1"}
+	err := pool.AddPool(opts)
+	c.Assert(err, check.IsNil)
+	defer pool.RemovePool("pool1")
+	params := provision.AddNodeOptions{
+		Metadata: map[string]string{
+			"id":   "test1",
+			"pool": "pool1",
+			"iaas": "test-iaas",
+		},
+	}
+	v, err := form.EncodeToValues(&params)
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("POST", "/1.2/node?create=true", strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", s.token.GetValue())
+	rec := httptest.NewRecorder()
+	s.testServer.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusCreated)
+	c.Assert(rec.Header().Get("Content-Type"), check.Equals, "application/x-json-stream")
+	body := rec.Body.String()
+	c.Assert(body, check.Matches, `(?s).*Creating machine.*`)
+	c.Assert(body, check.Matches, `(?s).*Machine \\"test1\\" created with address http://test1.somewhere.com:2375.*`)
+	c.Assert(body, check.Matches, `(?s).*Waiting up to 5m0s for node to be ready.*`)
+	c.Assert(body, check.Matches, `(?s).*Node http://test1.somewhere.com:2375 registered in pool \\"pool1\\".*`)
+	nodes, err := s.provisioner.ListNodes(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(nodes, check.HasLen, 1)
+	c.Assert(nodes[0].Address(), check.Equals, "http://test1.somewhere.com:2375")
+	c.Assert(nodes[0].Pool(), check.Equals, "pool1")
+	c.Assert(nodes[0].IaaSID(), check.Equals, "test1")
+}
+
+func (s *S) TestAddNodeHandlerCreateDestroysMachineOnFailure(c *check.C) {
+	iaas.RegisterIaasProvider("test-iaas", newTestIaaS)
+	opts := pool.AddPoolOptions{Name: "pool1"}
+	err := pool.AddPool(opts)
+	c.Assert(err, check.IsNil)
+	defer pool.RemovePool("pool1")
+	s.provisioner.PrepareFailure("AddNode", errors.New("node not ready"))
+	params := provision.AddNodeOptions{
+		Metadata: map[string]string{
+			"id":   "test1",
+			"pool": "pool1",
+			"iaas": "test-iaas",
+		},
+	}
+	v, err := form.EncodeToValues(&params)
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("POST", "/1.2/node?create=true", strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", s.token.GetValue())
+	rec := httptest.NewRecorder()
+	s.testServer.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusCreated)
+	c.Assert(rec.Body.String(), check.Matches, `(?s).*Destroying machine \\"test1\\".*node not ready.*`)
+	nodes, err := s.provisioner.ListNodes(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(nodes, check.HasLen, 0)
+	machines, err := iaas.ListMachines()
+	c.Assert(err, check.IsNil)
+	c.Assert(machines, check.HasLen, 0)
+}
+
+func (s *S) TestAddNodeHandlerCreateDestroysMachineOnExistingNode(c *check.C) {
+	iaas.RegisterIaasProvider("test-iaas", newTestIaaS)
+	opts := pool.AddPoolOptions{Name: "pool1"}
+	err := pool.AddPool(opts)
+	c.Assert(err, check.IsNil)
+	defer pool.RemovePool("pool1")
+	err = s.provisioner.AddNode(provision.AddNodeOptions{Address: "http://test1.somewhere.com:2375", Pool: "pool1"})
+	c.Assert(err, check.IsNil)
+	params := provision.AddNodeOptions{
+		Metadata: map[string]string{
+			"id":   "test1",
+			"pool": "pool1",
+			"iaas": "test-iaas",
+		},
+	}
+	v, err := form.EncodeToValues(&params)
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("POST", "/1.2/node?create=true", strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", s.token.GetValue())
+	rec := httptest.NewRecorder()
+	s.testServer.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusCreated)
+	c.Assert(rec.Body.String(), check.Matches, `(?s).*Destroying machine \\"test1\\".*already exists.*`)
+	machines, err := iaas.ListMachines()
+	c.Assert(err, check.IsNil)
+	c.Assert(machines, check.HasLen, 0)
+}
+
+func (s *S) TestAddNodeHandlerCreateWithRegister(c *check.C) {
+	opts := pool.AddPoolOptions{Name: "pool1"}
+	err := pool.AddPool(opts)
+	c.Assert(err, check.IsNil)
+	defer pool.RemovePool("pool1")
+	params := provision.AddNodeOptions{
+		Register: true,
+		Metadata: map[string]string{
+			"address": "http://mysrv1",
+			"pool":    "pool1",
+		},
+	}
+	v, err := form.EncodeToValues(&params)
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("POST", "/1.2/node?create=true", strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", s.token.GetValue())
+	rec := httptest.NewRecorder()
+	s.testServer.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(rec.Body.String(), check.Equals, "create and register are mutually exclusive\n")
+}
+
 func (s *S) TestAddNodeHandlerWithoutAddress(c *check.C) {
 	opts := pool.AddPoolOptions{Name: "pool1"}
 	err := pool.AddPool(opts)
@@ -320,6 +443,42 @@ func (s *S) TestRemoveNodeHandlerNoRebalance(c *check.C) {
 	}, eventtest.HasEvent)
 }
 
+func (s *S) TestRemoveNodeHandlerDryRun(c *check.C) {
+	err := s.provisioner.AddNode(provision.AddNodeOptions{
+		Address: "host.com:2375",
+	})
+	c.Assert(err, check.IsNil)
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err = app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	_, err = s.provisioner.AddUnitsToNode(&a, 1, "web", nil, "host.com:2375")
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("DELETE", "/node/host.com:2375?dry-run=true&remove-iaas=true", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Header().Get("Content-Type"), check.Equals, "application/json")
+	var actions []string
+	err = json.Unmarshal(rec.Body.Bytes(), &actions)
+	c.Assert(err, check.IsNil)
+	c.Assert(actions, check.DeepEquals, []string{
+		`move unit "myapp-0" of app "myapp" to another node`,
+		`remove node "host.com:2375"`,
+		`destroy IaaS machine of node "host.com:2375"`,
+	})
+	nodes, err := s.provisioner.ListNodes(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(nodes, check.HasLen, 1)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeNode, Value: "host.com:2375"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "node.delete",
+	}, check.Not(eventtest.HasEvent))
+}
+
 func (s *S) TestRemoveNodeHandlerWithoutRemoveIaaS(c *check.C) {
 	iaas.RegisterIaasProvider("some-iaas", newTestIaaS)
 	machine, err := iaas.CreateMachineForIaaS("some-iaas", map[string]string{"id": "m1"})
@@ -974,6 +1133,23 @@ func (s *S) TestNodeRebalanceEmptyBodyHandler(c *check.C) {
 	}, eventtest.HasEvent)
 }
 
+func (s *S) TestNodeRebalanceDryRun(c *check.C) {
+	err := s.provisioner.AddNode(provision.AddNodeOptions{
+		Address: "n1",
+		Pool:    "test1",
+	})
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("POST", "/node/rebalance?dry-run=true", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), check.Matches, "(?s).*rebalancing - dry: true, force: true.*")
+}
+
 func (s *S) TestNodeRebalanceFilters(c *check.C) {
 	poolOpts := pool.AddPoolOptions{Name: "pool1"}
 	err := pool.AddPool(poolOpts)
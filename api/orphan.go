@@ -0,0 +1,77 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+)
+
+// title: orphan list
+// path: /orphans
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+func orphanList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermOrphanRead) {
+		return permission.ErrUnauthorized
+	}
+	orphans, err := app.FindOrphans()
+	if err != nil {
+		return err
+	}
+	if len(orphans) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(orphans)
+}
+
+// title: orphan cleanup
+// path: /orphans/cleanup
+// method: POST
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+func orphanCleanup(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermOrphanCleanup) {
+		return permission.ErrUnauthorized
+	}
+	orphans, err := app.FindOrphans()
+	if err != nil {
+		return err
+	}
+	if len(orphans) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	evt, err := event.New(&event.Opts{
+		Target:  event.Target{Type: event.TargetTypeGlobal},
+		Kind:    permission.PermOrphanCleanup,
+		Owner:   t,
+		Allowed: event.Allowed(permission.PermOrphanReadEvents),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.DoneCustomData(err, orphans) }()
+	err = app.RepairOrphans(orphans)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(orphans)
+}
@@ -0,0 +1,124 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/service"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) insertOrphanInstance(c *check.C) {
+	err := s.conn.ServiceInstances().Insert(service.ServiceInstance{
+		Name:        "my-mysql",
+		ServiceName: "mysql",
+		Teams:       []string{s.team.Name},
+		TeamOwner:   s.team.Name,
+		Apps:        []string{"removedapp"},
+	})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestOrphanList(c *check.C) {
+	s.insertOrphanInstance(c)
+	request, err := http.NewRequest("GET", "/orphans", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var orphans []app.Orphan
+	err = json.Unmarshal(recorder.Body.Bytes(), &orphans)
+	c.Assert(err, check.IsNil)
+	c.Assert(orphans, check.DeepEquals, []app.Orphan{
+		{Kind: app.OrphanInstanceBind, App: "removedapp", Service: "mysql", Instance: "my-mysql"},
+	})
+	var si service.ServiceInstance
+	err = s.conn.ServiceInstances().Find(map[string]string{"name": "my-mysql"}).One(&si)
+	c.Assert(err, check.IsNil)
+	c.Assert(si.Apps, check.DeepEquals, []string{"removedapp"})
+}
+
+func (s *S) TestOrphanListEmpty(c *check.C) {
+	request, err := http.NewRequest("GET", "/orphans", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestOrphanListWithoutPermission(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppRead,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	request, err := http.NewRequest("GET", "/orphans", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestOrphanCleanup(c *check.C) {
+	s.insertOrphanInstance(c)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermOrphanCleanup,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	request, err := http.NewRequest("POST", "/orphans/cleanup", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var orphans []app.Orphan
+	err = json.Unmarshal(recorder.Body.Bytes(), &orphans)
+	c.Assert(err, check.IsNil)
+	c.Assert(orphans, check.DeepEquals, []app.Orphan{
+		{Kind: app.OrphanInstanceBind, App: "removedapp", Service: "mysql", Instance: "my-mysql", Repaired: true},
+	})
+	var si service.ServiceInstance
+	err = s.conn.ServiceInstances().Find(map[string]string{"name": "my-mysql"}).One(&si)
+	c.Assert(err, check.IsNil)
+	c.Assert(si.Apps, check.DeepEquals, []string{})
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeGlobal},
+		Owner:  token.GetUserName(),
+		Kind:   "orphan.cleanup",
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestOrphanCleanupEmpty(c *check.C) {
+	request, err := http.NewRequest("POST", "/orphans/cleanup", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestOrphanCleanupWithoutPermission(c *check.C) {
+	s.insertOrphanInstance(c)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermOrphanRead,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	request, err := http.NewRequest("POST", "/orphans/cleanup", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
@@ -14,7 +14,6 @@ import (
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/event"
-	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/repository"
 )
@@ -218,13 +217,13 @@ func syncRepositoryApps(user *auth.User, beforeApps []string, roleCache map[stri
 			err = manager.RevokeAccess(a, user.Email)
 		}
 		if err != nil {
-			log.Errorf("error revoking gandalf access for app %s, user %s: %s", a, user.Email, err)
+			logger.Errorf("error revoking gandalf access for app %s, user %s: %s", a, user.Email, err)
 		}
 	}
 	for _, a := range afterApps {
 		err := manager.GrantAccess(a, user.Email)
 		if err != nil {
-			log.Errorf("error granting gandalf access for app %s, user %s: %s", a, user.Email, err)
+			logger.Errorf("error granting gandalf access for app %s, user %s: %s", a, user.Email, err)
 		}
 	}
 	return nil
@@ -250,7 +249,7 @@ func runWithPermSync(users []auth.User, callback func() error) error {
 	for u, apps := range usersMap {
 		err = syncRepositoryApps(u, apps, roleCache)
 		if err != nil {
-			log.Errorf("unable to sync gandalf repositories updating permissions: %s", err)
+			logger.Errorf("unable to sync gandalf repositories updating permissions: %s", err)
 		}
 	}
 	return nil
@@ -163,6 +163,7 @@ func platformRemove(w http.ResponseWriter, r *http.Request, t auth.Token) (err e
 // responses:
 //   200: List platforms
 //   204: No content
+//   304: Not modified
 //   401: Unauthorized
 func platformList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	canUsePlat := permission.Check(t, permission.PermPlatformUpdate) ||
@@ -175,6 +176,10 @@ func platformList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 		w.WriteHeader(http.StatusNoContent)
 		return nil
 	}
-	w.Header().Set("Content-Type", "application/json")
-	return json.NewEncoder(w).Encode(platforms)
+	data, err := json.Marshal(platforms)
+	if err != nil {
+		return err
+	}
+	writeCacheable(w, r, "application/json", time.Time{}, data)
+	return nil
 }
@@ -334,6 +334,32 @@ func (s *PlatformSuite) TestPlatformList(c *check.C) {
 	c.Assert(got, check.DeepEquals, platforms)
 }
 
+func (s *PlatformSuite) TestPlatformListNotModified(c *check.C) {
+	app.PlatformService().Insert(appTypes.Platform{Name: "python"})
+	token := createToken(c)
+	request, err := http.NewRequest("GET", "/platforms", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Cache-Control"), check.Equals, "private, no-cache")
+	etag := recorder.Header().Get("ETag")
+	c.Assert(etag, check.Not(check.Equals), "")
+	request, err = http.NewRequest("GET", "/platforms", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	request.Header.Set("If-None-Match", etag)
+	recorder = httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotModified)
+	app.PlatformService().Insert(appTypes.Platform{Name: "ruby"})
+	recorder = httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("ETag"), check.Not(check.Equals), etag)
+}
+
 func (s *PlatformSuite) TestPlatformListGetDisabledPlatforms(c *check.C) {
 	platforms := []appTypes.Platform{
 		{Name: "python", Disabled: true},
@@ -77,8 +77,24 @@ func poolList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 		w.WriteHeader(http.StatusNoContent)
 		return nil
 	}
+	if isGlobal || !permission.HardIsolation() {
+		w.Header().Set("Content-Type", "application/json")
+		return json.NewEncoder(w).Encode(poolList)
+	}
+	services, err := readableServices(t, permission.ContextsForPermission(t, permission.PermServiceRead))
+	if err != nil {
+		return err
+	}
+	serviceNames := make([]string, len(services))
+	for i := range services {
+		serviceNames[i] = services[i].Name
+	}
+	views := make([]json.Marshaler, len(poolList))
+	for i := range poolList {
+		views[i] = poolList[i].VisibleTo(teams, serviceNames)
+	}
 	w.Header().Set("Content-Type", "application/json")
-	return json.NewEncoder(w).Encode(poolList)
+	return json.NewEncoder(w).Encode(views)
 }
 
 // title: pool create
@@ -154,10 +170,6 @@ func addPoolHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (err e
 //   404: Pool not found
 func removePoolHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	r.ParseForm()
-	allowed := permission.Check(t, permission.PermPoolDelete)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	poolName := r.URL.Query().Get(":name")
 	filter := &app.Filter{}
 	filter.Pool = poolName
@@ -201,10 +213,6 @@ func addTeamToPoolHandler(w http.ResponseWriter, r *http.Request, t auth.Token)
 		return &terrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
 	}
 	poolName := r.URL.Query().Get(":name")
-	allowed := permission.Check(t, permission.PermPoolUpdateTeamAdd, permission.Context(permission.CtxPool, poolName))
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	evt, err := event.New(&event.Opts{
 		Target:     event.Target{Type: event.TargetTypePool, Value: poolName},
 		Kind:       permission.PermPoolUpdateTeamAdd,
@@ -237,10 +245,6 @@ func addTeamToPoolHandler(w http.ResponseWriter, r *http.Request, t auth.Token)
 func removeTeamToPoolHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	r.ParseForm()
 	poolName := r.URL.Query().Get(":name")
-	allowed := permission.Check(t, permission.PermPoolUpdateTeamRemove, permission.Context(permission.CtxPool, poolName))
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	evt, err := event.New(&event.Opts{
 		Target:     event.Target{Type: event.TargetTypePool, Value: poolName},
 		Kind:       permission.PermPoolUpdateTeamRemove,
@@ -276,10 +280,6 @@ func removeTeamToPoolHandler(w http.ResponseWriter, r *http.Request, t auth.Toke
 //   409: Default pool already defined
 func poolUpdateHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	r.ParseForm()
-	allowed := permission.Check(t, permission.PermPoolUpdate)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	poolName := r.URL.Query().Get(":name")
 	evt, err := event.New(&event.Opts{
 		Target:     event.Target{Type: event.TargetTypePool, Value: poolName},
@@ -12,6 +12,7 @@ import (
 	"strings"
 
 	"github.com/ajg/form"
+	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/event"
@@ -399,6 +400,39 @@ func (s *S) TestPoolListPublicPool(c *check.C) {
 	c.Assert(pools, check.DeepEquals, expected)
 }
 
+func (s *S) TestPoolListHardIsolation(c *check.C) {
+	config.Set("multi-tenancy:hard-isolation", true)
+	defer config.Unset("multi-tenancy")
+	for _, name := range []string{"angra", "otherteam"} {
+		err := auth.TeamService().Insert(authTypes.Team{Name: name})
+		c.Assert(err, check.IsNil)
+	}
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppCreate,
+		Context: permission.Context(permission.CtxTeam, "angra"),
+	})
+	err := pool.AddPool(pool.AddPoolOptions{Name: "pool1", Public: true})
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("GET", "/pools", nil)
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	err = poolList(rec, req, token)
+	c.Assert(err, check.IsNil)
+	var pools []map[string]interface{}
+	err = json.NewDecoder(rec.Body).Decode(&pools)
+	c.Assert(err, check.IsNil)
+	var found bool
+	for _, p := range pools {
+		if p["name"] != "pool1" {
+			continue
+		}
+		found = true
+		c.Assert(p["teams"], check.DeepEquals, []interface{}{"angra"})
+		c.Assert(p["allowed"].(map[string]interface{})["team"], check.DeepEquals, []interface{}{"angra"})
+	}
+	c.Assert(found, check.Equals, true)
+}
+
 func (s *S) TestPoolListHandler(c *check.C) {
 	team := authTypes.Team{Name: "angra"}
 	err := auth.TeamService().Insert(team)
@@ -660,6 +694,31 @@ func (s *S) TestPoolUpdateProvisioner(c *check.C) {
 	}, eventtest.HasEvent)
 }
 
+func (s *S) TestPoolUpdateMemoryOvercommit(c *check.C) {
+	opts := pool.AddPoolOptions{Name: "pool1"}
+	err := pool.AddPool(opts)
+	c.Assert(err, check.IsNil)
+	b := bytes.NewBufferString("memoryovercommit=1.5")
+	req, err := http.NewRequest("PUT", "/pools/pool1", b)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	s.testServer.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	p, err := pool.GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(p.MemoryOvercommit, check.Equals, 1.5)
+	b = bytes.NewBufferString("memoryovercommit=-1")
+	req, err = http.NewRequest("PUT", "/pools/pool1", b)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec = httptest.NewRecorder()
+	s.testServer.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusBadRequest)
+}
+
 func (s *S) TestPoolUpdateNotFound(c *check.C) {
 	b := bytes.NewBufferString("public=true")
 	request, err := http.NewRequest("PUT", "/pools/not-found", b)
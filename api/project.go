@@ -0,0 +1,596 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/ajg/form"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/service"
+)
+
+type projectInfo struct {
+	app.Project
+	Apps             []string
+	ServiceInstances []string
+}
+
+type projectEnvs struct {
+	Env   map[string]string
+	Unset []string
+}
+
+func contextsForProject(p *app.Project) []permission.PermissionContext {
+	return permission.Contexts(permission.CtxTeam, append([]string{p.TeamOwner}, p.Teams...))
+}
+
+func projectTarget(name string) event.Target {
+	return event.Target{Type: event.TargetTypeProject, Value: name}
+}
+
+func getProject(name string) (*app.Project, error) {
+	p, err := app.GetProject(name)
+	if err == app.ErrProjectNotFound {
+		return nil, &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return p, err
+}
+
+func projectErrorToHTTP(err error) error {
+	switch err {
+	case app.ErrProjectNotFound:
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	case app.ErrProjectAlreadyExists, app.ErrInOtherProject, app.ErrAlreadyHaveAccess:
+		return &errors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	case app.ErrNotInProject, app.ErrNoAccess:
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	case app.ErrCannotOrphanApp:
+		return &errors.HTTP{Code: http.StatusForbidden, Message: "cannot revoke access from the team owner of the project"}
+	}
+	return err
+}
+
+// title: project list
+// path: /projects
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+func projectList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	contexts := permission.ContextsForPermission(t, permission.PermProjectRead)
+	if len(contexts) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	filter := &app.ProjectFilter{}
+	for _, c := range contexts {
+		if c.CtxType == permission.CtxGlobal {
+			filter = nil
+			break
+		}
+		if c.CtxType == permission.CtxTeam {
+			filter.Teams = append(filter.Teams, c.Value)
+		}
+	}
+	if filter != nil && len(filter.Teams) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	projects, err := app.ListProjects(filter)
+	if err != nil {
+		return err
+	}
+	if len(projects) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(projects)
+}
+
+// title: project create
+// path: /projects
+// method: POST
+// consume: application/x-www-form-urlencoded
+// responses:
+//   201: Project created
+//   400: Invalid data
+//   401: Unauthorized
+//   409: Project already exists
+func projectCreate(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	err = r.ParseForm()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	var p app.Project
+	dec := form.NewDecoder(nil)
+	dec.IgnoreUnknownKeys(true)
+	dec.IgnoreCase(true)
+	err = dec.DecodeValues(&p, r.Form)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	if p.TeamOwner == "" {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "team owner is required"}
+	}
+	if !permission.Check(t, permission.PermProjectCreate, permission.Context(permission.CtxTeam, p.TeamOwner)) {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     projectTarget(p.Name),
+		Kind:       permission.PermProjectCreate,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermProjectReadEvents, permission.Context(permission.CtxTeam, p.TeamOwner)),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = app.CreateProject(p)
+	if err != nil {
+		return projectErrorToHTTP(err)
+	}
+	w.WriteHeader(http.StatusCreated)
+	return nil
+}
+
+// title: project info
+// path: /projects/{name}
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: Project not found
+func projectInfoHandler(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	p, err := getProject(r.URL.Query().Get(":name"))
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, permission.PermProjectRead, contextsForProject(p)...) {
+		return permission.ErrUnauthorized
+	}
+	apps, err := p.Apps()
+	if err != nil {
+		return err
+	}
+	instances, err := p.ServiceInstances()
+	if err != nil {
+		return err
+	}
+	info := projectInfo{Project: *p, Apps: []string{}, ServiceInstances: []string{}}
+	for _, a := range apps {
+		info.Apps = append(info.Apps, a.Name)
+	}
+	for _, si := range instances {
+		info.ServiceInstances = append(info.ServiceInstances, fmt.Sprintf("%s/%s", si.ServiceName, si.Name))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(info)
+}
+
+// title: project delete
+// path: /projects/{name}
+// method: DELETE
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: Project not found
+func projectDelete(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	p, err := getProject(r.URL.Query().Get(":name"))
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, permission.PermProjectDelete, contextsForProject(p)...) {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     projectTarget(p.Name),
+		Kind:       permission.PermProjectDelete,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermProjectReadEvents, contextsForProject(p)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return projectErrorToHTTP(app.RemoveProject(p.Name))
+}
+
+// title: project add app
+// path: /projects/{name}/apps/{app}
+// method: PUT
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: Project or app not found
+//   409: App already in another project
+func projectAddApp(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	p, a, err := projectAppFromRequest(r, t)
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     projectTarget(p.Name),
+		Kind:       permission.PermProjectUpdateApp,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermProjectReadEvents, contextsForProject(p)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return projectErrorToHTTP(p.AddApp(a))
+}
+
+// title: project remove app
+// path: /projects/{name}/apps/{app}
+// method: DELETE
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: Project or app not found
+func projectRemoveApp(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	p, a, err := projectAppFromRequest(r, t)
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     projectTarget(p.Name),
+		Kind:       permission.PermProjectUpdateApp,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermProjectReadEvents, contextsForProject(p)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return projectErrorToHTTP(p.RemoveApp(a))
+}
+
+// projectAppFromRequest loads the project and the app in the request,
+// checking whether the user can change the apps of the project and grant
+// access to the app.
+func projectAppFromRequest(r *http.Request, t auth.Token) (*app.Project, *app.App, error) {
+	r.ParseForm()
+	p, err := getProject(r.URL.Query().Get(":name"))
+	if err != nil {
+		return nil, nil, err
+	}
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !permission.Check(t, permission.PermProjectUpdateApp, contextsForProject(p)...) ||
+		!permission.Check(t, permission.PermAppUpdateGrant, contextsForApp(&a)...) {
+		return nil, nil, permission.ErrUnauthorized
+	}
+	return p, &a, nil
+}
+
+// title: project add service instance
+// path: /projects/{name}/service-instances/{service}/{instance}
+// method: PUT
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: Project or service instance not found
+//   409: Service instance already in another project
+func projectAddServiceInstance(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	p, si, err := projectServiceInstanceFromRequest(r, t)
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     projectTarget(p.Name),
+		Kind:       permission.PermProjectUpdateServiceInstance,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermProjectReadEvents, contextsForProject(p)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return projectErrorToHTTP(p.AddServiceInstance(si))
+}
+
+// title: project remove service instance
+// path: /projects/{name}/service-instances/{service}/{instance}
+// method: DELETE
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: Project or service instance not found
+func projectRemoveServiceInstance(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	p, si, err := projectServiceInstanceFromRequest(r, t)
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     projectTarget(p.Name),
+		Kind:       permission.PermProjectUpdateServiceInstance,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermProjectReadEvents, contextsForProject(p)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return projectErrorToHTTP(p.RemoveServiceInstance(si))
+}
+
+// projectServiceInstanceFromRequest loads the project and the service
+// instance in the request, checking whether the user can change the
+// instances of the project and update the instance.
+func projectServiceInstanceFromRequest(r *http.Request, t auth.Token) (*app.Project, *service.ServiceInstance, error) {
+	r.ParseForm()
+	p, err := getProject(r.URL.Query().Get(":name"))
+	if err != nil {
+		return nil, nil, err
+	}
+	serviceName := r.URL.Query().Get(":service")
+	si, err := getReadableServiceInstance(t, serviceName, r.URL.Query().Get(":instance"))
+	if err != nil {
+		return nil, nil, err
+	}
+	if !permission.Check(t, permission.PermProjectUpdateServiceInstance, contextsForProject(p)...) ||
+		!permission.Check(t, permission.PermServiceInstanceUpdate, contextsForServiceInstance(si, serviceName)...) {
+		return nil, nil, permission.ErrUnauthorized
+	}
+	return p, si, nil
+}
+
+// title: project set envs
+// path: /projects/{name}/env
+// method: POST
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Envs updated
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Project not found
+func projectSetEnvs(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	err = r.ParseForm()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	var envs projectEnvs
+	dec := form.NewDecoder(nil)
+	dec.IgnoreUnknownKeys(true)
+	dec.IgnoreCase(true)
+	err = dec.DecodeValues(&envs, r.Form)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	if len(envs.Env) == 0 && len(envs.Unset) == 0 {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "You must provide the list of environment variables"}
+	}
+	p, err := getProject(r.URL.Query().Get(":name"))
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, permission.PermProjectUpdateEnv, contextsForProject(p)...) {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     projectTarget(p.Name),
+		Kind:       permission.PermProjectUpdateEnv,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermProjectReadEvents, contextsForProject(p)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return p.SetEnvs(envs.Env, envs.Unset)
+}
+
+// title: grant access to project
+// path: /projects/{name}/teams/{team}
+// method: PUT
+// responses:
+//   200: Access granted
+//   401: Unauthorized
+//   404: Project or team not found
+//   409: Grant already exists
+func projectGrant(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	p, err := getProject(r.URL.Query().Get(":name"))
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, permission.PermProjectUpdateGrant, contextsForProject(p)...) {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     projectTarget(p.Name),
+		Kind:       permission.PermProjectUpdateGrant,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermProjectReadEvents, contextsForProject(p)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	team, err := auth.TeamService().FindByName(r.URL.Query().Get(":team"))
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: "Team not found"}
+	}
+	return projectErrorToHTTP(p.Grant(team))
+}
+
+// title: revoke access to project
+// path: /projects/{name}/teams/{team}
+// method: DELETE
+// responses:
+//   200: Access revoked
+//   401: Unauthorized
+//   403: Forbidden
+//   404: Project or team not found
+func projectRevoke(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	p, err := getProject(r.URL.Query().Get(":name"))
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, permission.PermProjectUpdateRevoke, contextsForProject(p)...) {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     projectTarget(p.Name),
+		Kind:       permission.PermProjectUpdateRevoke,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermProjectReadEvents, contextsForProject(p)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	team, err := auth.TeamService().FindByName(r.URL.Query().Get(":team"))
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: "Team not found"}
+	}
+	return projectErrorToHTTP(p.Revoke(team))
+}
+
+// title: project events
+// path: /projects/{name}/events
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+//   404: Project not found
+func projectEvents(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	p, err := getProject(r.URL.Query().Get(":name"))
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, permission.PermProjectReadEvents, contextsForProject(p)...) {
+		return permission.ErrUnauthorized
+	}
+	apps, err := p.Apps()
+	if err != nil {
+		return err
+	}
+	instances, err := p.ServiceInstances()
+	if err != nil {
+		return err
+	}
+	filter := &event.Filter{
+		AllowedTargets: []event.TargetFilter{{Type: event.TargetTypeProject, Values: []string{p.Name}}},
+	}
+	if len(apps) > 0 {
+		names := make([]string, len(apps))
+		for i := range apps {
+			names[i] = apps[i].Name
+		}
+		filter.AllowedTargets = append(filter.AllowedTargets, event.TargetFilter{Type: event.TargetTypeApp, Values: names})
+	}
+	if len(instances) > 0 {
+		names := make([]string, len(instances))
+		for i, si := range instances {
+			names[i] = serviceInstanceTarget(si.ServiceName, si.Name).Value
+		}
+		filter.AllowedTargets = append(filter.AllowedTargets, event.TargetFilter{Type: event.TargetTypeServiceInstance, Values: names})
+	}
+	if l, _ := strconv.Atoi(r.URL.Query().Get("limit")); l > 0 {
+		filter.Limit = l
+	}
+	filter.PruneUserValues()
+	events, err := event.List(filter)
+	if err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(events)
+}
+
+// title: project log
+// path: /projects/{name}/log
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Project not found
+func projectLog(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	lines, err := strconv.Atoi(r.URL.Query().Get("lines"))
+	if err != nil || lines <= 0 {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: `Parameter "lines" must be a positive integer.`}
+	}
+	p, err := getProject(r.URL.Query().Get(":name"))
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, permission.PermProjectRead, contextsForProject(p)...) {
+		return permission.ErrUnauthorized
+	}
+	apps, err := p.Apps()
+	if err != nil {
+		return err
+	}
+	for i := range apps {
+		if !permission.Check(t, permission.PermAppReadLog, contextsForApp(&apps[i])...) {
+			return permission.ErrUnauthorized
+		}
+	}
+	logs, err := p.LastLogs(lines)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(logs)
+}
+
+// title: project usage
+// path: /projects/{name}/usage
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: Project not found
+func projectUsage(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	p, err := getProject(r.URL.Query().Get(":name"))
+	if err != nil {
+		return err
+	}
+	if !permission.Check(t, permission.PermProjectRead, contextsForProject(p)...) {
+		return permission.ErrUnauthorized
+	}
+	usage, err := p.Usage()
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(usage)
+}
@@ -0,0 +1,143 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestProjectCreate(c *check.C) {
+	body := strings.NewReader("name=fleet&description=my+fleet&teamowner=" + s.team.Name + "&env.LOG_LEVEL=info")
+	request, err := http.NewRequest("POST", "/projects", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	p, err := app.GetProject("fleet")
+	c.Assert(err, check.IsNil)
+	c.Assert(p, check.DeepEquals, &app.Project{
+		Name:        "fleet",
+		Description: "my fleet",
+		TeamOwner:   s.team.Name,
+		Teams:       []string{s.team.Name},
+		Env:         map[string]string{"LOG_LEVEL": "info"},
+	})
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeProject, Value: "fleet"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "project.create",
+	}, eventtest.HasEvent)
+	recorder = httptest.NewRecorder()
+	request, err = http.NewRequest("POST", "/projects", strings.NewReader("name=fleet&teamowner="+s.team.Name))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusConflict)
+}
+
+func (s *S) TestProjectCreateWithoutPermission(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermProjectCreate,
+		Context: permission.Context(permission.CtxTeam, "other-team"),
+	})
+	request, err := http.NewRequest("POST", "/projects", strings.NewReader("name=fleet&teamowner="+s.team.Name))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestProjectListFilteredByTeam(c *check.C) {
+	err := app.CreateProject(app.Project{Name: "fleet", TeamOwner: s.team.Name})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermProjectRead,
+		Context: permission.Context(permission.CtxTeam, "other-team"),
+	})
+	request, err := http.NewRequest("GET", "/projects", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var projects []app.Project
+	err = json.NewDecoder(recorder.Body).Decode(&projects)
+	c.Assert(err, check.IsNil)
+	c.Assert(projects, check.HasLen, 1)
+	c.Assert(projects[0].Name, check.Equals, "fleet")
+}
+
+func (s *S) TestProjectAddAppAndInfo(c *check.C) {
+	err := app.CreateProject(app.Project{Name: "fleet", TeamOwner: s.team.Name})
+	c.Assert(err, check.IsNil)
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err = app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("PUT", "/projects/fleet/apps/myapp", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeProject, Value: "fleet"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "project.update.app",
+	}, eventtest.HasEvent)
+	request, err = http.NewRequest("GET", "/projects/fleet", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var info projectInfo
+	err = json.NewDecoder(recorder.Body).Decode(&info)
+	c.Assert(err, check.IsNil)
+	c.Assert(info.Apps, check.DeepEquals, []string{"myapp"})
+	c.Assert(info.ServiceInstances, check.DeepEquals, []string{})
+}
+
+func (s *S) TestProjectSetEnvs(c *check.C) {
+	err := app.CreateProject(app.Project{Name: "fleet", TeamOwner: s.team.Name, Env: map[string]string{"OLD": "1"}})
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("env.LOG_LEVEL=debug&unset.0=OLD")
+	request, err := http.NewRequest("POST", "/projects/fleet/env", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	p, err := app.GetProject("fleet")
+	c.Assert(err, check.IsNil)
+	c.Assert(p.Env, check.DeepEquals, map[string]string{"LOG_LEVEL": "debug"})
+}
+
+func (s *S) TestProjectDeleteNotFound(c *check.C) {
+	request, err := http.NewRequest("DELETE", "/projects/unknown", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
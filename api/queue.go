@@ -0,0 +1,126 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/queue"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// title: queue worker list
+// path: /queue/workers
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+func queueWorkerList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermQueueRead) {
+		return permission.ErrUnauthorized
+	}
+	pools := queue.WorkerPools()
+	if len(pools) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(pools)
+}
+
+// title: queue job list
+// path: /queue/jobs
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   400: Invalid state
+//   401: Unauthorized
+func queueJobList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermQueueRead) {
+		return permission.ErrUnauthorized
+	}
+	filter := queue.MessageFilter{
+		Queue:  r.URL.Query().Get("queue"),
+		Target: r.URL.Query().Get("target"),
+		State:  r.URL.Query().Get("state"),
+	}
+	switch filter.State {
+	case "", queue.MessageStateWaiting, queue.MessageStateInFlight, queue.MessageStateExpired, queue.MessageStateDead:
+	default:
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid state: " + filter.State}
+	}
+	msgs, err := queue.ListMessages(filter)
+	if err != nil {
+		return err
+	}
+	if len(msgs) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(msgs)
+}
+
+// title: queue job retry
+// path: /queue/jobs/{id}/retry
+// method: POST
+// responses:
+//   200: Job retried
+//   400: Invalid id
+//   401: Unauthorized
+//   404: Job not found
+func queueJobRetry(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermQueueUpdateRetry) {
+		return permission.ErrUnauthorized
+	}
+	return queueJobAction(r, t, permission.PermQueueUpdateRetry, queue.RetryMessage)
+}
+
+// title: queue job discard
+// path: /queue/jobs/{id}
+// method: DELETE
+// responses:
+//   200: Job discarded
+//   400: Invalid id
+//   401: Unauthorized
+//   404: Job not found
+func queueJobDiscard(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	if !permission.Check(t, permission.PermQueueUpdateDiscard) {
+		return permission.ErrUnauthorized
+	}
+	return queueJobAction(r, t, permission.PermQueueUpdateDiscard, queue.DiscardMessage)
+}
+
+func queueJobAction(r *http.Request, t auth.Token, perm *permission.PermissionScheme, action func(bson.ObjectId) error) (err error) {
+	id := r.URL.Query().Get(":id")
+	if !bson.IsObjectIdHex(id) {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid job id"}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeQueueMessage, Value: id},
+		Kind:       perm,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermQueueReadEvents),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = action(bson.ObjectIdHex(id))
+	if err == queue.ErrMessageNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
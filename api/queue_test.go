@@ -0,0 +1,172 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/queue"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type queueTestPayload struct {
+	Name string
+}
+
+type queueTargetedPayload struct {
+	Name string
+}
+
+func (p queueTargetedPayload) Target() string {
+	return p.Name
+}
+
+func (s *S) TestQueueJobList(c *check.C) {
+	q := queue.NewWorkQueue("api-test", queue.WorkQueueOpts{})
+	msg, err := q.Enqueue(queueTestPayload{Name: "a"})
+	c.Assert(err, check.IsNil)
+	defer queue.DiscardMessage(msg.ID)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermQueueRead,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	request, err := http.NewRequest("GET", "/1.6/queue/jobs?queue=api-test", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var msgs []queue.MessageInfo
+	err = json.Unmarshal(recorder.Body.Bytes(), &msgs)
+	c.Assert(err, check.IsNil)
+	c.Assert(msgs, check.HasLen, 1)
+	c.Assert(msgs[0].ID, check.Equals, msg.ID)
+	c.Assert(msgs[0].Queue, check.Equals, "api-test")
+	c.Assert(msgs[0].State, check.Equals, queue.MessageStateWaiting)
+	c.Assert(msgs[0].Data, check.DeepEquals, bson.M{"name": "a"})
+}
+
+func (s *S) TestQueueJobListByTarget(c *check.C) {
+	q := queue.NewWorkQueue("api-test", queue.WorkQueueOpts{})
+	msg, err := q.Enqueue(queueTargetedPayload{Name: "myapp"})
+	c.Assert(err, check.IsNil)
+	defer queue.DiscardMessage(msg.ID)
+	other, err := q.Enqueue(queueTargetedPayload{Name: "otherapp"})
+	c.Assert(err, check.IsNil)
+	defer queue.DiscardMessage(other.ID)
+	request, err := http.NewRequest("GET", "/1.6/queue/jobs?target=myapp", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var msgs []queue.MessageInfo
+	err = json.Unmarshal(recorder.Body.Bytes(), &msgs)
+	c.Assert(err, check.IsNil)
+	c.Assert(msgs, check.HasLen, 1)
+	c.Assert(msgs[0].ID, check.Equals, msg.ID)
+	c.Assert(msgs[0].Target, check.Equals, "myapp")
+}
+
+func (s *S) TestQueueJobListInvalidState(c *check.C) {
+	request, err := http.NewRequest("GET", "/1.6/queue/jobs?state=sleeping", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+}
+
+func (s *S) TestQueueJobListForbidden(c *check.C) {
+	token := userWithPermission(c)
+	request, err := http.NewRequest("GET", "/1.6/queue/jobs", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestQueueWorkerListEmpty(c *check.C) {
+	request, err := http.NewRequest("GET", "/1.6/queue/workers", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *S) TestQueueJobRetry(c *check.C) {
+	q := queue.NewWorkQueue("api-test", queue.WorkQueueOpts{MaxAttempts: 1})
+	_, err := q.Enqueue(queueTestPayload{Name: "a"})
+	c.Assert(err, check.IsNil)
+	msg, err := q.Receive()
+	c.Assert(err, check.IsNil)
+	defer queue.DiscardMessage(msg.ID)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermQueueUpdateRetry,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	request, err := http.NewRequest("POST", "/1.6/queue/jobs/"+msg.ID.Hex()+"/retry", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	retried, err := q.Receive()
+	c.Assert(err, check.IsNil)
+	c.Assert(retried.ID, check.Equals, msg.ID)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeQueueMessage, Value: msg.ID.Hex()},
+		Owner:  token.GetUserName(),
+		Kind:   "queue.update.retry",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":id", "value": msg.ID.Hex()},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestQueueJobRetryNotFound(c *check.C) {
+	request, err := http.NewRequest("POST", "/1.6/queue/jobs/"+bson.NewObjectId().Hex()+"/retry", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	request, err = http.NewRequest("POST", "/1.6/queue/jobs/invalid/retry", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+}
+
+func (s *S) TestQueueJobDiscard(c *check.C) {
+	q := queue.NewWorkQueue("api-test", queue.WorkQueueOpts{})
+	msg, err := q.Enqueue(queueTestPayload{Name: "a"})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermQueueUpdateDiscard,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	request, err := http.NewRequest("DELETE", "/1.6/queue/jobs/"+msg.ID.Hex(), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	_, err = q.Receive()
+	c.Assert(err, check.Equals, queue.ErrNoMessage)
+	recorder = httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
@@ -103,10 +103,6 @@ func getAppQuota(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if err != nil {
 		return err
 	}
-	canRead := permission.Check(t, permission.PermAppRead, contextsForApp(&a)...)
-	if !canRead {
-		return permission.ErrUnauthorized
-	}
 	w.Header().Set("Content-Type", "application/json")
 	return json.NewEncoder(w).Encode(a.Quota)
 }
@@ -127,10 +123,6 @@ func changeAppQuota(w http.ResponseWriter, r *http.Request, t auth.Token) (err e
 	if err != nil {
 		return err
 	}
-	allowed := permission.Check(t, permission.PermAppAdminQuota, contextsForApp(&a)...)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	evt, err := event.New(&event.Opts{
 		Target:     event.Target{Type: event.TargetTypeApp, Value: appName},
 		Kind:       permission.PermAppAdminQuota,
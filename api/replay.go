@@ -0,0 +1,123 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/errors"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	signatureHeader = "X-Tsuru-Signature"
+	timestampHeader = "X-Tsuru-Timestamp"
+	nonceHeader     = "X-Tsuru-Nonce"
+
+	defaultSignedRequestMaxAge = 5 * time.Minute
+	maxNonceLength             = 128
+
+	// webhookDeliveryRetention is how long deliveries of git provider
+	// webhooks are remembered. Providers don't sign the time of the
+	// delivery, so replays are only caught within this period.
+	webhookDeliveryRetention = 7 * 24 * time.Hour
+)
+
+var (
+	errReplayedRequest         = &errors.HTTP{Code: http.StatusUnauthorized, Message: "request already processed"}
+	errInvalidRequestTime      = &errors.HTTP{Code: http.StatusUnauthorized, Message: "request timestamp missing or out of the accepted range"}
+	errInvalidRequestNonce     = &errors.HTTP{Code: http.StatusUnauthorized, Message: "request nonce missing or invalid"}
+	errInvalidRequestSignature = &errors.HTTP{Code: http.StatusUnauthorized, Message: "invalid request signature"}
+)
+
+// signedRequestMaxAge is the largest difference between the timestamp of
+// signed requests and the server clock, in either direction.
+func signedRequestMaxAge() time.Duration {
+	seconds, err := config.GetInt("callback:max-age")
+	if err != nil || seconds <= 0 {
+		return defaultSignedRequestMaxAge
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// verifySignedRequest checks the signature of a request sent with the
+// X-Tsuru-Timestamp, X-Tsuru-Nonce and X-Tsuru-Signature headers. The
+// signature is the HMAC-SHA256 of "<timestamp>.<nonce>.<body>" keyed by
+// secret, in the "sha256=<hex>" format. Requests older than the accepted age
+// and nonces already used in the scope are rejected, preventing replays.
+func verifySignedRequest(header http.Header, body []byte, secret, scope string) error {
+	if secret == "" {
+		return errInvalidRequestSignature
+	}
+	timestamp := header.Get(timestampHeader)
+	nonce := header.Get(nonceHeader)
+	message := make([]byte, 0, len(timestamp)+len(nonce)+len(body)+2)
+	message = append(message, timestamp+"."+nonce+"."...)
+	message = append(message, body...)
+	if !validHMAC(sha256.New, "sha256=", header.Get(signatureHeader), message, secret) {
+		return errInvalidRequestSignature
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errInvalidRequestTime
+	}
+	maxAge := signedRequestMaxAge()
+	age := time.Since(time.Unix(seconds, 0))
+	if age > maxAge || age < -maxAge {
+		return errInvalidRequestTime
+	}
+	if nonce == "" || len(nonce) > maxNonceLength {
+		return errInvalidRequestNonce
+	}
+	return useNonce(scope, nonce, 2*maxAge)
+}
+
+// useNonce records the nonce in the scope, returning errReplayedRequest if
+// it was already used. Nonces are forgotten after retention.
+func useNonce(scope, nonce string, retention time.Duration) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.RequestNonces().Insert(bson.M{
+		"_id":      scope + "/" + nonce,
+		"expireat": time.Now().UTC().Add(retention),
+	})
+	if mgo.IsDup(err) {
+		return errReplayedRequest
+	}
+	return err
+}
+
+// releaseNonce forgets a nonce recorded by useNonce, so requests that were
+// not processed after all may be sent again.
+func releaseNonce(scope, nonce string) {
+	conn, err := db.Conn()
+	if err != nil {
+		logger.Errorf("unable to release nonce of %s: %s", scope, err)
+		return
+	}
+	defer conn.Close()
+	err = conn.RequestNonces().RemoveId(scope + "/" + nonce)
+	if err != nil {
+		logger.Errorf("unable to release nonce of %s: %s", scope, err)
+	}
+}
+
+// webhookDeliveryNonce returns the nonce of a git provider webhook delivery,
+// derived from its payload. Delivery ids sent by the providers in headers
+// aren't covered by the signature, so they can't be trusted to detect
+// replays.
+func webhookDeliveryNonce(body []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(body))
+}
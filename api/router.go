@@ -100,12 +100,6 @@ func addAppRouter(w http.ResponseWriter, r *http.Request, t auth.Token) (err err
 	if err != nil {
 		return err
 	}
-	allowed := permission.Check(t, permission.PermAppUpdateRouterAdd,
-		contextsForApp(&a)...,
-	)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	evt, err := event.New(&event.Opts{
 		Target:     appTarget(appName),
 		Kind:       permission.PermAppUpdateRouterAdd,
@@ -152,12 +146,6 @@ func updateAppRouter(w http.ResponseWriter, r *http.Request, t auth.Token) (err
 	if err != nil {
 		return err
 	}
-	allowed := permission.Check(t, permission.PermAppUpdateRouterUpdate,
-		contextsForApp(&a)...,
-	)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	evt, err := event.New(&event.Opts{
 		Target:     appTarget(appName),
 		Kind:       permission.PermAppUpdateRouterUpdate,
@@ -191,12 +179,6 @@ func removeAppRouter(w http.ResponseWriter, r *http.Request, t auth.Token) (err
 	if err != nil {
 		return err
 	}
-	allowed := permission.Check(t, permission.PermAppUpdateRouterRemove,
-		contextsForApp(&a)...,
-	)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	evt, err := event.New(&event.Opts{
 		Target:     appTarget(appName),
 		Kind:       permission.PermAppUpdateRouterRemove,
@@ -228,12 +210,6 @@ func listAppRouters(w http.ResponseWriter, r *http.Request, t auth.Token) error
 	if err != nil {
 		return err
 	}
-	canRead := permission.Check(t, permission.PermAppReadRouter,
-		contextsForApp(&a)...,
-	)
-	if !canRead {
-		return permission.ErrUnauthorized
-	}
 	w.Header().Set("Content-Type", "application/json")
 	routers, err := a.GetRoutersWithAddr()
 	if err != nil {
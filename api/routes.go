@@ -0,0 +1,456 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/tsuru/config"
+	apiRouter "github.com/tsuru/tsuru/api/router"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/app/job"
+	"github.com/tsuru/tsuru/app/metrics"
+	"github.com/tsuru/tsuru/autoscale"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/featureflag"
+	"github.com/tsuru/tsuru/iaas"
+	"github.com/tsuru/tsuru/install"
+	"github.com/tsuru/tsuru/maintenance"
+	"github.com/tsuru/tsuru/naming"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/cluster"
+	"github.com/tsuru/tsuru/provision/pool"
+	"github.com/tsuru/tsuru/queue"
+	"github.com/tsuru/tsuru/service"
+	appTypes "github.com/tsuru/tsuru/types/app"
+	"github.com/tsuru/tsuru/volume"
+)
+
+// route is the declarative definition of an API endpoint. Besides being
+// used to build the request multiplexer, the route table is the source for
+// the OpenAPI document served at /swagger.json, so the permission, request
+// and response fields should be kept in sync with the handler code.
+type route struct {
+	version string
+	// method is the HTTP method handled by the route, an empty method binds
+	// the route to GET, POST, PUT and DELETE.
+	method  string
+	path    string
+	handler http.Handler
+	// permission is the main permission checked by the handler, or, when
+	// scope is set, before the handler runs.
+	permission *permission.PermissionScheme
+	// scope derives the contexts in which permission is required. Handlers
+	// of routes with a scope don't check the permission themselves, while
+	// routes without a scope are checked by their handlers, as the contexts
+	// depend on the request form or more than one permission is required.
+	scope *permissionScope
+	// request is a value of the type decoded from the request form.
+	request interface{}
+	// response is a value of the type encoded in the response body.
+	response    interface{}
+	skipAppLock bool
+	// skipMaintenance keeps the route available to every user while the
+	// maintenance mode is enabled.
+	skipMaintenance bool
+	deprecated      bool
+}
+
+// register adds the route to the router, returning the handler registered
+// for it. Handlers of routes with a scope are wrapped by a handler requiring
+// the route permission before running them.
+func (r *route) register(m *apiRouter.DelayedRouter) http.Handler {
+	h := r.handler
+	if r.scope != nil {
+		h = &scopedHandler{handler: h, permission: r.permission, scope: r.scope}
+	}
+	if r.method == "" {
+		m.AddAll(r.version, r.path, h)
+		return h
+	}
+	m.Add(r.version, r.method, r.path, h)
+	return h
+}
+
+var routeTable = []route{
+	{version: "1.0", method: "GET", path: "/info", handler: Handler(info), response: map[string]string{}},
+
+	// Handlers of service routes without a scope check their permissions:
+	// creation and clone in the team of the new instance, connection info in
+	// the owner team, updates depending on the fields changed, binds both in
+	// the instance and in the app, and plans and docs only for restricted
+	// services.
+	{version: "1.6", method: "GET", path: "/services/catalog", handler: AuthorizationRequiredHandler(serviceCatalog), response: []service.CatalogEntry{}},
+	{version: "1.6", method: "GET", path: "/services/instances/{instance}/connection", handler: AuthorizationRequiredHandler(serviceInstanceConnectionInfo), permission: permission.PermServiceInstanceReadConnection, response: serviceInstanceConnection{}},
+	{version: "1.6", method: "GET", path: "/services/instances/{instance}/metrics", handler: AuthorizationRequiredHandler(serviceInstanceMetricsHandler), permission: permission.PermServiceInstanceReadMetrics, scope: serviceInstanceScope, response: serviceInstanceMetrics{}},
+	{version: "1.6", method: "POST", path: "/services/instances/{instance}/clone", handler: AuthorizationRequiredHandler(cloneServiceInstance), permission: permission.PermServiceInstanceCreate},
+	{version: "1.6", method: "POST", path: "/services/instances/{instance}/callback", handler: Handler(serviceInstanceCallback)},
+	{version: "1.0", method: "GET", path: "/services/instances", handler: AuthorizationRequiredHandler(serviceInstances)},
+	{version: "1.0", method: "GET", path: "/services/{service}/instances/{instance}", handler: AuthorizationRequiredHandler(serviceInstance), permission: permission.PermServiceInstanceRead, scope: serviceInstanceScope},
+	{version: "1.0", method: "DELETE", path: "/services/{service}/instances/{instance}", handler: AuthorizationRequiredHandler(removeServiceInstance), permission: permission.PermServiceInstanceDelete, scope: serviceInstanceScope},
+	{version: "1.0", method: "POST", path: "/services/{service}/instances", handler: AuthorizationRequiredHandler(createServiceInstance), permission: permission.PermServiceInstanceCreate},
+	{version: "1.0", method: "PUT", path: "/services/{service}/instances/{instance}", handler: AuthorizationRequiredHandler(updateServiceInstance)},
+	{version: "1.0", method: "PUT", path: "/services/{service}/instances/{instance}/{app}", handler: AuthorizationRequiredHandler(bindServiceInstance), permission: permission.PermServiceInstanceUpdateBind},
+	{version: "1.0", method: "DELETE", path: "/services/{service}/instances/{instance}/{app}", handler: AuthorizationRequiredHandler(unbindServiceInstance), permission: permission.PermServiceInstanceUpdateUnbind},
+	{version: "1.0", method: "GET", path: "/services/{service}/instances/{instance}/status", handler: AuthorizationRequiredHandler(serviceInstanceStatus), permission: permission.PermServiceInstanceReadStatus, scope: serviceInstanceScope},
+	{version: "1.0", method: "PUT", path: "/services/{service}/instances/permission/{instance}/{team}", handler: AuthorizationRequiredHandler(serviceInstanceGrantTeam), permission: permission.PermServiceInstanceUpdateGrant, scope: serviceInstanceScope},
+	{version: "1.0", method: "DELETE", path: "/services/{service}/instances/permission/{instance}/{team}", handler: AuthorizationRequiredHandler(serviceInstanceRevokeTeam), permission: permission.PermServiceInstanceUpdateRevoke, scope: serviceInstanceScope},
+
+	{version: "1.0", path: "/services/{service}/proxy/{instance}", handler: AuthorizationRequiredHandler(serviceInstanceProxy), permission: permission.PermServiceInstanceUpdateProxy, scope: serviceInstanceScope},
+	{version: "1.0", path: "/services/proxy/service/{service}", handler: AuthorizationRequiredHandler(serviceProxy), permission: permission.PermServiceUpdateProxy, scope: serviceScope},
+
+	{version: "1.0", method: "GET", path: "/services", handler: AuthorizationRequiredHandler(serviceList), permission: permission.PermServiceRead, response: []service.ServiceModel{}},
+	{version: "1.0", method: "POST", path: "/services", handler: AuthorizationRequiredHandler(serviceCreate), permission: permission.PermServiceCreate},
+	{version: "1.0", method: "PUT", path: "/services/{name}", handler: AuthorizationRequiredHandler(serviceUpdate), permission: permission.PermServiceUpdate, scope: serviceScope},
+	{version: "1.0", method: "DELETE", path: "/services/{name}", handler: AuthorizationRequiredHandler(serviceDelete), permission: permission.PermServiceDelete, scope: serviceScope},
+	{version: "1.0", method: "GET", path: "/services/{name}", handler: AuthorizationRequiredHandler(serviceInfo)},
+	{version: "1.0", method: "GET", path: "/services/{name}/plans", handler: AuthorizationRequiredHandler(servicePlans), permission: permission.PermServiceReadPlans},
+	{version: "1.0", method: "GET", path: "/services/{name}/doc", handler: AuthorizationRequiredHandler(serviceDoc), permission: permission.PermServiceReadDoc},
+	{version: "1.6", method: "GET", path: "/services/{name}/status", handler: AuthorizationRequiredHandler(serviceStatus), permission: permission.PermServiceReadStatus, scope: serviceScope, response: service.ProviderStatus{}},
+	{version: "1.0", method: "PUT", path: "/services/{name}/doc", handler: AuthorizationRequiredHandler(serviceAddDoc), permission: permission.PermServiceUpdateDoc, scope: serviceScope},
+	{version: "1.6", method: "PUT", path: "/services/{name}/webhook", handler: AuthorizationRequiredHandler(serviceWebhookSet), permission: permission.PermServiceUpdateWebhook, scope: serviceScope, response: service.Webhook{}},
+	{version: "1.6", method: "DELETE", path: "/services/{name}/webhook", handler: AuthorizationRequiredHandler(serviceWebhookRemove), permission: permission.PermServiceUpdateWebhook, scope: serviceScope},
+	{version: "1.0", method: "PUT", path: "/services/{service}/team/{team}", handler: AuthorizationRequiredHandler(grantServiceAccess), permission: permission.PermServiceUpdateGrantAccess, scope: serviceScope},
+	{version: "1.0", method: "DELETE", path: "/services/{service}/team/{team}", handler: AuthorizationRequiredHandler(revokeServiceAccess), permission: permission.PermServiceUpdateRevokeAccess, scope: serviceScope},
+	{version: "1.6", method: "PUT", path: "/services/{name}/teams", handler: AuthorizationRequiredHandler(setServiceTeams), permission: permission.PermServiceUpdateTeams, scope: serviceScope, response: service.TeamsDiff{}},
+
+	{version: "1.0", method: "DELETE", path: "/apps/{app}", handler: AuthorizationRequiredHandler(appDelete), permission: permission.PermAppDelete, scope: appScope},
+	{version: "1.0", method: "GET", path: "/apps/{app}", handler: AuthorizationRequiredHandler(appInfo), permission: permission.PermAppReadInfo, scope: appScope},
+	{version: "1.6", method: "POST", path: "/apps/{app}/restore", handler: AuthorizationRequiredHandler(appRestore), permission: permission.PermAppUpdateRestore, skipAppLock: true},
+	{version: "1.6", method: "POST", path: "/apps/{app}/clone", handler: AuthorizationRequiredHandler(appClone), permission: permission.PermAppCreate},
+	{version: "1.0", method: "POST", path: "/apps/{app}/cname", handler: AuthorizationRequiredHandler(setCName), permission: permission.PermAppUpdateCnameAdd, scope: appScope},
+	{version: "1.0", method: "DELETE", path: "/apps/{app}/cname", handler: AuthorizationRequiredHandler(unsetCName), permission: permission.PermAppUpdateCnameRemove, scope: appScope},
+	{version: "1.0", method: "POST", path: "/apps/{app}/run", handler: AuthorizationRequiredHandler(runCommand), permission: permission.PermAppRun, scope: appScope, skipAppLock: true},
+	{version: "1.0", method: "POST", path: "/apps/{app}/restart", handler: AuthorizationRequiredHandler(restart), permission: permission.PermAppUpdateRestart, scope: appScope},
+	{version: "1.0", method: "POST", path: "/apps/{app}/start", handler: AuthorizationRequiredHandler(start), permission: permission.PermAppUpdateStart, scope: appScope},
+	{version: "1.0", method: "POST", path: "/apps/{app}/stop", handler: AuthorizationRequiredHandler(stop), permission: permission.PermAppUpdateStop, scope: appScope},
+	{version: "1.0", method: "POST", path: "/apps/{app}/sleep", handler: AuthorizationRequiredHandler(sleep), permission: permission.PermAppUpdateSleep, scope: appScope},
+	{version: "1.0", method: "GET", path: "/apps/{appname}/quota", handler: AuthorizationRequiredHandler(getAppQuota), permission: permission.PermAppRead, scope: appScope},
+	{version: "1.0", method: "PUT", path: "/apps/{appname}/quota", handler: AuthorizationRequiredHandler(changeAppQuota), permission: permission.PermAppAdminQuota, scope: appScope},
+	// The permissions required to update an app and to read and set its envs
+	// depend on the request, so these handlers check them.
+	{version: "1.0", method: "PUT", path: "/apps/{appname}", handler: AuthorizationRequiredHandler(updateApp), request: inputApp{}},
+	{version: "1.0", method: "GET", path: "/apps/{app}/env", handler: AuthorizationRequiredHandler(getEnv), permission: permission.PermAppReadEnv},
+	{version: "1.0", method: "POST", path: "/apps/{app}/env", handler: AuthorizationRequiredHandler(setEnv), permission: permission.PermAppUpdateEnvSet},
+	{version: "1.0", method: "DELETE", path: "/apps/{app}/env", handler: AuthorizationRequiredHandler(unsetEnv), permission: permission.PermAppUpdateEnvUnset, scope: appScope},
+	{version: "1.0", method: "GET", path: "/apps", handler: AuthorizationRequiredHandler(appList), permission: permission.PermAppReadInfo},
+	{version: "1.0", method: "POST", path: "/apps", handler: AuthorizationRequiredHandler(createApp), permission: permission.PermAppCreate, request: inputApp{}},
+	{version: "1.0", method: "DELETE", path: "/apps/{app}/lock", handler: AuthorizationRequiredHandler(forceDeleteLock), permission: permission.PermAppAdminUnlock, scope: appScope, skipAppLock: true},
+	{version: "1.0", method: "PUT", path: "/apps/{app}/units", handler: AuthorizationRequiredHandler(addUnits), permission: permission.PermAppUpdateUnitAdd, scope: appScope},
+	{version: "1.0", method: "DELETE", path: "/apps/{app}/units", handler: AuthorizationRequiredHandler(removeUnits), permission: permission.PermAppUpdateUnitRemove, scope: appScope},
+	{version: "1.0", method: "POST", path: "/apps/{app}/units/register", handler: AuthorizationRequiredHandler(registerUnit), permission: permission.PermAppUpdateUnitRegister, skipAppLock: true},
+	{version: "1.0", method: "POST", path: "/apps/{app}/units/{unit}", handler: AuthorizationRequiredHandler(setUnitStatus), permission: permission.PermAppUpdateUnitStatus, skipAppLock: true},
+	{version: "1.6", method: "GET", path: "/apps/{app}/units/{unit}", handler: AuthorizationRequiredHandler(unitInfo), permission: permission.PermAppRead, scope: appScope, response: provision.UnitInfo{}},
+	{version: "1.6", method: "POST", path: "/apps/{app}/units/{unit}/restart", handler: AuthorizationRequiredHandler(unitRestart), permission: permission.PermAppUpdateRestart, scope: appScope},
+	{version: "1.6", method: "PUT", path: "/apps/{app}/units/{unit}/resources", handler: AuthorizationRequiredHandler(unitResourcesSet), permission: permission.PermAppAdminResources, scope: appScope},
+	{version: "1.0", method: "PUT", path: "/apps/{app}/teams/{team}", handler: AuthorizationRequiredHandler(grantAppAccess), permission: permission.PermAppUpdateGrant, scope: appScope},
+	{version: "1.0", method: "DELETE", path: "/apps/{app}/teams/{team}", handler: AuthorizationRequiredHandler(revokeAppAccess), permission: permission.PermAppUpdateRevoke, scope: appScope},
+	{version: "1.0", method: "GET", path: "/apps/{app}/log", handler: AuthorizationRequiredHandler(appLog), permission: permission.PermAppReadLog, scope: appScope},
+	{version: "1.0", method: "POST", path: "/apps/{app}/log", handler: AuthorizationRequiredHandler(addLog), permission: permission.PermAppUpdateLog, skipAppLock: true},
+	{version: "1.6", method: "GET", path: "/apps/{app}/log/stream", handler: &wsHandler{handle: appLogStream}, permission: permission.PermAppReadLog, response: app.Applog{}},
+	{version: "1.6", method: "GET", path: "/apps/{app}/log/drains", handler: AuthorizationRequiredHandler(logDrainList), permission: permission.PermAppReadLog, scope: appScope, response: []app.LogDrain{}},
+	{version: "1.6", method: "POST", path: "/apps/{app}/log/drains", handler: AuthorizationRequiredHandler(logDrainAdd), permission: permission.PermAppUpdateLogDrainAdd, scope: appScope, response: app.LogDrain{}},
+	{version: "1.6", method: "DELETE", path: "/apps/{app}/log/drains", handler: AuthorizationRequiredHandler(logDrainRemove), permission: permission.PermAppUpdateLogDrainRemove, scope: appScope},
+	{version: "1.0", method: "POST", path: "/apps/{appname}/deploy/rollback", handler: AuthorizationRequiredHandler(deployRollback), permission: permission.PermAppDeploy, skipAppLock: true},
+	{version: "1.4", method: "PUT", path: "/apps/{appname}/deploy/rollback/update", handler: AuthorizationRequiredHandler(deployRollbackUpdate), permission: permission.PermAppUpdateDeployRollback},
+	{version: "1.3", method: "POST", path: "/apps/{appname}/deploy/rebuild", handler: AuthorizationRequiredHandler(deployRebuild), permission: permission.PermAppDeploy, skipAppLock: true},
+	{version: "1.6", method: "GET", path: "/apps/{app}/deploys/{id}", handler: AuthorizationRequiredHandler(appDeployInfo), permission: permission.PermAppReadDeploy, scope: appScope, response: app.DeployData{}},
+	{version: "1.6", method: "GET", path: "/apps/{app}/deploy/queue", handler: AuthorizationRequiredHandler(deployQueueList), permission: permission.PermAppReadDeploy, scope: appScope, response: []app.QueuedDeploy{}},
+	{version: "1.6", method: "POST", path: "/apps/{app}/deploy/{id}/cancel", handler: AuthorizationRequiredHandler(deployCancel), permission: permission.PermAppUpdateDeployCancel, scope: appScope, skipAppLock: true},
+	{version: "1.6", method: "DELETE", path: "/apps/{app}/deploy/queue/{id}", handler: AuthorizationRequiredHandler(deployQueueCancel), permission: permission.PermAppUpdateDeployCancel, scope: appScope, skipAppLock: true},
+	{version: "1.6", method: "PUT", path: "/apps/{app}/deploy/webhook", handler: AuthorizationRequiredHandler(deployWebhookSet), permission: permission.PermAppUpdateDeployWebhook, scope: appScope, response: app.DeployWebhook{}},
+	{version: "1.6", method: "DELETE", path: "/apps/{app}/deploy/webhook", handler: AuthorizationRequiredHandler(deployWebhookRemove), permission: permission.PermAppUpdateDeployWebhook, scope: appScope},
+	{version: "1.6", method: "POST", path: "/apps/{app}/deploy/webhook", handler: Handler(deployWebhook), skipAppLock: true},
+	{version: "1.6", method: "PUT", path: "/apps/{app}/deploy/approval", handler: AuthorizationRequiredHandler(deployApprovalSet), permission: permission.PermAppUpdateDeployApproval, scope: appScope},
+	{version: "1.6", method: "GET", path: "/apps/{app}/deploy/approvals", handler: AuthorizationRequiredHandler(deployApprovalList), permission: permission.PermAppReadDeploy, scope: appScope, response: []app.DeployApproval{}},
+	{version: "1.6", method: "POST", path: "/apps/{app}/deploy/approvals/{id}/approve", handler: AuthorizationRequiredHandler(deployApprove), permission: permission.PermAppDeployApprove, scope: appScope, response: app.DeployApproval{}, skipAppLock: true},
+	{version: "1.6", method: "POST", path: "/apps/{app}/deploy/approvals/{id}/reject", handler: AuthorizationRequiredHandler(deployReject), permission: permission.PermAppDeployApprove, scope: appScope, response: app.DeployApproval{}, skipAppLock: true},
+	{version: "1.6", method: "POST", path: "/services/{service}/instances/{instance}/rotate", handler: AuthorizationRequiredHandler(rotateServiceInstanceCredentials), permission: permission.PermServiceInstanceUpdateRotate, scope: serviceInstanceScope},
+	{version: "1.6", method: "GET", path: "/maintenance", handler: AuthorizationRequiredHandler(maintenanceInfo), permission: permission.PermMaintenanceRead, response: maintenance.Mode{}},
+	{version: "1.6", method: "PUT", path: "/maintenance", handler: AuthorizationRequiredHandler(maintenanceEnable), permission: permission.PermMaintenanceUpdate, response: maintenance.Mode{}},
+	{version: "1.6", method: "DELETE", path: "/maintenance", handler: AuthorizationRequiredHandler(maintenanceDisable), permission: permission.PermMaintenanceUpdate},
+	{version: "1.6", method: "GET", path: "/maintenance/windows", handler: AuthorizationRequiredHandler(maintenanceWindowList), permission: permission.PermMaintenanceRead, response: []maintenance.Window{}},
+	{version: "1.6", method: "POST", path: "/maintenance/windows", handler: AuthorizationRequiredHandler(maintenanceWindowAdd), permission: permission.PermMaintenanceUpdate, request: maintenance.Window{}},
+	{version: "1.6", method: "DELETE", path: "/maintenance/windows/{name}", handler: AuthorizationRequiredHandler(maintenanceWindowRemove), permission: permission.PermMaintenanceUpdate},
+	{version: "1.6", method: "GET", path: "/naming/reservations", handler: AuthorizationRequiredHandler(nameReservationList), permission: permission.PermNamingRead, response: []naming.Reservation{}},
+	{version: "1.6", method: "POST", path: "/naming/reservations", handler: AuthorizationRequiredHandler(nameReservationAdd), permission: permission.PermNamingUpdate},
+	{version: "1.6", method: "DELETE", path: "/naming/reservations/{kind}/{name}", handler: AuthorizationRequiredHandler(nameReservationRemove), permission: permission.PermNamingUpdate},
+	{version: "1.6", method: "GET", path: "/naming/prefixes", handler: AuthorizationRequiredHandler(namingPrefixesList), permission: permission.PermNamingRead, response: []naming.TeamPrefixes{}},
+	{version: "1.6", method: "PUT", path: "/naming/prefixes/{team}", handler: AuthorizationRequiredHandler(namingPrefixesSet), permission: permission.PermNamingUpdate, request: naming.TeamPrefixes{}},
+	{version: "1.6", method: "GET", path: "/feature-flags", handler: AuthorizationRequiredHandler(featureFlagList), permission: permission.PermFeatureFlagRead, response: []featureflag.Flag{}},
+	{version: "1.6", method: "PUT", path: "/feature-flags/{name}", handler: AuthorizationRequiredHandler(featureFlagUpdate), permission: permission.PermFeatureFlagUpdate, request: featureflag.Flag{}},
+	{version: "1.6", method: "DELETE", path: "/feature-flags/{name}", handler: AuthorizationRequiredHandler(featureFlagDelete), permission: permission.PermFeatureFlagDelete},
+	{version: "1.6", method: "GET", path: "/usage", handler: AuthorizationRequiredHandler(usageReport), permission: permission.PermUsageRead, response: usageResponse{}},
+	{version: "1.6", method: "GET", path: "/apps/{app}/export", handler: AuthorizationRequiredHandler(appExport), permission: permission.PermAppReadEnv, scope: appScope, response: app.AppExport{}},
+	{version: "1.6", method: "POST", path: "/apps/import", handler: AuthorizationRequiredHandler(appImport), permission: permission.PermAppCreate, response: app.ImportResult{}},
+	{version: "1.6", method: "GET", path: "/apps/{app}/metrics", handler: AuthorizationRequiredHandler(appMetrics), permission: permission.PermAppReadMetric, scope: appScope, response: []metrics.Series{}},
+	{version: "1.6", method: "GET", path: "/orphans", handler: AuthorizationRequiredHandler(orphanList), permission: permission.PermOrphanRead, response: []app.Orphan{}},
+	{version: "1.6", method: "POST", path: "/orphans/cleanup", handler: AuthorizationRequiredHandler(orphanCleanup), permission: permission.PermOrphanCleanup, response: []app.Orphan{}},
+	{version: "1.6", method: "GET", path: "/envdrift", handler: AuthorizationRequiredHandler(envDriftList), permission: permission.PermAppAdminEnvdrift, response: []app.UnitEnvDrift{}},
+	{version: "1.6", method: "GET", path: "/app-templates", handler: AuthorizationRequiredHandler(appTemplateList), permission: permission.PermAppTemplateRead, response: []app.Template{}},
+	{version: "1.6", method: "GET", path: "/app-templates/{name}", handler: AuthorizationRequiredHandler(appTemplateInfo), permission: permission.PermAppTemplateRead, response: app.Template{}},
+	{version: "1.6", method: "PUT", path: "/app-templates/{name}", handler: AuthorizationRequiredHandler(appTemplateUpdate), permission: permission.PermAppTemplateUpdate},
+	{version: "1.6", method: "DELETE", path: "/app-templates/{name}", handler: AuthorizationRequiredHandler(appTemplateDelete), permission: permission.PermAppTemplateDelete},
+	{version: "1.6", method: "GET", path: "/projects", handler: AuthorizationRequiredHandler(projectList), permission: permission.PermProjectRead, response: []app.Project{}},
+	{version: "1.6", method: "POST", path: "/projects", handler: AuthorizationRequiredHandler(projectCreate), permission: permission.PermProjectCreate, request: app.Project{}},
+	{version: "1.6", method: "GET", path: "/projects/{name}", handler: AuthorizationRequiredHandler(projectInfoHandler), permission: permission.PermProjectRead, response: projectInfo{}},
+	{version: "1.6", method: "DELETE", path: "/projects/{name}", handler: AuthorizationRequiredHandler(projectDelete), permission: permission.PermProjectDelete},
+	{version: "1.6", method: "PUT", path: "/projects/{name}/apps/{app}", handler: AuthorizationRequiredHandler(projectAddApp), permission: permission.PermProjectUpdateApp},
+	{version: "1.6", method: "DELETE", path: "/projects/{name}/apps/{app}", handler: AuthorizationRequiredHandler(projectRemoveApp), permission: permission.PermProjectUpdateApp},
+	{version: "1.6", method: "PUT", path: "/projects/{name}/service-instances/{service}/{instance}", handler: AuthorizationRequiredHandler(projectAddServiceInstance), permission: permission.PermProjectUpdateServiceInstance},
+	{version: "1.6", method: "DELETE", path: "/projects/{name}/service-instances/{service}/{instance}", handler: AuthorizationRequiredHandler(projectRemoveServiceInstance), permission: permission.PermProjectUpdateServiceInstance},
+	{version: "1.6", method: "POST", path: "/projects/{name}/env", handler: AuthorizationRequiredHandler(projectSetEnvs), permission: permission.PermProjectUpdateEnv},
+	{version: "1.6", method: "PUT", path: "/projects/{name}/teams/{team}", handler: AuthorizationRequiredHandler(projectGrant), permission: permission.PermProjectUpdateGrant},
+	{version: "1.6", method: "DELETE", path: "/projects/{name}/teams/{team}", handler: AuthorizationRequiredHandler(projectRevoke), permission: permission.PermProjectUpdateRevoke},
+	{version: "1.6", method: "GET", path: "/projects/{name}/events", handler: AuthorizationRequiredHandler(projectEvents), permission: permission.PermProjectReadEvents, response: []event.Event{}},
+	{version: "1.6", method: "GET", path: "/projects/{name}/log", handler: AuthorizationRequiredHandler(projectLog), permission: permission.PermProjectRead, response: []app.Applog{}},
+	{version: "1.6", method: "GET", path: "/projects/{name}/usage", handler: AuthorizationRequiredHandler(projectUsage), permission: permission.PermProjectRead, response: app.ProjectUsage{}},
+	{version: "1.6", method: "GET", path: "/teams/{name}/policy", handler: AuthorizationRequiredHandler(teamPolicyInfo), permission: permission.PermTeamPolicyRead, scope: teamScope, response: app.TeamPolicy{}},
+	{version: "1.6", method: "PUT", path: "/teams/{name}/policy", handler: AuthorizationRequiredHandler(teamPolicyUpdate), permission: permission.PermTeamPolicyUpdate, scope: teamScope},
+	{version: "1.6", method: "DELETE", path: "/teams/{name}/policy", handler: AuthorizationRequiredHandler(teamPolicyDelete), permission: permission.PermTeamPolicyDelete, scope: teamScope},
+	{version: "1.6", method: "GET", path: "/teams/{name}/deploy-freeze", handler: AuthorizationRequiredHandler(deployFreezeInfo), permission: permission.PermTeamDeployFreezeRead, scope: teamScope, response: app.DeployFreeze{}},
+	{version: "1.6", method: "PUT", path: "/teams/{name}/deploy-freeze", handler: AuthorizationRequiredHandler(deployFreezeUpdate), permission: permission.PermTeamDeployFreezeUpdate, scope: teamScope},
+	{version: "1.6", method: "DELETE", path: "/teams/{name}/deploy-freeze", handler: AuthorizationRequiredHandler(deployFreezeDelete), permission: permission.PermTeamDeployFreezeDelete, scope: teamScope},
+	{version: "1.6", method: "GET", path: "/apps/{app}/secrets", handler: AuthorizationRequiredHandler(listSecrets), permission: permission.PermAppReadEnv, scope: appScope, response: []string{}},
+	{version: "1.6", method: "POST", path: "/apps/{app}/secrets", handler: AuthorizationRequiredHandler(setSecrets), permission: permission.PermAppUpdateEnvSet, scope: appScope},
+	{version: "1.6", method: "DELETE", path: "/apps/{app}/secrets", handler: AuthorizationRequiredHandler(unsetSecrets), permission: permission.PermAppUpdateEnvUnset, scope: appScope},
+	{version: "1.0", method: "GET", path: "/apps/{app}/metric/envs", handler: AuthorizationRequiredHandler(appMetricEnvs), permission: permission.PermAppReadMetric, scope: appScope},
+	{version: "1.0", method: "POST", path: "/apps/{app}/routes", handler: AuthorizationRequiredHandler(appRebuildRoutes), permission: permission.PermAppAdminRoutes, scope: appScope},
+	{version: "1.2", method: "GET", path: "/apps/{app}/certificate", handler: AuthorizationRequiredHandler(listCertificates), permission: permission.PermAppReadCertificate, scope: appScope},
+	{version: "1.2", method: "PUT", path: "/apps/{app}/certificate", handler: AuthorizationRequiredHandler(setCertificate), permission: permission.PermAppUpdateCertificateSet, scope: appScope},
+	{version: "1.2", method: "DELETE", path: "/apps/{app}/certificate", handler: AuthorizationRequiredHandler(unsetCertificate), permission: permission.PermAppUpdateCertificateUnset, scope: appScope},
+
+	{version: "1.5", method: "POST", path: "/apps/{app}/routers", handler: AuthorizationRequiredHandler(addAppRouter), permission: permission.PermAppUpdateRouterAdd, scope: appScope, request: appTypes.AppRouter{}},
+	{version: "1.5", method: "PUT", path: "/apps/{app}/routers/{router}", handler: AuthorizationRequiredHandler(updateAppRouter), permission: permission.PermAppUpdateRouterUpdate, scope: appScope, request: appTypes.AppRouter{}},
+	{version: "1.5", method: "DELETE", path: "/apps/{app}/routers/{router}", handler: AuthorizationRequiredHandler(removeAppRouter), permission: permission.PermAppUpdateRouterRemove, scope: appScope},
+	{version: "1.5", method: "GET", path: "/apps/{app}/routers", handler: AuthorizationRequiredHandler(listAppRouters), permission: permission.PermAppReadRouter, scope: appScope, response: []appTypes.AppRouter{}},
+	{version: "1.6", method: "GET", path: "/apps/{app}/jobs", handler: AuthorizationRequiredHandler(jobList), permission: permission.PermAppReadJob, scope: appScope, response: []job.Job{}},
+	{version: "1.6", method: "POST", path: "/apps/{app}/jobs", handler: AuthorizationRequiredHandler(jobCreate), permission: permission.PermAppUpdateJobAdd, scope: appScope, request: inputJob{}, response: job.Job{}},
+	{version: "1.6", method: "DELETE", path: "/apps/{app}/jobs/{job}", handler: AuthorizationRequiredHandler(jobRemove), permission: permission.PermAppUpdateJobRemove, scope: appScope},
+	{version: "1.6", method: "POST", path: "/apps/{app}/jobs/{job}/run", handler: AuthorizationRequiredHandler(jobRun), permission: permission.PermAppUpdateJobRun, scope: appScope, response: job.Run{}},
+	{version: "1.6", method: "GET", path: "/apps/{app}/jobs/{job}/runs", handler: AuthorizationRequiredHandler(jobRunList), permission: permission.PermAppReadJob, scope: appScope, response: []job.Run{}},
+	{version: "1.6", method: "GET", path: "/apps/{app}/jobs/{job}/runs/{id}", handler: AuthorizationRequiredHandler(jobRunInfo), permission: permission.PermAppReadJob, scope: appScope, response: job.Run{}},
+
+	{version: "1.0", method: "POST", path: "/node/status", handler: AuthorizationRequiredHandler(setNodeStatus)},
+
+	{version: "1.0", method: "GET", path: "/deploys", handler: AuthorizationRequiredHandler(deploysList), permission: permission.PermAppReadDeploy, response: []app.DeployData{}},
+	{version: "1.0", method: "GET", path: "/deploys/{deploy}", handler: AuthorizationRequiredHandler(deployInfo), permission: permission.PermAppReadDeploy},
+
+	{version: "1.1", method: "GET", path: "/events", handler: AuthorizationRequiredHandler(eventList)},
+	{version: "1.3", method: "GET", path: "/events/blocks", handler: AuthorizationRequiredHandler(eventBlockList), permission: permission.PermEventBlockRead, response: []event.Block{}},
+	{version: "1.3", method: "POST", path: "/events/blocks", handler: AuthorizationRequiredHandler(eventBlockAdd), permission: permission.PermEventBlockAdd, request: event.Block{}},
+	{version: "1.3", method: "DELETE", path: "/events/blocks/{uuid}", handler: AuthorizationRequiredHandler(eventBlockRemove), permission: permission.PermEventBlockRemove},
+	{version: "1.6", method: "POST", path: "/events/prune", handler: AuthorizationRequiredHandler(eventPrune), permission: permission.PermEventRetentionPrune, response: event.PruneResult{}},
+	{version: "1.6", method: "GET", path: "/queue/workers", handler: AuthorizationRequiredHandler(queueWorkerList), permission: permission.PermQueueRead, response: []queue.WorkerPool{}},
+	{version: "1.6", method: "GET", path: "/queue/jobs", handler: AuthorizationRequiredHandler(queueJobList), permission: permission.PermQueueRead, response: []queue.MessageInfo{}},
+	{version: "1.6", method: "POST", path: "/queue/jobs/{id}/retry", handler: AuthorizationRequiredHandler(queueJobRetry), permission: permission.PermQueueUpdateRetry},
+	{version: "1.6", method: "DELETE", path: "/queue/jobs/{id}", handler: AuthorizationRequiredHandler(queueJobDiscard), permission: permission.PermQueueUpdateDiscard},
+	{version: "1.6", method: "GET", path: "/events/stream", handler: &wsHandler{handle: eventStream}},
+	{version: "1.1", method: "GET", path: "/events/kinds", handler: AuthorizationRequiredHandler(kindList)},
+	{version: "1.1", method: "GET", path: "/events/{uuid}", handler: AuthorizationRequiredHandler(eventInfo)},
+	{version: "1.1", method: "POST", path: "/events/{uuid}/cancel", handler: AuthorizationRequiredHandler(eventCancel)},
+
+	{version: "1.0", method: "GET", path: "/platforms", handler: AuthorizationRequiredHandler(platformList), response: []appTypes.Platform{}},
+	{version: "1.0", method: "POST", path: "/platforms", handler: AuthorizationRequiredHandler(platformAdd), permission: permission.PermPlatformCreate},
+	{version: "1.0", method: "PUT", path: "/platforms/{name}", handler: AuthorizationRequiredHandler(platformUpdate), permission: permission.PermPlatformUpdate},
+	{version: "1.0", method: "DELETE", path: "/platforms/{name}", handler: AuthorizationRequiredHandler(platformRemove), permission: permission.PermPlatformDelete},
+
+	// These handlers don't use {app} on purpose. Using :app means that only
+	// the token generate for the given app is valid, but these handlers
+	// use a token generated for Gandalf.
+	{version: "1.0", method: "POST", path: "/apps/{appname}/repository/clone", handler: AuthorizationRequiredHandler(deploy), permission: permission.PermAppDeploy, skipAppLock: true},
+	{version: "1.0", method: "POST", path: "/apps/{appname}/deploy", handler: AuthorizationRequiredHandler(deploy), permission: permission.PermAppDeploy, skipAppLock: true},
+	{version: "1.0", method: "POST", path: "/apps/{appname}/diff", handler: AuthorizationRequiredHandler(diffDeploy), permission: permission.PermAppReadDeploy, skipAppLock: true},
+	{version: "1.5", method: "POST", path: "/apps/{appname}/build", handler: AuthorizationRequiredHandler(build), permission: permission.PermAppBuild, skipAppLock: true},
+
+	// Shell also doesn't use {app} on purpose. Middlewares don't play well
+	// with websocket.
+	{version: "1.0", method: "GET", path: "/apps/{appname}/shell", handler: &wsHandler{raw: true, handle: remoteShellHandler}, permission: permission.PermAppRunShell},
+
+	{version: "1.0", method: "GET", path: "/users", handler: AuthorizationRequiredHandler(listUsers)},
+	{version: "1.0", method: "POST", path: "/users", handler: Handler(createUser), permission: permission.PermUserCreate},
+	{version: "1.0", method: "GET", path: "/users/info", handler: AuthorizationRequiredHandler(userInfo)},
+	{version: "1.0", method: "GET", path: "/auth/scheme", handler: Handler(authScheme)},
+	{version: "1.0", method: "POST", path: "/auth/login", handler: Handler(login), skipMaintenance: true},
+
+	{version: "1.0", method: "POST", path: "/auth/saml", handler: Handler(samlCallbackLogin), skipMaintenance: true},
+	{version: "1.0", method: "GET", path: "/auth/saml", handler: Handler(samlMetadata)},
+
+	{version: "1.0", method: "POST", path: "/users/{email}/password", handler: Handler(resetPassword), permission: permission.PermUserUpdateReset},
+	{version: "1.0", method: "POST", path: "/users/{email}/tokens", handler: Handler(login), skipMaintenance: true},
+	{version: "1.0", method: "GET", path: "/users/{email}/quota", handler: AuthorizationRequiredHandler(getUserQuota), permission: permission.PermUserUpdateQuota},
+	{version: "1.0", method: "PUT", path: "/users/{email}/quota", handler: AuthorizationRequiredHandler(changeUserQuota), permission: permission.PermUserUpdateQuota},
+	{version: "1.0", method: "DELETE", path: "/users/tokens", handler: AuthorizationRequiredHandler(logout)},
+	{version: "1.0", method: "PUT", path: "/users/password", handler: AuthorizationRequiredHandler(changePassword), permission: permission.PermUserUpdatePassword},
+	{version: "1.0", method: "DELETE", path: "/users", handler: AuthorizationRequiredHandler(removeUser), permission: permission.PermUserDelete},
+	{version: "1.0", method: "GET", path: "/users/keys", handler: AuthorizationRequiredHandler(listKeys)},
+	{version: "1.0", method: "POST", path: "/users/keys", handler: AuthorizationRequiredHandler(addKeyToUser), permission: permission.PermUserUpdateKeyAdd},
+	{version: "1.0", method: "DELETE", path: "/users/keys/{key}", handler: AuthorizationRequiredHandler(removeKeyFromUser), permission: permission.PermUserUpdateKeyRemove},
+	{version: "1.0", method: "GET", path: "/users/api-key", handler: AuthorizationRequiredHandler(showAPIToken), permission: permission.PermUserUpdateToken},
+	{version: "1.0", method: "POST", path: "/users/api-key", handler: AuthorizationRequiredHandler(regenerateAPIToken), permission: permission.PermUserUpdateToken},
+
+	{version: "1.0", method: "GET", path: "/logs", handler: &wsHandler{handle: addLogs}},
+
+	{version: "1.0", method: "GET", path: "/teams", handler: AuthorizationRequiredHandler(teamList)},
+	{version: "1.0", method: "POST", path: "/teams", handler: AuthorizationRequiredHandler(createTeam), permission: permission.PermTeamCreate},
+	{version: "1.0", method: "DELETE", path: "/teams/{name}", handler: AuthorizationRequiredHandler(removeTeam), permission: permission.PermTeamDelete, scope: hiddenTeamScope},
+	{version: "1.4", method: "POST", path: "/teams/{name}", handler: AuthorizationRequiredHandler(updateTeam), permission: permission.PermTeamUpdate, scope: teamScope},
+
+	{version: "1.0", method: "POST", path: "/swap", handler: AuthorizationRequiredHandler(swap), permission: permission.PermAppUpdateSwap},
+
+	{version: "1.0", method: "GET", path: "/healthcheck/", handler: http.HandlerFunc(healthcheck)},
+	{version: "1.0", method: "GET", path: "/healthcheck", handler: http.HandlerFunc(healthcheck)},
+
+	{version: "1.0", method: "GET", path: "/iaas/machines", handler: AuthorizationRequiredHandler(machinesList), permission: permission.PermMachineRead, response: []iaas.Machine{}},
+	{version: "1.0", method: "DELETE", path: "/iaas/machines/{machine_id}", handler: AuthorizationRequiredHandler(machineDestroy), permission: permission.PermMachineDelete},
+	{version: "1.0", method: "GET", path: "/iaas/templates", handler: AuthorizationRequiredHandler(templatesList), permission: permission.PermMachineTemplateRead, response: []iaas.Template{}},
+	{version: "1.0", method: "POST", path: "/iaas/templates", handler: AuthorizationRequiredHandler(templateCreate), permission: permission.PermMachineTemplateCreate, request: iaas.Template{}},
+	{version: "1.0", method: "PUT", path: "/iaas/templates/{template_name}", handler: AuthorizationRequiredHandler(templateUpdate), permission: permission.PermMachineTemplateUpdate},
+	{version: "1.0", method: "DELETE", path: "/iaas/templates/{template_name}", handler: AuthorizationRequiredHandler(templateDestroy), permission: permission.PermMachineTemplateDelete},
+
+	{version: "1.0", method: "GET", path: "/plans", handler: AuthorizationRequiredHandler(listPlans), response: []appTypes.Plan{}},
+	{version: "1.0", method: "POST", path: "/plans", handler: AuthorizationRequiredHandler(addPlan), permission: permission.PermPlanCreate},
+	{version: "1.0", method: "DELETE", path: "/plans/{planname}", handler: AuthorizationRequiredHandler(removePlan), permission: permission.PermPlanDelete},
+
+	{version: "1.0", method: "GET", path: "/pools", handler: AuthorizationRequiredHandler(poolList), response: []pool.Pool{}},
+	{version: "1.0", method: "POST", path: "/pools", handler: AuthorizationRequiredHandler(addPoolHandler), permission: permission.PermPoolCreate, request: pool.AddPoolOptions{}},
+	{version: "1.0", method: "DELETE", path: "/pools/{name}", handler: AuthorizationRequiredHandler(removePoolHandler), permission: permission.PermPoolDelete, scope: poolScope},
+	{version: "1.0", method: "PUT", path: "/pools/{name}", handler: AuthorizationRequiredHandler(poolUpdateHandler), permission: permission.PermPoolUpdate, scope: poolScope, request: pool.UpdatePoolOptions{}},
+	{version: "1.0", method: "POST", path: "/pools/{name}/team", handler: AuthorizationRequiredHandler(addTeamToPoolHandler), permission: permission.PermPoolUpdateTeamAdd, scope: poolScope},
+	{version: "1.0", method: "DELETE", path: "/pools/{name}/team", handler: AuthorizationRequiredHandler(removeTeamToPoolHandler), permission: permission.PermPoolUpdateTeamRemove, scope: poolScope},
+
+	{version: "1.3", method: "GET", path: "/constraints", handler: AuthorizationRequiredHandler(poolConstraintList), permission: permission.PermPoolReadConstraints, response: []pool.PoolConstraint{}},
+	{version: "1.3", method: "PUT", path: "/constraints", handler: AuthorizationRequiredHandler(poolConstraintSet), permission: permission.PermPoolUpdateConstraintsSet, request: pool.PoolConstraint{}},
+
+	{version: "1.0", method: "GET", path: "/roles", handler: AuthorizationRequiredHandler(listRoles), response: []permission.Role{}},
+	{version: "1.4", method: "PUT", path: "/roles", handler: AuthorizationRequiredHandler(roleUpdate)},
+	{version: "1.0", method: "POST", path: "/roles", handler: AuthorizationRequiredHandler(addRole), permission: permission.PermRoleCreate},
+	{version: "1.0", method: "GET", path: "/roles/{name}", handler: AuthorizationRequiredHandler(roleInfo)},
+	{version: "1.0", method: "DELETE", path: "/roles/{name}", handler: AuthorizationRequiredHandler(removeRole), permission: permission.PermRoleDelete},
+	{version: "1.0", method: "POST", path: "/roles/{name}/permissions", handler: AuthorizationRequiredHandler(addPermissions), permission: permission.PermRoleUpdatePermissionAdd},
+	{version: "1.0", method: "DELETE", path: "/roles/{name}/permissions/{permission}", handler: AuthorizationRequiredHandler(removePermissions), permission: permission.PermRoleUpdatePermissionRemove},
+	{version: "1.0", method: "POST", path: "/roles/{name}/user", handler: AuthorizationRequiredHandler(assignRole), permission: permission.PermRoleUpdateAssign},
+	{version: "1.0", method: "DELETE", path: "/roles/{name}/user/{email}", handler: AuthorizationRequiredHandler(dissociateRole), permission: permission.PermRoleUpdateDissociate},
+	{version: "1.0", method: "GET", path: "/role/default", handler: AuthorizationRequiredHandler(listDefaultRoles)},
+	{version: "1.0", method: "POST", path: "/role/default", handler: AuthorizationRequiredHandler(addDefaultRole), permission: permission.PermRoleDefaultCreate},
+	{version: "1.0", method: "DELETE", path: "/role/default", handler: AuthorizationRequiredHandler(removeDefaultRole), permission: permission.PermRoleDefaultDelete},
+	{version: "1.0", method: "GET", path: "/permissions", handler: AuthorizationRequiredHandler(listPermissions)},
+
+	{version: "1.0", method: "GET", path: "/debug/goroutines", handler: AuthorizationRequiredHandler(dumpGoroutines), permission: permission.PermDebug},
+	{version: "1.6", method: "GET", path: "/debug/log-levels", handler: AuthorizationRequiredHandler(logLevelsList), permission: permission.PermDebug, response: logLevels{}},
+	{version: "1.6", method: "POST", path: "/debug/log-levels", handler: AuthorizationRequiredHandler(logLevelsSet), permission: permission.PermDebug, response: logLevels{}},
+	{version: "1.0", method: "GET", path: "/debug/pprof/", handler: AuthorizationRequiredHandler(indexHandler), permission: permission.PermDebug},
+	{version: "1.0", method: "GET", path: "/debug/pprof/cmdline", handler: AuthorizationRequiredHandler(cmdlineHandler), permission: permission.PermDebug},
+	{version: "1.0", method: "GET", path: "/debug/pprof/profile", handler: AuthorizationRequiredHandler(profileHandler), permission: permission.PermDebug},
+	{version: "1.0", method: "GET", path: "/debug/pprof/symbol", handler: AuthorizationRequiredHandler(symbolHandler), permission: permission.PermDebug},
+	{version: "1.0", method: "GET", path: "/debug/pprof/heap", handler: AuthorizationRequiredHandler(indexHandler), permission: permission.PermDebug},
+	{version: "1.0", method: "GET", path: "/debug/pprof/goroutine", handler: AuthorizationRequiredHandler(indexHandler), permission: permission.PermDebug},
+	{version: "1.0", method: "GET", path: "/debug/pprof/threadcreate", handler: AuthorizationRequiredHandler(indexHandler), permission: permission.PermDebug},
+	{version: "1.0", method: "GET", path: "/debug/pprof/block", handler: AuthorizationRequiredHandler(indexHandler), permission: permission.PermDebug},
+	{version: "1.0", method: "GET", path: "/debug/pprof/trace", handler: AuthorizationRequiredHandler(traceHandler), permission: permission.PermDebug},
+
+	{version: "1.3", method: "GET", path: "/node/autoscale", handler: AuthorizationRequiredHandler(autoScaleHistoryHandler)},
+	{version: "1.3", method: "GET", path: "/node/autoscale/config", handler: AuthorizationRequiredHandler(autoScaleGetConfig), permission: permission.PermNodeAutoscaleRead},
+	{version: "1.3", method: "POST", path: "/node/autoscale/run", handler: AuthorizationRequiredHandler(autoScaleRunHandler), permission: permission.PermNodeAutoscaleUpdateRun},
+	{version: "1.3", method: "GET", path: "/node/autoscale/rules", handler: AuthorizationRequiredHandler(autoScaleListRules), permission: permission.PermNodeAutoscaleRead, response: []autoscale.Rule{}},
+	{version: "1.3", method: "POST", path: "/node/autoscale/rules", handler: AuthorizationRequiredHandler(autoScaleSetRule), permission: permission.PermNodeAutoscaleUpdate, request: autoscale.Rule{}},
+	{version: "1.3", method: "DELETE", path: "/node/autoscale/rules", handler: AuthorizationRequiredHandler(autoScaleDeleteRule)},
+	{version: "1.3", method: "DELETE", path: "/node/autoscale/rules/{id}", handler: AuthorizationRequiredHandler(autoScaleDeleteRule)},
+
+	{version: "1.2", method: "GET", path: "/node", handler: AuthorizationRequiredHandler(listNodesHandler)},
+	{version: "1.2", method: "GET", path: "/node/apps/{appname}/containers", handler: AuthorizationRequiredHandler(listUnitsByApp), permission: permission.PermAppRead},
+	{version: "1.2", method: "GET", path: "/node/{address:.*}/containers", handler: AuthorizationRequiredHandler(listUnitsByNode), permission: permission.PermNodeRead},
+	{version: "1.2", method: "POST", path: "/node", handler: AuthorizationRequiredHandler(addNodeHandler), permission: permission.PermNodeCreate},
+	{version: "1.2", method: "PUT", path: "/node", handler: AuthorizationRequiredHandler(updateNodeHandler), permission: permission.PermNodeUpdate},
+	{version: "1.2", method: "DELETE", path: "/node/{address:.*}", handler: AuthorizationRequiredHandler(removeNodeHandler), permission: permission.PermNodeDelete},
+	{version: "1.3", method: "POST", path: "/node/rebalance", handler: AuthorizationRequiredHandler(rebalanceNodesHandler), permission: permission.PermNodeUpdateRebalance},
+
+	{version: "1.2", method: "GET", path: "/nodecontainers", handler: AuthorizationRequiredHandler(nodeContainerList), permission: permission.PermNodecontainerRead},
+	{version: "1.2", method: "POST", path: "/nodecontainers", handler: AuthorizationRequiredHandler(nodeContainerCreate), permission: permission.PermNodecontainerCreate},
+	{version: "1.2", method: "GET", path: "/nodecontainers/{name}", handler: AuthorizationRequiredHandler(nodeContainerInfo), permission: permission.PermNodecontainerRead},
+	{version: "1.2", method: "DELETE", path: "/nodecontainers/{name}", handler: AuthorizationRequiredHandler(nodeContainerDelete), permission: permission.PermNodecontainerDelete},
+	{version: "1.2", method: "POST", path: "/nodecontainers/{name}", handler: AuthorizationRequiredHandler(nodeContainerUpdate), permission: permission.PermNodecontainerUpdate},
+	{version: "1.2", method: "POST", path: "/nodecontainers/{name}/upgrade", handler: AuthorizationRequiredHandler(nodeContainerUpgrade), permission: permission.PermNodecontainerUpdateUpgrade},
+
+	{version: "1.2", method: "POST", path: "/install/hosts", handler: AuthorizationRequiredHandler(installHostAdd), permission: permission.PermInstallManage, request: install.Host{}},
+	{version: "1.2", method: "GET", path: "/install/hosts", handler: AuthorizationRequiredHandler(installHostList), permission: permission.PermInstallManage, response: []install.Host{}},
+	{version: "1.2", method: "GET", path: "/install/hosts/{name}", handler: AuthorizationRequiredHandler(installHostInfo), permission: permission.PermInstallManage, response: install.Host{}},
+
+	{version: "1.2", method: "GET", path: "/healing/node", handler: AuthorizationRequiredHandler(nodeHealingRead), permission: permission.PermHealingRead},
+	{version: "1.2", method: "POST", path: "/healing/node", handler: AuthorizationRequiredHandler(nodeHealingUpdate), permission: permission.PermHealingUpdate},
+	{version: "1.2", method: "DELETE", path: "/healing/node", handler: AuthorizationRequiredHandler(nodeHealingDelete), permission: permission.PermHealingDelete},
+	{version: "1.3", method: "GET", path: "/healing", handler: AuthorizationRequiredHandler(healingHistoryHandler), permission: permission.PermHealingRead},
+	{version: "1.3", method: "GET", path: "/routers", handler: AuthorizationRequiredHandler(listRouters)},
+	{version: "1.2", method: "GET", path: "/metrics", handler: promhttp.Handler()},
+
+	{version: "1.3", method: "POST", path: "/provisioner/clusters", handler: AuthorizationRequiredHandler(createCluster), permission: permission.PermClusterCreate, request: cluster.Cluster{}},
+	{version: "1.4", method: "POST", path: "/provisioner/clusters/{name}", handler: AuthorizationRequiredHandler(updateCluster), permission: permission.PermClusterUpdate, request: cluster.Cluster{}},
+	{version: "1.3", method: "GET", path: "/provisioner/clusters", handler: AuthorizationRequiredHandler(listClusters), permission: permission.PermClusterRead, response: []cluster.Cluster{}},
+	{version: "1.3", method: "DELETE", path: "/provisioner/clusters/{name}", handler: AuthorizationRequiredHandler(deleteCluster), permission: permission.PermClusterDelete},
+
+	{version: "1.4", method: "GET", path: "/volumes", handler: AuthorizationRequiredHandler(volumesList), permission: permission.PermVolumeRead, response: []volume.Volume{}},
+	{version: "1.4", method: "GET", path: "/volumes/{name}", handler: AuthorizationRequiredHandler(volumeInfo), permission: permission.PermVolumeRead, response: volume.Volume{}},
+	{version: "1.4", method: "DELETE", path: "/volumes/{name}", handler: AuthorizationRequiredHandler(volumeDelete), permission: permission.PermVolumeDelete},
+	{version: "1.4", method: "POST", path: "/volumes", handler: AuthorizationRequiredHandler(volumeCreate), permission: permission.PermVolumeCreate, request: volume.Volume{}},
+	{version: "1.4", method: "POST", path: "/volumes/{name}", handler: AuthorizationRequiredHandler(volumeUpdate), permission: permission.PermVolumeUpdate, request: volume.Volume{}},
+	{version: "1.4", method: "POST", path: "/volumes/{name}/bind", handler: AuthorizationRequiredHandler(volumeBind), permission: permission.PermVolumeUpdateBind},
+	{version: "1.4", method: "DELETE", path: "/volumes/{name}/bind", handler: AuthorizationRequiredHandler(volumeUnbind), permission: permission.PermVolumeUpdateUnbind},
+	{version: "1.4", method: "GET", path: "/volumeplans", handler: AuthorizationRequiredHandler(volumePlansList), permission: permission.PermVolumeCreate},
+
+	// Handlers for compatibility reasons, should be removed on tsuru 2.0.
+	{version: "1.0", method: "GET", path: "/docker/node", handler: AuthorizationRequiredHandler(listNodesHandler), deprecated: true},
+	{version: "1.0", method: "GET", path: "/docker/node/apps/{appname}/containers", handler: AuthorizationRequiredHandler(listUnitsByApp), permission: permission.PermAppRead, deprecated: true},
+	{version: "1.0", method: "GET", path: "/docker/node/{address:.*}/containers", handler: AuthorizationRequiredHandler(listUnitsByNode), permission: permission.PermNodeRead, deprecated: true},
+	{version: "1.0", method: "POST", path: "/docker/node", handler: AuthorizationRequiredHandler(addNodeHandler), permission: permission.PermNodeCreate, deprecated: true},
+	{version: "1.0", method: "PUT", path: "/docker/node", handler: AuthorizationRequiredHandler(updateNodeHandler), permission: permission.PermNodeUpdate, deprecated: true},
+	{version: "1.0", method: "DELETE", path: "/docker/node/{address:.*}", handler: AuthorizationRequiredHandler(removeNodeHandler), permission: permission.PermNodeDelete, deprecated: true},
+	{version: "1.0", method: "POST", path: "/docker/containers/rebalance", handler: AuthorizationRequiredHandler(rebalanceNodesHandler), permission: permission.PermNodeUpdateRebalance, deprecated: true},
+
+	{version: "1.0", method: "GET", path: "/docker/nodecontainers", handler: AuthorizationRequiredHandler(nodeContainerList), permission: permission.PermNodecontainerRead, deprecated: true},
+	{version: "1.0", method: "POST", path: "/docker/nodecontainers", handler: AuthorizationRequiredHandler(nodeContainerCreate), permission: permission.PermNodecontainerCreate, deprecated: true},
+	{version: "1.0", method: "GET", path: "/docker/nodecontainers/{name}", handler: AuthorizationRequiredHandler(nodeContainerInfo), permission: permission.PermNodecontainerRead, deprecated: true},
+	{version: "1.0", method: "DELETE", path: "/docker/nodecontainers/{name}", handler: AuthorizationRequiredHandler(nodeContainerDelete), permission: permission.PermNodecontainerDelete, deprecated: true},
+	{version: "1.0", method: "POST", path: "/docker/nodecontainers/{name}", handler: AuthorizationRequiredHandler(nodeContainerUpdate), permission: permission.PermNodecontainerUpdate, deprecated: true},
+	{version: "1.0", method: "POST", path: "/docker/nodecontainers/{name}/upgrade", handler: AuthorizationRequiredHandler(nodeContainerUpgrade), permission: permission.PermNodecontainerUpdateUpgrade, deprecated: true},
+
+	{version: "1.0", method: "GET", path: "/docker/healing/node", handler: AuthorizationRequiredHandler(nodeHealingRead), permission: permission.PermHealingRead, deprecated: true},
+	{version: "1.0", method: "POST", path: "/docker/healing/node", handler: AuthorizationRequiredHandler(nodeHealingUpdate), permission: permission.PermHealingUpdate, deprecated: true},
+	{version: "1.0", method: "DELETE", path: "/docker/healing/node", handler: AuthorizationRequiredHandler(nodeHealingDelete), permission: permission.PermHealingDelete, deprecated: true},
+	{version: "1.0", method: "GET", path: "/docker/healing", handler: AuthorizationRequiredHandler(healingHistoryHandler), permission: permission.PermHealingRead, deprecated: true},
+
+	{version: "1.0", method: "GET", path: "/docker/autoscale", handler: AuthorizationRequiredHandler(autoScaleHistoryHandler), deprecated: true},
+	{version: "1.0", method: "GET", path: "/docker/autoscale/config", handler: AuthorizationRequiredHandler(autoScaleGetConfig), permission: permission.PermNodeAutoscaleRead, deprecated: true},
+	{version: "1.0", method: "POST", path: "/docker/autoscale/run", handler: AuthorizationRequiredHandler(autoScaleRunHandler), permission: permission.PermNodeAutoscaleUpdateRun, deprecated: true},
+	{version: "1.0", method: "GET", path: "/docker/autoscale/rules", handler: AuthorizationRequiredHandler(autoScaleListRules), permission: permission.PermNodeAutoscaleRead, response: []autoscale.Rule{}, deprecated: true},
+	{version: "1.0", method: "POST", path: "/docker/autoscale/rules", handler: AuthorizationRequiredHandler(autoScaleSetRule), permission: permission.PermNodeAutoscaleUpdate, request: autoscale.Rule{}, deprecated: true},
+	{version: "1.0", method: "DELETE", path: "/docker/autoscale/rules", handler: AuthorizationRequiredHandler(autoScaleDeleteRule), deprecated: true},
+	{version: "1.0", method: "DELETE", path: "/docker/autoscale/rules/{id}", handler: AuthorizationRequiredHandler(autoScaleDeleteRule), deprecated: true},
+
+	{version: "1.0", method: "GET", path: "/plans/routers", handler: AuthorizationRequiredHandler(listRouters), deprecated: true},
+}
+
+// apiRoutes returns the complete list of routes served by the API, including
+// the ones registered by other packages through RegisterHandler.
+func apiRoutes() []route {
+	var routes []route
+	for _, handler := range tsuruHandlerList {
+		routes = append(routes, route{
+			version: handler.version,
+			method:  handler.method,
+			path:    handler.path,
+			handler: handler.h,
+		})
+	}
+	if disableIndex, _ := config.GetBool("disable-index-page"); !disableIndex {
+		routes = append(routes, route{version: "1.0", method: "GET", path: "/", handler: Handler(index)})
+	}
+	routes = append(routes, routeTable...)
+	swagger := &swaggerHandler{}
+	routes = append(routes, route{version: "1.0", method: "GET", path: "/swagger.json", handler: swagger})
+	swagger.routes = routes
+	return routes
+}
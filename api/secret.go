@@ -0,0 +1,142 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ajg/form"
+	"github.com/tsuru/tsuru/api/types"
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	tsuruIo "github.com/tsuru/tsuru/io"
+	"github.com/tsuru/tsuru/permission"
+)
+
+// title: list secrets
+// path: /apps/{app}/secrets
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: App not found
+func listSecrets(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(a.SecretNames())
+}
+
+// title: set secrets
+// path: /apps/{app}/secrets
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/x-json-stream
+// responses:
+//   200: Secrets updated
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App not found
+func setSecrets(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	err = r.ParseForm()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	var e types.Envs
+	dec := form.NewDecoder(nil)
+	dec.IgnoreUnknownKeys(true)
+	err = dec.DecodeValues(&e, r.Form)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	if len(e.Envs) == 0 {
+		msg := "You must provide the list of secrets"
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: msg}
+	}
+	appName := r.URL.Query().Get(":app")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(e.Envs); i++ {
+		r.Form.Set(fmt.Sprintf("Envs.%d.Value", i), "*****")
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppUpdateEnvSet,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	variables := make([]bind.EnvVar, 0, len(e.Envs))
+	for _, v := range e.Envs {
+		variables = append(variables, bind.EnvVar{Name: v.Name, Value: v.Value})
+	}
+	w.Header().Set("Content-Type", "application/x-json-stream")
+	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
+	defer keepAliveWriter.Stop()
+	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	return a.SetSecrets(bind.SetEnvArgs{
+		Envs:          variables,
+		ShouldRestart: !e.NoRestart,
+		Writer:        writer,
+	})
+}
+
+// title: unset secrets
+// path: /apps/{app}/secrets
+// method: DELETE
+// produce: application/x-json-stream
+// responses:
+//   200: Secrets removed
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App not found
+func unsetSecrets(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	variables := r.URL.Query()["secret"]
+	if len(variables) == 0 {
+		msg := "You must provide the list of secrets."
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: msg}
+	}
+	appName := r.URL.Query().Get(":app")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppUpdateEnvUnset,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.URL.Query()),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	w.Header().Set("Content-Type", "application/x-json-stream")
+	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
+	defer keepAliveWriter.Stop()
+	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	noRestart, _ := strconv.ParseBool(r.URL.Query().Get("noRestart"))
+	return a.UnsetSecrets(bind.UnsetEnvArgs{
+		VariableNames: variables,
+		ShouldRestart: !noRestart,
+		Writer:        writer,
+	})
+}
@@ -0,0 +1,196 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/ajg/form"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api/types"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/secret"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) createAppWithSecret(c *check.C) *app.App {
+	config.Set("secrets:key", base64.StdEncoding.EncodeToString([]byte("0123456789abcdef")))
+	a := app.App{
+		Name:      "secretive",
+		Platform:  "zend",
+		TeamOwner: s.team.Name,
+		Env: map[string]bind.EnvVar{
+			"DATABASE_HOST": {Name: "DATABASE_HOST", Value: "localhost", Public: true},
+		},
+	}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetSecrets(bind.SetEnvArgs{
+		Envs: []bind.EnvVar{{Name: "DATABASE_PASSWORD", Value: "s3cr3t"}},
+	})
+	c.Assert(err, check.IsNil)
+	return &a
+}
+
+func (s *S) TestSetSecrets(c *check.C) {
+	config.Set("secrets:key", base64.StdEncoding.EncodeToString([]byte("0123456789abcdef")))
+	defer config.Unset("secrets:key")
+	a := app.App{Name: "black-dog", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	d := types.Envs{
+		Envs: []struct{ Name, Value string }{
+			{"DATABASE_PASSWORD", "s3cr3t"},
+		},
+		NoRestart: true,
+	}
+	v, err := form.EncodeToValues(&d)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", fmt.Sprintf("/apps/%s/secrets", a.Name), strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), check.Equals, `{"Message":"---- Setting 1 new secrets ----\n"}
+`)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Env, check.HasLen, 0)
+	value, err := secret.Decrypt(dbApp.Secrets["DATABASE_PASSWORD"])
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, "s3cr3t")
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.env.set",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":app", "value": a.Name},
+			{"name": "Envs.0.Name", "value": "DATABASE_PASSWORD"},
+			{"name": "Envs.0.Value", "value": "*****"},
+			{"name": "NoRestart", "value": "true"},
+			{"name": "Private", "value": ""},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestSetSecretsWithoutEnvs(c *check.C) {
+	request, err := http.NewRequest("POST", "/apps/black-dog/secrets", strings.NewReader(""))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "You must provide the list of secrets\n")
+}
+
+func (s *S) TestListSecrets(c *check.C) {
+	defer config.Unset("secrets:key")
+	a := s.createAppWithSecret(c)
+	request, err := http.NewRequest("GET", fmt.Sprintf("/apps/%s/secrets", a.Name), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var names []string
+	err = json.Unmarshal(recorder.Body.Bytes(), &names)
+	c.Assert(err, check.IsNil)
+	c.Assert(names, check.DeepEquals, []string{"DATABASE_PASSWORD"})
+}
+
+func (s *S) TestListSecretsWithoutPermission(c *check.C) {
+	defer config.Unset("secrets:key")
+	a := s.createAppWithSecret(c)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppReadEnv,
+		Context: permission.Context(permission.CtxApp, "-invalid-"),
+	})
+	request, err := http.NewRequest("GET", fmt.Sprintf("/apps/%s/secrets", a.Name), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestUnsetSecrets(c *check.C) {
+	defer config.Unset("secrets:key")
+	a := s.createAppWithSecret(c)
+	request, err := http.NewRequest("DELETE", fmt.Sprintf("/apps/%s/secrets?secret=DATABASE_PASSWORD&noRestart=true", a.Name), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Secrets, check.HasLen, 0)
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.env.unset",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":app", "value": a.Name},
+			{"name": "secret", "value": "DATABASE_PASSWORD"},
+			{"name": "noRestart", "value": "true"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestGetEnvHidesSecretValues(c *check.C) {
+	defer config.Unset("secrets:key")
+	a := s.createAppWithSecret(c)
+	request, err := http.NewRequest("GET", fmt.Sprintf("/apps/%s/env", a.Name), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), check.Not(check.Matches), "(?s).*s3cr3t.*")
+	var result []bind.EnvVar
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	var found bool
+	for _, env := range result {
+		if env.Name == "DATABASE_PASSWORD" {
+			found = true
+			c.Assert(env, check.DeepEquals, bind.EnvVar{Name: "DATABASE_PASSWORD"})
+		}
+	}
+	c.Assert(found, check.Equals, true)
+	request, err = http.NewRequest("GET", fmt.Sprintf("/apps/%s/env?env=DATABASE_PASSWORD", a.Name), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), check.Equals, `[{"name":"DATABASE_PASSWORD","value":"","public":false}]`+"\n")
+}
+
+func (s *S) TestGetEnvWithAppTokenReturnsSecretValues(c *check.C) {
+	defer config.Unset("secrets:key")
+	a := s.createAppWithSecret(c)
+	token, err := nativeScheme.AppLogin(a.Name)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", fmt.Sprintf("/apps/%s/env?env=DATABASE_PASSWORD", a.Name), nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), check.Equals, `[{"name":"DATABASE_PASSWORD","value":"s3cr3t","public":false}]`+"\n")
+}
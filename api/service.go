@@ -8,6 +8,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/errors"
@@ -40,7 +42,15 @@ func serviceList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	if err != nil {
 		return err
 	}
-	sInstances, err := service.GetServiceInstancesByServices(services)
+	var sInstances []service.ServiceInstance
+	if permission.HardIsolation() {
+		// The instances of other teams using the services aren't listed, so
+		// providers can't find out about the teams of other tenants.
+		instanceContexts := permission.ContextsForPermission(t, permission.PermServiceInstanceRead)
+		sInstances, err = readableInstances(t, instanceContexts, "", "")
+	} else {
+		sInstances, err = service.GetServiceInstancesByServices(services)
+	}
 	if err != nil {
 		return err
 	}
@@ -63,6 +73,53 @@ func serviceList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	return json.NewEncoder(w).Encode(results)
 }
 
+// title: service catalog
+// path: /services/catalog
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+func serviceCatalog(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	cacheKey := listCacheKey(t, r)
+	cached, generation := serviceCatalogCache.serve(w, cacheKey)
+	if cached {
+		return nil
+	}
+	contexts := permission.ContextsForPermission(t, permission.PermServiceRead)
+	services, err := readableServices(t, contexts)
+	if err != nil {
+		return err
+	}
+	if len(services) == 0 {
+		return serviceCatalogCache.store(w, cacheKey, generation, nil)
+	}
+	catalog := service.Catalog(services, requestIDHeader(r))
+	return serviceCatalogCache.store(w, cacheKey, generation, catalog)
+}
+
+// title: service status
+// path: /services/{name}/status
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   401: Unauthorized
+//   404: Service not found
+func serviceStatus(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	s, err := getService(r.URL.Query().Get(":name"))
+	if err != nil {
+		return err
+	}
+	status, err := s.ProviderStatus(requestIDHeader(r))
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(status)
+}
+
 // title: service create
 // path: /services
 // method: POST
@@ -74,10 +131,15 @@ func serviceList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 //   409: Service already exists
 func serviceCreate(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	s := service.Service{
-		Name:     r.FormValue("id"),
-		Username: r.FormValue("username"),
-		Endpoint: map[string]string{"production": r.FormValue("endpoint")},
-		Password: r.FormValue("password"),
+		Name:        r.FormValue("id"),
+		Username:    r.FormValue("username"),
+		Endpoint:    map[string]string{"production": r.FormValue("endpoint")},
+		Password:    r.FormValue("password"),
+		Description: r.FormValue("description"),
+	}
+	err = setServiceRequestOptions(&s, r)
+	if err != nil {
+		return err
 	}
 	team := r.FormValue("team")
 	if team == "" {
@@ -145,11 +207,9 @@ func serviceUpdate(w http.ResponseWriter, r *http.Request, t auth.Token) (err er
 	if err != nil {
 		return err
 	}
-	allowed := permission.Check(t, permission.PermServiceUpdate,
-		contextsForServiceProvision(&s)...,
-	)
-	if !allowed {
-		return permission.ErrUnauthorized
+	err = setServiceRequestOptions(&s, r)
+	if err != nil {
+		return err
 	}
 	delete(r.Form, "password")
 	evt, err := event.New(&event.Opts{
@@ -166,12 +226,32 @@ func serviceUpdate(w http.ResponseWriter, r *http.Request, t auth.Token) (err er
 	s.Endpoint = d.Endpoint
 	s.Password = d.Password
 	s.Username = d.Username
+	if _, ok := r.Form["description"]; ok {
+		s.Description = r.FormValue("description")
+	}
 	if team != "" {
 		s.OwnerTeams = []string{team}
 	}
 	return s.Update()
 }
 
+// setServiceRequestOptions sets the timeout and retries of the requests sent
+// to the service API, when they're in the form.
+func setServiceRequestOptions(s *service.Service, r *http.Request) error {
+	for field, value := range map[string]*int{"timeout": &s.Timeout, "retries": &s.Retries} {
+		str := r.FormValue(field)
+		if str == "" {
+			continue
+		}
+		n, err := strconv.Atoi(str)
+		if err != nil {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("invalid %s: %q", field, str)}
+		}
+		*value = n
+	}
+	return nil
+}
+
 // title: service delete
 // path: /services/{name}
 // method: DELETE
@@ -186,12 +266,6 @@ func serviceDelete(w http.ResponseWriter, r *http.Request, t auth.Token) (err er
 	if err != nil {
 		return err
 	}
-	allowed := permission.Check(t, permission.PermServiceDelete,
-		contextsForServiceProvision(&s)...,
-	)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	evt, err := event.New(&event.Opts{
 		Target:     serviceTarget(s.Name),
 		Kind:       permission.PermServiceDelete,
@@ -228,12 +302,6 @@ func serviceProxy(w http.ResponseWriter, r *http.Request, t auth.Token) (err err
 	if err != nil {
 		return err
 	}
-	allowed := permission.Check(t, permission.PermServiceUpdateProxy,
-		contextsForServiceProvision(&s)...,
-	)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	if r.Method != http.MethodGet && r.Method != http.MethodHead {
 		evt, err := event.New(&event.Opts{
 			Target: serviceTarget(s.Name),
@@ -270,12 +338,6 @@ func grantServiceAccess(w http.ResponseWriter, r *http.Request, t auth.Token) (e
 	if err != nil {
 		return err
 	}
-	allowed := permission.Check(t, permission.PermServiceUpdateGrantAccess,
-		contextsForServiceProvision(&s)...,
-	)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	teamName := r.URL.Query().Get(":team")
 	team, err := auth.GetTeam(teamName)
 	if err != nil {
@@ -302,6 +364,55 @@ func grantServiceAccess(w http.ResponseWriter, r *http.Request, t auth.Token) (e
 	return s.Update()
 }
 
+// title: set teams with access to a service
+// path: /services/{name}/teams
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// produce: application/json
+// responses:
+//   200: Service teams updated
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Service not found
+//   409: Service teams changed concurrently
+func setServiceTeams(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	err = r.ParseForm()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	s, err := getService(r.URL.Query().Get(":name"))
+	if err != nil {
+		return err
+	}
+	teams := r.Form["team"]
+	if len(teams) == 0 {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "at least one team is required"}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     serviceTarget(s.Name),
+		Kind:       permission.PermServiceUpdateTeams,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermServiceReadEvents, contextsForServiceProvision(&s)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	diff, err := s.SetTeams(teams)
+	if err != nil {
+		if errors.IsValidation(err) {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+		}
+		if err == service.ErrServiceTeamsChanged {
+			return &errors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+		}
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(diff)
+}
+
 // title: revoke access to a service
 // path: /services/{service}/team/{team}
 // method: DELETE
@@ -318,12 +429,6 @@ func revokeServiceAccess(w http.ResponseWriter, r *http.Request, t auth.Token) (
 	if err != nil {
 		return err
 	}
-	allowed := permission.Check(t, permission.PermServiceUpdateRevokeAccess,
-		contextsForServiceProvision(&s)...,
-	)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	teamName := r.URL.Query().Get(":team")
 	team, err := auth.GetTeam(teamName)
 	if err != nil {
@@ -368,13 +473,8 @@ func serviceAddDoc(w http.ResponseWriter, r *http.Request, t auth.Token) (err er
 	if err != nil {
 		return err
 	}
-	allowed := permission.Check(t, permission.PermServiceUpdateDoc,
-		contextsForServiceProvision(&s)...,
-	)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	s.Doc = r.FormValue("doc")
+	s.DocUpdatedAt = time.Now().UTC()
 	evt, err := event.New(&event.Opts{
 		Target:     serviceTarget(s.Name),
 		Kind:       permission.PermServiceUpdateDoc,
@@ -389,6 +489,75 @@ func serviceAddDoc(w http.ResponseWriter, r *http.Request, t auth.Token) (err er
 	return s.Update()
 }
 
+// title: service webhook set
+// path: /services/{name}/webhook
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// produce: application/json
+// responses:
+//   200: Webhook configured
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Service not found
+func serviceWebhookSet(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	s, err := getService(r.URL.Query().Get(":name"))
+	if err != nil {
+		return err
+	}
+	hook := service.Webhook{
+		URL:    r.FormValue("url"),
+		Secret: r.FormValue("secret"),
+	}
+	delete(r.Form, "secret")
+	evt, err := event.New(&event.Opts{
+		Target:     serviceTarget(s.Name),
+		Kind:       permission.PermServiceUpdateWebhook,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermServiceReadEvents, contextsForServiceProvision(&s)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	result, err := s.SetWebhook(hook)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(result)
+}
+
+// title: service webhook remove
+// path: /services/{name}/webhook
+// method: DELETE
+// responses:
+//   200: Webhook removed
+//   401: Unauthorized
+//   404: Service or webhook not found
+func serviceWebhookRemove(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	s, err := getService(r.URL.Query().Get(":name"))
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     serviceTarget(s.Name),
+		Kind:       permission.PermServiceUpdateWebhook,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermServiceReadEvents, contextsForServiceProvision(&s)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = s.RemoveWebhook()
+	if err == service.ErrWebhookNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
+
 func getService(name string) (service.Service, error) {
 	s := service.Service{Name: name}
 	err := s.Get()
@@ -9,7 +9,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
+	"net/url"
 	"sort"
 	"strconv"
 	"strings"
@@ -18,6 +20,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/api/context"
+	"github.com/tsuru/tsuru/app/bind"
 	"github.com/tsuru/tsuru/auth"
 	tsuruErrors "github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/event"
@@ -38,6 +41,7 @@ func serviceIntancePermName(serviceName, instanceName string) string {
 // path: /services/{service}/instances
 // method: POST
 // consume: application/x-www-form-urlencoded
+// produce: application/json
 // responses:
 //   201: Service created
 //   400: Invalid data
@@ -49,7 +53,7 @@ func createServiceInstance(w http.ResponseWriter, r *http.Request, t auth.Token)
 	if err != nil {
 		return err
 	}
-	srv, err := getService(serviceName)
+	srv, err := getReadableService(t, serviceName)
 	if err != nil {
 		return err
 	}
@@ -78,7 +82,7 @@ func createServiceInstance(w http.ResponseWriter, r *http.Request, t auth.Token)
 	if !allowed {
 		return permission.ErrUnauthorized
 	}
-	if srv.IsRestricted {
+	if isRestricted(&srv) {
 		allowed := permission.Check(t, permission.PermServiceRead,
 			contextsForService(&srv)...,
 		)
@@ -86,6 +90,9 @@ func createServiceInstance(w http.ResponseWriter, r *http.Request, t auth.Token)
 			return permission.ErrUnauthorized
 		}
 	}
+	if existing, err := service.GetServiceInstance(serviceName, instance.Name); err == nil {
+		return writeServiceInstanceConflict(w, t, existing)
+	}
 	evt, err := event.New(&event.Opts{
 		Target:     serviceInstanceTarget(serviceName, instance.Name),
 		Kind:       permission.PermServiceInstanceCreate,
@@ -106,18 +113,33 @@ func createServiceInstance(w http.ResponseWriter, r *http.Request, t auth.Token)
 			Message: err.Error(),
 		}
 	}
-	if err == service.ErrInvalidInstanceName {
-		return &tsuruErrors.HTTP{
-			Code:    http.StatusBadRequest,
-			Message: err.Error(),
-		}
-	}
 	if err == nil {
 		w.WriteHeader(http.StatusCreated)
 	}
 	return err
 }
 
+type serviceInstanceConflict struct {
+	Message  string                   `json:"message"`
+	Instance *service.ServiceInstance `json:"instance,omitempty"`
+}
+
+// writeServiceInstanceConflict writes a conflict response describing the
+// existing instance, the instance details are only included when the user is
+// allowed to read the instance.
+func writeServiceInstanceConflict(w http.ResponseWriter, t auth.Token, si *service.ServiceInstance) error {
+	conflict := serviceInstanceConflict{Message: service.ErrInstanceNameAlreadyExists.Error()}
+	allowed := permission.Check(t, permission.PermServiceInstanceRead,
+		contextsForServiceInstance(si, si.ServiceName)...,
+	)
+	if allowed {
+		conflict.Instance = si
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	return json.NewEncoder(w).Encode(conflict)
+}
+
 // title: service instance update
 // path: /services/{service}/instances/{instance}
 // method: PUT
@@ -142,7 +164,7 @@ func updateServiceInstance(w http.ResponseWriter, r *http.Request, t auth.Token)
 	if err != nil {
 		return err
 	}
-	si, err := getServiceInstanceOrError(serviceName, instanceName)
+	si, err := getReadableServiceInstance(t, serviceName, instanceName)
 	if err != nil {
 		return err
 	}
@@ -212,20 +234,29 @@ func removeServiceInstance(w http.ResponseWriter, r *http.Request, t auth.Token)
 	unbindAll := r.URL.Query().Get("unbindall")
 	serviceName := r.URL.Query().Get(":service")
 	instanceName := r.URL.Query().Get(":instance")
-	serviceInstance, err := getServiceInstanceOrError(serviceName, instanceName)
+	serviceInstance, err := getReadableServiceInstance(t, serviceName, instanceName)
 	if err != nil {
 		return err
 	}
+	unbindAllBool, _ := strconv.ParseBool(unbindAll)
+	if isDryRun(r) {
+		if len(serviceInstance.Apps) > 0 && !unbindAllBool {
+			return &tsuruErrors.HTTP{
+				Message: errors.Wrapf(service.ErrServiceInstanceBound, `Applications bound to the service "%s": "%s"`+"\n", instanceName, strings.Join(serviceInstance.Apps, ",")).Error(),
+				Code:    http.StatusBadRequest,
+			}
+		}
+		var actions []string
+		for _, appName := range serviceInstance.Apps {
+			actions = append(actions, fmt.Sprintf("unbind app %q from service instance %q", appName, serviceInstance.Name))
+		}
+		actions = append(actions, fmt.Sprintf("remove service instance %q of service %q", serviceInstance.Name, serviceInstance.ServiceName))
+		return writeDryRunActions(w, actions)
+	}
 	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
 	defer keepAliveWriter.Stop()
 	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
 	w.Header().Set("Content-Type", "application/x-json-stream")
-	allowed := permission.Check(t, permission.PermServiceInstanceDelete,
-		contextsForServiceInstance(serviceInstance, serviceName)...,
-	)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	evt, err := event.New(&event.Opts{
 		Target:     serviceInstanceTarget(serviceName, instanceName),
 		Kind:       permission.PermServiceInstanceDelete,
@@ -238,7 +269,6 @@ func removeServiceInstance(w http.ResponseWriter, r *http.Request, t auth.Token)
 		return err
 	}
 	defer func() { evt.Done(err) }()
-	unbindAllBool, _ := strconv.ParseBool(unbindAll)
 	if unbindAllBool {
 		if len(serviceInstance.Apps) > 0 {
 			for _, appName := range serviceInstance.Apps {
@@ -317,9 +347,32 @@ func filtersForServiceList(t auth.Token, contexts []permission.PermissionContext
 
 func readableServices(t auth.Token, contexts []permission.PermissionContext) ([]service.Service, error) {
 	teams, serviceNames := filtersForServiceList(t, contexts)
+	if permission.HardIsolation() {
+		return service.GetServicesGrantedToTeamsAndServices(teams, serviceNames)
+	}
 	return service.GetServicesByTeamsAndServices(teams, serviceNames)
 }
 
+// getReadableService is like getService, but in hard isolation mode it
+// returns the same not found error for services that don't exist and for
+// services the user can't read.
+func getReadableService(t auth.Token, name string) (service.Service, error) {
+	s, err := getService(name)
+	if !permission.HardIsolation() {
+		return s, err
+	}
+	if err != nil || !permission.Check(t, permission.PermServiceRead, contextsForService(&s)...) {
+		return service.Service{}, errIsolatedNotFound
+	}
+	return s, nil
+}
+
+// isRestricted reports whether the access to the given service must be
+// checked. Every service is restricted in hard isolation mode.
+func isRestricted(s *service.Service) bool {
+	return s.IsRestricted || permission.HardIsolation()
+}
+
 // title: service instance list
 // path: /services/instances
 // method: GET
@@ -380,16 +433,10 @@ func serviceInstances(w http.ResponseWriter, r *http.Request, t auth.Token) erro
 func serviceInstanceStatus(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	instanceName := r.URL.Query().Get(":instance")
 	serviceName := r.URL.Query().Get(":service")
-	serviceInstance, err := getServiceInstanceOrError(serviceName, instanceName)
+	serviceInstance, err := getReadableServiceInstance(t, serviceName, instanceName)
 	if err != nil {
 		return err
 	}
-	allowed := permission.Check(t, permission.PermServiceInstanceReadStatus,
-		contextsForServiceInstance(serviceInstance, serviceName)...,
-	)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	var b string
 	requestID := requestIDHeader(r)
 	if b, err = serviceInstance.Status(requestID); err != nil {
@@ -399,6 +446,234 @@ func serviceInstanceStatus(w http.ResponseWriter, r *http.Request, t auth.Token)
 	return err
 }
 
+// title: rotate service instance credentials
+// path: /services/{service}/instances/{instance}/rotate
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/x-json-stream
+// responses:
+//   200: Credentials rotated
+//   401: Unauthorized
+//   404: Service instance not found
+func rotateServiceInstanceCredentials(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	serviceName := r.URL.Query().Get(":service")
+	instanceName := r.URL.Query().Get(":instance")
+	noRestart, _ := strconv.ParseBool(r.FormValue("noRestart"))
+	serviceInstance, err := getReadableServiceInstance(t, serviceName, instanceName)
+	if err != nil {
+		return err
+	}
+	apps := make([]bind.App, len(serviceInstance.Apps))
+	for i, appName := range serviceInstance.Apps {
+		_, a, appErr := getServiceInstance(serviceName, instanceName, appName)
+		if appErr != nil {
+			return appErr
+		}
+		apps[i] = a
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     serviceInstanceTarget(serviceName, instanceName),
+		Kind:       permission.PermServiceInstanceUpdateRotate,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed: event.Allowed(permission.PermServiceInstanceReadEvents,
+			contextsForServiceInstance(serviceInstance, serviceName)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	w.Header().Set("Content-Type", "application/x-json-stream")
+	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
+	defer keepAliveWriter.Stop()
+	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	err = serviceInstance.RotateCredentials(apps, !noRestart, writer)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(writer, "\nCredentials of instance %q rotated in %d apps.\n", instanceName, len(apps))
+	return nil
+}
+
+type serviceInstanceConnection struct {
+	Service  string            `json:"service"`
+	Instance string            `json:"instance"`
+	App      string            `json:"app,omitempty"`
+	Host     string            `json:"host"`
+	Port     string            `json:"port"`
+	Envs     map[string]string `json:"envs"`
+}
+
+// connectionHostPort guesses the host and port of a service instance from
+// the variables it exports, either from variables suffixed with _HOST and
+// _PORT or from the first variable holding an URL.
+func connectionHostPort(envs []bind.ServiceEnvVar) (string, string) {
+	var host, port string
+	for _, env := range envs {
+		name := strings.ToUpper(env.Name)
+		if host == "" && strings.HasSuffix(name, "_HOST") {
+			host = env.Value
+		}
+		if port == "" && strings.HasSuffix(name, "_PORT") {
+			port = env.Value
+		}
+	}
+	if host != "" {
+		return host, port
+	}
+	for _, env := range envs {
+		u, err := url.Parse(env.Value)
+		if err == nil && u.Host != "" {
+			return u.Hostname(), u.Port()
+		}
+	}
+	return "", ""
+}
+
+// serviceNameForInstance returns the name of the service the instance named
+// instanceName belongs to, serviceName is used when the name is ambiguous.
+func serviceNameForInstance(instanceName, serviceName string) (string, error) {
+	if serviceName != "" {
+		return serviceName, nil
+	}
+	instances, err := service.GetServiceInstancesByName(instanceName)
+	if err != nil {
+		return "", err
+	}
+	switch len(instances) {
+	case 0:
+		return "", &tsuruErrors.HTTP{Code: http.StatusNotFound, Message: service.ErrServiceInstanceNotFound.Error()}
+	case 1:
+		return instances[0].ServiceName, nil
+	}
+	return "", &tsuruErrors.HTTP{
+		Code:    http.StatusBadRequest,
+		Message: fmt.Sprintf("there are %d instances named %q, use the service parameter to choose one", len(instances), instanceName),
+	}
+}
+
+// title: service instance connection info
+// path: /services/instances/{instance}/connection
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   400: Ambiguous instance name
+//   401: Unauthorized
+//   404: Service instance not found
+func serviceInstanceConnectionInfo(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	instanceName := r.URL.Query().Get(":instance")
+	serviceName, err := serviceNameForInstance(instanceName, r.URL.Query().Get("service"))
+	if err != nil {
+		return err
+	}
+	serviceInstance, err := getReadableServiceInstance(t, serviceName, instanceName)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermServiceInstanceReadConnection,
+		permission.Context(permission.CtxTeam, serviceInstance.TeamOwner),
+		permission.Context(permission.CtxServiceInstance, serviceIntancePermName(serviceName, instanceName)),
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	reveal, _ := strconv.ParseBool(r.URL.Query().Get("reveal"))
+	if reveal {
+		evt, err := event.New(&event.Opts{
+			Target:     serviceInstanceTarget(serviceName, instanceName),
+			Kind:       permission.PermServiceInstanceReadConnection,
+			Owner:      t,
+			CustomData: event.FormToCustomData(r.URL.Query()),
+			Allowed: event.Allowed(permission.PermServiceInstanceReadEvents,
+				contextsForServiceInstance(serviceInstance, serviceName)...),
+		})
+		if err != nil {
+			return err
+		}
+		defer func() { evt.Done(err) }()
+	}
+	conn := serviceInstanceConnection{
+		Service:  serviceName,
+		Instance: instanceName,
+		Envs:     map[string]string{},
+	}
+	conn.App = r.URL.Query().Get("app")
+	if conn.App == "" && len(serviceInstance.Apps) > 0 {
+		conn.App = serviceInstance.Apps[0]
+	}
+	if conn.App != "" {
+		if serviceInstance.FindApp(conn.App) == -1 {
+			return &tsuruErrors.HTTP{
+				Code:    http.StatusBadRequest,
+				Message: fmt.Sprintf("app %q is not bound to instance %q", conn.App, instanceName),
+			}
+		}
+		_, a, err := getServiceInstance(serviceName, instanceName, conn.App)
+		if err != nil {
+			return err
+		}
+		var envs []bind.ServiceEnvVar
+		for _, env := range a.ServiceEnvs {
+			if env.ServiceName == serviceName && env.InstanceName == instanceName {
+				envs = append(envs, env)
+			}
+		}
+		conn.Host, conn.Port = connectionHostPort(envs)
+		for _, env := range envs {
+			value := env.Value
+			if !env.Public && !reveal {
+				value = "*****"
+			}
+			conn.Envs[env.Name] = value
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(conn)
+}
+
+type serviceInstanceMetrics struct {
+	Service  string                   `json:"service"`
+	Instance string                   `json:"instance"`
+	Metrics  []service.InstanceMetric `json:"metrics"`
+}
+
+// title: service instance metrics
+// path: /services/instances/{instance}/metrics
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: Metrics not reported by the service
+//   400: Ambiguous instance name
+//   401: Unauthorized
+//   404: Service instance not found
+func serviceInstanceMetricsHandler(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	instanceName := r.URL.Query().Get(":instance")
+	serviceName, err := serviceNameForInstance(instanceName, r.URL.Query().Get("service"))
+	if err != nil {
+		return err
+	}
+	serviceInstance, err := getReadableServiceInstance(t, serviceName, instanceName)
+	if err != nil {
+		return err
+	}
+	metrics, err := serviceInstance.Metrics(requestIDHeader(r))
+	if err == service.ErrMetricsNotSupported {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(serviceInstanceMetrics{
+		Service:  serviceName,
+		Instance: instanceName,
+		Metrics:  metrics,
+	})
+}
+
 type serviceInstanceInfo struct {
 	Apps            []string
 	Teams           []string
@@ -408,6 +683,7 @@ type serviceInstanceInfo struct {
 	PlanDescription string
 	CustomInfo      map[string]string
 	Tags            []string
+	BindStatus      map[string]service.InstanceStatus `json:",omitempty"`
 }
 
 // title: service instance info
@@ -421,16 +697,10 @@ type serviceInstanceInfo struct {
 func serviceInstance(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	instanceName := r.URL.Query().Get(":instance")
 	serviceName := r.URL.Query().Get(":service")
-	serviceInstance, err := getServiceInstanceOrError(serviceName, instanceName)
+	serviceInstance, err := getReadableServiceInstance(t, serviceName, instanceName)
 	if err != nil {
 		return err
 	}
-	allowed := permission.Check(t, permission.PermServiceInstanceRead,
-		contextsForServiceInstance(serviceInstance, serviceName)...,
-	)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	requestID := requestIDHeader(r)
 	info, err := serviceInstance.Info(requestID)
 	if err != nil {
@@ -449,6 +719,7 @@ func serviceInstance(w http.ResponseWriter, r *http.Request, t auth.Token) error
 		PlanDescription: plan.Description,
 		CustomInfo:      info,
 		Tags:            serviceInstance.Tags,
+		BindStatus:      serviceInstance.BindStatus,
 	}
 	w.Header().Set("Content-Type", "application/json")
 	return json.NewEncoder(w).Encode(sInfo)
@@ -462,7 +733,7 @@ func serviceInstance(w http.ResponseWriter, r *http.Request, t auth.Token) error
 //   200: OK
 func serviceInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	serviceName := r.URL.Query().Get(":name")
-	_, err := getService(serviceName)
+	_, err := getReadableService(t, serviceName)
 	if err != nil {
 		return err
 	}
@@ -477,17 +748,19 @@ func serviceInfo(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 // title: service doc
 // path: /services/{name}/doc
 // method: GET
+// produce: text/plain
 // responses:
 //   200: OK
+//   304: Not modified
 //   401: Unauthorized
 //   404: Not found
 func serviceDoc(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	serviceName := r.URL.Query().Get(":name")
-	s, err := getService(serviceName)
+	s, err := getReadableService(t, serviceName)
 	if err != nil {
 		return err
 	}
-	if s.IsRestricted {
+	if isRestricted(&s) {
 		allowed := permission.Check(t, permission.PermServiceReadDoc,
 			contextsForService(&s)...,
 		)
@@ -495,10 +768,30 @@ func serviceDoc(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 			return permission.ErrUnauthorized
 		}
 	}
-	w.Write([]byte(s.Doc))
+	writeCacheable(w, r, "text/plain; charset=utf-8", s.DocUpdatedAt, []byte(s.Doc))
 	return nil
 }
 
+// getReadableServiceInstance is like getServiceInstanceOrError, but in hard
+// isolation mode it returns the same not found error for instances that
+// don't exist and for instances the user can't read.
+func getReadableServiceInstance(t auth.Token, serviceName string, instanceName string) (*service.ServiceInstance, error) {
+	si, err := getServiceInstanceOrError(serviceName, instanceName)
+	if !permission.HardIsolation() {
+		return si, err
+	}
+	if err != nil {
+		if tsuruErrors.StatusCode(err) == http.StatusNotFound {
+			return nil, errIsolatedNotFound
+		}
+		return nil, err
+	}
+	if !permission.Check(t, permission.PermServiceInstanceRead, contextsForServiceInstance(si, serviceName)...) {
+		return nil, errIsolatedNotFound
+	}
+	return si, nil
+}
+
 func getServiceInstanceOrError(serviceName string, instanceName string) (*service.ServiceInstance, error) {
 	serviceInstance, err := service.GetServiceInstance(serviceName, instanceName)
 	if err != nil {
@@ -525,11 +818,11 @@ func getServiceInstanceOrError(serviceName string, instanceName string) (*servic
 //   404: Service not found
 func servicePlans(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	serviceName := r.URL.Query().Get(":name")
-	s, err := getService(serviceName)
+	s, err := getReadableService(t, serviceName)
 	if err != nil {
 		return err
 	}
-	if s.IsRestricted {
+	if isRestricted(&s) {
 		allowed := permission.Check(t, permission.PermServiceReadPlans,
 			contextsForService(&s)...,
 		)
@@ -573,16 +866,10 @@ func serviceInstanceProxy(w http.ResponseWriter, r *http.Request, t auth.Token)
 	parseFormPreserveBody(r)
 	serviceName := r.URL.Query().Get(":service")
 	instanceName := r.URL.Query().Get(":instance")
-	serviceInstance, err := getServiceInstanceOrError(serviceName, instanceName)
+	serviceInstance, err := getReadableServiceInstance(t, serviceName, instanceName)
 	if err != nil {
 		return err
 	}
-	allowed := permission.Check(t, permission.PermServiceInstanceUpdateProxy,
-		contextsForServiceInstance(serviceInstance, serviceName)...,
-	)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	path := r.URL.Query().Get("callback")
 	if r.Method != http.MethodGet && r.Method != http.MethodHead {
 		evt, err := event.New(&event.Opts{
@@ -616,16 +903,10 @@ func serviceInstanceGrantTeam(w http.ResponseWriter, r *http.Request, t auth.Tok
 	r.ParseForm()
 	instanceName := r.URL.Query().Get(":instance")
 	serviceName := r.URL.Query().Get(":service")
-	serviceInstance, err := getServiceInstanceOrError(serviceName, instanceName)
+	serviceInstance, err := getReadableServiceInstance(t, serviceName, instanceName)
 	if err != nil {
 		return err
 	}
-	allowed := permission.Check(t, permission.PermServiceInstanceUpdateGrant,
-		contextsForServiceInstance(serviceInstance, serviceName)...,
-	)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	evt, err := event.New(&event.Opts{
 		Target:     serviceInstanceTarget(serviceName, instanceName),
 		Kind:       permission.PermServiceInstanceUpdateGrant,
@@ -653,16 +934,10 @@ func serviceInstanceRevokeTeam(w http.ResponseWriter, r *http.Request, t auth.To
 	r.ParseForm()
 	instanceName := r.URL.Query().Get(":instance")
 	serviceName := r.URL.Query().Get(":service")
-	serviceInstance, err := getServiceInstanceOrError(serviceName, instanceName)
+	serviceInstance, err := getReadableServiceInstance(t, serviceName, instanceName)
 	if err != nil {
 		return err
 	}
-	allowed := permission.Check(t, permission.PermServiceInstanceUpdateRevoke,
-		contextsForServiceInstance(serviceInstance, serviceName)...,
-	)
-	if !allowed {
-		return permission.ErrUnauthorized
-	}
 	evt, err := event.New(&event.Opts{
 		Target:     serviceInstanceTarget(serviceName, instanceName),
 		Kind:       permission.PermServiceInstanceUpdateRevoke,
@@ -707,3 +982,170 @@ func requestIDHeader(r *http.Request) string {
 	requestIDHeader, _ := config.GetString("request-id-header")
 	return context.GetRequestID(r, requestIDHeader)
 }
+
+// title: service instance clone
+// path: /services/instances/{instance}/clone
+// method: POST
+// consume: application/x-www-form-urlencoded
+// responses:
+//   201: Service instance cloned
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Service instance not found
+//   409: Service instance already exists
+//   501: Service does not support cloning
+func cloneServiceInstance(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	err = r.ParseForm()
+	if err != nil {
+		return err
+	}
+	instanceName := r.URL.Query().Get(":instance")
+	serviceName, err := serviceNameForInstance(instanceName, r.FormValue("service"))
+	if err != nil {
+		return err
+	}
+	source, err := getReadableServiceInstance(t, serviceName, instanceName)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermServiceInstanceRead,
+		contextsForServiceInstance(source, serviceName)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	name := r.FormValue("name")
+	if name == "" {
+		return &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: "name is required"}
+	}
+	teamOwner := r.FormValue("owner")
+	if teamOwner == "" {
+		teamOwner = source.TeamOwner
+	}
+	allowed = permission.Check(t, permission.PermServiceInstanceCreate,
+		permission.Context(permission.CtxTeam, teamOwner),
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	user, err := t.User()
+	if err != nil {
+		return err
+	}
+	srv, err := getService(serviceName)
+	if err != nil {
+		return err
+	}
+	if existing, err := service.GetServiceInstance(serviceName, name); err == nil {
+		return writeServiceInstanceConflict(w, t, existing)
+	}
+	clone := service.ServiceInstance{Name: name, ServiceName: serviceName, TeamOwner: teamOwner}
+	evt, err := event.New(&event.Opts{
+		Target:     serviceInstanceTarget(serviceName, name),
+		Kind:       permission.PermServiceInstanceCreate,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed: event.Allowed(permission.PermServiceInstanceReadEvents,
+			contextsForServiceInstance(&clone, serviceName)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = service.CloneServiceInstance(*source, name, teamOwner, &srv, user, requestIDHeader(r))
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusCreated)
+	case service.ErrInstanceNameAlreadyExists, service.ErrInstanceAlreadyExistsInAPI:
+		return &tsuruErrors.HTTP{Code: http.StatusConflict, Message: err.Error()}
+	case service.ErrCloneNotSupported:
+		return &tsuruErrors.HTTP{Code: http.StatusNotImplemented, Message: err.Error()}
+	}
+	return err
+}
+
+const (
+	maxCallbackPayloadSize = 1024 * 1024
+	callbackTokenHeader    = "X-Tsuru-Callback-Token"
+)
+
+// callbackSignatureRequired tells whether service instance callbacks must be
+// signed, refusing the plain callback token which doesn't prevent replays.
+func callbackSignatureRequired() bool {
+	required, _ := config.GetBool("callback:require-signature")
+	return required
+}
+
+// callbackToken returns the callback token sent in the request body or in the
+// X-Tsuru-Callback-Token header. Tokens in the query string, which end up in
+// access logs, are ignored.
+func callbackToken(r *http.Request) string {
+	if token := r.Header.Get(callbackTokenHeader); token != "" {
+		return token
+	}
+	return r.PostFormValue("token")
+}
+
+// title: service instance callback
+// path: /services/instances/{instance}/callback
+// method: POST
+// consume: application/x-www-form-urlencoded
+// responses:
+//   204: Status updated
+//   400: Invalid data
+//   401: Invalid callback token or signature
+//   404: Service instance not found
+func serviceInstanceCallback(w http.ResponseWriter, r *http.Request) (err error) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxCallbackPayloadSize))
+	if err != nil {
+		return &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	err = r.ParseForm()
+	if err != nil {
+		return &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	instanceName := r.URL.Query().Get(":instance")
+	serviceName, err := serviceNameForInstance(instanceName, r.FormValue("service"))
+	if err != nil {
+		return err
+	}
+	serviceInstance, err := getServiceInstanceOrError(serviceName, instanceName)
+	if err != nil {
+		return err
+	}
+	if r.Header.Get(signatureHeader) != "" || callbackSignatureRequired() {
+		scope := "callback/" + serviceName + "/" + instanceName
+		err = verifySignedRequest(r.Header, body, serviceInstance.CallbackToken, scope)
+		if err != nil {
+			return err
+		}
+	} else if !serviceInstance.ValidCallbackToken(callbackToken(r)) {
+		return &tsuruErrors.HTTP{Code: http.StatusUnauthorized, Message: "invalid callback token"}
+	}
+	delete(r.Form, "token")
+	evt, err := event.New(&event.Opts{
+		Target:     serviceInstanceTarget(serviceName, instanceName),
+		Kind:       permission.PermServiceInstanceUpdateStatus,
+		RawOwner:   event.Owner{Type: event.OwnerTypeInternal, Name: serviceName + "-callback"},
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed: event.Allowed(permission.PermServiceInstanceReadEvents,
+			contextsForServiceInstance(serviceInstance, serviceName)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	status := service.InstanceStatus{
+		Status:  r.FormValue("status"),
+		Message: r.FormValue("message"),
+	}
+	err = serviceInstance.SetCallbackStatus(r.FormValue("app"), status)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case service.ErrInvalidCallbackStatus, service.ErrAppNotBound:
+		return &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return err
+}
@@ -18,6 +18,8 @@ import (
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/db/dbtest"
+	"github.com/tsuru/tsuru/db/dbtest/fixture"
+	"github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/event/eventtest"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/permission/permissiontest"
@@ -36,6 +38,7 @@ type ProvisionSuite struct {
 	user       *auth.User
 	token      auth.Token
 	testServer http.Handler
+	snapshot   *fixture.Snapshot
 }
 
 var _ = check.Suite(&ProvisionSuite{})
@@ -57,6 +60,7 @@ func (s *ProvisionSuite) SetUpTest(c *check.C) {
 }
 
 func (s *ProvisionSuite) TearDownTest(c *check.C) {
+	c.Assert(s.snapshot.Restore(), check.IsNil)
 	s.conn.Close()
 }
 
@@ -73,9 +77,11 @@ func (s *ProvisionSuite) makeRequestToServicesHandler(c *check.C) (*httptest.Res
 }
 
 func (s *ProvisionSuite) createUserAndTeam(c *check.C) {
-	s.team = &authTypes.Team{Name: "tsuruteam"}
-	err := auth.TeamService().Insert(*s.team)
+	d := &fixture.Dataset{Teams: []authTypes.Team{{Name: "tsuruteam"}}}
+	var err error
+	s.snapshot, err = fixture.Load(d)
 	c.Assert(err, check.IsNil)
+	s.team = &d.Teams[0]
 	_, s.token = permissiontest.CustomUserWithPermission(c, nativeScheme, "provision-master-user", permission.Permission{
 		Scheme:  permission.PermService,
 		Context: permission.Context(permission.CtxTeam, s.team.Name),
@@ -114,6 +120,44 @@ func (s *ProvisionSuite) TestServiceListGetAllServicesFromUsersTeam(c *check.C)
 	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
 }
 
+func (s *ProvisionSuite) TestServiceListHardIsolation(c *check.C) {
+	config.Set("multi-tenancy:hard-isolation", true)
+	defer config.Unset("multi-tenancy")
+	srv := service.Service{
+		Name:       "mongodb",
+		OwnerTeams: []string{s.team.Name},
+		Endpoint:   map[string]string{"production": "http://localhost:1234"},
+		Password:   "abcde",
+	}
+	err := srv.Create()
+	c.Assert(err, check.IsNil)
+	si := service.ServiceInstance{Name: "my_nosql", ServiceName: srv.Name, Teams: []string{s.team.Name}}
+	err = s.conn.ServiceInstances().Insert(si)
+	c.Assert(err, check.IsNil)
+	si = service.ServiceInstance{Name: "their_nosql", ServiceName: srv.Name, Teams: []string{"otherteam"}}
+	err = s.conn.ServiceInstances().Insert(si)
+	c.Assert(err, check.IsNil)
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "provision-isolated-user", permission.Permission{
+		Scheme:  permission.PermService,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	}, permission.Permission{
+		Scheme:  permission.PermServiceInstanceRead,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	recorder, request := s.makeRequestToServicesHandler(c)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var services []service.ServiceModel
+	err = json.Unmarshal(recorder.Body.Bytes(), &services)
+	c.Assert(err, check.IsNil)
+	c.Assert(services, check.DeepEquals, []service.ServiceModel{{
+		Service:          "mongodb",
+		Instances:        []string{"my_nosql"},
+		ServiceInstances: []service.ServiceInstanceModel{{Name: "my_nosql"}},
+	}})
+}
+
 func (s *ProvisionSuite) TestServiceListEmptyList(c *check.C) {
 	recorder, request := s.makeRequestToServicesHandler(c)
 	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
@@ -196,6 +240,53 @@ func (s *ProvisionSuite) TestServiceCreateWithoutTeam(c *check.C) {
 	c.Assert(rService.Username, check.Equals, "test")
 }
 
+func (s *ProvisionSuite) TestServiceCreateWithDescription(c *check.C) {
+	v := url.Values{}
+	v.Set("id", "some-service")
+	v.Set("password", "xxxx")
+	v.Set("endpoint", "someservices.com")
+	v.Set("description", "My database")
+	recorder, request := s.makeRequest("POST", "/services", v.Encode(), c)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	var rService service.Service
+	err := s.conn.Services().FindId("some-service").One(&rService)
+	c.Assert(err, check.IsNil)
+	c.Assert(rService.Description, check.Equals, "My database")
+}
+
+func (s *ProvisionSuite) TestServiceCreateWithTimeoutAndRetries(c *check.C) {
+	v := url.Values{}
+	v.Set("id", "some-service")
+	v.Set("password", "xxxx")
+	v.Set("endpoint", "someservices.com")
+	v.Set("timeout", "900")
+	v.Set("retries", "2")
+	recorder, request := s.makeRequest("POST", "/services", v.Encode(), c)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	var rService service.Service
+	err := s.conn.Services().FindId("some-service").One(&rService)
+	c.Assert(err, check.IsNil)
+	c.Assert(rService.Timeout, check.Equals, 900)
+	c.Assert(rService.Retries, check.Equals, 2)
+}
+
+func (s *ProvisionSuite) TestServiceCreateInvalidTimeout(c *check.C) {
+	v := url.Values{}
+	v.Set("id", "some-service")
+	v.Set("password", "xxxx")
+	v.Set("endpoint", "someservices.com")
+	v.Set("timeout", "10m")
+	recorder, request := s.makeRequest("POST", "/services", v.Encode(), c)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "invalid timeout: \"10m\"\n")
+}
+
 func (s *ProvisionSuite) TestServiceCreateWithoutTeamUserWithMultiplePermissions(c *check.C) {
 	v := url.Values{}
 	v.Set("id", "some-service")
@@ -239,7 +330,25 @@ func (s *ProvisionSuite) TestServiceCreateReturnsBadRequestWithoutPassword(c *ch
 	recorder, request := s.makeRequest("POST", "/services", v.Encode(), c)
 	s.testServer.ServeHTTP(recorder, request)
 	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
-	c.Assert(recorder.Body.String(), check.Equals, "Service id is required\n")
+	c.Assert(recorder.Body.String(), check.Equals, "Service id is required\nService password is required\nService production endpoint is required\n")
+}
+
+func (s *ProvisionSuite) TestServiceCreateReturnsFieldErrorsAsJSON(c *check.C) {
+	v := url.Values{}
+	v.Set("id", "some-service")
+	recorder, request := s.makeRequest("POST", "/services", v.Encode(), c)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var verr errors.ValidationError
+	err := json.NewDecoder(recorder.Body).Decode(&verr)
+	c.Assert(err, check.IsNil)
+	c.Assert(verr.Fields, check.DeepEquals, []errors.FieldError{
+		{Field: "password", Message: "Service password is required"},
+		{Field: "endpoint", Message: "Service production endpoint is required"},
+	})
 }
 
 func (s *ProvisionSuite) TestServiceCreateReturnsBadRequestWithoutId(c *check.C) {
@@ -292,6 +401,36 @@ func (s *ProvisionSuite) TestServiceUpdate(c *check.C) {
 	}, eventtest.HasEvent)
 }
 
+func (s *ProvisionSuite) TestServiceUpdateTimeoutAndRetries(c *check.C) {
+	service := service.Service{
+		Name:       "mysqlapi",
+		Endpoint:   map[string]string{"production": "sqlapi.com"},
+		OwnerTeams: []string{s.team.Name},
+		Password:   "oldold",
+		Retries:    1,
+	}
+	err := service.Create()
+	c.Assert(err, check.IsNil)
+	v := url.Values{}
+	v.Set("password", "oldold")
+	v.Set("endpoint", "sqlapi.com")
+	v.Set("timeout", "600")
+	recorder, request := s.makeRequest("PUT", "/services/mysqlapi", v.Encode(), c)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	err = s.conn.Services().Find(bson.M{"_id": service.Name}).One(&service)
+	c.Assert(err, check.IsNil)
+	c.Assert(service.Timeout, check.Equals, 600)
+	c.Assert(service.Retries, check.Equals, 1)
+	v.Set("retries", "10")
+	recorder, request = s.makeRequest("PUT", "/services/mysqlapi", v.Encode(), c)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "Service retries must be between 0 and 5\n")
+}
+
 func (s *ProvisionSuite) TestServiceUpdateWithoutTeamIgnoresOwnerTeams(c *check.C) {
 	service := service.Service{
 		Name:       "mysqlapi",
@@ -342,7 +481,7 @@ func (s *ProvisionSuite) TestServiceUpdateReturnsBadRequestWithoutPassword(c *ch
 	recorder, request := s.makeRequest("PUT", "/services/some-service", v.Encode(), c)
 	s.testServer.ServeHTTP(recorder, request)
 	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
-	c.Assert(recorder.Body.String(), check.Equals, "Service password is required\n")
+	c.Assert(recorder.Body.String(), check.Equals, "Service password is required\nService production endpoint is required\n")
 }
 
 func (s *ProvisionSuite) TestServiceUpdateReturnsBadRequestWithoutProductionEndpoint(c *check.C) {
@@ -937,6 +1076,7 @@ func (s *ProvisionSuite) TestAddDoc(c *check.C) {
 	err := s.conn.Services().Find(query).One(&serv)
 	c.Assert(err, check.IsNil)
 	c.Assert(serv.Doc, check.Equals, "doc")
+	c.Assert(serv.DocUpdatedAt.IsZero(), check.Equals, false)
 	c.Assert(eventtest.EventDesc{
 		Target: serviceTarget("some-service"),
 		Owner:  s.token.GetUserName(),
@@ -948,6 +1088,69 @@ func (s *ProvisionSuite) TestAddDoc(c *check.C) {
 	}, eventtest.HasEvent)
 }
 
+func (s *ProvisionSuite) TestServiceWebhookSet(c *check.C) {
+	se := service.Service{
+		Name:       "some-service",
+		OwnerTeams: []string{s.team.Name},
+		Endpoint:   map[string]string{"production": "http://localhost:1234"},
+		Password:   "abcde",
+	}
+	err := se.Create()
+	c.Assert(err, check.IsNil)
+	v := url.Values{}
+	v.Set("url", "https://provider.example.com/tsuru-events")
+	v.Set("secret", "s3cr3t")
+	recorder, request := s.makeRequest("PUT", "/services/some-service/webhook", v.Encode(), c)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var hook service.Webhook
+	err = json.Unmarshal(recorder.Body.Bytes(), &hook)
+	c.Assert(err, check.IsNil)
+	c.Assert(hook, check.DeepEquals, service.Webhook{URL: "https://provider.example.com/tsuru-events", Secret: "s3cr3t"})
+	var serv service.Service
+	err = s.conn.Services().FindId("some-service").One(&serv)
+	c.Assert(err, check.IsNil)
+	c.Assert(serv.Webhook, check.DeepEquals, &hook)
+	c.Assert(eventtest.EventDesc{
+		Target: serviceTarget("some-service"),
+		Owner:  s.token.GetUserName(),
+		Kind:   "service.update.webhook",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":name", "value": "some-service"},
+			{"name": "url", "value": "https://provider.example.com/tsuru-events"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *ProvisionSuite) TestServiceWebhookSetInvalidURL(c *check.C) {
+	se := service.Service{Name: "some-service", OwnerTeams: []string{s.team.Name}, Endpoint: map[string]string{"production": "http://localhost:1234"}, Password: "abcde"}
+	err := se.Create()
+	c.Assert(err, check.IsNil)
+	recorder, request := s.makeRequest("PUT", "/services/some-service/webhook", "url=ftp://example.com", c)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+}
+
+func (s *ProvisionSuite) TestServiceWebhookRemove(c *check.C) {
+	se := service.Service{Name: "some-service", OwnerTeams: []string{s.team.Name}, Endpoint: map[string]string{"production": "http://localhost:1234"}, Password: "abcde"}
+	err := se.Create()
+	c.Assert(err, check.IsNil)
+	_, err = se.SetWebhook(service.Webhook{URL: "https://provider.example.com/tsuru-events"})
+	c.Assert(err, check.IsNil)
+	recorder, request := s.makeRequest("DELETE", "/services/some-service/webhook", "", c)
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var serv service.Service
+	err = s.conn.Services().FindId("some-service").One(&serv)
+	c.Assert(err, check.IsNil)
+	c.Assert(serv.Webhook, check.IsNil)
+	recorder, request = s.makeRequest("DELETE", "/services/some-service/webhook", "", c)
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
 func (s *ProvisionSuite) TestAddDocUserHasNoAccess(c *check.C) {
 	t := authTypes.Team{Name: "new-team"}
 	err := auth.TeamService().Insert(t)
@@ -962,3 +1165,134 @@ func (s *ProvisionSuite) TestAddDocUserHasNoAccess(c *check.C) {
 	s.testServer.ServeHTTP(recorder, request)
 	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
 }
+
+func (s *ProvisionSuite) TestServiceStatus(c *check.C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/resources/plans" {
+			w.Write([]byte(`[]`))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+	se := service.Service{Name: "mysql", Endpoint: map[string]string{"production": ts.URL}, Password: "abcde", OwnerTeams: []string{s.team.Name}}
+	err := se.Create()
+	c.Assert(err, check.IsNil)
+	err = s.conn.ServiceInstances().Insert(service.ServiceInstance{Name: "db1", ServiceName: "mysql"})
+	c.Assert(err, check.IsNil)
+	recorder, request := s.makeRequest("GET", "/1.6/services/mysql/status", "", c)
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var status service.ProviderStatus
+	err = json.NewDecoder(recorder.Body).Decode(&status)
+	c.Assert(err, check.IsNil)
+	c.Assert(status.Service, check.Equals, "mysql")
+	c.Assert(status.Endpoints, check.HasLen, 1)
+	c.Assert(status.Endpoints[0].Environment, check.Equals, "production")
+	c.Assert(status.Endpoints[0].Reachable, check.Equals, true)
+	c.Assert(status.Instances, check.DeepEquals, map[string]int{"up": 1})
+}
+
+func (s *ProvisionSuite) TestServiceStatusNotFound(c *check.C) {
+	recorder, request := s.makeRequest("GET", "/1.6/services/mysql/status", "", c)
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *ProvisionSuite) TestServiceStatusUserHasNoAccess(c *check.C) {
+	t := authTypes.Team{Name: "new-team"}
+	err := auth.TeamService().Insert(t)
+	c.Assert(err, check.IsNil)
+	se := service.Service{Name: "mysql", Endpoint: map[string]string{"production": "http://localhost:1234"}, Password: "abcde", OwnerTeams: []string{t.Name}}
+	err = se.Create()
+	c.Assert(err, check.IsNil)
+	recorder, request := s.makeRequest("GET", "/1.6/services/mysql/status", "", c)
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *ProvisionSuite) TestSetServiceTeams(c *check.C) {
+	for _, name := range []string{"team1", "team2"} {
+		err := auth.TeamService().Insert(authTypes.Team{Name: name})
+		c.Assert(err, check.IsNil)
+	}
+	se := service.Service{
+		Name:       "my-service",
+		OwnerTeams: []string{s.team.Name},
+		Teams:      []string{s.team.Name},
+		Endpoint:   map[string]string{"production": "http://localhost:1234"},
+		Password:   "abcde",
+	}
+	err := se.Create()
+	c.Assert(err, check.IsNil)
+	v := url.Values{"team": []string{"team1", "team2"}}
+	recorder, request := s.makeRequest("PUT", "/1.6/services/my-service/teams", v.Encode(), c)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var diff service.TeamsDiff
+	err = json.NewDecoder(recorder.Body).Decode(&diff)
+	c.Assert(err, check.IsNil)
+	c.Assert(diff, check.DeepEquals, service.TeamsDiff{Added: []string{"team1", "team2"}, Removed: []string{s.team.Name}})
+	err = se.Get()
+	c.Assert(err, check.IsNil)
+	c.Assert(se.Teams, check.DeepEquals, []string{"team1", "team2"})
+	c.Assert(eventtest.EventDesc{
+		Target: serviceTarget("my-service"),
+		Owner:  s.token.GetUserName(),
+		Kind:   "service.update.teams",
+		StartCustomData: []map[string]interface{}{
+			{"name": ":name", "value": "my-service"},
+			{"name": "team", "value": []string{"team1", "team2"}},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *ProvisionSuite) TestSetServiceTeamsTeamNotFound(c *check.C) {
+	se := service.Service{
+		Name:       "my-service",
+		OwnerTeams: []string{s.team.Name},
+		Endpoint:   map[string]string{"production": "http://localhost:1234"},
+		Password:   "abcde",
+	}
+	err := se.Create()
+	c.Assert(err, check.IsNil)
+	v := url.Values{"team": []string{s.team.Name, "ghost"}}
+	recorder, request := s.makeRequest("PUT", "/1.6/services/my-service/teams", v.Encode(), c)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "teams not found: ghost\n")
+}
+
+func (s *ProvisionSuite) TestSetServiceTeamsWithoutTeams(c *check.C) {
+	se := service.Service{
+		Name:       "my-service",
+		OwnerTeams: []string{s.team.Name},
+		Endpoint:   map[string]string{"production": "http://localhost:1234"},
+		Password:   "abcde",
+	}
+	err := se.Create()
+	c.Assert(err, check.IsNil)
+	recorder, request := s.makeRequest("PUT", "/1.6/services/my-service/teams", "", c)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "at least one team is required\n")
+}
+
+func (s *ProvisionSuite) TestSetServiceTeamsUserHasNoAccess(c *check.C) {
+	t := authTypes.Team{Name: "new-team"}
+	err := auth.TeamService().Insert(t)
+	c.Assert(err, check.IsNil)
+	se := service.Service{Name: "my-service", Endpoint: map[string]string{"production": "http://localhost:1234"}, Password: "abcde", OwnerTeams: []string{t.Name}}
+	err = se.Create()
+	c.Assert(err, check.IsNil)
+	v := url.Values{"team": []string{t.Name}}
+	recorder, request := s.makeRequest("PUT", "/1.6/services/my-service/teams", v.Encode(), c)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
@@ -6,6 +6,7 @@ package api
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,8 +14,7 @@ import (
 	"sync"
 	"unicode"
 
-	"github.com/tsuru/tsuru/api/context"
-	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/provision"
@@ -84,47 +84,83 @@ func (l *optionalWriterCloser) Close() error {
 	return nil
 }
 
-func remoteShellHandler(ws *websocket.Conn) {
-	var httpErr *errors.HTTP
-	defer func() {
-		defer ws.Close()
-		if httpErr != nil {
-			var msg string
-			switch httpErr.Code {
-			case http.StatusUnauthorized:
-				msg = "no token provided or session expired, please login again\n"
+// resizeMessage is sent by clients that support terminal resizing, in a text
+// frame, every time their terminal is resized. The data typed by users is
+// sent in binary frames by these clients.
+type resizeMessage struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+type shellFrame struct {
+	payloadType byte
+	data        []byte
+}
+
+var shellFrameCodec = websocket.Codec{
+	Unmarshal: func(data []byte, payloadType byte, v interface{}) error {
+		frame := v.(*shellFrame)
+		frame.payloadType = payloadType
+		frame.data = data
+		return nil
+	},
+}
+
+// resizableShellConn reads the shell input from binary frames and the
+// terminal size changes from text frames, sending them to resize.
+type resizableShellConn struct {
+	*wsConn
+	resize    chan provision.TerminalSize
+	buf       []byte
+	closeOnce sync.Once
+}
+
+func newResizableShellConn(conn *wsConn) *resizableShellConn {
+	return &resizableShellConn{wsConn: conn, resize: make(chan provision.TerminalSize, 1)}
+}
+
+func (c *resizableShellConn) Read(p []byte) (int, error) {
+	for len(c.buf) == 0 {
+		var frame shellFrame
+		err := shellFrameCodec.Receive(c.ws, &frame)
+		if err != nil {
+			c.closeOnce.Do(func() { close(c.resize) })
+			return 0, err
+		}
+		if frame.payloadType == websocket.BinaryFrame {
+			c.buf = frame.data
+			continue
+		}
+		var msg resizeMessage
+		if json.Unmarshal(frame.data, &msg) != nil || msg.Width <= 0 || msg.Height <= 0 {
+			continue
+		}
+		size := provision.TerminalSize{Width: msg.Width, Height: msg.Height}
+		select {
+		case c.resize <- size:
+		default:
+			// Only the latest size matters, the pending one is replaced.
+			select {
+			case <-c.resize:
 			default:
-				msg = httpErr.Message + "\n"
 			}
-			ws.Write([]byte("Error: " + msg))
-		}
-	}()
-	r := ws.Request()
-	token := context.GetAuthToken(r)
-	if token == nil {
-		httpErr = &errors.HTTP{
-			Code:    http.StatusUnauthorized,
-			Message: "no token provided",
+			c.resize <- size
 		}
-		return
 	}
+	n := copy(p, c.buf)
+	c.buf = c.buf[n:]
+	return n, nil
+}
+
+func remoteShellHandler(conn *wsConn, r *http.Request, token auth.Token) (err error) {
 	appName := r.URL.Query().Get(":appname")
 	a, err := getAppFromContext(appName, r)
 	if err != nil {
-		if herr, ok := err.(*errors.HTTP); ok {
-			httpErr = herr
-		} else {
-			httpErr = &errors.HTTP{
-				Code:    http.StatusInternalServerError,
-				Message: err.Error(),
-			}
-		}
-		return
+		return err
 	}
 	allowed := permission.Check(token, permission.PermAppRunShell, contextsForApp(&a)...)
 	if !allowed {
-		httpErr = permission.ErrUnauthorized
-		return
+		return permission.ErrUnauthorized
 	}
 	buf := &optionalWriterCloser{}
 	var term *terminal.Terminal
@@ -141,41 +177,33 @@ func remoteShellHandler(ws *websocket.Conn) {
 		DisableLock: true,
 	})
 	if err != nil {
-		httpErr = &errors.HTTP{
-			Code:    http.StatusInternalServerError,
-			Message: err.Error(),
-		}
-		return
+		return err
 	}
 	defer func() {
-		var finalErr error
-		if httpErr != nil {
-			finalErr = httpErr
-		}
 		for term != nil {
 			buf.disableWrite = true
-			var line string
-			line, err = term.ReadLine()
-			if err != nil {
+			line, readErr := term.ReadLine()
+			if readErr != nil {
 				break
 			}
 			fmt.Fprintf(evt, "> %s\n", line)
 		}
-		evt.Done(finalErr)
+		evt.Done(err)
 	}()
 	term = terminal.NewTerminal(buf, "")
+	var shellConn io.ReadWriteCloser = conn
+	var resize <-chan provision.TerminalSize
+	if resizable, _ := strconv.ParseBool(r.URL.Query().Get("resize")); resizable {
+		resizableConn := newResizableShellConn(conn)
+		shellConn, resize = resizableConn, resizableConn.resize
+	}
 	opts := provision.ShellOptions{
-		Conn:   &cmdLogger{base: ws, term: term},
+		Conn:   &cmdLogger{base: shellConn, term: term},
 		Width:  width,
 		Height: height,
 		Unit:   unitID,
 		Term:   clientTerm,
+		Resize: resize,
 	}
-	err = a.Shell(opts)
-	if err != nil {
-		httpErr = &errors.HTTP{
-			Code:    http.StatusInternalServerError,
-			Message: err.Error(),
-		}
-	}
+	return a.Shell(opts)
 }
@@ -9,6 +9,7 @@ import (
 	"io/ioutil"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/tsuru/tsuru/app"
@@ -161,21 +162,11 @@ func (s *S) TestAppShellUnauthorizedError(c *check.C) {
 	url := fmt.Sprintf("ws://%s/apps/%s/shell?width=140&height=38&term=xterm", testServerURL.Host, a.Name)
 	config, err := websocket.NewConfig(url, "ws://localhost/")
 	c.Assert(err, check.IsNil)
-	wsConn, err := websocket.DialConfig(config)
-	c.Assert(err, check.IsNil)
-	defer wsConn.Close()
-	_, err = wsConn.Write([]byte("echo test"))
-	c.Assert(err, check.IsNil)
-	var result string
-	err = tsurutest.WaitCondition(5*time.Second, func() bool {
-		part, readErr := ioutil.ReadAll(wsConn)
-		if readErr != nil {
-			return false
-		}
-		result += string(part)
-		return result == "Error: no token provided or session expired, please login again\n"
-	})
-	c.Assert(err, check.IsNil)
+	_, err = websocket.DialConfig(config)
+	c.Assert(err, check.NotNil)
+	dialErr, ok := err.(*websocket.DialError)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(dialErr.Err, check.Equals, websocket.ErrBadStatus)
 }
 
 func (s *S) TestAppShellGenericError(c *check.C) {
@@ -204,3 +195,47 @@ func (s *S) TestAppShellGenericError(c *check.C) {
 	})
 	c.Assert(err, check.IsNil)
 }
+
+func (s *S) TestResizableShellConn(c *check.C) {
+	type result struct {
+		input []byte
+		sizes []provision.TerminalSize
+	}
+	done := make(chan result)
+	server := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		conn := newResizableShellConn(newWSConn(ws, nil))
+		defer conn.Close()
+		var r result
+		sizesDone := make(chan struct{})
+		go func() {
+			defer close(sizesDone)
+			for size := range conn.resize {
+				r.sizes = append(r.sizes, size)
+			}
+		}()
+		r.input, _ = ioutil.ReadAll(conn)
+		<-sizesDone
+		done <- r
+	}))
+	defer server.Close()
+	config, err := websocket.NewConfig("ws"+strings.TrimPrefix(server.URL, "http"), "ws://localhost/")
+	c.Assert(err, check.IsNil)
+	wsConn, err := websocket.DialConfig(config)
+	c.Assert(err, check.IsNil)
+	err = websocket.Message.Send(wsConn, []byte("ls"))
+	c.Assert(err, check.IsNil)
+	err = websocket.JSON.Send(wsConn, resizeMessage{Width: 100, Height: 30})
+	c.Assert(err, check.IsNil)
+	err = websocket.Message.Send(wsConn, "invalid")
+	c.Assert(err, check.IsNil)
+	err = websocket.Message.Send(wsConn, []byte(" -la\n"))
+	c.Assert(err, check.IsNil)
+	wsConn.Close()
+	select {
+	case r := <-done:
+		c.Assert(string(r.input), check.Equals, "ls -la\n")
+		c.Assert(r.sizes, check.DeepEquals, []provision.TerminalSize{{Width: 100, Height: 30}})
+	case <-time.After(5 * time.Second):
+		c.Fatal("timeout waiting for shell input")
+	}
+}
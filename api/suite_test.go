@@ -20,6 +20,7 @@ import (
 	"github.com/tsuru/tsuru/autoscale"
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/db/dbtest"
+	"github.com/tsuru/tsuru/db/dbtest/fixture"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/permission/permissiontest"
 	"github.com/tsuru/tsuru/provision"
@@ -46,6 +47,7 @@ type S struct {
 	provisioner *provisiontest.FakeProvisioner
 	Pool        string
 	testServer  http.Handler
+	snapshot    *fixture.Snapshot
 }
 
 var _ = check.Suite(&S{})
@@ -83,9 +85,10 @@ func (s *S) createUserAndTeam(c *check.C) {
 	var err error
 	s.user, err = s.token.User()
 	c.Assert(err, check.IsNil)
-	s.team = &authTypes.Team{Name: "tsuruteam"}
-	err = auth.TeamService().Insert(*s.team)
+	d := &fixture.Dataset{Teams: []authTypes.Team{{Name: "tsuruteam"}}}
+	s.snapshot, err = fixture.Load(d)
 	c.Assert(err, check.IsNil)
+	s.team = &d.Teams[0]
 }
 
 var nativeScheme = auth.ManagedScheme(native.NativeScheme{})
@@ -135,6 +138,7 @@ func (s *S) TearDownTest(c *check.C) {
 		cfg.Shutdown(stdcontext.Background())
 	}
 	s.provisioner.Reset()
+	c.Assert(s.snapshot.Restore(), check.IsNil)
 	s.conn.Close()
 	s.logConn.Close()
 }
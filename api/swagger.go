@@ -0,0 +1,325 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/go-openapi/spec"
+)
+
+const swaggerSecurityName = "Bearer"
+
+var (
+	pathVarRegexp = regexp.MustCompile(`{([^}:]+)(:[^}]*)?}`)
+	timeType      = reflect.TypeOf(time.Time{})
+)
+
+type swaggerHandler struct {
+	routes []route
+}
+
+// title: api specification
+// path: /swagger.json
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+func (h *swaggerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(buildSwagger(h.routes))
+}
+
+// buildSwagger generates the OpenAPI (Swagger 2.0) document describing the
+// given routes.
+func buildSwagger(routes []route) *spec.Swagger {
+	doc := &spec.Swagger{
+		SwaggerProps: spec.SwaggerProps{
+			Swagger: "2.0",
+			Info: &spec.Info{
+				InfoProps: spec.InfoProps{
+					Title:   "tsuru",
+					Version: Version,
+				},
+			},
+			Consumes:    []string{"application/x-www-form-urlencoded"},
+			Produces:    []string{"application/json"},
+			Paths:       &spec.Paths{Paths: map[string]spec.PathItem{}},
+			Definitions: spec.Definitions{},
+			SecurityDefinitions: spec.SecurityDefinitions{
+				swaggerSecurityName: spec.APIKeyAuth("Authorization", "header"),
+			},
+		},
+	}
+	usedIDs := map[string]int{}
+	for _, r := range routes {
+		path, params := swaggerPath(r.path)
+		methods := []string{r.method}
+		if r.method == "" {
+			methods = []string{"GET", "POST", "PUT", "DELETE"}
+		}
+		for _, method := range methods {
+			op := swaggerOperation(r, method, params, doc.Definitions)
+			if count := usedIDs[op.ID]; count > 0 {
+				usedIDs[op.ID]++
+				op.ID = fmt.Sprintf("%s%d", op.ID, count+1)
+			} else {
+				usedIDs[op.ID] = 1
+			}
+			item := doc.Paths.Paths[path]
+			setPathOperation(&item, method, op)
+			doc.Paths.Paths[path] = item
+		}
+	}
+	return doc
+}
+
+func swaggerPath(muxPath string) (string, []string) {
+	var params []string
+	for _, match := range pathVarRegexp.FindAllStringSubmatch(muxPath, -1) {
+		params = append(params, match[1])
+	}
+	return pathVarRegexp.ReplaceAllString(muxPath, "{$1}"), params
+}
+
+func swaggerOperation(r route, method string, pathParams []string, defs spec.Definitions) *spec.Operation {
+	op := spec.NewOperation(handlerName(r.handler))
+	op.Deprecated = r.deprecated
+	op.AddExtension("x-tsuru-version", r.version)
+	if r.permission != nil {
+		op.AddExtension("x-tsuru-permission", r.permission.FullName())
+	}
+	if r.scope != nil {
+		op.AddExtension("x-tsuru-permission-scope", r.scope.name)
+	}
+	switch r.handler.(type) {
+	case AuthorizationRequiredHandler, *wsHandler:
+		op.Security = []map[string][]string{{swaggerSecurityName: {}}}
+	}
+	for _, p := range pathParams {
+		param := spec.PathParam(p)
+		param.Type = "string"
+		op.Parameters = append(op.Parameters, *param)
+	}
+	if r.request != nil {
+		in := "formData"
+		if method == "GET" || method == "DELETE" {
+			in = "query"
+		}
+		op.Parameters = append(op.Parameters, formParameters(reflect.TypeOf(r.request), "", in)...)
+	}
+	resp := spec.NewResponse().WithDescription("OK")
+	if r.response != nil {
+		resp.WithSchema(typeSchema(reflect.TypeOf(r.response), defs))
+	}
+	op.RespondsWith(http.StatusOK, resp)
+	if op.Security != nil {
+		op.RespondsWith(http.StatusUnauthorized, spec.NewResponse().WithDescription("Unauthorized"))
+	}
+	if r.permission != nil {
+		op.RespondsWith(http.StatusForbidden, spec.NewResponse().WithDescription("Forbidden"))
+	}
+	return op
+}
+
+func setPathOperation(item *spec.PathItem, method string, op *spec.Operation) {
+	switch strings.ToUpper(method) {
+	case "GET":
+		item.Get = op
+	case "POST":
+		item.Post = op
+	case "PUT":
+		item.Put = op
+	case "DELETE":
+		item.Delete = op
+	case "PATCH":
+		item.Patch = op
+	}
+}
+
+// handlerName returns the name of the function behind h, used as the
+// operation ID in the generated document.
+func handlerName(h http.Handler) string {
+	if ws, ok := h.(*wsHandler); ok {
+		return funcName(reflect.ValueOf(ws.handle))
+	}
+	v := reflect.ValueOf(h)
+	if v.Kind() == reflect.Ptr {
+		return v.Elem().Type().Name()
+	}
+	if v.Kind() != reflect.Func {
+		return v.Type().Name()
+	}
+	return funcName(v)
+}
+
+func funcName(v reflect.Value) string {
+	name := runtime.FuncForPC(v.Pointer()).Name()
+	if idx := strings.LastIndex(name, "."); idx != -1 {
+		name = name[idx+1:]
+	}
+	return strings.TrimSuffix(name, "-fm")
+}
+
+// formParameters flattens a type into form parameters, using the same naming
+// rules applied by the form decoder used in the handlers.
+func formParameters(t reflect.Type, prefix, in string) []spec.Parameter {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == timeType {
+		return nil
+	}
+	var params []spec.Parameter
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := field.Name
+		if tag := strings.Split(field.Tag.Get("form"), ",")[0]; tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType.Kind() == reflect.Struct && fieldType != timeType {
+			params = append(params, formParameters(fieldType, name, in)...)
+			continue
+		}
+		param := spec.Parameter{ParamProps: spec.ParamProps{Name: name, In: in}}
+		switch fieldType.Kind() {
+		case reflect.Slice, reflect.Array:
+			itemType, itemFormat := simpleType(fieldType.Elem())
+			param.Type = "array"
+			param.CollectionFormat = "multi"
+			param.Items = spec.NewItems().Typed(itemType, itemFormat)
+		case reflect.Map:
+			// maps are encoded as name.key=value pairs, which can't be
+			// described as a single parameter.
+			continue
+		default:
+			param.Type, param.Format = simpleType(fieldType)
+		}
+		params = append(params, param)
+	}
+	return params
+}
+
+func simpleType(t reflect.Type) (string, string) {
+	if t == timeType {
+		return "string", "date-time"
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean", ""
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return "integer", "int32"
+	case reflect.Int64, reflect.Uint64:
+		return "integer", "int64"
+	case reflect.Float32:
+		return "number", "float"
+	case reflect.Float64:
+		return "number", "double"
+	}
+	return "string", ""
+}
+
+// typeSchema returns the JSON schema for t, registering named struct types
+// in defs and referencing them.
+func typeSchema(t reflect.Type, defs spec.Definitions) *spec.Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return spec.StrFmtProperty("byte")
+		}
+		return spec.ArrayProperty(typeSchema(t.Elem(), defs))
+	case reflect.Map:
+		return spec.MapProperty(typeSchema(t.Elem(), defs))
+	case reflect.Interface:
+		return &spec.Schema{}
+	case reflect.Struct:
+		if t == timeType {
+			return spec.DateTimeProperty()
+		}
+		if t.Name() == "" {
+			return structSchema(t, defs)
+		}
+		name := definitionName(t)
+		if _, ok := defs[name]; !ok {
+			// registers a placeholder to stop recursion on self
+			// referencing types.
+			defs[name] = spec.Schema{}
+			defs[name] = *structSchema(t, defs)
+		}
+		return spec.RefProperty("#/definitions/" + name)
+	}
+	typ, format := simpleType(t)
+	schema := &spec.Schema{}
+	schema.Typed(typ, format)
+	return schema
+}
+
+func structSchema(t reflect.Type, defs spec.Definitions) *spec.Schema {
+	schema := &spec.Schema{}
+	schema.Typed("object", "")
+	schema.Properties = map[string]spec.Schema{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := field.Name
+		tag := strings.Split(field.Tag.Get("json"), ",")[0]
+		if tag == "-" {
+			continue
+		}
+		if tag != "" {
+			name = tag
+		}
+		if field.Anonymous && tag == "" {
+			embedded := field.Type
+			for embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				for k, v := range structSchema(embedded, defs).Properties {
+					schema.Properties[k] = v
+				}
+				continue
+			}
+		}
+		schema.Properties[name] = *typeSchema(field.Type, defs)
+	}
+	return schema
+}
+
+func definitionName(t reflect.Type) string {
+	pkg := t.PkgPath()
+	if idx := strings.LastIndex(pkg, "/"); idx != -1 {
+		pkg = pkg[idx+1:]
+	}
+	if pkg == "" {
+		return t.Name()
+	}
+	return pkg + "." + t.Name()
+}
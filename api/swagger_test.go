@@ -0,0 +1,76 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/go-openapi/spec"
+	"github.com/tsuru/tsuru/permission"
+	appTypes "github.com/tsuru/tsuru/types/app"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestSwaggerSpec(c *check.C) {
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/swagger.json", nil)
+	c.Assert(err, check.IsNil)
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var doc spec.Swagger
+	err = json.Unmarshal(recorder.Body.Bytes(), &doc)
+	c.Assert(err, check.IsNil)
+	c.Assert(doc.Swagger, check.Equals, "2.0")
+	c.Assert(doc.Info.Version, check.Equals, Version)
+	item, ok := doc.Paths.Paths["/apps/{app}/env"]
+	c.Assert(ok, check.Equals, true)
+	c.Assert(item.Get, check.NotNil)
+	c.Assert(item.Get.ID, check.Equals, "getEnv")
+	c.Assert(item.Get.Extensions["x-tsuru-permission"], check.Equals, "app.read.env")
+	c.Assert(item.Get.Security, check.HasLen, 1)
+	c.Assert(item.Get.Parameters, check.HasLen, 1)
+	c.Assert(item.Get.Parameters[0].Name, check.Equals, "app")
+	c.Assert(item.Get.Parameters[0].In, check.Equals, "path")
+	c.Assert(item.Post, check.NotNil)
+	c.Assert(item.Delete, check.NotNil)
+	_, ok = doc.Paths.Paths["/node/{address}/containers"]
+	c.Assert(ok, check.Equals, true)
+	item = doc.Paths.Paths["/docker/node"]
+	c.Assert(item.Get.Deprecated, check.Equals, true)
+}
+
+func (s *S) TestSwaggerSpecFromRoutes(c *check.C) {
+	routes := []route{
+		{version: "1.5", method: "POST", path: "/apps/{app}/routers", handler: AuthorizationRequiredHandler(addAppRouter), permission: permission.PermAppUpdateRouterAdd, scope: appScope, request: appTypes.AppRouter{}},
+		{version: "1.5", method: "GET", path: "/apps/{app}/routers", handler: AuthorizationRequiredHandler(listAppRouters), response: []appTypes.AppRouter{}},
+		{version: "1.0", path: "/proxy/{name:.*}", handler: Handler(info)},
+	}
+	doc := buildSwagger(routes)
+	c.Assert(doc.Paths.Paths, check.HasLen, 2)
+	post := doc.Paths.Paths["/apps/{app}/routers"].Post
+	c.Assert(post.ID, check.Equals, "addAppRouter")
+	c.Assert(post.Extensions["x-tsuru-version"], check.Equals, "1.5")
+	c.Assert(post.Extensions["x-tsuru-permission"], check.Equals, "app.update.router.add")
+	c.Assert(post.Extensions["x-tsuru-permission-scope"], check.Equals, "app")
+	var formParams []string
+	for _, p := range post.Parameters {
+		if p.In == "formData" {
+			formParams = append(formParams, p.Name)
+		}
+	}
+	c.Assert(formParams, check.DeepEquals, []string{"Name", "Address"})
+	get := doc.Paths.Paths["/apps/{app}/routers"].Get
+	c.Assert(get.Responses.StatusCodeResponses[http.StatusOK].Schema.Items.Schema.Ref.String(), check.Equals, "#/definitions/app.AppRouter")
+	c.Assert(doc.Definitions["app.AppRouter"].Properties, check.HasLen, 3)
+	proxy := doc.Paths.Paths["/proxy/{name}"]
+	c.Assert(proxy.Get, check.NotNil)
+	c.Assert(proxy.Get.Security, check.IsNil)
+	c.Assert(proxy.Put, check.NotNil)
+	c.Assert(proxy.Post.ID, check.Equals, "info2")
+	c.Assert(proxy.Delete.ID, check.Equals, "info4")
+}
@@ -0,0 +1,138 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestTeamPolicyUpdate(c *check.C) {
+	body := strings.NewReader("allowedplatform=zend&allowedplatform=heimerdinger")
+	request, err := http.NewRequest("PUT", "/teams/"+s.team.Name+"/policy", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK, check.Commentf("body: %s", recorder.Body.String()))
+	policy, err := app.GetTeamPolicy(s.team.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(policy, check.DeepEquals, &app.TeamPolicy{Team: s.team.Name, AllowedPlatforms: []string{"zend", "heimerdinger"}})
+	c.Assert(eventtest.EventDesc{
+		Target: teamTarget(s.team.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "team.policy.update",
+		StartCustomData: []map[string]interface{}{
+			{"name": "allowedplatform", "value": []string{"zend", "heimerdinger"}},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestTeamPolicyUpdateInvalid(c *check.C) {
+	body := strings.NewReader("defaultplan=unknown")
+	request, err := http.NewRequest("PUT", "/teams/"+s.team.Name+"/policy", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "plan \"unknown\" not found\n")
+}
+
+func (s *S) TestTeamPolicyUpdateTeamNotFound(c *check.C) {
+	request, err := http.NewRequest("PUT", "/teams/unknown/policy", strings.NewReader(""))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestTeamPolicyUpdateWithoutPermission(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermTeamPolicyRead,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	request, err := http.NewRequest("PUT", "/teams/"+s.team.Name+"/policy", strings.NewReader("allowedplatform=zend"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestTeamPolicyInfo(c *check.C) {
+	err := app.SaveTeamPolicy(app.TeamPolicy{Team: s.team.Name, AllowedPlatforms: []string{"zend"}})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermTeamPolicyRead,
+		Context: permission.Context(permission.CtxTeam, s.team.Name),
+	})
+	request, err := http.NewRequest("GET", "/teams/"+s.team.Name+"/policy", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var policy app.TeamPolicy
+	err = json.Unmarshal(recorder.Body.Bytes(), &policy)
+	c.Assert(err, check.IsNil)
+	c.Assert(policy, check.DeepEquals, app.TeamPolicy{Team: s.team.Name, AllowedPlatforms: []string{"zend"}})
+}
+
+func (s *S) TestTeamPolicyInfoNotFound(c *check.C) {
+	request, err := http.NewRequest("GET", "/teams/"+s.team.Name+"/policy", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestTeamPolicyDelete(c *check.C) {
+	err := app.SaveTeamPolicy(app.TeamPolicy{Team: s.team.Name})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", "/teams/"+s.team.Name+"/policy", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	_, err = app.GetTeamPolicy(s.team.Name)
+	c.Assert(err, check.Equals, app.ErrTeamPolicyNotFound)
+	c.Assert(eventtest.EventDesc{
+		Target: teamTarget(s.team.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "team.policy.delete",
+	}, eventtest.HasEvent)
+	recorder = httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestCreateAppPlatformNotAllowedByTeamPolicy(c *check.C) {
+	err := app.SaveTeamPolicy(app.TeamPolicy{Team: s.team.Name, AllowedPlatforms: []string{"zend"}})
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/apps", strings.NewReader("name=someapp&platform=heimerdinger"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "platform \"heimerdinger\" is not allowed for team \""+s.team.Name+"\", allowed platforms: zend\n")
+}
@@ -0,0 +1,79 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api/context"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+)
+
+var versionPrefixRegexp = regexp.MustCompile(`^/[0-9]+\.[0-9]+/`)
+
+// configureTLS sets up the TLS listener of the API server. Clients may
+// present certificates signed by the CA in tls:client-ca-file, which are
+// then required in the paths listed in tls:client-auth-paths. HTTP/2 is
+// negotiated with clients supporting it, unless tls:disable-http2 is set.
+func configureTLS(srv *http.Server) error {
+	tlsConfig := &tls.Config{}
+	caFile, _ := config.GetString("tls:client-ca-file")
+	if caFile != "" {
+		data, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return errors.Wrap(err, "unable to read tls:client-ca-file")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return errors.Errorf("no certificates found in %s", caFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	} else if paths, _ := config.GetList("tls:client-auth-paths"); len(paths) > 0 {
+		return errors.New("tls:client-ca-file is required when tls:client-auth-paths is set")
+	}
+	srv.TLSConfig = tlsConfig
+	if disabled, _ := config.GetBool("tls:disable-http2"); disabled {
+		srv.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+	}
+	return nil
+}
+
+// clientCertMiddleware rejects requests to the paths set in
+// tls:client-auth-paths, typically admin routes, made without a verified
+// client certificate.
+type clientCertMiddleware struct {
+	paths []string
+}
+
+func newClientCertMiddleware() *clientCertMiddleware {
+	paths, _ := config.GetList("tls:client-auth-paths")
+	return &clientCertMiddleware{paths: paths}
+}
+
+func (m *clientCertMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if !m.requiresCert(r.URL.Path) || (r.TLS != nil && len(r.TLS.VerifiedChains) > 0) {
+		next(w, r)
+		return
+	}
+	context.AddRequestError(r, &tsuruErrors.HTTP{Code: http.StatusForbidden, Message: "a valid client certificate is required"})
+}
+
+func (m *clientCertMiddleware) requiresCert(path string) bool {
+	path = versionPrefixRegexp.ReplaceAllString(path, "/")
+	for _, p := range m.paths {
+		if path == p || strings.HasPrefix(path, strings.TrimSuffix(p, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
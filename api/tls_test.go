@@ -0,0 +1,80 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestConfigureTLS(c *check.C) {
+	var srv http.Server
+	err := configureTLS(&srv)
+	c.Assert(err, check.IsNil)
+	c.Assert(srv.TLSConfig.ClientCAs, check.IsNil)
+	c.Assert(srv.TLSConfig.ClientAuth, check.Equals, tls.NoClientCert)
+	c.Assert(srv.TLSNextProto, check.IsNil)
+}
+
+func (s *S) TestConfigureTLSClientCA(c *check.C) {
+	config.Set("tls:client-ca-file", "../app/testdata/certificate.crt")
+	config.Set("tls:disable-http2", true)
+	defer config.Unset("tls")
+	var srv http.Server
+	err := configureTLS(&srv)
+	c.Assert(err, check.IsNil)
+	c.Assert(srv.TLSConfig.ClientCAs, check.NotNil)
+	c.Assert(srv.TLSConfig.ClientAuth, check.Equals, tls.VerifyClientCertIfGiven)
+	c.Assert(srv.TLSNextProto, check.NotNil)
+	c.Assert(srv.TLSNextProto, check.HasLen, 0)
+}
+
+func (s *S) TestConfigureTLSInvalid(c *check.C) {
+	config.Set("tls:client-auth-paths", []interface{}{"/node"})
+	defer config.Unset("tls")
+	var srv http.Server
+	err := configureTLS(&srv)
+	c.Assert(err, check.ErrorMatches, "tls:client-ca-file is required when tls:client-auth-paths is set")
+	config.Set("tls:client-ca-file", "testdata/config.yaml")
+	err = configureTLS(&srv)
+	c.Assert(err, check.ErrorMatches, "no certificates found in testdata/config.yaml")
+}
+
+func (s *S) TestClientCertMiddlewareRequiresCert(c *check.C) {
+	m := clientCertMiddleware{paths: []string{"/node", "/roles/"}}
+	c.Assert(m.requiresCert("/node"), check.Equals, true)
+	c.Assert(m.requiresCert("/1.6/node/autoscale"), check.Equals, true)
+	c.Assert(m.requiresCert("/roles/admin"), check.Equals, true)
+	c.Assert(m.requiresCert("/nodes"), check.Equals, false)
+	c.Assert(m.requiresCert("/apps"), check.Equals, false)
+}
+
+func (s *S) TestClientCertMiddleware(c *check.C) {
+	config.Set("tls:client-auth-paths", []interface{}{"/plans"})
+	defer config.Unset("tls")
+	server := RunServer(true)
+	request, err := http.NewRequest("GET", "/plans", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	c.Assert(recorder.Body.String(), check.Equals, "a valid client certificate is required\n")
+	request.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}}
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Not(check.Equals), http.StatusForbidden)
+	request, err = http.NewRequest("GET", "/apps", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Not(check.Equals), http.StatusForbidden)
+}
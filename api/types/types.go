@@ -5,9 +5,13 @@
 package types
 
 // Envs represents the configuration of an environment variable data
-// for the remote API
+// for the remote API. Unset lists the names of variables removed along
+// with the ones being set, and Interpolate enables the expansion of
+// ${NAME} references in their values.
 type Envs struct {
-	Envs      []struct{ Name, Value string }
-	NoRestart bool
-	Private   bool
+	Envs        []struct{ Name, Value string }
+	Unset       []string `form:",omitempty"`
+	NoRestart   bool
+	Private     bool
+	Interpolate bool `form:",omitempty"`
 }
@@ -0,0 +1,133 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/service"
+)
+
+type usageResponse struct {
+	Teams []teamUsage `json:"teams"`
+	Pools []poolUsage `json:"pools"`
+}
+
+type teamUsage struct {
+	Team             string `json:"team"`
+	Apps             int    `json:"apps"`
+	Units            int    `json:"units"`
+	ServiceInstances int    `json:"serviceInstances"`
+	Memory           int64  `json:"memory"`
+}
+
+type poolUsage struct {
+	Pool            string `json:"pool"`
+	Nodes           int    `json:"nodes"`
+	Units           int    `json:"units"`
+	MemoryCapacity  int64  `json:"memoryCapacity"`
+	MemoryAllocated int64  `json:"memoryAllocated"`
+}
+
+// title: usage report
+// path: /usage
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   401: Unauthorized
+func usageReport(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermUsageRead) {
+		return permission.ErrUnauthorized
+	}
+	apps, err := app.List(nil)
+	if err != nil {
+		return err
+	}
+	instances, err := service.GetServicesInstancesByTeamsAndNames(nil, nil, "", "")
+	if err != nil {
+		return err
+	}
+	provs, err := provision.Registry()
+	if err != nil {
+		return err
+	}
+	teams := map[string]*teamUsage{}
+	getTeam := func(name string) *teamUsage {
+		if teams[name] == nil {
+			teams[name] = &teamUsage{Team: name}
+		}
+		return teams[name]
+	}
+	appsMap := make(map[string]*app.App, len(apps))
+	for i := range apps {
+		appsMap[apps[i].Name] = &apps[i]
+		getTeam(apps[i].TeamOwner).Apps++
+	}
+	for _, si := range instances {
+		getTeam(si.TeamOwner).ServiceInstances++
+	}
+	memoryMetadata, _ := config.GetString("docker:scheduler:total-memory-metadata")
+	pools := map[string]*poolUsage{}
+	for _, prov := range provs {
+		nodeProv, ok := prov.(provision.NodeProvisioner)
+		if !ok {
+			continue
+		}
+		nodes, err := nodeProv.ListNodes(nil)
+		if err != nil {
+			return err
+		}
+		for _, n := range nodes {
+			pool := pools[n.Pool()]
+			if pool == nil {
+				pool = &poolUsage{Pool: n.Pool()}
+				pools[n.Pool()] = pool
+			}
+			pool.Nodes++
+			if memoryMetadata != "" {
+				totalMemory, _ := strconv.ParseFloat(n.Metadata()[memoryMetadata], 64)
+				pool.MemoryCapacity += int64(totalMemory)
+			}
+			units, err := n.Units()
+			if err != nil {
+				return err
+			}
+			for _, u := range units {
+				pool.Units++
+				a := appsMap[u.AppName]
+				if a == nil {
+					continue
+				}
+				team := getTeam(a.TeamOwner)
+				team.Units++
+				team.Memory += a.Plan.Memory
+				pool.MemoryAllocated += a.Plan.Memory
+			}
+		}
+	}
+	result := usageResponse{
+		Teams: make([]teamUsage, 0, len(teams)),
+		Pools: make([]poolUsage, 0, len(pools)),
+	}
+	for _, team := range teams {
+		result.Teams = append(result.Teams, *team)
+	}
+	for _, pool := range pools {
+		result.Pools = append(result.Pools, *pool)
+	}
+	sort.Slice(result.Teams, func(i, j int) bool { return result.Teams[i].Team < result.Teams[j].Team })
+	sort.Slice(result.Pools, func(i, j int) bool { return result.Pools[i].Pool < result.Pools[j].Pool })
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(result)
+}
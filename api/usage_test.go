@@ -0,0 +1,89 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/service"
+	appTypes "github.com/tsuru/tsuru/types/app"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestUsageReport(c *check.C) {
+	config.Set("docker:scheduler:total-memory-metadata", "totalMemory")
+	defer config.Unset("docker:scheduler:total-memory-metadata")
+	plan := appTypes.Plan{Name: "small", Memory: 1024, CpuShare: 100}
+	err := app.SavePlan(plan)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddNode(provision.AddNodeOptions{
+		Address:  "n1",
+		Pool:     "pool1",
+		Metadata: map[string]string{"totalMemory": "4096"},
+	})
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddNode(provision.AddNodeOptions{
+		Address:  "n2",
+		Pool:     "pool2",
+		Metadata: map[string]string{"totalMemory": "8192"},
+	})
+	c.Assert(err, check.IsNil)
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name, Plan: plan}
+	err = app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	_, err = s.provisioner.AddUnitsToNode(&a, 3, "web", nil, "n1")
+	c.Assert(err, check.IsNil)
+	_, err = s.provisioner.AddUnitsToNode(&a, 1, "web", nil, "n2")
+	c.Assert(err, check.IsNil)
+	err = s.conn.ServiceInstances().Insert(service.ServiceInstance{
+		Name:        "my-mysql",
+		ServiceName: "mysql",
+		Teams:       []string{s.team.Name},
+		TeamOwner:   s.team.Name,
+	})
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermUsageRead,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	request, err := http.NewRequest("GET", "/usage", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result usageResponse
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, usageResponse{
+		Teams: []teamUsage{
+			{Team: s.team.Name, Apps: 1, Units: 4, ServiceInstances: 1, Memory: 4096},
+		},
+		Pools: []poolUsage{
+			{Pool: "pool1", Nodes: 1, Units: 3, MemoryCapacity: 4096, MemoryAllocated: 3072},
+			{Pool: "pool2", Nodes: 1, Units: 1, MemoryCapacity: 8192, MemoryAllocated: 1024},
+		},
+	})
+}
+
+func (s *S) TestUsageReportWithoutPermission(c *check.C) {
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppRead,
+		Context: permission.Context(permission.CtxGlobal, ""),
+	})
+	request, err := http.NewRequest("GET", "/usage", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	s.testServer.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
@@ -0,0 +1,267 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api/context"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"golang.org/x/net/websocket"
+)
+
+const defaultWebsocketPingInterval = 30 * time.Second
+
+var errWebsocketRequired = &errors.HTTP{
+	Code:    http.StatusBadRequest,
+	Message: "this endpoint requires a websocket connection",
+}
+
+// wsHandler serves endpoints that talk to clients through websockets.
+//
+// Clients are authenticated during the handshake, using the same
+// Authorization header used by regular handlers, and handshakes without a
+// valid token are refused before the connection is upgraded. After the
+// upgrade, the connection is kept alive by ping frames sent periodically and
+// is closed when, for two ping intervals, nothing, not even a pong, is
+// received from the client and no message is written to it. Connections are
+// always finished with a close frame. The error returned by handle is
+// reported to the client before closing the connection: as a final
+// {"error": "..."} message for JSON streams or as a plain text line for raw
+// connections.
+type wsHandler struct {
+	// raw indicates that the connection transports raw terminal data instead
+	// of JSON messages.
+	raw    bool
+	handle func(conn *wsConn, r *http.Request, t auth.Token) error
+}
+
+func (h *wsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t := context.GetAuthToken(r)
+	if t == nil {
+		w.Header().Set("WWW-Authenticate", "Bearer realm=\"tsuru\" scope=\"tsuru\"")
+		context.AddRequestError(r, tokenRequiredErr)
+		return
+	}
+	if !isWebsocketRequest(r) {
+		context.AddRequestError(r, errWebsocketRequired)
+		return
+	}
+	rw := &wsResponseWriter{ResponseWriter: w, idleTimeout: 2 * websocketPingInterval()}
+	server := websocket.Server{
+		// Clients are authenticated by their tokens, the origin is not
+		// relevant as it's not sent by most non-browser clients.
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(ws *websocket.Conn) {
+			conn := newWSConn(ws, rw.conn)
+			defer conn.Close()
+			err := h.handle(conn, r, t)
+			if err != nil {
+				logger.Errorf("failure in websocket handler for %s: %s", r.URL.Path, err)
+			}
+			conn.finish(err, h.raw)
+		},
+	}
+	server.ServeHTTP(rw, r)
+}
+
+func isWebsocketRequest(r *http.Request) bool {
+	return strings.ToLower(r.Header.Get("Upgrade")) == "websocket"
+}
+
+func websocketPingInterval() time.Duration {
+	interval, _ := config.GetFloat("server:websocket-ping-interval")
+	if interval <= 0 {
+		return defaultWebsocketPingInterval
+	}
+	return time.Duration(interval * float64(time.Second))
+}
+
+// wsResponseWriter wraps the connection hijacked by the websocket server so
+// that its read deadline is extended whenever data arrives from the client.
+// Pong frames are consumed by the websocket package without reaching
+// handlers, so the deadline must be tracked below the frame level. The
+// deadline is also extended by the messages written by handlers, so clients
+// that only receive data are not dropped when they don't answer pings.
+type wsResponseWriter struct {
+	http.ResponseWriter
+	idleTimeout time.Duration
+	conn        *idleTimeoutConn
+}
+
+func (w *wsResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := w.ResponseWriter.(http.Hijacker).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	idleConn := &idleTimeoutConn{Conn: conn, timeout: w.idleTimeout}
+	idleConn.extendDeadline()
+	w.conn = idleConn
+	buffered, _ := rw.Reader.Peek(rw.Reader.Buffered())
+	reader := io.MultiReader(bytes.NewReader(buffered), idleConn)
+	return idleConn, bufio.NewReadWriter(bufio.NewReader(reader), rw.Writer), nil
+}
+
+type idleTimeoutConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *idleTimeoutConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.extendDeadline()
+	}
+	return n, err
+}
+
+func (c *idleTimeoutConn) extendDeadline() {
+	c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+}
+
+// wsConn wraps a websocket connection, serializing writes so that ping
+// frames may be sent concurrently with the messages written by handlers.
+type wsConn struct {
+	ws        *websocket.Conn
+	idle      *idleTimeoutConn
+	writeMu   sync.Mutex
+	closeOnce sync.Once
+	quit      chan struct{}
+}
+
+func newWSConn(ws *websocket.Conn, idle *idleTimeoutConn) *wsConn {
+	conn := &wsConn{ws: ws, idle: idle, quit: make(chan struct{})}
+	go conn.keepAlive(websocketPingInterval())
+	return conn
+}
+
+func (c *wsConn) keepAlive(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.quit:
+			return
+		case <-ticker.C:
+		}
+		if err := c.ping(interval); err != nil {
+			logger.Debugf("unable to ping websocket client, closing connection: %s", err)
+			c.Close()
+			return
+		}
+	}
+}
+
+func (c *wsConn) ping(timeout time.Duration) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.ws.SetWriteDeadline(time.Now().Add(timeout))
+	defer c.ws.SetWriteDeadline(time.Time{})
+	payloadType := c.ws.PayloadType
+	c.ws.PayloadType = websocket.PingFrame
+	defer func() { c.ws.PayloadType = payloadType }()
+	_, err := c.ws.Write(nil)
+	return err
+}
+
+func (c *wsConn) Read(p []byte) (int, error) {
+	return c.ws.Read(p)
+}
+
+func (c *wsConn) Write(p []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	n, err := c.ws.Write(p)
+	if err == nil {
+		c.written()
+	}
+	return n, err
+}
+
+// SendJSON writes v as a single JSON message.
+func (c *wsConn) SendJSON(v interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	err := websocket.JSON.Send(c.ws, v)
+	if err == nil {
+		c.written()
+	}
+	return err
+}
+
+// written extends the idle deadline after a message is successfully written.
+// Pings are not considered, otherwise connections would never be idle.
+func (c *wsConn) written() {
+	if c.idle != nil {
+		c.idle.extendDeadline()
+	}
+}
+
+// ReceiveJSON reads a single JSON message into v.
+func (c *wsConn) ReceiveJSON(v interface{}) error {
+	return websocket.JSON.Receive(c.ws, v)
+}
+
+// Done returns a channel that is closed when the connection is closed.
+func (c *wsConn) Done() <-chan struct{} {
+	return c.quit
+}
+
+// CloseOnClientEOF reads and discards everything sent by the client, closing
+// the connection as soon as the client goes away. It's used by endpoints that
+// only stream data to clients.
+func (c *wsConn) CloseOnClientEOF() {
+	io.Copy(ioutil.Discard, c.ws)
+	c.Close()
+}
+
+// Close sends the close frame and closes the underlying connection. It's
+// safe to call Close multiple times.
+func (c *wsConn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.quit)
+		c.writeMu.Lock()
+		defer c.writeMu.Unlock()
+		err = c.ws.Close()
+	})
+	return err
+}
+
+func (c *wsConn) finish(err error, raw bool) {
+	select {
+	case <-c.quit:
+		return
+	default:
+	}
+	if raw {
+		if err != nil {
+			c.Write([]byte("Error: " + wsErrorMessage(err) + "\n"))
+		}
+		return
+	}
+	msg := &errMsg{}
+	if err != nil {
+		msg.Error = wsErrorMessage(err)
+	}
+	c.SendJSON(msg)
+}
+
+func wsErrorMessage(err error) string {
+	if httpErr, ok := err.(*errors.HTTP); ok {
+		return httpErr.Message
+	}
+	return err.Error()
+}
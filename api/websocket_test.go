@@ -0,0 +1,210 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api/context"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/auth/native"
+	"github.com/tsuru/tsuru/errors"
+	"golang.org/x/net/websocket"
+	"gopkg.in/check.v1"
+)
+
+func wsTestServer(h *wsHandler, t auth.Token) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if t != nil {
+			context.SetAuthToken(r, t)
+		}
+		h.ServeHTTP(w, r)
+		if err := context.GetRequestError(r); err != nil {
+			w.WriteHeader(err.(*errors.HTTP).Code)
+		}
+	}))
+}
+
+func wsTestDial(c *check.C, srv *httptest.Server) (*websocket.Conn, error) {
+	config, err := websocket.NewConfig(strings.Replace(srv.URL, "http://", "ws://", 1), "ws://localhost/")
+	c.Assert(err, check.IsNil)
+	return websocket.DialConfig(config)
+}
+
+func (s *S) TestWebsocketHandlerSendsFinalMessage(c *check.C) {
+	h := &wsHandler{handle: func(conn *wsConn, r *http.Request, t auth.Token) error {
+		return conn.SendJSON(map[string]string{"user": t.GetUserName()})
+	}}
+	srv := wsTestServer(h, &native.Token{UserEmail: "me@tsuru.io"})
+	defer srv.Close()
+	ws, err := wsTestDial(c, srv)
+	c.Assert(err, check.IsNil)
+	defer ws.Close()
+	var msg map[string]string
+	err = websocket.JSON.Receive(ws, &msg)
+	c.Assert(err, check.IsNil)
+	c.Assert(msg, check.DeepEquals, map[string]string{"user": "me@tsuru.io"})
+	var final errMsg
+	err = websocket.JSON.Receive(ws, &final)
+	c.Assert(err, check.IsNil)
+	c.Assert(final, check.DeepEquals, errMsg{})
+	_, err = ioutil.ReadAll(ws)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestWebsocketHandlerSendsError(c *check.C) {
+	h := &wsHandler{handle: func(conn *wsConn, r *http.Request, t auth.Token) error {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: "not here"}
+	}}
+	srv := wsTestServer(h, &native.Token{UserEmail: "me@tsuru.io"})
+	defer srv.Close()
+	ws, err := wsTestDial(c, srv)
+	c.Assert(err, check.IsNil)
+	defer ws.Close()
+	var final errMsg
+	err = websocket.JSON.Receive(ws, &final)
+	c.Assert(err, check.IsNil)
+	c.Assert(final, check.DeepEquals, errMsg{Error: "not here"})
+}
+
+func (s *S) TestWebsocketHandlerRawError(c *check.C) {
+	h := &wsHandler{raw: true, handle: func(conn *wsConn, r *http.Request, t auth.Token) error {
+		fmt.Fprint(conn, "some output\n")
+		return fmt.Errorf("something went wrong")
+	}}
+	srv := wsTestServer(h, &native.Token{UserEmail: "me@tsuru.io"})
+	defer srv.Close()
+	ws, err := wsTestDial(c, srv)
+	c.Assert(err, check.IsNil)
+	defer ws.Close()
+	data, err := ioutil.ReadAll(ws)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "some output\nError: something went wrong\n")
+}
+
+func (s *S) TestWebsocketHandlerRequiresToken(c *check.C) {
+	called := false
+	h := &wsHandler{handle: func(conn *wsConn, r *http.Request, t auth.Token) error {
+		called = true
+		return nil
+	}}
+	srv := wsTestServer(h, nil)
+	defer srv.Close()
+	_, err := wsTestDial(c, srv)
+	c.Assert(err, check.NotNil)
+	c.Assert(called, check.Equals, false)
+}
+
+func (s *S) TestWebsocketHandlerRequiresUpgrade(c *check.C) {
+	h := &wsHandler{handle: func(conn *wsConn, r *http.Request, t auth.Token) error {
+		return nil
+	}}
+	srv := wsTestServer(h, &native.Token{UserEmail: "me@tsuru.io"})
+	defer srv.Close()
+	rsp, err := http.Get(srv.URL)
+	c.Assert(err, check.IsNil)
+	c.Assert(rsp.StatusCode, check.Equals, http.StatusBadRequest)
+}
+
+func (s *S) TestWebsocketHandlerClosesOnClientEOF(c *check.C) {
+	done := make(chan struct{})
+	h := &wsHandler{handle: func(conn *wsConn, r *http.Request, t auth.Token) error {
+		defer close(done)
+		go conn.CloseOnClientEOF()
+		<-conn.Done()
+		return nil
+	}}
+	srv := wsTestServer(h, &native.Token{UserEmail: "me@tsuru.io"})
+	defer srv.Close()
+	ws, err := wsTestDial(c, srv)
+	c.Assert(err, check.IsNil)
+	ws.Close()
+	<-done
+}
+
+func (s *S) TestWebsocketHandlerClosesIdleConnection(c *check.C) {
+	config.Set("server:websocket-ping-interval", 0.1)
+	defer config.Unset("server:websocket-ping-interval")
+	done := make(chan struct{})
+	h := &wsHandler{handle: func(conn *wsConn, r *http.Request, t auth.Token) error {
+		defer close(done)
+		conn.CloseOnClientEOF()
+		return nil
+	}}
+	srv := wsTestServer(h, &native.Token{UserEmail: "me@tsuru.io"})
+	defer srv.Close()
+	ws, err := wsTestDial(c, srv)
+	c.Assert(err, check.IsNil)
+	defer ws.Close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		c.Fatal("timeout waiting for idle connection to be closed")
+	}
+}
+
+func (s *S) TestWebsocketHandlerKeepsConnectionAnsweringPings(c *check.C) {
+	config.Set("server:websocket-ping-interval", 0.1)
+	defer config.Unset("server:websocket-ping-interval")
+	done := make(chan struct{})
+	h := &wsHandler{handle: func(conn *wsConn, r *http.Request, t auth.Token) error {
+		defer close(done)
+		conn.CloseOnClientEOF()
+		return nil
+	}}
+	srv := wsTestServer(h, &native.Token{UserEmail: "me@tsuru.io"})
+	defer srv.Close()
+	ws, err := wsTestDial(c, srv)
+	c.Assert(err, check.IsNil)
+	go ioutil.ReadAll(ws)
+	select {
+	case <-done:
+		c.Fatal("connection answering pings was closed")
+	case <-time.After(time.Second):
+	}
+	ws.Close()
+	<-done
+}
+
+func (s *S) TestWebsocketHandlerKeepsConnectionWritingMessages(c *check.C) {
+	config.Set("server:websocket-ping-interval", 0.1)
+	defer config.Unset("server:websocket-ping-interval")
+	result := make(chan error, 1)
+	h := &wsHandler{handle: func(conn *wsConn, r *http.Request, t auth.Token) error {
+		go conn.CloseOnClientEOF()
+		for i := 0; i < 50; i++ {
+			select {
+			case <-conn.Done():
+				result <- fmt.Errorf("connection writing messages was closed")
+				return nil
+			case <-time.After(20 * time.Millisecond):
+			}
+			err := conn.SendJSON(map[string]int{"line": i})
+			if err != nil {
+				result <- err
+				return nil
+			}
+		}
+		result <- nil
+		return nil
+	}}
+	srv := wsTestServer(h, &native.Token{UserEmail: "me@tsuru.io"})
+	defer srv.Close()
+	ws, err := wsTestDial(c, srv)
+	c.Assert(err, check.IsNil)
+	defer ws.Close()
+	select {
+	case err = <-result:
+		c.Assert(err, check.IsNil)
+	case <-time.After(5 * time.Second):
+		c.Fatal("timeout waiting for messages to be written")
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/tsuru/tsuru/app/bind"
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/dns"
 	tsuruErrors "github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/permission"
@@ -418,7 +419,7 @@ var validateNewCNames = action.Action{
 		defer conn.Close()
 		for _, cname := range cnames {
 			if !cnameRegexp.MatchString(cname) {
-				return nil, errors.New("Invalid cname")
+				return nil, ErrInvalidCName
 			}
 			cs, err := conn.Apps().Find(bson.M{"cname": cname}).Count()
 			if err != nil {
@@ -464,6 +465,49 @@ func setUnsetCnames(app *App, cnames []string, toSet bool) error {
 	return multi.ToError()
 }
 
+var addCNamesDNSRecords = action.Action{
+	Name: "add-cnames-dns-records",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		app := ctx.Params[0].(*App)
+		cnames := ctx.Params[1].([]string)
+		if !dns.Enabled() {
+			return cnames, nil
+		}
+		target, err := app.cnameTarget()
+		if err != nil {
+			return nil, err
+		}
+		for i, cname := range cnames {
+			err = dns.EnsureCNAME(cname, target)
+			if err != nil {
+				for _, added := range cnames[:i] {
+					dns.RemoveCNAME(added, target)
+				}
+				return nil, err
+			}
+		}
+		return cnames, nil
+	},
+	Backward: func(ctx action.BWContext) {
+		app := ctx.Params[0].(*App)
+		cnames := ctx.Params[1].([]string)
+		if !dns.Enabled() {
+			return
+		}
+		target, err := app.cnameTarget()
+		if err != nil {
+			log.Errorf("BACKWARD add cnames dns records - unable to get cname target: %s", err)
+			return
+		}
+		for _, cname := range cnames {
+			err = dns.RemoveCNAME(cname, target)
+			if err != nil {
+				log.Errorf("BACKWARD add cnames dns records - unable to remove record of %q: %s", cname, err)
+			}
+		}
+	},
+}
+
 var setNewCNamesToProvisioner = action.Action{
 	Name: "set-new-cnames-to-provisioner",
 	Forward: func(ctx action.FWContext) (result action.Result, err error) {
@@ -585,6 +629,49 @@ var checkCNameExists = action.Action{
 	},
 }
 
+var removeCNamesDNSRecords = action.Action{
+	Name: "remove-cnames-dns-records",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		app := ctx.Params[0].(*App)
+		cnames := ctx.Params[1].([]string)
+		if !dns.Enabled() {
+			return cnames, nil
+		}
+		target, err := app.cnameTarget()
+		if err != nil {
+			return nil, err
+		}
+		for i, cname := range cnames {
+			err = dns.RemoveCNAME(cname, target)
+			if err != nil {
+				for _, removed := range cnames[:i] {
+					dns.EnsureCNAME(removed, target)
+				}
+				return nil, err
+			}
+		}
+		return cnames, nil
+	},
+	Backward: func(ctx action.BWContext) {
+		app := ctx.Params[0].(*App)
+		cnames := ctx.Params[1].([]string)
+		if !dns.Enabled() {
+			return
+		}
+		target, err := app.cnameTarget()
+		if err != nil {
+			log.Errorf("BACKWARD remove cnames dns records - unable to get cname target: %s", err)
+			return
+		}
+		for _, cname := range cnames {
+			err = dns.EnsureCNAME(cname, target)
+			if err != nil {
+				log.Errorf("BACKWARD remove cnames dns records - unable to add record of %q: %s", cname, err)
+			}
+		}
+	},
+}
+
 var unsetCNameFromProvisioner = action.Action{
 	Name: "unset-cname-from-provisioner",
 	Forward: func(ctx action.FWContext) (result action.Result, err error) {
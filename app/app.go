@@ -23,10 +23,12 @@ import (
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/builder"
 	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/dns"
 	tsuruErrors "github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/healer"
 	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/naming"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/nodecontainer"
@@ -37,6 +39,7 @@ import (
 	"github.com/tsuru/tsuru/router"
 	"github.com/tsuru/tsuru/router/rebuild"
 	"github.com/tsuru/tsuru/service"
+	"github.com/tsuru/tsuru/set"
 	"github.com/tsuru/tsuru/storage"
 	appTypes "github.com/tsuru/tsuru/types/app"
 	authTypes "github.com/tsuru/tsuru/types/auth"
@@ -54,6 +57,7 @@ var (
 	ErrNoAccess          = errors.New("team does not have access to this app")
 	ErrCannotOrphanApp   = errors.New("cannot revoke access from this team, as it's the unique team with access to the app")
 	ErrDisabledPlatform  = errors.New("Disabled Platform, only admin users can create applications with the platform")
+	ErrInvalidCName      = &tsuruErrors.ValidationError{Message: "Invalid cname"}
 )
 
 const (
@@ -138,6 +142,15 @@ type App struct {
 	Tags           []string
 	Error          string
 	Routers        []appTypes.AppRouter
+	DeployWebhook  *DeployWebhook    `bson:",omitempty"`
+	Secrets        map[string]string `bson:",omitempty"`
+	LogDrains      []LogDrain        `bson:",omitempty"`
+	Spread         *bool             `bson:",omitempty"`
+	Project        string            `bson:",omitempty"`
+	ProjectEnvs    []bind.EnvVar     `bson:",omitempty"`
+	DeployApproval bool              `bson:",omitempty"`
+	DeletedAt      time.Time         `bson:",omitempty"`
+	DeletedBy      string            `bson:",omitempty"`
 
 	quota.Quota
 	builder     builder.Builder
@@ -242,6 +255,13 @@ func (app *App) MarshalJSON() ([]byte, error) {
 	result["lock"] = app.Lock
 	result["tags"] = app.Tags
 	result["routers"] = routers
+	result["spread"] = app.SpreadUnits()
+	if app.Project != "" {
+		result["project"] = app.Project
+	}
+	if app.DeployApproval {
+		result["deployApproval"] = true
+	}
 	if len(errMsgs) > 0 {
 		result["error"] = strings.Join(errMsgs, "\n")
 	}
@@ -359,7 +379,7 @@ func ReleaseApplicationLock(appName string) {
 }
 
 // GetByName queries the database to find an app identified by the given
-// name.
+// name. Apps pending removal are not found.
 func GetByName(name string) (*App, error) {
 	var app App
 	conn, err := db.Conn()
@@ -367,7 +387,7 @@ func GetByName(name string) (*App, error) {
 		return nil, err
 	}
 	defer conn.Close()
-	err = conn.Apps().Find(bson.M{"name": name}).One(&app)
+	err = conn.Apps().Find(bson.M{"name": name, "deletedat": bson.M{"$exists": false}}).One(&app)
 	if err == mgo.ErrNotFound {
 		return nil, ErrAppNotFound
 	}
@@ -382,8 +402,11 @@ func GetByName(name string) (*App, error) {
 //       2. Create the git repository using the repository manager
 //       3. Provision the app using the provisioner
 func CreateApp(app *App, user *auth.User) error {
+	err := applyTeamPolicy(app)
+	if err != nil {
+		return err
+	}
 	var plan *appTypes.Plan
-	var err error
 	if app.Plan.Name == "" {
 		plan, err = DefaultPlan()
 	} else {
@@ -408,6 +431,10 @@ func CreateApp(app *App, user *auth.User) error {
 	if err != nil {
 		return err
 	}
+	err = naming.Check(naming.KindApp, app.Name, app.TeamOwner)
+	if err != nil {
+		return err
+	}
 	actions := []*action.Action{
 		&reserveUserApp,
 		&insertApp,
@@ -505,6 +532,10 @@ func (app *App) Update(updateData App, w io.Writer) (err error) {
 	if updateData.UpdatePlatform {
 		app.UpdatePlatform = true
 	}
+	if updateData.Spread != nil {
+		spread := *updateData.Spread
+		app.Spread = &spread
+	}
 	err = app.validate()
 	if err != nil {
 		return err
@@ -527,6 +558,12 @@ func (app *App) Update(updateData App, w io.Writer) (err error) {
 	return conn.Apps().Update(bson.M{"name": app.Name}, app)
 }
 
+// SpreadUnits returns whether the units of the app should be spread across
+// distinct nodes and zones. Apps spread their units unless told otherwise.
+func (app *App) SpreadUnits() bool {
+	return app.Spread == nil || *app.Spread
+}
+
 func processTags(tags []string) []string {
 	if tags == nil {
 		return nil
@@ -639,6 +676,20 @@ func Delete(app *App, w io.Writer) error {
 	if err != nil {
 		logErr("Unable to unbind app", err)
 	}
+	if dns.Enabled() && len(app.CName) > 0 {
+		var target string
+		target, err = app.cnameTarget()
+		if err == nil {
+			for _, cname := range app.CName {
+				err = dns.RemoveCNAME(cname, target)
+				if err != nil {
+					logErr(fmt.Sprintf("Unable to remove DNS record of cname %q", cname), err)
+				}
+			}
+		} else {
+			logErr("Unable to remove DNS records of cnames", err)
+		}
+	}
 	routers := app.GetRouters()
 	for _, appRouter := range routers {
 		var r router.Router
@@ -697,6 +748,98 @@ func Delete(app *App, w io.Writer) error {
 	return nil
 }
 
+// DeleteActions returns the actions taken to remove the app, in the order
+// they would be taken, without executing any of them. When
+// apps:delete-grace-period is set the app is soft deleted, as in SoftDelete,
+// and the actions of the permanent removal are listed as happening after the
+// grace period.
+func DeleteActions(app *App) ([]string, error) {
+	isSwapped, swappedWith, err := router.IsSwapped(app.GetName())
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to check if app is swapped")
+	}
+	if isSwapped {
+		return nil, errors.Errorf("application is swapped with %q, cannot remove it", swappedWith)
+	}
+	prov, err := app.getProvisioner()
+	if err != nil {
+		return nil, err
+	}
+	units, err := prov.Units(app)
+	if err != nil {
+		return nil, err
+	}
+	var actions []string
+	grace := DeleteGracePeriod()
+	if grace > 0 {
+		for _, u := range units {
+			actions = append(actions, fmt.Sprintf("stop unit %q in provisioner %q", u.ID, prov.GetName()))
+		}
+		actions = append(actions, fmt.Sprintf("mark app %q as removed, it may be restored for %s", app.Name, grace))
+	}
+	purgeActions, err := purgeActions(app, prov, units)
+	if err != nil {
+		return nil, err
+	}
+	for _, action := range purgeActions {
+		if grace > 0 {
+			action = fmt.Sprintf("after %s, %s", grace, action)
+		}
+		actions = append(actions, action)
+	}
+	return actions, nil
+}
+
+// purgeActions returns the actions taken by Delete and by the removal of the
+// jobs of the app.
+func purgeActions(app *App, prov provision.Provisioner, units []provision.Unit) ([]string, error) {
+	var actions []string
+	for _, u := range units {
+		actions = append(actions, fmt.Sprintf("destroy unit %q in provisioner %q", u.ID, prov.GetName()))
+	}
+	actions = append(actions, fmt.Sprintf("remove images of app %q from registry", app.Name))
+	instances, err := service.GetServiceInstancesBoundToApp(app.Name)
+	if err != nil {
+		return nil, err
+	}
+	for _, instance := range instances {
+		actions = append(actions, fmt.Sprintf("unbind service instance %q of service %q", instance.Name, instance.ServiceName))
+	}
+	if dns.Enabled() {
+		for _, cname := range app.CName {
+			actions = append(actions, fmt.Sprintf("remove DNS record of cname %q", cname))
+		}
+	}
+	for _, appRouter := range app.GetRouters() {
+		actions = append(actions, fmt.Sprintf("remove backend from router %q", appRouter.Name))
+	}
+	volumes, err := volume.ListByApp(app.Name)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to list volumes for unbind")
+	}
+	for _, v := range volumes {
+		var binds []volume.VolumeBind
+		binds, err = v.LoadBinds()
+		if err != nil {
+			return nil, errors.Wrap(err, "Unable to list volume binds for unbind")
+		}
+		for _, b := range binds {
+			if b.ID.App == app.Name {
+				actions = append(actions, fmt.Sprintf("unbind volume %q from %q", v.Name, b.ID.MountPoint))
+			}
+		}
+	}
+	actions = append(actions,
+		"remove repository from repository manager",
+		"remove app token",
+		fmt.Sprintf("release app quota of user %q", app.Owner),
+		"remove logs",
+		fmt.Sprintf("remove app %q from database", app.Name),
+		fmt.Sprintf("remove jobs of app %q", app.Name),
+	)
+	return actions, nil
+}
+
 func (app *App) BindUnit(unit *provision.Unit) error {
 	instances, err := service.GetServiceInstancesBoundToApp(app.Name)
 	if err != nil {
@@ -1039,27 +1182,28 @@ func (app *App) getEnv(name string) (bind.EnvVar, error) {
 	return bind.EnvVar{}, errors.New("Environment variable not declared for this app.")
 }
 
-// validate checks app name format
+// validate checks app name format and whether its team owner and routers
+// are allowed in its pool, reporting all failures at once.
 func (app *App) validate() error {
+	verr := &tsuruErrors.ValidationError{}
 	if app.Name == InternalAppName || !validation.ValidateName(app.Name) {
-		msg := "Invalid app name, your app should have at most 63 " +
-			"characters, containing only lower case letters, numbers or dashes, " +
-			"starting with a letter."
-		return &tsuruErrors.ValidationError{Message: msg}
+		verr.Add("name", "Invalid app name, your app should have at most 63 "+
+			"characters, containing only lower case letters, numbers or dashes, "+
+			"starting with a letter.")
 	}
-	return app.validatePool()
-}
-
-func (app *App) validatePool() error {
 	pool, err := pool.GetPoolByName(app.Pool)
 	if err != nil {
 		return err
 	}
-	err = app.validateTeamOwner(pool)
+	err = verr.Merge("teamOwner", app.validateTeamOwner(pool))
+	if err != nil {
+		return err
+	}
+	err = verr.Merge("router", app.validateRouter(pool))
 	if err != nil {
 		return err
 	}
-	return app.validateRouter(pool)
+	return verr.ToError()
 }
 
 func (app *App) validateTeamOwner(p *pool.Pool) error {
@@ -1123,6 +1267,12 @@ func (app *App) ValidateService(service string) error {
 	return &tsuruErrors.ValidationError{Message: msg}
 }
 
+// GetServiceEnvs returns the environment variables set by service instances
+// bound to the app.
+func (app *App) GetServiceEnvs() []bind.ServiceEnvVar {
+	return app.ServiceEnvs
+}
+
 // InstanceEnvs returns a map of environment variables that belongs to the
 // given service and service instance.
 func (app *App) InstanceEnvs(serviceName, instanceName string) map[string]bind.EnvVar {
@@ -1194,6 +1344,58 @@ func (app *App) Restart(process string, w io.Writer) error {
 	return nil
 }
 
+// RestartUnit restarts a single unit of the app, writing its output to w.
+func (app *App) RestartUnit(unitID string, w io.Writer) error {
+	prov, err := app.getProvisioner()
+	if err != nil {
+		return err
+	}
+	unitProv, ok := prov.(provision.UnitProvisioner)
+	if !ok {
+		return provision.ProvisionerNotSupported{Prov: prov, Action: "restarting units"}
+	}
+	w = app.withLogWriter(w)
+	fmt.Fprintf(w, "---- Restarting unit %q ----\n", unitID)
+	err = unitProv.RestartUnit(app, unitID, w)
+	if err != nil {
+		log.Errorf("[restart] error on restart unit %s of the app %s - %s", unitID, app.Name, err)
+		return err
+	}
+	rebuild.RoutesRebuildOrEnqueue(app.Name)
+	return nil
+}
+
+// InspectUnit returns detailed information about a unit of the app.
+func (app *App) InspectUnit(unitID string) (*provision.UnitInfo, error) {
+	prov, err := app.getProvisioner()
+	if err != nil {
+		return nil, err
+	}
+	unitProv, ok := prov.(provision.UnitProvisioner)
+	if !ok {
+		return nil, provision.ProvisionerNotSupported{Prov: prov, Action: "inspecting units"}
+	}
+	return unitProv.InspectUnit(app, unitID)
+}
+
+// SetUnitResources temporarily overrides the memory and CPU share of a unit
+// of the app. The override is dropped when the unit is replaced, e.g. on the
+// next deploy, and zero resources set the unit back to the app plan.
+func (app *App) SetUnitResources(unitID string, r provision.UnitResources) error {
+	if r.Memory < 0 || r.CpuShare < 0 {
+		return &tsuruErrors.ValidationError{Message: "memory and cpu share must not be negative"}
+	}
+	prov, err := app.getProvisioner()
+	if err != nil {
+		return err
+	}
+	resourcesProv, ok := prov.(provision.UnitResourcesProvisioner)
+	if !ok {
+		return provision.ProvisionerNotSupported{Prov: prov, Action: "overriding unit resources"}
+	}
+	return resourcesProv.SetUnitResources(app, unitID, r)
+}
+
 func (app *App) Stop(w io.Writer, process string) error {
 	w = app.withLogWriter(w)
 	msg := fmt.Sprintf("\n ---> Stopping the process %q", process)
@@ -1371,10 +1573,16 @@ func (app *App) GetDeploys() uint {
 
 // Envs returns a map representing the apps environment variables.
 func (app *App) Envs() map[string]bind.EnvVar {
-	mergedEnvs := make(map[string]bind.EnvVar, len(app.Env)+len(app.ServiceEnvs)+1)
+	mergedEnvs := make(map[string]bind.EnvVar, len(app.ProjectEnvs)+len(app.Env)+len(app.Secrets)+len(app.ServiceEnvs)+1)
+	for _, e := range app.ProjectEnvs {
+		mergedEnvs[e.Name] = e
+	}
 	for _, e := range app.Env {
 		mergedEnvs[e.Name] = e
 	}
+	for _, e := range app.secretEnvs() {
+		mergedEnvs[e.Name] = e
+	}
 	for _, e := range app.ServiceEnvs {
 		mergedEnvs[e.Name] = e.EnvVar
 	}
@@ -1390,6 +1598,13 @@ func (app *App) SetEnvs(setEnvs bind.SetEnvArgs) error {
 	if setEnvs.Writer != nil {
 		fmt.Fprintf(setEnvs.Writer, "---- Setting %d new environment variables ----\n", len(setEnvs.Envs))
 	}
+	candidate := app.Envs()
+	for _, env := range setEnvs.Envs {
+		candidate[env.Name] = env
+	}
+	if _, err := provision.InterpolateEnvs(candidate); err != nil {
+		return err
+	}
 	for _, env := range setEnvs.Envs {
 		app.setEnv(env)
 	}
@@ -1403,7 +1618,7 @@ func (app *App) SetEnvs(setEnvs bind.SetEnvArgs) error {
 		return err
 	}
 	if setEnvs.ShouldRestart {
-		return app.restartIfUnits(setEnvs.Writer)
+		return app.RestartIfUnits(setEnvs.Writer)
 	}
 	return nil
 }
@@ -1430,12 +1645,103 @@ func (app *App) UnsetEnvs(unsetEnvs bind.UnsetEnvArgs) error {
 		return err
 	}
 	if unsetEnvs.ShouldRestart {
-		return app.restartIfUnits(unsetEnvs.Writer)
+		return app.RestartIfUnits(unsetEnvs.Writer)
+	}
+	return nil
+}
+
+// UpdateEnvsArgs holds the environment variables set and unset together by
+// UpdateEnvs.
+type UpdateEnvsArgs struct {
+	Set           []bind.EnvVar
+	Unset         []string
+	Writer        io.Writer
+	ShouldRestart bool
+}
+
+// UpdateEnvs sets and unsets environment variables of the app all at once: no
+// variable is changed if any of them is invalid, and the app is restarted a
+// single time, after all of them are stored.
+func (app *App) UpdateEnvs(args UpdateEnvsArgs) error {
+	if len(args.Set) == 0 && len(args.Unset) == 0 {
+		return nil
+	}
+	candidate := app.Envs()
+	var verr tsuruErrors.ValidationError
+	setNames := make(map[string]struct{}, len(args.Set))
+	for _, env := range args.Set {
+		if !envNameRegexp.MatchString(env.Name) {
+			verr.Add("env", fmt.Sprintf("invalid environment variable name %q", env.Name))
+		}
+		if _, ok := setNames[env.Name]; ok {
+			verr.Add("env", fmt.Sprintf("environment variable %q set more than once", env.Name))
+		}
+		setNames[env.Name] = struct{}{}
+		candidate[env.Name] = env
+	}
+	for _, name := range args.Unset {
+		if !envNameRegexp.MatchString(name) {
+			verr.Add("env", fmt.Sprintf("invalid environment variable name %q", name))
+		}
+		if _, ok := setNames[name]; ok {
+			verr.Add("env", fmt.Sprintf("environment variable %q can't be both set and unset", name))
+		}
+		delete(candidate, name)
+	}
+	if err := verr.ToError(); err != nil {
+		return err
+	}
+	if _, err := provision.InterpolateEnvs(candidate); err != nil {
+		return &tsuruErrors.ValidationError{Message: err.Error()}
+	}
+	if args.Writer != nil {
+		switch {
+		case len(args.Unset) == 0:
+			fmt.Fprintf(args.Writer, "---- Setting %d new environment variables ----\n", len(args.Set))
+		case len(args.Set) == 0:
+			fmt.Fprintf(args.Writer, "---- Unsetting %d environment variables ----\n", len(args.Unset))
+		default:
+			fmt.Fprintf(args.Writer, "---- Setting %d and unsetting %d environment variables ----\n", len(args.Set), len(args.Unset))
+		}
+	}
+	update := bson.M{}
+	if len(args.Set) > 0 {
+		toSet := bson.M{}
+		for _, env := range args.Set {
+			toSet["env."+env.Name] = env
+		}
+		update["$set"] = toSet
+	}
+	if len(args.Unset) > 0 {
+		toUnset := bson.M{}
+		for _, name := range args.Unset {
+			toUnset["env."+name] = ""
+		}
+		update["$unset"] = toUnset
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Apps().Update(bson.M{"name": app.Name}, update)
+	if err != nil {
+		return err
+	}
+	for _, env := range args.Set {
+		app.setEnv(env)
+	}
+	for _, name := range args.Unset {
+		delete(app.Env, name)
+	}
+	if args.ShouldRestart {
+		return app.RestartIfUnits(args.Writer)
 	}
 	return nil
 }
 
-func (app *App) restartIfUnits(w io.Writer) error {
+// RestartIfUnits restarts the app, unless it has no units.
+func (app *App) RestartIfUnits(w io.Writer) error {
 	units, err := app.GetUnits()
 	if err != nil {
 		return err
@@ -1457,6 +1763,7 @@ func (app *App) restartIfUnits(w io.Writer) error {
 func (app *App) AddCName(cnames ...string) error {
 	actions := []*action.Action{
 		&validateNewCNames,
+		&addCNamesDNSRecords,
 		&setNewCNamesToProvisioner,
 		&saveCNames,
 		&updateApp,
@@ -1469,6 +1776,7 @@ func (app *App) AddCName(cnames ...string) error {
 func (app *App) RemoveCName(cnames ...string) error {
 	actions := []*action.Action{
 		&checkCNameExists,
+		&removeCNamesDNSRecords,
 		&unsetCNameFromProvisioner,
 		&removeCNameFromDatabase,
 		&removeCNameFromApp,
@@ -1508,6 +1816,9 @@ func serviceEnvsFromEnvVars(vars []bind.ServiceEnvVar) bind.EnvVar {
 	}
 }
 
+// AddInstance adds the environment variables of a service instance to the
+// app. Variables previously set by the same instances are replaced in a
+// single update, so credentials can be swapped atomically.
 func (app *App) AddInstance(addArgs bind.AddInstanceArgs) error {
 	if len(addArgs.Envs) == 0 {
 		return nil
@@ -1515,7 +1826,7 @@ func (app *App) AddInstance(addArgs bind.AddInstanceArgs) error {
 	if addArgs.Writer != nil {
 		fmt.Fprintf(addArgs.Writer, "---- Setting %d new environment variables ----\n", len(addArgs.Envs)+1)
 	}
-	app.ServiceEnvs = append(app.ServiceEnvs, addArgs.Envs...)
+	app.ServiceEnvs = append(removeInstanceEnvs(app.ServiceEnvs, addArgs.Envs), addArgs.Envs...)
 	conn, err := db.Conn()
 	if err != nil {
 		return err
@@ -1526,11 +1837,28 @@ func (app *App) AddInstance(addArgs bind.AddInstanceArgs) error {
 		return err
 	}
 	if addArgs.ShouldRestart {
-		return app.restartIfUnits(addArgs.Writer)
+		return app.RestartIfUnits(addArgs.Writer)
 	}
 	return nil
 }
 
+func removeInstanceEnvs(envs, newEnvs []bind.ServiceEnvVar) []bind.ServiceEnvVar {
+	result := make([]bind.ServiceEnvVar, 0, len(envs))
+	for _, se := range envs {
+		replaced := false
+		for _, newEnv := range newEnvs {
+			if se.ServiceName == newEnv.ServiceName && se.InstanceName == newEnv.InstanceName {
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			result = append(result, se)
+		}
+	}
+	return result
+}
+
 func (app *App) RemoveInstance(removeArgs bind.RemoveInstanceArgs) error {
 	lenBefore := len(app.ServiceEnvs)
 	for i := 0; i < len(app.ServiceEnvs); i++ {
@@ -1557,7 +1885,7 @@ func (app *App) RemoveInstance(removeArgs bind.RemoveInstanceArgs) error {
 		return err
 	}
 	if removeArgs.ShouldRestart {
-		return app.restartIfUnits(removeArgs.Writer)
+		return app.RestartIfUnits(removeArgs.Writer)
 	}
 	return nil
 }
@@ -1708,6 +2036,7 @@ func List(filter *Filter) ([]App, error) {
 	}
 	defer conn.Close()
 	query := filter.Query()
+	query["deletedat"] = bson.M{"$exists": false}
 	if err = conn.Apps().Find(query).All(&apps); err != nil {
 		return nil, err
 	}
@@ -2019,6 +2348,20 @@ func (app *App) GetRouters() []appTypes.AppRouter {
 	return routers
 }
 
+// cnameTarget returns the address the DNS records of the app CNAMEs must
+// point to, which is the address of the app in its first router.
+func (app *App) cnameTarget() (string, error) {
+	routers := app.GetRouters()
+	if len(routers) == 0 {
+		return "", errors.Errorf("app %q has no routers", app.Name)
+	}
+	r, err := router.Get(routers[0].Name)
+	if err != nil {
+		return "", err
+	}
+	return r.Addr(app.Name)
+}
+
 func (app *App) GetRoutersWithAddr() ([]appTypes.AppRouter, error) {
 	routers := app.GetRouters()
 	multi := tsuruErrors.NewMultiError()
@@ -2249,11 +2592,18 @@ func RenameTeam(oldName, newName string) error {
 		if a.TeamOwner == oldName {
 			a.TeamOwner = newName
 		}
-		for i, team := range a.Teams {
+		teams := make([]string, 0, len(a.Teams))
+		seen := set.Set{}
+		for _, team := range a.Teams {
 			if team == oldName {
-				a.Teams[i] = newName
+				team = newName
+			}
+			if !seen.Includes(team) {
+				seen.Add(team)
+				teams = append(teams, team)
 			}
 		}
+		a.Teams = teams
 		bulk.Update(bson.M{"name": a.Name}, a)
 	}
 	_, err = bulk.Run()
@@ -0,0 +1,210 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/db"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/service"
+	"gopkg.in/mgo.v2"
+)
+
+var (
+	ErrTemplateNotFound = errors.New("app template not found")
+
+	templateNameRegexp = regexp.MustCompile(`^[a-z][a-z0-9._-]*$`)
+	envNameRegexp      = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// Template holds the defaults used to bootstrap an app in a single call: the
+// platform, plan and pool of the app, its environment variables and the
+// service instances created and bound to it.
+type Template struct {
+	Name        string `bson:"_id"`
+	Description string
+	Platform    string
+	Plan        string
+	Pool        string
+	Env         map[string]string
+	Services    []TemplateService
+}
+
+// TemplateService is a service instance created from a template. When
+// Instance is empty the instance is named after the app and the service.
+type TemplateService struct {
+	Service  string
+	Plan     string
+	Instance string
+}
+
+// TemplateResult lists the resources provisioned from a template.
+type TemplateResult struct {
+	Template  string
+	Envs      []string
+	Instances []string
+}
+
+func (t *Template) validate() error {
+	var verr tsuruErrors.ValidationError
+	if !templateNameRegexp.MatchString(t.Name) {
+		verr.Add("name", "invalid template name, it must start with a letter and contain only lowercase letters, numbers, dots, dashes and underscores")
+	}
+	for name := range t.Env {
+		if !envNameRegexp.MatchString(name) {
+			verr.Add("env", fmt.Sprintf("invalid environment variable name %q", name))
+		}
+	}
+	for i, s := range t.Services {
+		if s.Service == "" {
+			verr.Add("services", fmt.Sprintf("service %d has no service name", i))
+			continue
+		}
+		srv := service.Service{Name: s.Service}
+		if err := srv.Get(); err != nil {
+			verr.Add("services", fmt.Sprintf("service %q not found", s.Service))
+		}
+	}
+	return verr.ToError()
+}
+
+// SaveTemplate creates or updates a template.
+func SaveTemplate(t Template) error {
+	err := t.validate()
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.AppTemplates().UpsertId(t.Name, t)
+	return err
+}
+
+// RemoveTemplate removes a template, apps already created from it are kept.
+func RemoveTemplate(name string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.AppTemplates().RemoveId(name)
+	if err == mgo.ErrNotFound {
+		return ErrTemplateNotFound
+	}
+	return err
+}
+
+// GetTemplate returns the template with the given name.
+func GetTemplate(name string) (*Template, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var t Template
+	err = conn.AppTemplates().FindId(name).One(&t)
+	if err == mgo.ErrNotFound {
+		return nil, ErrTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ListTemplates returns all templates sorted by name.
+func ListTemplates() ([]Template, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var templates []Template
+	err = conn.AppTemplates().Find(nil).Sort("_id").All(&templates)
+	if err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// Apply sets the platform, plan and pool of the app to the ones in the
+// template, unless they were already set.
+func (t *Template) Apply(a *App) {
+	if a.Platform == "" {
+		a.Platform = t.Platform
+	}
+	if a.Plan.Name == "" {
+		a.Plan.Name = t.Plan
+	}
+	if a.Pool == "" {
+		a.Pool = t.Pool
+	}
+}
+
+// InstanceName returns the name of the instance of s created for the app.
+func (s TemplateService) InstanceName(appName string) string {
+	if s.Instance != "" {
+		return s.Instance
+	}
+	return fmt.Sprintf("%s-%s", appName, s.Service)
+}
+
+// Provision sets the environment variables of the template in the already
+// created app, then creates the service instances of the template, owned by
+// the team owner of the app, and binds them to it. The app isn't restarted.
+// Resources provisioned before a failure are kept and listed in the result.
+func (t *Template) Provision(a *App, user *auth.User, requestID string, w io.Writer) (*TemplateResult, error) {
+	result := &TemplateResult{Template: t.Name}
+	if len(t.Env) > 0 {
+		envs := make([]bind.EnvVar, 0, len(t.Env))
+		for name, value := range t.Env {
+			envs = append(envs, bind.EnvVar{Name: name, Value: value, Public: true})
+		}
+		sort.Slice(envs, func(i, j int) bool { return envs[i].Name < envs[j].Name })
+		err := a.SetEnvs(bind.SetEnvArgs{Envs: envs, Writer: w})
+		if err != nil {
+			return result, errors.Wrap(err, "unable to set environment variables")
+		}
+		for _, env := range envs {
+			result.Envs = append(result.Envs, env.Name)
+		}
+	}
+	for _, s := range t.Services {
+		srv := service.Service{Name: s.Service}
+		err := srv.Get()
+		if err != nil {
+			return result, errors.Wrapf(err, "unable to find service %q", s.Service)
+		}
+		instance := service.ServiceInstance{
+			Name:      s.InstanceName(a.Name),
+			PlanName:  s.Plan,
+			TeamOwner: a.TeamOwner,
+		}
+		err = service.CreateServiceInstance(instance, &srv, user, requestID)
+		if err != nil {
+			return result, errors.Wrapf(err, "unable to create instance %q of service %q", instance.Name, s.Service)
+		}
+		si, err := service.GetServiceInstance(s.Service, instance.Name)
+		if err != nil {
+			return result, err
+		}
+		err = si.BindApp(a, false, w)
+		if err != nil {
+			return result, errors.Wrapf(err, "unable to bind instance %q of service %q", instance.Name, s.Service)
+		}
+		result.Instances = append(result.Instances, fmt.Sprintf("%s/%s", s.Service, instance.Name))
+	}
+	return result, nil
+}
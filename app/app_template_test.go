@@ -0,0 +1,61 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	appTypes "github.com/tsuru/tsuru/types/app"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestTemplateApply(c *check.C) {
+	tpl := Template{Name: "stack", Platform: "python", Plan: "small", Pool: "pool1"}
+	a := App{Name: "myapp"}
+	tpl.Apply(&a)
+	c.Assert(a.Platform, check.Equals, "python")
+	c.Assert(a.Plan.Name, check.Equals, "small")
+	c.Assert(a.Pool, check.Equals, "pool1")
+	a = App{Name: "myapp", Platform: "go", Plan: appTypes.Plan{Name: "large"}, Pool: "pool2"}
+	tpl.Apply(&a)
+	c.Assert(a.Platform, check.Equals, "go")
+	c.Assert(a.Plan.Name, check.Equals, "large")
+	c.Assert(a.Pool, check.Equals, "pool2")
+}
+
+func (s *S) TestTemplateServiceInstanceName(c *check.C) {
+	c.Assert(TemplateService{Service: "mysql"}.InstanceName("myapp"), check.Equals, "myapp-mysql")
+	c.Assert(TemplateService{Service: "mysql", Instance: "shared-db"}.InstanceName("myapp"), check.Equals, "shared-db")
+}
+
+func (s *S) TestSaveTemplateInvalid(c *check.C) {
+	err := SaveTemplate(Template{Name: "Stack", Env: map[string]string{"LOG-LEVEL": "info"}})
+	c.Assert(err, check.FitsTypeOf, &tsuruErrors.ValidationError{})
+	c.Assert(err, check.ErrorMatches, `(?s).*invalid template name.*invalid environment variable name "LOG-LEVEL".*`)
+}
+
+func (s *S) TestSaveAndGetTemplate(c *check.C) {
+	tpl := Template{
+		Name:     "stack",
+		Platform: "python",
+		Env:      map[string]string{"LOG_LEVEL": "info"},
+	}
+	err := SaveTemplate(tpl)
+	c.Assert(err, check.IsNil)
+	dbTpl, err := GetTemplate("stack")
+	c.Assert(err, check.IsNil)
+	c.Assert(*dbTpl, check.DeepEquals, tpl)
+	tpl.Platform = "go"
+	err = SaveTemplate(tpl)
+	c.Assert(err, check.IsNil)
+	templates, err := ListTemplates()
+	c.Assert(err, check.IsNil)
+	c.Assert(templates, check.DeepEquals, []Template{tpl})
+	err = RemoveTemplate("stack")
+	c.Assert(err, check.IsNil)
+	_, err = GetTemplate("stack")
+	c.Assert(err, check.Equals, ErrTemplateNotFound)
+	err = RemoveTemplate("stack")
+	c.Assert(err, check.Equals, ErrTemplateNotFound)
+}
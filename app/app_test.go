@@ -23,9 +23,12 @@ import (
 	"github.com/tsuru/tsuru/app/bind"
 	"github.com/tsuru/tsuru/app/image"
 	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/dns"
+	"github.com/tsuru/tsuru/dns/dnstest"
 	"github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/naming"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/permission/permissiontest"
 	"github.com/tsuru/tsuru/provision"
@@ -102,6 +105,70 @@ func (s *S) TestDelete(c *check.C) {
 	c.Assert(imgs, check.HasLen, 0)
 }
 
+func (s *S) TestDeleteActions(c *check.C) {
+	a := App{
+		Name:      "ritual",
+		Platform:  "ruby",
+		Owner:     s.user.Email,
+		TeamOwner: s.team.Name,
+	}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddUnits(&a, 1, "web", nil)
+	c.Assert(err, check.IsNil)
+	instance := service.ServiceInstance{Name: "mydb", ServiceName: "mysql", Apps: []string{a.Name}}
+	err = s.conn.ServiceInstances().Insert(instance)
+	c.Assert(err, check.IsNil)
+	actions, err := DeleteActions(&a)
+	c.Assert(err, check.IsNil)
+	c.Assert(actions, check.DeepEquals, []string{
+		`destroy unit "ritual-0" in provisioner "fake"`,
+		`remove images of app "ritual" from registry`,
+		`unbind service instance "mydb" of service "mysql"`,
+		`remove backend from router "fake"`,
+		"remove repository from repository manager",
+		"remove app token",
+		fmt.Sprintf("release app quota of user %q", s.user.Email),
+		"remove logs",
+		`remove app "ritual" from database`,
+		`remove jobs of app "ritual"`,
+	})
+	_, err = GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.provisioner.Provisioned(&a), check.Equals, true)
+	c.Assert(routertest.FakeRouter.HasBackend(a.Name), check.Equals, true)
+}
+
+func (s *S) TestDeleteActionsWithGracePeriod(c *check.C) {
+	config.Set("apps:delete-grace-period", 3600)
+	defer config.Unset("apps:delete-grace-period")
+	a := App{
+		Name:      "ritual",
+		Platform:  "ruby",
+		Owner:     s.user.Email,
+		TeamOwner: s.team.Name,
+	}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddUnits(&a, 1, "web", nil)
+	c.Assert(err, check.IsNil)
+	actions, err := DeleteActions(&a)
+	c.Assert(err, check.IsNil)
+	c.Assert(actions, check.DeepEquals, []string{
+		`stop unit "ritual-0" in provisioner "fake"`,
+		`mark app "ritual" as removed, it may be restored for 1h0m0s`,
+		`after 1h0m0s, destroy unit "ritual-0" in provisioner "fake"`,
+		`after 1h0m0s, remove images of app "ritual" from registry`,
+		`after 1h0m0s, remove backend from router "fake"`,
+		"after 1h0m0s, remove repository from repository manager",
+		"after 1h0m0s, remove app token",
+		fmt.Sprintf("after 1h0m0s, release app quota of user %q", s.user.Email),
+		"after 1h0m0s, remove logs",
+		`after 1h0m0s, remove app "ritual" from database`,
+		`after 1h0m0s, remove jobs of app "ritual"`,
+	})
+}
+
 func (s *S) TestDeleteWithEvents(c *check.C) {
 	a := App{
 		Name:      "ritual",
@@ -379,6 +446,17 @@ func (s *S) TestCreateAppTeamOwnerTeamNotFound(c *check.C) {
 	c.Assert(err.Error(), check.Equals, "team not found")
 }
 
+func (s *S) TestCreateAppReservedName(c *check.C) {
+	err := naming.Reserve(naming.Reservation{Kind: naming.KindApp, Name: "www"})
+	c.Assert(err, check.IsNil)
+	app := App{Name: "www", Platform: "python", TeamOwner: s.team.Name}
+	err = CreateApp(&app, s.user)
+	c.Assert(err, check.FitsTypeOf, &errors.ValidationError{})
+	c.Assert(err, check.ErrorMatches, `name "www" is reserved`)
+	_, err = GetByName("www")
+	c.Assert(err, check.Equals, ErrAppNotFound)
+}
+
 func (s *S) TestCannotCreateAppWithoutTeamOwner(c *check.C) {
 	u := auth.User{Email: "perpetual@yes.com"}
 	err := u.Create()
@@ -414,7 +492,8 @@ func (s *S) TestCantCreateAppWithInvalidName(c *check.C) {
 	msg := "Invalid app name, your app should have at most 63 " +
 		"characters, containing only lower case letters, numbers or dashes, " +
 		"starting with a letter."
-	c.Assert(e.Message, check.Equals, msg)
+	c.Assert(e.Error(), check.Equals, msg)
+	c.Assert(e.Fields, check.DeepEquals, []errors.FieldError{{Field: "name", Message: msg}})
 }
 
 func (s *S) TestCreateAppProvisionerFailures(c *check.C) {
@@ -1159,6 +1238,54 @@ func (s *S) TestSetEnvWithNoRestartFlag(c *check.C) {
 	c.Assert(s.provisioner.Restarts(&a, ""), check.Equals, 0)
 }
 
+func (s *S) TestSetEnvsReferenceCycle(c *check.C) {
+	a := App{
+		Name: "myapp",
+		Env: map[string]bind.EnvVar{
+			"DATABASE_HOST": {Name: "DATABASE_HOST", Value: "${DATABASE_URL}", Interpolate: true},
+		},
+		TeamOwner: s.team.Name,
+	}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetEnvs(bind.SetEnvArgs{
+		Envs: []bind.EnvVar{{Name: "DATABASE_URL", Value: "mysql://${DATABASE_HOST}/db", Interpolate: true}},
+	})
+	c.Assert(err, check.FitsTypeOf, &provision.EnvCycleError{})
+	newApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	_, ok := newApp.Env["DATABASE_URL"]
+	c.Assert(ok, check.Equals, false)
+}
+
+func (s *S) TestSetEnvsEncryptsPrivateValues(c *check.C) {
+	config.Set("secrets:key", "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	config.Set("secrets:encrypt-storage", true)
+	defer config.Unset("secrets")
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetEnvs(bind.SetEnvArgs{
+		Envs: []bind.EnvVar{
+			{Name: "DATABASE_PASSWORD", Value: "s3cr3t"},
+			{Name: "DATABASE_HOST", Value: "localhost", Public: true},
+		},
+	})
+	c.Assert(err, check.IsNil)
+	var stored struct {
+		Env map[string]struct{ Value string }
+	}
+	err = s.conn.Apps().Find(bson.M{"name": a.Name}).One(&stored)
+	c.Assert(err, check.IsNil)
+	c.Assert(stored.Env["DATABASE_PASSWORD"].Value, check.Matches, `enc:v1:.+`)
+	c.Assert(stored.Env["TSURU_APP_TOKEN"].Value, check.Matches, `enc:v1:.+`)
+	c.Assert(stored.Env["DATABASE_HOST"].Value, check.Equals, "localhost")
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Env["DATABASE_PASSWORD"].Value, check.Equals, "s3cr3t")
+	c.Assert(dbApp.Env["TSURU_APP_TOKEN"].Value, check.Equals, a.Env["TSURU_APP_TOKEN"].Value)
+}
+
 func (s *S) TestSetEnvsWhenAppHaveNoUnits(c *check.C) {
 	a := App{
 		Name: "myapp",
@@ -1209,6 +1336,96 @@ func (s *S) TestSetEnvsWhenAppHaveNoUnits(c *check.C) {
 	c.Assert(s.provisioner.Restarts(&a, ""), check.Equals, 0)
 }
 
+func (s *S) TestUpdateEnvs(c *check.C) {
+	a := App{
+		Name: "myapp",
+		Env: map[string]bind.EnvVar{
+			"DATABASE_HOST": {Name: "DATABASE_HOST", Value: "localhost"},
+			"OLD_FLAG":      {Name: "OLD_FLAG", Value: "1", Public: true},
+		},
+		TeamOwner: s.team.Name,
+	}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.AddUnits(1, "web", nil)
+	c.Assert(err, check.IsNil)
+	var buf bytes.Buffer
+	err = a.UpdateEnvs(UpdateEnvsArgs{
+		Set: []bind.EnvVar{
+			{Name: "DATABASE_HOST", Value: "remotehost"},
+			{Name: "DATABASE_URL", Value: "mysql://${DATABASE_HOST}/db", Public: true},
+		},
+		Unset:         []string{"OLD_FLAG"},
+		ShouldRestart: true,
+		Writer:        &buf,
+	})
+	c.Assert(err, check.IsNil)
+	expected := map[string]bind.EnvVar{
+		"DATABASE_HOST": {Name: "DATABASE_HOST", Value: "remotehost"},
+		"DATABASE_URL":  {Name: "DATABASE_URL", Value: "mysql://${DATABASE_HOST}/db", Public: true},
+	}
+	c.Assert(a.Env, check.DeepEquals, expected)
+	newApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(newApp.Env, check.DeepEquals, expected)
+	c.Assert(s.provisioner.Restarts(&a, ""), check.Equals, 1)
+	c.Assert(buf.String(), check.Equals, "---- Setting 2 and unsetting 1 environment variables ----\nrestarting app")
+}
+
+func (s *S) TestUpdateEnvsInvalid(c *check.C) {
+	a := App{
+		Name: "myapp",
+		Env: map[string]bind.EnvVar{
+			"DATABASE_HOST": {Name: "DATABASE_HOST", Value: "localhost"},
+		},
+		TeamOwner: s.team.Name,
+	}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.AddUnits(1, "web", nil)
+	c.Assert(err, check.IsNil)
+	tests := []struct {
+		args UpdateEnvsArgs
+		err  string
+	}{
+		{
+			args: UpdateEnvsArgs{Set: []bind.EnvVar{{Name: "VALID", Value: "1"}, {Name: "IN-VALID", Value: "2"}}},
+			err:  `.*invalid environment variable name "IN-VALID".*`,
+		},
+		{
+			args: UpdateEnvsArgs{Set: []bind.EnvVar{{Name: "VALID", Value: "1"}, {Name: "VALID", Value: "2"}}},
+			err:  `.*environment variable "VALID" set more than once.*`,
+		},
+		{
+			args: UpdateEnvsArgs{Set: []bind.EnvVar{{Name: "VALID", Value: "1"}}, Unset: []string{"VALID"}},
+			err:  `.*environment variable "VALID" can't be both set and unset.*`,
+		},
+		{
+			args: UpdateEnvsArgs{Set: []bind.EnvVar{{Name: "VALID", Value: "1"}}, Unset: []string{"env.DATABASE_HOST.value"}},
+			err:  `.*invalid environment variable name "env.DATABASE_HOST.value".*`,
+		},
+		{
+			args: UpdateEnvsArgs{Set: []bind.EnvVar{
+				{Name: "DATABASE_HOST", Value: "${DATABASE_URL}"},
+				{Name: "DATABASE_URL", Value: "${DATABASE_HOST}"},
+			}},
+			err: `.*cycle.*`,
+		},
+	}
+	for _, tt := range tests {
+		tt.args.ShouldRestart = true
+		err = a.UpdateEnvs(tt.args)
+		c.Check(err, check.FitsTypeOf, &errors.ValidationError{})
+		c.Check(err, check.ErrorMatches, tt.err)
+	}
+	newApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(newApp.Env, check.DeepEquals, map[string]bind.EnvVar{
+		"DATABASE_HOST": {Name: "DATABASE_HOST", Value: "localhost"},
+	})
+	c.Assert(s.provisioner.Restarts(&a, ""), check.Equals, 0)
+}
+
 func (s *S) TestUnsetEnvKeepServiceVariables(c *check.C) {
 	a := App{
 		Name: "myapp",
@@ -1383,6 +1600,57 @@ func (s *S) TestAddCName(c *check.C) {
 	c.Assert(app.CName, check.DeepEquals, []string{"ktulu.mycompany.com", "ktulu2.mycompany.com"})
 }
 
+func (s *S) TestAddCNameCreatesDNSRecord(c *check.C) {
+	config.Set("dns:provider", "fake")
+	defer config.Unset("dns:provider")
+	defer dnstest.FakeProvider.Reset()
+	app := &App{Name: "ktulu", TeamOwner: s.team.Name}
+	err := CreateApp(app, s.user)
+	c.Assert(err, check.IsNil)
+	err = app.AddCName("ktulu.mycompany.com")
+	c.Assert(err, check.IsNil)
+	c.Assert(dnstest.FakeProvider.Records(), check.DeepEquals, map[string]string{
+		"ktulu.mycompany.com": "ktulu.fakerouter.com",
+	})
+	err = app.RemoveCName("ktulu.mycompany.com")
+	c.Assert(err, check.IsNil)
+	c.Assert(dnstest.FakeProvider.Records(), check.HasLen, 0)
+}
+
+func (s *S) TestAddCNameDNSRecordConflict(c *check.C) {
+	config.Set("dns:provider", "fake")
+	defer config.Unset("dns:provider")
+	defer dnstest.FakeProvider.Reset()
+	err := dnstest.FakeProvider.AddCNAME("ktulu.mycompany.com", "other.example.com", 300)
+	c.Assert(err, check.IsNil)
+	app := &App{Name: "ktulu", TeamOwner: s.team.Name}
+	err = CreateApp(app, s.user)
+	c.Assert(err, check.IsNil)
+	err = app.AddCName("ktulu.mycompany.com")
+	c.Assert(err, check.DeepEquals, &dns.ErrRecordConflict{Name: "ktulu.mycompany.com", Target: "other.example.com"})
+	app, err = GetByName(app.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(app.CName, check.HasLen, 0)
+	c.Assert(routertest.FakeRouter.HasCName("ktulu.mycompany.com"), check.Equals, false)
+}
+
+func (s *S) TestDeleteRemovesDNSRecords(c *check.C) {
+	config.Set("dns:provider", "fake")
+	defer config.Unset("dns:provider")
+	defer dnstest.FakeProvider.Reset()
+	a := App{Name: "ktulu", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.AddCName("ktulu.mycompany.com")
+	c.Assert(err, check.IsNil)
+	c.Assert(dnstest.FakeProvider.Records(), check.HasLen, 1)
+	app, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	err = Delete(app, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(dnstest.FakeProvider.Records(), check.HasLen, 0)
+}
+
 func (s *S) TestAddCNameCantBeDuplicated(c *check.C) {
 	app := &App{Name: "ktulu", TeamOwner: s.team.Name}
 	err := CreateApp(app, s.user)
@@ -2132,6 +2400,7 @@ func (s *S) TestIsValid(c *check.C) {
 		{"myapp", "invalidteam", "pool1", "fake", "team not found"},
 		{"myapp", s.team.Name, "pool1", "faketls", "router \"faketls\" is not available for pool \"pool1\". Available routers are: \"fake, fake-hc, fake-tls\""},
 		{"myapp", "noaccessteam", "pool1", "fake", "App team owner \"noaccessteam\" has no access to pool \"pool1\""},
+		{"my app", "noaccessteam", "pool1", "faketls", errMsg + "\nApp team owner \"noaccessteam\" has no access to pool \"pool1\"\nrouter \"faketls\" is not available for pool \"pool1\". Available routers are: \"fake, fake-hc, fake-tls\""},
 	}
 	for _, d := range data {
 		a := App{Name: d.name, TeamOwner: d.teamOwner, Pool: d.pool, Routers: []appTypes.AppRouter{{Name: d.router}}}
@@ -2160,6 +2429,63 @@ func (s *S) TestRestart(c *check.C) {
 	c.Assert(restarts, check.Equals, 1)
 }
 
+func (s *S) TestRestartUnit(c *check.C) {
+	a := App{Name: "someapp", Platform: "django", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddUnits(&a, 2, "web", nil)
+	c.Assert(err, check.IsNil)
+	units, err := a.Units()
+	c.Assert(err, check.IsNil)
+	var b bytes.Buffer
+	err = a.RestartUnit(units[0].ID, &b)
+	c.Assert(err, check.IsNil)
+	c.Assert(b.String(), check.Matches, `(?s).*---- Restarting unit "`+units[0].ID+`" ----.*`)
+	c.Assert(s.provisioner.UnitRestarts(&a, units[0].ID), check.Equals, 1)
+	c.Assert(s.provisioner.UnitRestarts(&a, units[1].ID), check.Equals, 0)
+	err = a.RestartUnit("unknown", nil)
+	c.Assert(err, check.DeepEquals, &provision.UnitNotFoundError{ID: "unknown"})
+}
+
+func (s *S) TestInspectUnit(c *check.C) {
+	a := App{Name: "someapp", Platform: "django", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddUnits(&a, 1, "web", nil)
+	c.Assert(err, check.IsNil)
+	units, err := a.Units()
+	c.Assert(err, check.IsNil)
+	info, err := a.InspectUnit(units[0].ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(info.Unit, check.DeepEquals, units[0])
+	c.Assert(info.Node, check.Equals, units[0].IP)
+}
+
+func (s *S) TestSetUnitResources(c *check.C) {
+	a := App{Name: "someapp", Platform: "django", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.AddUnits(&a, 2, "web", nil)
+	c.Assert(err, check.IsNil)
+	units, err := a.Units()
+	c.Assert(err, check.IsNil)
+	resources := provision.UnitResources{Memory: 1024 * 1024 * 1024, CpuShare: 200}
+	err = a.SetUnitResources(units[0].ID, resources)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.provisioner.UnitResources(&a, units[0].ID), check.DeepEquals, resources)
+	c.Assert(s.provisioner.UnitResources(&a, units[1].ID).IsZero(), check.Equals, true)
+	info, err := a.InspectUnit(units[0].ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(info.Resources, check.DeepEquals, &resources)
+	err = a.SetUnitResources(units[0].ID, provision.UnitResources{})
+	c.Assert(err, check.IsNil)
+	c.Assert(s.provisioner.UnitResources(&a, units[0].ID).IsZero(), check.Equals, true)
+	err = a.SetUnitResources(units[0].ID, provision.UnitResources{Memory: -1})
+	c.Assert(err, check.FitsTypeOf, &errors.ValidationError{})
+	err = a.SetUnitResources("unknown", resources)
+	c.Assert(err, check.DeepEquals, &provision.UnitNotFoundError{ID: "unknown"})
+}
+
 func (s *S) TestStop(c *check.C) {
 	a := App{Name: "app", TeamOwner: s.team.Name}
 	err := CreateApp(&a, s.user)
@@ -2449,6 +2775,7 @@ func (s *S) TestAppMarshalJSON(c *check.C) {
 		"description": "description",
 		"teamowner":   "myteam",
 		"lock":        s.zeroLock,
+		"spread":      true,
 		"plan": map[string]interface{}{
 			"name":     "myplan",
 			"memory":   float64(64),
@@ -2506,6 +2833,7 @@ func (s *S) TestAppMarshalJSONWithoutRepository(c *check.C) {
 		"description": "description",
 		"teamowner":   "myteam",
 		"lock":        s.zeroLock,
+		"spread":      true,
 		"plan": map[string]interface{}{
 			"name":     "myplan",
 			"memory":   float64(64),
@@ -2555,6 +2883,7 @@ func (s *S) TestAppMarshalJSONUnitsError(c *check.C) {
 		"description": "",
 		"teamowner":   "",
 		"lock":        s.zeroLock,
+		"spread":      true,
 		"plan": map[string]interface{}{
 			"name":     "",
 			"memory":   float64(0),
@@ -3957,7 +4286,10 @@ func (s *S) TestAppCreateValidateRouterNotAvailableForPool(c *check.C) {
 	a := App{Name: "test", Platform: "python", TeamOwner: s.team.Name, Routers: []appTypes.AppRouter{{Name: "fake-tls"}}}
 	err := CreateApp(&a, s.user)
 	c.Assert(err, check.DeepEquals, &errors.ValidationError{
-		Message: "router \"fake-tls\" is not available for pool \"pool1\". Available routers are: \"fake, fake-hc\"",
+		Fields: []errors.FieldError{{
+			Field:   "router",
+			Message: "router \"fake-tls\" is not available for pool \"pool1\". Available routers are: \"fake, fake-hc\"",
+		}},
 	})
 }
 
@@ -4283,6 +4615,25 @@ func (s *S) TestUpdateDescription(c *check.C) {
 	c.Assert(dbApp.Description, check.Equals, "bleble")
 }
 
+func (s *S) TestUpdateSpread(c *check.C) {
+	app := App{Name: "example", Platform: "python", TeamOwner: s.team.Name}
+	err := CreateApp(&app, s.user)
+	c.Assert(err, check.IsNil)
+	c.Assert(app.SpreadUnits(), check.Equals, true)
+	spread := false
+	updateData := App{Name: "example", Spread: &spread}
+	err = app.Update(updateData, new(bytes.Buffer))
+	c.Assert(err, check.IsNil)
+	dbApp, err := GetByName(app.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.SpreadUnits(), check.Equals, false)
+	err = dbApp.Update(App{Name: "example", Description: "bleble"}, new(bytes.Buffer))
+	c.Assert(err, check.IsNil)
+	dbApp, err = GetByName(app.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.SpreadUnits(), check.Equals, false)
+}
+
 func (s *S) TestUpdatePlatformLanguage(c *check.C) {
 	app := App{Name: "example", Platform: "python", TeamOwner: s.team.Name}
 	err := CreateApp(&app, s.user)
@@ -4561,6 +4912,18 @@ func (s *S) TestRenameTeam(c *check.C) {
 	c.Assert(dbApps[1].Teams, check.DeepEquals, []string{"t3", "t1"})
 }
 
+func (s *S) TestRenameTeamToExistingTeam(c *check.C) {
+	a := App{Name: "test1", TeamOwner: "t1", Routers: []appTypes.AppRouter{{Name: "fake"}}, Teams: []string{"t1", "t2", "t3"}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	err = RenameTeam("t1", "t3")
+	c.Assert(err, check.IsNil)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.TeamOwner, check.Equals, "t3")
+	c.Assert(dbApp.Teams, check.DeepEquals, []string{"t3", "t2"})
+}
+
 func (s *S) TestRenameTeamLockedApp(c *check.C) {
 	apps := []App{
 		{Name: "test1", TeamOwner: "t1", Routers: []appTypes.AppRouter{{Name: "fake"}}, Teams: []string{"t2", "t3", "t1"}},
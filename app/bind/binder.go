@@ -6,13 +6,47 @@
 // service.
 package bind
 
-import "io"
+import (
+	"io"
 
-// EnvVar represents a environment variable for an app.
+	"github.com/tsuru/tsuru/secret"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// EnvVar represents a environment variable for an app. References to other
+// variables in the value, in the form ${NAME}, are only expanded when
+// Interpolate is set.
 type EnvVar struct {
-	Name   string `json:"name"`
-	Value  string `json:"value"`
-	Public bool   `json:"public"`
+	Name        string `json:"name"`
+	Value       string `json:"value"`
+	Public      bool   `json:"public"`
+	Interpolate bool   `json:"interpolate,omitempty"`
+}
+
+// GetBSON encrypts the value of private variables, like the app token,
+// before storing them, when secrets:encrypt-storage is enabled.
+func (e EnvVar) GetBSON() (interface{}, error) {
+	type plain EnvVar
+	if e.Public {
+		return plain(e), nil
+	}
+	value, err := secret.Seal(e.Value)
+	if err != nil {
+		return nil, err
+	}
+	e.Value = value
+	return plain(e), nil
+}
+
+// SetBSON decrypts the value of the variable read from the storage.
+func (e *EnvVar) SetBSON(raw bson.Raw) error {
+	type plain EnvVar
+	err := raw.Unmarshal((*plain)(e))
+	if err != nil {
+		return err
+	}
+	e.Value, err = secret.Open(e.Value)
+	return err
 }
 
 type ServiceEnvVar struct {
@@ -21,6 +55,59 @@ type ServiceEnvVar struct {
 	InstanceName string `json:"-"`
 }
 
+// serviceEnvVarDoc is the stored form of ServiceEnvVar. EnvVar isn't
+// embedded, as its GetBSON and SetBSON would be promoted and handle the
+// whole document.
+type serviceEnvVarDoc struct {
+	Name         string
+	Value        string
+	Public       bool
+	Interpolate  bool
+	ServiceName  string
+	InstanceName string
+}
+
+// GetBSON encrypts the value of the variable before storing it, when
+// secrets:encrypt-storage is enabled.
+func (e ServiceEnvVar) GetBSON() (interface{}, error) {
+	value, err := secret.Seal(e.Value)
+	if err != nil {
+		return nil, err
+	}
+	return serviceEnvVarDoc{
+		Name:         e.Name,
+		Value:        value,
+		Public:       e.Public,
+		Interpolate:  e.Interpolate,
+		ServiceName:  e.ServiceName,
+		InstanceName: e.InstanceName,
+	}, nil
+}
+
+// SetBSON decrypts the value of the variable read from the storage.
+func (e *ServiceEnvVar) SetBSON(raw bson.Raw) error {
+	var doc serviceEnvVarDoc
+	err := raw.Unmarshal(&doc)
+	if err != nil {
+		return err
+	}
+	value, err := secret.Open(doc.Value)
+	if err != nil {
+		return err
+	}
+	*e = ServiceEnvVar{
+		EnvVar: EnvVar{
+			Name:        doc.Name,
+			Value:       value,
+			Public:      doc.Public,
+			Interpolate: doc.Interpolate,
+		},
+		ServiceName:  doc.ServiceName,
+		InstanceName: doc.InstanceName,
+	}
+	return nil
+}
+
 // Unit represents an application unit to be used in binds.
 type Unit interface {
 	GetID() string
@@ -37,11 +124,18 @@ type App interface {
 	// GetUnits returns the app units.
 	GetUnits() ([]Unit, error)
 
+	// GetServiceEnvs returns the environment variables set by service
+	// instances bound to the app.
+	GetServiceEnvs() []ServiceEnvVar
+
 	// AddInstance adds an instance to the application.
 	AddInstance(args AddInstanceArgs) error
 
 	// RemoveInstance removes an instance from the application.
 	RemoveInstance(args RemoveInstanceArgs) error
+
+	// RestartIfUnits restarts the app, unless it has no units.
+	RestartIfUnits(w io.Writer) error
 }
 
 type SetEnvArgs struct {
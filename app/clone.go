@@ -0,0 +1,79 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/service"
+	appTypes "github.com/tsuru/tsuru/types/app"
+)
+
+// CloneOptions holds the name of the app created by Clone and the service
+// instances bound to it.
+type CloneOptions struct {
+	Name      string
+	Instances []service.ServiceInstance
+	Writer    io.Writer
+}
+
+// CloneResult lists the resources copied from the source app.
+type CloneResult struct {
+	Source    string
+	Envs      []string
+	Instances []string
+}
+
+// Clone creates a new app with the platform, plan, pool, team owner,
+// description and tags of src, then sets the public environment variables of
+// src in it and binds it to the given service instances. The new app isn't
+// deployed nor restarted. Resources copied before a failure are kept and
+// listed in the result.
+func Clone(src *App, opts CloneOptions, user *auth.User) (*App, *CloneResult, error) {
+	a := App{
+		Name:        opts.Name,
+		Platform:    src.Platform,
+		Plan:        appTypes.Plan{Name: src.Plan.Name},
+		Pool:        src.Pool,
+		TeamOwner:   src.TeamOwner,
+		Description: src.Description,
+		Tags:        src.Tags,
+	}
+	err := CreateApp(&a, user)
+	if err != nil {
+		return nil, nil, err
+	}
+	result := &CloneResult{Source: src.Name}
+	var envs []bind.EnvVar
+	for _, env := range src.Env {
+		if env.Public {
+			envs = append(envs, env)
+		}
+	}
+	if len(envs) > 0 {
+		sort.Slice(envs, func(i, j int) bool { return envs[i].Name < envs[j].Name })
+		err = a.SetEnvs(bind.SetEnvArgs{Envs: envs, Writer: opts.Writer})
+		if err != nil {
+			return &a, result, errors.Wrap(err, "unable to set environment variables")
+		}
+		for _, env := range envs {
+			result.Envs = append(result.Envs, env.Name)
+		}
+	}
+	for i := range opts.Instances {
+		si := &opts.Instances[i]
+		err = si.BindApp(&a, false, opts.Writer)
+		if err != nil {
+			return &a, result, errors.Wrapf(err, "unable to bind instance %q of service %q", si.Name, si.ServiceName)
+		}
+		result.Instances = append(result.Instances, fmt.Sprintf("%s/%s", si.ServiceName, si.Name))
+	}
+	return &a, result, nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/service"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestClone(c *check.C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"DATABASE_HOST":"localhost"}`))
+	}))
+	defer server.Close()
+	srvc := service.Service{
+		Name:       "mysql",
+		Endpoint:   map[string]string{"production": server.URL},
+		Password:   "abcde",
+		OwnerTeams: []string{s.team.Name},
+	}
+	err := srvc.Create()
+	c.Assert(err, check.IsNil)
+	src := App{
+		Name:        "riviera",
+		Platform:    "python",
+		TeamOwner:   s.team.Name,
+		Description: "the source app",
+		Tags:        []string{"review"},
+	}
+	err = CreateApp(&src, s.user)
+	c.Assert(err, check.IsNil)
+	err = src.SetEnvs(bind.SetEnvArgs{Envs: []bind.EnvVar{
+		{Name: "PUBLIC_VAR", Value: "public", Public: true},
+		{Name: "PRIVATE_VAR", Value: "private", Public: false},
+	}})
+	c.Assert(err, check.IsNil)
+	si := service.ServiceInstance{Name: "mydb", ServiceName: "mysql", Apps: []string{src.Name}}
+	err = s.conn.ServiceInstances().Insert(si)
+	c.Assert(err, check.IsNil)
+	a, result, err := Clone(&src, CloneOptions{Name: "riviera-review", Instances: []service.ServiceInstance{si}}, s.user)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, &CloneResult{
+		Source:    "riviera",
+		Envs:      []string{"PUBLIC_VAR"},
+		Instances: []string{"mysql/mydb"},
+	})
+	clone, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(clone.Platform, check.Equals, "python")
+	c.Assert(clone.Pool, check.Equals, src.Pool)
+	c.Assert(clone.Plan.Name, check.Equals, src.Plan.Name)
+	c.Assert(clone.TeamOwner, check.Equals, s.team.Name)
+	c.Assert(clone.Description, check.Equals, "the source app")
+	c.Assert(clone.Tags, check.DeepEquals, []string{"review"})
+	c.Assert(clone.Env["PUBLIC_VAR"].Value, check.Equals, "public")
+	_, ok := clone.Env["PRIVATE_VAR"]
+	c.Assert(ok, check.Equals, false)
+	c.Assert(clone.ServiceEnvs, check.HasLen, 1)
+	c.Assert(clone.ServiceEnvs[0].Name, check.Equals, "DATABASE_HOST")
+	instance, err := service.GetServiceInstance("mysql", "mydb")
+	c.Assert(err, check.IsNil)
+	c.Assert(instance.Apps, check.DeepEquals, []string{"riviera", "riviera-review"})
+}
+
+func (s *S) TestCloneAlreadyExists(c *check.C) {
+	src := App{Name: "riviera", Platform: "python", TeamOwner: s.team.Name}
+	err := CreateApp(&src, s.user)
+	c.Assert(err, check.IsNil)
+	a, _, err := Clone(&src, CloneOptions{Name: "riviera"}, s.user)
+	c.Assert(a, check.IsNil)
+	e, ok := err.(*AppCreationError)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.Err, check.Equals, ErrAppAlreadyExists)
+}
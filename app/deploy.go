@@ -8,18 +8,22 @@ import (
 	"fmt"
 	"io"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/tsuru/tsuru/app/image"
 	"github.com/tsuru/tsuru/builder"
 	"github.com/tsuru/tsuru/db"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/event"
 	tsuruIo "github.com/tsuru/tsuru/io"
 	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/pool"
 	"github.com/tsuru/tsuru/router/rebuild"
+	"github.com/tsuru/tsuru/scan"
 	"github.com/tsuru/tsuru/set"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
@@ -51,8 +55,11 @@ type DeployData struct {
 	User        string
 	Origin      string
 	CanRollback bool
+	Canceled    bool
 	RemoveDate  time.Time `bson:",omitempty"`
 	Diff        string
+	Scan        *scan.Result   `bson:",omitempty"`
+	Changes     *DeployChanges `bson:",omitempty"`
 }
 
 func findValidImages(apps ...App) (set.Set, error) {
@@ -99,6 +106,40 @@ func ListDeploys(filter *Filter, skip, limit int) ([]DeployData, error) {
 	return list, nil
 }
 
+// LastDeploys returns the time of the last successful deploy of each of the
+// given apps. Apps that were never deployed are not included in the result.
+func LastDeploys(appNames []string) (map[string]time.Time, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	pipe := conn.Events().Pipe([]bson.M{
+		{"$match": bson.M{
+			"target.type":  event.TargetTypeApp,
+			"target.value": bson.M{"$in": appNames},
+			"kind.type":    event.KindTypePermission,
+			"kind.name":    permission.PermAppDeploy.FullName(),
+			"running":      false,
+			"error":        "",
+		}},
+		{"$group": bson.M{"_id": "$target.value", "endtime": bson.M{"$max": "$endtime"}}},
+	})
+	var results []struct {
+		App     string `bson:"_id"`
+		EndTime time.Time
+	}
+	err = pipe.All(&results)
+	if err != nil {
+		return nil, err
+	}
+	lastDeploys := make(map[string]time.Time, len(results))
+	for _, r := range results {
+		lastDeploys[r.App] = r.EndTime
+	}
+	return lastDeploys, nil
+}
+
 func GetDeploy(id string) (*DeployData, error) {
 	if !bson.IsObjectIdHex(id) {
 		return nil, errors.Errorf("id parameter is not ObjectId: %s", id)
@@ -119,6 +160,7 @@ func eventToDeployData(evt *event.Event, validImages set.Set, full bool) *Deploy
 		Duration:  evt.EndTime.Sub(evt.StartTime),
 		Error:     evt.Error,
 		User:      evt.Owner.Name,
+		Canceled:  evt.CancelInfo.Canceled,
 	}
 	var startOpts DeployOptions
 	err := evt.StartData(&startOpts)
@@ -128,10 +170,15 @@ func eventToDeployData(evt *event.Event, validImages set.Set, full bool) *Deploy
 	}
 	if full {
 		data.Log = evt.Log
-		var otherData map[string]string
+		data.Scan, err = scan.GetResult(evt.UniqueID)
+		if err != nil {
+			log.Errorf("unable to get scan result of deploy %s: %s", evt.UniqueID.Hex(), err)
+		}
+		var otherData deployOtherData
 		err = evt.OtherData(&otherData)
 		if err == nil {
-			data.Diff = otherData["diff"]
+			data.Diff = otherData.Diff
+			data.Changes = otherData.Changes
 		}
 	}
 	var endData map[string]string
@@ -165,6 +212,31 @@ type DeployOptions struct {
 	Event        *event.Event `bson:"-"`
 	Kind         DeployKind
 	Message      string
+	// Emergency deploys are allowed during the freeze windows of the team
+	// owning the app, see CheckDeployFreeze.
+	Emergency bool `bson:",omitempty"`
+	// BuildArgs are environment variables only available to the build, like
+	// tokens for private package registries. They're neither stored nor set
+	// in the units of the app.
+	BuildArgs map[string]string `bson:"-"`
+}
+
+// ParseBuildArgs parses build arguments in the NAME=value format.
+func ParseBuildArgs(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	args := make(map[string]string, len(values))
+	for _, v := range values {
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) != 2 || !envNameRegexp.MatchString(parts[0]) {
+			return nil, &tsuruErrors.ValidationError{
+				Message: fmt.Sprintf("invalid build argument %q, it must be in the NAME=value format", v),
+			}
+		}
+		args[parts[0]] = parts[1]
+	}
+	return args, nil
 }
 
 func (o *DeployOptions) GetOrigin() string {
@@ -255,6 +327,10 @@ func Deploy(opts DeployOptions) (string, error) {
 	if err != nil {
 		log.Errorf("WARNING: couldn't increment deploy count, deploy opts: %#v", opts)
 	}
+	err = recordDeployChanges(opts.App, opts.Commit, imageID, opts.Event)
+	if err != nil {
+		log.Errorf("WARNING: couldn't record changes of deploy of app %q: %s", opts.App.Name, err)
+	}
 	if opts.Kind == DeployImage || opts.Kind == DeployRollback {
 		if !opts.App.UpdatePlatform {
 			opts.App.SetUpdatePlatform(true)
@@ -291,6 +367,10 @@ func deployToProvisioner(opts *DeployOptions, evt *event.Event) (string, error)
 			if err != nil {
 				return "", err
 			}
+			err = scanImage(deployer, opts, imageID, evt)
+			if err != nil {
+				return "", err
+			}
 			return deployer.Deploy(opts.App, imageID, evt)
 		}
 	} else {
@@ -331,6 +411,7 @@ func builderDeploy(prov provision.BuilderDeploy, opts *DeployOptions, evt *event
 		Rebuild:       isRebuild,
 		ImageID:       opts.Image,
 		Tag:           opts.BuildTag,
+		BuildArgs:     opts.BuildArgs,
 	}
 	builder, err := opts.App.getBuilder()
 	if err != nil {
@@ -339,6 +420,34 @@ func builderDeploy(prov provision.BuilderDeploy, opts *DeployOptions, evt *event
 	return builder.Build(prov, opts.App, evt, buildOpts)
 }
 
+// scanImage checks the image built by the deploy for vulnerabilities,
+// according to the scan policy of the app's pool. The result of the scan is
+// stored along with the deploy and, when the policy blocks the deploy, the
+// image is removed.
+func scanImage(deployer provision.BuilderDeploy, opts *DeployOptions, imageID string, evt *event.Event) error {
+	if !scan.Enabled() {
+		return nil
+	}
+	var policy string
+	if opts.App.Pool != "" {
+		p, err := pool.GetPoolByName(opts.App.Pool)
+		if err != nil {
+			return err
+		}
+		policy = p.ScanPolicy
+	}
+	result, err := scan.ScanImage(imageID, policy, evt)
+	if result != nil {
+		if saveErr := scan.SaveResult(evt.UniqueID, result); saveErr != nil {
+			log.Errorf("unable to save scan result of image %s: %s", imageID, saveErr)
+		}
+	}
+	if err != nil {
+		deployer.CleanImage(opts.App.Name, imageID)
+	}
+	return err
+}
+
 func ValidateOrigin(origin string) bool {
 	originList := []string{"app-deploy", "git", "rollback", "drag-and-drop", "image", "rebuild"}
 	for _, ol := range originList {
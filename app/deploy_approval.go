@@ -0,0 +1,275 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	DeployApprovalPending  = "pending"
+	DeployApprovalApproved = "approved"
+	DeployApprovalRejected = "rejected"
+	DeployApprovalExpired  = "expired"
+	DeployApprovalCanceled = "canceled"
+
+	defaultDeployApprovalTimeout = time.Hour
+)
+
+var (
+	ErrDeployApprovalNotFound = errors.New("deploy approval not found")
+	ErrDeployApprovalDecided  = errors.New("deploy is not waiting for approval")
+	ErrDeployApprovalSelf     = errors.New("deploys must be approved by a user other than the one who requested them")
+	ErrDeployApprovalExpired  = errors.New("deploy not approved in time")
+
+	deployApprovalPollInterval = time.Second
+)
+
+// DeployRejectedError is returned to the deploy waiting for approval when it
+// is rejected.
+type DeployRejectedError struct {
+	User   string
+	Reason string
+}
+
+func (e *DeployRejectedError) Error() string {
+	msg := fmt.Sprintf("deploy rejected by %s", e.User)
+	if e.Reason != "" {
+		msg += ": " + e.Reason
+	}
+	return msg
+}
+
+// DeployApproval is a deploy waiting for, or that already went through,
+// the approval of a second user. Apps that require approval keep their
+// deploys out of the deploy queue until they are approved.
+type DeployApproval struct {
+	ID          bson.ObjectId `bson:"_id"`
+	App         string
+	Requester   string
+	Kind        DeployKind
+	Origin      string
+	Message     string
+	Image       string
+	Status      string
+	Decider     string `bson:",omitempty"`
+	Reason      string `bson:",omitempty"`
+	RequestedAt time.Time
+	DecidedAt   time.Time `bson:",omitempty"`
+}
+
+// SetDeployApproval enables or disables the approval of deploys of the app,
+// including rollbacks and rebuilds.
+func (app *App) SetDeployApproval(required bool) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Apps().Update(bson.M{"name": app.Name}, bson.M{"$set": bson.M{"deployapproval": required}})
+	if err != nil {
+		return err
+	}
+	app.DeployApproval = required
+	return nil
+}
+
+func deployApprovalTimeout() time.Duration {
+	timeout, err := config.GetInt("deploy:approval-timeout")
+	if err != nil || timeout <= 0 {
+		return defaultDeployApprovalTimeout
+	}
+	return time.Duration(timeout) * time.Second
+}
+
+// RequestDeployApproval registers the deploy as waiting for approval. An
+// internal event is kept running while the deploy waits, notifying event
+// watchers about the request.
+func RequestDeployApproval(opts DeployOptions) (*DeployApproval, error) {
+	approval := DeployApproval{
+		ID:          bson.NewObjectId(),
+		App:         opts.App.Name,
+		Requester:   opts.User,
+		Kind:        opts.GetKind(),
+		Origin:      opts.Origin,
+		Message:     opts.Message,
+		Image:       opts.Image,
+		Status:      DeployApprovalPending,
+		RequestedAt: time.Now().UTC(),
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	err = conn.DeployApprovals().Insert(approval)
+	if err != nil {
+		return nil, err
+	}
+	return &approval, nil
+}
+
+// WaitDeployApproval blocks until the deploy is approved, returning a
+// DeployRejectedError if it's rejected. Deploys not decided within the
+// deploy:approval-timeout config entry, one hour by default, expire.
+func WaitDeployApproval(ctx context.Context, approval *DeployApproval, w io.Writer) (err error) {
+	evt, err := event.NewInternal(&event.Opts{
+		Target:       event.Target{Type: event.TargetTypeApp, Value: approval.App},
+		InternalKind: "deploy-approval",
+		RawOwner:     event.Owner{Type: event.OwnerTypeUser, Name: approval.Requester},
+		CustomData:   approval,
+		DisableLock:  true,
+		Allowed: event.Allowed(permission.PermAppReadEvents,
+			permission.Context(permission.CtxApp, approval.App)),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.DoneCustomData(err, approval) }()
+	fmt.Fprintf(w, " ---> Deploy waiting for approval (approval id: %s)...\n", approval.ID.Hex())
+	timeout := time.NewTimer(deployApprovalTimeout())
+	defer timeout.Stop()
+	ticker := time.NewTicker(deployApprovalPollInterval)
+	defer ticker.Stop()
+	for {
+		var current *DeployApproval
+		current, err = GetDeployApproval(approval.App, approval.ID.Hex())
+		if err != nil {
+			return err
+		}
+		*approval = *current
+		switch approval.Status {
+		case DeployApprovalApproved:
+			fmt.Fprintf(w, " ---> Deploy approved by %s\n", approval.Decider)
+			return nil
+		case DeployApprovalRejected:
+			return &DeployRejectedError{User: approval.Decider, Reason: approval.Reason}
+		}
+		select {
+		case <-ticker.C:
+		case <-timeout.C:
+			finishDeployApproval(approval, DeployApprovalExpired)
+			return ErrDeployApprovalExpired
+		case <-ctx.Done():
+			finishDeployApproval(approval, DeployApprovalCanceled)
+			return ctx.Err()
+		}
+	}
+}
+
+// finishDeployApproval marks a pending approval that will no longer be
+// waited for, so it can't be decided anymore.
+func finishDeployApproval(approval *DeployApproval, status string) {
+	conn, err := db.Conn()
+	if err != nil {
+		log.Errorf("[deploy-approval] unable to update approval %s: %s", approval.ID.Hex(), err)
+		return
+	}
+	defer conn.Close()
+	now := time.Now().UTC()
+	err = conn.DeployApprovals().Update(
+		bson.M{"_id": approval.ID, "status": DeployApprovalPending},
+		bson.M{"$set": bson.M{"status": status, "decidedat": now}},
+	)
+	if err != nil && err != mgo.ErrNotFound {
+		log.Errorf("[deploy-approval] unable to update approval %s: %s", approval.ID.Hex(), err)
+		return
+	}
+	approval.Status = status
+	approval.DecidedAt = now
+}
+
+// GetDeployApproval returns an approval request of the app by its id.
+func GetDeployApproval(appName, id string) (*DeployApproval, error) {
+	if !bson.IsObjectIdHex(id) {
+		return nil, ErrDeployApprovalNotFound
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var approval DeployApproval
+	err = conn.DeployApprovals().Find(bson.M{"_id": bson.ObjectIdHex(id), "app": appName}).One(&approval)
+	if err == mgo.ErrNotFound {
+		return nil, ErrDeployApprovalNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &approval, nil
+}
+
+// ListDeployApprovals returns the approval requests of an app, the most
+// recent first. An empty status returns requests in any status.
+func ListDeployApprovals(appName, status string) ([]DeployApproval, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	query := bson.M{"app": appName}
+	if status != "" {
+		query["status"] = status
+	}
+	var approvals []DeployApproval
+	err = conn.DeployApprovals().Find(query).Sort("-requestedat").All(&approvals)
+	if err != nil {
+		return nil, err
+	}
+	return approvals, nil
+}
+
+// DecideDeployApproval approves or rejects a deploy waiting for approval.
+// The user who requested the deploy can't decide on it.
+func DecideDeployApproval(appName, id, user string, approve bool, reason string) (*DeployApproval, error) {
+	approval, err := GetDeployApproval(appName, id)
+	if err != nil {
+		return nil, err
+	}
+	if approval.Status != DeployApprovalPending {
+		return nil, ErrDeployApprovalDecided
+	}
+	if approval.Requester == user {
+		return nil, ErrDeployApprovalSelf
+	}
+	status := DeployApprovalRejected
+	if approve {
+		status = DeployApprovalApproved
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	now := time.Now().UTC()
+	err = conn.DeployApprovals().Update(
+		bson.M{"_id": approval.ID, "status": DeployApprovalPending},
+		bson.M{"$set": bson.M{"status": status, "decider": user, "reason": reason, "decidedat": now}},
+	)
+	if err == mgo.ErrNotFound {
+		return nil, ErrDeployApprovalDecided
+	}
+	if err != nil {
+		return nil, err
+	}
+	approval.Status = status
+	approval.Decider = user
+	approval.Reason = reason
+	approval.DecidedAt = now
+	return approval, nil
+}
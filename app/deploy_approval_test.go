@@ -0,0 +1,94 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestSetDeployApproval(c *check.C) {
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetDeployApproval(true)
+	c.Assert(err, check.IsNil)
+	c.Assert(a.DeployApproval, check.Equals, true)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.DeployApproval, check.Equals, true)
+}
+
+func (s *S) TestDecideDeployApproval(c *check.C) {
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	approval, err := RequestDeployApproval(DeployOptions{App: &a, User: "me", Image: "registry.example.com/app:v1"})
+	c.Assert(err, check.IsNil)
+	c.Assert(approval.Kind, check.Equals, DeployImage)
+	_, err = DecideDeployApproval(a.Name, approval.ID.Hex(), "me", true, "")
+	c.Assert(err, check.Equals, ErrDeployApprovalSelf)
+	decided, err := DecideDeployApproval(a.Name, approval.ID.Hex(), "you", false, "failing tests")
+	c.Assert(err, check.IsNil)
+	c.Assert(decided.Status, check.Equals, DeployApprovalRejected)
+	c.Assert(decided.Decider, check.Equals, "you")
+	_, err = DecideDeployApproval(a.Name, approval.ID.Hex(), "you", true, "")
+	c.Assert(err, check.Equals, ErrDeployApprovalDecided)
+	_, err = DecideDeployApproval("otherapp", approval.ID.Hex(), "you", true, "")
+	c.Assert(err, check.Equals, ErrDeployApprovalNotFound)
+	approvals, err := ListDeployApprovals(a.Name, DeployApprovalPending)
+	c.Assert(err, check.IsNil)
+	c.Assert(approvals, check.HasLen, 0)
+	approvals, err = ListDeployApprovals(a.Name, "")
+	c.Assert(err, check.IsNil)
+	c.Assert(approvals, check.HasLen, 1)
+}
+
+func (s *S) TestWaitDeployApprovalApproved(c *check.C) {
+	defer func(interval time.Duration) { deployApprovalPollInterval = interval }(deployApprovalPollInterval)
+	deployApprovalPollInterval = 10 * time.Millisecond
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	approval, err := RequestDeployApproval(DeployOptions{App: &a, User: "me", Image: "registry.example.com/app:v1"})
+	c.Assert(err, check.IsNil)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		DecideDeployApproval(a.Name, approval.ID.Hex(), "you", true, "")
+	}()
+	var buf bytes.Buffer
+	err = WaitDeployApproval(context.Background(), approval, &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(approval.Status, check.Equals, DeployApprovalApproved)
+	c.Assert(buf.String(), check.Matches, `(?s).*waiting for approval.*approved by you\n`)
+}
+
+func (s *S) TestWaitDeployApprovalRejected(c *check.C) {
+	defer func(interval time.Duration) { deployApprovalPollInterval = interval }(deployApprovalPollInterval)
+	deployApprovalPollInterval = 10 * time.Millisecond
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	approval, err := RequestDeployApproval(DeployOptions{App: &a, User: "me", Image: "registry.example.com/app:v1"})
+	c.Assert(err, check.IsNil)
+	_, err = DecideDeployApproval(a.Name, approval.ID.Hex(), "you", false, "not today")
+	c.Assert(err, check.IsNil)
+	var buf bytes.Buffer
+	err = WaitDeployApproval(context.Background(), approval, &buf)
+	c.Assert(err, check.DeepEquals, &DeployRejectedError{User: "you", Reason: "not today"})
+	c.Assert(err, check.ErrorMatches, "deploy rejected by you: not today")
+}
+
+func (s *S) TestWaitDeployApprovalCanceled(c *check.C) {
+	defer func(interval time.Duration) { deployApprovalPollInterval = interval }(deployApprovalPollInterval)
+	deployApprovalPollInterval = 10 * time.Millisecond
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	approval, err := RequestDeployApproval(DeployOptions{App: &a, User: "me", Image: "registry.example.com/app:v1"})
+	c.Assert(err, check.IsNil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var buf bytes.Buffer
+	err = WaitDeployApproval(ctx, approval, &buf)
+	c.Assert(err, check.Equals, context.Canceled)
+	_, err = DecideDeployApproval(a.Name, approval.ID.Hex(), "you", true, "")
+	c.Assert(err, check.Equals, ErrDeployApprovalDecided)
+}
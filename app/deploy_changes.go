@@ -0,0 +1,209 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"sort"
+
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/secret"
+	"github.com/tsuru/tsuru/service"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// deployState is the state of an app after a successful deploy, stored in
+// the deploy event so the next deploy can be compared with it. Env values
+// are never stored, only their fingerprints keyed with the secrets key, see
+// envFingerprint.
+type deployState struct {
+	Commit   string
+	Image    string
+	Envs     map[string]string
+	Services []string
+}
+
+// DeployChanges describes what changed in the app between a deploy and the
+// previous successful one.
+type DeployChanges struct {
+	PreviousCommit  string
+	PreviousImage   string
+	EnvsAdded       []string
+	EnvsChanged     []string
+	EnvsRemoved     []string
+	ServicesBound   []string
+	ServicesUnbound []string
+}
+
+type deployOtherData struct {
+	Diff    string         `bson:"diff"`
+	State   *deployState   `bson:"state,omitempty"`
+	Changes *DeployChanges `bson:"changes,omitempty"`
+}
+
+func newDeployState(app *App, commit, imageID string) (*deployState, error) {
+	state := &deployState{
+		Commit: commit,
+		Image:  imageID,
+		Envs:   map[string]string{},
+	}
+	for _, env := range app.Env {
+		state.Envs[env.Name] = envFingerprint(env.Value)
+	}
+	for _, env := range app.secretEnvs() {
+		state.Envs[env.Name] = envFingerprint(env.Value)
+	}
+	instances, err := service.GetServiceInstancesBoundToApp(app.Name)
+	if err != nil {
+		return nil, err
+	}
+	for _, si := range instances {
+		state.Services = append(state.Services, si.ServiceName+"/"+si.Name)
+	}
+	sort.Strings(state.Services)
+	return state, nil
+}
+
+// envFingerprint returns the fingerprint of an env value stored in the
+// deploy state. Deploy events are readable by every user allowed to read
+// the app events, so plain digests, which could be brute-forced, are never
+// stored. Without a secrets key only the env name is stored and changed
+// values aren't reported.
+func envFingerprint(value string) string {
+	fp, err := secret.Fingerprint(value)
+	if err != nil {
+		return ""
+	}
+	return fp
+}
+
+func lastDeployState(appName string) (*deployState, error) {
+	evts, err := event.List(&event.Filter{
+		Target:    event.Target{Type: event.TargetTypeApp, Value: appName},
+		KindNames: []string{permission.PermAppDeploy.FullName()},
+		KindType:  event.KindTypePermission,
+		Raw:       bson.M{"othercustomdata.state": bson.M{"$exists": true}},
+		Limit:     1,
+	})
+	if err != nil || len(evts) == 0 {
+		return nil, err
+	}
+	var data deployOtherData
+	err = evts[0].OtherData(&data)
+	if err != nil {
+		return nil, err
+	}
+	return data.State, nil
+}
+
+func (s *deployState) changesSince(previous *deployState) *DeployChanges {
+	if previous == nil {
+		previous = &deployState{}
+	}
+	changes := &DeployChanges{
+		PreviousCommit: previous.Commit,
+		PreviousImage:  previous.Image,
+	}
+	for name, fp := range s.Envs {
+		oldFp, ok := previous.Envs[name]
+		if !ok {
+			changes.EnvsAdded = append(changes.EnvsAdded, name)
+		} else if secret.IsFingerprint(fp) && secret.IsFingerprint(oldFp) && oldFp != fp {
+			changes.EnvsChanged = append(changes.EnvsChanged, name)
+		}
+	}
+	for name := range previous.Envs {
+		if _, ok := s.Envs[name]; !ok {
+			changes.EnvsRemoved = append(changes.EnvsRemoved, name)
+		}
+	}
+	changes.ServicesBound = missingFrom(s.Services, previous.Services)
+	changes.ServicesUnbound = missingFrom(previous.Services, s.Services)
+	sort.Strings(changes.EnvsAdded)
+	sort.Strings(changes.EnvsChanged)
+	sort.Strings(changes.EnvsRemoved)
+	return changes
+}
+
+func missingFrom(values, other []string) []string {
+	var result []string
+	for _, v := range values {
+		found := false
+		for _, o := range other {
+			if v == o {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// recordDeployChanges stores in the deploy event the state of the app after
+// the deploy and what changed since the previous successful deploy.
+func recordDeployChanges(app *App, commit, imageID string, evt *event.Event) error {
+	previous, err := lastDeployState(app.Name)
+	if err != nil {
+		return err
+	}
+	state, err := newDeployState(app, commit, imageID)
+	if err != nil {
+		return err
+	}
+	err = evt.SetOtherCustomDataKey("state", state)
+	if err != nil {
+		return err
+	}
+	return evt.SetOtherCustomDataKey("changes", state.changesSince(previous))
+}
+
+// RemoveDeployEnvHashes removes the digests of env values stored in the
+// state of deploy events by previous versions of tsuru, keeping only the env
+// names. Deploy events are readable by other users, and unsalted digests of
+// short values may be brute-forced.
+func RemoveDeployEnvHashes() error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	query := bson.M{
+		"kind.name":                  permission.PermAppDeploy.FullName(),
+		"othercustomdata.state.envs": bson.M{"$exists": true},
+	}
+	iter := conn.Events().Find(query).Select(bson.M{"othercustomdata.state.envs": 1}).Iter()
+	var evt struct {
+		ID              interface{} `bson:"_id"`
+		OtherCustomData struct {
+			State struct {
+				Envs map[string]string
+			}
+		}
+	}
+	for iter.Next(&evt) {
+		envs := make(map[string]string, len(evt.OtherCustomData.State.Envs))
+		changed := false
+		for name, value := range evt.OtherCustomData.State.Envs {
+			if value != "" && !secret.IsFingerprint(value) {
+				value = ""
+				changed = true
+			}
+			envs[name] = value
+		}
+		if changed {
+			err = conn.Events().UpdateId(evt.ID, bson.M{"$set": bson.M{"othercustomdata.state.envs": envs}})
+			if err != nil {
+				iter.Close()
+				return err
+			}
+		}
+		evt.OtherCustomData.State.Envs = nil
+	}
+	return iter.Close()
+}
@@ -0,0 +1,244 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/db"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"gopkg.in/mgo.v2"
+)
+
+const minutesInWeek = 7 * 24 * 60
+
+var ErrDeployFreezeNotFound = errors.New("deploy freeze not found")
+
+// DeployFrozenError is returned when a deploy is attempted during one of the
+// freeze windows of the team owning the app.
+type DeployFrozenError struct {
+	Team   string
+	Until  time.Time
+	Reason string
+}
+
+func (e *DeployFrozenError) Error() string {
+	msg := fmt.Sprintf("deploys of apps owned by team %q are frozen until %s", e.Team, e.Until.Format(time.RFC1123))
+	if e.Reason != "" {
+		msg += ": " + e.Reason
+	}
+	return msg + ". Only users allowed to do emergency deploys may deploy now, using the emergency flag"
+}
+
+// FreezeWindow is a weekly recurring period in which deploys are frozen.
+// Start and End are in the "Fri 18:00" format and are interpreted in
+// Timezone, or UTC when it's empty. Windows ending before they start span
+// the turn of the week, like "Fri 18:00" to "Mon 08:00".
+type FreezeWindow struct {
+	Start    string
+	End      string
+	Timezone string `bson:",omitempty"`
+	Reason   string `bson:",omitempty"`
+}
+
+// parseWeekTime parses a "Fri 18:00" value, returning the minute of the week
+// it represents, starting on Sunday.
+func parseWeekTime(value string) (int, error) {
+	parts := strings.Fields(value)
+	if len(parts) != 2 {
+		return 0, errors.Errorf("invalid time %q, expected a weekday and a time, like \"Fri 18:00\"", value)
+	}
+	day := -1
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := d.String()
+		if strings.EqualFold(parts[0], name) || strings.EqualFold(parts[0], name[:3]) {
+			day = int(d)
+			break
+		}
+	}
+	if day < 0 {
+		return 0, errors.Errorf("invalid weekday %q", parts[0])
+	}
+	clock, err := time.Parse("15:04", parts[1])
+	if err != nil {
+		return 0, errors.Errorf("invalid time of day %q, expected HH:MM", parts[1])
+	}
+	return day*24*60 + clock.Hour()*60 + clock.Minute(), nil
+}
+
+func (w *FreezeWindow) location() (*time.Location, error) {
+	if w.Timezone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(w.Timezone)
+}
+
+func (w *FreezeWindow) validate() error {
+	start, err := parseWeekTime(w.Start)
+	if err != nil {
+		return err
+	}
+	end, err := parseWeekTime(w.End)
+	if err != nil {
+		return err
+	}
+	if start == end {
+		return errors.New("freeze window must not start and end at the same time")
+	}
+	if _, err = w.location(); err != nil {
+		return errors.Errorf("invalid timezone %q", w.Timezone)
+	}
+	return nil
+}
+
+// activeUntil returns whether the window includes the given time and, if it
+// does, the time in which the window ends.
+func (w *FreezeWindow) activeUntil(now time.Time) (time.Time, bool) {
+	start, err := parseWeekTime(w.Start)
+	if err != nil {
+		return time.Time{}, false
+	}
+	end, err := parseWeekTime(w.End)
+	if err != nil {
+		return time.Time{}, false
+	}
+	loc, err := w.location()
+	if err != nil {
+		return time.Time{}, false
+	}
+	now = now.In(loc)
+	current := int(now.Weekday())*24*60 + now.Hour()*60 + now.Minute()
+	var active bool
+	if start < end {
+		active = current >= start && current < end
+	} else {
+		active = current >= start || current < end
+	}
+	if !active {
+		return time.Time{}, false
+	}
+	remaining := (end - current + minutesInWeek) % minutesInWeek
+	until := now.Truncate(time.Minute).Add(time.Duration(remaining) * time.Minute)
+	return until, true
+}
+
+// DeployFreeze holds the freeze windows of a team, in which deploys of the
+// apps owned by the team are rejected, unless they're emergency deploys.
+type DeployFreeze struct {
+	Team    string `bson:"_id"`
+	Windows []FreezeWindow
+}
+
+func (f *DeployFreeze) validate() error {
+	_, err := auth.GetTeam(f.Team)
+	if err != nil {
+		return err
+	}
+	var verr tsuruErrors.ValidationError
+	for i := range f.Windows {
+		if err := f.Windows[i].validate(); err != nil {
+			verr.Add("windows."+strconv.Itoa(i), err.Error())
+		}
+	}
+	return verr.ToError()
+}
+
+// check returns a DeployFrozenError when the given time is inside any of the
+// freeze windows.
+func (f *DeployFreeze) check(now time.Time) error {
+	for _, w := range f.Windows {
+		if until, ok := w.activeUntil(now); ok {
+			return &DeployFrozenError{Team: f.Team, Until: until, Reason: w.Reason}
+		}
+	}
+	return nil
+}
+
+// SaveDeployFreeze creates or updates the freeze windows of a team.
+func SaveDeployFreeze(f DeployFreeze) error {
+	err := f.validate()
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.DeployFreezes().UpsertId(f.Team, f)
+	return err
+}
+
+// RemoveDeployFreeze removes the freeze windows of a team.
+func RemoveDeployFreeze(team string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.DeployFreezes().RemoveId(team)
+	if err == mgo.ErrNotFound {
+		return ErrDeployFreezeNotFound
+	}
+	return err
+}
+
+// GetDeployFreeze returns the freeze windows of a team.
+func GetDeployFreeze(team string) (*DeployFreeze, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var f DeployFreeze
+	err = conn.DeployFreezes().FindId(team).One(&f)
+	if err == mgo.ErrNotFound {
+		return nil, ErrDeployFreezeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// RenameDeployFreeze moves the freeze windows of a team to its new name.
+func RenameDeployFreeze(oldName, newName string) error {
+	f, err := GetDeployFreeze(oldName)
+	if err == ErrDeployFreezeNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	f.Team = newName
+	err = conn.DeployFreezes().Insert(f)
+	if err != nil {
+		return err
+	}
+	return conn.DeployFreezes().RemoveId(oldName)
+}
+
+// CheckDeployFreeze returns a DeployFrozenError when the team owning the app
+// has a freeze window including the given time.
+func (app *App) CheckDeployFreeze(now time.Time) error {
+	f, err := GetDeployFreeze(app.TeamOwner)
+	if err == ErrDeployFreezeNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return f.check(now)
+}
@@ -0,0 +1,115 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"time"
+
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	authTypes "github.com/tsuru/tsuru/types/auth"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestFreezeWindowActiveUntil(c *check.C) {
+	weekend := FreezeWindow{Start: "Fri 18:00", End: "Mon 08:00"}
+	friday := time.Date(2018, time.March, 9, 19, 30, 0, 0, time.UTC)
+	until, ok := weekend.activeUntil(friday)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(until, check.DeepEquals, time.Date(2018, time.March, 12, 8, 0, 0, 0, time.UTC))
+	sunday := time.Date(2018, time.March, 11, 23, 0, 0, 0, time.UTC)
+	until, ok = weekend.activeUntil(sunday)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(until, check.DeepEquals, time.Date(2018, time.March, 12, 8, 0, 0, 0, time.UTC))
+	monday := time.Date(2018, time.March, 12, 8, 0, 0, 0, time.UTC)
+	_, ok = weekend.activeUntil(monday)
+	c.Assert(ok, check.Equals, false)
+	lunch := FreezeWindow{Start: "wednesday 12:00", End: "Wed 13:00"}
+	_, ok = lunch.activeUntil(time.Date(2018, time.March, 14, 12, 30, 0, 0, time.UTC))
+	c.Assert(ok, check.Equals, true)
+	_, ok = lunch.activeUntil(time.Date(2018, time.March, 15, 12, 30, 0, 0, time.UTC))
+	c.Assert(ok, check.Equals, false)
+}
+
+func (s *S) TestFreezeWindowActiveUntilTimezone(c *check.C) {
+	w := FreezeWindow{Start: "Fri 18:00", End: "Mon 08:00", Timezone: "America/Sao_Paulo"}
+	_, ok := w.activeUntil(time.Date(2018, time.March, 9, 19, 30, 0, 0, time.UTC))
+	c.Assert(ok, check.Equals, false)
+	until, ok := w.activeUntil(time.Date(2018, time.March, 9, 22, 30, 0, 0, time.UTC))
+	c.Assert(ok, check.Equals, true)
+	c.Assert(until.UTC(), check.DeepEquals, time.Date(2018, time.March, 12, 11, 0, 0, 0, time.UTC))
+}
+
+func (s *S) TestSaveDeployFreeze(c *check.C) {
+	f := DeployFreeze{
+		Team:    s.team.Name,
+		Windows: []FreezeWindow{{Start: "Fri 18:00", End: "Mon 08:00", Reason: "weekend"}},
+	}
+	err := SaveDeployFreeze(f)
+	c.Assert(err, check.IsNil)
+	dbFreeze, err := GetDeployFreeze(s.team.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(*dbFreeze, check.DeepEquals, f)
+	err = RemoveDeployFreeze(s.team.Name)
+	c.Assert(err, check.IsNil)
+	_, err = GetDeployFreeze(s.team.Name)
+	c.Assert(err, check.Equals, ErrDeployFreezeNotFound)
+	err = RemoveDeployFreeze(s.team.Name)
+	c.Assert(err, check.Equals, ErrDeployFreezeNotFound)
+}
+
+func (s *S) TestSaveDeployFreezeInvalid(c *check.C) {
+	err := SaveDeployFreeze(DeployFreeze{Team: "unknown"})
+	c.Assert(err, check.Equals, authTypes.ErrTeamNotFound)
+	err = SaveDeployFreeze(DeployFreeze{
+		Team: s.team.Name,
+		Windows: []FreezeWindow{
+			{Start: "Fri 18:00", End: "Mon 8h"},
+			{Start: "Someday 18:00", End: "Mon 08:00"},
+			{Start: "Fri 18:00", End: "Fri 18:00"},
+			{Start: "Fri 18:00", End: "Mon 08:00", Timezone: "Nowhere/Land"},
+		},
+	})
+	c.Assert(err, check.FitsTypeOf, &tsuruErrors.ValidationError{})
+	verr := err.(*tsuruErrors.ValidationError)
+	c.Assert(verr.Fields, check.DeepEquals, []tsuruErrors.FieldError{
+		{Field: "windows.0", Message: `invalid time of day "8h", expected HH:MM`},
+		{Field: "windows.1", Message: `invalid weekday "Someday"`},
+		{Field: "windows.2", Message: `freeze window must not start and end at the same time`},
+		{Field: "windows.3", Message: `invalid timezone "Nowhere/Land"`},
+	})
+}
+
+func (s *S) TestRenameDeployFreeze(c *check.C) {
+	err := SaveDeployFreeze(DeployFreeze{Team: s.team.Name, Windows: []FreezeWindow{{Start: "Fri 18:00", End: "Mon 08:00"}}})
+	c.Assert(err, check.IsNil)
+	err = RenameDeployFreeze(s.team.Name, "newteam")
+	c.Assert(err, check.IsNil)
+	_, err = GetDeployFreeze(s.team.Name)
+	c.Assert(err, check.Equals, ErrDeployFreezeNotFound)
+	f, err := GetDeployFreeze("newteam")
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Windows, check.HasLen, 1)
+	err = RenameDeployFreeze("otherteam", "anotherteam")
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestCheckDeployFreeze(c *check.C) {
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	friday := time.Date(2018, time.March, 9, 19, 30, 0, 0, time.UTC)
+	c.Assert(a.CheckDeployFreeze(friday), check.IsNil)
+	err := SaveDeployFreeze(DeployFreeze{
+		Team:    s.team.Name,
+		Windows: []FreezeWindow{{Start: "Fri 18:00", End: "Mon 08:00", Reason: "weekend"}},
+	})
+	c.Assert(err, check.IsNil)
+	err = a.CheckDeployFreeze(friday)
+	c.Assert(err, check.DeepEquals, &DeployFrozenError{
+		Team:   s.team.Name,
+		Until:  time.Date(2018, time.March, 12, 8, 0, 0, 0, time.UTC),
+		Reason: "weekend",
+	})
+	c.Assert(err, check.ErrorMatches, `deploys of apps owned by team "tsuruteam" are frozen until Mon, 12 Mar 2018 08:00:00 UTC: weekend\. .*emergency flag`)
+	c.Assert(a.CheckDeployFreeze(friday.Add(-2*time.Hour)), check.IsNil)
+}
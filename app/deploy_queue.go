@@ -0,0 +1,114 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/queue"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const deployQueueName = "app-deploys"
+
+var (
+	ErrDeployCanceled         = errors.New("deploy canceled while waiting in queue")
+	ErrQueuedDeployNotFound   = errors.New("queued deploy not found")
+	ErrQueuedDeployNotWaiting = errors.New("deploy is already running, cancel its event instead")
+)
+
+// QueuedDeploy is a deploy waiting for its turn, or running, in the deploy
+// queue of an app.
+type QueuedDeploy struct {
+	ID         string
+	App        string
+	Owner      string
+	EnqueuedAt time.Time
+	Running    bool
+	Position   int
+}
+
+// deployQueue returns the queue used to serialize deploys of the same app.
+// The global limit of concurrent deploys comes from the
+// deploy:max-concurrent-builds config entry, it's unlimited by default.
+func deployQueue() *queue.SerialQueue {
+	maxActive, _ := config.GetInt("deploy:max-concurrent-builds")
+	return queue.NewSerialQueue(deployQueueName, queue.SerialQueueOpts{MaxActive: maxActive})
+}
+
+// JoinDeployQueue adds a new deploy to the end of the app's deploy queue. The
+// returned slot must be passed to WaitDeployTurn and, after the deploy
+// finishes, to LeaveDeployQueue.
+func JoinDeployQueue(appName, owner string) (*queue.Slot, error) {
+	return deployQueue().Join(appName, owner)
+}
+
+// WaitDeployTurn blocks until the deploy is the next one of its app and the
+// global limit of concurrent deploys allows it to run, reporting its
+// position in the queue to w. ErrDeployCanceled is returned if the deploy is
+// canceled while waiting.
+func WaitDeployTurn(ctx context.Context, slot *queue.Slot, w io.Writer) error {
+	err := deployQueue().Acquire(ctx, slot, func(position int) {
+		if position > 0 {
+			fmt.Fprintf(w, " ---> Deploy queued at position %d (queue id: %s), waiting...\n", position, slot.ID.Hex())
+		}
+	})
+	if err == queue.ErrSlotCanceled {
+		return ErrDeployCanceled
+	}
+	return err
+}
+
+// LeaveDeployQueue removes the deploy from the queue, allowing the next
+// deploy of the app to run.
+func LeaveDeployQueue(slot *queue.Slot) error {
+	return deployQueue().Release(slot)
+}
+
+// ListQueuedDeploys returns the deploys of an app in the deploy queue, the
+// running one first.
+func ListQueuedDeploys(appName string) ([]QueuedDeploy, error) {
+	slots, err := deployQueue().List(appName)
+	if err != nil {
+		return nil, err
+	}
+	deploys := make([]QueuedDeploy, len(slots))
+	position := 0
+	for i, slot := range slots {
+		deploys[i] = QueuedDeploy{
+			ID:         slot.ID.Hex(),
+			App:        slot.Key,
+			Owner:      slot.Owner,
+			EnqueuedAt: slot.EnqueuedAt,
+			Running:    slot.Active,
+		}
+		if !slot.Active {
+			position++
+			deploys[i].Position = position
+		}
+	}
+	return deploys, nil
+}
+
+// CancelQueuedDeploy cancels a deploy waiting in the app's deploy queue.
+// Running deploys are canceled through their events.
+func CancelQueuedDeploy(appName, id, owner string) error {
+	if !bson.IsObjectIdHex(id) {
+		return ErrQueuedDeployNotFound
+	}
+	err := deployQueue().Cancel(appName, bson.ObjectIdHex(id), owner)
+	switch err {
+	case queue.ErrSlotNotFound:
+		return ErrQueuedDeployNotFound
+	case queue.ErrSlotActive:
+		return ErrQueuedDeployNotWaiting
+	}
+	return err
+}
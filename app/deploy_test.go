@@ -13,12 +13,20 @@ import (
 	"strings"
 	"time"
 
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app/bind"
 	"github.com/tsuru/tsuru/app/image"
 	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/builder"
+	"github.com/tsuru/tsuru/builder/fake"
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/pool"
+	"github.com/tsuru/tsuru/scan"
+	"github.com/tsuru/tsuru/secret"
+	"github.com/tsuru/tsuru/service"
 	authTypes "github.com/tsuru/tsuru/types/auth"
 	"gopkg.in/check.v1"
 	"gopkg.in/mgo.v2/bson"
@@ -107,6 +115,28 @@ func (s *S) TestListAppDeploys(c *check.C) {
 	}
 }
 
+func (s *S) TestLastDeploys(c *check.C) {
+	insert := []DeployData{
+		{App: "g1", Timestamp: time.Now().Add(-3600 * time.Second)},
+		{App: "g1", Timestamp: time.Now()},
+		{App: "g3", Timestamp: time.Now()},
+	}
+	evts := insertDeploysAsEvents(insert, c)
+	evt, err := event.New(&event.Opts{
+		Target:   event.Target{Type: "app", Value: "g2"},
+		Kind:     permission.PermAppDeploy,
+		RawOwner: event.Owner{Type: event.OwnerTypeUser, Name: "someone"},
+		Allowed:  event.Allowed(permission.PermApp),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(errors.New("deploy failed"))
+	c.Assert(err, check.IsNil)
+	lastDeploys, err := LastDeploys([]string{"g1", "g2"})
+	c.Assert(err, check.IsNil)
+	c.Assert(lastDeploys, check.HasLen, 1)
+	c.Assert(lastDeploys["g1"].Unix(), check.Equals, evts[1].EndTime.Unix())
+}
+
 func (s *S) TestListAppDeploysWithImage(c *check.C) {
 	a := App{Name: "g1", TeamOwner: s.team.Name}
 	err := CreateApp(&a, s.user)
@@ -311,6 +341,54 @@ func (s *S) TestDeployAppUpload(c *check.C) {
 	c.Assert(updatedApp.UpdatePlatform, check.Equals, false)
 }
 
+func (s *S) TestDeployAppWithBuildArgs(c *check.C) {
+	a := App{
+		Name:      "some-app",
+		Platform:  "django",
+		Teams:     []string{s.team.Name},
+		TeamOwner: s.team.Name,
+		Router:    "fake",
+	}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	evt, err := event.New(&event.Opts{
+		Target:   event.Target{Type: "app", Value: a.Name},
+		Kind:     permission.PermAppDeploy,
+		RawOwner: event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+		Allowed:  event.Allowed(permission.PermApp),
+	})
+	c.Assert(err, check.IsNil)
+	buildArgs := map[string]string{"NPM_TOKEN": "secret"}
+	_, err = Deploy(DeployOptions{
+		App:          &a,
+		ArchiveURL:   "https://s3.amazonaws.com/smt/archive.tar.gz",
+		OutputStream: ioutil.Discard,
+		Event:        evt,
+		BuildArgs:    buildArgs,
+	})
+	c.Assert(err, check.IsNil)
+	b, err := builder.Get("fake")
+	c.Assert(err, check.IsNil)
+	c.Assert(b.(*fake.FakeBuilder).BuildArgs, check.DeepEquals, buildArgs)
+	updatedApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	_, ok := updatedApp.Env["NPM_TOKEN"]
+	c.Assert(ok, check.Equals, false)
+}
+
+func (s *S) TestParseBuildArgs(c *check.C) {
+	args, err := ParseBuildArgs(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(args, check.IsNil)
+	args, err = ParseBuildArgs([]string{"NPM_TOKEN=abc=123", "EMPTY="})
+	c.Assert(err, check.IsNil)
+	c.Assert(args, check.DeepEquals, map[string]string{"NPM_TOKEN": "abc=123", "EMPTY": ""})
+	_, err = ParseBuildArgs([]string{"NPM_TOKEN"})
+	c.Assert(err, check.ErrorMatches, `invalid build argument "NPM_TOKEN", it must be in the NAME=value format`)
+	_, err = ParseBuildArgs([]string{"1NVALID=x"})
+	c.Assert(err, check.ErrorMatches, `invalid build argument "1NVALID=x".*`)
+}
+
 func (s *S) TestDeployAppImage(c *check.C) {
 	a := App{
 		Name:      "some-app",
@@ -469,6 +547,130 @@ func (s *S) TestDeployAppIncrementDeployNumber(c *check.C) {
 	c.Assert(updatedApp.Deploys, check.Equals, uint(1))
 }
 
+func (s *S) TestDeployAppRecordsChanges(c *check.C) {
+	defer setSecretsKey()()
+	a := App{
+		Name:      "otherapp",
+		Platform:  "zend",
+		Teams:     []string{s.team.Name},
+		TeamOwner: s.team.Name,
+		Router:    "fake",
+		Env: map[string]bind.EnvVar{
+			"A": {Name: "A", Value: "1"},
+			"B": {Name: "B", Value: "2"},
+		},
+	}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	deploy := func(commit string) *DeployData {
+		evt, err := event.New(&event.Opts{
+			Target:   event.Target{Type: "app", Value: a.Name},
+			Kind:     permission.PermAppDeploy,
+			RawOwner: event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+			Allowed:  event.Allowed(permission.PermApp),
+		})
+		c.Assert(err, check.IsNil)
+		imageID, err := Deploy(DeployOptions{
+			App:          &a,
+			Image:        "myimage",
+			Commit:       commit,
+			OutputStream: &bytes.Buffer{},
+			Event:        evt,
+		})
+		c.Assert(err, check.IsNil)
+		err = evt.DoneCustomData(nil, map[string]string{"image": imageID})
+		c.Assert(err, check.IsNil)
+		data, err := GetDeploy(evt.UniqueID.Hex())
+		c.Assert(err, check.IsNil)
+		return data
+	}
+	first := deploy("1ee1f1084927b3a5db59c9033bc5c4abefb7b93c")
+	c.Assert(first.Changes, check.NotNil)
+	c.Assert(first.Changes.PreviousCommit, check.Equals, "")
+	c.Assert(strings.Join(first.Changes.EnvsAdded, ","), check.Matches, "A,B(,TSURU_.*)?")
+	a.Env["A"] = bind.EnvVar{Name: "A", Value: "changed"}
+	a.Env["C"] = bind.EnvVar{Name: "C", Value: "3"}
+	delete(a.Env, "B")
+	err = s.conn.ServiceInstances().Insert(service.ServiceInstance{Name: "mydb", ServiceName: "mysql", Apps: []string{a.Name}})
+	c.Assert(err, check.IsNil)
+	second := deploy("2bc1f1084927b3a5db59c9033bc5c4abefb7b93c")
+	c.Assert(second.Changes, check.DeepEquals, &DeployChanges{
+		PreviousCommit: "1ee1f1084927b3a5db59c9033bc5c4abefb7b93c",
+		PreviousImage:  first.Image,
+		EnvsAdded:      []string{"C"},
+		EnvsChanged:    []string{"A"},
+		EnvsRemoved:    []string{"B"},
+		ServicesBound:  []string{"mysql/mydb"},
+	})
+}
+
+func (s *S) TestDeployStateChangesSince(c *check.C) {
+	previous := &deployState{
+		Commit:   "abc",
+		Image:    "app:v1",
+		Envs:     map[string]string{"A": "hmac-sha256:1", "B": "hmac-sha256:2", "C": "", "D": "5e884898da28"},
+		Services: []string{"mysql/db", "redis/cache"},
+	}
+	current := &deployState{
+		Commit:   "def",
+		Image:    "app:v2",
+		Envs:     map[string]string{"A": "hmac-sha256:1", "B": "hmac-sha256:3", "C": "hmac-sha256:4", "D": "hmac-sha256:5"},
+		Services: []string{"redis/cache", "mongodb/docs"},
+	}
+	c.Assert(current.changesSince(previous), check.DeepEquals, &DeployChanges{
+		PreviousCommit:  "abc",
+		PreviousImage:   "app:v1",
+		EnvsChanged:     []string{"B"},
+		ServicesBound:   []string{"mongodb/docs"},
+		ServicesUnbound: []string{"mysql/db"},
+	})
+}
+
+func (s *S) TestNewDeployStateFingerprints(c *check.C) {
+	a := App{
+		Name:      "otherapp",
+		Platform:  "zend",
+		TeamOwner: s.team.Name,
+		Env: map[string]bind.EnvVar{
+			"A": {Name: "A", Value: "1"},
+		},
+	}
+	state, err := newDeployState(&a, "abc", "app:v1")
+	c.Assert(err, check.IsNil)
+	c.Assert(state.Envs, check.DeepEquals, map[string]string{"A": ""})
+	defer setSecretsKey()()
+	state, err = newDeployState(&a, "abc", "app:v1")
+	c.Assert(err, check.IsNil)
+	fp, err := secret.Fingerprint("1")
+	c.Assert(err, check.IsNil)
+	c.Assert(state.Envs, check.DeepEquals, map[string]string{"A": fp})
+}
+
+func (s *S) TestRemoveDeployEnvHashes(c *check.C) {
+	evt, err := event.New(&event.Opts{
+		Target:   event.Target{Type: "app", Value: "myapp"},
+		Kind:     permission.PermAppDeploy,
+		RawOwner: event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+		Allowed:  event.Allowed(permission.PermApp),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.SetOtherCustomDataKey("state", deployState{
+		Commit: "abc",
+		Envs:   map[string]string{"A": "5e884898da28", "B": "hmac-sha256:1", "C": ""},
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	err = RemoveDeployEnvHashes()
+	c.Assert(err, check.IsNil)
+	state, err := lastDeployState("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(state, check.DeepEquals, &deployState{
+		Commit: "abc",
+		Envs:   map[string]string{"A": "", "B": "hmac-sha256:1", "C": ""},
+	})
+}
+
 func (s *S) TestDeployAppSaveDeployData(c *check.C) {
 	a := App{
 		Name:      "otherapp",
@@ -784,6 +986,90 @@ func (s *S) TestDeployToProvisionerImage(c *check.C) {
 	c.Assert(evt.Log, check.Equals, "Builder deploy called")
 }
 
+type fakeScanner struct {
+	vulns []scan.Vulnerability
+}
+
+func (f *fakeScanner) Scan(image string) ([]scan.Vulnerability, error) {
+	return f.vulns, nil
+}
+
+func (s *S) TestDeployToProvisionerScanBlocked(c *check.C) {
+	scan.Register("fake", func(prefix string) (scan.Scanner, error) {
+		return &fakeScanner{vulns: []scan.Vulnerability{
+			{ID: "CVE-2018-1", Package: "openssl", InstalledVersion: "1.0.1", Severity: "CRITICAL"},
+		}}, nil
+	})
+	config.Set("scan:driver", "fake")
+	defer config.Unset("scan")
+	policy := scan.PolicyBlock
+	err := pool.PoolUpdate(s.Pool, pool.UpdatePoolOptions{ScanPolicy: &policy})
+	c.Assert(err, check.IsNil)
+	a := App{
+		Name:      "some-app",
+		Platform:  "django",
+		Teams:     []string{s.team.Name},
+		TeamOwner: s.team.Name,
+	}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	opts := DeployOptions{App: &a, Image: "my-image-x"}
+	evt, err := event.New(&event.Opts{
+		Target:   event.Target{Type: "app", Value: a.Name},
+		Kind:     permission.PermAppDeploy,
+		RawOwner: event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+		Allowed:  event.Allowed(permission.PermApp),
+	})
+	c.Assert(err, check.IsNil)
+	_, err = deployToProvisioner(&opts, evt)
+	c.Assert(err, check.FitsTypeOf, &scan.ErrBlocked{})
+	err = evt.Done(err)
+	c.Assert(err, check.IsNil)
+	c.Assert(strings.Contains(evt.Log, "Builder deploy called"), check.Equals, false)
+	c.Assert(strings.Contains(evt.Log, "CVE-2018-1 in openssl 1.0.1"), check.Equals, true)
+	deploy, err := GetDeploy(evt.UniqueID.Hex())
+	c.Assert(err, check.IsNil)
+	c.Assert(deploy.Scan, check.NotNil)
+	c.Assert(deploy.Scan.Blocked, check.Equals, true)
+	c.Assert(deploy.Scan.Summary, check.DeepEquals, map[string]int{"CRITICAL": 1})
+}
+
+func (s *S) TestDeployToProvisionerScanWarn(c *check.C) {
+	scan.Register("fake", func(prefix string) (scan.Scanner, error) {
+		return &fakeScanner{vulns: []scan.Vulnerability{
+			{ID: "CVE-2018-1", Package: "openssl", InstalledVersion: "1.0.1", Severity: "CRITICAL"},
+		}}, nil
+	})
+	config.Set("scan:driver", "fake")
+	defer config.Unset("scan")
+	a := App{
+		Name:      "some-app",
+		Platform:  "django",
+		Teams:     []string{s.team.Name},
+		TeamOwner: s.team.Name,
+	}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	opts := DeployOptions{App: &a, Image: "my-image-x"}
+	evt, err := event.New(&event.Opts{
+		Target:   event.Target{Type: "app", Value: a.Name},
+		Kind:     permission.PermAppDeploy,
+		RawOwner: event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+		Allowed:  event.Allowed(permission.PermApp),
+	})
+	c.Assert(err, check.IsNil)
+	_, err = deployToProvisioner(&opts, evt)
+	c.Assert(err, check.IsNil)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(strings.Contains(evt.Log, "WARNING: image has 1 critical vulnerabilities"), check.Equals, true)
+	c.Assert(strings.HasSuffix(evt.Log, "Builder deploy called"), check.Equals, true)
+	deploy, err := GetDeploy(evt.UniqueID.Hex())
+	c.Assert(err, check.IsNil)
+	c.Assert(deploy.Scan.Policy, check.Equals, scan.PolicyWarn)
+	c.Assert(deploy.Scan.Blocked, check.Equals, false)
+}
+
 func (s *S) TestRollbackWithNameImage(c *check.C) {
 	a := App{
 		Name:      "otherapp",
@@ -0,0 +1,81 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/db"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const defaultDeployWebhookBranch = "master"
+
+var ErrDeployWebhookNotFound = errors.New("deploy webhook not configured for this app")
+
+// DeployWebhook holds the settings of deploys triggered by push
+// notifications sent by git providers. Pushes are only deployed when they
+// are signed with Secret and target Branch.
+type DeployWebhook struct {
+	Branch string
+	Secret string
+}
+
+// SetDeployWebhook enables deploys triggered by git provider webhooks in the
+// app. An empty branch defaults to master and a random secret is generated
+// when none is given.
+func (app *App) SetDeployWebhook(hook DeployWebhook) (*DeployWebhook, error) {
+	if hook.Branch == "" {
+		hook.Branch = defaultDeployWebhookBranch
+	}
+	if hook.Secret == "" {
+		secret, err := generateWebhookSecret()
+		if err != nil {
+			return nil, err
+		}
+		hook.Secret = secret
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	err = conn.Apps().Update(bson.M{"name": app.Name}, bson.M{"$set": bson.M{"deploywebhook": hook}})
+	if err != nil {
+		return nil, err
+	}
+	app.DeployWebhook = &hook
+	return &hook, nil
+}
+
+// RemoveDeployWebhook disables deploys triggered by git provider webhooks in
+// the app.
+func (app *App) RemoveDeployWebhook() error {
+	if app.DeployWebhook == nil {
+		return ErrDeployWebhookNotFound
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Apps().Update(bson.M{"name": app.Name}, bson.M{"$unset": bson.M{"deploywebhook": ""}})
+	if err != nil {
+		return err
+	}
+	app.DeployWebhook = nil
+	return nil
+}
+
+func generateWebhookSecret() (string, error) {
+	var data [20]byte
+	_, err := rand.Read(data[:])
+	if err != nil {
+		return "", errors.Wrap(err, "unable to generate webhook secret")
+	}
+	return hex.EncodeToString(data[:]), nil
+}
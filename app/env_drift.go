@@ -0,0 +1,285 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api/shutdown"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	envDriftRunID = "env-drift"
+
+	envProbeMarker = "----tsuru-env-probe:"
+	envProbeCmd    = "echo " + envProbeMarker + "$HOSTNAME; env"
+)
+
+// UnitEnvDrift describes the differences between the environment stored for
+// an app and the environment a unit is actually running with, which happens
+// when binds or env changes are applied while a restart fails. Only variable
+// names are reported, never their values.
+type UnitEnvDrift struct {
+	App      string
+	Unit     string   `json:",omitempty"`
+	Missing  []string `json:",omitempty"`
+	Outdated []string `json:",omitempty"`
+	Error    string   `json:",omitempty"`
+}
+
+// FindEnvDrift probes the units of the given apps, or of all apps when none
+// is given, returning the units whose environment doesn't match the app.
+// Apps without available units are skipped.
+func FindEnvDrift(appNames ...string) ([]UnitEnvDrift, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var query bson.M
+	if len(appNames) > 0 {
+		query = bson.M{"name": bson.M{"$in": appNames}}
+	}
+	var apps []App
+	err = conn.Apps().Find(query).All(&apps)
+	if err != nil {
+		return nil, err
+	}
+	var drifts []UnitEnvDrift
+	for i := range apps {
+		if !apps[i].available() {
+			continue
+		}
+		appDrifts, err := apps[i].EnvDrift()
+		if err != nil {
+			drifts = append(drifts, UnitEnvDrift{App: apps[i].Name, Error: err.Error()})
+			continue
+		}
+		drifts = append(drifts, appDrifts...)
+	}
+	return drifts, nil
+}
+
+// EnvDrift runs a probe in the units of the app, comparing their environment
+// with the one stored for the app.
+func (app *App) EnvDrift() ([]UnitEnvDrift, error) {
+	units, err := app.Units()
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	err = app.run(envProbeCmd, &out, provision.RunArgs{})
+	if err != nil {
+		return nil, err
+	}
+	unitEnvs := parseEnvProbe(&out)
+	expected := app.Envs()
+	if interpolated, err := provision.InterpolateEnvs(expected); err == nil {
+		expected = interpolated
+	}
+	var drifts []UnitEnvDrift
+	for _, u := range units {
+		if !u.Available() {
+			continue
+		}
+		envs, ok := findUnitEnvs(unitEnvs, u.ID)
+		if !ok {
+			drifts = append(drifts, UnitEnvDrift{App: app.Name, Unit: u.ID, Error: "unit not reached by the env probe"})
+			continue
+		}
+		drift := UnitEnvDrift{App: app.Name, Unit: u.ID}
+		for name, envVar := range expected {
+			value, ok := envs[name]
+			switch {
+			case !ok:
+				drift.Missing = append(drift.Missing, name)
+			case strings.Contains(envVar.Value, "\n"):
+				// multi-line values can't be recovered from the env output
+			case !sameEnvValue(name, envVar.Value, value):
+				drift.Outdated = append(drift.Outdated, name)
+			}
+		}
+		if len(drift.Missing) == 0 && len(drift.Outdated) == 0 {
+			continue
+		}
+		sort.Strings(drift.Missing)
+		sort.Strings(drift.Outdated)
+		drifts = append(drifts, drift)
+	}
+	return drifts, nil
+}
+
+// parseEnvProbe parses the output of the env probe, returning the variables
+// of each unit indexed by the unit hostname.
+func parseEnvProbe(out *bytes.Buffer) map[string]map[string]string {
+	result := map[string]map[string]string{}
+	var current map[string]string
+	scanner := bufio.NewScanner(out)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, envProbeMarker) {
+			current = map[string]string{}
+			result[strings.TrimPrefix(line, envProbeMarker)] = current
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if current == nil || len(parts) != 2 {
+			continue
+		}
+		current[parts[0]] = parts[1]
+	}
+	return result
+}
+
+// findUnitEnvs finds the variables of a unit, whose hostname may be a prefix
+// of the unit ID, as in Docker containers.
+func findUnitEnvs(unitEnvs map[string]map[string]string, unitID string) (map[string]string, bool) {
+	if envs, ok := unitEnvs[unitID]; ok {
+		return envs, true
+	}
+	for hostname, envs := range unitEnvs {
+		if hostname != "" && strings.HasPrefix(unitID, hostname) {
+			return envs, true
+		}
+	}
+	return nil, false
+}
+
+func sameEnvValue(name, expected, actual string) bool {
+	if expected == actual {
+		return true
+	}
+	if name != TsuruServicesEnvVar {
+		return false
+	}
+	var expectedData, actualData interface{}
+	if json.Unmarshal([]byte(expected), &expectedData) != nil || json.Unmarshal([]byte(actual), &actualData) != nil {
+		return false
+	}
+	return reflect.DeepEqual(expectedData, actualData)
+}
+
+// InitializeEnvDriftCheck starts the task checking for env drift in the
+// units of all apps, when env-drift:interval is configured. Apps with drift
+// are logged and get an event with the drift found.
+func InitializeEnvDriftCheck() error {
+	interval, _ := config.GetDuration("env-drift:interval")
+	if interval <= 0 {
+		return nil
+	}
+	d := &envDriftCheck{
+		interval: interval,
+		shutdown: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go d.loop()
+	shutdown.Register(d)
+	return nil
+}
+
+type envDriftCheck struct {
+	interval time.Duration
+	shutdown chan struct{}
+	done     chan struct{}
+}
+
+func (d *envDriftCheck) loop() {
+	defer close(d.done)
+	for {
+		claimed, err := claimEnvDriftRun(time.Now().UTC(), d.interval)
+		if err != nil {
+			log.Errorf("[env-drift] error claiming run: %s", err)
+		}
+		if claimed {
+			err = checkEnvDrift()
+			if err != nil {
+				log.Errorf("[env-drift] error checking env drift: %s", err)
+			}
+		}
+		select {
+		case <-time.After(d.interval):
+		case <-d.shutdown:
+			return
+		}
+	}
+}
+
+func (d *envDriftCheck) Shutdown(ctx context.Context) error {
+	close(d.shutdown)
+	select {
+	case <-d.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
+
+func (d *envDriftCheck) String() string {
+	return "env drift check"
+}
+
+// claimEnvDriftRun sets the time of the next env drift check, so only one of
+// the tsuru API instances probes the units in each interval.
+func claimEnvDriftRun(now time.Time, interval time.Duration) (bool, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	return claimRun(conn.EnvDriftRuns(), envDriftRunID, now, interval)
+}
+
+func checkEnvDrift() error {
+	drifts, err := FindEnvDrift()
+	if err != nil {
+		return err
+	}
+	byApp := map[string][]UnitEnvDrift{}
+	var appNames []string
+	for _, drift := range drifts {
+		if _, ok := byApp[drift.App]; !ok {
+			appNames = append(appNames, drift.App)
+		}
+		byApp[drift.App] = append(byApp[drift.App], drift)
+	}
+	for _, appName := range appNames {
+		log.Errorf("[env-drift] app %q has %d units with env drift", appName, len(byApp[appName]))
+		err = recordEnvDrift(appName, byApp[appName])
+		if err != nil {
+			log.Errorf("[env-drift] unable to record event for app %q: %s", appName, err)
+		}
+	}
+	return nil
+}
+
+func recordEnvDrift(appName string, drifts []UnitEnvDrift) error {
+	evt, err := event.NewInternal(&event.Opts{
+		Target:       event.Target{Type: event.TargetTypeApp, Value: appName},
+		InternalKind: "env-drift",
+		CustomData:   drifts,
+		DisableLock:  true,
+		Allowed: event.Allowed(permission.PermAppReadEvents,
+			permission.Context(permission.CtxApp, appName)),
+	})
+	if err != nil {
+		return err
+	}
+	return evt.Done(nil)
+}
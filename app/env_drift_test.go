@@ -0,0 +1,120 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/tsuru/tsuru/app/bind"
+	"gopkg.in/check.v1"
+)
+
+func envProbeOutput(hostname string, envs map[string]bind.EnvVar) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s%s\n", envProbeMarker, hostname)
+	names := make([]string, 0, len(envs))
+	for name := range envs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&buf, "%s=%s\n", name, envs[name].Value)
+	}
+	return buf.Bytes()
+}
+
+func (s *S) TestAppEnvDrift(c *check.C) {
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetEnvs(bind.SetEnvArgs{
+		Envs:          []bind.EnvVar{{Name: "DATABASE_HOST", Value: "10.0.0.1"}, {Name: "DEBUG", Value: "1"}},
+		ShouldRestart: false,
+	})
+	c.Assert(err, check.IsNil)
+	s.provisioner.AddUnits(&a, 2, "web", nil)
+	envs := a.Envs()
+	s.provisioner.PrepareOutput(envProbeOutput("myapp-0", envs))
+	stale := make(map[string]bind.EnvVar, len(envs))
+	for name, env := range envs {
+		stale[name] = env
+	}
+	delete(stale, "DEBUG")
+	stale["DATABASE_HOST"] = bind.EnvVar{Name: "DATABASE_HOST", Value: "10.0.0.2"}
+	s.provisioner.PrepareOutput(envProbeOutput("myapp-1", stale))
+	drifts, err := a.EnvDrift()
+	c.Assert(err, check.IsNil)
+	c.Assert(drifts, check.DeepEquals, []UnitEnvDrift{
+		{App: "myapp", Unit: "myapp-1", Missing: []string{"DEBUG"}, Outdated: []string{"DATABASE_HOST"}},
+	})
+	cmds := s.provisioner.GetCmds(envProbeCmd, &a)
+	c.Assert(cmds, check.HasLen, 1)
+}
+
+func (s *S) TestAppEnvDriftUnitNotReached(c *check.C) {
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	s.provisioner.AddUnits(&a, 1, "web", nil)
+	s.provisioner.PrepareOutput([]byte("sh: env: not found\n"))
+	drifts, err := a.EnvDrift()
+	c.Assert(err, check.IsNil)
+	c.Assert(drifts, check.DeepEquals, []UnitEnvDrift{
+		{App: "myapp", Unit: "myapp-0", Error: "unit not reached by the env probe"},
+	})
+}
+
+func (s *S) TestFindEnvDriftSkipsAppsWithoutUnits(c *check.C) {
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	drifts, err := FindEnvDrift()
+	c.Assert(err, check.IsNil)
+	c.Assert(drifts, check.HasLen, 0)
+	c.Assert(s.provisioner.GetCmds(envProbeCmd, &a), check.HasLen, 0)
+}
+
+func (s *S) TestParseEnvProbe(c *check.C) {
+	out := bytes.NewBufferString("garbage\n" + envProbeMarker + "abc123\nA=1\nB=x=y\n" + envProbeMarker + "def456\nA=2\ninvalid line\n")
+	c.Assert(parseEnvProbe(out), check.DeepEquals, map[string]map[string]string{
+		"abc123": {"A": "1", "B": "x=y"},
+		"def456": {"A": "2"},
+	})
+}
+
+func (s *S) TestFindUnitEnvs(c *check.C) {
+	unitEnvs := map[string]map[string]string{
+		"abc123": {"A": "1"},
+		"":       {"A": "2"},
+	}
+	envs, ok := findUnitEnvs(unitEnvs, "abc123")
+	c.Assert(ok, check.Equals, true)
+	c.Assert(envs, check.DeepEquals, map[string]string{"A": "1"})
+	envs, ok = findUnitEnvs(unitEnvs, "abc123456789")
+	c.Assert(ok, check.Equals, true)
+	c.Assert(envs, check.DeepEquals, map[string]string{"A": "1"})
+	_, ok = findUnitEnvs(unitEnvs, "xyz")
+	c.Assert(ok, check.Equals, false)
+}
+
+func (s *S) TestSameEnvValue(c *check.C) {
+	c.Assert(sameEnvValue("A", "1", "1"), check.Equals, true)
+	c.Assert(sameEnvValue("A", "1", "2"), check.Equals, false)
+	c.Assert(sameEnvValue(TsuruServicesEnvVar, `{"a":[1],"b":[2]}`, `{"b":[2],"a":[1]}`), check.Equals, true)
+	c.Assert(sameEnvValue(TsuruServicesEnvVar, `{"a":[1]}`, `{"a":[2]}`), check.Equals, false)
+}
+
+func (s *S) TestClaimEnvDriftRun(c *check.C) {
+	now := time.Now().UTC()
+	claimed, err := claimEnvDriftRun(now, time.Hour)
+	c.Assert(err, check.IsNil)
+	c.Assert(claimed, check.Equals, true)
+	claimed, err = claimEnvDriftRun(now.Add(time.Minute), time.Hour)
+	c.Assert(err, check.IsNil)
+	c.Assert(claimed, check.Equals, false)
+}
@@ -0,0 +1,207 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/app/image"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/service"
+	appTypes "github.com/tsuru/tsuru/types/app"
+)
+
+// ExportVersion is the version of the format written by App.Export.
+const ExportVersion = 1
+
+// AppExport is a self contained description of an app, used to recreate it
+// in another tsuru installation. Only the values of public environment
+// variables are exported, private variables and secrets are listed by name,
+// and service instances are not exported, only the binds of the app.
+type AppExport struct {
+	Version     int
+	Name        string
+	Description string
+	Platform    string
+	Plan        string
+	Pool        string
+	TeamOwner   string
+	Teams       []string
+	Router      string
+	RouterOpts  map[string]string
+	Tags        []string
+	CName       []string
+	Env         []bind.EnvVar
+	PrivateEnvs []string
+	Secrets     []string
+	Binds       []ExportedBind
+	Image       string
+}
+
+// ExportedBind is a bind between the exported app and a service instance.
+type ExportedBind struct {
+	Service  string
+	Instance string
+	Plan     string
+}
+
+// ImportOptions overrides settings of an exported app when importing it,
+// as pools and teams usually differ between installations.
+type ImportOptions struct {
+	Name      string
+	Pool      string
+	TeamOwner string
+	Plan      string
+}
+
+// ImportResult describes what was recreated from an exported app. Binds to
+// instances that don't exist in this installation are listed in
+// MissingBinds, PrivateEnvs must be set again by the user and Image must be
+// deployed to the app to run it.
+type ImportResult struct {
+	App          string
+	Envs         int
+	PrivateEnvs  []string
+	Binds        []string
+	MissingBinds []string
+	Image        string
+}
+
+// Export returns the description of the app used by ImportApp.
+func (app *App) Export() (*AppExport, error) {
+	exp := &AppExport{
+		Version:     ExportVersion,
+		Name:        app.Name,
+		Description: app.Description,
+		Platform:    app.Platform,
+		Plan:        app.Plan.Name,
+		Pool:        app.Pool,
+		TeamOwner:   app.TeamOwner,
+		Teams:       app.Teams,
+		Router:      app.Router,
+		RouterOpts:  app.RouterOpts,
+		Tags:        app.Tags,
+		CName:       app.CName,
+	}
+	for _, env := range app.Env {
+		if isInternalEnv(env.Name) {
+			continue
+		}
+		if env.Public {
+			exp.Env = append(exp.Env, env)
+		} else {
+			exp.PrivateEnvs = append(exp.PrivateEnvs, env.Name)
+		}
+	}
+	sort.Slice(exp.Env, func(i, j int) bool { return exp.Env[i].Name < exp.Env[j].Name })
+	sort.Strings(exp.PrivateEnvs)
+	for name := range app.Secrets {
+		exp.Secrets = append(exp.Secrets, name)
+	}
+	sort.Strings(exp.Secrets)
+	instances, err := service.GetServiceInstancesBoundToApp(app.Name)
+	if err != nil {
+		return nil, err
+	}
+	for _, si := range instances {
+		exp.Binds = append(exp.Binds, ExportedBind{Service: si.ServiceName, Instance: si.Name, Plan: si.PlanName})
+	}
+	if app.Deploys == 0 {
+		return exp, nil
+	}
+	exp.Image, err = image.AppCurrentImageName(app.Name)
+	if err != nil && err != image.ErrNoImagesAvailable {
+		return nil, errors.Wrap(err, "unable to get the current image of the app")
+	}
+	return exp, nil
+}
+
+// isInternalEnv reports whether the variable is managed by tsuru, like the
+// app token, and must not be carried to another installation.
+func isInternalEnv(name string) bool {
+	return strings.HasPrefix(name, "TSURU_")
+}
+
+// apply returns the app described by the export, with the settings in opts
+// taking precedence over the exported ones.
+func (opts ImportOptions) apply(exp *AppExport) App {
+	a := App{
+		Name:        exp.Name,
+		Description: exp.Description,
+		Platform:    exp.Platform,
+		Plan:        appTypes.Plan{Name: exp.Plan},
+		Pool:        exp.Pool,
+		TeamOwner:   exp.TeamOwner,
+		Router:      exp.Router,
+		RouterOpts:  exp.RouterOpts,
+		Tags:        exp.Tags,
+	}
+	if opts.Name != "" {
+		a.Name = opts.Name
+	}
+	if opts.Pool != "" {
+		a.Pool = opts.Pool
+	}
+	if opts.TeamOwner != "" {
+		a.TeamOwner = opts.TeamOwner
+	}
+	if opts.Plan != "" {
+		a.Plan.Name = opts.Plan
+	}
+	return a
+}
+
+// ImportApp creates the app described by the export, sets its environment
+// variables and binds it to the exported instances found in this
+// installation. The app isn't deployed, CNames aren't added and teams other
+// than the team owner aren't granted access, as they may belong to other
+// apps or not exist in this installation. Resources created before a
+// failure are kept and listed in the result.
+func ImportApp(exp *AppExport, opts ImportOptions, user *auth.User, w io.Writer) (*ImportResult, error) {
+	if exp.Version != ExportVersion {
+		return nil, errors.Errorf("unsupported export version %d", exp.Version)
+	}
+	a := opts.apply(exp)
+	err := CreateApp(&a, user)
+	if err != nil {
+		return nil, err
+	}
+	result := &ImportResult{App: a.Name, PrivateEnvs: exp.PrivateEnvs, Image: exp.Image}
+	var envs []bind.EnvVar
+	for _, env := range exp.Env {
+		if env.Public && !isInternalEnv(env.Name) {
+			envs = append(envs, env)
+		}
+	}
+	if len(envs) > 0 {
+		err = a.SetEnvs(bind.SetEnvArgs{Envs: envs, Writer: w})
+		if err != nil {
+			return result, errors.Wrap(err, "unable to set environment variables")
+		}
+		result.Envs = len(envs)
+	}
+	for _, b := range exp.Binds {
+		name := fmt.Sprintf("%s/%s", b.Service, b.Instance)
+		si, err := service.GetServiceInstance(b.Service, b.Instance)
+		if err == service.ErrServiceInstanceNotFound {
+			result.MissingBinds = append(result.MissingBinds, name)
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+		err = si.BindApp(&a, false, w)
+		if err != nil {
+			return result, errors.Wrapf(err, "unable to bind instance %q", name)
+		}
+		result.Binds = append(result.Binds, name)
+	}
+	return result, nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/service"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAppExport(c *check.C) {
+	a := App{
+		Name:      "myapp",
+		Platform:  "python",
+		TeamOwner: s.team.Name,
+		Tags:      []string{"web"},
+		Env: map[string]bind.EnvVar{
+			"LOG_LEVEL":       {Name: "LOG_LEVEL", Value: "info", Public: true},
+			"API_HOST":        {Name: "API_HOST", Value: "api.example.com", Public: true},
+			"DB_PASS":         {Name: "DB_PASS", Value: "s3cr3t", Public: false},
+			"TSURU_APP_TOKEN": {Name: "TSURU_APP_TOKEN", Value: "abc123", Public: false},
+			"TSURU_APPNAME":   {Name: "TSURU_APPNAME", Value: "myapp", Public: true},
+		},
+	}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	si := service.ServiceInstance{Name: "mydb", ServiceName: "mysql", PlanName: "small", Apps: []string{a.Name}}
+	err = s.conn.ServiceInstances().Insert(si)
+	c.Assert(err, check.IsNil)
+	exp, err := a.Export()
+	c.Assert(err, check.IsNil)
+	c.Assert(exp.Version, check.Equals, ExportVersion)
+	c.Assert(exp.Name, check.Equals, "myapp")
+	c.Assert(exp.Platform, check.Equals, "python")
+	c.Assert(exp.TeamOwner, check.Equals, s.team.Name)
+	c.Assert(exp.Tags, check.DeepEquals, []string{"web"})
+	c.Assert(exp.Env, check.DeepEquals, []bind.EnvVar{
+		{Name: "API_HOST", Value: "api.example.com", Public: true},
+		{Name: "LOG_LEVEL", Value: "info", Public: true},
+	})
+	c.Assert(exp.PrivateEnvs, check.DeepEquals, []string{"DB_PASS"})
+	c.Assert(exp.Binds, check.DeepEquals, []ExportedBind{{Service: "mysql", Instance: "mydb", Plan: "small"}})
+	c.Assert(exp.Image, check.Equals, "")
+}
+
+func (s *S) TestImportApp(c *check.C) {
+	exp := &AppExport{
+		Version:   ExportVersion,
+		Name:      "myapp",
+		Platform:  "python",
+		TeamOwner: "team-from-other-installation",
+		Env: []bind.EnvVar{
+			{Name: "LOG_LEVEL", Value: "info", Public: true},
+			{Name: "DB_PASS", Value: "s3cr3t", Public: false},
+			{Name: "TSURU_APPNAME", Value: "myapp", Public: true},
+		},
+		PrivateEnvs: []string{"API_KEY"},
+		Binds:       []ExportedBind{{Service: "mysql", Instance: "mydb"}},
+		Image:       "registry.example.com/tsuru/app-myapp:v3",
+	}
+	result, err := ImportApp(exp, ImportOptions{Name: "newapp", TeamOwner: s.team.Name}, s.user, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, &ImportResult{
+		App:          "newapp",
+		Envs:         1,
+		PrivateEnvs:  []string{"API_KEY"},
+		MissingBinds: []string{"mysql/mydb"},
+		Image:        "registry.example.com/tsuru/app-myapp:v3",
+	})
+	a, err := GetByName("newapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(a.Platform, check.Equals, "python")
+	c.Assert(a.TeamOwner, check.Equals, s.team.Name)
+	c.Assert(a.Env["LOG_LEVEL"].Value, check.Equals, "info")
+	_, ok := a.Env["DB_PASS"]
+	c.Assert(ok, check.Equals, false)
+	c.Assert(a.Env["TSURU_APPNAME"].Value, check.Equals, "newapp")
+}
+
+func (s *S) TestImportAppUnsupportedVersion(c *check.C) {
+	_, err := ImportApp(&AppExport{Version: ExportVersion + 1, Name: "myapp"}, ImportOptions{}, s.user, nil)
+	c.Assert(err, check.ErrorMatches, "unsupported export version 2")
+	_, err = GetByName("myapp")
+	c.Assert(err, check.Equals, ErrAppNotFound)
+}
@@ -0,0 +1,143 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package job implements scheduled jobs for apps. Jobs run a command in an
+// isolated unit of the app, using the app's current image, at the times
+// defined by a cron expression or when manually triggered.
+package job
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/db"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/validation"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+)
+
+var (
+	ErrJobNotFound      = errors.New("job not found")
+	ErrJobAlreadyExists = errors.New("job already exists")
+	ErrRunNotFound      = errors.New("job run not found")
+)
+
+// Job is a command executed periodically in an app.
+type Job struct {
+	Name      string
+	App       string
+	Schedule  string
+	Command   string
+	NextRun   time.Time
+	CreatedAt time.Time
+}
+
+func (j *Job) Validate() error {
+	if !validation.ValidateName(j.Name) {
+		msg := "Invalid job name, job name should have at most 63 " +
+			"characters, containing only lower case letters, numbers or dashes, " +
+			"starting with a letter."
+		return &tsuruErrors.ValidationError{Message: msg}
+	}
+	if j.Command == "" {
+		return &tsuruErrors.ValidationError{Message: "job command cannot be empty"}
+	}
+	_, err := ParseSchedule(j.Schedule)
+	if err != nil {
+		return &tsuruErrors.ValidationError{Message: err.Error()}
+	}
+	return nil
+}
+
+// Create validates and stores a new job, scheduling its first run.
+func Create(j *Job) error {
+	err := j.Validate()
+	if err != nil {
+		return err
+	}
+	schedule, _ := ParseSchedule(j.Schedule)
+	now := time.Now().UTC()
+	j.CreatedAt = now
+	j.NextRun = schedule.Next(now)
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Jobs().Insert(j)
+	if mgo.IsDup(err) {
+		return ErrJobAlreadyExists
+	}
+	return err
+}
+
+func Get(appName, name string) (*Job, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var j Job
+	err = conn.Jobs().Find(bson.M{"app": appName, "name": name}).One(&j)
+	if err == mgo.ErrNotFound {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+func List(appName string) ([]Job, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var jobs []Job
+	err = conn.Jobs().Find(bson.M{"app": appName}).Sort("name").All(&jobs)
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// Remove removes the job and its run history.
+func Remove(appName, name string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Jobs().Remove(bson.M{"app": appName, "name": name})
+	if err == mgo.ErrNotFound {
+		return ErrJobNotFound
+	}
+	if err != nil {
+		return err
+	}
+	_, err = conn.JobRuns().RemoveAll(bson.M{"app": appName, "job": name})
+	return err
+}
+
+// RemoveAll removes all jobs of an app, along with their run history.
+func RemoveAll(appName string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Jobs().RemoveAll(bson.M{"app": appName})
+	if err != nil {
+		return err
+	}
+	_, err = conn.JobRuns().RemoveAll(bson.M{"app": appName})
+	return err
+}
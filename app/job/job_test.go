@@ -0,0 +1,151 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package job
+
+import (
+	"errors"
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/provision/provisiontest"
+	"github.com/tsuru/tsuru/queue"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestCreate(c *check.C) {
+	j := Job{Name: "backup", App: "myapp", Schedule: "0 * * * *", Command: "./backup.sh"}
+	err := Create(&j)
+	c.Assert(err, check.IsNil)
+	c.Assert(j.NextRun.IsZero(), check.Equals, false)
+	c.Assert(j.NextRun.Minute(), check.Equals, 0)
+	dbJob, err := Get("myapp", "backup")
+	c.Assert(err, check.IsNil)
+	c.Assert(dbJob.Command, check.Equals, "./backup.sh")
+	c.Assert(dbJob.NextRun.Equal(j.NextRun), check.Equals, true)
+	err = Create(&Job{Name: "backup", App: "myapp", Schedule: "@daily", Command: "ls"})
+	c.Assert(err, check.Equals, ErrJobAlreadyExists)
+	err = Create(&Job{Name: "backup", App: "otherapp", Schedule: "@daily", Command: "ls"})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestCreateInvalid(c *check.C) {
+	tests := []Job{
+		{Name: "Invalid_Name", App: "myapp", Schedule: "@daily", Command: "ls"},
+		{Name: "nocmd", App: "myapp", Schedule: "@daily"},
+		{Name: "badschedule", App: "myapp", Schedule: "* *", Command: "ls"},
+	}
+	for _, j := range tests {
+		err := Create(&j)
+		_, ok := err.(*tsuruErrors.ValidationError)
+		c.Check(ok, check.Equals, true, check.Commentf("job %#v: %v", j, err))
+	}
+}
+
+func (s *S) TestListAndRemove(c *check.C) {
+	for _, name := range []string{"job2", "job1"} {
+		err := Create(&Job{Name: name, App: "myapp", Schedule: "@daily", Command: "ls"})
+		c.Assert(err, check.IsNil)
+	}
+	jobs, err := List("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(jobs, check.HasLen, 2)
+	c.Assert(jobs[0].Name, check.Equals, "job1")
+	c.Assert(jobs[1].Name, check.Equals, "job2")
+	err = Remove("myapp", "job1")
+	c.Assert(err, check.IsNil)
+	err = Remove("myapp", "job1")
+	c.Assert(err, check.Equals, ErrJobNotFound)
+	err = RemoveAll("myapp")
+	c.Assert(err, check.IsNil)
+	jobs, err = List("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(jobs, check.HasLen, 0)
+}
+
+func (s *S) TestTriggerAndExecute(c *check.C) {
+	err := s.conn.Apps().Insert(app.App{Name: "myapp"})
+	c.Assert(err, check.IsNil)
+	j := Job{Name: "backup", App: "myapp", Schedule: "@daily", Command: "./backup.sh"}
+	err = Create(&j)
+	c.Assert(err, check.IsNil)
+	run, err := Trigger(&j, TriggerManual, "me@tsuru.io")
+	c.Assert(err, check.IsNil)
+	c.Assert(run.Trigger, check.Equals, TriggerManual)
+	runs, err := Runs("myapp", "backup", 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(runs, check.HasLen, 1)
+	c.Assert(runs[0].StartTime.IsZero(), check.Equals, true)
+	provisiontest.ProvisionerInstance.PrepareOutput([]byte("backup done"))
+	msg, err := runQueue.Receive()
+	c.Assert(err, check.IsNil)
+	err = execute(msg)
+	c.Assert(err, check.IsNil)
+	dbRun, err := GetRun("myapp", "backup", run.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbRun.Running, check.Equals, false)
+	c.Assert(dbRun.StartTime.IsZero(), check.Equals, false)
+	c.Assert(dbRun.EndTime.IsZero(), check.Equals, false)
+	c.Assert(dbRun.Error, check.Equals, "")
+	c.Assert(dbRun.Log, check.Equals, "backup done")
+	c.Assert(dbRun.Owner, check.Equals, "me@tsuru.io")
+	cmds := provisiontest.ProvisionerInstance.GetCmds("", &app.App{Name: "myapp"})
+	c.Assert(cmds, check.HasLen, 1)
+	c.Assert(cmds[0].Cmd, check.Matches, ".*./backup.sh$")
+	err = execute(msg)
+	c.Assert(err, check.IsNil)
+	cmds = provisiontest.ProvisionerInstance.GetCmds("", &app.App{Name: "myapp"})
+	c.Assert(cmds, check.HasLen, 1)
+}
+
+func (s *S) TestExecuteFailure(c *check.C) {
+	err := s.conn.Apps().Insert(app.App{Name: "myapp"})
+	c.Assert(err, check.IsNil)
+	j := Job{Name: "backup", App: "myapp", Schedule: "@daily", Command: "./backup.sh"}
+	err = Create(&j)
+	c.Assert(err, check.IsNil)
+	run, err := Trigger(&j, TriggerManual, "")
+	c.Assert(err, check.IsNil)
+	provisiontest.ProvisionerInstance.PrepareFailure("ExecuteCommandIsolated", errors.New("exit status 1"))
+	msg, err := runQueue.Receive()
+	c.Assert(err, check.IsNil)
+	err = execute(msg)
+	c.Assert(err, check.IsNil)
+	dbRun, err := GetRun("myapp", "backup", run.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbRun.Error, check.Equals, "exit status 1")
+}
+
+func (s *S) TestGetRunNotFound(c *check.C) {
+	_, err := GetRun("myapp", "backup", bson.NewObjectId())
+	c.Assert(err, check.Equals, ErrRunNotFound)
+}
+
+func (s *S) TestTriggerDueJobs(c *check.C) {
+	err := Create(&Job{Name: "due", App: "myapp", Schedule: "* * * * *", Command: "ls"})
+	c.Assert(err, check.IsNil)
+	err = Create(&Job{Name: "notdue", App: "myapp", Schedule: "@yearly", Command: "ls"})
+	c.Assert(err, check.IsNil)
+	now := time.Now().UTC().Add(2 * time.Minute)
+	err = triggerDueJobs(now)
+	c.Assert(err, check.IsNil)
+	err = triggerDueJobs(now)
+	c.Assert(err, check.IsNil)
+	runs, err := Runs("myapp", "due", 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(runs, check.HasLen, 1)
+	c.Assert(runs[0].Trigger, check.Equals, TriggerSchedule)
+	runs, err = Runs("myapp", "notdue", 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(runs, check.HasLen, 0)
+	j, err := Get("myapp", "due")
+	c.Assert(err, check.IsNil)
+	c.Assert(j.NextRun.After(now), check.Equals, true)
+	_, err = runQueue.Receive()
+	c.Assert(err, check.IsNil)
+	_, err = runQueue.Receive()
+	c.Assert(err, check.Equals, queue.ErrNoMessage)
+}
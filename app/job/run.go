@@ -0,0 +1,293 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package job
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api/shutdown"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/queue"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const maxRunLogSize = 1024 * 1024
+
+var runQueue = queue.NewWorkQueue("app-jobs", queue.WorkQueueOpts{
+	MaxAttempts:       1,
+	VisibilityTimeout: 24 * time.Hour,
+})
+
+// Run is a single execution of a job.
+type Run struct {
+	ID         bson.ObjectId `bson:"_id"`
+	App        string
+	Job        string
+	Command    string
+	Trigger    string
+	Owner      string `bson:",omitempty"`
+	EnqueuedAt time.Time
+	StartTime  time.Time
+	EndTime    time.Time
+	Running    bool
+	Error      string
+	Log        string `json:",omitempty"`
+}
+
+type runMessage struct {
+	RunID bson.ObjectId
+	App   string
+	Job   string
+}
+
+func (m runMessage) Target() string {
+	return m.App + "/" + m.Job
+}
+
+// Trigger enqueues a new run of the job, which is executed in background.
+func Trigger(j *Job, trigger, owner string) (*Run, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	run := &Run{
+		ID:         bson.NewObjectId(),
+		App:        j.App,
+		Job:        j.Name,
+		Command:    j.Command,
+		Trigger:    trigger,
+		Owner:      owner,
+		EnqueuedAt: time.Now().UTC(),
+	}
+	err = conn.JobRuns().Insert(run)
+	if err != nil {
+		return nil, err
+	}
+	_, err = runQueue.Enqueue(runMessage{RunID: run.ID, App: run.App, Job: run.Job})
+	if err != nil {
+		conn.JobRuns().RemoveId(run.ID)
+		return nil, err
+	}
+	return run, nil
+}
+
+// Runs returns the most recent runs of a job, without their logs.
+func Runs(appName, jobName string, limit int) ([]Run, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	query := conn.JobRuns().Find(bson.M{"app": appName, "job": jobName}).Select(bson.M{"log": 0}).Sort("-enqueuedat")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	var runs []Run
+	err = query.All(&runs)
+	if err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// GetRun returns a job run, including its captured output.
+func GetRun(appName, jobName string, id bson.ObjectId) (*Run, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var run Run
+	err = conn.JobRuns().Find(bson.M{"_id": id, "app": appName, "job": jobName}).One(&run)
+	if err == mgo.ErrNotFound {
+		return nil, ErrRunNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+func execute(msg *queue.Message) error {
+	var data runMessage
+	err := msg.Decode(&data)
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var run Run
+	// Marking the run as started atomically prevents running a job twice
+	// when the message is delivered again.
+	_, err = conn.JobRuns().Find(bson.M{"_id": data.RunID, "starttime": time.Time{}}).Apply(mgo.Change{
+		Update:    bson.M{"$set": bson.M{"starttime": time.Now().UTC(), "running": true}},
+		ReturnNew: true,
+	}, &run)
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	output := &limitedBuffer{limit: maxRunLogSize}
+	runErr := runCommand(&run, output)
+	update := bson.M{
+		"endtime": time.Now().UTC(),
+		"running": false,
+		"log":     output.String(),
+	}
+	if runErr != nil {
+		update["error"] = runErr.Error()
+	}
+	return conn.JobRuns().UpdateId(run.ID, bson.M{"$set": update})
+}
+
+func runCommand(run *Run, w *limitedBuffer) error {
+	a, err := app.GetByName(run.App)
+	if err != nil {
+		return err
+	}
+	return a.Run(run.Command, w, provision.RunArgs{Isolated: true})
+}
+
+// limitedBuffer stores at most limit bytes written to it, discarding the
+// remaining output.
+type limitedBuffer struct {
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	remaining := b.limit - b.buf.Len()
+	if remaining < len(p) {
+		b.truncated = true
+		if remaining > 0 {
+			b.buf.Write(p[:remaining])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.truncated {
+		return b.buf.String() + fmt.Sprintf("\n[output truncated after %d bytes]\n", b.limit)
+	}
+	return b.buf.String()
+}
+
+// Initialize starts the job scheduler and the processing of job runs.
+func Initialize() error {
+	interval, _ := config.GetDuration("jobs:scheduler-interval")
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	err := runQueue.Consume(execute)
+	if err != nil {
+		return err
+	}
+	s := &scheduler{
+		interval: interval,
+		shutdown: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go s.loop()
+	shutdown.Register(s)
+	return nil
+}
+
+type scheduler struct {
+	interval time.Duration
+	shutdown chan struct{}
+	done     chan struct{}
+}
+
+func (s *scheduler) loop() {
+	defer close(s.done)
+	for {
+		err := triggerDueJobs(time.Now().UTC())
+		if err != nil {
+			log.Errorf("[job-scheduler] error triggering jobs: %s", err)
+		}
+		select {
+		case <-time.After(s.interval):
+		case <-s.shutdown:
+			return
+		}
+	}
+}
+
+func (s *scheduler) Shutdown(ctx context.Context) error {
+	close(s.shutdown)
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
+
+func (s *scheduler) String() string {
+	return "job scheduler"
+}
+
+// triggerDueJobs enqueues a run for each job scheduled to run until now.
+// Multiple tsuru API instances may run it concurrently, the next run time of
+// each job is updated atomically so only one of them triggers the job.
+func triggerDueJobs(now time.Time) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var jobs []Job
+	err = conn.Jobs().Find(bson.M{"nextrun": bson.M{"$lte": now, "$ne": time.Time{}}}).All(&jobs)
+	if err != nil {
+		return err
+	}
+	for i := range jobs {
+		j := &jobs[i]
+		schedule, err := ParseSchedule(j.Schedule)
+		if err != nil {
+			log.Errorf("[job-scheduler] invalid schedule for job %q in app %q: %s", j.Name, j.App, err)
+			continue
+		}
+		err = conn.Jobs().Update(
+			bson.M{"app": j.App, "name": j.Name, "nextrun": j.NextRun},
+			bson.M{"$set": bson.M{"nextrun": schedule.Next(now)}},
+		)
+		if err == mgo.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "unable to update next run of job %q in app %q", j.Name, j.App)
+		}
+		_, err = Trigger(j, TriggerSchedule, "")
+		if err != nil {
+			log.Errorf("[job-scheduler] unable to trigger job %q in app %q: %s", j.Name, j.App, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package job
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestParseScheduleInvalid(c *check.C) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"@every 5m",
+	}
+	for _, tt := range tests {
+		_, err := ParseSchedule(tt)
+		c.Check(err, check.NotNil, check.Commentf("schedule %q", tt))
+	}
+}
+
+func (s *S) TestScheduleNext(c *check.C) {
+	base := time.Date(2018, time.March, 14, 10, 27, 31, 0, time.UTC)
+	tests := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2018, time.March, 14, 10, 28, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2018, time.March, 14, 10, 30, 0, 0, time.UTC)},
+		{"5 * * * *", time.Date(2018, time.March, 14, 11, 5, 0, 0, time.UTC)},
+		{"0,27 10 * * *", time.Date(2018, time.March, 15, 10, 0, 0, 0, time.UTC)},
+		{"30 8-9 * * *", time.Date(2018, time.March, 15, 8, 30, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2018, time.March, 14, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2018, time.March, 15, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2018, time.March, 18, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2018, time.March, 18, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2018, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 12 1 * 1", time.Date(2018, time.March, 19, 12, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		schedule, err := ParseSchedule(tt.spec)
+		c.Assert(err, check.IsNil)
+		c.Check(schedule.Next(base), check.DeepEquals, tt.expected, check.Commentf("schedule %q", tt.spec))
+	}
+}
@@ -288,18 +288,30 @@ func (d *LogDispatcher) Shutdown(ctx context.Context) error {
 type appLogDispatcher struct {
 	appName string
 	*bulkProcessor
+	drains *appLogDrains
 }
 
 func newAppLogDispatcher(appName string) *appLogDispatcher {
 	d := &appLogDispatcher{
 		bulkProcessor: initBulkProcessor(bulkMaxWaitMongoTime, bulkMaxNumberMsgs),
 		appName:       appName,
+		drains:        &appLogDrains{appName: appName},
 	}
 	d.flushable = d
 	go d.run()
 	return d
 }
 
+func (d *appLogDispatcher) send(msg *msgWithTS) {
+	d.bulkProcessor.send(msg)
+	d.drains.send(msg)
+}
+
+func (d *appLogDispatcher) stopWait() {
+	d.bulkProcessor.stopWait()
+	d.drains.stopWait()
+}
+
 func (d *appLogDispatcher) flush(msgs []interface{}, lastMessage *msgWithTS) bool {
 	conn, err := db.LogConn()
 	if err != nil {
@@ -327,6 +339,9 @@ type bulkProcessor struct {
 	finished    chan struct{}
 	ch          chan *msgWithTS
 	nextNotify  *time.Timer
+	target      string
+	dropped     prometheus.Counter
+	queued      prometheus.Gauge
 	flushable   interface {
 		flush([]interface{}, *msgWithTS) bool
 	}
@@ -339,18 +354,21 @@ func initBulkProcessor(maxWait time.Duration, bulkSize int) *bulkProcessor {
 		finished:    make(chan struct{}),
 		ch:          make(chan *msgWithTS, bulkQueueMaxSize),
 		nextNotify:  time.NewTimer(0),
+		target:      "mongodb",
+		dropped:     logsDropped,
+		queued:      logsInAppQueues,
 	}
 }
 
 func (p *bulkProcessor) send(msg *msgWithTS) {
 	select {
 	case p.ch <- msg:
-		logsInAppQueues.Set(float64(len(p.ch)))
+		p.queued.Set(float64(len(p.ch)))
 	default:
-		logsDropped.Inc()
+		p.dropped.Inc()
 		select {
 		case <-p.nextNotify.C:
-			log.Errorf("dropping log messages to mongodb due to full channel buffer. app: %q, len: %d", msg.msg.AppName, len(p.ch))
+			log.Errorf("dropping log messages to %s due to full channel buffer. app: %q, len: %d", p.target, msg.msg.AppName, len(p.ch))
 			p.nextNotify.Reset(time.Minute)
 		default:
 		}
@@ -373,7 +391,7 @@ func (p *bulkProcessor) run() {
 		var flush bool
 		select {
 		case msgExtra := <-p.ch:
-			logsInAppQueues.Set(float64(len(p.ch)))
+			p.queued.Set(float64(len(p.ch)))
 			if msgExtra == nil {
 				flush = true
 				shouldReturn = true
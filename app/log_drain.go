@@ -0,0 +1,356 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tsuru/tsuru/db"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/log"
+	tsuruNet "github.com/tsuru/tsuru/net"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	logDrainsRefreshInterval = 30 * time.Second
+	logDrainBulkSize         = 100
+	logDrainMaxWait          = time.Second
+	logDrainTimeout          = 10 * time.Second
+
+	ErrLogDrainNotFound      = errors.New("log drain not found")
+	ErrLogDrainAlreadyExists = errors.New("log drain already exists")
+
+	logDrainSchemes = map[string]bool{
+		"syslog":     true,
+		"syslog+tcp": true,
+		"syslog+udp": true,
+		"syslog+tls": true,
+		"http":       true,
+		"https":      true,
+	}
+
+	logsDrainSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "tsuru_logs_drain_sent_total",
+		Help: "The number of log entries sent to log drains.",
+	}, []string{"scheme"})
+
+	logsDrainDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "tsuru_logs_drain_dropped_total",
+		Help: "The number of log entries dropped by log drains due to full buffers or delivery errors.",
+	}, []string{"scheme"})
+
+	logsInDrainQueues = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "tsuru_logs_drain_queues_current",
+		Help: "The current number of log entries in log drain queues.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(logsDrainSent)
+	prometheus.MustRegister(logsDrainDropped)
+	prometheus.MustRegister(logsInDrainQueues)
+}
+
+// LogDrain is an external destination receiving a copy of each log line of
+// an app. Syslog drains (syslog://, syslog+udp://, syslog+tls://) receive
+// RFC 5424 messages, HTTP drains receive POST requests with a JSON list of
+// log entries.
+type LogDrain struct {
+	URL string `json:"url"`
+}
+
+func validateLogDrainURL(drainURL string) (*url.URL, error) {
+	u, err := url.Parse(drainURL)
+	if err != nil {
+		return nil, errors.Errorf("invalid log drain url %q: %s", drainURL, err)
+	}
+	if !logDrainSchemes[u.Scheme] {
+		return nil, errors.Errorf("invalid log drain url %q: unsupported scheme %q", drainURL, u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, errors.Errorf("invalid log drain url %q: missing host", drainURL)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" && u.Port() == "" {
+		return nil, errors.Errorf("invalid log drain url %q: missing port", drainURL)
+	}
+	return u, nil
+}
+
+// AddLogDrain registers a new log drain in the app.
+func (app *App) AddLogDrain(drainURL string) (*LogDrain, error) {
+	_, err := validateLogDrainURL(drainURL)
+	if err != nil {
+		return nil, &tsuruErrors.ValidationError{Message: err.Error()}
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	drain := LogDrain{URL: drainURL}
+	err = conn.Apps().Update(
+		bson.M{"name": app.Name, "logdrains.url": bson.M{"$ne": drainURL}},
+		bson.M{"$push": bson.M{"logdrains": drain}},
+	)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, ErrLogDrainAlreadyExists
+		}
+		return nil, err
+	}
+	app.LogDrains = append(app.LogDrains, drain)
+	return &drain, nil
+}
+
+// RemoveLogDrain removes a log drain from the app.
+func (app *App) RemoveLogDrain(drainURL string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Apps().Update(
+		bson.M{"name": app.Name, "logdrains.url": drainURL},
+		bson.M{"$pull": bson.M{"logdrains": bson.M{"url": drainURL}}},
+	)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return ErrLogDrainNotFound
+		}
+		return err
+	}
+	for i, d := range app.LogDrains {
+		if d.URL == drainURL {
+			app.LogDrains = append(app.LogDrains[:i], app.LogDrains[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func getLogDrains(appName string) ([]LogDrain, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var a App
+	err = conn.Apps().Find(bson.M{"name": appName}).Select(bson.M{"logdrains": 1}).One(&a)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return a.LogDrains, nil
+}
+
+// logDrainSender delivers a batch of log entries to a drain.
+type logDrainSender interface {
+	sendLogs(logs []*Applog) error
+}
+
+func newLogDrainSender(drainURL string) (logDrainSender, error) {
+	u, err := validateLogDrainURL(drainURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return &httpLogDrain{url: drainURL}, nil
+	case "syslog+udp":
+		return &syslogLogDrain{network: "udp", address: u.Host}, nil
+	case "syslog+tls":
+		return &syslogLogDrain{network: "tcp", address: u.Host, tls: true}, nil
+	}
+	return &syslogLogDrain{network: "tcp", address: u.Host}, nil
+}
+
+type httpLogDrain struct {
+	url string
+}
+
+func (d *httpLogDrain) sendLogs(logs []*Applog) error {
+	data, err := json.Marshal(logs)
+	if err != nil {
+		return err
+	}
+	rsp, err := tsuruNet.Dial5Full60ClientNoKeepAlive.Post(d.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode < 200 || rsp.StatusCode >= 300 {
+		data, _ = ioutil.ReadAll(rsp.Body)
+		return errors.Errorf("invalid status code %d: %s", rsp.StatusCode, data)
+	}
+	return nil
+}
+
+type syslogLogDrain struct {
+	network string
+	address string
+	tls     bool
+}
+
+func (d *syslogLogDrain) sendLogs(logs []*Applog) error {
+	dialer := &net.Dialer{Timeout: logDrainTimeout}
+	var conn net.Conn
+	var err error
+	if d.tls {
+		conn, err = tls.DialWithDialer(dialer, d.network, d.address, &tls.Config{})
+	} else {
+		conn, err = dialer.Dial(d.network, d.address)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetWriteDeadline(time.Now().Add(logDrainTimeout))
+	for _, l := range logs {
+		msg := formatSyslog(l)
+		if d.network != "udp" {
+			// Octet counting framing, as described in RFC 6587.
+			msg = fmt.Sprintf("%d %s", len(msg), msg)
+		}
+		_, err = conn.Write([]byte(msg))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatSyslog formats a log entry as a RFC 5424 message, using the app name
+// as hostname, the log source as app-name and the unit as procid.
+func formatSyslog(l *Applog) string {
+	const priority = 14 // facility user, severity informational
+	return fmt.Sprintf("<%d>1 %s %s %s %s - - %s",
+		priority,
+		l.Date.UTC().Format(time.RFC3339Nano),
+		syslogField(l.AppName),
+		syslogField(l.Source),
+		syslogField(l.Unit),
+		l.Message,
+	)
+}
+
+func syslogField(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// logDrainDispatcher queues the log entries sent to a drain, dropping them
+// when the drain can't keep up.
+type logDrainDispatcher struct {
+	url    string
+	scheme string
+	sender logDrainSender
+	*bulkProcessor
+}
+
+func newLogDrainDispatcher(drainURL string) (*logDrainDispatcher, error) {
+	sender, err := newLogDrainSender(drainURL)
+	if err != nil {
+		return nil, err
+	}
+	u, _ := url.Parse(drainURL)
+	d := &logDrainDispatcher{
+		url:           drainURL,
+		scheme:        u.Scheme,
+		sender:        sender,
+		bulkProcessor: initBulkProcessor(logDrainMaxWait, logDrainBulkSize),
+	}
+	d.flushable = d
+	d.target = fmt.Sprintf("log drain %s", u.Scheme)
+	d.dropped = logsDrainDropped.WithLabelValues(u.Scheme)
+	d.queued = logsInDrainQueues
+	go d.run()
+	return d, nil
+}
+
+// flush always reports the logs as handled, logs failing to be delivered
+// are dropped instead of retried so a broken drain doesn't hold its queue.
+func (d *logDrainDispatcher) flush(msgs []interface{}, lastMessage *msgWithTS) bool {
+	logs := make([]*Applog, len(msgs))
+	for i := range msgs {
+		logs[i] = msgs[i].(*Applog)
+	}
+	err := d.sender.sendLogs(logs)
+	if err != nil {
+		log.Errorf("[log drain] unable to send logs to %s drain of app %q: %s", d.scheme, logs[0].AppName, err)
+		logsDrainDropped.WithLabelValues(d.scheme).Add(float64(len(msgs)))
+		return true
+	}
+	logsDrainSent.WithLabelValues(d.scheme).Add(float64(len(msgs)))
+	return true
+}
+
+// appLogDrains keeps the drain dispatchers of an app in sync with the drains
+// registered in the database.
+type appLogDrains struct {
+	appName     string
+	dispatchers map[string]*logDrainDispatcher
+	nextRefresh time.Time
+}
+
+func (d *appLogDrains) send(msg *msgWithTS) {
+	if time.Now().After(d.nextRefresh) {
+		d.refresh()
+	}
+	for _, drainD := range d.dispatchers {
+		drainD.send(msg)
+	}
+}
+
+func (d *appLogDrains) refresh() {
+	d.nextRefresh = time.Now().Add(logDrainsRefreshInterval)
+	drains, err := getLogDrains(d.appName)
+	if err != nil {
+		log.Errorf("[log drain] unable to get log drains for app %q: %s", d.appName, err)
+		return
+	}
+	current := make(map[string]struct{}, len(drains))
+	for _, drain := range drains {
+		current[drain.URL] = struct{}{}
+		if _, ok := d.dispatchers[drain.URL]; ok {
+			continue
+		}
+		drainD, err := newLogDrainDispatcher(drain.URL)
+		if err != nil {
+			log.Errorf("[log drain] ignoring log drain for app %q: %s", d.appName, err)
+			continue
+		}
+		if d.dispatchers == nil {
+			d.dispatchers = make(map[string]*logDrainDispatcher)
+		}
+		d.dispatchers[drain.URL] = drainD
+	}
+	for drainURL, drainD := range d.dispatchers {
+		if _, ok := current[drainURL]; !ok {
+			delete(d.dispatchers, drainURL)
+			go drainD.stopWait()
+		}
+	}
+}
+
+func (d *appLogDrains) stopWait() {
+	for _, drainD := range d.dispatchers {
+		drainD.stopWait()
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/tsuru/tsuru/errors"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestValidateLogDrainURL(c *check.C) {
+	tests := []struct {
+		url string
+		err string
+	}{
+		{url: "syslog://logs.example.com:514"},
+		{url: "syslog+udp://logs.example.com:514"},
+		{url: "syslog+tls://logs.example.com:6514"},
+		{url: "https://logs.example.com/drain"},
+		{url: "ftp://logs.example.com", err: `invalid log drain url "ftp://logs.example.com": unsupported scheme "ftp"`},
+		{url: "syslog://logs.example.com", err: `invalid log drain url "syslog://logs.example.com": missing port`},
+		{url: "https:///drain", err: `invalid log drain url "https:///drain": missing host`},
+	}
+	for _, tt := range tests {
+		_, err := validateLogDrainURL(tt.url)
+		if tt.err == "" {
+			c.Check(err, check.IsNil)
+		} else {
+			c.Check(err, check.ErrorMatches, tt.err)
+		}
+	}
+}
+
+func (s *S) TestFormatSyslog(c *check.C) {
+	l := &Applog{
+		Date:    time.Date(2018, 3, 1, 10, 0, 0, 0, time.UTC),
+		Message: "listening on 8888",
+		Source:  "web",
+		AppName: "myapp",
+		Unit:    "unit1",
+	}
+	c.Assert(formatSyslog(l), check.Equals, "<14>1 2018-03-01T10:00:00Z myapp web unit1 - - listening on 8888")
+	l.Unit = ""
+	c.Assert(formatSyslog(l), check.Equals, "<14>1 2018-03-01T10:00:00Z myapp web - - - listening on 8888")
+}
+
+func (s *S) TestAddLogDrain(c *check.C) {
+	a := App{Name: "myapp", Platform: "python", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	drain, err := a.AddLogDrain("syslog://logs.example.com:514")
+	c.Assert(err, check.IsNil)
+	c.Assert(drain, check.DeepEquals, &LogDrain{URL: "syslog://logs.example.com:514"})
+	_, err = a.AddLogDrain("https://logs.example.com/drain")
+	c.Assert(err, check.IsNil)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.LogDrains, check.DeepEquals, []LogDrain{
+		{URL: "syslog://logs.example.com:514"},
+		{URL: "https://logs.example.com/drain"},
+	})
+	_, err = a.AddLogDrain("syslog://logs.example.com:514")
+	c.Assert(err, check.Equals, ErrLogDrainAlreadyExists)
+	_, err = a.AddLogDrain("ftp://logs.example.com")
+	c.Assert(errors.IsValidation(err), check.Equals, true)
+}
+
+func (s *S) TestRemoveLogDrain(c *check.C) {
+	a := App{Name: "myapp", Platform: "python", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	_, err = a.AddLogDrain("syslog://logs.example.com:514")
+	c.Assert(err, check.IsNil)
+	_, err = a.AddLogDrain("https://logs.example.com/drain")
+	c.Assert(err, check.IsNil)
+	err = a.RemoveLogDrain("syslog://logs.example.com:514")
+	c.Assert(err, check.IsNil)
+	c.Assert(a.LogDrains, check.DeepEquals, []LogDrain{{URL: "https://logs.example.com/drain"}})
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.LogDrains, check.DeepEquals, []LogDrain{{URL: "https://logs.example.com/drain"}})
+	err = a.RemoveLogDrain("syslog://logs.example.com:514")
+	c.Assert(err, check.Equals, ErrLogDrainNotFound)
+}
+
+func (s *S) TestHTTPLogDrainSendLogs(c *check.C) {
+	var received []Applog
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("Content-Type"), check.Equals, "application/json")
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer srv.Close()
+	sender, err := newLogDrainSender(srv.URL)
+	c.Assert(err, check.IsNil)
+	err = sender.sendLogs([]*Applog{
+		{Message: "msg1", AppName: "myapp"},
+		{Message: "msg2", AppName: "myapp"},
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(logMessages(received), check.DeepEquals, []string{"msg1", "msg2"})
+}
+
+func (s *S) TestHTTPLogDrainSendLogsError(c *check.C) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("unavailable"))
+	}))
+	defer srv.Close()
+	sender, err := newLogDrainSender(srv.URL)
+	c.Assert(err, check.IsNil)
+	err = sender.sendLogs([]*Applog{{Message: "msg1"}})
+	c.Assert(err, check.ErrorMatches, "invalid status code 503: unavailable")
+}
+
+func (s *S) TestSyslogLogDrainSendLogs(c *check.C) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	defer listener.Close()
+	received := make(chan string)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		data, _ := ioutil.ReadAll(conn)
+		received <- string(data)
+	}()
+	sender, err := newLogDrainSender("syslog://" + listener.Addr().String())
+	c.Assert(err, check.IsNil)
+	date := time.Date(2018, 3, 1, 10, 0, 0, 0, time.UTC)
+	err = sender.sendLogs([]*Applog{
+		{Date: date, Message: "msg1", AppName: "myapp", Source: "web", Unit: "u1"},
+		{Date: date, Message: "msg2", AppName: "myapp", Source: "web", Unit: "u1"},
+	})
+	c.Assert(err, check.IsNil)
+	select {
+	case data := <-received:
+		c.Assert(data, check.Equals, "48 <14>1 2018-03-01T10:00:00Z myapp web u1 - - msg148 <14>1 2018-03-01T10:00:00Z myapp web u1 - - msg2")
+	case <-time.After(5 * time.Second):
+		c.Fatal("timeout waiting for syslog messages")
+	}
+}
+
+func (s *S) TestLogDrainDispatcherDropsOnError(c *check.C) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	d, err := newLogDrainDispatcher(srv.URL)
+	c.Assert(err, check.IsNil)
+	dropped := logsDrainDropped.WithLabelValues("http")
+	var before dto.Metric
+	dropped.Write(&before)
+	d.send(&msgWithTS{msg: &Applog{Message: "msg1", AppName: "myapp"}, arriveTime: time.Now()})
+	d.stopWait()
+	var after dto.Metric
+	dropped.Write(&after)
+	c.Assert(after.Counter.GetValue()-before.Counter.GetValue(), check.Equals, 1.0)
+}
+
+func (s *S) TestLogDispatcherSendToDrains(c *check.C) {
+	received := make(chan []Applog, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var logs []Applog
+		json.NewDecoder(r.Body).Decode(&logs)
+		received <- logs
+	}))
+	defer srv.Close()
+	a := App{Name: "myapp1", Platform: "python", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	_, err = a.AddLogDrain(srv.URL)
+	c.Assert(err, check.IsNil)
+	dispatcher := NewlogDispatcher(100)
+	logMsg := Applog{
+		Date: time.Now(), Message: "msg1", Source: "web", AppName: "myapp1", Unit: "unit1",
+	}
+	err = dispatcher.Send(&logMsg)
+	c.Assert(err, check.IsNil)
+	dispatcher.Shutdown(context.Background())
+	select {
+	case logs := <-received:
+		c.Assert(logMessages(logs), check.DeepEquals, []string{"msg1"})
+	case <-time.After(5 * time.Second):
+		c.Fatal("timeout waiting for drain")
+	}
+	logs, err := a.LastLogs(1, Applog{})
+	c.Assert(err, check.IsNil)
+	c.Assert(logMessages(logs), check.DeepEquals, []string{"msg1"})
+}
@@ -0,0 +1,268 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api/shutdown"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/storage"
+	"github.com/tsuru/tsuru/log"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const logRetentionRunID = "log-retention"
+
+// LogArchiver ships app logs to an external storage before they're removed
+// by the log retention task.
+type LogArchiver interface {
+	Archive(appName string, logs []Applog) error
+}
+
+var logArchivers = map[string]func() (LogArchiver, error){}
+
+// RegisterLogArchiver registers a log archival driver, which may be chosen
+// in the log:archive:driver config.
+func RegisterLogArchiver(name string, factory func() (LogArchiver, error)) {
+	logArchivers[name] = factory
+}
+
+func getLogArchiver() (LogArchiver, error) {
+	driver, _ := config.GetString("log:archive:driver")
+	if driver == "" {
+		return nil, nil
+	}
+	factory, ok := logArchivers[driver]
+	if !ok {
+		return nil, errors.Errorf("unknown log archive driver: %q", driver)
+	}
+	return factory()
+}
+
+// logMaxAge returns for how long the logs of an app are kept, zero meaning
+// the logs are only limited by the size of the collection.
+func logMaxAge(appName string) time.Duration {
+	maxAge, err := config.GetDuration("log:retention:apps:" + appName + ":max-age")
+	if err != nil || maxAge <= 0 {
+		maxAge, _ = config.GetDuration("log:retention:max-age")
+	}
+	return maxAge
+}
+
+// InitializeLogRetention starts the task enforcing the retention of app logs,
+// when log:retention is configured.
+func InitializeLogRetention() error {
+	if _, err := config.Get("log:retention"); err != nil {
+		return nil
+	}
+	interval, _ := config.GetDuration("log:retention:interval")
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	archiver, err := getLogArchiver()
+	if err != nil {
+		return err
+	}
+	r := &logRetention{
+		interval: interval,
+		archiver: archiver,
+		shutdown: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go r.loop()
+	shutdown.Register(r)
+	return nil
+}
+
+type logRetention struct {
+	interval time.Duration
+	archiver LogArchiver
+	shutdown chan struct{}
+	done     chan struct{}
+}
+
+func (r *logRetention) loop() {
+	defer close(r.done)
+	for {
+		now := time.Now().UTC()
+		claimed, err := claimLogRetentionRun(now, r.interval)
+		if err != nil {
+			log.Errorf("[log-retention] error claiming run: %s", err)
+		}
+		if claimed {
+			err = enforceLogRetention(now, r.archiver)
+			if err != nil {
+				log.Errorf("[log-retention] error enforcing retention: %s", err)
+			}
+		}
+		select {
+		case <-time.After(r.interval):
+		case <-r.shutdown:
+			return
+		}
+	}
+}
+
+func (r *logRetention) Shutdown(ctx context.Context) error {
+	close(r.shutdown)
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
+
+func (r *logRetention) String() string {
+	return "log retention"
+}
+
+// claimLogRetentionRun atomically sets the time of the next run, so only one
+// of the tsuru API instances enforces the retention in each interval.
+func claimLogRetentionRun(now time.Time, interval time.Duration) (bool, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	return claimRun(conn.LogRetentionRuns(), logRetentionRunID, now, interval)
+}
+
+// claimRun atomically sets the time of the next run of a periodic task,
+// returning whether the caller should run it now.
+func claimRun(coll *storage.Collection, id string, now time.Time, interval time.Duration) (bool, error) {
+	next := now.Add(interval)
+	err := coll.Update(
+		bson.M{"_id": id, "next": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"next": next}},
+	)
+	if err == nil {
+		return true, nil
+	}
+	if err != mgo.ErrNotFound {
+		return false, err
+	}
+	err = coll.Insert(bson.M{"_id": id, "next": next})
+	if mgo.IsDup(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func enforceLogRetention(now time.Time, archiver LogArchiver) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	var apps []App
+	err = conn.Apps().Find(nil).Select(bson.M{"name": 1}).All(&apps)
+	conn.Close()
+	if err != nil {
+		return err
+	}
+	for _, a := range apps {
+		err = enforceAppLogRetention(a.Name, now, archiver)
+		if err != nil {
+			log.Errorf("[log-retention] error enforcing retention for app %q: %s", a.Name, err)
+		}
+	}
+	return nil
+}
+
+// enforceAppLogRetention removes the logs of an app older than its max age
+// and resizes its log collection when the configured size has changed. As
+// capped collections don't allow removals, the collection is rewritten with
+// the logs being kept. Removed logs are archived first, when an archiver is
+// configured.
+func enforceAppLogRetention(appName string, now time.Time, archiver LogArchiver) error {
+	conn, err := db.LogConn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	coll := conn.Logs(appName)
+	info := db.LogsCappedInfo(appName)
+	var stats struct {
+		Max int `bson:"max"`
+	}
+	err = coll.Database.Run(bson.D{{Name: "collStats", Value: coll.Name}}, &stats)
+	if err != nil {
+		return err
+	}
+	var logs []Applog
+	err = coll.Find(nil).Sort("$natural").All(&logs)
+	if err != nil {
+		return err
+	}
+	maxAge := logMaxAge(appName)
+	var kept, removed []Applog
+	for _, l := range logs {
+		if maxAge > 0 && l.Date.Before(now.Add(-maxAge)) {
+			removed = append(removed, l)
+		} else {
+			kept = append(kept, l)
+		}
+	}
+	if overflow := len(kept) - info.MaxDocs; overflow > 0 {
+		removed = append(removed, kept[:overflow]...)
+		kept = kept[overflow:]
+	}
+	if len(removed) == 0 && stats.Max == info.MaxDocs {
+		return nil
+	}
+	if archiver != nil && len(removed) > 0 {
+		err = archiver.Archive(appName, removed)
+		if err != nil {
+			return errors.Wrap(err, "unable to archive logs")
+		}
+	}
+	var lastID bson.ObjectId
+	if len(logs) > 0 {
+		lastID = logs[len(logs)-1].MongoID
+	}
+	return rewriteLogs(conn, coll.Name, info, kept, lastID)
+}
+
+// rewriteLogs replaces a log collection by a new one, created with the given
+// options and holding the given logs along with the ones inserted after
+// lastID, while the collection was being rewritten.
+func rewriteLogs(conn *db.LogStorage, name string, info *mgo.CollectionInfo, logs []Applog, lastID bson.ObjectId) error {
+	tmp := conn.Collection(name + "_retention")
+	tmp.Collection.DropCollection()
+	err := tmp.Collection.Create(info)
+	if err != nil {
+		return err
+	}
+	var recent []Applog
+	query := bson.M{}
+	if lastID != "" {
+		query["_id"] = bson.M{"$gt": lastID}
+	}
+	err = conn.Collection(name).Find(query).Sort("$natural").All(&recent)
+	if err != nil {
+		return err
+	}
+	logs = append(logs, recent...)
+	docs := make([]interface{}, len(logs))
+	for i := range logs {
+		docs[i] = logs[i]
+	}
+	if len(docs) > 0 {
+		err = tmp.Insert(docs...)
+		if err != nil {
+			return err
+		}
+	}
+	return tmp.Database.Session.DB("admin").Run(bson.D{
+		{Name: "renameCollection", Value: tmp.FullName},
+		{Name: "to", Value: conn.Collection(name).FullName},
+		{Name: "dropTarget", Value: true},
+	}, nil)
+}
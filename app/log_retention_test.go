@@ -0,0 +1,151 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type fakeLogArchiver struct {
+	archived map[string][]Applog
+	err      error
+}
+
+func (a *fakeLogArchiver) Archive(appName string, logs []Applog) error {
+	if a.err != nil {
+		return a.err
+	}
+	if a.archived == nil {
+		a.archived = map[string][]Applog{}
+	}
+	a.archived[appName] = append(a.archived[appName], logs...)
+	return nil
+}
+
+func logMessages(logs []Applog) []string {
+	messages := make([]string, len(logs))
+	for i, l := range logs {
+		messages[i] = l.Message
+	}
+	return messages
+}
+
+func (s *S) TestLogMaxAge(c *check.C) {
+	c.Assert(logMaxAge("myapp"), check.Equals, time.Duration(0))
+	config.Set("log:retention:max-age", "48h")
+	defer config.Unset("log:retention:max-age")
+	c.Assert(logMaxAge("myapp"), check.Equals, 48*time.Hour)
+	config.Set("log:retention:apps:myapp:max-age", "1h")
+	defer config.Unset("log:retention:apps")
+	c.Assert(logMaxAge("myapp"), check.Equals, time.Hour)
+	c.Assert(logMaxAge("otherapp"), check.Equals, 48*time.Hour)
+}
+
+func (s *S) TestGetLogArchiver(c *check.C) {
+	archiver, err := getLogArchiver()
+	c.Assert(err, check.IsNil)
+	c.Assert(archiver, check.IsNil)
+	fake := &fakeLogArchiver{}
+	RegisterLogArchiver("fake", func() (LogArchiver, error) {
+		return fake, nil
+	})
+	defer delete(logArchivers, "fake")
+	config.Set("log:archive:driver", "fake")
+	defer config.Unset("log:archive")
+	archiver, err = getLogArchiver()
+	c.Assert(err, check.IsNil)
+	c.Assert(archiver, check.Equals, fake)
+	config.Set("log:archive:driver", "unknown")
+	_, err = getLogArchiver()
+	c.Assert(err, check.ErrorMatches, `unknown log archive driver: "unknown"`)
+}
+
+func (s *S) TestClaimLogRetentionRun(c *check.C) {
+	now := time.Now().UTC()
+	claimed, err := claimLogRetentionRun(now, time.Hour)
+	c.Assert(err, check.IsNil)
+	c.Assert(claimed, check.Equals, true)
+	claimed, err = claimLogRetentionRun(now.Add(time.Minute), time.Hour)
+	c.Assert(err, check.IsNil)
+	c.Assert(claimed, check.Equals, false)
+	claimed, err = claimLogRetentionRun(now.Add(time.Hour), time.Hour)
+	c.Assert(err, check.IsNil)
+	c.Assert(claimed, check.Equals, true)
+}
+
+func (s *S) TestEnforceAppLogRetention(c *check.C) {
+	config.Set("log:retention:max-age", "24h")
+	defer config.Unset("log:retention")
+	s.logConn.Logs("myapp").DropCollection()
+	defer s.logConn.Logs("myapp").DropCollection()
+	now := time.Now().UTC()
+	err := insertLogs("myapp", []interface{}{
+		Applog{Date: now.Add(-72 * time.Hour), Message: "old1", AppName: "myapp"},
+		Applog{Date: now.Add(-48 * time.Hour), Message: "old2", AppName: "myapp"},
+		Applog{Date: now.Add(-time.Hour), Message: "new1", AppName: "myapp"},
+		Applog{Date: now, Message: "new2", AppName: "myapp"},
+	})
+	c.Assert(err, check.IsNil)
+	archiver := &fakeLogArchiver{}
+	err = enforceAppLogRetention("myapp", now, archiver)
+	c.Assert(err, check.IsNil)
+	c.Assert(logMessages(archiver.archived["myapp"]), check.DeepEquals, []string{"old1", "old2"})
+	var logs []Applog
+	err = s.logConn.Logs("myapp").Find(nil).Sort("$natural").All(&logs)
+	c.Assert(err, check.IsNil)
+	c.Assert(logMessages(logs), check.DeepEquals, []string{"new1", "new2"})
+}
+
+func (s *S) TestEnforceAppLogRetentionResizesCollection(c *check.C) {
+	s.logConn.Logs("myapp").DropCollection()
+	defer s.logConn.Logs("myapp").DropCollection()
+	err := insertLogs("myapp", []interface{}{
+		Applog{Message: "msg1", AppName: "myapp"},
+		Applog{Message: "msg2", AppName: "myapp"},
+		Applog{Message: "msg3", AppName: "myapp"},
+	})
+	c.Assert(err, check.IsNil)
+	config.Set("log:retention:apps:myapp:max-docs", 2)
+	defer config.Unset("log:retention")
+	err = enforceAppLogRetention("myapp", time.Now().UTC(), nil)
+	c.Assert(err, check.IsNil)
+	var logs []Applog
+	err = s.logConn.Logs("myapp").Find(nil).Sort("$natural").All(&logs)
+	c.Assert(err, check.IsNil)
+	c.Assert(logMessages(logs), check.DeepEquals, []string{"msg2", "msg3"})
+	var stats struct {
+		Max int `bson:"max"`
+	}
+	err = s.logConn.Logs("myapp").Database.Run(bson.D{{Name: "collStats", Value: "logs_myapp"}}, &stats)
+	c.Assert(err, check.IsNil)
+	c.Assert(stats.Max, check.Equals, 2)
+}
+
+func (s *S) TestEnforceAppLogRetentionArchiveError(c *check.C) {
+	config.Set("log:retention:max-age", "1h")
+	defer config.Unset("log:retention")
+	s.logConn.Logs("myapp").DropCollection()
+	defer s.logConn.Logs("myapp").DropCollection()
+	now := time.Now().UTC()
+	err := insertLogs("myapp", []interface{}{
+		Applog{Date: now.Add(-2 * time.Hour), Message: "old", AppName: "myapp"},
+	})
+	c.Assert(err, check.IsNil)
+	archiver := &fakeLogArchiver{err: errors.New("archive unavailable")}
+	err = enforceAppLogRetention("myapp", now, archiver)
+	c.Assert(err, check.ErrorMatches, "unable to archive logs: archive unavailable")
+	conn, err := db.LogConn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	count, err := conn.Logs("myapp").Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, 1)
+}
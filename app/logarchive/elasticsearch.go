@@ -0,0 +1,89 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logarchive
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app"
+	tsuruNet "github.com/tsuru/tsuru/net"
+)
+
+const defaultElasticsearchIndex = "tsuru-logs"
+
+func init() {
+	app.RegisterLogArchiver("elasticsearch", newElasticsearchArchiver)
+}
+
+// elasticsearchArchiver indexes archived logs in elasticsearch using the
+// bulk API.
+type elasticsearchArchiver struct {
+	url   string
+	index string
+}
+
+func newElasticsearchArchiver() (app.LogArchiver, error) {
+	url, err := config.GetString("log:archive:elasticsearch:url")
+	if err != nil {
+		return nil, err
+	}
+	index, _ := config.GetString("log:archive:elasticsearch:index")
+	if index == "" {
+		index = defaultElasticsearchIndex
+	}
+	return &elasticsearchArchiver{
+		url:   strings.TrimRight(url, "/"),
+		index: index,
+	}, nil
+}
+
+type bulkResult struct {
+	Errors bool `json:"errors"`
+}
+
+func (a *elasticsearchArchiver) Archive(appName string, logs []app.Applog) error {
+	var buf bytes.Buffer
+	action, err := json.Marshal(map[string]interface{}{
+		"index": map[string]string{"_index": a.index, "_type": "log"},
+	})
+	if err != nil {
+		return err
+	}
+	for _, l := range logs {
+		doc, err := encodeLogs([]app.Applog{l})
+		if err != nil {
+			return err
+		}
+		buf.Write(action)
+		buf.WriteByte('\n')
+		buf.Write(doc)
+	}
+	url := fmt.Sprintf("%s/_bulk", a.url)
+	rsp, err := tsuruNet.Dial5Full60ClientNoKeepAlive.Post(url, "application/x-ndjson", &buf)
+	if err != nil {
+		return errors.Wrap(err, "unable to archive logs to elasticsearch")
+	}
+	defer rsp.Body.Close()
+	data, _ := ioutil.ReadAll(rsp.Body)
+	if rsp.StatusCode != http.StatusOK {
+		return errors.Errorf("unable to archive logs to elasticsearch (%d): %s", rsp.StatusCode, data)
+	}
+	var result bulkResult
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		return errors.Wrap(err, "unable to parse elasticsearch response")
+	}
+	if result.Errors {
+		return errors.Errorf("unable to archive some logs to elasticsearch: %s", data)
+	}
+	return nil
+}
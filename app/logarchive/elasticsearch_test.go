@@ -0,0 +1,71 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logarchive
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestElasticsearchArchiverArchive(c *check.C) {
+	var path, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		data, _ := ioutil.ReadAll(r.Body)
+		body = string(data)
+		w.Write([]byte(`{"errors": false}`))
+	}))
+	defer srv.Close()
+	config.Set("log:archive:elasticsearch:url", srv.URL+"/")
+	archiver, err := newElasticsearchArchiver()
+	c.Assert(err, check.IsNil)
+	err = archiver.Archive("myapp", []app.Applog{
+		{Message: "msg1", AppName: "myapp"},
+		{Message: "msg2", AppName: "myapp"},
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(path, check.Equals, "/_bulk")
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+	c.Assert(lines, check.HasLen, 4)
+	c.Assert(lines[0], check.Equals, `{"index":{"_index":"tsuru-logs","_type":"log"}}`)
+	c.Assert(lines[1], check.Matches, `\{.*"Message":"msg1".*\}`)
+	c.Assert(lines[2], check.Equals, lines[0])
+	c.Assert(lines[3], check.Matches, `\{.*"Message":"msg2".*\}`)
+}
+
+func (s *S) TestElasticsearchArchiverArchiveCustomIndex(c *check.C) {
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		body = string(data)
+		w.Write([]byte(`{"errors": false}`))
+	}))
+	defer srv.Close()
+	config.Set("log:archive:elasticsearch:url", srv.URL)
+	config.Set("log:archive:elasticsearch:index", "archived-logs")
+	archiver, err := newElasticsearchArchiver()
+	c.Assert(err, check.IsNil)
+	err = archiver.Archive("myapp", []app.Applog{{Message: "msg1"}})
+	c.Assert(err, check.IsNil)
+	c.Assert(strings.HasPrefix(body, `{"index":{"_index":"archived-logs","_type":"log"}}`), check.Equals, true)
+}
+
+func (s *S) TestElasticsearchArchiverArchiveItemErrors(c *check.C) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"errors": true}`))
+	}))
+	defer srv.Close()
+	config.Set("log:archive:elasticsearch:url", srv.URL)
+	archiver, err := newElasticsearchArchiver()
+	c.Assert(err, check.IsNil)
+	err = archiver.Archive("myapp", []app.Applog{{Message: "msg1"}})
+	c.Assert(err, check.ErrorMatches, `unable to archive some logs to elasticsearch: .*`)
+}
@@ -0,0 +1,28 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package logarchive provides the drivers used to archive app logs before
+// they're removed by the log retention task. Drivers register themselves
+// in the app package and are chosen in the log:archive:driver config.
+package logarchive
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/tsuru/tsuru/app"
+)
+
+// encodeLogs encodes the logs as JSON lines.
+func encodeLogs(logs []app.Applog) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, l := range logs {
+		err := encoder.Encode(l)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
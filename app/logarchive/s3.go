@@ -0,0 +1,94 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logarchive
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app"
+	tsuruNet "github.com/tsuru/tsuru/net"
+)
+
+func init() {
+	app.RegisterLogArchiver("s3", newS3Archiver)
+}
+
+// s3Archiver stores each batch of archived logs as an object in a S3
+// bucket, named after the app and the archival time.
+type s3Archiver struct {
+	endpoint string
+	bucket   string
+	region   string
+	signer   *v4.Signer
+	now      func() time.Time
+}
+
+func newS3Archiver() (app.LogArchiver, error) {
+	bucket, err := config.GetString("log:archive:s3:bucket")
+	if err != nil {
+		return nil, err
+	}
+	region, _ := config.GetString("log:archive:s3:region")
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpoint, _ := config.GetString("log:archive:s3:endpoint")
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+	var creds *credentials.Credentials
+	keyID, _ := config.GetString("log:archive:s3:access-key-id")
+	if keyID != "" {
+		secret, _ := config.GetString("log:archive:s3:secret-access-key")
+		creds = credentials.NewStaticCredentials(keyID, secret, "")
+	} else {
+		creds = credentials.NewEnvCredentials()
+	}
+	return &s3Archiver{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		bucket:   bucket,
+		region:   region,
+		signer:   v4.NewSigner(creds),
+		now:      time.Now,
+	}, nil
+}
+
+func (a *s3Archiver) Archive(appName string, logs []app.Applog) error {
+	data, err := encodeLogs(logs)
+	if err != nil {
+		return err
+	}
+	key := fmt.Sprintf("%s/%s.json", appName, a.now().UTC().Format("20060102T150405.000000000Z"))
+	url := fmt.Sprintf("%s/%s/%s", a.endpoint, a.bucket, key)
+	body := bytes.NewReader(data)
+	req, err := http.NewRequest(http.MethodPut, url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	_, err = a.signer.Sign(req, body, "s3", a.region, a.now())
+	if err != nil {
+		return errors.Wrap(err, "unable to sign s3 request")
+	}
+	rsp, err := tsuruNet.Dial5Full60ClientNoKeepAlive.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "unable to archive logs to s3")
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		data, _ = ioutil.ReadAll(rsp.Body)
+		return errors.Errorf("unable to archive logs to s3 (%d): %s", rsp.StatusCode, data)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logarchive
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestS3ArchiverArchive(c *check.C) {
+	var path, auth, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, http.MethodPut)
+		path = r.URL.Path
+		auth = r.Header.Get("Authorization")
+		data, _ := ioutil.ReadAll(r.Body)
+		body = string(data)
+	}))
+	defer srv.Close()
+	config.Set("log:archive:s3:bucket", "logs")
+	config.Set("log:archive:s3:endpoint", srv.URL)
+	config.Set("log:archive:s3:access-key-id", "key")
+	config.Set("log:archive:s3:secret-access-key", "secret")
+	archiver, err := newS3Archiver()
+	c.Assert(err, check.IsNil)
+	archiver.(*s3Archiver).now = func() time.Time {
+		return time.Date(2018, 3, 1, 10, 0, 0, 0, time.UTC)
+	}
+	err = archiver.Archive("myapp", []app.Applog{
+		{Message: "msg1", AppName: "myapp", Source: "web"},
+		{Message: "msg2", AppName: "myapp", Source: "web"},
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(path, check.Equals, "/logs/myapp/20180301T100000.000000000Z.json")
+	c.Assert(strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=key/20180301/us-east-1/s3/aws4_request"), check.Equals, true)
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+	c.Assert(lines, check.HasLen, 2)
+	c.Assert(lines[0], check.Matches, `\{.*"Message":"msg1".*\}`)
+	c.Assert(lines[1], check.Matches, `\{.*"Message":"msg2".*\}`)
+}
+
+func (s *S) TestS3ArchiverArchiveError(c *check.C) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("access denied"))
+	}))
+	defer srv.Close()
+	config.Set("log:archive:s3:bucket", "logs")
+	config.Set("log:archive:s3:endpoint", srv.URL)
+	config.Set("log:archive:s3:access-key-id", "key")
+	config.Set("log:archive:s3:secret-access-key", "secret")
+	archiver, err := newS3Archiver()
+	c.Assert(err, check.IsNil)
+	err = archiver.Archive("myapp", []app.Applog{{Message: "msg1"}})
+	c.Assert(err, check.ErrorMatches, `unable to archive logs to s3 \(403\): access denied`)
+}
+
+func (s *S) TestNewS3ArchiverWithoutBucket(c *check.C) {
+	_, err := newS3Archiver()
+	c.Assert(err, check.NotNil)
+}
@@ -0,0 +1,236 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package metrics reads the metrics of apps collected by big-sibling. The
+// metrics are sent by big-sibling to logstash, which stores them in
+// elasticsearch, one document type per metric.
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	tsuruNet "github.com/tsuru/tsuru/net"
+)
+
+const (
+	MetricCPU      = "cpu"
+	MetricMemory   = "memory"
+	MetricRequests = "requests"
+
+	defaultIndex    = ".measure-tsuru"
+	defaultWindow   = time.Hour
+	defaultInterval = time.Minute
+	maxPoints       = 1440
+)
+
+var ErrNotConfigured = errors.New("metrics backend is not configured")
+
+// query describes how each metric is stored in elasticsearch. Metrics
+// reported per container are split by unit, the others are aggregated for
+// the whole app.
+type query struct {
+	docType string
+	agg     string
+	perUnit bool
+}
+
+var queries = map[string]query{
+	MetricCPU:      {docType: "cpu_max", agg: "max", perUnit: true},
+	MetricMemory:   {docType: "mem_max", agg: "max", perUnit: true},
+	MetricRequests: {docType: "requests_min", agg: "sum"},
+}
+
+type Options struct {
+	Window   time.Duration
+	Interval time.Duration
+	Metrics  []string
+}
+
+// Point is the value of a metric in the interval starting at Timestamp.
+type Point struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+}
+
+// Series is the list of points of a metric in a unit of the app, Unit is
+// empty for metrics reported for the whole app.
+type Series struct {
+	Metric string  `json:"metric"`
+	Unit   string  `json:"unit,omitempty"`
+	Points []Point `json:"points"`
+}
+
+func (o *Options) validate() error {
+	if o.Window == 0 {
+		o.Window = defaultWindow
+	}
+	if o.Interval == 0 {
+		o.Interval = defaultInterval
+	}
+	if len(o.Metrics) == 0 {
+		o.Metrics = []string{MetricCPU, MetricMemory, MetricRequests}
+	}
+	if o.Window < 0 || o.Interval < time.Second {
+		return &tsuruErrors.ValidationError{Message: "window must be positive and interval must be at least 1s"}
+	}
+	if o.Window < o.Interval {
+		return &tsuruErrors.ValidationError{Message: "window must not be smaller than interval"}
+	}
+	if o.Window/o.Interval > maxPoints {
+		return &tsuruErrors.ValidationError{Message: fmt.Sprintf("window must not be greater than %d intervals", maxPoints)}
+	}
+	for _, m := range o.Metrics {
+		if _, ok := queries[m]; !ok {
+			return &tsuruErrors.ValidationError{Message: fmt.Sprintf("unknown metric %q", m)}
+		}
+	}
+	return nil
+}
+
+// AppMetrics returns the metrics of the app in the window ending now, with
+// one point for each interval.
+func AppMetrics(appName string, opts Options) ([]Series, error) {
+	err := opts.validate()
+	if err != nil {
+		return nil, err
+	}
+	host, _ := config.GetString("metrics:elasticsearch:host")
+	if host == "" {
+		return nil, ErrNotConfigured
+	}
+	index, _ := config.GetString("metrics:elasticsearch:index")
+	if index == "" {
+		index = defaultIndex
+	}
+	baseURL := fmt.Sprintf("%s/%s-*", strings.TrimRight(host, "/"), index)
+	since := time.Now().Add(-opts.Window)
+	var result []Series
+	for _, m := range opts.Metrics {
+		series, err := search(baseURL, appName, m, since, opts.Interval)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, series...)
+	}
+	return result, nil
+}
+
+type valueAggregation struct {
+	Value *float64 `json:"value"`
+}
+
+type bucket struct {
+	Key      interface{}       `json:"key"`
+	Value    *valueAggregation `json:"value"`
+	Interval struct {
+		Buckets []bucket `json:"buckets"`
+	} `json:"interval"`
+}
+
+type searchResult struct {
+	Aggregations struct {
+		Units struct {
+			Buckets []bucket `json:"buckets"`
+		} `json:"units"`
+		Interval struct {
+			Buckets []bucket `json:"buckets"`
+		} `json:"interval"`
+	} `json:"aggregations"`
+}
+
+func search(baseURL, appName, metric string, since time.Time, interval time.Duration) ([]Series, error) {
+	q := queries[metric]
+	histogram := map[string]interface{}{
+		"date_histogram": map[string]interface{}{
+			"field":    "@timestamp",
+			"interval": fmt.Sprintf("%ds", int(interval.Seconds())),
+		},
+		"aggs": map[string]interface{}{
+			"value": map[string]interface{}{
+				q.agg: map[string]interface{}{"field": "value"},
+			},
+		},
+	}
+	aggs := map[string]interface{}{"interval": histogram}
+	if q.perUnit {
+		aggs = map[string]interface{}{
+			"units": map[string]interface{}{
+				"terms": map[string]interface{}{"field": "host.raw", "size": 1000},
+				"aggs":  aggs,
+			},
+		}
+	}
+	body := map[string]interface{}{
+		"size": 0,
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": []interface{}{
+					map[string]interface{}{"term": map[string]interface{}{"app.raw": appName}},
+					map[string]interface{}{"range": map[string]interface{}{
+						"@timestamp": map[string]interface{}{
+							"gte":    since.UnixNano() / int64(time.Millisecond),
+							"format": "epoch_millis",
+						},
+					}},
+				},
+			},
+		},
+		"aggs": aggs,
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s/%s/_search", baseURL, q.docType)
+	rsp, err := tsuruNet.Dial5Full60ClientNoKeepAlive.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to query metrics")
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		data, _ = ioutil.ReadAll(rsp.Body)
+		return nil, errors.Errorf("unable to query metrics (%d): %s", rsp.StatusCode, data)
+	}
+	var result searchResult
+	err = json.NewDecoder(rsp.Body).Decode(&result)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse metrics")
+	}
+	if !q.perUnit {
+		return []Series{toSeries(metric, "", result.Aggregations.Interval.Buckets)}, nil
+	}
+	series := make([]Series, 0, len(result.Aggregations.Units.Buckets))
+	for _, b := range result.Aggregations.Units.Buckets {
+		series = append(series, toSeries(metric, fmt.Sprint(b.Key), b.Interval.Buckets))
+	}
+	sort.Slice(series, func(i, j int) bool {
+		return series[i].Unit < series[j].Unit
+	})
+	return series, nil
+}
+
+func toSeries(metric, unit string, buckets []bucket) Series {
+	s := Series{Metric: metric, Unit: unit, Points: make([]Point, 0, len(buckets))}
+	for _, b := range buckets {
+		ms, ok := b.Key.(float64)
+		if !ok || b.Value == nil || b.Value.Value == nil {
+			continue
+		}
+		s.Points = append(s.Points, Point{
+			Timestamp: time.Unix(0, int64(ms)*int64(time.Millisecond)).UTC(),
+			Value:     *b.Value.Value,
+		})
+	}
+	return s
+}
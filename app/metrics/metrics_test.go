@@ -0,0 +1,120 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tsuru/config"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type S struct{}
+
+var _ = check.Suite(&S{})
+
+func (s *S) TearDownTest(c *check.C) {
+	config.Unset("metrics")
+}
+
+type fakeElasticsearch struct {
+	paths  []string
+	bodies []map[string]interface{}
+}
+
+func (f *fakeElasticsearch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&body)
+	f.paths = append(f.paths, r.URL.Path)
+	f.bodies = append(f.bodies, body)
+	switch r.URL.Path {
+	case "/.measure-tsuru-*/cpu_max/_search":
+		fmt.Fprint(w, `{"aggregations":{"units":{"buckets":[
+{"key":"unit2","interval":{"buckets":[{"key":1514800800000,"value":{"value":10.5}}]}},
+{"key":"unit1","interval":{"buckets":[{"key":1514800800000,"value":{"value":20}},{"key":1514800860000,"value":{"value":null}},{"key":1514800920000,"value":{"value":30}}]}}
+]}}}`)
+	case "/.measure-tsuru-*/requests_min/_search":
+		fmt.Fprint(w, `{"aggregations":{"interval":{"buckets":[{"key":1514800800000,"value":{"value":120}}]}}}`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `index not found`)
+	}
+}
+
+func (s *S) TestAppMetrics(c *check.C) {
+	fake := &fakeElasticsearch{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	config.Set("metrics:elasticsearch:host", srv.URL+"/")
+	result, err := AppMetrics("myapp", Options{
+		Window:   10 * time.Minute,
+		Interval: time.Minute,
+		Metrics:  []string{MetricCPU, MetricRequests},
+	})
+	c.Assert(err, check.IsNil)
+	t0 := time.Date(2018, 1, 1, 10, 0, 0, 0, time.UTC)
+	c.Assert(result, check.DeepEquals, []Series{
+		{Metric: MetricCPU, Unit: "unit1", Points: []Point{
+			{Timestamp: t0, Value: 20},
+			{Timestamp: t0.Add(2 * time.Minute), Value: 30},
+		}},
+		{Metric: MetricCPU, Unit: "unit2", Points: []Point{{Timestamp: t0, Value: 10.5}}},
+		{Metric: MetricRequests, Points: []Point{{Timestamp: t0, Value: 120}}},
+	})
+	c.Assert(fake.paths, check.DeepEquals, []string{
+		"/.measure-tsuru-*/cpu_max/_search",
+		"/.measure-tsuru-*/requests_min/_search",
+	})
+	aggs := fake.bodies[0]["aggs"].(map[string]interface{})
+	c.Assert(aggs["units"], check.NotNil)
+	histogram := aggs["units"].(map[string]interface{})["aggs"].(map[string]interface{})["interval"].(map[string]interface{})
+	c.Assert(histogram["date_histogram"], check.DeepEquals, map[string]interface{}{"field": "@timestamp", "interval": "60s"})
+	filter := fake.bodies[0]["query"].(map[string]interface{})["bool"].(map[string]interface{})["filter"].([]interface{})
+	c.Assert(filter[0], check.DeepEquals, map[string]interface{}{"term": map[string]interface{}{"app.raw": "myapp"}})
+	aggs = fake.bodies[1]["aggs"].(map[string]interface{})
+	c.Assert(aggs["units"], check.IsNil)
+	c.Assert(aggs["interval"], check.NotNil)
+}
+
+func (s *S) TestAppMetricsCustomIndex(c *check.C) {
+	fake := &fakeElasticsearch{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	config.Set("metrics:elasticsearch:host", srv.URL)
+	config.Set("metrics:elasticsearch:index", ".measure-other")
+	_, err := AppMetrics("myapp", Options{Metrics: []string{MetricMemory}})
+	c.Assert(err, check.ErrorMatches, `unable to query metrics \(404\): index not found`)
+	c.Assert(fake.paths, check.DeepEquals, []string{"/.measure-other-*/mem_max/_search"})
+}
+
+func (s *S) TestAppMetricsNotConfigured(c *check.C) {
+	_, err := AppMetrics("myapp", Options{})
+	c.Assert(err, check.Equals, ErrNotConfigured)
+}
+
+func (s *S) TestAppMetricsInvalidOptions(c *check.C) {
+	tests := []struct {
+		opts Options
+		msg  string
+	}{
+		{Options{Interval: time.Millisecond}, "window must be positive and interval must be at least 1s"},
+		{Options{Window: -time.Hour}, "window must be positive and interval must be at least 1s"},
+		{Options{Window: time.Minute, Interval: time.Hour}, "window must not be smaller than interval"},
+		{Options{Window: 48 * time.Hour, Interval: time.Minute}, "window must not be greater than 1440 intervals"},
+		{Options{Metrics: []string{"disk"}}, `unknown metric "disk"`},
+	}
+	for _, tt := range tests {
+		_, err := AppMetrics("myapp", tt.opts)
+		c.Assert(err, check.DeepEquals, &tsuruErrors.ValidationError{Message: tt.msg})
+	}
+}
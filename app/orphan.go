@@ -0,0 +1,245 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"net/url"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/router"
+	"github.com/tsuru/tsuru/service"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	// OrphanInstanceBind is a service instance bound to an app that no
+	// longer exists.
+	OrphanInstanceBind = "instance-bind"
+	// OrphanServiceEnv is an environment variable of an app set by a service
+	// instance that no longer exists or that isn't bound to the app.
+	OrphanServiceEnv = "service-env"
+	// OrphanRoute is a route of an app pointing to an address that isn't
+	// one of the app units.
+	OrphanRoute = "route"
+)
+
+// ErrOrphanChanged is recorded in orphans that were no longer found when
+// repairing them, usually fixed by an operation that was running during the
+// scan.
+var ErrOrphanChanged = errors.New("no longer an orphan, skipped")
+
+// Orphan is an inconsistency between apps, service instances and routers,
+// usually left behind by operations that failed midway. Error holds the
+// reason the orphan couldn't be repaired.
+type Orphan struct {
+	Kind     string
+	App      string
+	Service  string
+	Instance string
+	Router   string
+	Address  string
+	Repaired bool
+	Error    string
+}
+
+// FindOrphans looks for inconsistencies in all apps and service instances,
+// without changing anything.
+func FindOrphans() ([]Orphan, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var apps []App
+	err = conn.Apps().Find(nil).All(&apps)
+	if err != nil {
+		return nil, err
+	}
+	var instances []service.ServiceInstance
+	err = conn.ServiceInstances().Find(nil).All(&instances)
+	if err != nil {
+		return nil, err
+	}
+	appNames := make(map[string]struct{}, len(apps))
+	for _, a := range apps {
+		appNames[a.Name] = struct{}{}
+	}
+	boundApps := make(map[[2]string]map[string]struct{}, len(instances))
+	var orphans []Orphan
+	for _, si := range instances {
+		key := [2]string{si.ServiceName, si.Name}
+		boundApps[key] = make(map[string]struct{}, len(si.Apps))
+		for _, appName := range si.Apps {
+			boundApps[key][appName] = struct{}{}
+			if _, ok := appNames[appName]; !ok {
+				orphans = append(orphans, Orphan{
+					Kind:     OrphanInstanceBind,
+					App:      appName,
+					Service:  si.ServiceName,
+					Instance: si.Name,
+				})
+			}
+		}
+	}
+	for i := range apps {
+		a := &apps[i]
+		seen := make(map[[2]string]struct{})
+		for _, env := range a.ServiceEnvs {
+			key := [2]string{env.ServiceName, env.InstanceName}
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			if _, ok := boundApps[key][a.Name]; !ok {
+				orphans = append(orphans, Orphan{
+					Kind:     OrphanServiceEnv,
+					App:      a.Name,
+					Service:  env.ServiceName,
+					Instance: env.InstanceName,
+				})
+			}
+		}
+		routeOrphans, err := findOrphanRoutes(a)
+		if err != nil {
+			log.Errorf("[orphans] unable to check routes of app %q: %s", a.Name, err)
+			continue
+		}
+		orphans = append(orphans, routeOrphans...)
+	}
+	return orphans, nil
+}
+
+func findOrphanRoutes(a *App) ([]Orphan, error) {
+	addresses, err := a.RoutableAddresses()
+	if err != nil {
+		return nil, err
+	}
+	expected := make(map[string]struct{}, len(addresses))
+	for _, addr := range addresses {
+		expected[addr.Host] = struct{}{}
+	}
+	var orphans []Orphan
+	for _, appRouter := range a.GetRouters() {
+		r, err := router.Get(appRouter.Name)
+		if err != nil {
+			return nil, err
+		}
+		routes, err := r.Routes(a.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, route := range routes {
+			if _, ok := expected[route.Host]; !ok {
+				orphans = append(orphans, Orphan{
+					Kind:    OrphanRoute,
+					App:     a.Name,
+					Router:  appRouter.Name,
+					Address: route.String(),
+				})
+			}
+		}
+	}
+	return orphans, nil
+}
+
+// RepairOrphans fixes the inconsistencies found by FindOrphans: apps that no
+// longer exist are removed from service instances, environment variables of
+// missing service instances are removed from apps, without restarting them,
+// and routes to unknown addresses are removed from routers. Orphans of
+// existing apps are repaired holding the app lock, and each orphan is checked
+// again before being repaired, as it may have been fixed by an operation that
+// was running during the scan. Failures are recorded in each orphan.
+func RepairOrphans(orphans []Orphan) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	for i := range orphans {
+		o := &orphans[i]
+		err = repairOrphan(conn, o)
+		if err != nil {
+			o.Error = err.Error()
+			continue
+		}
+		o.Repaired = true
+	}
+	return nil
+}
+
+func repairOrphan(conn *db.Storage, o *Orphan) error {
+	if o.Kind == OrphanInstanceBind {
+		n, err := conn.Apps().Find(bson.M{"name": o.App}).Count()
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return ErrOrphanChanged
+		}
+		return conn.ServiceInstances().Update(
+			bson.M{"name": o.Instance, "service_name": o.Service},
+			bson.M{"$pull": bson.M{"apps": o.App, "bound_units": bson.M{"appname": o.App}}},
+		)
+	}
+	_, err := GetByName(o.App)
+	if err != nil {
+		return err
+	}
+	locked, err := AcquireApplicationLock(o.App, InternalAppName, "repair orphans")
+	if err != nil {
+		return err
+	}
+	if !locked {
+		return errors.Errorf("app %q is locked by another operation", o.App)
+	}
+	defer ReleaseApplicationLock(o.App)
+	a, err := GetByName(o.App)
+	if err != nil {
+		return err
+	}
+	switch o.Kind {
+	case OrphanServiceEnv:
+		var n int
+		n, err = conn.ServiceInstances().Find(bson.M{"name": o.Instance, "service_name": o.Service, "apps": o.App}).Count()
+		if err != nil {
+			return err
+		}
+		if n > 0 || len(a.InstanceEnvs(o.Service, o.Instance)) == 0 {
+			return ErrOrphanChanged
+		}
+		return a.RemoveInstance(bind.RemoveInstanceArgs{ServiceName: o.Service, InstanceName: o.Instance})
+	case OrphanRoute:
+		var routeOrphans []Orphan
+		routeOrphans, err = findOrphanRoutes(a)
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, ro := range routeOrphans {
+			if ro.Router == o.Router && ro.Address == o.Address {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return ErrOrphanChanged
+		}
+		var r router.Router
+		r, err = router.Get(o.Router)
+		if err != nil {
+			return err
+		}
+		var addr *url.URL
+		addr, err = url.Parse(o.Address)
+		if err != nil {
+			return err
+		}
+		return r.RemoveRoutes(o.App, []*url.URL{addr})
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"net/url"
+
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/router/routertest"
+	"github.com/tsuru/tsuru/service"
+	appTypes "github.com/tsuru/tsuru/types/app"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) createOrphans(c *check.C) *App {
+	a := App{Name: "myapp", TeamOwner: s.team.Name, Routers: []appTypes.AppRouter{{Name: "fake"}}}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	s.provisioner.AddUnits(&a, 1, "web", nil)
+	err = routertest.FakeRouter.AddRoutes(a.Name, []*url.URL{{Scheme: "http", Host: "10.0.0.99:8080"}})
+	c.Assert(err, check.IsNil)
+	err = s.conn.ServiceInstances().Insert(
+		service.ServiceInstance{Name: "db1", ServiceName: "mysql", Apps: []string{"myapp", "removedapp"}},
+		service.ServiceInstance{Name: "db2", ServiceName: "mysql", Apps: []string{}},
+	)
+	c.Assert(err, check.IsNil)
+	a.ServiceEnvs = []bind.ServiceEnvVar{
+		{ServiceName: "mysql", InstanceName: "db1", EnvVar: bind.EnvVar{Name: "DB1_HOST", Value: "db1"}},
+		{ServiceName: "mysql", InstanceName: "db2", EnvVar: bind.EnvVar{Name: "DB2_HOST", Value: "db2"}},
+		{ServiceName: "mysql", InstanceName: "db2", EnvVar: bind.EnvVar{Name: "DB2_PORT", Value: "3306"}},
+		{ServiceName: "redis", InstanceName: "cache", EnvVar: bind.EnvVar{Name: "REDIS_HOST", Value: "cache"}},
+	}
+	err = s.conn.Apps().Update(map[string]string{"name": a.Name}, map[string]interface{}{"$set": map[string]interface{}{"serviceenvs": a.ServiceEnvs}})
+	c.Assert(err, check.IsNil)
+	return &a
+}
+
+func (s *S) TestFindOrphans(c *check.C) {
+	s.createOrphans(c)
+	orphans, err := FindOrphans()
+	c.Assert(err, check.IsNil)
+	c.Assert(orphans, check.DeepEquals, []Orphan{
+		{Kind: OrphanInstanceBind, App: "removedapp", Service: "mysql", Instance: "db1"},
+		{Kind: OrphanServiceEnv, App: "myapp", Service: "mysql", Instance: "db2"},
+		{Kind: OrphanServiceEnv, App: "myapp", Service: "redis", Instance: "cache"},
+		{Kind: OrphanRoute, App: "myapp", Router: "fake", Address: "http://10.0.0.99:8080"},
+	})
+}
+
+func (s *S) TestFindOrphansNone(c *check.C) {
+	a := App{Name: "myapp", TeamOwner: s.team.Name, Routers: []appTypes.AppRouter{{Name: "fake"}}}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	s.provisioner.AddUnits(&a, 1, "web", nil)
+	orphans, err := FindOrphans()
+	c.Assert(err, check.IsNil)
+	c.Assert(orphans, check.HasLen, 0)
+}
+
+func (s *S) TestRepairOrphans(c *check.C) {
+	a := s.createOrphans(c)
+	orphans, err := FindOrphans()
+	c.Assert(err, check.IsNil)
+	err = RepairOrphans(orphans)
+	c.Assert(err, check.IsNil)
+	for _, o := range orphans {
+		c.Assert(o.Repaired, check.Equals, true, check.Commentf("%#v", o))
+		c.Assert(o.Error, check.Equals, "")
+	}
+	var si service.ServiceInstance
+	err = s.conn.ServiceInstances().Find(map[string]string{"name": "db1"}).One(&si)
+	c.Assert(err, check.IsNil)
+	c.Assert(si.Apps, check.DeepEquals, []string{"myapp"})
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.ServiceEnvs, check.DeepEquals, []bind.ServiceEnvVar{
+		{ServiceName: "mysql", InstanceName: "db1", EnvVar: bind.EnvVar{Name: "DB1_HOST", Value: "db1"}},
+	})
+	c.Assert(routertest.FakeRouter.HasRoute(a.Name, "http://10.0.0.99:8080"), check.Equals, false)
+	routes, err := routertest.FakeRouter.Routes(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(routes, check.HasLen, 1)
+	orphans, err = FindOrphans()
+	c.Assert(err, check.IsNil)
+	c.Assert(orphans, check.HasLen, 0)
+}
+
+func (s *S) TestRepairOrphansRecordsFailures(c *check.C) {
+	orphans := []Orphan{{Kind: OrphanServiceEnv, App: "unknown", Service: "mysql", Instance: "db1"}}
+	err := RepairOrphans(orphans)
+	c.Assert(err, check.IsNil)
+	c.Assert(orphans[0].Repaired, check.Equals, false)
+	c.Assert(orphans[0].Error, check.Equals, ErrAppNotFound.Error())
+}
+
+func (s *S) TestRepairOrphansSkipsLockedApps(c *check.C) {
+	a := s.createOrphans(c)
+	orphans, err := FindOrphans()
+	c.Assert(err, check.IsNil)
+	locked, err := AcquireApplicationLock(a.Name, "me", "deploy")
+	c.Assert(err, check.IsNil)
+	c.Assert(locked, check.Equals, true)
+	defer ReleaseApplicationLock(a.Name)
+	err = RepairOrphans(orphans)
+	c.Assert(err, check.IsNil)
+	for _, o := range orphans {
+		if o.App != a.Name {
+			continue
+		}
+		c.Assert(o.Repaired, check.Equals, false)
+		c.Assert(o.Error, check.Equals, `app "myapp" is locked by another operation`)
+	}
+	c.Assert(routertest.FakeRouter.HasRoute(a.Name, "http://10.0.0.99:8080"), check.Equals, true)
+}
+
+func (s *S) TestRepairOrphansSkipsFixedOrphans(c *check.C) {
+	a := s.createOrphans(c)
+	orphans, err := FindOrphans()
+	c.Assert(err, check.IsNil)
+	err = s.conn.ServiceInstances().Update(map[string]string{"name": "db2"}, map[string]interface{}{"$push": map[string]interface{}{"apps": a.Name}})
+	c.Assert(err, check.IsNil)
+	err = routertest.FakeRouter.RemoveRoutes(a.Name, []*url.URL{{Scheme: "http", Host: "10.0.0.99:8080"}})
+	c.Assert(err, check.IsNil)
+	err = RepairOrphans(orphans)
+	c.Assert(err, check.IsNil)
+	for _, o := range orphans {
+		if o.Instance == "db2" || o.Kind == OrphanRoute {
+			c.Assert(o.Repaired, check.Equals, false)
+			c.Assert(o.Error, check.Equals, ErrOrphanChanged.Error())
+		}
+	}
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.InstanceEnvs("mysql", "db2"), check.HasLen, 2)
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/tsuru/tsuru/db"
 	tsuruErrors "github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/storage"
 	appTypes "github.com/tsuru/tsuru/types/app"
 	"github.com/tsuru/tsuru/validation"
@@ -47,12 +48,28 @@ func Platforms(enabledOnly bool) ([]appTypes.Platform, error) {
 	return PlatformService().FindAll()
 }
 
+// parseEnvFormat parses the env injection format supported by a platform.
+func parseEnvFormat(value string) (int, error) {
+	format, err := strconv.Atoi(value)
+	if err != nil || (format != provision.EnvFormatPlain && format != provision.EnvFormatJSON) {
+		return 0, appTypes.ErrInvalidPlatformEnvFormat
+	}
+	return format, nil
+}
+
 // PlatformAdd adds a new platform to tsuru
 func PlatformAdd(opts builder.PlatformOptions) error {
 	p := appTypes.Platform{Name: opts.Name}
 	if err := validatePlatform(p); err != nil {
 		return err
 	}
+	if opts.Args["env-format"] != "" {
+		format, err := parseEnvFormat(opts.Args["env-format"])
+		if err != nil {
+			return err
+		}
+		p.EnvFormat = format
+	}
 	err := PlatformService().Insert(p)
 	if err != nil {
 		return err
@@ -80,7 +97,7 @@ func PlatformUpdate(opts builder.PlatformOptions) error {
 		return err
 	}
 	defer conn.Close()
-	_, err = PlatformService().FindByName(opts.Name)
+	p, err := PlatformService().FindByName(opts.Name)
 	if err != nil {
 		return err
 	}
@@ -98,12 +115,25 @@ func PlatformUpdate(opts builder.PlatformOptions) error {
 			app.SetUpdatePlatform(true)
 		}
 	}
+	var changed bool
 	if opts.Args["disabled"] != "" {
 		disableBool, err := strconv.ParseBool(opts.Args["disabled"])
 		if err != nil {
 			return err
 		}
-		return PlatformService().Update(appTypes.Platform{Name: opts.Name, Disabled: disableBool})
+		p.Disabled = disableBool
+		changed = true
+	}
+	if opts.Args["env-format"] != "" {
+		format, err := parseEnvFormat(opts.Args["env-format"])
+		if err != nil {
+			return err
+		}
+		p.EnvFormat = format
+		changed = true
+	}
+	if changed {
+		return PlatformService().Update(*p)
 	}
 	return nil
 }
@@ -13,6 +13,7 @@ import (
 	"github.com/tsuru/tsuru/builder/fake"
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/db/dbtest"
+	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/repository/repositorytest"
 	appTypes "github.com/tsuru/tsuru/types/app"
 	"gopkg.in/check.v1"
@@ -118,6 +119,26 @@ func (s *PlatformSuite) TestPlatformAdd(c *check.C) {
 	c.Assert(platform.Name, check.Equals, name)
 }
 
+func (s *PlatformSuite) TestPlatformAddWithEnvFormat(c *check.C) {
+	name := "test-platform-add"
+	args := map[string]string{"env-format": "2"}
+	err := PlatformAdd(builder.PlatformOptions{Name: name, Args: args})
+	c.Assert(err, check.IsNil)
+	platform, err := GetPlatform(name)
+	c.Assert(err, check.IsNil)
+	c.Assert(platform.EnvFormat, check.Equals, provision.EnvFormatJSON)
+}
+
+func (s *PlatformSuite) TestPlatformAddInvalidEnvFormat(c *check.C) {
+	for _, format := range []string{"0", "3", "json"} {
+		args := map[string]string{"env-format": format}
+		err := PlatformAdd(builder.PlatformOptions{Name: "test-platform-add", Args: args})
+		c.Assert(err, check.Equals, appTypes.ErrInvalidPlatformEnvFormat)
+	}
+	_, err := GetPlatform("test-platform-add")
+	c.Assert(err, check.Equals, appTypes.ErrInvalidPlatform)
+}
+
 func (s *PlatformSuite) TestPlatformAddValidatesPlatformName(c *check.C) {
 	tt := []struct {
 		name        string
@@ -296,6 +317,25 @@ func (s *PlatformSuite) TestPlatformUpdateDisableFalseWithoutDockerfile(c *check
 	c.Assert(platf.Disabled, check.Equals, false)
 }
 
+func (s *PlatformSuite) TestPlatformUpdateEnvFormat(c *check.C) {
+	name := "test-platform-update"
+	err := PlatformAdd(builder.PlatformOptions{Name: name})
+	c.Assert(err, check.IsNil)
+	args := map[string]string{"env-format": "2"}
+	err = PlatformUpdate(builder.PlatformOptions{Name: name, Args: args})
+	c.Assert(err, check.IsNil)
+	args = map[string]string{"disabled": "true"}
+	err = PlatformUpdate(builder.PlatformOptions{Name: name, Args: args})
+	c.Assert(err, check.IsNil)
+	platf, err := GetPlatform(name)
+	c.Assert(err, check.IsNil)
+	c.Assert(platf.EnvFormat, check.Equals, provision.EnvFormatJSON)
+	c.Assert(platf.Disabled, check.Equals, true)
+	args = map[string]string{"env-format": "9"}
+	err = PlatformUpdate(builder.PlatformOptions{Name: name, Args: args})
+	c.Assert(err, check.Equals, appTypes.ErrInvalidPlatformEnvFormat)
+}
+
 func (s *PlatformSuite) TestPlatformUpdateWithoutName(c *check.C) {
 	err := PlatformUpdate(builder.PlatformOptions{Name: ""})
 	c.Assert(err, check.Equals, appTypes.ErrPlatformNameMissing)
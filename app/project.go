@@ -0,0 +1,425 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/db"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/service"
+	authTypes "github.com/tsuru/tsuru/types/auth"
+	"github.com/tsuru/tsuru/validation"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	ErrProjectNotFound      = errors.New("project not found")
+	ErrProjectAlreadyExists = errors.New("project already exists")
+	ErrInOtherProject       = errors.New("resource already belongs to another project")
+	ErrNotInProject         = errors.New("resource does not belong to the project")
+)
+
+// Project groups related apps and service instances. Environment variables
+// of the project are shared by all its apps and teams granted access to the
+// project are granted access to all its apps.
+type Project struct {
+	Name        string `bson:"_id"`
+	Description string
+	TeamOwner   string
+	Teams       []string
+	Env         map[string]string
+}
+
+// ProjectUsage aggregates the resources used by the apps and instances of a
+// project.
+type ProjectUsage struct {
+	Apps      int
+	Units     int
+	Instances int
+	Memory    int64
+}
+
+// ProjectFilter filters the projects returned by ListProjects, only projects
+// with access for one of Teams are returned when it's not nil.
+type ProjectFilter struct {
+	Teams []string
+}
+
+func (p *Project) validate() error {
+	var verr tsuruErrors.ValidationError
+	if !validation.ValidateName(p.Name) {
+		verr.Add("name", "invalid project name, it must start with a letter and contain only lowercase letters, numbers and dashes")
+	}
+	if p.TeamOwner == "" {
+		verr.Add("teamowner", "team owner is required")
+	}
+	for name := range p.Env {
+		if !envNameRegexp.MatchString(name) {
+			verr.Add("env", fmt.Sprintf("invalid environment variable name %q", name))
+		}
+	}
+	return verr.ToError()
+}
+
+// CreateProject creates a new project, the team owner is always granted
+// access to it.
+func CreateProject(p Project) error {
+	err := p.validate()
+	if err != nil {
+		return err
+	}
+	_, err = auth.TeamService().FindByName(p.TeamOwner)
+	if err != nil {
+		return err
+	}
+	if !containsString(p.Teams, p.TeamOwner) {
+		p.Teams = append(p.Teams, p.TeamOwner)
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Projects().Insert(p)
+	if mgo.IsDup(err) {
+		return ErrProjectAlreadyExists
+	}
+	return err
+}
+
+// GetProject returns the project with the given name.
+func GetProject(name string) (*Project, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var p Project
+	err = conn.Projects().FindId(name).One(&p)
+	if err == mgo.ErrNotFound {
+		return nil, ErrProjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ListProjects returns the projects matching the filter sorted by name.
+func ListProjects(filter *ProjectFilter) ([]Project, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	query := bson.M{}
+	if filter != nil && filter.Teams != nil {
+		query["teams"] = bson.M{"$in": filter.Teams}
+	}
+	var projects []Project
+	err = conn.Projects().Find(query).Sort("_id").All(&projects)
+	if err != nil {
+		return nil, err
+	}
+	return projects, nil
+}
+
+// RemoveProject removes a project. Its apps and instances are kept, only
+// leaving the project along with the project environment variables.
+func RemoveProject(name string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Projects().RemoveId(name)
+	if err == mgo.ErrNotFound {
+		return ErrProjectNotFound
+	}
+	if err != nil {
+		return err
+	}
+	_, err = conn.Apps().UpdateAll(bson.M{"project": name}, bson.M{"$unset": bson.M{"project": "", "projectenvs": ""}})
+	if err != nil {
+		return err
+	}
+	_, err = conn.ServiceInstances().UpdateAll(bson.M{"project": name}, bson.M{"$unset": bson.M{"project": ""}})
+	return err
+}
+
+// Apps returns the apps in the project.
+func (p *Project) Apps() ([]App, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var apps []App
+	err = conn.Apps().Find(bson.M{"project": p.Name}).Sort("name").All(&apps)
+	if err != nil {
+		return nil, err
+	}
+	return apps, nil
+}
+
+// ServiceInstances returns the service instances in the project.
+func (p *Project) ServiceInstances() ([]service.ServiceInstance, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var instances []service.ServiceInstance
+	err = conn.ServiceInstances().Find(bson.M{"project": p.Name}).Sort("service_name", "name").All(&instances)
+	if err != nil {
+		return nil, err
+	}
+	return instances, nil
+}
+
+func (p *Project) envVars() []bind.EnvVar {
+	envs := make([]bind.EnvVar, 0, len(p.Env))
+	for name, value := range p.Env {
+		envs = append(envs, bind.EnvVar{Name: name, Value: value, Public: true})
+	}
+	sort.Slice(envs, func(i, j int) bool { return envs[i].Name < envs[j].Name })
+	return envs
+}
+
+// AddApp moves the app to the project, granting the teams of the project
+// access to the app. The environment variables of the project are applied
+// on the next restart of the app.
+func (p *Project) AddApp(a *App) error {
+	if a.Project == p.Name {
+		return nil
+	}
+	if a.Project != "" {
+		return ErrInOtherProject
+	}
+	for _, teamName := range p.Teams {
+		if containsString(a.Teams, teamName) {
+			continue
+		}
+		err := a.Grant(&authTypes.Team{Name: teamName})
+		if err != nil {
+			return errors.Wrapf(err, "unable to grant team %q access to app %q", teamName, a.Name)
+		}
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	a.Project = p.Name
+	a.ProjectEnvs = p.envVars()
+	return conn.Apps().Update(bson.M{"name": a.Name}, bson.M{"$set": bson.M{"project": a.Project, "projectenvs": a.ProjectEnvs}})
+}
+
+// RemoveApp removes the app from the project. Teams granted access through
+// the project keep their access to the app.
+func (p *Project) RemoveApp(a *App) error {
+	if a.Project != p.Name {
+		return ErrNotInProject
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	a.Project = ""
+	a.ProjectEnvs = nil
+	return conn.Apps().Update(bson.M{"name": a.Name}, bson.M{"$unset": bson.M{"project": "", "projectenvs": ""}})
+}
+
+// AddServiceInstance moves the service instance to the project.
+func (p *Project) AddServiceInstance(si *service.ServiceInstance) error {
+	if si.Project == p.Name {
+		return nil
+	}
+	if si.Project != "" {
+		return ErrInOtherProject
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	si.Project = p.Name
+	return conn.ServiceInstances().Update(bson.M{"service_name": si.ServiceName, "name": si.Name}, bson.M{"$set": bson.M{"project": p.Name}})
+}
+
+// RemoveServiceInstance removes the service instance from the project.
+func (p *Project) RemoveServiceInstance(si *service.ServiceInstance) error {
+	if si.Project != p.Name {
+		return ErrNotInProject
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	si.Project = ""
+	return conn.ServiceInstances().Update(bson.M{"service_name": si.ServiceName, "name": si.Name}, bson.M{"$unset": bson.M{"project": ""}})
+}
+
+// SetEnvs sets and unsets environment variables of the project, propagating
+// them to all apps of the project. Apps must be restarted to use them.
+func (p *Project) SetEnvs(envs map[string]string, unset []string) error {
+	if p.Env == nil {
+		p.Env = make(map[string]string)
+	}
+	for name, value := range envs {
+		p.Env[name] = value
+	}
+	for _, name := range unset {
+		delete(p.Env, name)
+	}
+	err := p.validate()
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Projects().UpdateId(p.Name, bson.M{"$set": bson.M{"env": p.Env}})
+	if err != nil {
+		return err
+	}
+	_, err = conn.Apps().UpdateAll(bson.M{"project": p.Name}, bson.M{"$set": bson.M{"projectenvs": p.envVars()}})
+	return err
+}
+
+// Grant gives the team access to the project and all its apps.
+func (p *Project) Grant(team *authTypes.Team) error {
+	if containsString(p.Teams, team.Name) {
+		return ErrAlreadyHaveAccess
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Projects().UpdateId(p.Name, bson.M{"$addToSet": bson.M{"teams": team.Name}})
+	if err != nil {
+		return err
+	}
+	p.Teams = append(p.Teams, team.Name)
+	apps, err := p.Apps()
+	if err != nil {
+		return err
+	}
+	for i := range apps {
+		if containsString(apps[i].Teams, team.Name) {
+			continue
+		}
+		err = apps[i].Grant(team)
+		if err != nil {
+			return errors.Wrapf(err, "unable to grant access to app %q", apps[i].Name)
+		}
+	}
+	return nil
+}
+
+// Revoke removes the access of the team to the project and to its apps,
+// except for apps owned by the team.
+func (p *Project) Revoke(team *authTypes.Team) error {
+	if team.Name == p.TeamOwner {
+		return ErrCannotOrphanApp
+	}
+	if !containsString(p.Teams, team.Name) {
+		return ErrNoAccess
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Projects().UpdateId(p.Name, bson.M{"$pull": bson.M{"teams": team.Name}})
+	if err != nil {
+		return err
+	}
+	for i, name := range p.Teams {
+		if name == team.Name {
+			p.Teams = append(p.Teams[:i], p.Teams[i+1:]...)
+			break
+		}
+	}
+	apps, err := p.Apps()
+	if err != nil {
+		return err
+	}
+	for i := range apps {
+		if apps[i].TeamOwner == team.Name || !containsString(apps[i].Teams, team.Name) {
+			continue
+		}
+		err = apps[i].Revoke(team)
+		if err != nil && err != ErrCannotOrphanApp {
+			return errors.Wrapf(err, "unable to revoke access to app %q", apps[i].Name)
+		}
+	}
+	return nil
+}
+
+// Usage returns the amount of apps, units, instances and memory used by the
+// project.
+func (p *Project) Usage() (*ProjectUsage, error) {
+	apps, err := p.Apps()
+	if err != nil {
+		return nil, err
+	}
+	instances, err := p.ServiceInstances()
+	if err != nil {
+		return nil, err
+	}
+	usage := ProjectUsage{Apps: len(apps), Instances: len(instances)}
+	for i := range apps {
+		units, err := apps[i].Units()
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to list units of app %q", apps[i].Name)
+		}
+		usage.Units += len(units)
+		usage.Memory += apps[i].Plan.Memory * int64(len(units))
+	}
+	return &usage, nil
+}
+
+// LastLogs returns the last lines of logs of the apps in the project, merged
+// and sorted by date.
+func (p *Project) LastLogs(lines int) ([]Applog, error) {
+	apps, err := p.Apps()
+	if err != nil {
+		return nil, err
+	}
+	logs := []Applog{}
+	for i := range apps {
+		appLogs, err := apps[i].LastLogs(lines, Applog{})
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to get logs of app %q", apps[i].Name)
+		}
+		logs = append(logs, appLogs...)
+	}
+	sort.SliceStable(logs, func(i, j int) bool { return logs[i].Date.Before(logs[j].Date) })
+	if len(logs) > lines {
+		logs = logs[len(logs)-lines:]
+	}
+	return logs, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/auth"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/service"
+	authTypes "github.com/tsuru/tsuru/types/auth"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestCreateProjectInvalid(c *check.C) {
+	err := CreateProject(Project{Name: "Fleet", Env: map[string]string{"LOG-LEVEL": "info"}})
+	c.Assert(err, check.FitsTypeOf, &tsuruErrors.ValidationError{})
+	c.Assert(err, check.ErrorMatches, `(?s).*invalid project name.*team owner is required.*invalid environment variable name "LOG-LEVEL".*`)
+}
+
+func (s *S) TestCreateAndGetProject(c *check.C) {
+	err := CreateProject(Project{Name: "fleet", TeamOwner: s.team.Name})
+	c.Assert(err, check.IsNil)
+	p, err := GetProject("fleet")
+	c.Assert(err, check.IsNil)
+	c.Assert(p, check.DeepEquals, &Project{Name: "fleet", TeamOwner: s.team.Name, Teams: []string{s.team.Name}})
+	err = CreateProject(Project{Name: "fleet", TeamOwner: s.team.Name})
+	c.Assert(err, check.Equals, ErrProjectAlreadyExists)
+	projects, err := ListProjects(&ProjectFilter{Teams: []string{"other"}})
+	c.Assert(err, check.IsNil)
+	c.Assert(projects, check.HasLen, 0)
+	projects, err = ListProjects(&ProjectFilter{Teams: []string{s.team.Name}})
+	c.Assert(err, check.IsNil)
+	c.Assert(projects, check.DeepEquals, []Project{*p})
+}
+
+func (s *S) TestProjectAddAppSharesEnvsAndTeams(c *check.C) {
+	other := authTypes.Team{Name: "other-team"}
+	err := auth.TeamService().Insert(other)
+	c.Assert(err, check.IsNil)
+	err = CreateProject(Project{Name: "fleet", TeamOwner: s.team.Name, Teams: []string{other.Name}, Env: map[string]string{"LOG_LEVEL": "info", "DATABASE_HOST": "project-host"}})
+	c.Assert(err, check.IsNil)
+	p, err := GetProject("fleet")
+	c.Assert(err, check.IsNil)
+	a := App{Name: "myapp", TeamOwner: s.team.Name, Env: map[string]bind.EnvVar{
+		"DATABASE_HOST": {Name: "DATABASE_HOST", Value: "app-host"},
+	}}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = p.AddApp(&a)
+	c.Assert(err, check.IsNil)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Project, check.Equals, "fleet")
+	c.Assert(dbApp.Teams, check.DeepEquals, []string{s.team.Name, other.Name})
+	envs := dbApp.Envs()
+	c.Assert(envs["LOG_LEVEL"].Value, check.Equals, "info")
+	c.Assert(envs["DATABASE_HOST"].Value, check.Equals, "app-host")
+	err = p.SetEnvs(map[string]string{"LOG_LEVEL": "debug"}, nil)
+	c.Assert(err, check.IsNil)
+	dbApp, err = GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Envs()["LOG_LEVEL"].Value, check.Equals, "debug")
+	err = p.RemoveApp(dbApp)
+	c.Assert(err, check.IsNil)
+	dbApp, err = GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Project, check.Equals, "")
+	_, ok := dbApp.Envs()["LOG_LEVEL"]
+	c.Assert(ok, check.Equals, false)
+	err = p.RemoveApp(dbApp)
+	c.Assert(err, check.Equals, ErrNotInProject)
+}
+
+func (s *S) TestProjectAddAppInOtherProject(c *check.C) {
+	a := App{Name: "myapp", TeamOwner: s.team.Name, Project: "other"}
+	p := Project{Name: "fleet", TeamOwner: s.team.Name}
+	c.Assert(p.AddApp(&a), check.Equals, ErrInOtherProject)
+}
+
+func (s *S) TestProjectGrantAndRevoke(c *check.C) {
+	other := authTypes.Team{Name: "other-team"}
+	err := auth.TeamService().Insert(other)
+	c.Assert(err, check.IsNil)
+	err = CreateProject(Project{Name: "fleet", TeamOwner: s.team.Name})
+	c.Assert(err, check.IsNil)
+	p, err := GetProject("fleet")
+	c.Assert(err, check.IsNil)
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = p.AddApp(&a)
+	c.Assert(err, check.IsNil)
+	err = p.Grant(&other)
+	c.Assert(err, check.IsNil)
+	c.Assert(p.Grant(&other), check.Equals, ErrAlreadyHaveAccess)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Teams, check.DeepEquals, []string{s.team.Name, other.Name})
+	err = p.Revoke(&other)
+	c.Assert(err, check.IsNil)
+	dbApp, err = GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Teams, check.DeepEquals, []string{s.team.Name})
+	c.Assert(p.Revoke(&s.team), check.Equals, ErrCannotOrphanApp)
+	dbProject, err := GetProject("fleet")
+	c.Assert(err, check.IsNil)
+	c.Assert(dbProject.Teams, check.DeepEquals, []string{s.team.Name})
+}
+
+func (s *S) TestRemoveProjectKeepsResources(c *check.C) {
+	err := CreateProject(Project{Name: "fleet", TeamOwner: s.team.Name, Env: map[string]string{"LOG_LEVEL": "info"}})
+	c.Assert(err, check.IsNil)
+	p, err := GetProject("fleet")
+	c.Assert(err, check.IsNil)
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = p.AddApp(&a)
+	c.Assert(err, check.IsNil)
+	si := service.ServiceInstance{Name: "mydb", ServiceName: "mysql", Teams: []string{s.team.Name}}
+	err = s.conn.ServiceInstances().Insert(si)
+	c.Assert(err, check.IsNil)
+	err = p.AddServiceInstance(&si)
+	c.Assert(err, check.IsNil)
+	instances, err := p.ServiceInstances()
+	c.Assert(err, check.IsNil)
+	c.Assert(instances, check.HasLen, 1)
+	err = RemoveProject("fleet")
+	c.Assert(err, check.IsNil)
+	c.Assert(RemoveProject("fleet"), check.Equals, ErrProjectNotFound)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Project, check.Equals, "")
+	c.Assert(dbApp.ProjectEnvs, check.HasLen, 0)
+	dbInstance, err := service.GetServiceInstance("mysql", "mydb")
+	c.Assert(err, check.IsNil)
+	c.Assert(dbInstance.Project, check.Equals, "")
+}
+
+func (s *S) TestProjectUsage(c *check.C) {
+	err := CreateProject(Project{Name: "fleet", TeamOwner: s.team.Name})
+	c.Assert(err, check.IsNil)
+	p, err := GetProject("fleet")
+	c.Assert(err, check.IsNil)
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	s.provisioner.AddUnits(&a, 2, "web", nil)
+	err = p.AddApp(&a)
+	c.Assert(err, check.IsNil)
+	usage, err := p.Usage()
+	c.Assert(err, check.IsNil)
+	c.Assert(usage, check.DeepEquals, &ProjectUsage{Apps: 1, Units: 2, Memory: 2 * a.Plan.Memory})
+}
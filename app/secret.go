@@ -0,0 +1,103 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/secret"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// SetSecrets saves a list of secret environment variables in the app. Values
+// are encrypted before being stored and are only decrypted when injected in
+// the units of the app.
+func (app *App) SetSecrets(setEnvs bind.SetEnvArgs) error {
+	if len(setEnvs.Envs) == 0 {
+		return nil
+	}
+	if setEnvs.Writer != nil {
+		fmt.Fprintf(setEnvs.Writer, "---- Setting %d new secrets ----\n", len(setEnvs.Envs))
+	}
+	secrets := make(map[string]string, len(app.Secrets)+len(setEnvs.Envs))
+	for name, value := range app.Secrets {
+		secrets[name] = value
+	}
+	for _, env := range setEnvs.Envs {
+		encrypted, err := secret.Encrypt(env.Value)
+		if err != nil {
+			return err
+		}
+		secrets[env.Name] = encrypted
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Apps().Update(bson.M{"name": app.Name}, bson.M{"$set": bson.M{"secrets": secrets}})
+	if err != nil {
+		return err
+	}
+	app.Secrets = secrets
+	if setEnvs.ShouldRestart {
+		return app.RestartIfUnits(setEnvs.Writer)
+	}
+	return nil
+}
+
+// UnsetSecrets removes secret environment variables from an app.
+func (app *App) UnsetSecrets(unsetEnvs bind.UnsetEnvArgs) error {
+	if len(unsetEnvs.VariableNames) == 0 {
+		return nil
+	}
+	if unsetEnvs.Writer != nil {
+		fmt.Fprintf(unsetEnvs.Writer, "---- Unsetting %d secrets ----\n", len(unsetEnvs.VariableNames))
+	}
+	for _, name := range unsetEnvs.VariableNames {
+		delete(app.Secrets, name)
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Apps().Update(bson.M{"name": app.Name}, bson.M{"$set": bson.M{"secrets": app.Secrets}})
+	if err != nil {
+		return err
+	}
+	if unsetEnvs.ShouldRestart {
+		return app.RestartIfUnits(unsetEnvs.Writer)
+	}
+	return nil
+}
+
+// SecretNames returns the sorted names of the secret environment variables
+// of the app. Secret values are never exposed by the API.
+func (app *App) SecretNames() []string {
+	names := make([]string, 0, len(app.Secrets))
+	for name := range app.Secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (app *App) secretEnvs() []bind.EnvVar {
+	envs := make([]bind.EnvVar, 0, len(app.Secrets))
+	for _, name := range app.SecretNames() {
+		value, err := secret.Decrypt(app.Secrets[name])
+		if err != nil {
+			log.Errorf("[secrets] unable to decrypt secret %q of app %q: %s", name, app.Name, err)
+			continue
+		}
+		envs = append(envs, bind.EnvVar{Name: name, Value: value})
+	}
+	return envs
+}
@@ -0,0 +1,95 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"encoding/base64"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/secret"
+	"gopkg.in/check.v1"
+)
+
+func setSecretsKey() func() {
+	config.Set("secrets:key", base64.StdEncoding.EncodeToString([]byte("0123456789abcdef")))
+	return func() { config.Unset("secrets:key") }
+}
+
+func (s *S) TestSetSecrets(c *check.C) {
+	defer setSecretsKey()()
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	s.provisioner.AddUnits(&a, 1, "web", nil)
+	err = a.SetSecrets(bind.SetEnvArgs{
+		Envs:          []bind.EnvVar{{Name: "DATABASE_PASSWORD", Value: "123"}},
+		ShouldRestart: true,
+	})
+	c.Assert(err, check.IsNil)
+	newApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(newApp.SecretNames(), check.DeepEquals, []string{"DATABASE_PASSWORD"})
+	c.Assert(newApp.Secrets["DATABASE_PASSWORD"], check.Not(check.Equals), "123")
+	value, err := secret.Decrypt(newApp.Secrets["DATABASE_PASSWORD"])
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, "123")
+	c.Assert(newApp.Env, check.HasLen, 0)
+	c.Assert(s.provisioner.Restarts(&a, ""), check.Equals, 1)
+}
+
+func (s *S) TestSetSecretsWithoutKey(c *check.C) {
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetSecrets(bind.SetEnvArgs{
+		Envs: []bind.EnvVar{{Name: "DATABASE_PASSWORD", Value: "123"}},
+	})
+	c.Assert(err, check.Equals, secret.ErrKeyNotConfigured)
+	newApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(newApp.Secrets, check.HasLen, 0)
+}
+
+func (s *S) TestUnsetSecrets(c *check.C) {
+	defer setSecretsKey()()
+	a := App{Name: "myapp", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.SetSecrets(bind.SetEnvArgs{
+		Envs: []bind.EnvVar{
+			{Name: "DATABASE_PASSWORD", Value: "123"},
+			{Name: "API_KEY", Value: "abc"},
+		},
+	})
+	c.Assert(err, check.IsNil)
+	err = a.UnsetSecrets(bind.UnsetEnvArgs{VariableNames: []string{"API_KEY"}})
+	c.Assert(err, check.IsNil)
+	newApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(newApp.SecretNames(), check.DeepEquals, []string{"DATABASE_PASSWORD"})
+}
+
+func (s *S) TestEnvsWithSecrets(c *check.C) {
+	defer setSecretsKey()()
+	encrypted, err := secret.Encrypt("123")
+	c.Assert(err, check.IsNil)
+	a := App{
+		Name: "time",
+		Env: map[string]bind.EnvVar{
+			"DATABASE_HOST": {Name: "DATABASE_HOST", Value: "localhost", Public: true},
+		},
+		Secrets: map[string]string{
+			"DATABASE_PASSWORD": encrypted,
+			"BROKEN":            "invalid",
+		},
+	}
+	expected := map[string]bind.EnvVar{
+		"DATABASE_HOST":     {Name: "DATABASE_HOST", Value: "localhost", Public: true},
+		"DATABASE_PASSWORD": {Name: "DATABASE_PASSWORD", Value: "123"},
+		"TSURU_SERVICES":    {Name: "TSURU_SERVICES", Value: "{}"},
+	}
+	c.Assert(a.Envs(), check.DeepEquals, expected)
+}
@@ -0,0 +1,245 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api/shutdown"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/router"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	appPurgeRunID = "app-purge"
+
+	maxAppPurgeInterval = time.Hour
+)
+
+// DeleteGracePeriod returns for how long removed apps are kept before being
+// permanently deleted, zero meaning apps are deleted right away.
+func DeleteGracePeriod() time.Duration {
+	seconds, err := config.GetInt("apps:delete-grace-period")
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// SoftDelete marks the app as deleted and stops its units. The app metadata,
+// env, deploy history, images, routes and repository are kept until the
+// grace period ends, when the app is permanently deleted, and the app may be
+// restored until then.
+func SoftDelete(app *App, user string, w io.Writer) error {
+	isSwapped, swappedWith, err := router.IsSwapped(app.GetName())
+	if err != nil {
+		return errors.Wrap(err, "unable to check if app is swapped")
+	}
+	if isSwapped {
+		return errors.Errorf("application is swapped with %q, cannot remove it", swappedWith)
+	}
+	if w == nil {
+		w = ioutil.Discard
+	}
+	err = app.Stop(w, "")
+	if err != nil {
+		fmt.Fprintf(w, "Unable to stop units: %s\n", err)
+	}
+	now := time.Now().UTC()
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Apps().Update(
+		bson.M{"name": app.Name, "deletedat": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"deletedat": now, "deletedby": user}},
+	)
+	if err == mgo.ErrNotFound {
+		return ErrAppNotFound
+	}
+	if err != nil {
+		return err
+	}
+	app.DeletedAt = now
+	app.DeletedBy = user
+	deadline := now.Add(DeleteGracePeriod())
+	fmt.Fprintf(w, "---- Application %q removed, it may be restored until %s.\n", app.Name, deadline.Format(time.RFC3339))
+	return nil
+}
+
+// GetDeletedByName returns an app pending permanent removal.
+func GetDeletedByName(name string) (*App, error) {
+	var app App
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	err = conn.Apps().Find(bson.M{"name": name, "deletedat": bson.M{"$exists": true}}).One(&app)
+	if err == mgo.ErrNotFound {
+		return nil, ErrAppNotFound
+	}
+	return &app, err
+}
+
+// Restore undoes the removal of an app still in the grace period, starting
+// its units again.
+func Restore(app *App, w io.Writer) error {
+	if w == nil {
+		w = ioutil.Discard
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Apps().Update(
+		bson.M{"name": app.Name, "deletedat": bson.M{"$exists": true}},
+		bson.M{"$unset": bson.M{"deletedat": "", "deletedby": ""}},
+	)
+	if err == mgo.ErrNotFound {
+		return ErrAppNotFound
+	}
+	if err != nil {
+		return err
+	}
+	app.DeletedAt = time.Time{}
+	app.DeletedBy = ""
+	fmt.Fprintf(w, "---- Application %q restored.\n", app.Name)
+	return app.Start(w, "")
+}
+
+// InitializeDeletedAppPurge starts the task permanently deleting the apps
+// whose grace period is over, when apps:delete-grace-period is configured.
+// onPurge is called after each app is deleted, removing data kept outside of
+// this package.
+func InitializeDeletedAppPurge(onPurge func(appName string) error) error {
+	grace := DeleteGracePeriod()
+	if grace <= 0 {
+		return nil
+	}
+	interval := grace
+	if interval > maxAppPurgeInterval {
+		interval = maxAppPurgeInterval
+	}
+	p := &deletedAppPurge{
+		interval: interval,
+		onPurge:  onPurge,
+		shutdown: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go p.loop()
+	shutdown.Register(p)
+	return nil
+}
+
+type deletedAppPurge struct {
+	interval time.Duration
+	onPurge  func(string) error
+	shutdown chan struct{}
+	done     chan struct{}
+}
+
+func (p *deletedAppPurge) loop() {
+	defer close(p.done)
+	for {
+		now := time.Now().UTC()
+		claimed, err := claimAppPurgeRun(now, p.interval)
+		if err != nil {
+			log.Errorf("[app-purge] error claiming run: %s", err)
+		}
+		if claimed {
+			err = purgeDeletedApps(now.Add(-DeleteGracePeriod()), p.onPurge)
+			if err != nil {
+				log.Errorf("[app-purge] error purging deleted apps: %s", err)
+			}
+		}
+		select {
+		case <-time.After(p.interval):
+		case <-p.shutdown:
+			return
+		}
+	}
+}
+
+func (p *deletedAppPurge) Shutdown(ctx context.Context) error {
+	close(p.shutdown)
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
+
+func (p *deletedAppPurge) String() string {
+	return "deleted app purge"
+}
+
+// claimAppPurgeRun sets the time of the next purge, so only one of the tsuru
+// API instances deletes apps in each interval.
+func claimAppPurgeRun(now time.Time, interval time.Duration) (bool, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	return claimRun(conn.AppPurgeRuns(), appPurgeRunID, now, interval)
+}
+
+// purgeDeletedApps permanently deletes the apps removed before the given
+// time.
+func purgeDeletedApps(before time.Time, onPurge func(string) error) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	var apps []App
+	err = conn.Apps().Find(bson.M{"deletedat": bson.M{"$lte": before}}).All(&apps)
+	conn.Close()
+	if err != nil {
+		return err
+	}
+	for i := range apps {
+		err = purgeApp(&apps[i], onPurge)
+		if err != nil {
+			log.Errorf("[app-purge] error deleting app %q: %s", apps[i].Name, err)
+		}
+	}
+	return nil
+}
+
+func purgeApp(app *App, onPurge func(string) error) (err error) {
+	evt, err := event.NewInternal(&event.Opts{
+		Target:       event.Target{Type: event.TargetTypeApp, Value: app.Name},
+		InternalKind: "app-purge",
+		Allowed: event.Allowed(permission.PermAppReadEvents,
+			permission.Context(permission.CtxApp, app.Name)),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = Delete(app, evt)
+	if err != nil {
+		return err
+	}
+	if onPurge != nil {
+		err = onPurge(app.Name)
+	}
+	return err
+}
@@ -0,0 +1,92 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestDeleteGracePeriod(c *check.C) {
+	c.Assert(DeleteGracePeriod(), check.Equals, time.Duration(0))
+	config.Set("apps:delete-grace-period", 3600)
+	defer config.Unset("apps:delete-grace-period")
+	c.Assert(DeleteGracePeriod(), check.Equals, time.Hour)
+}
+
+func (s *S) TestSoftDeleteAndRestore(c *check.C) {
+	config.Set("apps:delete-grace-period", 3600)
+	defer config.Unset("apps:delete-grace-period")
+	a := App{Name: "ritual", Platform: "ruby", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	var buf bytes.Buffer
+	err = SoftDelete(&a, s.user.Email, &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Matches, `(?s).*Application "ritual" removed, it may be restored until .*`)
+	_, err = GetByName(a.Name)
+	c.Assert(err, check.Equals, ErrAppNotFound)
+	apps, err := List(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(apps, check.HasLen, 0)
+	c.Assert(routertest.FakeRouter.HasBackend(a.Name), check.Equals, true)
+	c.Assert(s.provisioner.Provisioned(&a), check.Equals, true)
+	deleted, err := GetDeletedByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(deleted.DeletedBy, check.Equals, s.user.Email)
+	c.Assert(deleted.DeletedAt.IsZero(), check.Equals, false)
+	err = Restore(deleted, nil)
+	c.Assert(err, check.IsNil)
+	restored, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(restored.DeletedBy, check.Equals, "")
+	c.Assert(restored.DeletedAt.IsZero(), check.Equals, true)
+	_, err = GetDeletedByName(a.Name)
+	c.Assert(err, check.Equals, ErrAppNotFound)
+}
+
+func (s *S) TestRestoreNotDeleted(c *check.C) {
+	a := App{Name: "ritual", Platform: "ruby", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = Restore(&a, nil)
+	c.Assert(err, check.Equals, ErrAppNotFound)
+}
+
+func (s *S) TestPurgeDeletedApps(c *check.C) {
+	config.Set("apps:delete-grace-period", 3600)
+	defer config.Unset("apps:delete-grace-period")
+	old := App{Name: "old", Platform: "ruby", TeamOwner: s.team.Name}
+	err := CreateApp(&old, s.user)
+	c.Assert(err, check.IsNil)
+	err = SoftDelete(&old, s.user.Email, nil)
+	c.Assert(err, check.IsNil)
+	recent := App{Name: "recent", Platform: "ruby", TeamOwner: s.team.Name}
+	err = CreateApp(&recent, s.user)
+	c.Assert(err, check.IsNil)
+	err = SoftDelete(&recent, s.user.Email, nil)
+	c.Assert(err, check.IsNil)
+	err = s.conn.Apps().Update(bson.M{"name": "old"}, bson.M{
+		"$set": bson.M{"deletedat": time.Now().UTC().Add(-2 * time.Hour)},
+	})
+	c.Assert(err, check.IsNil)
+	var purged []string
+	err = purgeDeletedApps(time.Now().UTC().Add(-time.Hour), func(name string) error {
+		purged = append(purged, name)
+		return nil
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(purged, check.DeepEquals, []string{"old"})
+	_, err = GetDeletedByName("old")
+	c.Assert(err, check.Equals, ErrAppNotFound)
+	c.Assert(routertest.FakeRouter.HasBackend("old"), check.Equals, false)
+	_, err = GetDeletedByName("recent")
+	c.Assert(err, check.IsNil)
+}
@@ -0,0 +1,189 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/db"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/provision/pool"
+	"gopkg.in/mgo.v2"
+)
+
+var ErrTeamPolicyNotFound = errors.New("team policy not found")
+
+// TeamPolicy holds the defaults and restrictions applied to apps created by
+// a team. DefaultPool and DefaultPlan are used when the app is created
+// without a pool or a plan, AllowedPlans and AllowedPlatforms, when not
+// empty, restrict the plans and platforms the team may use.
+type TeamPolicy struct {
+	Team             string `bson:"_id"`
+	DefaultPool      string
+	DefaultPlan      string
+	AllowedPlans     []string
+	AllowedPlatforms []string
+}
+
+func (p *TeamPolicy) validate() error {
+	_, err := auth.GetTeam(p.Team)
+	if err != nil {
+		return err
+	}
+	var verr tsuruErrors.ValidationError
+	if p.DefaultPool != "" {
+		dbPool, err := pool.GetPoolByName(p.DefaultPool)
+		if err != nil {
+			verr.Addf("defaultPool", "pool %q not found", p.DefaultPool)
+		} else if !poolHasTeam(dbPool, p.Team) {
+			verr.Addf("defaultPool", "team %q has no access to pool %q", p.Team, p.DefaultPool)
+		}
+	}
+	if p.DefaultPlan != "" {
+		if _, err := findPlanByName(p.DefaultPlan); err != nil {
+			verr.Addf("defaultPlan", "plan %q not found", p.DefaultPlan)
+		} else if !p.allows(p.AllowedPlans, p.DefaultPlan) {
+			verr.Addf("defaultPlan", "plan %q is not in the allowed plans", p.DefaultPlan)
+		}
+	}
+	for _, name := range p.AllowedPlans {
+		if _, err := findPlanByName(name); err != nil {
+			verr.Addf("allowedPlans", "plan %q not found", name)
+		}
+	}
+	for _, name := range p.AllowedPlatforms {
+		if _, err := GetPlatform(name); err != nil {
+			verr.Addf("allowedPlatforms", "platform %q not found", name)
+		}
+	}
+	return verr.ToError()
+}
+
+func poolHasTeam(p *pool.Pool, team string) bool {
+	teams, err := p.GetTeams()
+	if err != nil {
+		return false
+	}
+	for _, t := range teams {
+		if t == team {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *TeamPolicy) allows(allowed []string, name string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if a == name {
+			return true
+		}
+	}
+	return false
+}
+
+// apply fills the pool and plan of the app with the defaults of the policy
+// and checks whether its plan and platform are allowed.
+func (p *TeamPolicy) apply(app *App) error {
+	if app.Pool == "" {
+		app.Pool = p.DefaultPool
+	}
+	if app.Plan.Name == "" {
+		app.Plan.Name = p.DefaultPlan
+	}
+	var verr tsuruErrors.ValidationError
+	if app.Plan.Name != "" && !p.allows(p.AllowedPlans, app.Plan.Name) {
+		verr.Addf("plan", "plan %q is not allowed for team %q, allowed plans: %s", app.Plan.Name, p.Team, strings.Join(p.AllowedPlans, ", "))
+	}
+	if app.Platform != "" && !p.allows(p.AllowedPlatforms, app.Platform) {
+		verr.Addf("platform", "platform %q is not allowed for team %q, allowed platforms: %s", app.Platform, p.Team, strings.Join(p.AllowedPlatforms, ", "))
+	}
+	return verr.ToError()
+}
+
+// SaveTeamPolicy creates or updates the policy of a team.
+func SaveTeamPolicy(p TeamPolicy) error {
+	err := p.validate()
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.TeamPolicies().UpsertId(p.Team, p)
+	return err
+}
+
+// RemoveTeamPolicy removes the policy of a team, apps already created are
+// kept as they are.
+func RemoveTeamPolicy(team string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.TeamPolicies().RemoveId(team)
+	if err == mgo.ErrNotFound {
+		return ErrTeamPolicyNotFound
+	}
+	return err
+}
+
+// GetTeamPolicy returns the policy of a team.
+func GetTeamPolicy(team string) (*TeamPolicy, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var p TeamPolicy
+	err = conn.TeamPolicies().FindId(team).One(&p)
+	if err == mgo.ErrNotFound {
+		return nil, ErrTeamPolicyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// RenameTeamPolicy moves the policy of a team to its new name.
+func RenameTeamPolicy(oldName, newName string) error {
+	p, err := GetTeamPolicy(oldName)
+	if err == ErrTeamPolicyNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	p.Team = newName
+	err = conn.TeamPolicies().Insert(p)
+	if err != nil {
+		return err
+	}
+	return conn.TeamPolicies().RemoveId(oldName)
+}
+
+func applyTeamPolicy(app *App) error {
+	p, err := GetTeamPolicy(app.TeamOwner)
+	if err == ErrTeamPolicyNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return p.apply(app)
+}
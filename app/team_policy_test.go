@@ -0,0 +1,124 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/provision/pool"
+	appTypes "github.com/tsuru/tsuru/types/app"
+	authTypes "github.com/tsuru/tsuru/types/auth"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestTeamPolicyApply(c *check.C) {
+	p := TeamPolicy{Team: "team1", DefaultPool: "pool2", DefaultPlan: "small"}
+	a := App{Name: "myapp"}
+	err := p.apply(&a)
+	c.Assert(err, check.IsNil)
+	c.Assert(a.Pool, check.Equals, "pool2")
+	c.Assert(a.Plan.Name, check.Equals, "small")
+	a = App{Name: "myapp", Pool: "pool3", Plan: appTypes.Plan{Name: "large"}}
+	err = p.apply(&a)
+	c.Assert(err, check.IsNil)
+	c.Assert(a.Pool, check.Equals, "pool3")
+	c.Assert(a.Plan.Name, check.Equals, "large")
+}
+
+func (s *S) TestTeamPolicyApplyRestrictions(c *check.C) {
+	p := TeamPolicy{Team: "team1", AllowedPlans: []string{"small"}, AllowedPlatforms: []string{"python", "go"}}
+	a := App{Name: "myapp", Platform: "python", Plan: appTypes.Plan{Name: "small"}}
+	c.Assert(p.apply(&a), check.IsNil)
+	a = App{Name: "myapp", Platform: "ruby", Plan: appTypes.Plan{Name: "large"}}
+	err := p.apply(&a)
+	c.Assert(err, check.FitsTypeOf, &tsuruErrors.ValidationError{})
+	verr := err.(*tsuruErrors.ValidationError)
+	c.Assert(verr.Fields, check.DeepEquals, []tsuruErrors.FieldError{
+		{Field: "plan", Message: `plan "large" is not allowed for team "team1", allowed plans: small`},
+		{Field: "platform", Message: `platform "ruby" is not allowed for team "team1", allowed platforms: python, go`},
+	})
+}
+
+func (s *S) TestSaveTeamPolicy(c *check.C) {
+	err := pool.AddPool(pool.AddPoolOptions{Name: "pool2", Public: true})
+	c.Assert(err, check.IsNil)
+	p := TeamPolicy{
+		Team:             s.team.Name,
+		DefaultPool:      "pool2",
+		DefaultPlan:      s.defaultPlan.Name,
+		AllowedPlatforms: []string{"python"},
+	}
+	err = SaveTeamPolicy(p)
+	c.Assert(err, check.IsNil)
+	dbPolicy, err := GetTeamPolicy(s.team.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(*dbPolicy, check.DeepEquals, p)
+	err = RemoveTeamPolicy(s.team.Name)
+	c.Assert(err, check.IsNil)
+	_, err = GetTeamPolicy(s.team.Name)
+	c.Assert(err, check.Equals, ErrTeamPolicyNotFound)
+	err = RemoveTeamPolicy(s.team.Name)
+	c.Assert(err, check.Equals, ErrTeamPolicyNotFound)
+}
+
+func (s *S) TestSaveTeamPolicyInvalid(c *check.C) {
+	err := SaveTeamPolicy(TeamPolicy{Team: "unknown"})
+	c.Assert(err, check.Equals, authTypes.ErrTeamNotFound)
+	err = SaveTeamPolicy(TeamPolicy{
+		Team:             s.team.Name,
+		DefaultPool:      "unknown",
+		DefaultPlan:      s.defaultPlan.Name,
+		AllowedPlans:     []string{"other-plan"},
+		AllowedPlatforms: []string{"cobol"},
+	})
+	c.Assert(err, check.FitsTypeOf, &tsuruErrors.ValidationError{})
+	verr := err.(*tsuruErrors.ValidationError)
+	c.Assert(verr.Fields, check.DeepEquals, []tsuruErrors.FieldError{
+		{Field: "defaultPool", Message: `pool "unknown" not found`},
+		{Field: "defaultPlan", Message: `plan "default-plan" is not in the allowed plans`},
+		{Field: "allowedPlans", Message: `plan "other-plan" not found`},
+		{Field: "allowedPlatforms", Message: `platform "cobol" not found`},
+	})
+}
+
+func (s *S) TestRenameTeamPolicy(c *check.C) {
+	err := SaveTeamPolicy(TeamPolicy{Team: s.team.Name, AllowedPlatforms: []string{"python"}})
+	c.Assert(err, check.IsNil)
+	err = RenameTeamPolicy(s.team.Name, "newteam")
+	c.Assert(err, check.IsNil)
+	_, err = GetTeamPolicy(s.team.Name)
+	c.Assert(err, check.Equals, ErrTeamPolicyNotFound)
+	p, err := GetTeamPolicy("newteam")
+	c.Assert(err, check.IsNil)
+	c.Assert(p.AllowedPlatforms, check.DeepEquals, []string{"python"})
+	err = RenameTeamPolicy("otherteam", "anotherteam")
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestCreateAppWithTeamPolicy(c *check.C) {
+	err := pool.AddPool(pool.AddPoolOptions{Name: "pool2", Public: true})
+	c.Assert(err, check.IsNil)
+	myPlan := appTypes.Plan{Name: "myplan", Memory: 4194304, Swap: 2, CpuShare: 3}
+	err = SavePlan(myPlan)
+	c.Assert(err, check.IsNil)
+	err = SaveTeamPolicy(TeamPolicy{Team: s.team.Name, DefaultPool: "pool2", DefaultPlan: "myplan"})
+	c.Assert(err, check.IsNil)
+	a := App{Name: "appname", Platform: "python", TeamOwner: s.team.Name}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Pool, check.Equals, "pool2")
+	c.Assert(dbApp.Plan, check.DeepEquals, myPlan)
+}
+
+func (s *S) TestCreateAppWithTeamPolicyPlatformNotAllowed(c *check.C) {
+	err := SaveTeamPolicy(TeamPolicy{Team: s.team.Name, AllowedPlatforms: []string{"heimerdinger"}})
+	c.Assert(err, check.IsNil)
+	a := App{Name: "appname", Platform: "python", TeamOwner: s.team.Name}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.ErrorMatches, `platform "python" is not allowed for team "tsuruteam", allowed platforms: heimerdinger`)
+	_, err = GetByName(a.Name)
+	c.Assert(err, check.Equals, ErrAppNotFound)
+}
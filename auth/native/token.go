@@ -16,6 +16,7 @@ import (
 	"github.com/tsuru/tsuru/db"
 	tsuruErrors "github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/secret"
 	"github.com/tsuru/tsuru/validation"
 	"golang.org/x/crypto/bcrypt"
 	"gopkg.in/mgo.v2"
@@ -43,6 +44,15 @@ type Token struct {
 	AppName   string        `json:"app"`
 }
 
+// GetBSON hashes the token before storing it, when secrets:encrypt-storage
+// is enabled. Tokens are only looked up by value, so the stored form is never
+// read back.
+func (t Token) GetBSON() (interface{}, error) {
+	type plain Token
+	t.Token = secret.Hash(t.Token)
+	return plain(t), nil
+}
+
 func (t *Token) GetValue() string {
 	return t.Token
 }
@@ -67,6 +77,13 @@ func (t *Token) Permissions() ([]permission.Permission, error) {
 	return auth.BaseTokenPermission(t)
 }
 
+func (t *Token) GetExpireTime() time.Time {
+	if t.Expires <= 0 {
+		return time.Time{}
+	}
+	return t.Creation.Add(t.Expires)
+}
+
 func loadConfig() error {
 	if cost == 0 && tokenExpire == 0 {
 		var err error
@@ -201,7 +218,7 @@ func getToken(header string) (*Token, error) {
 	if err != nil {
 		return nil, err
 	}
-	err = conn.Tokens().Find(bson.M{"token": token}).One(&t)
+	err = conn.Tokens().Find(bson.M{"token": bson.M{"$in": secret.LookupValues(token)}}).One(&t)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			return nil, auth.ErrInvalidToken
@@ -211,6 +228,7 @@ func getToken(header string) (*Token, error) {
 	if t.Expires > 0 && time.Until(t.Creation.Add(t.Expires)) < 1 {
 		return nil, auth.ErrInvalidToken
 	}
+	t.Token = token
 	return &t, nil
 }
 
@@ -220,7 +238,7 @@ func deleteToken(token string) error {
 		return err
 	}
 	defer conn.Close()
-	return conn.Tokens().Remove(bson.M{"token": token})
+	return conn.Tokens().Remove(bson.M{"token": bson.M{"$in": secret.LookupValues(token)}})
 }
 
 func deleteAllTokens(email string) error {
@@ -251,3 +269,25 @@ func createApplicationToken(appName string) (*Token, error) {
 	}
 	return &t, nil
 }
+
+// HashStoredTokens hashes the tokens stored in plain text. It's used by the
+// encrypt-sensitive-fields migration.
+func HashStoredTokens() error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var tokens []Token
+	err = conn.Tokens().Find(bson.M{"token": bson.M{"$not": bson.RegEx{Pattern: "^sha256:"}}}).All(&tokens)
+	if err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		err = conn.Tokens().Update(bson.M{"token": t.Token}, t)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
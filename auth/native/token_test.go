@@ -341,6 +341,43 @@ func (s *S) TestCreateApplicationToken(c *check.C) {
 	c.Assert(t.AppName, check.Equals, "tsuru-healer")
 }
 
+func (s *S) TestApplicationTokenStoredHashed(c *check.C) {
+	config.Set("secrets:encrypt-storage", true)
+	defer config.Unset("secrets:encrypt-storage")
+	t, err := createApplicationToken("tsuru-healer")
+	c.Assert(err, check.IsNil)
+	var stored bson.M
+	err = s.conn.Tokens().Find(bson.M{"appname": "tsuru-healer"}).One(&stored)
+	c.Assert(err, check.IsNil)
+	c.Assert(stored["token"], check.Matches, `sha256:[0-9a-f]{64}`)
+	found, err := getToken("bearer " + t.GetValue())
+	c.Assert(err, check.IsNil)
+	c.Assert(found.GetValue(), check.Equals, t.GetValue())
+	c.Assert(found.AppName, check.Equals, "tsuru-healer")
+	err = deleteToken(t.GetValue())
+	c.Assert(err, check.IsNil)
+	_, err = getToken("bearer " + t.GetValue())
+	c.Assert(err, check.Equals, auth.ErrInvalidToken)
+}
+
+func (s *S) TestHashStoredTokens(c *check.C) {
+	t, err := createApplicationToken("tsuru-healer")
+	c.Assert(err, check.IsNil)
+	config.Set("secrets:encrypt-storage", true)
+	defer config.Unset("secrets:encrypt-storage")
+	err = HashStoredTokens()
+	c.Assert(err, check.IsNil)
+	n, err := s.conn.Tokens().Find(bson.M{"token": t.GetValue()}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 0)
+	found, err := getToken("bearer " + s.token.GetValue())
+	c.Assert(err, check.IsNil)
+	c.Assert(found.UserEmail, check.Equals, s.user.Email)
+	found, err = getToken("bearer " + t.GetValue())
+	c.Assert(err, check.IsNil)
+	c.Assert(found.AppName, check.Equals, "tsuru-healer")
+}
+
 func (s *S) TestTokenGetUser(c *check.C) {
 	u, err := s.token.User()
 	c.Assert(err, check.IsNil)
@@ -380,6 +417,14 @@ func (s *S) TestTokenIsAppToken(c *check.C) {
 	c.Assert(isAppToken, check.Equals, false)
 }
 
+func (s *S) TestTokenGetExpireTime(c *check.C) {
+	creation := time.Now()
+	t := Token{Creation: creation, Expires: time.Hour}
+	c.Assert(t.GetExpireTime(), check.Equals, creation.Add(time.Hour))
+	t = Token{Creation: creation, AppName: "myapp"}
+	c.Assert(t.GetExpireTime().IsZero(), check.Equals, true)
+}
+
 func (s *S) TestUserCheckPasswordUsesBcrypt(c *check.C) {
 	u := auth.User{Email: "paradisum", Password: "abcd1234"}
 	err := hashPassword(&u)
@@ -5,12 +5,15 @@
 package oauth
 
 import (
+	"time"
+
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/db/storage"
 	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/secret"
 	"golang.org/x/oauth2"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
@@ -21,6 +24,30 @@ type Token struct {
 	UserEmail string `json:"email"`
 }
 
+// GetBSON encrypts the refresh token before storing it, when
+// secrets:encrypt-storage is enabled. The access token is stored as is, as
+// tokens are looked up by it.
+func (t Token) GetBSON() (interface{}, error) {
+	type plain Token
+	refresh, err := secret.Seal(t.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	t.RefreshToken = refresh
+	return plain(t), nil
+}
+
+// SetBSON decrypts the refresh token read from the storage.
+func (t *Token) SetBSON(raw bson.Raw) error {
+	type plain Token
+	err := raw.Unmarshal((*plain)(t))
+	if err != nil {
+		return err
+	}
+	t.RefreshToken, err = secret.Open(t.RefreshToken)
+	return err
+}
+
 func (t *Token) GetValue() string {
 	return t.AccessToken
 }
@@ -45,6 +72,10 @@ func (t *Token) Permissions() ([]permission.Permission, error) {
 	return auth.BaseTokenPermission(t)
 }
 
+func (t *Token) GetExpireTime() time.Time {
+	return t.Expiry
+}
+
 func getToken(header string) (*Token, error) {
 	conn, err := db.Conn()
 	if err != nil {
@@ -101,3 +132,22 @@ func collection() *storage.Collection {
 	coll.EnsureIndex(mgo.Index{Key: []string{"token.accesstoken"}})
 	return coll
 }
+
+// SealRefreshTokens encrypts the refresh tokens stored in plain text. It's
+// used by the encrypt-sensitive-fields migration.
+func SealRefreshTokens() error {
+	coll := collection()
+	defer coll.Close()
+	var tokens []Token
+	err := coll.Find(bson.M{"token.refreshtoken": bson.M{"$nin": []interface{}{"", nil}}}).All(&tokens)
+	if err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		err = coll.Update(bson.M{"token.accesstoken": t.AccessToken}, t)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
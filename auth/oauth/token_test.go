@@ -5,9 +5,11 @@
 package oauth
 
 import (
+	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/auth"
 	"golang.org/x/oauth2"
 	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
 )
 
 func (s *S) TestGetToken(c *check.C) {
@@ -68,3 +70,26 @@ func (s *S) TestDelete(c *check.C) {
 	c.Assert(err, check.IsNil)
 	c.Assert(tokens, check.HasLen, 0)
 }
+
+func (s *S) TestTokenBSONEncryptsRefreshToken(c *check.C) {
+	config.Set("secrets:key", "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	config.Set("secrets:encrypt-storage", true)
+	defer config.Unset("secrets")
+	token := Token{Token: oauth2.Token{AccessToken: "myvalidtoken", RefreshToken: "myrefresh"}, UserEmail: "x@x.com"}
+	data, err := bson.Marshal(token)
+	c.Assert(err, check.IsNil)
+	var raw struct {
+		Token struct {
+			AccessToken  string
+			RefreshToken string
+		}
+	}
+	err = bson.Unmarshal(data, &raw)
+	c.Assert(err, check.IsNil)
+	c.Assert(raw.Token.AccessToken, check.Equals, "myvalidtoken")
+	c.Assert(raw.Token.RefreshToken, check.Matches, `enc:v1:.+`)
+	var result Token
+	err = bson.Unmarshal(data, &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, token)
+}
@@ -23,6 +23,7 @@ type request struct {
 	Expires  time.Time `json:"expires"`
 	Email    string    `json:"email"`
 	Authed   bool      `json:"authed"`
+	Teams    []string  `json:"teams"`
 }
 
 func (r *request) expireTime() time.Duration {
@@ -173,6 +173,12 @@ func (s *SAMLAuthScheme) Login(params map[string]string) (auth.Token, error) {
 			return nil, err
 		}
 	}
+	if teamsAttribute() != "" {
+		err = syncUserTeams(user, req.Teams)
+		if err != nil {
+			return nil, err
+		}
+	}
 	token, err := createToken(user)
 	if err != nil {
 		return nil, err
@@ -237,6 +243,12 @@ func (s *SAMLAuthScheme) callback(params map[string]string) error {
 			return &tsuruErrors.ValidationError{Message: "could not create valid email with auth:saml:idp-attribute-user-identity"}
 		}
 	}
+	if teamsAttribute() != "" {
+		req.Teams, err = getUserTeams(response)
+		if err != nil {
+			return err
+		}
+	}
 	req.Authed = true
 	req.Email = email
 	req.Update()
@@ -0,0 +1,128 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package saml
+
+import (
+	"strings"
+
+	"github.com/diego-araujo/go-saml"
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/log"
+)
+
+// teamsAttribute returns the name of the assertion attribute listing the
+// groups of the user, teams are only synchronized when it's configured.
+func teamsAttribute() string {
+	attr, _ := config.GetString("auth:saml:idp-attribute-teams")
+	return attr
+}
+
+// teamMapping returns the auth:saml:team-mapping config entry, mapping
+// group names sent by the identity provider to tsuru team names.
+func teamMapping() (map[string]string, error) {
+	data, _ := config.Get("auth:saml:team-mapping")
+	if data == nil {
+		return nil, nil
+	}
+	m, ok := data.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.Errorf("invalid auth:saml:team-mapping configuration: %v", data)
+	}
+	mapping := make(map[string]string, len(m))
+	for k, v := range m {
+		group, okK := k.(string)
+		team, okV := v.(string)
+		if !okK || !okV {
+			return nil, errors.Errorf("invalid auth:saml:team-mapping configuration: %v", data)
+		}
+		mapping[group] = team
+	}
+	return mapping, nil
+}
+
+// getUserTeams returns the teams of the user according to the groups listed
+// in the attribute set in auth:saml:idp-attribute-teams. The attribute may
+// be repeated or hold a comma separated list of groups. When a team mapping
+// is configured, groups without a mapping are ignored, otherwise groups are
+// used as team names.
+func getUserTeams(r *saml.Response) ([]string, error) {
+	attrName := teamsAttribute()
+	mapping, err := teamMapping()
+	if err != nil {
+		return nil, err
+	}
+	attrStatement := r.Assertion.AttributeStatement
+	if r.IsEncrypted() {
+		attrStatement = r.EncryptedAssertion.Assertion.AttributeStatement
+	}
+	var teams []string
+	seen := map[string]bool{}
+	for _, attr := range attrStatement.Attributes {
+		if attr.Name != attrName && attr.FriendlyName != attrName {
+			continue
+		}
+		for _, group := range strings.Split(attr.AttributeValue.Value, ",") {
+			group = strings.TrimSpace(group)
+			if group == "" {
+				continue
+			}
+			team := group
+			if mapping != nil {
+				var ok bool
+				if team, ok = mapping[group]; !ok {
+					continue
+				}
+			}
+			if !seen[team] {
+				seen[team] = true
+				teams = append(teams, team)
+			}
+		}
+	}
+	return teams, nil
+}
+
+// syncUserTeams grants the role set in auth:saml:team-role to the user in
+// each of the given teams, revoking it from teams no longer listed by the
+// identity provider. Teams unknown to tsuru are ignored.
+func syncUserTeams(user *auth.User, teams []string) error {
+	roleName, err := config.GetString("auth:saml:team-role")
+	if err != nil {
+		return errors.Wrap(err, "auth:saml:team-role must be set to synchronize teams")
+	}
+	current := map[string]bool{}
+	for _, role := range user.Roles {
+		if role.Name == roleName {
+			current[role.ContextValue] = true
+		}
+	}
+	wanted := map[string]bool{}
+	for _, team := range teams {
+		if _, err = auth.GetTeam(team); err != nil {
+			log.Debugf("[saml] ignoring team %q of user %q: %s", team, user.Email, err)
+			continue
+		}
+		wanted[team] = true
+		if current[team] {
+			continue
+		}
+		err = user.AddRole(roleName, team)
+		if err != nil {
+			return err
+		}
+	}
+	for team := range current {
+		if wanted[team] {
+			continue
+		}
+		err = user.RemoveRole(roleName, team)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package saml
+
+import (
+	"sort"
+
+	"github.com/diego-araujo/go-saml"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/permission"
+	authTypes "github.com/tsuru/tsuru/types/auth"
+	"gopkg.in/check.v1"
+)
+
+func responseWithGroups(groups ...string) *saml.Response {
+	r := &saml.Response{}
+	r.Assertion.AttributeStatement.Attributes = []saml.Attribute{
+		{Name: "eduPersonPrincipalName", AttributeValue: saml.AttributeValue{Value: "john"}},
+	}
+	for _, g := range groups {
+		r.Assertion.AttributeStatement.Attributes = append(r.Assertion.AttributeStatement.Attributes, saml.Attribute{
+			Name:           "urn:oid:1.3.6.1.4.1.5923.1.5.1.1",
+			FriendlyName:   "isMemberOf",
+			AttributeValue: saml.AttributeValue{Value: g},
+		})
+	}
+	return r
+}
+
+func (s *S) TestGetUserTeams(c *check.C) {
+	config.Set("auth:saml:idp-attribute-teams", "isMemberOf")
+	defer config.Unset("auth:saml:idp-attribute-teams")
+	teams, err := getUserTeams(responseWithGroups("admin", "devs, ops", "admin"))
+	c.Assert(err, check.IsNil)
+	c.Assert(teams, check.DeepEquals, []string{"admin", "devs", "ops"})
+	teams, err = getUserTeams(responseWithGroups())
+	c.Assert(err, check.IsNil)
+	c.Assert(teams, check.IsNil)
+}
+
+func (s *S) TestGetUserTeamsWithMapping(c *check.C) {
+	config.Set("auth:saml:idp-attribute-teams", "isMemberOf")
+	defer config.Unset("auth:saml:idp-attribute-teams")
+	config.Set("auth:saml:team-mapping", map[interface{}]interface{}{
+		"cn=admins": "admin",
+		"cn=devs":   "devs",
+	})
+	defer config.Unset("auth:saml:team-mapping")
+	teams, err := getUserTeams(responseWithGroups("cn=admins,cn=devs", "cn=others"))
+	c.Assert(err, check.IsNil)
+	c.Assert(teams, check.DeepEquals, []string{"admin", "devs"})
+}
+
+func (s *S) TestGetUserTeamsInvalidMapping(c *check.C) {
+	config.Set("auth:saml:idp-attribute-teams", "isMemberOf")
+	defer config.Unset("auth:saml:idp-attribute-teams")
+	config.Set("auth:saml:team-mapping", "admin")
+	defer config.Unset("auth:saml:team-mapping")
+	_, err := getUserTeams(responseWithGroups("admin"))
+	c.Assert(err, check.ErrorMatches, "invalid auth:saml:team-mapping configuration: admin")
+}
+
+func (s *S) TestSyncUserTeams(c *check.C) {
+	config.Set("auth:saml:team-role", "team-member")
+	defer config.Unset("auth:saml:team-role")
+	_, err := permission.NewRole("team-member", "team", "")
+	c.Assert(err, check.IsNil)
+	for _, name := range []string{"admin", "devs", "ops"} {
+		err = auth.TeamService().Insert(authTypes.Team{Name: name})
+		c.Assert(err, check.IsNil)
+	}
+	user := &auth.User{Email: "john@tsuru.io"}
+	err = user.Create()
+	c.Assert(err, check.IsNil)
+	err = user.AddRole("team-member", "ops")
+	c.Assert(err, check.IsNil)
+	err = syncUserTeams(user, []string{"admin", "devs", "unknown"})
+	c.Assert(err, check.IsNil)
+	user, err = auth.GetUserByEmail("john@tsuru.io")
+	c.Assert(err, check.IsNil)
+	var teams []string
+	for _, r := range user.Roles {
+		c.Assert(r.Name, check.Equals, "team-member")
+		teams = append(teams, r.ContextValue)
+	}
+	sort.Strings(teams)
+	c.Assert(teams, check.DeepEquals, []string{"admin", "devs"})
+}
+
+func (s *S) TestSyncUserTeamsWithoutRole(c *check.C) {
+	user := &auth.User{Email: "john@tsuru.io"}
+	err := syncUserTeams(user, []string{"admin"})
+	c.Assert(err, check.ErrorMatches, "auth:saml:team-role must be set to synchronize teams: .*")
+}
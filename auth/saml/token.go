@@ -15,6 +15,7 @@ import (
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/secret"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
@@ -34,6 +35,14 @@ type Token struct {
 	AppName   string        `json:"app"`
 }
 
+// GetBSON hashes the token before storing it, when secrets:encrypt-storage
+// is enabled, the same way native tokens are stored.
+func (t Token) GetBSON() (interface{}, error) {
+	type plain Token
+	t.Token = secret.Hash(t.Token)
+	return plain(t), nil
+}
+
 func (t *Token) GetValue() string {
 	return t.Token
 }
@@ -58,6 +67,13 @@ func (t *Token) Permissions() ([]permission.Permission, error) {
 	return auth.BaseTokenPermission(t)
 }
 
+func (t *Token) GetExpireTime() time.Time {
+	if t.Expires <= 0 {
+		return time.Time{}
+	}
+	return t.Creation.Add(t.Expires)
+}
+
 func loadConfig() error {
 	if tokenExpire == 0 {
 		var err error
@@ -163,13 +179,14 @@ func getToken(header string) (*Token, error) {
 	if err != nil {
 		return nil, err
 	}
-	err = conn.Tokens().Find(bson.M{"token": token}).One(&t)
+	err = conn.Tokens().Find(bson.M{"token": bson.M{"$in": secret.LookupValues(token)}}).One(&t)
 	if err != nil {
 		if err == mgo.ErrNotFound {
 			return nil, auth.ErrInvalidToken
 		}
 		return nil, err
 	}
+	t.Token = token
 	if t.Expires > 0 && time.Until(t.Creation.Add(t.Expires)) < 1 {
 		return nil, auth.ErrInvalidToken
 	}
@@ -182,7 +199,7 @@ func deleteToken(token string) error {
 		return err
 	}
 	defer conn.Close()
-	return conn.Tokens().Remove(bson.M{"token": token})
+	return conn.Tokens().Remove(bson.M{"token": bson.M{"$in": secret.LookupValues(token)}})
 }
 
 func deleteAllTokens(email string) error {
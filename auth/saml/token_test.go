@@ -5,6 +5,7 @@
 package saml
 
 import (
+	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/auth"
 	"gopkg.in/check.v1"
 	"gopkg.in/mgo.v2/bson"
@@ -40,3 +41,21 @@ func (s *S) TestGetTokenInvalid(c *check.C) {
 	c.Assert(t, check.IsNil)
 	c.Assert(err, check.Equals, auth.ErrInvalidToken)
 }
+
+func (s *S) TestTokenStoredHashed(c *check.C) {
+	config.Set("secrets:encrypt-storage", true)
+	defer config.Unset("secrets:encrypt-storage")
+	token, err := createToken(&auth.User{Email: "x@x.com"})
+	c.Assert(err, check.IsNil)
+	var stored bson.M
+	err = s.conn.Tokens().Find(bson.M{"useremail": "x@x.com"}).One(&stored)
+	c.Assert(err, check.IsNil)
+	c.Assert(stored["token"], check.Matches, `sha256:[0-9a-f]{64}`)
+	t, err := getToken("bearer " + token.Token)
+	c.Assert(err, check.IsNil)
+	c.Assert(t.Token, check.Equals, token.Token)
+	err = deleteToken(token.Token)
+	c.Assert(err, check.IsNil)
+	_, err = getToken("bearer " + token.Token)
+	c.Assert(err, check.Equals, auth.ErrInvalidToken)
+}
@@ -7,6 +7,7 @@ package auth
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -21,8 +22,9 @@ import (
 var teamNameRegexp = regexp.MustCompile(`^[a-z][-@_.+\w]+$`)
 
 type ErrTeamStillUsed struct {
-	Apps             []string
-	ServiceInstances []string
+	Apps             []string `json:"apps,omitempty"`
+	Services         []string `json:"services,omitempty"`
+	ServiceInstances []string `json:"serviceInstances,omitempty"`
 }
 
 func TeamService() authTypes.TeamService {
@@ -37,10 +39,17 @@ func TeamService() authTypes.TeamService {
 }
 
 func (e *ErrTeamStillUsed) Error() string {
+	var refs []string
 	if len(e.Apps) > 0 {
-		return fmt.Sprintf("Apps: %s", strings.Join(e.Apps, ", "))
+		refs = append(refs, fmt.Sprintf("Apps: %s", strings.Join(e.Apps, ", ")))
 	}
-	return fmt.Sprintf("Service instances: %s", strings.Join(e.ServiceInstances, ", "))
+	if len(e.Services) > 0 {
+		refs = append(refs, fmt.Sprintf("Services: %s", strings.Join(e.Services, ", ")))
+	}
+	if len(e.ServiceInstances) > 0 {
+		refs = append(refs, fmt.Sprintf("Service instances: %s", strings.Join(e.ServiceInstances, ", ")))
+	}
+	return strings.Join(refs, "\n")
 }
 
 func validateTeam(t authTypes.Team) error {
@@ -89,28 +98,46 @@ func GetTeamsNames(teams []authTypes.Team) []string {
 }
 
 func RemoveTeam(teamName string) error {
-	conn, err := db.Conn()
+	refs, err := TeamReferences(teamName)
 	if err != nil {
 		return err
 	}
+	if refs != nil {
+		return refs
+	}
+	return TeamService().Delete(authTypes.Team{Name: teamName})
+}
+
+// TeamReferences lists the apps, services and service instances that would
+// be left without the team if it were removed. It returns nil when the team
+// is not used anymore.
+func TeamReferences(teamName string) (*ErrTeamStillUsed, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
 	defer conn.Close()
-	var apps []string
-	err = conn.Apps().Find(bson.M{"teams": teamName}).Distinct("name", &apps)
+	var refs ErrTeamStillUsed
+	err = conn.Apps().Find(bson.M{"teams": teamName}).Distinct("name", &refs.Apps)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if len(apps) > 0 {
-		return &ErrTeamStillUsed{Apps: apps}
+	query := bson.M{"$or": []bson.M{{"owner_teams": teamName}, {"teams": teamName}}}
+	err = conn.Services().Find(query).Distinct("_id", &refs.Services)
+	if err != nil {
+		return nil, err
 	}
-	var serviceInstances []string
-	err = conn.ServiceInstances().Find(bson.M{"teams": teamName}).Distinct("name", &serviceInstances)
+	err = conn.ServiceInstances().Find(bson.M{"teams": teamName}).Distinct("name", &refs.ServiceInstances)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if len(serviceInstances) > 0 {
-		return &ErrTeamStillUsed{ServiceInstances: serviceInstances}
+	if len(refs.Apps) == 0 && len(refs.Services) == 0 && len(refs.ServiceInstances) == 0 {
+		return nil, nil
 	}
-	return TeamService().Delete(authTypes.Team{Name: teamName})
+	sort.Strings(refs.Apps)
+	sort.Strings(refs.Services)
+	sort.Strings(refs.ServiceInstances)
+	return &refs, nil
 }
 
 func ListTeams() ([]authTypes.Team, error) {
@@ -0,0 +1,108 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+)
+
+const (
+	TeamCreationOpen      = "open"
+	TeamCreationAdminOnly = "admin-only"
+	TeamCreationDomain    = "domain"
+)
+
+// TeamCreationDeniedError is returned when the team creation policy doesn't
+// allow the user to create a team.
+type TeamCreationDeniedError struct {
+	Reason string
+}
+
+func (e *TeamCreationDeniedError) Error() string {
+	return "team creation not allowed: " + e.Reason
+}
+
+// TeamCreationPolicy controls which users may create teams, set in the
+// team:creation config entry. In the domain mode, only users whose email is
+// in one of AllowedDomains may create teams. MaxPerUser, when greater than
+// zero, limits the number of teams created by each user. Administrators are
+// never restricted.
+type TeamCreationPolicy struct {
+	Mode           string
+	AllowedDomains []string
+	MaxPerUser     int
+}
+
+// GetTeamCreationPolicy returns the team creation policy in the config,
+// defaulting to open.
+func GetTeamCreationPolicy() (*TeamCreationPolicy, error) {
+	policy := TeamCreationPolicy{Mode: TeamCreationOpen}
+	if mode, _ := config.GetString("team:creation:policy"); mode != "" {
+		policy.Mode = mode
+	}
+	policy.AllowedDomains, _ = config.GetList("team:creation:allowed-domains")
+	policy.MaxPerUser, _ = config.GetInt("team:creation:max-per-user")
+	switch policy.Mode {
+	case TeamCreationOpen, TeamCreationAdminOnly:
+	case TeamCreationDomain:
+		if len(policy.AllowedDomains) == 0 {
+			return nil, errors.New("team:creation:allowed-domains is required when team:creation:policy is domain")
+		}
+	default:
+		return nil, errors.Errorf("invalid team:creation:policy %q, must be one of: %s, %s, %s", policy.Mode, TeamCreationOpen, TeamCreationAdminOnly, TeamCreationDomain)
+	}
+	return &policy, nil
+}
+
+// Check returns a TeamCreationDeniedError when the policy doesn't allow the
+// user to create one more team.
+func (p *TeamCreationPolicy) Check(user *User, admin bool) error {
+	if admin {
+		return nil
+	}
+	switch p.Mode {
+	case TeamCreationAdminOnly:
+		return &TeamCreationDeniedError{Reason: "only administrators may create teams"}
+	case TeamCreationDomain:
+		if !p.allowsEmail(user.Email) {
+			return &TeamCreationDeniedError{Reason: fmt.Sprintf("only users with emails in %s may create teams", strings.Join(p.AllowedDomains, ", "))}
+		}
+	}
+	if p.MaxPerUser <= 0 {
+		return nil
+	}
+	teams, err := TeamService().FindAll()
+	if err != nil {
+		return err
+	}
+	var created int
+	for _, t := range teams {
+		if t.CreatingUser == user.Email {
+			created++
+		}
+	}
+	if created >= p.MaxPerUser {
+		return &TeamCreationDeniedError{Reason: fmt.Sprintf("users may create at most %d teams", p.MaxPerUser)}
+	}
+	return nil
+}
+
+func (p *TeamCreationPolicy) allowsEmail(email string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+	for _, d := range p.AllowedDomains {
+		if strings.ToLower(strings.TrimPrefix(d, "@")) == domain {
+			return true
+		}
+	}
+	return false
+}
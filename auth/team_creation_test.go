@@ -0,0 +1,60 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"github.com/tsuru/config"
+	authTypes "github.com/tsuru/tsuru/types/auth"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestGetTeamCreationPolicy(c *check.C) {
+	policy, err := GetTeamCreationPolicy()
+	c.Assert(err, check.IsNil)
+	c.Assert(policy, check.DeepEquals, &TeamCreationPolicy{Mode: TeamCreationOpen})
+	config.Set("team:creation:policy", "domain")
+	config.Set("team:creation:allowed-domains", []interface{}{"globo.com"})
+	config.Set("team:creation:max-per-user", 3)
+	defer config.Unset("team:creation")
+	policy, err = GetTeamCreationPolicy()
+	c.Assert(err, check.IsNil)
+	c.Assert(policy, check.DeepEquals, &TeamCreationPolicy{
+		Mode:           TeamCreationDomain,
+		AllowedDomains: []string{"globo.com"},
+		MaxPerUser:     3,
+	})
+}
+
+func (s *S) TestGetTeamCreationPolicyInvalid(c *check.C) {
+	config.Set("team:creation:policy", "domain")
+	defer config.Unset("team:creation")
+	_, err := GetTeamCreationPolicy()
+	c.Assert(err, check.ErrorMatches, "team:creation:allowed-domains is required when team:creation:policy is domain")
+	config.Set("team:creation:policy", "closed")
+	_, err = GetTeamCreationPolicy()
+	c.Assert(err, check.ErrorMatches, `invalid team:creation:policy "closed", must be one of: open, admin-only, domain`)
+}
+
+func (s *S) TestTeamCreationPolicyCheck(c *check.C) {
+	policy := TeamCreationPolicy{Mode: TeamCreationAdminOnly}
+	c.Assert(policy.Check(s.user, false), check.FitsTypeOf, &TeamCreationDeniedError{})
+	c.Assert(policy.Check(s.user, true), check.IsNil)
+	policy = TeamCreationPolicy{Mode: TeamCreationDomain, AllowedDomains: []string{"@Globo.com"}}
+	c.Assert(policy.Check(s.user, false), check.IsNil)
+	c.Assert(policy.Check(&User{Email: "someone@example.com"}, false), check.ErrorMatches,
+		"team creation not allowed: only users with emails in @Globo.com may create teams")
+}
+
+func (s *S) TestTeamCreationPolicyCheckMaxPerUser(c *check.C) {
+	policy := TeamCreationPolicy{Mode: TeamCreationOpen, MaxPerUser: 2}
+	c.Assert(policy.Check(s.user, false), check.IsNil)
+	err := TeamService().Insert(authTypes.Team{Name: "team1", CreatingUser: s.user.Email})
+	c.Assert(err, check.IsNil)
+	err = TeamService().Insert(authTypes.Team{Name: "team2", CreatingUser: s.user.Email})
+	c.Assert(err, check.IsNil)
+	c.Assert(policy.Check(s.user, false), check.ErrorMatches, "team creation not allowed: users may create at most 2 teams")
+	c.Assert(policy.Check(s.user, true), check.IsNil)
+	c.Assert(policy.Check(&User{Email: "someone@globo.com"}, false), check.IsNil)
+}
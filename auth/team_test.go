@@ -117,6 +117,38 @@ func (s *S) TestRemoveTeamWithServiceInstances(c *check.C) {
 	c.Assert(err, check.ErrorMatches, "Service instances: vladimir")
 }
 
+func (s *S) TestRemoveTeamWithServices(c *check.C) {
+	team := authTypes.Team{Name: "harkonnen"}
+	err := TeamService().Insert(team)
+	c.Assert(err, check.IsNil)
+	err = s.conn.Services().Insert(bson.M{"_id": "spice", "owner_teams": []string{"harkonnen"}})
+	c.Assert(err, check.IsNil)
+	err = RemoveTeam(team.Name)
+	c.Assert(err, check.ErrorMatches, "Services: spice")
+}
+
+func (s *S) TestTeamReferences(c *check.C) {
+	err := s.conn.Apps().Insert(bson.M{"name": "leto", "teams": []string{"atreides"}})
+	c.Assert(err, check.IsNil)
+	err = s.conn.Apps().Insert(bson.M{"name": "duncan", "teams": []string{"atreides", "fremen"}})
+	c.Assert(err, check.IsNil)
+	err = s.conn.Services().Insert(bson.M{"_id": "water", "teams": []string{"atreides"}})
+	c.Assert(err, check.IsNil)
+	err = s.conn.ServiceInstances().Insert(bson.M{"name": "caladan", "teams": []string{"atreides"}})
+	c.Assert(err, check.IsNil)
+	refs, err := TeamReferences("atreides")
+	c.Assert(err, check.IsNil)
+	c.Assert(refs, check.DeepEquals, &ErrTeamStillUsed{
+		Apps:             []string{"duncan", "leto"},
+		Services:         []string{"water"},
+		ServiceInstances: []string{"caladan"},
+	})
+	c.Assert(refs.Error(), check.Equals, "Apps: duncan, leto\nServices: water\nService instances: caladan")
+	refs, err = TeamReferences("corrino")
+	c.Assert(err, check.IsNil)
+	c.Assert(refs, check.IsNil)
+}
+
 func (s *S) TestListTeams(c *check.C) {
 	err := TeamService().Insert(authTypes.Team{Name: "corrino"})
 	c.Assert(err, check.IsNil)
@@ -6,6 +6,7 @@ package auth
 
 import (
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/tsuru/tsuru/permission"
@@ -20,6 +21,14 @@ type Token interface {
 	Permissions() ([]permission.Permission, error)
 }
 
+// ExpirableToken is a token that stops being valid at some point in time.
+type ExpirableToken interface {
+	Token
+	// GetExpireTime returns when the token stops being valid, the zero time
+	// means it never expires.
+	GetExpireTime() time.Time
+}
+
 var ErrInvalidToken = errors.New("Invalid token")
 
 // ParseToken extracts token from a header:
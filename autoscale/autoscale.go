@@ -19,6 +19,7 @@ import (
 	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/iaas"
 	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/maintenance"
 	"github.com/tsuru/tsuru/net"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/provision"
@@ -283,13 +284,24 @@ func (a *Config) runScalerInNodes(prov provision.NodeProvisioner, pool string, n
 			evt.Logf("not all required nodes were created: %s", err)
 		}
 	} else if len(customData.Result.ToRemove) > 0 {
-		evt.Logf("running event \"remove\" for %q: %#v", pool, customData.Result)
-		customData.Nodes = customData.Result.ToRemove
-		err = a.removeMultipleNodes(evt, prov, customData.Result.ToRemove)
+		var window *maintenance.Window
+		window, err = maintenance.ActiveWindow(maintenance.ActionAutoscaleDown, "", pool)
 		if err != nil {
-			retErr = err
+			retErr = errors.Wrapf(err, "unable to check maintenance windows for %s", pool)
 			return
 		}
+		if window != nil {
+			evt.Logf("removal of nodes from %q deferred by maintenance window %q", pool, window.Name)
+			customData.Result.ToRemove = nil
+		} else {
+			evt.Logf("running event \"remove\" for %q: %#v", pool, customData.Result)
+			customData.Nodes = customData.Result.ToRemove
+			err = a.removeMultipleNodes(evt, prov, customData.Result.ToRemove)
+			if err != nil {
+				retErr = err
+				return
+			}
+		}
 	}
 	if !customData.Rule.PreventRebalance {
 		err := a.rebalanceIfNeeded(evt, prov, pool, nodes, &customData)
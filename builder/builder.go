@@ -27,6 +27,9 @@ type BuildOpts struct {
 	ArchiveSize    int64
 	ImageID        string
 	Tag            string
+	// BuildArgs are environment variables only available to the build, they
+	// aren't kept in the image nor set in the units of the app.
+	BuildArgs map[string]string
 }
 
 // Builder is the basic interface of this package.
@@ -34,6 +34,7 @@ type runContainerActionsArgs struct {
 	exposedPort      string
 	event            *event.Event
 	tarFile          io.Reader
+	buildArgs        map[string]string
 }
 
 func checkCanceled(evt *event.Event) error {
@@ -67,6 +68,7 @@ var createContainer = action.Action{
 			Image:         args.imageID,
 			BuildingImage: args.buildingImage,
 			ExposedPort:   args.exposedPort,
+			BuildArgs:     args.buildArgs,
 		}
 		log.Debugf("create container for app %s, based on image %s, with cmds %s", args.app.GetName(), args.imageID, args.commands)
 		err := cont.Create(&CreateContainerArgs{
@@ -269,6 +269,38 @@ func (s *S) TestUpdateAppBuilderImageForward(c *check.C) {
 	c.Assert(allImages[0], check.Equals, "tsuru/app-mightyapp:v1")
 }
 
+func (s *S) TestContainerBuildArgs(c *check.C) {
+	client, err := docker.NewClient(s.server.URL())
+	c.Assert(err, check.IsNil)
+	err = s.newFakeImage(client, "tsuru/python", nil)
+	c.Assert(err, check.IsNil)
+	app := provisiontest.NewFakeApp("mightyapp", "python", 1)
+	nextImgName, err := image.AppNewImageName(app.GetName())
+	c.Assert(err, check.IsNil)
+	cont := Container{
+		AppName:       "mightyapp",
+		BuildingImage: nextImgName,
+		BuildArgs:     map[string]string{"NPM_TOKEN": "secret"},
+	}
+	err = cont.Create(&CreateContainerArgs{
+		App:      app,
+		ImageID:  "tsuru/python",
+		Commands: []string{"foo"},
+		Client:   client,
+		Building: true,
+	})
+	c.Assert(err, check.IsNil)
+	dockerContainer, err := client.InspectContainer(cont.ID)
+	c.Assert(err, check.IsNil)
+	env := dockerContainer.Config.Env
+	c.Assert(env[len(env)-1], check.Equals, "NPM_TOKEN=secret")
+	imgID, err := cont.Commit(client, safe.NewBuffer(nil))
+	c.Assert(err, check.IsNil)
+	img, err := client.InspectImage(imgID)
+	c.Assert(err, check.IsNil)
+	c.Assert(img.Config.Env, check.DeepEquals, []string{"NPM_TOKEN="})
+}
+
 func (s *S) newContainer(client *docker.Client) (*Container, error) {
 	container := Container{
 		ID:          "id",
@@ -77,7 +77,7 @@ func (b *dockerBuilder) Build(p provision.BuilderDeploy, app provision.App, evt
 	if err != nil {
 		return "", err
 	}
-	imageID, err := b.buildPipeline(p, client, app, tarFile, evt, opts.Tag)
+	imageID, err := b.buildPipeline(p, client, app, tarFile, evt, opts.Tag, opts.BuildArgs)
 	if err != nil {
 		return "", err
 	}
@@ -10,6 +10,7 @@ import (
 	"io"
 	"math/rand"
 	"net/url"
+	"sort"
 	"strings"
 	"time"
 
@@ -47,6 +48,9 @@ type Container struct {
 	LockedUntil             time.Time
 	Routable                bool `bson:"-"`
 	ExposedPort             string
+	// BuildArgs are set in the environment of the container building the
+	// image and cleared from the committed image.
+	BuildArgs map[string]string `bson:"-"`
 }
 
 func (c *Container) ShortID() string {
@@ -137,6 +141,11 @@ func (c *Container) addEnvsToConfig(args *CreateContainerArgs, port string, cfg
 	for _, envData := range envs {
 		cfg.Env = append(cfg.Env, fmt.Sprintf("%s=%s", envData.Name, envData.Value))
 	}
+	if args.Building {
+		for _, name := range c.buildArgNames() {
+			cfg.Env = append(cfg.Env, fmt.Sprintf("%s=%s", name, c.BuildArgs[name]))
+		}
+	}
 	sharedMount, _ := config.GetString("docker:sharedfs:mountpoint")
 	sharedBasedir, _ := config.GetString("docker:sharedfs:hostdir")
 	if sharedMount != "" && sharedBasedir != "" {
@@ -147,6 +156,15 @@ func (c *Container) addEnvsToConfig(args *CreateContainerArgs, port string, cfg
 	}
 }
 
+func (c *Container) buildArgNames() []string {
+	names := make([]string, 0, len(c.BuildArgs))
+	for name := range c.BuildArgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func (c *Container) SetStatus(status provision.Status, updateDB bool) error {
 	c.Status = status.String()
 	c.LastStatusUpdate = time.Now().In(time.UTC)
@@ -197,6 +215,15 @@ func (c *Container) Commit(client provision.BuilderDockerClient, writer io.Write
 	repository := strings.Join(parts[:len(parts)-1], ":")
 	tag := parts[len(parts)-1]
 	opts := docker.CommitContainerOptions{Container: c.ID, Repository: repository, Tag: tag}
+	if names := c.buildArgNames(); len(names) > 0 {
+		// docker keeps the environment of the container in the image, the
+		// values of build args are overridden so they don't reach the units.
+		env := make([]string, len(names))
+		for i, name := range names {
+			env[i] = name + "="
+		}
+		opts.Run = &docker.Config{Env: env}
+	}
 	image, err := client.CommitContainer(opts)
 	if err != nil {
 		return "", log.WrapError(errors.Wrapf(err, "error in commit container %s", c.ID))
@@ -26,7 +26,7 @@ const (
 	archiveFileName = "archive.tar.gz"
 )
 
-func (b *dockerBuilder) buildPipeline(p provision.BuilderDeploy, client provision.BuilderDockerClient, app provision.App, tarFile io.Reader, evt *event.Event, imageTag string) (string, error) {
+func (b *dockerBuilder) buildPipeline(p provision.BuilderDeploy, client provision.BuilderDockerClient, app provision.App, tarFile io.Reader, evt *event.Event, imageTag string, buildArgs map[string]string) (string, error) {
 	actions := []*action.Action{
 		&createContainer,
 		&uploadToContainer,
@@ -56,6 +56,7 @@ func (b *dockerBuilder) buildPipeline(p provision.BuilderDeploy, client provisio
 		event:         evt,
 		provisioner:   p,
 		tarFile:       tarFile,
+		buildArgs:     buildArgs,
 	}
 	err = pipeline.Execute(args)
 	if err != nil {
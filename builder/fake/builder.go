@@ -23,6 +23,7 @@ type FakeBuilder struct {
 	IsArchiveFileDeploy bool
 	IsRebuildDeploy     bool
 	IsImageDeploy       bool
+	BuildArgs           map[string]string
 	platforms           []provisionedPlatform
 	failures            chan failure
 }
@@ -42,6 +43,7 @@ func (b *FakeBuilder) Build(p provision.BuilderDeploy, app provision.App, evt *e
 	if opts.BuildFromFile {
 		return "", errors.New("build image from Dockerfile is not yet supported")
 	}
+	b.BuildArgs = opts.BuildArgs
 	if opts.ArchiveFile != nil && opts.ArchiveSize != 0 {
 		_, err := ioutil.ReadAll(opts.ArchiveFile)
 		if err != nil {
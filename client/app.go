@@ -0,0 +1,218 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/url"
+	"strings"
+
+	"github.com/ajg/form"
+	"github.com/pkg/errors"
+	apiTypes "github.com/tsuru/tsuru/api/types"
+	appTypes "github.com/tsuru/tsuru/types/app"
+)
+
+// ErrDeployFailed is returned by Deploy when the deploy output doesn't end
+// with the success mark.
+var ErrDeployFailed = errors.New("deploy failed, see the output for details")
+
+// App is a tsuru app, as returned by the API.
+type App struct {
+	Name        string        `json:"name"`
+	Platform    string        `json:"platform"`
+	Description string        `json:"description"`
+	TeamOwner   string        `json:"teamowner"`
+	Teams       []string      `json:"teams"`
+	Owner       string        `json:"owner"`
+	Pool        string        `json:"pool"`
+	Plan        appTypes.Plan `json:"plan"`
+	IP          string        `json:"ip"`
+	CName       []string      `json:"cname"`
+	Tags        []string      `json:"tags"`
+	Deploys     uint          `json:"deploys"`
+	Units       []Unit        `json:"units"`
+	Error       string        `json:"error,omitempty"`
+}
+
+// Unit is a unit of an app.
+type Unit struct {
+	ID          string
+	Name        string
+	AppName     string
+	ProcessName string
+	Type        string
+	IP          string
+	Status      string
+	Address     *url.URL
+}
+
+// AppFilter filters the apps returned by ListApps. Empty fields match every
+// app.
+type AppFilter struct {
+	Name      string
+	Platform  string
+	TeamOwner string
+	Owner     string
+	Pool      string
+	Status    string
+	Tags      []string
+}
+
+func (f AppFilter) values() url.Values {
+	query := url.Values{}
+	for key, value := range map[string]string{
+		"name":      f.Name,
+		"platform":  f.Platform,
+		"teamOwner": f.TeamOwner,
+		"owner":     f.Owner,
+		"pool":      f.Pool,
+		"status":    f.Status,
+	} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	for _, tag := range f.Tags {
+		query.Add("tag", tag)
+	}
+	return query
+}
+
+// CreateAppArgs holds the data of an app being created.
+type CreateAppArgs struct {
+	Name        string
+	Platform    string
+	Plan        string
+	TeamOwner   string
+	Pool        string
+	Description string
+	Router      string
+	Tags        []string
+}
+
+// ListApps returns the apps matching the filter the client has access to.
+func (c *Client) ListApps(filter AppFilter) ([]App, error) {
+	var apps []App
+	err := c.getJSON("/apps", filter.values(), &apps)
+	return apps, err
+}
+
+// AppInfo returns the app with the given name.
+func (c *Client) AppInfo(name string) (*App, error) {
+	var a App
+	err := c.getJSON("/apps/"+name, nil, &a)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// CreateApp creates a new app.
+func (c *Client) CreateApp(args CreateAppArgs) error {
+	values := url.Values{}
+	values.Set("name", args.Name)
+	values.Set("platform", args.Platform)
+	values.Set("plan", args.Plan)
+	values.Set("teamOwner", args.TeamOwner)
+	values.Set("pool", args.Pool)
+	values.Set("description", args.Description)
+	values.Set("router", args.Router)
+	for _, tag := range args.Tags {
+		values.Add("tag", tag)
+	}
+	return c.send("POST", "/apps", values)
+}
+
+// RemoveApp removes the app, writing the progress of the removal to w.
+func (c *Client) RemoveApp(name string, w io.Writer) error {
+	return c.stream("DELETE", "/apps/"+name, nil, w)
+}
+
+// SetEnvs sets and unsets environment variables of the app, writing the
+// progress of the change, and of the restart of the app, to w.
+func (c *Client) SetEnvs(appName string, envs apiTypes.Envs, w io.Writer) error {
+	values, err := form.EncodeToValues(envs)
+	if err != nil {
+		return err
+	}
+	return c.stream("POST", "/apps/"+appName+"/env", values, w)
+}
+
+// DeployOptions holds the data of a deploy. Exactly one of Archive and
+// Image must be set.
+type DeployOptions struct {
+	// Archive is a gzipped tarball with the code of the app.
+	Archive io.Reader
+	// Image is the name of a docker image to be deployed.
+	Image string
+	// Message describes the deploy.
+	Message string
+}
+
+// Deploy deploys the app, writing the deploy output to w as it's sent by the
+// API. ErrDeployFailed is returned when the deploy doesn't succeed.
+func (c *Client) Deploy(appName string, opts DeployOptions, w io.Writer) error {
+	if (opts.Archive == nil) == (opts.Image == "") {
+		return errors.New("either the archive or the image must be set")
+	}
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	if opts.Message != "" {
+		writer.WriteField("message", opts.Message)
+	}
+	if opts.Image != "" {
+		writer.WriteField("image", opts.Image)
+	} else {
+		part, err := writer.CreateFormFile("file", "archive.tar.gz")
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(part, opts.Archive)
+		if err != nil {
+			return err
+		}
+	}
+	err := writer.Close()
+	if err != nil {
+		return err
+	}
+	request, err := c.newRequest("POST", fmt.Sprintf("/apps/%s/deploy", appName), &body)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	response, err := c.do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	var lastLine string
+	scanner := bufio.NewScanner(response.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		fmt.Fprintln(w, line)
+		if strings.TrimSpace(line) != "" {
+			lastLine = strings.TrimSpace(line)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+	if lastLine != "OK" {
+		return ErrDeployFailed
+	}
+	return nil
+}
+
+// CancelDeploy cancels the running deploy of the app with the given event
+// ID.
+func (c *Client) CancelDeploy(appName, eventID string) error {
+	return c.send("POST", fmt.Sprintf("/apps/%s/deploy/%s/cancel", appName, eventID), url.Values{})
+}
@@ -0,0 +1,86 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package client
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	apiTypes "github.com/tsuru/tsuru/api/types"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestListApps(c *check.C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/1.0/apps")
+		c.Check(r.URL.RawQuery, check.Equals, "pool=pool1&tag=web&teamOwner=myteam")
+		w.Write([]byte(`[{"name":"myapp","teamowner":"myteam","pool":"pool1","plan":{"name":"small","memory":1024}}]`))
+	}
+	apps, err := s.client.ListApps(AppFilter{TeamOwner: "myteam", Pool: "pool1", Tags: []string{"web"}})
+	c.Assert(err, check.IsNil)
+	c.Assert(apps, check.HasLen, 1)
+	c.Assert(apps[0].Name, check.Equals, "myapp")
+	c.Assert(apps[0].Plan.Memory, check.Equals, int64(1024))
+}
+
+func (s *S) TestListAppsNoContent(c *check.C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+	apps, err := s.client.ListApps(AppFilter{})
+	c.Assert(err, check.IsNil)
+	c.Assert(apps, check.HasLen, 0)
+}
+
+func (s *S) TestSetEnvs(c *check.C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "POST")
+		c.Check(r.URL.Path, check.Equals, "/1.0/apps/myapp/env")
+		c.Check(r.FormValue("Envs.0.Name"), check.Equals, "DATABASE_HOST")
+		c.Check(r.FormValue("Envs.0.Value"), check.Equals, "localhost")
+		c.Check(r.FormValue("Unset.0"), check.Equals, "OLD")
+		c.Check(r.FormValue("NoRestart"), check.Equals, "true")
+		w.Write([]byte(`{"Message":"---- Setting 1 and unsetting 1 environment variables ----\n"}` + "\n"))
+	}
+	envs := apiTypes.Envs{
+		Envs:      []struct{ Name, Value string }{{Name: "DATABASE_HOST", Value: "localhost"}},
+		Unset:     []string{"OLD"},
+		NoRestart: true,
+	}
+	var buf bytes.Buffer
+	err := s.client.SetEnvs("myapp", envs, &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Equals, "---- Setting 1 and unsetting 1 environment variables ----\n")
+}
+
+func (s *S) TestDeploy(c *check.C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/1.0/apps/myapp/deploy")
+		c.Check(r.FormValue("message"), check.Equals, "first deploy")
+		file, _, err := r.FormFile("file")
+		c.Assert(err, check.IsNil)
+		data, _ := ioutil.ReadAll(file)
+		c.Check(string(data), check.Equals, "archive data")
+		w.Write([]byte("building\nOK\n"))
+	}
+	var buf bytes.Buffer
+	err := s.client.Deploy("myapp", DeployOptions{Archive: strings.NewReader("archive data"), Message: "first deploy"}, &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Equals, "building\nOK\n")
+}
+
+func (s *S) TestDeployFailed(c *check.C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.FormValue("image"), check.Equals, "myimage")
+		w.Write([]byte("building\nERROR: build failed\n"))
+	}
+	var buf bytes.Buffer
+	err := s.client.Deploy("myapp", DeployOptions{Image: "myimage"}, &buf)
+	c.Assert(err, check.Equals, ErrDeployFailed)
+	err = s.client.Deploy("myapp", DeployOptions{}, &buf)
+	c.Assert(err, check.ErrorMatches, "either the archive or the image must be set")
+}
@@ -0,0 +1,85 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package client
+
+import (
+	"encoding/json"
+	"net/url"
+	"time"
+)
+
+// User is a tsuru user, with its roles and teams.
+type User struct {
+	Email       string
+	Roles       []RoleInstance
+	Permissions []RoleInstance
+	Teams       []string
+	Token       *TokenInfo
+}
+
+// RoleInstance is a role, or a permission, granted in a context.
+type RoleInstance struct {
+	Name         string
+	ContextType  string
+	ContextValue string
+}
+
+// TokenInfo describes the token used to authenticate in the tsuru API.
+type TokenInfo struct {
+	Kind    string
+	App     string
+	Expires *time.Time
+}
+
+// AuthScheme is the authentication scheme used by the tsuru API, with the
+// data clients need to log in with it.
+type AuthScheme struct {
+	Name string            `json:"name"`
+	Data map[string]string `json:"data"`
+}
+
+// Login logs in with the native authentication scheme, returning the token
+// of the new session. The client keeps using its own token.
+func (c *Client) Login(email, password string) (string, error) {
+	var result struct {
+		Token string `json:"token"`
+	}
+	response, err := c.doForm("POST", "/users/"+email+"/tokens", url.Values{"password": []string{password}})
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	err = json.NewDecoder(response.Body).Decode(&result)
+	if err != nil {
+		return "", err
+	}
+	return result.Token, nil
+}
+
+// Logout ends the session of the client token.
+func (c *Client) Logout() error {
+	return c.send("DELETE", "/users/tokens", nil)
+}
+
+// UserInfo returns the user owning the client token.
+func (c *Client) UserInfo() (*User, error) {
+	var user User
+	err := c.getJSON("/users/info", nil, &user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// AuthScheme returns the authentication scheme of the API. It doesn't
+// require a token.
+func (c *Client) AuthScheme() (*AuthScheme, error) {
+	var scheme AuthScheme
+	err := c.getJSON("/auth/scheme", nil, &scheme)
+	if err != nil {
+		return nil, err
+	}
+	return &scheme, nil
+}
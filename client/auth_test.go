@@ -0,0 +1,39 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package client
+
+import (
+	"net/http"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestLogin(c *check.C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "POST")
+		c.Check(r.URL.Path, check.Equals, "/1.0/users/me@example.com/tokens")
+		c.Check(r.FormValue("password"), check.Equals, "123456")
+		w.Write([]byte(`{"token":"newtoken","is_admin":false}`))
+	}
+	token, err := s.client.Login("me@example.com", "123456")
+	c.Assert(err, check.IsNil)
+	c.Assert(token, check.Equals, "newtoken")
+	c.Assert(s.client.Token, check.Equals, "mytoken")
+}
+
+func (s *S) TestUserInfo(c *check.C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/1.0/users/info")
+		w.Write([]byte(`{"Email":"me@example.com","Teams":["myteam"],"Roles":[{"Name":"admin","ContextType":"global"}],"Token":{"Kind":"session"}}`))
+	}
+	user, err := s.client.UserInfo()
+	c.Assert(err, check.IsNil)
+	c.Assert(user, check.DeepEquals, &User{
+		Email: "me@example.com",
+		Teams: []string{"myteam"},
+		Roles: []RoleInstance{{Name: "admin", ContextType: "global"}},
+		Token: &TokenInfo{Kind: "session"},
+	})
+}
@@ -0,0 +1,170 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package client provides a Go client for the tsuru API, with typed methods
+// for authentication, apps, services and events, and helpers for the
+// streaming responses of long running operations:
+//
+//	c := client.New("https://tsuru.example.com", token)
+//	apps, err := c.ListApps(client.AppFilter{TeamOwner: "myteam"})
+//	...
+//	err = c.BindServiceInstance("mysql", "mydb", "myapp", client.BindOptions{}, os.Stdout)
+//
+// Errors returned by the API are *errors.HTTP values, from the
+// github.com/tsuru/tsuru/errors package, holding the status code and the
+// message sent by the API.
+package client
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	tsuruIo "github.com/tsuru/tsuru/io"
+)
+
+const apiVersion = "1.0"
+
+// Doer sends HTTP requests. *http.Client implements it, as does the client
+// of the cmd package, which handles authentication and verbosity on its own.
+type Doer interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// Client is a client for the tsuru API.
+type Client struct {
+	// Target is the address of the tsuru API, like
+	// https://tsuru.example.com.
+	Target string
+	// Token is sent as a bearer token in every request, unless empty.
+	Token string
+	// HTTPClient sends the requests, http.DefaultClient is used when nil.
+	HTTPClient Doer
+}
+
+// New returns a client for the tsuru API in target, authenticated with the
+// given token.
+func New(target, token string) *Client {
+	return &Client{Target: target, Token: token}
+}
+
+func (c *Client) url(path string) string {
+	target := c.Target
+	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+		target = "http://" + target
+	}
+	return strings.TrimRight(target, "/") + "/" + apiVersion + path
+}
+
+func (c *Client) newRequest(method, path string, body io.Reader) (*http.Request, error) {
+	request, err := http.NewRequest(method, c.url(path), body)
+	if err != nil {
+		return nil, err
+	}
+	if c.Token != "" {
+		request.Header.Set("Authorization", "bearer "+c.Token)
+	}
+	return request, nil
+}
+
+// do sends the request, turning error responses into *errors.HTTP values.
+func (c *Client) do(request *http.Request) (*http.Response, error) {
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode >= http.StatusBadRequest {
+		defer response.Body.Close()
+		httpErr := &tsuruErrors.HTTP{Code: response.StatusCode, Message: response.Status}
+		body, _ := ioutil.ReadAll(response.Body)
+		if len(body) > 0 {
+			httpErr.Message = strings.TrimSpace(string(body))
+		}
+		return nil, httpErr
+	}
+	return response, nil
+}
+
+func (c *Client) get(path string, query url.Values) (*http.Response, error) {
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	request, err := c.newRequest("GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(request)
+}
+
+// getJSON decodes the response of a GET request into v. Responses without
+// content leave v untouched.
+func (c *Client) getJSON(path string, query url.Values, v interface{}) error {
+	response, err := c.get(path, query)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(response.Body).Decode(v)
+}
+
+func (c *Client) doForm(method, path string, values url.Values) (*http.Response, error) {
+	request, err := c.newRequest(method, path, strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.do(request)
+}
+
+// send sends a form, discarding the response.
+func (c *Client) send(method, path string, values url.Values) error {
+	response, err := c.doForm(method, path, values)
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
+
+// stream sends a form, writing the JSON stream sent in response to w.
+func (c *Client) stream(method, path string, values url.Values, w io.Writer) error {
+	response, err := c.doForm(method, path, values)
+	if err != nil {
+		return err
+	}
+	return StreamJSON(w, response)
+}
+
+// StreamJSON writes to w the messages of a response in the JSON streaming
+// format used by the tsuru API, like the ones of binds and env changes. It
+// returns the error sent in the stream, if any.
+func StreamJSON(w io.Writer, response *http.Response) error {
+	if response == nil {
+		return errors.New("response cannot be nil")
+	}
+	defer response.Body.Close()
+	var err error
+	output := tsuruIo.NewStreamWriter(w, nil)
+	for n := int64(1); n > 0 && err == nil; n, err = io.Copy(output, response.Body) {
+	}
+	if err != nil {
+		return err
+	}
+	unparsed := output.Remaining()
+	if len(unparsed) > 0 {
+		return errors.Errorf("unparsed message error: %s", string(unparsed))
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package client
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestURL(c *check.C) {
+	cli := New("tsuru.example.com/", "")
+	c.Assert(cli.url("/apps"), check.Equals, "http://tsuru.example.com/1.0/apps")
+	cli = New("https://tsuru.example.com", "")
+	c.Assert(cli.url("/apps"), check.Equals, "https://tsuru.example.com/1.0/apps")
+}
+
+func (s *S) TestErrorResponse(c *check.C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("Authorization"), check.Equals, "bearer mytoken")
+		http.Error(w, "app not found", http.StatusNotFound)
+	}
+	_, err := s.client.AppInfo("myapp")
+	c.Assert(err, check.DeepEquals, &tsuruErrors.HTTP{Code: http.StatusNotFound, Message: "app not found"})
+}
+
+func (s *S) TestWithoutToken(c *check.C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("Authorization"), check.Equals, "")
+		w.Write([]byte(`{"name":"native","data":{}}`))
+	}
+	s.client.Token = ""
+	scheme, err := s.client.AuthScheme()
+	c.Assert(err, check.IsNil)
+	c.Assert(scheme, check.DeepEquals, &AuthScheme{Name: "native", Data: map[string]string{}})
+}
+
+func (s *S) TestStreamJSON(c *check.C) {
+	response := &http.Response{Body: ioutil.NopCloser(strings.NewReader(`{"Message":"binding\n"}` + "\n" + `{"Message":"done\n"}` + "\n"))}
+	var buf bytes.Buffer
+	err := StreamJSON(&buf, response)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Equals, "binding\ndone\n")
+	response = &http.Response{Body: ioutil.NopCloser(strings.NewReader(`{"Message":"binding\n"}` + "\n" + `{"Error":"bind failed"}` + "\n"))}
+	buf.Reset()
+	err = StreamJSON(&buf, response)
+	c.Assert(err, check.ErrorMatches, "bind failed")
+	c.Assert(buf.String(), check.Equals, "binding\n")
+}
@@ -0,0 +1,120 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package client
+
+import (
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+var httpSchemeRegexp = regexp.MustCompile(`^http`)
+
+// Event is an operation recorded by tsuru, like a deploy or a bind.
+type Event struct {
+	UniqueID  string
+	StartTime time.Time
+	EndTime   time.Time
+	Target    struct{ Type, Value string }
+	Kind      struct{ Type, Name string }
+	Owner     struct{ Type, Name string }
+	Error     string
+	Log       string
+	Running   bool
+}
+
+// EventFilter filters the events returned by ListEvents and WatchEvents.
+// Empty fields match every event.
+type EventFilter struct {
+	Kind        string
+	TargetType  string
+	TargetValue string
+	OwnerName   string
+	Running     bool
+	Limit       int
+}
+
+func (f EventFilter) values() url.Values {
+	query := url.Values{}
+	if f.Kind != "" {
+		query.Set("kindname", f.Kind)
+	}
+	if f.TargetType != "" {
+		query.Set("target.type", f.TargetType)
+	}
+	if f.TargetValue != "" {
+		query.Set("target.value", f.TargetValue)
+	}
+	if f.OwnerName != "" {
+		query.Set("ownername", f.OwnerName)
+	}
+	if f.Running {
+		query.Set("running", "true")
+	}
+	if f.Limit > 0 {
+		query.Set("limit", strconv.Itoa(f.Limit))
+	}
+	return query
+}
+
+// ListEvents returns the events matching the filter the client has access
+// to, most recent first.
+func (c *Client) ListEvents(filter EventFilter) ([]Event, error) {
+	var events []Event
+	err := c.getJSON("/events", filter.values(), &events)
+	return events, err
+}
+
+// EventInfo returns the event with the given ID.
+func (c *Client) EventInfo(id string) (*Event, error) {
+	var evt Event
+	err := c.getJSON("/events/"+id, nil, &evt)
+	if err != nil {
+		return nil, err
+	}
+	return &evt, nil
+}
+
+// CancelEvent asks the running event to be canceled, for the given reason.
+func (c *Client) CancelEvent(id, reason string) error {
+	return c.send("POST", "/events/"+id+"/cancel", url.Values{"reason": []string{reason}})
+}
+
+// WatchEvents calls fn with every event matching the filter as it starts
+// and finishes, until the stream is closed by the API or fn returns an
+// error, which is then returned.
+func (c *Client) WatchEvents(filter EventFilter, fn func(Event) error) error {
+	serverURL := httpSchemeRegexp.ReplaceAllString(c.url("/events/stream?"+filter.values().Encode()), "ws")
+	config, err := websocket.NewConfig(serverURL, "ws://localhost")
+	if err != nil {
+		return err
+	}
+	if c.Token != "" {
+		config.Header.Set("Authorization", "bearer "+c.Token)
+	}
+	conn, err := websocket.DialConfig(config)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	for {
+		var evt Event
+		err = websocket.JSON.Receive(conn, &evt)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = fn(evt)
+		if err != nil {
+			return err
+		}
+	}
+}
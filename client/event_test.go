@@ -0,0 +1,68 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package client
+
+import (
+	"errors"
+	"net/http"
+
+	"golang.org/x/net/websocket"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestListEvents(c *check.C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.RequestURI(), check.Equals, "/1.0/events?kindname=app.deploy&limit=10&running=true&target.type=app&target.value=myapp")
+		w.Write([]byte(`[{"UniqueID":"5a1b2c3d4e5f60718293a4b5","Running":true,"Kind":{"Type":"permission","Name":"app.deploy"}}]`))
+	}
+	events, err := s.client.ListEvents(EventFilter{Kind: "app.deploy", TargetType: "app", TargetValue: "myapp", Running: true, Limit: 10})
+	c.Assert(err, check.IsNil)
+	c.Assert(events, check.HasLen, 1)
+	c.Assert(events[0].UniqueID, check.Equals, "5a1b2c3d4e5f60718293a4b5")
+	c.Assert(events[0].Kind.Name, check.Equals, "app.deploy")
+	c.Assert(events[0].Running, check.Equals, true)
+}
+
+func (s *S) TestCancelEvent(c *check.C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "POST")
+		c.Check(r.URL.Path, check.Equals, "/1.0/events/5a1b2c3d4e5f60718293a4b5/cancel")
+		c.Check(r.FormValue("reason"), check.Equals, "wrong version")
+	}
+	err := s.client.CancelEvent("5a1b2c3d4e5f60718293a4b5", "wrong version")
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestWatchEvents(c *check.C) {
+	s.handler = websocket.Handler(func(conn *websocket.Conn) {
+		c.Check(conn.Request().URL.RequestURI(), check.Equals, "/1.0/events/stream?target.value=myapp")
+		c.Check(conn.Request().Header.Get("Authorization"), check.Equals, "bearer mytoken")
+		websocket.JSON.Send(conn, Event{UniqueID: "1", Running: true})
+		websocket.JSON.Send(conn, Event{UniqueID: "1"})
+	}).ServeHTTP
+	var events []Event
+	err := s.client.WatchEvents(EventFilter{TargetValue: "myapp"}, func(evt Event) error {
+		events = append(events, evt)
+		return nil
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(events, check.HasLen, 2)
+	c.Assert(events[0].Running, check.Equals, true)
+	c.Assert(events[1].Running, check.Equals, false)
+}
+
+func (s *S) TestWatchEventsStopsOnError(c *check.C) {
+	s.handler = websocket.Handler(func(conn *websocket.Conn) {
+		websocket.JSON.Send(conn, Event{UniqueID: "1"})
+		websocket.JSON.Send(conn, Event{UniqueID: "2"})
+	}).ServeHTTP
+	var calls int
+	err := s.client.WatchEvents(EventFilter{}, func(evt Event) error {
+		calls++
+		return errors.New("stop")
+	})
+	c.Assert(err, check.ErrorMatches, "stop")
+	c.Assert(calls, check.Equals, 1)
+}
@@ -0,0 +1,113 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package client
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Service is a service with the instances the client has access to.
+type Service struct {
+	Service          string            `json:"service"`
+	Instances        []string          `json:"instances"`
+	Plans            []string          `json:"plans"`
+	ServiceInstances []ServiceInstance `json:"service_instances"`
+}
+
+// ServiceInstance is an instance of a service.
+type ServiceInstance struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+// CreateServiceInstanceArgs holds the data of a service instance being
+// created.
+type CreateServiceInstanceArgs struct {
+	Name        string
+	Plan        string
+	TeamOwner   string
+	Description string
+	Tags        []string
+}
+
+// BindOptions holds the options of binds and unbinds.
+type BindOptions struct {
+	// NoRestart skips the restart of the app after the envs exported by the
+	// instance are changed.
+	NoRestart bool
+	// Wait, when set, makes the bind wait for the instance to be ready, up
+	// to the given duration.
+	Wait time.Duration
+}
+
+// ListServices returns the services and instances the client has access
+// to.
+func (c *Client) ListServices() ([]Service, error) {
+	var services []Service
+	err := c.getJSON("/services", nil, &services)
+	return services, err
+}
+
+// CreateServiceInstance creates an instance of the service.
+func (c *Client) CreateServiceInstance(serviceName string, args CreateServiceInstanceArgs) error {
+	values := url.Values{}
+	values.Set("name", args.Name)
+	values.Set("plan", args.Plan)
+	values.Set("owner", args.TeamOwner)
+	values.Set("description", args.Description)
+	for _, tag := range args.Tags {
+		values.Add("tag", tag)
+	}
+	return c.send("POST", fmt.Sprintf("/services/%s/instances", serviceName), values)
+}
+
+// RemoveServiceInstance removes the instance, writing the progress of the
+// removal to w. With unbindAll, the instance is unbound from its apps
+// before being removed.
+func (c *Client) RemoveServiceInstance(serviceName, instanceName string, unbindAll bool, w io.Writer) error {
+	path := fmt.Sprintf("/services/%s/instances/%s?unbindall=%t", serviceName, instanceName, unbindAll)
+	return c.stream("DELETE", path, nil, w)
+}
+
+// ServiceInstanceStatus returns the status of the instance, as reported by
+// the service.
+func (c *Client) ServiceInstanceStatus(serviceName, instanceName string) (string, error) {
+	response, err := c.get(fmt.Sprintf("/services/%s/instances/%s/status", serviceName, instanceName), nil)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+	prefix := fmt.Sprintf("Service instance %q is ", instanceName)
+	return strings.TrimPrefix(strings.TrimSpace(string(data)), prefix), nil
+}
+
+// BindServiceInstance binds the app to the instance, writing the progress
+// of the bind to w.
+func (c *Client) BindServiceInstance(serviceName, instanceName, appName string, opts BindOptions, w io.Writer) error {
+	values := url.Values{}
+	values.Set("noRestart", strconv.FormatBool(opts.NoRestart))
+	if opts.Wait > 0 {
+		values.Set("wait", strconv.Itoa(int(opts.Wait/time.Second)))
+	}
+	path := fmt.Sprintf("/services/%s/instances/%s/%s", serviceName, instanceName, appName)
+	return c.stream("PUT", path, values, w)
+}
+
+// UnbindServiceInstance unbinds the app from the instance, writing the
+// progress of the unbind to w.
+func (c *Client) UnbindServiceInstance(serviceName, instanceName, appName string, opts BindOptions, w io.Writer) error {
+	path := fmt.Sprintf("/services/%s/instances/%s/%s?noRestart=%t", serviceName, instanceName, appName, opts.NoRestart)
+	return c.stream("DELETE", path, nil, w)
+}
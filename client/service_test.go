@@ -0,0 +1,63 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package client
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestListServices(c *check.C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/1.0/services")
+		w.Write([]byte(`[{"service":"mysql","instances":["mydb"],"plans":["small"],"service_instances":[{"name":"mydb","tags":["prod"]}]}]`))
+	}
+	services, err := s.client.ListServices()
+	c.Assert(err, check.IsNil)
+	c.Assert(services, check.DeepEquals, []Service{{
+		Service:          "mysql",
+		Instances:        []string{"mydb"},
+		Plans:            []string{"small"},
+		ServiceInstances: []ServiceInstance{{Name: "mydb", Tags: []string{"prod"}}},
+	}})
+}
+
+func (s *S) TestServiceInstanceStatus(c *check.C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/1.0/services/mysql/instances/mydb/status")
+		w.Write([]byte(`Service instance "mydb" is up`))
+	}
+	status, err := s.client.ServiceInstanceStatus("mysql", "mydb")
+	c.Assert(err, check.IsNil)
+	c.Assert(status, check.Equals, "up")
+}
+
+func (s *S) TestBindServiceInstance(c *check.C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "PUT")
+		c.Check(r.URL.Path, check.Equals, "/1.0/services/mysql/instances/mydb/myapp")
+		c.Check(r.FormValue("noRestart"), check.Equals, "true")
+		c.Check(r.FormValue("wait"), check.Equals, "30")
+		w.Write([]byte(`{"Message":"binding\n"}` + "\n"))
+	}
+	var buf bytes.Buffer
+	err := s.client.BindServiceInstance("mysql", "mydb", "myapp", BindOptions{NoRestart: true, Wait: 30 * time.Second}, &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Equals, "binding\n")
+}
+
+func (s *S) TestUnbindServiceInstance(c *check.C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "DELETE")
+		c.Check(r.URL.RequestURI(), check.Equals, "/1.0/services/mysql/instances/mydb/myapp?noRestart=false")
+		w.Write([]byte(`{"Error":"unbind failed"}` + "\n"))
+	}
+	var buf bytes.Buffer
+	err := s.client.UnbindServiceInstance("mysql", "mydb", "myapp", BindOptions{}, &buf)
+	c.Assert(err, check.ErrorMatches, "unbind failed")
+}
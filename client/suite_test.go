@@ -0,0 +1,35 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gopkg.in/check.v1"
+)
+
+type S struct {
+	server  *httptest.Server
+	handler http.HandlerFunc
+	client  *Client
+}
+
+var _ = check.Suite(&S{})
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+func (s *S) SetUpTest(c *check.C) {
+	s.handler = nil
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.handler(w, r)
+	}))
+	s.client = New(s.server.URL, "mytoken")
+}
+
+func (s *S) TearDownTest(c *check.C) {
+	s.server.Close()
+}
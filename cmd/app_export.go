@@ -0,0 +1,156 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+
+	"github.com/tsuru/gnuflag"
+)
+
+type AppExport struct {
+	GuessingCommand
+	fs     *gnuflag.FlagSet
+	output string
+}
+
+func (c *AppExport) Info() *Info {
+	return &Info{
+		Name:  "app-export",
+		Usage: "app-export [-a/--app <appname>] [-o/--output <file>]",
+		Desc: `Exports the metadata, public environment variables, binds and current image
+of an app, to recreate it in another tsuru installation with app-import. The
+values of private environment variables and secrets aren't exported, only
+their names, and variables managed by tsuru, like TSURU_APP_TOKEN, are left
+out.
+
+The export is written to the standard output unless [[--output]] is given.`,
+	}
+}
+
+func (c *AppExport) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = c.GuessingCommand.Flags()
+		output := "Write the export to the given file"
+		c.fs.StringVar(&c.output, "output", "", output)
+		c.fs.StringVar(&c.output, "o", "", output)
+	}
+	return c.fs
+}
+
+func (c *AppExport) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	var exp json.RawMessage
+	err = getJSON(client, fmt.Sprintf("/apps/%s/export", appName), &exp)
+	if err != nil {
+		return err
+	}
+	if c.output == "" {
+		_, err = fmt.Fprintf(context.Stdout, "%s\n", exp)
+		return err
+	}
+	err = ioutil.WriteFile(c.output, exp, 0600)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "App %q exported to %s.\n", appName, c.output)
+	return nil
+}
+
+type AppImport struct {
+	fs        *gnuflag.FlagSet
+	name      string
+	pool      string
+	teamOwner string
+	plan      string
+}
+
+func (c *AppImport) Info() *Info {
+	return &Info{
+		Name:  "app-import",
+		Usage: "app-import <file> [-n/--name <appname>] [-o/--pool <pool>] [-t/--team <team>] [-p/--plan <plan>]",
+		Desc: `Creates an app from a file written by app-export, usually in another tsuru
+installation. The name, pool, team owner and plan of the exported app are
+used unless overridden by the flags.
+
+The public environment variables of the app are set and the app is bound to
+the exported service instances that exist in this installation. The names of
+the private environment variables, which must be set again with env-set, are
+displayed. The app isn't deployed, the exported image is displayed so it can
+be deployed once the registry is reachable.`,
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (c *AppImport) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("app-import", gnuflag.ExitOnError)
+		name := "The name of the new app"
+		c.fs.StringVar(&c.name, "name", "", name)
+		c.fs.StringVar(&c.name, "n", "", name)
+		pool := "The pool of the new app"
+		c.fs.StringVar(&c.pool, "pool", "", pool)
+		c.fs.StringVar(&c.pool, "o", "", pool)
+		team := "The team owner of the new app"
+		c.fs.StringVar(&c.teamOwner, "team", "", team)
+		c.fs.StringVar(&c.teamOwner, "t", "", team)
+		plan := "The plan of the new app"
+		c.fs.StringVar(&c.plan, "plan", "", plan)
+		c.fs.StringVar(&c.plan, "p", "", plan)
+	}
+	return c.fs
+}
+
+func (c *AppImport) Run(context *Context, client *Client) error {
+	data, err := ioutil.ReadFile(context.Args[0])
+	if err != nil {
+		return err
+	}
+	values := url.Values{}
+	values.Set("export", string(data))
+	values.Set("name", c.name)
+	values.Set("pool", c.pool)
+	values.Set("teamowner", c.teamOwner)
+	values.Set("plan", c.plan)
+	resp, err := doForm(client, "POST", "/apps/import", values)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		App          string
+		Envs         int
+		PrivateEnvs  []string
+		Binds        []string
+		MissingBinds []string
+		Image        string
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "App %q imported with %d environment variables.\n", result.App, result.Envs)
+	if len(result.PrivateEnvs) > 0 {
+		fmt.Fprintf(context.Stdout, "Private environment variables not exported, set them manually: %s.\n", strings.Join(result.PrivateEnvs, ", "))
+	}
+	if len(result.Binds) > 0 {
+		fmt.Fprintf(context.Stdout, "Bound to: %s.\n", strings.Join(result.Binds, ", "))
+	}
+	if len(result.MissingBinds) > 0 {
+		fmt.Fprintf(context.Stdout, "Instances not found, bind them manually: %s.\n", strings.Join(result.MissingBinds, ", "))
+	}
+	if result.Image != "" {
+		fmt.Fprintf(context.Stdout, "The app isn't deployed, the exported image is %s.\n", result.Image)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+const appExportJSON = `{"Version":1,"Name":"myapp","Platform":"python","Env":[{"name":"LOG_LEVEL","value":"info","public":true}]}`
+
+func (s *S) TestAppExportInfo(c *check.C) {
+	c.Assert((&AppExport{}).Info(), check.NotNil)
+}
+
+func (s *S) TestAppExportRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: appExportJSON, Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.0/apps/myapp/export"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := AppExport{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, appExportJSON+"\n")
+}
+
+func (s *S) TestAppExportRunToFile(c *check.C) {
+	output := filepath.Join(c.MkDir(), "myapp.json")
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.Transport{Message: appExportJSON, Status: http.StatusOK}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := AppExport{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp", "-o", output})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "App \"myapp\" exported to "+output+".\n")
+	data, err := ioutil.ReadFile(output)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, appExportJSON)
+}
+
+func (s *S) TestAppImportInfo(c *check.C) {
+	c.Assert((&AppImport{}).Info(), check.NotNil)
+}
+
+func (s *S) TestAppImportRun(c *check.C) {
+	input := filepath.Join(c.MkDir(), "myapp.json")
+	err := ioutil.WriteFile(input, []byte(appExportJSON), 0600)
+	c.Assert(err, check.IsNil)
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{input}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `{"App":"newapp","Envs":1,"PrivateEnvs":["API_KEY"],"Binds":["mysql/db1"],"MissingBinds":["redis/cache"],"Image":"registry.example.com/tsuru/app-myapp:v3"}`,
+			Status:  http.StatusCreated,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "POST" && req.URL.Path == "/1.0/apps/import" &&
+				req.FormValue("export") == appExportJSON &&
+				req.FormValue("name") == "newapp" &&
+				req.FormValue("pool") == "pool2"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := AppImport{}
+	err = command.Flags().Parse(true, []string{"-n", "newapp", "--pool", "pool2"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `App "newapp" imported with 1 environment variables.
+Private environment variables not exported, set them manually: API_KEY.
+Bound to: mysql/db1.
+Instances not found, bind them manually: redis/cache.
+The app isn't deployed, the exported image is registry.example.com/tsuru/app-myapp:v3.
+`)
+}
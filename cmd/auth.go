@@ -8,12 +8,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
-	"net/url"
 	"os"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	tsuruNet "github.com/tsuru/tsuru/net"
@@ -43,34 +42,16 @@ func nativeLogin(context *Context, client *Client) error {
 		return err
 	}
 	fmt.Fprintln(context.Stdout)
-	u, err := GetURL("/users/" + email + "/tokens")
+	apiClient, err := client.APIClient()
 	if err != nil {
 		return err
 	}
-	v := url.Values{}
-	v.Set("password", password)
-	b := strings.NewReader(v.Encode())
-	request, err := http.NewRequest("POST", u, b)
-	if err != nil {
-		return err
-	}
-	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	response, err := client.Do(request)
-	if err != nil {
-		return err
-	}
-	defer response.Body.Close()
-	result, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		return err
-	}
-	out := make(map[string]interface{})
-	err = json.Unmarshal(result, &out)
+	token, err := apiClient.Login(email, password)
 	if err != nil {
 		return err
 	}
 	fmt.Fprintln(context.Stdout, "Successfully logged in!")
-	return writeToken(out["token"].(string))
+	return writeToken(token)
 }
 
 func (c *login) getScheme() *loginScheme {
@@ -148,6 +129,15 @@ type APIUser struct {
 	Email       string
 	Roles       []APIRolePermissionData
 	Permissions []APIRolePermissionData
+	Teams       []string
+	Token       *APITokenInfo
+}
+
+// APITokenInfo describes the token used to authenticate in the tsuru API.
+type APITokenInfo struct {
+	Kind    string
+	App     string
+	Expires *time.Time
 }
 
 func (u *APIUser) RoleInstances() []string {
@@ -212,6 +202,19 @@ func (userInfo) Run(context *Context, client *Client) error {
 		return err
 	}
 	fmt.Fprintf(context.Stdout, "Email: %s\n", u.Email)
+	if len(u.Teams) > 0 {
+		fmt.Fprintf(context.Stdout, "Teams: %s\n", strings.Join(u.Teams, ", "))
+	}
+	if u.Token != nil {
+		token := u.Token.Kind
+		if u.Token.App != "" {
+			token += " (" + u.Token.App + ")"
+		}
+		if u.Token.Expires != nil {
+			token += ", expires at " + u.Token.Expires.Local().Format(time.RFC1123)
+		}
+		fmt.Fprintf(context.Stdout, "Token: %s\n", token)
+	}
 	roles := u.RoleInstances()
 	if len(roles) > 0 {
 		fmt.Fprintf(context.Stdout, "Roles:\n\t%s\n", strings.Join(roles, "\n\t"))
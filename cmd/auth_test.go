@@ -12,6 +12,7 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/tsuru/tsuru/cmd/cmdtest"
 	"github.com/tsuru/tsuru/fs/fstest"
@@ -325,6 +326,35 @@ Permissions:
 	c.Assert(called, check.Equals, true)
 }
 
+func (s *S) TestUserInfoRunWithTeamsAndToken(c *check.C) {
+	expires := time.Date(2018, 7, 1, 12, 0, 0, 0, time.UTC)
+	expected := `Email: myuser@company.com
+Teams: team1, team2
+Token: session, expires at ` + expires.Local().Format(time.RFC1123) + `
+Roles:
+	x(team team1)
+`
+	context := Context{[]string{}, globalManager.stdout, globalManager.stderr, globalManager.stdin}
+	command := userInfo{}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `{"Email":"myuser@company.com","Roles":[
+	{"Name":"x","ContextType":"team","ContextValue":"team1"}
+],
+"Teams":["team1","team2"],
+"Token":{"Kind":"session","Expires":"2018-07-01T12:00:00Z"}}`,
+			Status: http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.0/users/info"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(globalManager.stdout.(*bytes.Buffer).String(), check.Equals, expected)
+}
+
 func (s *S) TestPasswordFromReaderUsingFile(c *check.C) {
 	tmpdir, err := filepath.EvalSymlinks(os.TempDir())
 	filename := path.Join(tmpdir, "password-reader.txt")
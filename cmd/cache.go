@@ -0,0 +1,155 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/gnuflag"
+)
+
+var errNoCachedData = errors.New("no cached data available for this command, run it once while the tsuru server is reachable")
+
+type cacheEntry struct {
+	SavedAt time.Time
+	Body    []byte
+}
+
+// Embed this struct in read-only commands to keep the last successful
+// responses of the tsuru server for the current target. It adds the --cached
+// flag, which displays the kept responses instead of contacting the server,
+// useful for incident triage when the server is unreachable.
+type CachedCommand struct {
+	fs       *gnuflag.FlagSet
+	cached   bool
+	notified bool
+}
+
+func (cmd *CachedCommand) Flags() *gnuflag.FlagSet {
+	if cmd.fs == nil {
+		cmd.fs = gnuflag.NewFlagSet("", gnuflag.ExitOnError)
+		cmd.fs.BoolVar(&cmd.cached, "cached", false, "Display the data cached by the last successful run, without contacting the tsuru server.")
+	}
+	return cmd.fs
+}
+
+// Get requests path, keeping the response body in the cache of the current
+// target. With --cached, the body is read from the cache instead and a notice
+// with its age is written to the command's stderr, once.
+func (cmd *CachedCommand) Get(context *Context, client *Client, path string) ([]byte, error) {
+	target, err := GetTarget()
+	if err != nil {
+		return nil, err
+	}
+	if cmd.cached {
+		entry, err := readCacheEntry(target, path)
+		if err != nil {
+			return nil, err
+		}
+		if !cmd.notified {
+			cmd.notified = true
+			fmt.Fprintf(context.Stderr, "WARNING: displaying cached data from %s, it may be stale.\n\n", entry.SavedAt.Local().Format(time.RFC1123))
+		}
+		return entry.Body, nil
+	}
+	url, err := GetURL(path)
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var body []byte
+	if resp.StatusCode != http.StatusNoContent {
+		body, err = ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	writeCacheEntry(target, path, body)
+	return body, nil
+}
+
+// GetJSON is like Get, decoding the body into v. Empty bodies leave v
+// untouched.
+func (cmd *CachedCommand) GetJSON(context *Context, client *Client, path string, v interface{}) error {
+	body, err := cmd.Get(context, client, path)
+	if err != nil || len(body) == 0 {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+func cachePath(target string) string {
+	sum := sha256.Sum256([]byte(target))
+	return JoinWithUserDir(".tsuru", "cache", hex.EncodeToString(sum[:16])+".json")
+}
+
+func readCache(target string) (map[string]cacheEntry, error) {
+	entries := map[string]cacheEntry{}
+	f, err := filesystem().Open(cachePath(target))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return entries, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	err = json.NewDecoder(f).Decode(&entries)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read the cache")
+	}
+	return entries, nil
+}
+
+func readCacheEntry(target, path string) (*cacheEntry, error) {
+	entries, err := readCache(target)
+	if err != nil {
+		return nil, err
+	}
+	entry, ok := entries[path]
+	if !ok {
+		return nil, errNoCachedData
+	}
+	return &entry, nil
+}
+
+// writeCacheEntry stores the body in the cache. Failures are ignored, the
+// cache is only a convenience and must not break commands.
+func writeCacheEntry(target, path string, body []byte) {
+	entries, err := readCache(target)
+	if err != nil {
+		entries = map[string]cacheEntry{}
+	}
+	entries[path] = cacheEntry{SavedAt: time.Now(), Body: body}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return
+	}
+	if err = filesystem().MkdirAll(JoinWithUserDir(".tsuru", "cache"), 0700); err != nil {
+		return
+	}
+	f, err := filesystem().OpenFile(cachePath(target), syscall.O_WRONLY|syscall.O_CREAT|syscall.O_TRUNC, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.Write(data)
+}
@@ -0,0 +1,81 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/fs/fstest"
+	"gopkg.in/check.v1"
+)
+
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, errors.New("connection refused")
+}
+
+func (s *S) TestServiceListRunCached(c *check.C) {
+	fsystem = &fstest.RecordingFs{}
+	defer func() {
+		fsystem = nil
+	}()
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	client := NewClient(&http.Client{Transport: serviceTransport()}, nil, globalManager)
+	command := ServiceList{}
+	err := command.Flags().Parse(true, []string{"-s"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	expected := stdout.String()
+	stdout.Reset()
+	client = NewClient(&http.Client{Transport: failingTransport{}}, nil, globalManager)
+	command = ServiceList{}
+	err = command.Flags().Parse(true, []string{"-s", "--cached"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, expected)
+	c.Assert(stderr.String(), check.Matches, "WARNING: displaying cached data from .*, it may be stale.\n\n")
+}
+
+func (s *S) TestCachedCommandWithoutCache(c *check.C) {
+	fsystem = &fstest.RecordingFs{}
+	defer func() {
+		fsystem = nil
+	}()
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	client := NewClient(&http.Client{Transport: failingTransport{}}, nil, globalManager)
+	command := DockerNodeList{}
+	err := command.Flags().Parse(true, []string{"--cached"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.Equals, errNoCachedData)
+}
+
+func (s *S) TestCachedCommandPerTarget(c *check.C) {
+	fsystem = &fstest.RecordingFs{}
+	defer func() {
+		fsystem = nil
+	}()
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	client := NewClient(&http.Client{Transport: serviceTransport()}, nil, globalManager)
+	var cmd CachedCommand
+	_, err := cmd.Get(&context, client, "/services/instances")
+	c.Assert(err, check.IsNil)
+	cmd.cached = true
+	body, err := cmd.Get(&context, client, "/services/instances")
+	c.Assert(err, check.IsNil)
+	c.Assert(body, check.Not(check.HasLen), 0)
+	os.Setenv("TSURU_TARGET", "http://other.tsuru.io")
+	_, err = cmd.Get(&context, client, "/services/instances")
+	c.Assert(err, check.Equals, errNoCachedData)
+}
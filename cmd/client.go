@@ -15,8 +15,8 @@ import (
 	"strconv"
 
 	"github.com/pkg/errors"
+	tsuruClient "github.com/tsuru/tsuru/client"
 	tsuruerr "github.com/tsuru/tsuru/errors"
-	tsuruio "github.com/tsuru/tsuru/io"
 )
 
 const (
@@ -132,20 +132,15 @@ and download the last version.
 
 // StreamJSONResponse supports the JSON streaming format from the tsuru API.
 func StreamJSONResponse(w io.Writer, response *http.Response) error {
-	if response == nil {
-		return errors.New("response cannot be nil")
-	}
-	defer response.Body.Close()
-	var err error
-	output := tsuruio.NewStreamWriter(w, nil)
-	for n := int64(1); n > 0 && err == nil; n, err = io.Copy(output, response.Body) {
-	}
+	return tsuruClient.StreamJSON(w, response)
+}
+
+// APIClient returns a client for the tsuru API in the current target,
+// sending requests through c, which handles the authentication.
+func (c *Client) APIClient() (*tsuruClient.Client, error) {
+	target, err := GetTarget()
 	if err != nil {
-		return err
-	}
-	unparsed := output.Remaining()
-	if len(unparsed) > 0 {
-		return errors.Errorf("unparsed message error: %s", string(unparsed))
+		return nil, err
 	}
-	return nil
+	return &tsuruClient.Client{Target: target, HTTPClient: c}, nil
 }
@@ -0,0 +1,399 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/gnuflag"
+	tsuruClient "github.com/tsuru/tsuru/client"
+)
+
+const ignoreFileName = ".tsuruignore"
+
+type AppDeploy struct {
+	GuessingCommand
+	fs         *gnuflag.FlagSet
+	message    string
+	interrupts chan os.Signal
+}
+
+func (c *AppDeploy) Info() *Info {
+	return &Info{
+		Name:  "app-deploy",
+		Usage: "app-deploy [-a/--app <appname>] [-m/--message <message>] <file-or-directory> ...",
+		Desc: `Deploys a set of files and/or directories to tsuru server. Some examples of
+calls are:
+
+::
+
+    $ tsuru app-deploy .
+    $ tsuru app-deploy myfile.jar Procfile
+
+The files are archived and uploaded to the server, and the output of the build
+and roll-out is displayed until the deploy finishes. The command fails if the
+deploy fails. Pressing Ctrl-C while the deploy is running cancels it, the
+server stops the deploy at the next safe point and removes the partially
+created containers and images.
+
+Files matching the patterns in the .tsuruignore file of a deployed directory,
+one pattern per line, are not sent to the server.`,
+		MinArgs: 1,
+	}
+}
+
+func (c *AppDeploy) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = c.GuessingCommand.Flags()
+		message := "A message describing this deploy"
+		c.fs.StringVar(&c.message, "message", "", message)
+		c.fs.StringVar(&c.message, "m", "", message)
+	}
+	return c.fs
+}
+
+func (c *AppDeploy) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	var archive bytes.Buffer
+	err = buildArchive(&archive, context.Args)
+	if err != nil {
+		return err
+	}
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	if c.message != "" {
+		writer.WriteField("message", c.message)
+	}
+	part, err := writer.CreateFormFile("file", "archive.tar.gz")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(part, &archive)
+	if err != nil {
+		return err
+	}
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+	u, err := GetURL(fmt.Sprintf("/apps/%s/deploy", appName))
+	if err != nil {
+		return err
+	}
+	progress := &progressReader{r: &body, total: int64(body.Len()), w: context.Stdout}
+	request, err := http.NewRequest("POST", u, progress)
+	if err != nil {
+		return err
+	}
+	request.ContentLength = progress.total
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	done := make(chan struct{})
+	defer close(done)
+	go c.cancelOnInterrupt(context, client, appName, done)
+	output := &lastLineWriter{w: context.Stdout}
+	_, err = io.Copy(output, resp.Body)
+	if err != nil {
+		return err
+	}
+	if output.lastLine() != "OK" {
+		return ErrAbortCommand
+	}
+	return nil
+}
+
+// cancelOnInterrupt waits for an interrupt until done is closed, asking the
+// API to cancel the running deploy of the app when one arrives. The deploy
+// output keeps being streamed, so the user can follow the cleanup.
+func (c *AppDeploy) cancelOnInterrupt(context *Context, client *Client, appName string, done <-chan struct{}) {
+	interrupts := c.interrupts
+	if interrupts == nil {
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, os.Interrupt)
+		defer signal.Stop(ch)
+		interrupts = ch
+	}
+	select {
+	case <-interrupts:
+	case <-done:
+		return
+	}
+	fmt.Fprintln(context.Stderr, "\nCanceling deploy...")
+	err := cancelRunningDeploy(client, appName)
+	if err != nil {
+		fmt.Fprintf(context.Stderr, "Unable to cancel deploy: %s\n", err)
+	}
+}
+
+func cancelRunningDeploy(client *Client, appName string) error {
+	apiClient, err := client.APIClient()
+	if err != nil {
+		return err
+	}
+	events, err := apiClient.ListEvents(tsuruClient.EventFilter{
+		Kind:        "app.deploy",
+		TargetType:  "app",
+		TargetValue: appName,
+		Running:     true,
+	})
+	if err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		return errors.Errorf("no running deploy found for app %q", appName)
+	}
+	return apiClient.CancelDeploy(appName, events[0].UniqueID)
+}
+
+type deployData struct {
+	ID        string
+	App       string
+	Timestamp time.Time
+	Duration  time.Duration
+	Error     string
+	Image     string
+	User      string
+	Origin    string
+}
+
+type AppDeployList struct {
+	GuessingCommand
+	PaginatedCommand
+	fs *gnuflag.FlagSet
+}
+
+func (c *AppDeployList) Info() *Info {
+	return &Info{
+		Name:  "app-deploy-list",
+		Usage: "app-deploy-list [-a/--app <appname>] [--max-results <n>] [--all]",
+		Desc: `Lists the deploys of an app, most recent first.
+
+By default only the last 100 deploys are displayed, use [[--max-results]] to
+change this limit or [[--all]] to display every deploy.`,
+	}
+}
+
+func (c *AppDeployList) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = MergeFlagSet(c.GuessingCommand.Flags(), c.PaginatedCommand.Flags())
+	}
+	return c.fs
+}
+
+func (c *AppDeployList) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	table := NewTable()
+	table.Headers = Row{"ID", "Image", "Origin", "User", "Date (Duration)", "Error"}
+	err = c.FetchPages(client, "/deploys", url.Values{"app": []string{appName}}, func(r io.Reader) (int, error) {
+		var deploys []deployData
+		err := json.NewDecoder(r).Decode(&deploys)
+		if err != nil {
+			return 0, err
+		}
+		for _, d := range deploys {
+			date := fmt.Sprintf("%s (%s)", d.Timestamp.Local().Format(time.Stamp), d.Duration)
+			table.AddRow(Row{d.ID, d.Image, d.Origin, d.User, date, d.Error})
+		}
+		return len(deploys), nil
+	})
+	if err != nil {
+		return err
+	}
+	if table.Rows() == 0 {
+		fmt.Fprintf(context.Stdout, "App %q has no deploys.\n", appName)
+		return nil
+	}
+	context.Stdout.Write(table.Bytes())
+	return nil
+}
+
+// progressReader reports to w how much of the request body was already sent.
+type progressReader struct {
+	r     io.Reader
+	w     io.Writer
+	total int64
+	read  int64
+	done  bool
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += int64(n)
+	if p.done {
+		return n, err
+	}
+	percent := 100.0
+	if p.total > 0 {
+		percent = float64(p.read) * 100 / float64(p.total)
+	}
+	fmt.Fprintf(p.w, "\rUploading files (%.2fMB)... %.2f%%", float64(p.total)/(1024*1024), percent)
+	if p.read >= p.total {
+		p.done = true
+		fmt.Fprintln(p.w, " Processing ok")
+	}
+	return n, err
+}
+
+// lastLineWriter forwards everything to w, keeping track of the last
+// non-empty line written.
+type lastLineWriter struct {
+	w    io.Writer
+	tail []byte
+}
+
+func (l *lastLineWriter) Write(b []byte) (int, error) {
+	l.tail = append(l.tail, b...)
+	if idx := bytes.LastIndexByte(bytes.TrimRight(l.tail, "\n"), '\n'); idx >= 0 {
+		l.tail = l.tail[idx+1:]
+	}
+	return l.w.Write(b)
+}
+
+func (l *lastLineWriter) lastLine() string {
+	return strings.TrimSpace(string(l.tail))
+}
+
+type ignorePatterns []string
+
+func readIgnorePatterns(dir string) (ignorePatterns, error) {
+	f, err := os.Open(filepath.Join(dir, ignoreFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	var patterns ignorePatterns
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, strings.Trim(line, "/"))
+	}
+	return patterns, scanner.Err()
+}
+
+// match reports whether the path, relative to the deployed directory, is
+// matched by any of the patterns. Patterns are matched against both the full
+// path and its base name.
+func (p ignorePatterns) match(path string) bool {
+	path = filepath.ToSlash(path)
+	for _, pattern := range p {
+		if ok, _ := filepath.Match(pattern, path); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, filepath.Base(path)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// buildArchive writes to w a gzipped tarball with the given files and
+// directories. The contents of each directory are added to the root of the
+// archive, honoring its .tsuruignore file.
+func buildArchive(w io.Writer, paths []string) error {
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return errors.Wrapf(err, "unable to add %q to the archive", path)
+		}
+		if !fi.IsDir() {
+			err = addFileToArchive(tarWriter, path, filepath.Base(path), fi)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		patterns, err := readIgnorePatterns(path)
+		if err != nil {
+			return err
+		}
+		err = filepath.Walk(path, func(filePath string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			relPath, err := filepath.Rel(path, filePath)
+			if err != nil || relPath == "." {
+				return err
+			}
+			if patterns.match(relPath) {
+				if fi.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			return addFileToArchive(tarWriter, filePath, relPath, fi)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	err := tarWriter.Close()
+	if err != nil {
+		return err
+	}
+	return gzipWriter.Close()
+}
+
+func addFileToArchive(w *tar.Writer, path, name string, fi os.FileInfo) error {
+	var link string
+	if fi.Mode()&os.ModeSymlink != 0 {
+		var err error
+		link, err = os.Readlink(path)
+		if err != nil {
+			return err
+		}
+	}
+	header, err := tar.FileInfoHeader(fi, link)
+	if err != nil {
+		return err
+	}
+	header.Name = filepath.ToSlash(name)
+	err = w.WriteHeader(header)
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
@@ -0,0 +1,216 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) createDeployDir(c *check.C) string {
+	dir := c.MkDir()
+	files := map[string]string{
+		"Procfile":            "web: ./app",
+		"app/main.go":         "package main",
+		"app/main_test.go":    "package main",
+		"tmp/cache":           "cached",
+		"logs/app.log":        "log",
+		ignoreFileName:        "# build artifacts\n*_test.go\n/tmp/\n\nlogs\n",
+		"app/static/main.css": "body {}",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		c.Assert(err, check.IsNil)
+		err = ioutil.WriteFile(path, []byte(content), 0644)
+		c.Assert(err, check.IsNil)
+	}
+	return dir
+}
+
+func archiveFiles(c *check.C, r io.Reader) map[string]string {
+	gzipReader, err := gzip.NewReader(r)
+	c.Assert(err, check.IsNil)
+	tarReader := tar.NewReader(gzipReader)
+	files := map[string]string{}
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		c.Assert(err, check.IsNil)
+		if header.Typeflag == tar.TypeDir {
+			files[header.Name] = ""
+			continue
+		}
+		data, err := ioutil.ReadAll(tarReader)
+		c.Assert(err, check.IsNil)
+		files[header.Name] = string(data)
+	}
+	return files
+}
+
+func (s *S) TestAppDeployInfo(c *check.C) {
+	c.Assert((&AppDeploy{}).Info(), check.NotNil)
+}
+
+func (s *S) TestBuildArchive(c *check.C) {
+	dir := s.createDeployDir(c)
+	extra := filepath.Join(c.MkDir(), "app.jar")
+	err := ioutil.WriteFile(extra, []byte("jar"), 0644)
+	c.Assert(err, check.IsNil)
+	var buf bytes.Buffer
+	err = buildArchive(&buf, []string{dir, extra})
+	c.Assert(err, check.IsNil)
+	files := archiveFiles(c, &buf)
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	c.Assert(names, check.DeepEquals, []string{
+		ignoreFileName,
+		"Procfile",
+		"app",
+		"app.jar",
+		"app/main.go",
+		"app/static",
+		"app/static/main.css",
+	})
+	c.Assert(files["Procfile"], check.Equals, "web: ./app")
+	c.Assert(files["app.jar"], check.Equals, "jar")
+}
+
+func (s *S) TestBuildArchiveNotFound(c *check.C) {
+	var buf bytes.Buffer
+	err := buildArchive(&buf, []string{"/tmp/something/that/does/not/exist"})
+	c.Assert(err, check.ErrorMatches, `unable to add "/tmp/something/that/does/not/exist" to the archive: .*`)
+}
+
+func (s *S) TestAppDeployRun(c *check.C) {
+	dir := s.createDeployDir(c)
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{dir}, Stdout: &stdout, Stderr: &stderr}
+	var files map[string]string
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: "building\nrolling out\n\nOK\n", Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			if req.Method != "POST" || req.URL.Path != "/1.0/apps/myapp/deploy" {
+				return false
+			}
+			if req.FormValue("message") != "my deploy" {
+				return false
+			}
+			file, _, err := req.FormFile("file")
+			if err != nil {
+				return false
+			}
+			files = archiveFiles(c, file)
+			return true
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := AppDeploy{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp", "-m", "my deploy"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(files["app/main.go"], check.Equals, "package main")
+	c.Assert(strings.Contains(stdout.String(), "100.00% Processing ok\n"), check.Equals, true)
+	c.Assert(strings.HasSuffix(stdout.String(), "Processing ok\nbuilding\nrolling out\n\nOK\n"), check.Equals, true)
+}
+
+func (s *S) TestAppDeployRunFailure(c *check.C) {
+	dir := s.createDeployDir(c)
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{dir}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.Transport{Message: "building\nERROR: build failed\n", Status: http.StatusOK}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := AppDeploy{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.Equals, ErrAbortCommand)
+	c.Assert(strings.HasSuffix(stdout.String(), "building\nERROR: build failed\n"), check.Equals, true)
+}
+
+// cancelDeployTransport streams the deploy output until the deploy is
+// canceled, recording the requests it receives.
+type cancelDeployTransport struct {
+	output   *io.PipeWriter
+	requests []string
+}
+
+func (t *cancelDeployTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests = append(t.requests, req.Method+" "+req.URL.RequestURI())
+	var body io.ReadCloser
+	switch {
+	case req.URL.Path == "/1.0/apps/myapp/deploy":
+		var r *io.PipeReader
+		r, t.output = io.Pipe()
+		body = r
+		go t.output.Write([]byte("building\n"))
+	case req.URL.Path == "/1.0/events":
+		body = ioutil.NopCloser(strings.NewReader(`[{"UniqueID":"5a1b2c3d4e5f60718293a4b5","Running":true}]`))
+	default:
+		go func() {
+			t.output.Write([]byte("ERROR: deploy canceled by user action\n"))
+			t.output.Close()
+		}()
+		body = ioutil.NopCloser(strings.NewReader(""))
+	}
+	return &http.Response{Body: body, StatusCode: http.StatusOK, Header: http.Header{}}, nil
+}
+
+func (s *S) TestAppDeployRunInterrupted(c *check.C) {
+	dir := s.createDeployDir(c)
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{dir}, Stdout: &stdout, Stderr: &stderr}
+	transport := cancelDeployTransport{}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := AppDeploy{interrupts: make(chan os.Signal, 1)}
+	command.interrupts <- os.Interrupt
+	err := command.Flags().Parse(true, []string{"-a", "myapp"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.Equals, ErrAbortCommand)
+	c.Assert(transport.requests, check.DeepEquals, []string{
+		"POST /1.0/apps/myapp/deploy",
+		"GET /1.0/events?kindname=app.deploy&running=true&target.type=app&target.value=myapp",
+		"POST /1.0/apps/myapp/deploy/5a1b2c3d4e5f60718293a4b5/cancel",
+	})
+	c.Assert(stderr.String(), check.Equals, "\nCanceling deploy...\n")
+	c.Assert(strings.HasSuffix(stdout.String(), "ERROR: deploy canceled by user action\n"), check.Equals, true)
+}
+
+func (s *S) TestCancelRunningDeployNotRunning(c *check.C) {
+	transport := cmdtest.Transport{Status: http.StatusNoContent}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	err := cancelRunningDeploy(client, "myapp")
+	c.Assert(err, check.ErrorMatches, `no running deploy found for app "myapp"`)
+}
+
+func (s *S) TestLastLineWriter(c *check.C) {
+	var buf bytes.Buffer
+	w := &lastLineWriter{w: &buf}
+	w.Write([]byte("first line\nsec"))
+	c.Assert(w.lastLine(), check.Equals, "sec")
+	w.Write([]byte("ond line\n\nO"))
+	c.Assert(w.lastLine(), check.Equals, "O")
+	w.Write([]byte("K\n"))
+	c.Assert(w.lastLine(), check.Equals, "OK")
+	c.Assert(buf.String(), check.Equals, "first line\nsecond line\n\nOK\n")
+}
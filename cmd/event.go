@@ -0,0 +1,160 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/tsuru/gnuflag"
+	"golang.org/x/net/websocket"
+)
+
+// clearScreen moves the cursor to the top of the terminal and clears it.
+const clearScreen = "\033[H\033[2J"
+
+type eventData struct {
+	UniqueID  string
+	StartTime time.Time
+	EndTime   time.Time
+	Target    struct{ Type, Value string }
+	Kind      struct{ Type, Name string }
+	Owner     struct{ Type, Name string }
+	Error     string
+	Running   bool
+}
+
+type EventList struct {
+	PaginatedCommand
+	fs          *gnuflag.FlagSet
+	kind        string
+	targetType  string
+	targetValue string
+	running     bool
+	watch       bool
+}
+
+func (c *EventList) Info() *Info {
+	return &Info{
+		Name:  "event-list",
+		Usage: "event-list [-k/--kind <kind>] [-t/--target <type>] [-v/--target-value <value>] [-r/--running] [-w/--watch] [--max-results <n>] [--all]",
+		Desc: `Lists events that you have permission to see, most recent first.
+
+By default only the last 100 events are displayed, use [[--max-results]] to
+change this limit or [[--all]] to display every event.
+
+With [[--watch]], the list is refreshed every time an event matching the
+filters starts or finishes, until the command is interrupted.`,
+	}
+}
+
+func (c *EventList) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("event-list", gnuflag.ExitOnError)
+		desc := "Filter events by kind name"
+		c.fs.StringVar(&c.kind, "kind", "", desc)
+		c.fs.StringVar(&c.kind, "k", "", desc)
+		desc = "Filter events by target type"
+		c.fs.StringVar(&c.targetType, "target", "", desc)
+		c.fs.StringVar(&c.targetType, "t", "", desc)
+		desc = "Filter events by target value"
+		c.fs.StringVar(&c.targetValue, "target-value", "", desc)
+		c.fs.StringVar(&c.targetValue, "v", "", desc)
+		desc = "Display only running events"
+		c.fs.BoolVar(&c.running, "running", false, desc)
+		c.fs.BoolVar(&c.running, "r", false, desc)
+		desc = "Refresh the list as events start and finish"
+		c.fs.BoolVar(&c.watch, "watch", false, desc)
+		c.fs.BoolVar(&c.watch, "w", false, desc)
+		c.fs = MergeFlagSet(c.PaginatedCommand.Flags(), c.fs)
+	}
+	return c.fs
+}
+
+func (c *EventList) Run(context *Context, client *Client) error {
+	query := url.Values{}
+	if c.kind != "" {
+		query.Set("kindname", c.kind)
+	}
+	if c.targetType != "" {
+		query.Set("target.type", c.targetType)
+	}
+	if c.targetValue != "" {
+		query.Set("target.value", c.targetValue)
+	}
+	if c.running {
+		query.Set("running", "true")
+	}
+	output, err := c.render(client, query)
+	if err != nil {
+		return err
+	}
+	if !c.watch {
+		context.Stdout.Write(output)
+		return nil
+	}
+	return c.watchEvents(context, client, query, output)
+}
+
+// watchEvents redraws the list every time the event stream sends an event,
+// until the stream is closed.
+func (c *EventList) watchEvents(context *Context, client *Client, query url.Values, output []byte) error {
+	context.RawOutput()
+	conn, err := dialWebsocket("/events/stream?" + query.Encode())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	for {
+		fmt.Fprint(context.Stdout, clearScreen)
+		context.Stdout.Write(output)
+		var evt eventData
+		err = websocket.JSON.Receive(conn, &evt)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		output, err = c.render(client, query)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (c *EventList) render(client *Client, query url.Values) ([]byte, error) {
+	table := NewTable()
+	table.Headers = Row{"ID", "Start (duration)", "Success", "Owner", "Kind", "Target"}
+	err := c.FetchPages(client, "/events", query, func(r io.Reader) (int, error) {
+		var events []eventData
+		err := json.NewDecoder(r).Decode(&events)
+		if err != nil {
+			return 0, err
+		}
+		for _, evt := range events {
+			duration, success := "running", ""
+			if !evt.Running {
+				duration = evt.EndTime.Sub(evt.StartTime).String()
+				success = fmt.Sprintf("%t", evt.Error == "")
+			}
+			start := fmt.Sprintf("%s (%s)", evt.StartTime.Local().Format(time.Stamp), duration)
+			owner := fmt.Sprintf("%s %s", evt.Owner.Type, evt.Owner.Name)
+			target := fmt.Sprintf("%s: %s", evt.Target.Type, evt.Target.Value)
+			table.AddRow(Row{evt.UniqueID, start, success, owner, evt.Kind.Name, target})
+		}
+		return len(events), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if table.Rows() == 0 {
+		return []byte("No events found.\n"), nil
+	}
+	return table.Bytes(), nil
+}
@@ -0,0 +1,125 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"golang.org/x/net/websocket"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestEventListInfo(c *check.C) {
+	c.Assert((&EventList{}).Info(), check.NotNil)
+}
+
+func (s *S) TestEventListRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `[
+{"UniqueID":"e2","StartTime":"2018-01-02T10:00:00Z","Target":{"Type":"app","Value":"myapp"},"Kind":{"Type":"permission","Name":"app.deploy"},"Owner":{"Type":"user","Name":"me@me.com"},"Running":true},
+{"UniqueID":"e1","StartTime":"2018-01-01T10:00:00Z","EndTime":"2018-01-01T10:00:05Z","Target":{"Type":"app","Value":"myapp"},"Kind":{"Type":"permission","Name":"app.update.restart"},"Owner":{"Type":"user","Name":"me@me.com"},"Error":"failed"}
+]`,
+			Status: http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			query := req.URL.Query()
+			return req.Method == "GET" && req.URL.Path == "/1.0/events" &&
+				query.Get("kindname") == "app.deploy" &&
+				query.Get("target.type") == "app" &&
+				query.Get("target.value") == "myapp" &&
+				query.Get("skip") == "0" &&
+				query.Get("limit") == "10"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := EventList{}
+	err := command.Flags().Parse(true, []string{"-k", "app.deploy", "-t", "app", "-v", "myapp", "--max-results", "10"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	output := stdout.String()
+	c.Assert(output, check.Matches, `(?s).*\| e2 +\| .* \(running\) +\| +\| user me@me.com \| app.deploy +\| app: myapp \|.*`)
+	c.Assert(output, check.Matches, `(?s).*\| e1 +\| .* \(5s\) +\| false +\| user me@me.com \| app.update.restart \| app: myapp \|.*`)
+}
+
+func (s *S) TestEventListRunRunning(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `[{"UniqueID":"e2","StartTime":"2018-01-02T10:00:00Z","Target":{"Type":"app","Value":"myapp"},"Kind":{"Type":"permission","Name":"app.deploy"},"Owner":{"Type":"user","Name":"me@me.com"},"Running":true}]`,
+			Status:  http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/1.0/events" && req.URL.Query().Get("running") == "true"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := EventList{}
+	err := command.Flags().Parse(true, []string{"--running"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Matches, `(?s).*\| e2 +\| .* \(running\) +\|.*`)
+}
+
+func (s *S) TestEventListRunEmpty(c *check.C) {
+	var stdout bytes.Buffer
+	context := Context{Stdout: &stdout}
+	transport := cmdtest.Transport{Status: http.StatusNoContent}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := EventList{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "No events found.\n")
+}
+
+func (s *S) TestEventListRunWatch(c *check.C) {
+	running := `[{"UniqueID":"e2","StartTime":"2018-01-02T10:00:00Z","Target":{"Type":"app","Value":"myapp"},"Kind":{"Type":"permission","Name":"app.deploy"},"Owner":{"Type":"user","Name":"me@me.com"},"Running":true}]`
+	finished := `[{"UniqueID":"e2","StartTime":"2018-01-02T10:00:00Z","EndTime":"2018-01-02T10:01:00Z","Target":{"Type":"app","Value":"myapp"},"Kind":{"Type":"permission","Name":"app.deploy"},"Owner":{"Type":"user","Name":"me@me.com"}}]`
+	var streamQuery, streamAuth string
+	server := httptest.NewServer(websocket.Handler(func(conn *websocket.Conn) {
+		streamQuery = conn.Request().URL.RawQuery
+		streamAuth = conn.Request().Header.Get("Authorization")
+		conn.Write([]byte(strings.Trim(finished, "[]")))
+		conn.Close()
+	}))
+	defer server.Close()
+	os.Setenv("TSURU_TARGET", server.URL)
+	defer os.Unsetenv("TSURU_TARGET")
+	os.Setenv("TSURU_TOKEN", "abc123")
+	defer os.Unsetenv("TSURU_TOKEN")
+	cond := func(req *http.Request) bool {
+		return req.URL.Path == "/1.0/events" && req.URL.Query().Get("target.value") == "myapp"
+	}
+	transport := cmdtest.MultiConditionalTransport{
+		ConditionalTransports: []cmdtest.ConditionalTransport{
+			{Transport: cmdtest.Transport{Message: running, Status: http.StatusOK}, CondFunc: cond},
+			{Transport: cmdtest.Transport{Message: finished, Status: http.StatusOK}, CondFunc: cond},
+		},
+	}
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := EventList{}
+	err := command.Flags().Parse(true, []string{"-v", "myapp", "-w"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(streamQuery, check.Matches, `.*target.value=myapp.*`)
+	c.Assert(streamAuth, check.Equals, "bearer abc123")
+	screens := strings.Split(stdout.String(), clearScreen)
+	c.Assert(screens, check.HasLen, 3)
+	c.Assert(screens[1], check.Matches, `(?s).*\| e2 +\| .* \(running\) +\|.*`)
+	c.Assert(screens[2], check.Matches, `(?s).*\| e2 +\| .* \(1m0s\) +\| true .*`)
+}
@@ -0,0 +1,164 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/tsuru/gnuflag"
+)
+
+const maxSparklineWidth = 60
+
+var sparklineTicks = []rune("▁▂▃▄▅▆▇█")
+
+type metricPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+}
+
+type metricSeries struct {
+	Metric string        `json:"metric"`
+	Unit   string        `json:"unit"`
+	Points []metricPoint `json:"points"`
+}
+
+type AppMetrics struct {
+	GuessingCommand
+	fs       *gnuflag.FlagSet
+	window   string
+	interval string
+	metrics  StringSliceFlag
+}
+
+func (c *AppMetrics) Info() *Info {
+	return &Info{
+		Name:  "app-metrics",
+		Usage: "app-metrics [-a/--app <appname>] [-w/--window <duration>] [-i/--interval <duration>] [-m/--metric <cpu|memory|requests>]...",
+		Desc: `Displays the cpu and memory usage of each unit of an app, along with the
+number of requests received by the app, in the given window of time.
+
+Each metric is displayed as a graph with one bar per interval, along with its
+last and maximum values. By default the last hour is displayed, in intervals
+of one minute.`,
+	}
+}
+
+func (c *AppMetrics) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = c.GuessingCommand.Flags()
+		desc := "The window of time to display, e.g. 30m or 6h"
+		c.fs.StringVar(&c.window, "window", "", desc)
+		c.fs.StringVar(&c.window, "w", "", desc)
+		desc = "The interval represented by each bar of the graph, e.g. 1m or 5m"
+		c.fs.StringVar(&c.interval, "interval", "", desc)
+		c.fs.StringVar(&c.interval, "i", "", desc)
+		desc = "The metric to display, may be repeated"
+		c.fs.Var(&c.metrics, "metric", desc)
+		c.fs.Var(&c.metrics, "m", desc)
+	}
+	return c.fs
+}
+
+func (c *AppMetrics) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	query := url.Values{}
+	if c.window != "" {
+		query.Set("window", c.window)
+	}
+	if c.interval != "" {
+		query.Set("interval", c.interval)
+	}
+	for _, m := range c.metrics {
+		query.Add("metric", m)
+	}
+	var series []metricSeries
+	err = getJSON(client, fmt.Sprintf("/apps/%s/metrics?%s", appName, query.Encode()), &series)
+	if err != nil {
+		return err
+	}
+	if len(series) == 0 {
+		fmt.Fprintf(context.Stdout, "No metrics available for app %q.\n", appName)
+		return nil
+	}
+	table := NewTable()
+	table.Headers = Row{"Metric", "Unit", "Graph", "Last", "Max"}
+	for _, s := range series {
+		var last, max float64
+		values := make([]float64, len(s.Points))
+		for i, p := range s.Points {
+			values[i] = p.Value
+			if p.Value > max {
+				max = p.Value
+			}
+		}
+		if len(values) > 0 {
+			last = values[len(values)-1]
+		}
+		table.AddRow(Row{
+			s.Metric,
+			s.Unit,
+			sparkline(values, maxSparklineWidth),
+			formatMetric(s.Metric, last),
+			formatMetric(s.Metric, max),
+		})
+	}
+	context.Stdout.Write(table.Bytes())
+	return nil
+}
+
+func formatMetric(metric string, value float64) string {
+	switch metric {
+	case "cpu":
+		return fmt.Sprintf("%.1f%%", value)
+	case "memory":
+		return fmt.Sprintf("%.1fMB", value/(1024*1024))
+	}
+	return fmt.Sprintf("%.0f", value)
+}
+
+// sparkline renders the values as a line of bars, scaled from zero to the
+// largest value. When there are more values than width, consecutive values
+// are grouped, keeping the largest one of each group.
+func sparkline(values []float64, width int) string {
+	if len(values) > width {
+		grouped := make([]float64, 0, width)
+		size := (len(values) + width - 1) / width
+		for i := 0; i < len(values); i += size {
+			end := i + size
+			if end > len(values) {
+				end = len(values)
+			}
+			max := values[i]
+			for _, v := range values[i:end] {
+				if v > max {
+					max = v
+				}
+			}
+			grouped = append(grouped, max)
+		}
+		values = grouped
+	}
+	var max float64
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+	result := make([]rune, len(values))
+	for i, v := range values {
+		idx := 0
+		if max > 0 && v > 0 {
+			idx = int(v / max * float64(len(sparklineTicks)-1))
+		}
+		result[i] = sparklineTicks[idx]
+	}
+	return string(result)
+}
@@ -0,0 +1,73 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAppMetricsInfo(c *check.C) {
+	c.Assert((&AppMetrics{}).Info(), check.NotNil)
+}
+
+func (s *S) TestAppMetricsRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `[
+{"metric":"cpu","unit":"unit1","points":[{"timestamp":"2018-01-01T10:00:00Z","value":10},{"timestamp":"2018-01-01T10:01:00Z","value":80},{"timestamp":"2018-01-01T10:02:00Z","value":40}]},
+{"metric":"memory","unit":"unit1","points":[{"timestamp":"2018-01-01T10:00:00Z","value":104857600}]},
+{"metric":"requests","points":[{"timestamp":"2018-01-01T10:00:00Z","value":0},{"timestamp":"2018-01-01T10:01:00Z","value":120}]}
+]`,
+			Status: http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			query := req.URL.Query()
+			return req.Method == "GET" && req.URL.Path == "/1.0/apps/myapp/metrics" &&
+				query.Get("window") == "3m" && query.Get("interval") == "1m" &&
+				len(query["metric"]) == 0
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := AppMetrics{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp", "-w", "3m", "-i", "1m"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	expected := `+----------+-------+-------+---------+---------+
+| Metric   | Unit  | Graph | Last    | Max     |
++----------+-------+-------+---------+---------+
+| cpu      | unit1 | ▁█▄   | 40.0%   | 80.0%   |
+| memory   | unit1 | █     | 100.0MB | 100.0MB |
+| requests |       | ▁█    | 120     | 120     |
++----------+-------+-------+---------+---------+
+`
+	c.Assert(stdout.String(), check.Equals, expected)
+}
+
+func (s *S) TestAppMetricsRunEmpty(c *check.C) {
+	var stdout bytes.Buffer
+	context := Context{Stdout: &stdout}
+	transport := cmdtest.Transport{Message: `[]`, Status: http.StatusOK}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := AppMetrics{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp", "-m", "cpu"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "No metrics available for app \"myapp\".\n")
+}
+
+func (s *S) TestSparkline(c *check.C) {
+	c.Assert(sparkline(nil, 10), check.Equals, "")
+	c.Assert(sparkline([]float64{0, 0}, 10), check.Equals, "▁▁")
+	c.Assert(sparkline([]float64{0, 1, 2, 3, 4, 5, 6, 7}, 10), check.Equals, "▁▂▃▄▅▆▇█")
+	c.Assert(sparkline([]float64{1, 8, 2, 2, 8, 1}, 3), check.Equals, "█▂█")
+}
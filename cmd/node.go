@@ -0,0 +1,311 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/gnuflag"
+)
+
+type nodeSpec struct {
+	Address     string
+	IaaSID      string
+	Metadata    map[string]string
+	Status      string
+	Pool        string
+	Provisioner string
+}
+
+func doForm(client *Client, method, path string, values url.Values) (*http.Response, error) {
+	u, err := GetURL(path)
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequest(method, u, strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return client.Do(request)
+}
+
+func parseKeyValues(args []string) (map[string]string, error) {
+	result := make(map[string]string, len(args))
+	for _, arg := range args {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errors.Errorf("invalid parameter %q, expected key=value", arg)
+		}
+		result[parts[0]] = parts[1]
+	}
+	return result, nil
+}
+
+type DockerNodeAdd struct {
+	fs       *gnuflag.FlagSet
+	register bool
+}
+
+func (c *DockerNodeAdd) Info() *Info {
+	return &Info{
+		Name:  "docker-node-add",
+		Usage: "docker-node-add [param_name=param_value...] [--register]",
+		Desc: `Creates or registers a new node in the cluster.
+
+By default, this command will call the configured IaaS to create a new
+machine. Every param will be sent to the IaaS implementation.
+
+With the [[--register]] flag, an existing docker node is registered instead.
+In this case the [[address]] param is required and no IaaS is called.
+
+The [[pool]] param is always required.`,
+		MinArgs: 1,
+	}
+}
+
+func (c *DockerNodeAdd) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("docker-node-add", gnuflag.ExitOnError)
+		c.fs.BoolVar(&c.register, "register", false, "Registers an existing docker endpoint, the IaaS won't be called.")
+	}
+	return c.fs
+}
+
+func (c *DockerNodeAdd) Run(context *Context, client *Client) error {
+	params, err := parseKeyValues(context.Args)
+	if err != nil {
+		return err
+	}
+	if params["pool"] == "" {
+		return errors.New("the pool param is required")
+	}
+	if c.register && params["address"] == "" {
+		return errors.New("the address param is required when registering a node")
+	}
+	values := url.Values{}
+	for k, v := range params {
+		values.Set("Metadata."+k, v)
+	}
+	values.Set("Register", strconv.FormatBool(c.register))
+	resp, err := doForm(client, "POST", "/node", values)
+	if err != nil {
+		return err
+	}
+	err = StreamJSONResponse(context.Stdout, resp)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(context.Stdout, "Node successfully registered.")
+	return nil
+}
+
+type DockerNodeRemove struct {
+	ConfirmationCommand
+	fs          *gnuflag.FlagSet
+	destroy     bool
+	noRebalance bool
+}
+
+func (c *DockerNodeRemove) Info() *Info {
+	return &Info{
+		Name:  "docker-node-remove",
+		Usage: "docker-node-remove <address> [--no-rebalance] [--destroy] [-y]",
+		Desc: `Removes a node from the cluster.
+
+By default the containers running in the node are moved to other nodes before
+it's removed, use [[--no-rebalance]] to skip this step. The [[--destroy]] flag
+also destroys the machine in the IaaS used to create it, if any.`,
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (c *DockerNodeRemove) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("docker-node-remove", gnuflag.ExitOnError)
+		c.fs.BoolVar(&c.destroy, "destroy", false, "Destroy the machine in the IaaS used to create it, if any.")
+		c.fs.BoolVar(&c.noRebalance, "no-rebalance", false, "Do not move the containers running in the node to other nodes.")
+		c.fs = MergeFlagSet(c.ConfirmationCommand.Flags(), c.fs)
+	}
+	return c.fs
+}
+
+func (c *DockerNodeRemove) Run(context *Context, client *Client) error {
+	address := context.Args[0]
+	msg := fmt.Sprintf("Are you sure you want to remove the node %q?", address)
+	if c.destroy {
+		msg = fmt.Sprintf("Are you sure you want to remove and destroy the node %q?", address)
+	}
+	if !c.Confirm(context, msg) {
+		return nil
+	}
+	values := url.Values{}
+	values.Set("no-rebalance", strconv.FormatBool(c.noRebalance))
+	values.Set("remove-iaas", strconv.FormatBool(c.destroy))
+	u, err := GetURL(fmt.Sprintf("/node/%s?%s", address, values.Encode()))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("DELETE", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(context.Stdout, resp.Body)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(context.Stdout, "Node successfully removed.")
+	return nil
+}
+
+type DockerNodeList struct {
+	CachedCommand
+	fs     *gnuflag.FlagSet
+	filter MapFlag
+}
+
+func (c *DockerNodeList) Info() *Info {
+	return &Info{
+		Name:  "docker-node-list",
+		Usage: "docker-node-list [--filter/-f <metadata>=<value>]... [--cached]",
+		Desc: `Lists the nodes in the cluster, along with their status, pool and the
+number of containers running in each one.
+
+The [[--filter]] flag only shows nodes whose metadata match the given values.
+
+The [[--cached]] flag displays the nodes retrieved by the last successful run
+against the current target, without contacting the tsuru server. It's meant
+for incident triage when the server is unreachable, the data may be stale.`,
+	}
+}
+
+func (c *DockerNodeList) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("docker-node-list", gnuflag.ExitOnError)
+		filter := "Filter by metadata name and value"
+		c.fs.Var(&c.filter, "filter", filter)
+		c.fs.Var(&c.filter, "f", filter)
+		c.fs = MergeFlagSet(c.CachedCommand.Flags(), c.fs)
+	}
+	return c.fs
+}
+
+func (c *DockerNodeList) Run(context *Context, client *Client) error {
+	var result struct {
+		Nodes []nodeSpec `json:"nodes"`
+	}
+	err := c.GetJSON(context, client, "/node", &result)
+	if err != nil {
+		return err
+	}
+	table := NewTable()
+	table.Headers = Row{"Address", "IaaS ID", "Status", "Pool", "Containers"}
+	table.LineSeparator = true
+	for _, node := range result.Nodes {
+		if !c.matches(node) {
+			continue
+		}
+		containers := ""
+		if !strings.HasPrefix(node.Status, "ERROR") {
+			var units []struct{}
+			err = c.GetJSON(context, client, fmt.Sprintf("/node/%s/containers", node.Address), &units)
+			if err != nil {
+				return err
+			}
+			containers = strconv.Itoa(len(units))
+		}
+		table.AddRow(Row{node.Address, node.IaaSID, node.Status, node.Pool, containers})
+	}
+	table.Sort()
+	context.Stdout.Write(table.Bytes())
+	return nil
+}
+
+func (c *DockerNodeList) matches(node nodeSpec) bool {
+	for k, v := range c.filter {
+		value := node.Metadata[k]
+		if k == "pool" && value == "" {
+			value = node.Pool
+		}
+		if value != v {
+			return false
+		}
+	}
+	return true
+}
+
+type ContainersRebalance struct {
+	ConfirmationCommand
+	fs       *gnuflag.FlagSet
+	dry      bool
+	metadata MapFlag
+	apps     StringSliceFlag
+}
+
+func (c *ContainersRebalance) Info() *Info {
+	return &Info{
+		Name:  "containers-rebalance",
+		Usage: "containers-rebalance [--dry] [-y/--assume-yes] [-m/--metadata <metadata>=<value>]... [-a/--app <appname>]...",
+		Desc: `Moves containers between nodes in the cluster, trying to distribute them
+evenly.
+
+The [[--metadata]] flag limits the rebalance to nodes matching the given
+metadata, the [[pool]] metadata limits it to a single pool. The [[--app]] flag
+limits the rebalance to containers of the given apps. With [[--dry]] no
+containers are moved, the command only shows what would be done.`,
+	}
+}
+
+func (c *ContainersRebalance) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("containers-rebalance", gnuflag.ExitOnError)
+		c.fs.BoolVar(&c.dry, "dry", false, "Dry run, only shows what would be done")
+		desc := "Filter by host metadata"
+		c.fs.Var(&c.metadata, "metadata", desc)
+		c.fs.Var(&c.metadata, "m", desc)
+		desc = "Filter by app name"
+		c.fs.Var(&c.apps, "app", desc)
+		c.fs.Var(&c.apps, "a", desc)
+		c.fs = MergeFlagSet(c.ConfirmationCommand.Flags(), c.fs)
+	}
+	return c.fs
+}
+
+func (c *ContainersRebalance) Run(context *Context, client *Client) error {
+	if !c.dry && !c.Confirm(context, "Are you sure you want to rebalance containers?") {
+		return nil
+	}
+	values := url.Values{}
+	values.Set("Dry", strconv.FormatBool(c.dry))
+	keys := make([]string, 0, len(c.metadata))
+	for k := range c.metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		values.Set("MetadataFilter."+k, c.metadata[k])
+	}
+	for i, app := range c.apps {
+		values.Set(fmt.Sprintf("AppFilter.%d", i), app)
+	}
+	resp, err := doForm(client, "POST", "/node/rebalance", values)
+	if err != nil {
+		return err
+	}
+	return StreamJSONResponse(context.Stdout, resp)
+}
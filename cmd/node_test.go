@@ -0,0 +1,174 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestDockerNodeAddInfo(c *check.C) {
+	c.Assert((&DockerNodeAdd{}).Info(), check.NotNil)
+}
+
+func (s *S) TestDockerNodeAddRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"address=http://10.0.0.1:2375", "pool=pool1"}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: `{"Message":"adding node\n"}`, Status: http.StatusCreated},
+		CondFunc: func(req *http.Request) bool {
+			req.ParseForm()
+			return req.Method == "POST" && req.URL.Path == "/1.0/node" &&
+				req.Header.Get("Content-Type") == "application/x-www-form-urlencoded" &&
+				req.Form.Get("Metadata.address") == "http://10.0.0.1:2375" &&
+				req.Form.Get("Metadata.pool") == "pool1" &&
+				req.Form.Get("Register") == "true"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := DockerNodeAdd{}
+	err := command.Flags().Parse(true, []string{"--register"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "adding node\nNode successfully registered.\n")
+}
+
+func (s *S) TestDockerNodeAddRunRequiresPool(c *check.C) {
+	context := Context{Args: []string{"address=http://10.0.0.1:2375"}, Stdout: &bytes.Buffer{}}
+	command := DockerNodeAdd{}
+	err := command.Run(&context, nil)
+	c.Assert(err, check.ErrorMatches, "the pool param is required")
+}
+
+func (s *S) TestDockerNodeAddRunRegisterRequiresAddress(c *check.C) {
+	context := Context{Args: []string{"pool=pool1"}, Stdout: &bytes.Buffer{}}
+	command := DockerNodeAdd{}
+	err := command.Flags().Parse(true, []string{"--register"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, nil)
+	c.Assert(err, check.ErrorMatches, "the address param is required when registering a node")
+}
+
+func (s *S) TestDockerNodeAddRunInvalidParam(c *check.C) {
+	context := Context{Args: []string{"pool"}, Stdout: &bytes.Buffer{}}
+	command := DockerNodeAdd{}
+	err := command.Run(&context, nil)
+	c.Assert(err, check.ErrorMatches, `invalid parameter "pool", expected key=value`)
+}
+
+func (s *S) TestDockerNodeRemoveRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"http://10.0.0.1:2375"}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: "", Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "DELETE" && strings.HasSuffix(req.URL.Path, "/node/http://10.0.0.1:2375") &&
+				req.URL.Query().Get("no-rebalance") == "true" &&
+				req.URL.Query().Get("remove-iaas") == "true"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := DockerNodeRemove{}
+	err := command.Flags().Parse(true, []string{"-y", "--destroy", "--no-rebalance"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Node successfully removed.\n")
+}
+
+func (s *S) TestDockerNodeRemoveRunAbort(c *check.C) {
+	var stdout bytes.Buffer
+	context := Context{Args: []string{"http://10.0.0.1:2375"}, Stdout: &stdout, Stdin: strings.NewReader("n\n")}
+	command := DockerNodeRemove{}
+	err := command.Run(&context, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Are you sure you want to remove the node \"http://10.0.0.1:2375\"? (y/n) Abort.\n")
+}
+
+func (s *S) TestDockerNodeListRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	route := func(path, message string) cmdtest.ConditionalTransport {
+		return cmdtest.ConditionalTransport{
+			Transport: cmdtest.Transport{Message: message, Status: http.StatusOK},
+			CondFunc: func(req *http.Request) bool {
+				return req.Method == "GET" && req.URL.Path == path
+			},
+		}
+	}
+	transport := cmdtest.AnyConditionalTransport{
+		ConditionalTransports: []cmdtest.ConditionalTransport{
+			route("/1.0/node", `{"nodes":[
+{"Address":"http://10.0.0.2:2375","IaaSID":"","Metadata":{"zone":"b"},"Status":"disabled","Pool":"pool2"},
+{"Address":"http://10.0.0.1:2375","IaaSID":"m1","Metadata":{"zone":"a"},"Status":"ready","Pool":"pool1"}
+],"machines":[]}`),
+			route("/1.0/node/http://10.0.0.1:2375/containers", `[{"ID":"c1"},{"ID":"c2"}]`),
+			route("/1.0/node/http://10.0.0.2:2375/containers", `[]`),
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := DockerNodeList{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	expected := `+----------------------+---------+----------+-------+------------+
+| Address              | IaaS ID | Status   | Pool  | Containers |
++----------------------+---------+----------+-------+------------+
+| http://10.0.0.1:2375 | m1      | ready    | pool1 | 2          |
++----------------------+---------+----------+-------+------------+
+| http://10.0.0.2:2375 |         | disabled | pool2 | 0          |
++----------------------+---------+----------+-------+------------+
+`
+	c.Assert(stdout.String(), check.Equals, expected)
+	stdout.Reset()
+	command = DockerNodeList{}
+	err = command.Flags().Parse(true, []string{"-f", "pool=pool2"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	expected = `+----------------------+---------+----------+-------+------------+
+| Address              | IaaS ID | Status   | Pool  | Containers |
++----------------------+---------+----------+-------+------------+
+| http://10.0.0.2:2375 |         | disabled | pool2 | 0          |
++----------------------+---------+----------+-------+------------+
+`
+	c.Assert(stdout.String(), check.Equals, expected)
+}
+
+func (s *S) TestContainersRebalanceRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: `{"Message":"rebalancing\n"}`, Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			req.ParseForm()
+			return req.Method == "POST" && req.URL.Path == "/1.0/node/rebalance" &&
+				req.Form.Get("Dry") == "true" &&
+				req.Form.Get("MetadataFilter.pool") == "pool1" &&
+				req.Form.Get("AppFilter.0") == "app1" &&
+				req.Form.Get("AppFilter.1") == "app2"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := ContainersRebalance{}
+	err := command.Flags().Parse(true, []string{"--dry", "-m", "pool=pool1", "-a", "app1", "--app", "app2"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "rebalancing\n")
+}
+
+func (s *S) TestContainersRebalanceRunAbort(c *check.C) {
+	var stdout bytes.Buffer
+	context := Context{Stdout: &stdout, Stdin: strings.NewReader("n\n")}
+	command := ContainersRebalance{}
+	err := command.Run(&context, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Are you sure you want to rebalance containers? (y/n) Abort.\n")
+}
@@ -0,0 +1,112 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/tsuru/gnuflag"
+)
+
+type orphan struct {
+	Kind     string
+	App      string
+	Service  string
+	Instance string
+	Router   string
+	Address  string
+	Repaired bool
+	Error    string
+}
+
+func (o orphan) resource() string {
+	if o.Kind == "route" {
+		return fmt.Sprintf("%s (router %s)", o.Address, o.Router)
+	}
+	return fmt.Sprintf("%s/%s", o.Service, o.Instance)
+}
+
+type OrphanCleanup struct {
+	ConfirmationCommand
+	fs    *gnuflag.FlagSet
+	apply bool
+}
+
+func (c *OrphanCleanup) Info() *Info {
+	return &Info{
+		Name:  "orphan-cleanup",
+		Usage: "orphan-cleanup [--apply] [-y/--assume-yes]",
+		Desc: `Looks for inconsistencies left behind by failed operations: service
+instances bound to apps that no longer exist, app environment variables set by
+missing service instances and routes pointing to addresses that aren't units
+of the app.
+
+By default the inconsistencies are only reported. With [[--apply]] they're
+repaired: apps are unbound from the instances, environment variables are
+removed without restarting the apps and routes are removed from the routers.`,
+	}
+}
+
+func (c *OrphanCleanup) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("orphan-cleanup", gnuflag.ExitOnError)
+		c.fs.BoolVar(&c.apply, "apply", false, "Repair the inconsistencies found")
+		c.fs = MergeFlagSet(c.ConfirmationCommand.Flags(), c.fs)
+	}
+	return c.fs
+}
+
+func (c *OrphanCleanup) Run(context *Context, client *Client) error {
+	var orphans []orphan
+	err := getJSON(client, "/orphans", &orphans)
+	if err != nil {
+		return err
+	}
+	if len(orphans) == 0 {
+		fmt.Fprintln(context.Stdout, "No inconsistencies found.")
+		return nil
+	}
+	if !c.apply {
+		table := NewTable()
+		table.Headers = Row{"Kind", "App", "Resource"}
+		for _, o := range orphans {
+			table.AddRow(Row{o.Kind, o.App, o.resource()})
+		}
+		context.Stdout.Write(table.Bytes())
+		fmt.Fprintf(context.Stdout, "\nFound %d inconsistencies, run with --apply to repair them.\n", len(orphans))
+		return nil
+	}
+	if !c.Confirm(context, fmt.Sprintf("Are you sure you want to repair %d inconsistencies?", len(orphans))) {
+		return nil
+	}
+	resp, err := doForm(client, "POST", "/orphans/cleanup", url.Values{})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNoContent {
+		fmt.Fprintln(context.Stdout, "No inconsistencies found.")
+		return nil
+	}
+	orphans = nil
+	err = json.NewDecoder(resp.Body).Decode(&orphans)
+	if err != nil {
+		return err
+	}
+	table := NewTable()
+	table.Headers = Row{"Kind", "App", "Resource", "Status"}
+	for _, o := range orphans {
+		status := "repaired"
+		if !o.Repaired {
+			status = "failed: " + o.Error
+		}
+		table.AddRow(Row{o.Kind, o.App, o.resource(), status})
+	}
+	context.Stdout.Write(table.Bytes())
+	return nil
+}
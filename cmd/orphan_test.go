@@ -0,0 +1,96 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+const orphansJSON = `[
+{"Kind":"instance-bind","App":"removedapp","Service":"mysql","Instance":"db1"},
+{"Kind":"route","App":"myapp","Router":"hipache","Address":"http://10.0.0.9:8080"}]`
+
+func (s *S) TestOrphanCleanupInfo(c *check.C) {
+	c.Assert((&OrphanCleanup{}).Info(), check.NotNil)
+}
+
+func (s *S) TestOrphanCleanupRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: orphansJSON, Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.0/orphans"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := OrphanCleanup{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `+---------------+------------+---------------------------------------+
+| Kind          | App        | Resource                              |
++---------------+------------+---------------------------------------+
+| instance-bind | removedapp | mysql/db1                             |
+| route         | myapp      | http://10.0.0.9:8080 (router hipache) |
++---------------+------------+---------------------------------------+
+
+Found 2 inconsistencies, run with --apply to repair them.
+`)
+}
+
+func (s *S) TestOrphanCleanupRunNoOrphans(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.Transport{Status: http.StatusNoContent}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := OrphanCleanup{}
+	err := command.Flags().Parse(true, []string{"--apply", "-y"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "No inconsistencies found.\n")
+}
+
+func (s *S) TestOrphanCleanupRunApply(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.MultiConditionalTransport{
+		ConditionalTransports: []cmdtest.ConditionalTransport{
+			{
+				Transport: cmdtest.Transport{Message: orphansJSON, Status: http.StatusOK},
+				CondFunc: func(req *http.Request) bool {
+					return req.Method == "GET" && req.URL.Path == "/1.0/orphans"
+				},
+			},
+			{
+				Transport: cmdtest.Transport{
+					Message: `[{"Kind":"instance-bind","App":"removedapp","Service":"mysql","Instance":"db1","Repaired":true},
+{"Kind":"route","App":"myapp","Router":"hipache","Address":"http://10.0.0.9:8080","Error":"router unavailable"}]`,
+					Status: http.StatusOK,
+				},
+				CondFunc: func(req *http.Request) bool {
+					return req.Method == "POST" && req.URL.Path == "/1.0/orphans/cleanup"
+				},
+			},
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := OrphanCleanup{}
+	err := command.Flags().Parse(true, []string{"--apply", "-y"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `+---------------+------------+---------------------------------------+----------------------------+
+| Kind          | App        | Resource                              | Status                     |
++---------------+------------+---------------------------------------+----------------------------+
+| instance-bind | removedapp | mysql/db1                             | repaired                   |
+| route         | myapp      | http://10.0.0.9:8080 (router hipache) | failed: router unavailable |
++---------------+------------+---------------------------------------+----------------------------+
+`)
+}
@@ -0,0 +1,97 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/gnuflag"
+)
+
+const (
+	defaultPageSize   = 100
+	defaultMaxResults = 100
+)
+
+// Embed this struct in list commands backed by endpoints that support the
+// skip and limit query parameters. It adds the --max-results and --all flags
+// and fetches as many pages as needed to honor them.
+type PaginatedCommand struct {
+	PageSize   int
+	fs         *gnuflag.FlagSet
+	maxResults int
+	all        bool
+}
+
+func (cmd *PaginatedCommand) Flags() *gnuflag.FlagSet {
+	if cmd.fs == nil {
+		cmd.fs = gnuflag.NewFlagSet("", gnuflag.ExitOnError)
+		cmd.fs.IntVar(&cmd.maxResults, "max-results", defaultMaxResults, "The maximum number of results to display.")
+		cmd.fs.BoolVar(&cmd.all, "all", false, "Display all results, ignoring --max-results.")
+	}
+	return cmd.fs
+}
+
+// FetchPages requests path once for each page, using the skip and limit
+// query parameters, until the results are exhausted or the maximum number of
+// results is reached. decode is called with the body of each page and must
+// return the number of items in it.
+func (cmd *PaginatedCommand) FetchPages(client *Client, path string, query url.Values, decode func(io.Reader) (int, error)) error {
+	if cmd.fs == nil {
+		cmd.Flags()
+	}
+	if !cmd.all && cmd.maxResults <= 0 {
+		return errors.New("--max-results must be greater than zero")
+	}
+	pageSize := cmd.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	if query == nil {
+		query = url.Values{}
+	}
+	remaining := cmd.maxResults
+	for skip := 0; ; {
+		limit := pageSize
+		if !cmd.all && remaining < limit {
+			limit = remaining
+		}
+		query.Set("skip", strconv.Itoa(skip))
+		query.Set("limit", strconv.Itoa(limit))
+		n, err := fetchPage(client, path+"?"+query.Encode(), decode)
+		if err != nil {
+			return err
+		}
+		skip += n
+		remaining -= n
+		if n < limit || (!cmd.all && remaining <= 0) {
+			return nil
+		}
+	}
+}
+
+func fetchPage(client *Client, path string, decode func(io.Reader) (int, error)) (int, error) {
+	u, err := GetURL(path)
+	if err != nil {
+		return 0, err
+	}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNoContent {
+		return 0, nil
+	}
+	return decode(resp.Body)
+}
@@ -0,0 +1,145 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"gopkg.in/check.v1"
+)
+
+// pagedTransport serves total items, numbered from zero, honoring the skip
+// and limit query parameters.
+type pagedTransport struct {
+	total    int
+	requests []url.Values
+}
+
+func (t *pagedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	query := req.URL.Query()
+	t.requests = append(t.requests, query)
+	skip, _ := strconv.Atoi(query.Get("skip"))
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	items := []int{}
+	for i := skip; i < t.total && len(items) < limit; i++ {
+		items = append(items, i)
+	}
+	if len(items) == 0 {
+		return &http.Response{Body: ioutil.NopCloser(&bytes.Buffer{}), StatusCode: http.StatusNoContent}, nil
+	}
+	data, _ := json.Marshal(items)
+	return &http.Response{Body: ioutil.NopCloser(bytes.NewReader(data)), StatusCode: http.StatusOK}, nil
+}
+
+func (s *S) fetchPages(c *check.C, transport *pagedTransport, pageSize int, args ...string) ([]int, error) {
+	client := NewClient(&http.Client{Transport: transport}, nil, globalManager)
+	command := PaginatedCommand{PageSize: pageSize}
+	err := command.Flags().Parse(true, args)
+	c.Assert(err, check.IsNil)
+	var result []int
+	err = command.FetchPages(client, "/items", url.Values{"filter": []string{"x"}}, func(r io.Reader) (int, error) {
+		var items []int
+		err := json.NewDecoder(r).Decode(&items)
+		result = append(result, items...)
+		return len(items), err
+	})
+	return result, err
+}
+
+func (s *S) TestFetchPagesMaxResults(c *check.C) {
+	transport := &pagedTransport{total: 25}
+	items, err := s.fetchPages(c, transport, 10, "--max-results", "15")
+	c.Assert(err, check.IsNil)
+	c.Assert(items, check.HasLen, 15)
+	c.Assert(items[14], check.Equals, 14)
+	c.Assert(transport.requests, check.HasLen, 2)
+	c.Assert(transport.requests[0].Get("filter"), check.Equals, "x")
+	c.Assert(transport.requests[0].Get("skip"), check.Equals, "0")
+	c.Assert(transport.requests[0].Get("limit"), check.Equals, "10")
+	c.Assert(transport.requests[1].Get("skip"), check.Equals, "10")
+	c.Assert(transport.requests[1].Get("limit"), check.Equals, "5")
+}
+
+func (s *S) TestFetchPagesDefaultMaxResults(c *check.C) {
+	transport := &pagedTransport{total: 250}
+	items, err := s.fetchPages(c, transport, 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(items, check.HasLen, defaultMaxResults)
+	c.Assert(transport.requests, check.HasLen, 1)
+}
+
+func (s *S) TestFetchPagesAll(c *check.C) {
+	transport := &pagedTransport{total: 25}
+	items, err := s.fetchPages(c, transport, 10, "--all", "--max-results", "5")
+	c.Assert(err, check.IsNil)
+	c.Assert(items, check.HasLen, 25)
+	c.Assert(transport.requests, check.HasLen, 3)
+	c.Assert(transport.requests[2].Get("skip"), check.Equals, "20")
+}
+
+func (s *S) TestFetchPagesAllExactPages(c *check.C) {
+	transport := &pagedTransport{total: 20}
+	items, err := s.fetchPages(c, transport, 10, "--all")
+	c.Assert(err, check.IsNil)
+	c.Assert(items, check.HasLen, 20)
+	c.Assert(transport.requests, check.HasLen, 3)
+}
+
+func (s *S) TestFetchPagesInvalidMaxResults(c *check.C) {
+	transport := &pagedTransport{total: 20}
+	_, err := s.fetchPages(c, transport, 10, "--max-results", "0")
+	c.Assert(err, check.ErrorMatches, "--max-results must be greater than zero")
+	c.Assert(transport.requests, check.HasLen, 0)
+}
+
+type deployPagesTransport struct {
+	requests []url.Values
+}
+
+func (t *deployPagesTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests = append(t.requests, req.URL.Query())
+	body := `[]`
+	if req.URL.Query().Get("skip") == "0" {
+		body = `[{"ID":"d2","App":"myapp","Timestamp":"2018-01-02T10:00:00Z","Duration":30000000000,"Image":"v2","User":"me","Origin":"app-deploy"},
+{"ID":"d1","App":"myapp","Timestamp":"2018-01-01T10:00:00Z","Duration":60000000000,"Image":"v1","User":"me","Origin":"git","Error":"failed"}]`
+	}
+	return &http.Response{Body: ioutil.NopCloser(bytes.NewBufferString(body)), StatusCode: http.StatusOK}, nil
+}
+
+func (s *S) TestAppDeployListRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := &deployPagesTransport{}
+	client := NewClient(&http.Client{Transport: transport}, nil, globalManager)
+	command := AppDeployList{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp", "--max-results", "2"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(transport.requests, check.HasLen, 1)
+	c.Assert(transport.requests[0].Get("app"), check.Equals, "myapp")
+	c.Assert(transport.requests[0].Get("limit"), check.Equals, "2")
+	output := stdout.String()
+	c.Assert(output, check.Matches, `(?s).*\| d2 +\| v2 +\| app-deploy \| me +\| .* \(30s\) +\| +\|.*`)
+	c.Assert(output, check.Matches, `(?s).*\| d1 +\| v1 +\| git +\| me +\| .* \(1m0s\) +\| failed \|.*`)
+}
+
+func (s *S) TestAppDeployListRunEmpty(c *check.C) {
+	var stdout bytes.Buffer
+	context := Context{Stdout: &stdout}
+	client := NewClient(&http.Client{Transport: &pagedTransport{}}, nil, globalManager)
+	command := AppDeployList{}
+	err := command.Flags().Parse(true, []string{"-a", "myapp"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "App \"myapp\" has no deploys.\n")
+}
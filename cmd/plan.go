@@ -0,0 +1,87 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/tsuru/gnuflag"
+)
+
+// PlanEntry is the representation of a plan used by the plan-list command,
+// both in the table and in the JSON output. Memory and swap are in bytes.
+type PlanEntry struct {
+	Name     string `json:"name"`
+	Memory   int64  `json:"memory"`
+	Swap     int64  `json:"swap"`
+	CpuShare int    `json:"cpushare"`
+	Default  bool   `json:"default"`
+}
+
+type PlanList struct {
+	fs    *gnuflag.FlagSet
+	json  bool
+	bytes bool
+}
+
+func (c *PlanList) Info() *Info {
+	return &Info{
+		Name:  "plan-list",
+		Usage: "plan-list [-b/--bytes] [--json]",
+		Desc: `Lists the available plans, which are the valid values for the [[--plan]]
+flag of the [[app-create]] command.
+
+Memory and swap are displayed in megabytes, unless the [[--bytes]] flag is
+used. The [[--json]] flag prints the plans in JSON format, with memory and swap
+in bytes, suitable for scripting.`,
+	}
+}
+
+func (c *PlanList) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("plan-list", gnuflag.ExitOnError)
+		c.fs.BoolVar(&c.json, "json", false, "Display the plans in JSON format")
+		bytes := "Display memory and swap in bytes"
+		c.fs.BoolVar(&c.bytes, "bytes", false, bytes)
+		c.fs.BoolVar(&c.bytes, "b", false, bytes)
+	}
+	return c.fs
+}
+
+func (c *PlanList) Run(context *Context, client *Client) error {
+	entries := []PlanEntry{}
+	err := getJSON(client, "/plans", &entries)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return writeJSON(context.Stdout, entries)
+	}
+	if len(entries) == 0 {
+		fmt.Fprintln(context.Stdout, "No plans available.")
+		return nil
+	}
+	table := NewTable()
+	table.Headers = Row{"Name", "Memory", "Swap", "Cpu Share", "Default"}
+	for _, e := range entries {
+		table.AddRow(Row{
+			e.Name,
+			c.formatSize(e.Memory),
+			c.formatSize(e.Swap),
+			strconv.Itoa(e.CpuShare),
+			strconv.FormatBool(e.Default),
+		})
+	}
+	context.Stdout.Write(table.Bytes())
+	return nil
+}
+
+func (c *PlanList) formatSize(size int64) string {
+	if c.bytes {
+		return strconv.FormatInt(size, 10)
+	}
+	return fmt.Sprintf("%d MB", size/1024/1024)
+}
@@ -0,0 +1,96 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+const planListJSON = `[
+	{"name":"small","memory":134217728,"swap":268435456,"cpushare":100,"default":true},
+	{"name":"large","memory":1073741824,"swap":0,"cpushare":400}
+]`
+
+func (s *S) TestPlanListInfo(c *check.C) {
+	c.Assert((&PlanList{}).Info(), check.NotNil)
+}
+
+func (s *S) TestPlanListRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: planListJSON, Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.0/plans"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := PlanList{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	expected := `+-------+---------+--------+-----------+---------+
+| Name  | Memory  | Swap   | Cpu Share | Default |
++-------+---------+--------+-----------+---------+
+| small | 128 MB  | 256 MB | 100       | true    |
+| large | 1024 MB | 0 MB   | 400       | false   |
++-------+---------+--------+-----------+---------+
+`
+	c.Assert(stdout.String(), check.Equals, expected)
+}
+
+func (s *S) TestPlanListRunBytes(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.Transport{Message: planListJSON, Status: http.StatusOK}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := PlanList{}
+	err := command.Flags().Parse(true, []string{"-b"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	expected := `+-------+------------+-----------+-----------+---------+
+| Name  | Memory     | Swap      | Cpu Share | Default |
++-------+------------+-----------+-----------+---------+
+| small | 134217728  | 268435456 | 100       | true    |
+| large | 1073741824 | 0         | 400       | false   |
++-------+------------+-----------+-----------+---------+
+`
+	c.Assert(stdout.String(), check.Equals, expected)
+}
+
+func (s *S) TestPlanListRunJSON(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.Transport{Message: planListJSON, Status: http.StatusOK}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := PlanList{}
+	err := command.Flags().Parse(true, []string{"--json"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	var entries []PlanEntry
+	err = json.Unmarshal(stdout.Bytes(), &entries)
+	c.Assert(err, check.IsNil)
+	c.Assert(entries, check.DeepEquals, []PlanEntry{
+		{Name: "small", Memory: 134217728, Swap: 268435456, CpuShare: 100, Default: true},
+		{Name: "large", Memory: 1073741824, CpuShare: 400},
+	})
+}
+
+func (s *S) TestPlanListRunEmpty(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.Transport{Status: http.StatusNoContent}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := PlanList{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "No plans available.\n")
+}
@@ -0,0 +1,69 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/tsuru/gnuflag"
+)
+
+// PlatformEntry is the representation of a platform used by the
+// platform-list command, both in the table and in the JSON output.
+type PlatformEntry struct {
+	Name     string `json:"name"`
+	Disabled bool   `json:"disabled"`
+}
+
+type PlatformList struct {
+	fs   *gnuflag.FlagSet
+	json bool
+}
+
+func (c *PlatformList) Info() *Info {
+	return &Info{
+		Name:  "platform-list",
+		Usage: "platform-list [--json]",
+		Desc: `Lists the platforms the user may create apps with, which are the valid values
+for the platform argument of the [[app-create]] command. Disabled platforms
+are only listed for users allowed to manage platforms.
+
+The [[--json]] flag prints the platforms in JSON format, suitable for
+scripting.`,
+	}
+}
+
+func (c *PlatformList) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("platform-list", gnuflag.ExitOnError)
+		c.fs.BoolVar(&c.json, "json", false, "Display the platforms in JSON format")
+	}
+	return c.fs
+}
+
+func (c *PlatformList) Run(context *Context, client *Client) error {
+	entries := []PlatformEntry{}
+	err := getJSON(client, "/platforms", &entries)
+	if err != nil {
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	if c.json {
+		return writeJSON(context.Stdout, entries)
+	}
+	if len(entries) == 0 {
+		fmt.Fprintln(context.Stdout, "No platforms available.")
+		return nil
+	}
+	for _, e := range entries {
+		if e.Disabled {
+			fmt.Fprintf(context.Stdout, "- %s (disabled)\n", e.Name)
+		} else {
+			fmt.Fprintf(context.Stdout, "- %s\n", e.Name)
+		}
+	}
+	return nil
+}
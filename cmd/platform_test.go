@@ -0,0 +1,70 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestPlatformListInfo(c *check.C) {
+	c.Assert((&PlatformList{}).Info(), check.NotNil)
+}
+
+func (s *S) TestPlatformListRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `[{"Name":"ruby"},{"Name":"python","Disabled":true},{"Name":"go"}]`,
+			Status:  http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.0/platforms"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := PlatformList{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "- go\n- python (disabled)\n- ruby\n")
+}
+
+func (s *S) TestPlatformListRunJSON(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.Transport{
+		Message: `[{"Name":"ruby"},{"Name":"python","Disabled":true}]`,
+		Status:  http.StatusOK,
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := PlatformList{}
+	err := command.Flags().Parse(true, []string{"--json"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	var entries []PlatformEntry
+	err = json.Unmarshal(stdout.Bytes(), &entries)
+	c.Assert(err, check.IsNil)
+	c.Assert(entries, check.DeepEquals, []PlatformEntry{
+		{Name: "python", Disabled: true},
+		{Name: "ruby"},
+	})
+}
+
+func (s *S) TestPlatformListRunEmpty(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.Transport{Status: http.StatusNoContent}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := PlatformList{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "No platforms available.\n")
+}
@@ -0,0 +1,185 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tsuru/gnuflag"
+)
+
+type PoolAdd struct {
+	fs          *gnuflag.FlagSet
+	public      bool
+	defaultPool bool
+	force       bool
+	provisioner string
+	scanPolicy  string
+	overcommit  float64
+}
+
+func (c *PoolAdd) Info() *Info {
+	return &Info{
+		Name:  "pool-add",
+		Usage: "pool-add <pool> [-p/--public] [-d/--default] [--provisioner <name>] [--scan-policy <disabled|warn|block>] [--memory-overcommit <ratio>] [-f/--force]",
+		Desc: `Adds a new pool.
+
+Each docker node added using [[docker-node-add]] command belongs to one pool.
+Also, when creating a new application a pool must be chosen and this means
+that all units of the created application will be spawned in nodes belonging
+to the chosen pool.
+
+The memory overcommit is the ratio between the memory that may be reserved by
+units in each node of the pool and the memory of the node, units are not
+placed in the pool when no node has enough memory left.`,
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (c *PoolAdd) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("pool-add", gnuflag.ExitOnError)
+		msg := "Make pool public (all teams can use it)"
+		c.fs.BoolVar(&c.public, "public", false, msg)
+		c.fs.BoolVar(&c.public, "p", false, msg)
+		msg = "Make pool default (when none is specified during app-create, this pool will be used)"
+		c.fs.BoolVar(&c.defaultPool, "default", false, msg)
+		c.fs.BoolVar(&c.defaultPool, "d", false, msg)
+		msg = "Force overwrite default pool"
+		c.fs.BoolVar(&c.force, "force", false, msg)
+		c.fs.BoolVar(&c.force, "f", false, msg)
+		c.fs.StringVar(&c.provisioner, "provisioner", "", "Provisioner associated to the pool (empty for default docker provisioner)")
+		c.fs.StringVar(&c.scanPolicy, "scan-policy", "", "What to do when the image of a deploy has critical vulnerabilities (empty for the default policy)")
+		c.fs.Float64Var(&c.overcommit, "memory-overcommit", 0, "Ratio between the memory reservable by units and the memory of the nodes (0 for the provisioner default)")
+	}
+	return c.fs
+}
+
+func (c *PoolAdd) Run(context *Context, client *Client) error {
+	values := url.Values{}
+	values.Set("name", context.Args[0])
+	values.Set("public", strconv.FormatBool(c.public))
+	values.Set("default", strconv.FormatBool(c.defaultPool))
+	values.Set("force", strconv.FormatBool(c.force))
+	values.Set("provisioner", c.provisioner)
+	if c.scanPolicy != "" {
+		values.Set("scanpolicy", c.scanPolicy)
+	}
+	if c.overcommit != 0 {
+		values.Set("memoryovercommit", strconv.FormatFloat(c.overcommit, 'f', -1, 64))
+	}
+	resp, err := doForm(client, "POST", "/pools", values)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	fmt.Fprintf(context.Stdout, "Pool %q successfully registered.\n", context.Args[0])
+	return nil
+}
+
+type PoolTeamsAdd struct{}
+
+func (PoolTeamsAdd) Info() *Info {
+	return &Info{
+		Name:    "pool-teams-add",
+		Usage:   "pool-teams-add <pool> <teams>...",
+		Desc:    "Adds teams to a pool. This will make the specified pool available when creating a new application for one of the added teams.",
+		MinArgs: 2,
+	}
+}
+
+func (PoolTeamsAdd) Run(context *Context, client *Client) error {
+	values := url.Values{"team": context.Args[1:]}
+	resp, err := doForm(client, "POST", fmt.Sprintf("/pools/%s/team", context.Args[0]), values)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	fmt.Fprintf(context.Stdout, "Teams successfully registered to pool %q.\n", context.Args[0])
+	return nil
+}
+
+// PoolEntry is the representation of a pool used by the pool-list command,
+// both in the table and in the JSON output.
+type PoolEntry struct {
+	Name             string   `json:"name"`
+	Public           bool     `json:"public"`
+	Default          bool     `json:"default"`
+	Provisioner      string   `json:"provisioner"`
+	MemoryOvercommit float64  `json:"memory_overcommit"`
+	Teams            []string `json:"teams"`
+}
+
+func (e *PoolEntry) kind() string {
+	switch {
+	case e.Default:
+		return "default"
+	case e.Public:
+		return "public"
+	}
+	return "team"
+}
+
+type PoolList struct {
+	fs   *gnuflag.FlagSet
+	json bool
+}
+
+func (c *PoolList) Info() *Info {
+	return &Info{
+		Name:  "pool-list",
+		Usage: "pool-list [--json]",
+		Desc: `Lists the pools the user may create apps in, which are the valid values for
+the [[--pool]] flag of the [[app-create]] command.
+
+The [[--json]] flag prints the pools in JSON format, suitable for scripting.`,
+	}
+}
+
+func (c *PoolList) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("pool-list", gnuflag.ExitOnError)
+		c.fs.BoolVar(&c.json, "json", false, "Display the pools in JSON format")
+	}
+	return c.fs
+}
+
+func (c *PoolList) Run(context *Context, client *Client) error {
+	entries := []PoolEntry{}
+	err := getJSON(client, "/pools", &entries)
+	if err != nil {
+		return err
+	}
+	for i := range entries {
+		if entries[i].Teams == nil {
+			entries[i].Teams = []string{}
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	if c.json {
+		return writeJSON(context.Stdout, entries)
+	}
+	if len(entries) == 0 {
+		fmt.Fprintln(context.Stdout, "No pools available.")
+		return nil
+	}
+	table := NewTable()
+	table.Headers = Row{"Pool", "Kind", "Provisioner", "Teams"}
+	for i := range entries {
+		e := &entries[i]
+		provisioner := e.Provisioner
+		if provisioner == "" {
+			provisioner = "default"
+		}
+		table.AddRow(Row{e.Name, e.kind(), provisioner, strings.Join(e.Teams, ", ")})
+	}
+	context.Stdout.Write(table.Bytes())
+	return nil
+}
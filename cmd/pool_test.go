@@ -0,0 +1,124 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestPoolAddInfo(c *check.C) {
+	c.Assert((&PoolAdd{}).Info(), check.NotNil)
+}
+
+func (s *S) TestPoolAddRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"pool1"}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: "", Status: http.StatusCreated},
+		CondFunc: func(req *http.Request) bool {
+			req.ParseForm()
+			return req.Method == "POST" && req.URL.Path == "/1.0/pools" &&
+				req.Form.Get("name") == "pool1" &&
+				req.Form.Get("public") == "true" &&
+				req.Form.Get("default") == "false" &&
+				req.Form.Get("force") == "false" &&
+				req.Form.Get("provisioner") == "kubernetes" &&
+				req.Form.Get("scanpolicy") == "block" &&
+				req.Form.Get("memoryovercommit") == "1.5"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := PoolAdd{}
+	err := command.Flags().Parse(true, []string{"-p", "--provisioner", "kubernetes", "--scan-policy", "block", "--memory-overcommit", "1.5"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Pool \"pool1\" successfully registered.\n")
+}
+
+func (s *S) TestPoolTeamsAddRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"pool1", "team1", "team2"}, Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: "", Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			req.ParseForm()
+			teams := req.Form["team"]
+			return req.Method == "POST" && req.URL.Path == "/1.0/pools/pool1/team" &&
+				len(teams) == 2 && teams[0] == "team1" && teams[1] == "team2"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	err := PoolTeamsAdd{}.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Teams successfully registered to pool \"pool1\".\n")
+}
+
+func (s *S) TestPoolListInfo(c *check.C) {
+	c.Assert((&PoolList{}).Info(), check.NotNil)
+}
+
+func (s *S) TestPoolListRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: `[{"name":"theonepool","public":false,"default":false,"provisioner":"kubernetes","teams":["team1","team2"]},{"name":"dev","public":true,"default":true,"provisioner":""}]`,
+			Status:  http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.0/pools"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := PoolList{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	expected := `+------------+---------+-------------+--------------+
+| Pool       | Kind    | Provisioner | Teams        |
++------------+---------+-------------+--------------+
+| dev        | default | default     |              |
+| theonepool | team    | kubernetes  | team1, team2 |
++------------+---------+-------------+--------------+
+`
+	c.Assert(stdout.String(), check.Equals, expected)
+}
+
+func (s *S) TestPoolListRunJSON(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.Transport{
+		Message: `[{"name":"dev","public":true,"default":true,"provisioner":"","memory_overcommit":1.5}]`,
+		Status:  http.StatusOK,
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := PoolList{}
+	err := command.Flags().Parse(true, []string{"--json"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	var entries []PoolEntry
+	err = json.Unmarshal(stdout.Bytes(), &entries)
+	c.Assert(err, check.IsNil)
+	c.Assert(entries, check.DeepEquals, []PoolEntry{
+		{Name: "dev", Public: true, Default: true, MemoryOvercommit: 1.5, Teams: []string{}},
+	})
+}
+
+func (s *S) TestPoolListRunEmpty(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.Transport{Status: http.StatusNoContent}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := PoolList{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "No pools available.\n")
+}
@@ -0,0 +1,105 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/tsuru/gnuflag"
+)
+
+type queueJob struct {
+	ID         string
+	Queue      string
+	Target     string
+	Attempts   int
+	EnqueuedAt time.Time
+	State      string
+	LastError  string
+}
+
+type JobList struct {
+	fs      *gnuflag.FlagSet
+	kind    string
+	target  string
+	state   string
+	running bool
+}
+
+func (c *JobList) Info() *Info {
+	return &Info{
+		Name:  "job-list",
+		Usage: "job-list [-k/--kind <queue>] [-t/--target <target>] [-s/--state <state>] [-r/--running]",
+		Desc: `Lists the jobs in the work queues of the tsuru API. Jobs may be filtered
+by the queue that holds them, by their target (usually the name of an app) and
+by their state, which is one of waiting, in-flight, expired or dead.
+
+The [[--running]] flag is a shortcut for [[--state in-flight]].`,
+	}
+}
+
+func (c *JobList) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("job-list", gnuflag.ExitOnError)
+		desc := "Display only jobs in the given queue"
+		c.fs.StringVar(&c.kind, "kind", "", desc)
+		c.fs.StringVar(&c.kind, "k", "", desc)
+		desc = "Display only jobs with the given target"
+		c.fs.StringVar(&c.target, "target", "", desc)
+		c.fs.StringVar(&c.target, "t", "", desc)
+		desc = "Display only jobs in the given state (waiting, in-flight, expired or dead)"
+		c.fs.StringVar(&c.state, "state", "", desc)
+		c.fs.StringVar(&c.state, "s", "", desc)
+		desc = "Display only jobs being processed"
+		c.fs.BoolVar(&c.running, "running", false, desc)
+		c.fs.BoolVar(&c.running, "r", false, desc)
+	}
+	return c.fs
+}
+
+func (c *JobList) Run(context *Context, client *Client) error {
+	state := c.state
+	if c.running {
+		if state != "" && state != "in-flight" {
+			return errors.New("--running and --state flags are mutually exclusive")
+		}
+		state = "in-flight"
+	}
+	query := url.Values{}
+	if c.kind != "" {
+		query.Set("queue", c.kind)
+	}
+	if c.target != "" {
+		query.Set("target", c.target)
+	}
+	if state != "" {
+		query.Set("state", state)
+	}
+	path := "/queue/jobs"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	var jobs []queueJob
+	err := getJSON(client, path, &jobs)
+	if err != nil {
+		return err
+	}
+	if len(jobs) == 0 {
+		fmt.Fprintln(context.Stdout, "No jobs found.")
+		return nil
+	}
+	table := NewTable()
+	table.Headers = Row{"ID", "Kind", "Target", "State", "Attempts", "Enqueued", "Last error"}
+	for _, job := range jobs {
+		enqueued := job.EnqueuedAt.Local().Format(time.Stamp)
+		table.AddRow(Row{job.ID, job.Queue, job.Target, job.State, strconv.Itoa(job.Attempts), enqueued, job.LastError})
+	}
+	context.Stdout.Write(table.Bytes())
+	return nil
+}
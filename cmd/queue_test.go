@@ -0,0 +1,80 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+const jobsJSON = `[
+{"ID":"5a1b","Queue":"app-restart","Target":"myapp","Attempts":2,"EnqueuedAt":"2018-01-02T10:00:00Z","State":"in-flight","LastError":"timeout"}]`
+
+func (s *S) TestJobListInfo(c *check.C) {
+	c.Assert((&JobList{}).Info(), check.NotNil)
+}
+
+func (s *S) TestJobListRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: jobsJSON, Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			q := req.URL.Query()
+			return req.Method == "GET" && req.URL.Path == "/1.0/queue/jobs" &&
+				q.Get("queue") == "app-restart" && q.Get("target") == "myapp" && q.Get("state") == ""
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := JobList{}
+	err := command.Flags().Parse(true, []string{"-k", "app-restart", "-t", "myapp"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Matches, `(?s).*\| ID +\| Kind +\| Target +\| State +\| Attempts \| Enqueued +\| Last error \|.*`)
+	c.Assert(stdout.String(), check.Matches, `(?s).*\| 5a1b \| app-restart \| myapp +\| in-flight \| 2 +\| .* \| timeout +\|.*`)
+}
+
+func (s *S) TestJobListRunRunning(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: jobsJSON, Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/1.0/queue/jobs" && req.URL.Query().Get("state") == "in-flight"
+		},
+	}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := JobList{}
+	err := command.Flags().Parse(true, []string{"--running"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Matches, `(?s).*\| 5a1b \|.*`)
+}
+
+func (s *S) TestJobListRunRunningAndState(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	command := JobList{}
+	err := command.Flags().Parse(true, []string{"--running", "-s", "dead"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, nil)
+	c.Assert(err, check.ErrorMatches, "--running and --state flags are mutually exclusive")
+}
+
+func (s *S) TestJobListRunEmpty(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.Transport{Status: http.StatusNoContent}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := JobList{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "No jobs found.\n")
+}
@@ -0,0 +1,11 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import "os"
+
+// notifyResize does nothing on Windows, which has no signal for terminal
+// resizes.
+func notifyResize(c chan<- os.Signal) {}
@@ -0,0 +1,498 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/gnuflag"
+	tsuruerr "github.com/tsuru/tsuru/errors"
+)
+
+type serviceModel struct {
+	Service   string   `json:"service"`
+	Instances []string `json:"instances"`
+	Plans     []string `json:"plans"`
+}
+
+type serviceInstanceModel struct {
+	Name        string
+	ServiceName string
+	PlanName    string
+	Apps        []string
+	Teams       []string
+	TeamOwner   string
+	Description string
+	Tags        []string
+}
+
+// ServiceInstanceEntry is the representation of a service instance used by
+// the service-list command, both in the table and in the JSON output.
+type ServiceInstanceEntry struct {
+	Service  string   `json:"service"`
+	Instance string   `json:"instance"`
+	Plan     string   `json:"plan"`
+	Apps     []string `json:"apps"`
+	State    string   `json:"state,omitempty"`
+}
+
+// ServiceInstanceDetails is the representation of a service instance used by
+// the service-info command.
+type ServiceInstanceDetails struct {
+	Service         string            `json:"service"`
+	Instance        string            `json:"instance"`
+	State           string            `json:"state"`
+	Plan            string            `json:"plan"`
+	PlanDescription string            `json:"planDescription"`
+	Apps            []string          `json:"apps"`
+	Teams           []string          `json:"teams"`
+	TeamOwner       string            `json:"teamOwner"`
+	Description     string            `json:"description"`
+	Tags            []string          `json:"tags"`
+	CustomInfo      map[string]string `json:"customInfo"`
+	Metrics         []InstanceMetric  `json:"metrics,omitempty"`
+}
+
+// InstanceMetric is a usage metric of a service instance, like the number of
+// open connections or the storage used, as reported by the service.
+type InstanceMetric struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit,omitempty"`
+}
+
+func getJSON(client *Client, path string, v interface{}) error {
+	url, err := GetURL(path)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func serviceInstanceState(client *Client, service, instance string) (string, error) {
+	url, err := GetURL(fmt.Sprintf("/services/%s/instances/%s/status", service, instance))
+	if err != nil {
+		return "", err
+	}
+	request, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return parseInstanceState(instance, data), nil
+}
+
+// serviceInstanceMetrics returns the usage metrics reported by the service,
+// if any. API servers older than metrics reporting answer with not found.
+func serviceInstanceMetrics(client *Client, service, instance string) ([]InstanceMetric, error) {
+	query := url.Values{}
+	query.Set("service", service)
+	var result struct {
+		Metrics []InstanceMetric `json:"metrics"`
+	}
+	err := getJSON(client, fmt.Sprintf("/services/instances/%s/metrics?%s", instance, query.Encode()), &result)
+	if httpErr, ok := err.(*tsuruerr.HTTP); ok && httpErr.Code == http.StatusNotFound {
+		return nil, nil
+	}
+	return result.Metrics, err
+}
+
+func parseInstanceState(instance string, data []byte) string {
+	prefix := fmt.Sprintf("Service instance %q is ", instance)
+	return strings.TrimPrefix(strings.TrimSpace(string(data)), prefix)
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+type ServiceList struct {
+	CachedCommand
+	fs     *gnuflag.FlagSet
+	json   bool
+	status bool
+}
+
+func (c *ServiceList) Info() *Info {
+	return &Info{
+		Name:  "service-list",
+		Usage: "service-list [-s/--status] [--json] [--cached]",
+		Desc: `Lists the service instances the user has access to, along with their
+plans and the apps bound to them.
+
+The [[--status]] flag retrieves the state of each instance from the service,
+which issues one extra request per instance. The [[--json]] flag prints the
+instances in JSON format, suitable for scripting.
+
+The [[--cached]] flag displays the list retrieved by the last successful run
+against the current target, without contacting the tsuru server. It's meant
+for incident triage when the server is unreachable, the data may be stale.`,
+	}
+}
+
+func (c *ServiceList) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("service-list", gnuflag.ExitOnError)
+		c.fs.BoolVar(&c.json, "json", false, "Display the instances in JSON format")
+		status := "Display the state of each instance"
+		c.fs.BoolVar(&c.status, "status", false, status)
+		c.fs.BoolVar(&c.status, "s", false, status)
+		c.fs = MergeFlagSet(c.CachedCommand.Flags(), c.fs)
+	}
+	return c.fs
+}
+
+func (c *ServiceList) Run(context *Context, client *Client) error {
+	var services []serviceModel
+	err := c.GetJSON(context, client, "/services/instances", &services)
+	if err != nil {
+		return err
+	}
+	entries := []ServiceInstanceEntry{}
+	for _, s := range services {
+		if len(s.Instances) == 0 {
+			continue
+		}
+		var instances []serviceInstanceModel
+		err = c.GetJSON(context, client, "/services/"+s.Service, &instances)
+		if err != nil {
+			return err
+		}
+		for _, si := range instances {
+			entry := ServiceInstanceEntry{
+				Service:  s.Service,
+				Instance: si.Name,
+				Plan:     si.PlanName,
+				Apps:     si.Apps,
+			}
+			if entry.Apps == nil {
+				entry.Apps = []string{}
+			}
+			if c.status {
+				var data []byte
+				data, err = c.Get(context, client, fmt.Sprintf("/services/%s/instances/%s/status", s.Service, si.Name))
+				if err != nil {
+					return err
+				}
+				entry.State = parseInstanceState(si.Name, data)
+			}
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Service == entries[j].Service {
+			return entries[i].Instance < entries[j].Instance
+		}
+		return entries[i].Service < entries[j].Service
+	})
+	if c.json {
+		return writeJSON(context.Stdout, entries)
+	}
+	if len(entries) == 0 {
+		fmt.Fprintln(context.Stdout, "No service instances available.")
+		return nil
+	}
+	table := NewTable()
+	table.LineSeparator = true
+	table.Headers = Row{"Service", "Instance", "Plan", "Apps"}
+	if c.status {
+		table.Headers = append(table.Headers, "State")
+	}
+	for _, e := range entries {
+		row := Row{e.Service, e.Instance, e.Plan, strings.Join(e.Apps, "\n")}
+		if c.status {
+			row = append(row, e.State)
+		}
+		table.AddRow(row)
+	}
+	context.Stdout.Write(table.Bytes())
+	return nil
+}
+
+type ServiceInfo struct {
+	fs   *gnuflag.FlagSet
+	json bool
+}
+
+func (c *ServiceInfo) Info() *Info {
+	return &Info{
+		Name:  "service-info",
+		Usage: "service-info <service-name> <instance-name> [--json]",
+		Desc: `Displays information about a service instance: its state, plan, the apps
+bound to it and any additional information provided by the service.
+
+The [[--json]] flag prints the information in JSON format, suitable for
+scripting.`,
+		MinArgs: 2,
+		MaxArgs: 2,
+	}
+}
+
+func (c *ServiceInfo) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("service-info", gnuflag.ExitOnError)
+		c.fs.BoolVar(&c.json, "json", false, "Display the instance in JSON format")
+	}
+	return c.fs
+}
+
+func (c *ServiceInfo) Run(context *Context, client *Client) error {
+	serviceName, instanceName := context.Args[0], context.Args[1]
+	var info struct {
+		Apps            []string
+		Teams           []string
+		TeamOwner       string
+		Description     string
+		PlanName        string
+		PlanDescription string
+		CustomInfo      map[string]string
+		Tags            []string
+	}
+	err := getJSON(client, fmt.Sprintf("/services/%s/instances/%s", serviceName, instanceName), &info)
+	if err != nil {
+		return err
+	}
+	state, err := serviceInstanceState(client, serviceName, instanceName)
+	if err != nil {
+		return err
+	}
+	metrics, err := serviceInstanceMetrics(client, serviceName, instanceName)
+	if err != nil {
+		return err
+	}
+	details := ServiceInstanceDetails{
+		Service:         serviceName,
+		Instance:        instanceName,
+		State:           state,
+		Plan:            info.PlanName,
+		PlanDescription: info.PlanDescription,
+		Apps:            info.Apps,
+		Teams:           info.Teams,
+		TeamOwner:       info.TeamOwner,
+		Description:     info.Description,
+		Tags:            info.Tags,
+		CustomInfo:      info.CustomInfo,
+		Metrics:         metrics,
+	}
+	if c.json {
+		return writeJSON(context.Stdout, details)
+	}
+	fmt.Fprintf(context.Stdout, "Service: %s\n", details.Service)
+	fmt.Fprintf(context.Stdout, "Instance: %s\n", details.Instance)
+	fmt.Fprintf(context.Stdout, "State: %s\n", details.State)
+	if details.PlanDescription != "" {
+		fmt.Fprintf(context.Stdout, "Plan: %s (%s)\n", details.Plan, details.PlanDescription)
+	} else {
+		fmt.Fprintf(context.Stdout, "Plan: %s\n", details.Plan)
+	}
+	fmt.Fprintf(context.Stdout, "Team owner: %s\n", details.TeamOwner)
+	fmt.Fprintf(context.Stdout, "Teams: %s\n", strings.Join(details.Teams, ", "))
+	fmt.Fprintf(context.Stdout, "Apps: %s\n", strings.Join(details.Apps, ", "))
+	if details.Description != "" {
+		fmt.Fprintf(context.Stdout, "Description: %s\n", details.Description)
+	}
+	if len(details.Tags) > 0 {
+		fmt.Fprintf(context.Stdout, "Tags: %s\n", strings.Join(details.Tags, ", "))
+	}
+	if len(details.CustomInfo) > 0 {
+		table := NewTable()
+		table.Headers = Row{"Key", "Value"}
+		for k, v := range details.CustomInfo {
+			table.AddRow(Row{k, v})
+		}
+		table.Sort()
+		fmt.Fprintf(context.Stdout, "\nCustom Info:\n%s", table.String())
+	}
+	if len(details.Metrics) > 0 {
+		table := NewTable()
+		table.Headers = Row{"Metric", "Value"}
+		for _, m := range details.Metrics {
+			value := strconv.FormatFloat(m.Value, 'f', -1, 64)
+			if m.Unit != "" {
+				value += " " + m.Unit
+			}
+			table.AddRow(Row{m.Name, value})
+		}
+		fmt.Fprintf(context.Stdout, "\nMetrics:\n%s", table.String())
+	}
+	return nil
+}
+
+// bindStatusPollInterval is the interval between checks of the bind status
+// reported by the service, when waiting for the bind to finish.
+var bindStatusPollInterval = 5 * time.Second
+
+// maxBindWait is the longest time the API server accepts to wait for the
+// service instance to become ready before binding it.
+const maxBindWait = 10 * time.Minute
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+type bindStatus struct {
+	Status  string
+	Message string
+}
+
+type ServiceInstanceBind struct {
+	GuessingCommand
+	fs        *gnuflag.FlagSet
+	noRestart bool
+	wait      bool
+	timeout   time.Duration
+}
+
+func (c *ServiceInstanceBind) Info() *Info {
+	return &Info{
+		Name:  "service-instance-bind",
+		Usage: "service-instance-bind <service-name> <instance-name> [-a/--app <appname>] [--no-restart] [-w/--wait] [--timeout <duration>]",
+		Desc: `Binds an application to a service instance, restarting the application so it
+gets the environment variables exported by the service, and lists these
+variables once the bind finishes. Values of private variables are hidden.
+
+The [[--no-restart]] flag binds the application without restarting it.
+
+Some services finish the bind in background and report its status later. The
+[[--wait]] flag waits until the service reports the bind as ready, failing if
+the service reports an error or the [[--timeout]] expires. With [[--wait]],
+instances still being created are also waited for before binding, instead of
+failing right away.`,
+		MinArgs: 2,
+		MaxArgs: 2,
+	}
+}
+
+func (c *ServiceInstanceBind) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = c.GuessingCommand.Flags()
+		c.fs.BoolVar(&c.noRestart, "no-restart", false, "Bind the application without restarting it")
+		wait := "Wait until the service reports the bind as finished"
+		c.fs.BoolVar(&c.wait, "wait", false, wait)
+		c.fs.BoolVar(&c.wait, "w", false, wait)
+		c.fs.DurationVar(&c.timeout, "timeout", 10*time.Minute, "Maximum time to wait for the bind to finish")
+	}
+	return c.fs
+}
+
+func (c *ServiceInstanceBind) Run(context *Context, client *Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	serviceName, instanceName := context.Args[0], context.Args[1]
+	values := url.Values{}
+	values.Set("noRestart", strconv.FormatBool(c.noRestart))
+	if c.wait && c.timeout > 0 {
+		values.Set("wait", strconv.Itoa(int(minDuration(c.timeout, maxBindWait)/time.Second)))
+	}
+	path := fmt.Sprintf("/services/%s/instances/%s/%s", serviceName, instanceName, appName)
+	resp, err := doForm(client, "PUT", path, values)
+	if err != nil {
+		return err
+	}
+	err = StreamJSONResponse(context.Stdout, resp)
+	if err != nil {
+		return err
+	}
+	if c.wait {
+		err = c.waitBind(context, client, serviceName, instanceName, appName)
+		if err != nil {
+			return err
+		}
+	}
+	return printBindEnvs(context, client, serviceName, instanceName, appName)
+}
+
+// waitBind polls the service instance until the service reports the bind of
+// the app as finished. Services that don't report the bind status are
+// considered finished right away.
+func (c *ServiceInstanceBind) waitBind(context *Context, client *Client, serviceName, instanceName, appName string) error {
+	deadline := time.Now().Add(c.timeout)
+	path := fmt.Sprintf("/services/%s/instances/%s", serviceName, instanceName)
+	for {
+		var info struct {
+			BindStatus map[string]bindStatus
+		}
+		err := getJSON(client, path, &info)
+		if err != nil {
+			return err
+		}
+		status, ok := info.BindStatus[appName]
+		if !ok || status.Status == "ready" {
+			return nil
+		}
+		if status.Status == "error" {
+			return errors.Errorf("the service failed to bind the app: %s", status.Message)
+		}
+		if time.Now().After(deadline) {
+			return errors.Errorf("timeout waiting for the service to finish the bind after %s", c.timeout)
+		}
+		fmt.Fprintln(context.Stdout, "Waiting for the service to finish the bind...")
+		time.Sleep(bindStatusPollInterval)
+	}
+}
+
+func printBindEnvs(context *Context, client *Client, serviceName, instanceName, appName string) error {
+	query := url.Values{}
+	query.Set("service", serviceName)
+	query.Set("app", appName)
+	var conn struct {
+		Envs map[string]string `json:"envs"`
+	}
+	err := getJSON(client, fmt.Sprintf("/services/instances/%s/connection?%s", instanceName, query.Encode()), &conn)
+	if err != nil {
+		return err
+	}
+	if len(conn.Envs) == 0 {
+		return nil
+	}
+	table := NewTable()
+	table.Headers = Row{"Name", "Value"}
+	for name, value := range conn.Envs {
+		table.AddRow(Row{name, value})
+	}
+	table.Sort()
+	fmt.Fprintf(context.Stdout, "\nEnvironment variables added to the app:\n%s", table.String())
+	return nil
+}
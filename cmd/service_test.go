@@ -0,0 +1,304 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func serviceTransport() *cmdtest.AnyConditionalTransport {
+	route := func(path, message string) cmdtest.ConditionalTransport {
+		return cmdtest.ConditionalTransport{
+			Transport: cmdtest.Transport{Message: message, Status: http.StatusOK},
+			CondFunc: func(req *http.Request) bool {
+				return req.Method == "GET" && req.URL.Path == path
+			},
+		}
+	}
+	return &cmdtest.AnyConditionalTransport{
+		ConditionalTransports: []cmdtest.ConditionalTransport{
+			route("/1.0/services/instances", `[{"service":"mysql","instances":["db2","db1"],"plans":["small","large"]},{"service":"redis","instances":[],"plans":[]}]`),
+			route("/1.0/services/mysql", `[{"Name":"db2","ServiceName":"mysql","PlanName":"small","Apps":null},{"Name":"db1","ServiceName":"mysql","PlanName":"large","Apps":["app1","app2"]}]`),
+			route("/1.0/services/mysql/instances/db1/status", `Service instance "db1" is up`),
+			route("/1.0/services/mysql/instances/db2/status", `Service instance "db2" is down`),
+			route("/1.0/services/instances/db1/metrics", `{"service":"mysql","instance":"db1","metrics":[{"name":"connections","value":12},{"name":"storage","value":1.5,"unit":"GB"}]}`),
+			route("/1.0/services/mysql/instances/db1", `{"Apps":["app1","app2"],"Teams":["admin","dev"],"TeamOwner":"admin","Description":"main database","PlanName":"large","PlanDescription":"8GB of RAM","CustomInfo":{"Version":"5.7","Host":"10.0.0.1"},"Tags":["prod"]}`),
+		},
+	}
+}
+
+func (s *S) TestServiceListInfo(c *check.C) {
+	var command ServiceList
+	c.Assert(command.Info(), check.NotNil)
+}
+
+func (s *S) TestServiceListRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	client := NewClient(&http.Client{Transport: serviceTransport()}, nil, globalManager)
+	command := ServiceList{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	expected := `+---------+----------+-------+------+
+| Service | Instance | Plan  | Apps |
++---------+----------+-------+------+
+| mysql   | db1      | large | app1 |
+|         |          |       | app2 |
++---------+----------+-------+------+
+| mysql   | db2      | small |      |
++---------+----------+-------+------+
+`
+	c.Assert(stdout.String(), check.Equals, expected)
+}
+
+func (s *S) TestServiceListRunWithStatus(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	client := NewClient(&http.Client{Transport: serviceTransport()}, nil, globalManager)
+	command := ServiceList{}
+	err := command.Flags().Parse(true, []string{"-s"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	expected := `+---------+----------+-------+------+-------+
+| Service | Instance | Plan  | Apps | State |
++---------+----------+-------+------+-------+
+| mysql   | db1      | large | app1 | up    |
+|         |          |       | app2 |       |
++---------+----------+-------+------+-------+
+| mysql   | db2      | small |      | down  |
++---------+----------+-------+------+-------+
+`
+	c.Assert(stdout.String(), check.Equals, expected)
+}
+
+func (s *S) TestServiceListRunJSON(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	client := NewClient(&http.Client{Transport: serviceTransport()}, nil, globalManager)
+	command := ServiceList{}
+	err := command.Flags().Parse(true, []string{"--json", "--status"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	var entries []ServiceInstanceEntry
+	err = json.Unmarshal(stdout.Bytes(), &entries)
+	c.Assert(err, check.IsNil)
+	c.Assert(entries, check.DeepEquals, []ServiceInstanceEntry{
+		{Service: "mysql", Instance: "db1", Plan: "large", Apps: []string{"app1", "app2"}, State: "up"},
+		{Service: "mysql", Instance: "db2", Plan: "small", Apps: []string{}, State: "down"},
+	})
+}
+
+func (s *S) TestServiceListRunEmpty(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Stdout: &stdout, Stderr: &stderr}
+	transport := cmdtest.Transport{Status: http.StatusNoContent}
+	client := NewClient(&http.Client{Transport: &transport}, nil, globalManager)
+	command := ServiceList{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "No service instances available.\n")
+}
+
+func (s *S) TestServiceInfoInfo(c *check.C) {
+	var command ServiceInfo
+	info := command.Info()
+	c.Assert(info, check.NotNil)
+	c.Assert(info.MinArgs, check.Equals, 2)
+}
+
+func (s *S) TestServiceInfoRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"mysql", "db1"}, Stdout: &stdout, Stderr: &stderr}
+	client := NewClient(&http.Client{Transport: serviceTransport()}, nil, globalManager)
+	command := ServiceInfo{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	expected := `Service: mysql
+Instance: db1
+State: up
+Plan: large (8GB of RAM)
+Team owner: admin
+Teams: admin, dev
+Apps: app1, app2
+Description: main database
+Tags: prod
+
+Custom Info:
++---------+----------+
+| Key     | Value    |
++---------+----------+
+| Host    | 10.0.0.1 |
+| Version | 5.7      |
++---------+----------+
+
+Metrics:
++-------------+--------+
+| Metric      | Value  |
++-------------+--------+
+| connections | 12     |
+| storage     | 1.5 GB |
++-------------+--------+
+`
+	c.Assert(stdout.String(), check.Equals, expected)
+}
+
+func (s *S) TestServiceInfoRunWithoutMetrics(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"mysql", "db1"}, Stdout: &stdout, Stderr: &stderr}
+	transport := serviceTransport()
+	transport.ConditionalTransports = append([]cmdtest.ConditionalTransport{{
+		Transport: cmdtest.Transport{Message: "not found", Status: http.StatusNotFound},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/1.0/services/instances/db1/metrics"
+		},
+	}}, transport.ConditionalTransports...)
+	client := NewClient(&http.Client{Transport: transport}, nil, globalManager)
+	command := ServiceInfo{}
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Not(check.Matches), "(?s).*Metrics:.*")
+}
+
+func (s *S) TestServiceInfoRunJSON(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"mysql", "db1"}, Stdout: &stdout, Stderr: &stderr}
+	client := NewClient(&http.Client{Transport: serviceTransport()}, nil, globalManager)
+	command := ServiceInfo{}
+	err := command.Flags().Parse(true, []string{"--json"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	var details ServiceInstanceDetails
+	err = json.Unmarshal(stdout.Bytes(), &details)
+	c.Assert(err, check.IsNil)
+	c.Assert(details, check.DeepEquals, ServiceInstanceDetails{
+		Service:         "mysql",
+		Instance:        "db1",
+		State:           "up",
+		Plan:            "large",
+		PlanDescription: "8GB of RAM",
+		Apps:            []string{"app1", "app2"},
+		Teams:           []string{"admin", "dev"},
+		TeamOwner:       "admin",
+		Description:     "main database",
+		Tags:            []string{"prod"},
+		CustomInfo:      map[string]string{"Version": "5.7", "Host": "10.0.0.1"},
+		Metrics: []InstanceMetric{
+			{Name: "connections", Value: 12},
+			{Name: "storage", Value: 1.5, Unit: "GB"},
+		},
+	})
+}
+
+func bindTransport(statuses ...string) *cmdtest.MultiConditionalTransport {
+	transports := []cmdtest.ConditionalTransport{
+		{
+			Transport: cmdtest.Transport{Message: `{"Message":"restarting app1\n"}` + "\n", Status: http.StatusOK},
+			CondFunc: func(req *http.Request) bool {
+				return req.Method == "PUT" && req.URL.Path == "/1.0/services/mysql/instances/db1/app1" &&
+					req.FormValue("noRestart") == "false"
+			},
+		},
+	}
+	for _, status := range statuses {
+		transports = append(transports, cmdtest.ConditionalTransport{
+			Transport: cmdtest.Transport{Message: `{"Apps":["app1"],"BindStatus":{"app1":` + status + `}}`, Status: http.StatusOK},
+			CondFunc: func(req *http.Request) bool {
+				return req.Method == "GET" && req.URL.Path == "/1.0/services/mysql/instances/db1"
+			},
+		})
+	}
+	transports = append(transports, cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: `{"service":"mysql","instance":"db1","app":"app1","envs":{"MYSQL_HOST":"10.0.0.1","MYSQL_PASSWORD":"*****"}}`, Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.0/services/instances/db1/connection" &&
+				req.URL.Query().Get("service") == "mysql" && req.URL.Query().Get("app") == "app1"
+		},
+	})
+	return &cmdtest.MultiConditionalTransport{ConditionalTransports: transports}
+}
+
+func (s *S) TestServiceInstanceBindInfo(c *check.C) {
+	info := (&ServiceInstanceBind{}).Info()
+	c.Assert(info.Name, check.Equals, "service-instance-bind")
+	c.Assert(info.MinArgs, check.Equals, 2)
+}
+
+func (s *S) TestServiceInstanceBindRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"mysql", "db1"}, Stdout: &stdout, Stderr: &stderr}
+	client := NewClient(&http.Client{Transport: bindTransport()}, nil, globalManager)
+	command := ServiceInstanceBind{}
+	err := command.Flags().Parse(true, []string{"-a", "app1"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	expected := `restarting app1
+
+Environment variables added to the app:
++----------------+----------+
+| Name           | Value    |
++----------------+----------+
+| MYSQL_HOST     | 10.0.0.1 |
+| MYSQL_PASSWORD | *****    |
++----------------+----------+
+`
+	c.Assert(stdout.String(), check.Equals, expected)
+}
+
+func (s *S) TestServiceInstanceBindRunWait(c *check.C) {
+	defer func(interval time.Duration) { bindStatusPollInterval = interval }(bindStatusPollInterval)
+	bindStatusPollInterval = 0
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"mysql", "db1"}, Stdout: &stdout, Stderr: &stderr}
+	trans := bindTransport(`{"Status":"pending"}`, `{"Status":"ready"}`)
+	client := NewClient(&http.Client{Transport: trans}, nil, globalManager)
+	command := ServiceInstanceBind{}
+	err := command.Flags().Parse(true, []string{"-a", "app1", "--wait"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Matches, `(?s)restarting app1
+Waiting for the service to finish the bind...
+
+Environment variables added to the app:.*`)
+	c.Assert(trans.ConditionalTransports, check.HasLen, 0)
+}
+
+func (s *S) TestServiceInstanceBindRunWaitError(c *check.C) {
+	defer func(interval time.Duration) { bindStatusPollInterval = interval }(bindStatusPollInterval)
+	bindStatusPollInterval = 0
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"mysql", "db1"}, Stdout: &stdout, Stderr: &stderr}
+	trans := bindTransport(`{"Status":"error","Message":"quota exceeded"}`)
+	client := NewClient(&http.Client{Transport: trans}, nil, globalManager)
+	command := ServiceInstanceBind{}
+	err := command.Flags().Parse(true, []string{"-a", "app1", "-w"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.ErrorMatches, "the service failed to bind the app: quota exceeded")
+}
+
+func (s *S) TestServiceInstanceBindRunWaitTimeout(c *check.C) {
+	defer func(interval time.Duration) { bindStatusPollInterval = interval }(bindStatusPollInterval)
+	bindStatusPollInterval = 0
+	var stdout, stderr bytes.Buffer
+	context := Context{Args: []string{"mysql", "db1"}, Stdout: &stdout, Stderr: &stderr}
+	trans := bindTransport(`{"Status":"pending"}`)
+	client := NewClient(&http.Client{Transport: trans}, nil, globalManager)
+	command := ServiceInstanceBind{}
+	err := command.Flags().Parse(true, []string{"-a", "app1", "--wait", "--timeout", "-1s"})
+	c.Assert(err, check.IsNil)
+	err = command.Run(&context, client)
+	c.Assert(err, check.ErrorMatches, "timeout waiting for the service to finish the bind after -1s")
+}
@@ -21,6 +21,43 @@ import (
 
 var httpRegexp = regexp.MustCompile(`^http`)
 
+// resizeMessage is sent to the API, in a text frame, every time the terminal
+// is resized. The input typed by the user is sent in binary frames.
+type resizeMessage struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+type binaryFrameWriter struct {
+	conn *websocket.Conn
+}
+
+func (w binaryFrameWriter) Write(p []byte) (int, error) {
+	err := websocket.Message.Send(w.conn, p)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// dialWebsocket opens a websocket connection to the given path of the
+// current target, authenticated with the current token.
+func dialWebsocket(path string) (*websocket.Conn, error) {
+	serverURL, err := GetURL(path)
+	if err != nil {
+		return nil, err
+	}
+	serverURL = httpRegexp.ReplaceAllString(serverURL, "ws")
+	config, err := websocket.NewConfig(serverURL, "ws://localhost")
+	if err != nil {
+		return nil, err
+	}
+	if token, tokenErr := ReadToken(); tokenErr == nil {
+		config.Header.Set("Authorization", "bearer "+token)
+	}
+	return websocket.DialConfig(config)
+}
+
 type ShellToContainerCmd struct {
 	GuessingCommand
 }
@@ -55,9 +92,11 @@ func (c *ShellToContainerCmd) Run(context *Context, client *Client) error {
 	}
 	context.RawOutput()
 	var width, height int
+	termFd := -1
 	if desc, ok := context.Stdin.(descriptable); ok {
 		fd := int(desc.Fd())
 		if terminal.IsTerminal(fd) {
+			termFd = fd
 			width, height, _ = terminal.GetSize(fd)
 			oldState, terminalErr := terminal.MakeRaw(fd)
 			if terminalErr != nil {
@@ -77,6 +116,7 @@ func (c *ShellToContainerCmd) Run(context *Context, client *Client) error {
 	queryString := make(url.Values)
 	queryString.Set("width", strconv.Itoa(width))
 	queryString.Set("height", strconv.Itoa(height))
+	queryString.Set("resize", "true")
 	if len(context.Args) > 0 {
 		queryString.Set("unit", context.Args[0])
 		queryString.Set("container_id", context.Args[0])
@@ -84,27 +124,26 @@ func (c *ShellToContainerCmd) Run(context *Context, client *Client) error {
 	if term := os.Getenv("TERM"); term != "" {
 		queryString.Set("term", term)
 	}
-	serverURL, err := GetURL(fmt.Sprintf("/apps/%s/shell?%s", appName, queryString.Encode()))
-	if err != nil {
-		return err
-	}
-	serverURL = httpRegexp.ReplaceAllString(serverURL, "ws")
-	config, err := websocket.NewConfig(serverURL, "ws://localhost")
-	if err != nil {
-		return err
-	}
-	var token string
-	if token, err = ReadToken(); err == nil {
-		config.Header.Set("Authorization", "bearer "+token)
-	}
-	conn, err := websocket.DialConfig(config)
+	conn, err := dialWebsocket(fmt.Sprintf("/apps/%s/shell?%s", appName, queryString.Encode()))
 	if err != nil {
 		return err
 	}
 	defer conn.Close()
+	if termFd >= 0 {
+		resizeChan := make(chan os.Signal, 1)
+		notifyResize(resizeChan)
+		defer signal.Stop(resizeChan)
+		go func() {
+			for range resizeChan {
+				if w, h, sizeErr := terminal.GetSize(termFd); sizeErr == nil {
+					websocket.JSON.Send(conn, resizeMessage{Width: w, Height: h})
+				}
+			}
+		}()
+	}
 	errs := make(chan error, 2)
 	quit := make(chan bool)
-	go io.Copy(conn, context.Stdin)
+	go io.Copy(binaryFrameWriter{conn: conn}, context.Stdin)
 	go func() {
 		defer close(quit)
 		_, err := io.Copy(context.Stdout, conn)
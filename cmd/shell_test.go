@@ -103,6 +103,61 @@ func (s *S) TestShellToContainerWithUnit(c *check.C) {
 	c.Assert(stdout.String(), check.Equals, "hello my friend\nglad to see you here\n")
 }
 
+func (s *S) TestShellToContainerSendsInputInBinaryFrames(c *check.C) {
+	transport := cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{
+			Message: "",
+			Status:  http.StatusOK,
+		},
+		CondFunc: func(req *http.Request) bool {
+			return req.Method == "GET" && req.URL.Path == "/1.0/apps/myapp"
+		},
+	}
+	guesser := cmdtest.FakeGuesser{Name: "myapp"}
+	var resize string
+	var payloadType byte
+	var input []byte
+	received := websocket.Codec{
+		Unmarshal: func(data []byte, pt byte, v interface{}) error {
+			payloadType = pt
+			input = data
+			return nil
+		},
+	}
+	server := httptest.NewServer(websocket.Handler(func(conn *websocket.Conn) {
+		resize = conn.Request().URL.Query().Get("resize")
+		received.Receive(conn, nil)
+		conn.Write([]byte("done\n"))
+		conn.Close()
+	}))
+	defer server.Close()
+	target := "http://" + server.Listener.Addr().String()
+	os.Setenv("TSURU_TARGET", target)
+	defer os.Unsetenv("TSURU_TARGET")
+	os.Setenv("TSURU_TOKEN", "abc123")
+	defer os.Unsetenv("TSURU_TOKEN")
+	var stdout, stderr bytes.Buffer
+	context := Context{
+		Stdout: &stdout,
+		Stderr: &stderr,
+		Stdin:  bytes.NewBufferString("ls -la\n"),
+	}
+	var command ShellToContainerCmd
+	command.GuessingCommand = GuessingCommand{G: &guesser}
+	err := command.Flags().Parse(true, []string{"-a", "myapp"})
+	c.Assert(err, check.IsNil)
+	mngr := NewManager("admin", "0.1", "admin-ver", &stdout, &stderr, nil, nil)
+	var exiter recordingExiter
+	mngr.e = &exiter
+	client := NewClient(&http.Client{Transport: &transport}, &context, mngr)
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "done\n")
+	c.Assert(resize, check.Equals, "true")
+	c.Assert(payloadType, check.Equals, byte(websocket.BinaryFrame))
+	c.Assert(string(input), check.Equals, "ls -la\n")
+}
+
 func (s *S) TestShellToContainerCmdConnectionRefused(c *check.C) {
 	var buf bytes.Buffer
 	transport := cmdtest.ConditionalTransport{
@@ -9,11 +9,14 @@ import (
 	"log"
 	"strconv"
 
+	"github.com/pkg/errors"
 	"github.com/tsuru/config"
 	"github.com/tsuru/gnuflag"
 	"github.com/tsuru/tsuru/app"
 	appMigrate "github.com/tsuru/tsuru/app/migrate"
 	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/auth/native"
+	"github.com/tsuru/tsuru/auth/oauth"
 	"github.com/tsuru/tsuru/cmd"
 	"github.com/tsuru/tsuru/db"
 	evtMigrate "github.com/tsuru/tsuru/event/migrate"
@@ -25,6 +28,8 @@ import (
 	"github.com/tsuru/tsuru/provision/nodecontainer"
 	"github.com/tsuru/tsuru/provision/pool"
 	"github.com/tsuru/tsuru/router"
+	"github.com/tsuru/tsuru/secret"
+	"github.com/tsuru/tsuru/service"
 	authTypes "github.com/tsuru/tsuru/types/auth"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
@@ -88,10 +93,22 @@ func init() {
 	if err != nil {
 		log.Fatalf("unable to register migration: %s", err)
 	}
+	err = migration.Register("create-app-viewer-role", createAppViewerRole)
+	if err != nil {
+		log.Fatalf("unable to register migration: %s", err)
+	}
+	err = migration.Register("remove-deploy-env-hashes", app.RemoveDeployEnvHashes)
+	if err != nil {
+		log.Fatalf("unable to register migration: %s", err)
+	}
 	err = migration.RegisterOptional("migrate-roles", migrateRoles)
 	if err != nil {
 		log.Fatalf("unable to register migration: %s", err)
 	}
+	err = migration.RegisterOptional("encrypt-sensitive-fields", encryptSensitiveFields)
+	if err != nil {
+		log.Fatalf("unable to register migration: %s", err)
+	}
 }
 
 func getProvisioner() (string, error) {
@@ -261,6 +278,26 @@ func createRole(name, contextType string) (permission.Role, error) {
 	return role, err
 }
 
+// createAppViewerRole creates the app-viewer role, which may be assigned to
+// users that should only be able to read the info, public environment
+// variables and logs of an app, e.g. auditors and support staff.
+func createAppViewerRole() error {
+	role, err := createRole("app-viewer", string(permission.CtxApp))
+	if err != nil {
+		return err
+	}
+	role.Description = "read-only access to app info, public environment variables and logs"
+	err = role.Update()
+	if err != nil {
+		return err
+	}
+	return role.AddPermissions(
+		permission.PermAppReadInfo.FullName(),
+		permission.PermAppReadEnvPublic.FullName(),
+		permission.PermAppReadLog.FullName(),
+	)
+}
+
 func migrateRoles() error {
 	adminTeam, err := config.GetString("admin-team")
 	if err != nil {
@@ -426,3 +463,78 @@ func migrateRCEvents() error {
 	}
 	return evtMigrate.MigrateRCEvents()
 }
+
+// encryptSensitiveFields rewrites service passwords and webhook secrets,
+// service instance envs and callback tokens, private app envs, including the
+// app tokens, and oauth refresh tokens stored before secrets:encrypt-storage
+// was enabled, so they get encrypted, and hashes the stored auth tokens.
+func encryptSensitiveFields() error {
+	if !secret.StorageEncryptionEnabled() {
+		return errors.New("secrets:encrypt-storage must be enabled to encrypt stored fields")
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var services []service.Service
+	err = conn.Services().Find(nil).All(&services)
+	if err != nil {
+		return err
+	}
+	for _, s := range services {
+		err = conn.Services().UpdateId(s.Name, s)
+		if err != nil {
+			return err
+		}
+	}
+	var apps []app.App
+	err = conn.Apps().Find(nil).Select(bson.M{"name": 1, "env": 1, "serviceenvs": 1, "projectenvs": 1}).All(&apps)
+	if err != nil {
+		return err
+	}
+	for _, a := range apps {
+		update := bson.M{}
+		if len(a.Env) > 0 {
+			update["env"] = a.Env
+		}
+		if len(a.ServiceEnvs) > 0 {
+			update["serviceenvs"] = a.ServiceEnvs
+		}
+		if len(a.ProjectEnvs) > 0 {
+			update["projectenvs"] = a.ProjectEnvs
+		}
+		if len(update) == 0 {
+			continue
+		}
+		err = conn.Apps().Update(bson.M{"name": a.Name}, bson.M{"$set": update})
+		if err != nil {
+			return err
+		}
+	}
+	var instances []service.ServiceInstance
+	err = conn.ServiceInstances().Find(bson.M{"callback_token": bson.M{"$exists": true}}).Select(bson.M{"name": 1, "service_name": 1, "callback_token": 1}).All(&instances)
+	if err != nil {
+		return err
+	}
+	for _, si := range instances {
+		token, err := secret.Seal(si.CallbackToken)
+		if err != nil {
+			return err
+		}
+		err = conn.ServiceInstances().Update(bson.M{"name": si.Name, "service_name": si.ServiceName}, bson.M{"$set": bson.M{"callback_token": token}})
+		if err != nil {
+			return err
+		}
+	}
+	// The tokens collection holds the user tokens of the native and saml
+	// schemes and the app tokens of every scheme, all with the same fields.
+	err = native.HashStoredTokens()
+	if err != nil {
+		return err
+	}
+	if scheme, _ := config.GetString("auth:scheme"); scheme == "oauth" {
+		return oauth.SealRefreshTokens()
+	}
+	return nil
+}
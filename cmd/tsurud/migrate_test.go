@@ -7,6 +7,7 @@ package main
 import (
 	"github.com/fsouza/go-dockerclient"
 	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/provision/nodecontainer"
 	"gopkg.in/check.v1"
 	"gopkg.in/mgo.v2/bson"
@@ -86,3 +87,15 @@ func (s *S) TestMigrateBSEnvs(c *check.C) {
 	c.Assert(entries["p2"], check.DeepEquals, expectedP2)
 	c.Assert(entries["p3"], check.DeepEquals, expectedP3)
 }
+
+func (s *S) TestCreateAppViewerRole(c *check.C) {
+	err := createAppViewerRole()
+	c.Assert(err, check.IsNil)
+	role, err := permission.FindRole("app-viewer")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(role.ContextType), check.Equals, "app")
+	c.Assert(role.Description, check.Equals, "read-only access to app info, public environment variables and logs")
+	c.Assert(role.SchemeNames, check.DeepEquals, []string{"app.read.env.public", "app.read.info", "app.read.log"})
+	err = createAppViewerRole()
+	c.Assert(err, check.IsNil)
+}
@@ -7,12 +7,14 @@
 package main
 
 import (
+	"log"
 	"os"
 	"os/signal"
 	"runtime/pprof"
 	"syscall"
 
 	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api"
 )
 
 func listenSignals() {
@@ -23,9 +25,21 @@ func listenSignals() {
 			case syscall.SIGUSR1:
 				pprof.Lookup("goroutine").WriteTo(os.Stdout, 2)
 			case syscall.SIGHUP:
-				config.ReadConfigFile(configPath)
+				reloadConfig()
 			}
 		}
 	}()
 	signal.Notify(ch, syscall.SIGHUP, syscall.SIGUSR1)
 }
+
+func reloadConfig() {
+	err := config.ReadConfigFile(configPath)
+	if err != nil {
+		log.Printf("unable to reload config file %q, keeping the current config: %s", configPath, err)
+		return
+	}
+	err = api.ReloadConfig()
+	if err != nil {
+		log.Printf("unable to apply the reloaded config: %s", err)
+	}
+}
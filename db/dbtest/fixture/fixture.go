@@ -0,0 +1,284 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package fixture builds realistic datasets of users, teams, services,
+// service instances and apps bound to them, loading them into the database
+// for tests. Load takes a snapshot of the collections the dataset fills, so
+// tests can restore them to their previous state when they're done:
+//
+//	func (s *S) SetUpTest(c *check.C) {
+//		s.snapshot, err = fixture.Load(fixture.Default())
+//		c.Assert(err, check.IsNil)
+//	}
+//
+//	func (s *S) TearDownTest(c *check.C) {
+//		c.Assert(s.snapshot.Restore(), check.IsNil)
+//	}
+//
+// Default is meant for authors of provisioners, routers and other plugins
+// testing them against tsuru data. tsuru's own api and service suites only
+// load the users and teams they share through small datasets, their tests
+// still create the services, instances and apps they need.
+package fixture
+
+import (
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/storage"
+	"github.com/tsuru/tsuru/service"
+	appTypes "github.com/tsuru/tsuru/types/app"
+	authTypes "github.com/tsuru/tsuru/types/auth"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Dataset is a set of documents loaded into the database by Load.
+type Dataset struct {
+	Users     []auth.User
+	Teams     []authTypes.Team
+	Services  []service.Service
+	Instances []service.ServiceInstance
+	Apps      []app.App
+}
+
+// Default returns a small but realistic dataset, with an admin and a
+// developer in the "admin" and "dev" teams, the "mysql" service with two
+// instances and the "web" and "worker" apps, web being bound to both
+// instances and worker to one of them.
+func Default() *Dataset {
+	d := &Dataset{
+		Users: []auth.User{
+			{Email: "admin@example.com", Password: "123456"},
+			{Email: "dev@example.com", Password: "123456"},
+		},
+		Teams: []authTypes.Team{
+			{Name: "admin", CreatingUser: "admin@example.com"},
+			{Name: "dev", CreatingUser: "dev@example.com"},
+		},
+		Services: []service.Service{{
+			Name:       "mysql",
+			Username:   "mysql",
+			Password:   "s3cr3t",
+			Endpoint:   map[string]string{"production": "http://mysql.example.com"},
+			OwnerTeams: []string{"admin"},
+		}},
+		Instances: []service.ServiceInstance{
+			{Name: "web-db", ServiceName: "mysql", PlanName: "small", Teams: []string{"dev"}, TeamOwner: "dev"},
+			{Name: "reports-db", ServiceName: "mysql", PlanName: "large", Teams: []string{"dev", "admin"}, TeamOwner: "dev"},
+		},
+		Apps: []app.App{
+			newApp("web", "python", "dev", "dev@example.com"),
+			newApp("worker", "go", "dev", "dev@example.com"),
+		},
+	}
+	d.mustBind("web", "mysql", "web-db", map[string]string{"MYSQL_HOST": "10.0.0.1", "MYSQL_PASSWORD": "web"})
+	d.mustBind("web", "mysql", "reports-db", map[string]string{"MYSQL_HOST": "10.0.0.2", "MYSQL_PASSWORD": "reports"})
+	d.mustBind("worker", "mysql", "reports-db", map[string]string{"MYSQL_HOST": "10.0.0.2", "MYSQL_PASSWORD": "reports"})
+	return d
+}
+
+func newApp(name, platform, team, owner string) app.App {
+	return app.App{
+		Name:      name,
+		Platform:  platform,
+		TeamOwner: team,
+		Teams:     []string{team},
+		Owner:     owner,
+		Plan:      appTypes.Plan{Name: "default", Memory: 512 * 1024 * 1024, CpuShare: 100, Default: true},
+		Env:       map[string]bind.EnvVar{},
+	}
+}
+
+// App returns the app with the given name in the dataset, or nil if there's
+// no such app.
+func (d *Dataset) App(name string) *app.App {
+	for i := range d.Apps {
+		if d.Apps[i].Name == name {
+			return &d.Apps[i]
+		}
+	}
+	return nil
+}
+
+// Instance returns the instance of the given service in the dataset, or nil
+// if there's no such instance.
+func (d *Dataset) Instance(serviceName, name string) *service.ServiceInstance {
+	for i := range d.Instances {
+		if d.Instances[i].ServiceName == serviceName && d.Instances[i].Name == name {
+			return &d.Instances[i]
+		}
+	}
+	return nil
+}
+
+// Bind binds the app to the service instance, both already in the dataset,
+// just like tsuru does: the app is added to the instance and the envs
+// exported by the instance are added to the app.
+func (d *Dataset) Bind(appName, serviceName, instanceName string, envs map[string]string) error {
+	a := d.App(appName)
+	if a == nil {
+		return errors.Errorf("app %q not found in dataset", appName)
+	}
+	si := d.Instance(serviceName, instanceName)
+	if si == nil {
+		return errors.Errorf("service instance %q of service %q not found in dataset", instanceName, serviceName)
+	}
+	si.Apps = append(si.Apps, appName)
+	for name, value := range envs {
+		a.ServiceEnvs = append(a.ServiceEnvs, bind.ServiceEnvVar{
+			EnvVar:       bind.EnvVar{Name: name, Value: value},
+			ServiceName:  serviceName,
+			InstanceName: instanceName,
+		})
+	}
+	return nil
+}
+
+func (d *Dataset) mustBind(appName, serviceName, instanceName string, envs map[string]string) {
+	if err := d.Bind(appName, serviceName, instanceName, envs); err != nil {
+		panic(err)
+	}
+}
+
+// Load takes a snapshot of the collections filled by the dataset and inserts
+// its documents. The returned snapshot restores the collections, removing
+// the dataset and anything else tests stored in them. Collections without
+// documents in the dataset are left out of the snapshot.
+func Load(d *Dataset) (*Snapshot, error) {
+	snapshot, err := Take(d.collections()...)
+	if err != nil {
+		return nil, err
+	}
+	err = d.insert()
+	if err != nil {
+		snapshot.Restore()
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+func (d *Dataset) insert() error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	for i := range d.Users {
+		err = d.Users[i].Create()
+		if err != nil {
+			return err
+		}
+	}
+	for _, t := range d.Teams {
+		err = auth.TeamService().Insert(t)
+		if err != nil {
+			return err
+		}
+	}
+	for i := range d.Services {
+		err = conn.Services().Insert(d.Services[i])
+		if err != nil {
+			return err
+		}
+	}
+	for i := range d.Instances {
+		err = conn.ServiceInstances().Insert(d.Instances[i])
+		if err != nil {
+			return err
+		}
+	}
+	for i := range d.Apps {
+		err = conn.Apps().Insert(d.Apps[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Dataset) collections() []string {
+	var names []string
+	for name, n := range map[string]int{
+		"users":             len(d.Users),
+		"teams":             len(d.Teams),
+		"services":          len(d.Services),
+		"service_instances": len(d.Instances),
+		"apps":              len(d.Apps),
+	} {
+		if n > 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Snapshot holds the documents stored in a set of collections at the time
+// it was taken.
+type Snapshot struct {
+	docs map[string][]bson.Raw
+}
+
+// Take takes a snapshot of the given collections. Without collections, the
+// ones storing datasets are used.
+func Take(collections ...string) (*Snapshot, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if len(collections) == 0 {
+		collections = datasetCollections(conn)
+	}
+	s := &Snapshot{docs: make(map[string][]bson.Raw, len(collections))}
+	for _, name := range collections {
+		var docs []bson.Raw
+		err = conn.Collection(name).Find(nil).All(&docs)
+		if err != nil {
+			return nil, err
+		}
+		s.docs[name] = docs
+	}
+	return s, nil
+}
+
+// Restore replaces the documents in the collections of the snapshot with
+// the ones stored when it was taken.
+func (s *Snapshot) Restore() error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	for name, docs := range s.docs {
+		coll := conn.Collection(name)
+		_, err = coll.RemoveAll(nil)
+		if err != nil {
+			return err
+		}
+		for _, doc := range docs {
+			err = coll.Insert(doc)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func datasetCollections(conn *db.Storage) []string {
+	colls := []*storage.Collection{
+		conn.Users(),
+		conn.Collection("teams"),
+		conn.Services(),
+		conn.ServiceInstances(),
+		conn.Apps(),
+	}
+	names := make([]string, len(colls))
+	for i, coll := range colls {
+		names[i] = coll.Name
+	}
+	return names
+}
@@ -0,0 +1,85 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fixture
+
+import (
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/service"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestDefault(c *check.C) {
+	d := Default()
+	c.Assert(d.Users, check.HasLen, 2)
+	c.Assert(d.Teams, check.HasLen, 2)
+	c.Assert(d.Services, check.HasLen, 1)
+	c.Assert(d.Instances, check.HasLen, 2)
+	c.Assert(d.Apps, check.HasLen, 2)
+	c.Assert(d.Instance("mysql", "web-db").Apps, check.DeepEquals, []string{"web"})
+	c.Assert(d.Instance("mysql", "reports-db").Apps, check.DeepEquals, []string{"web", "worker"})
+	c.Assert(d.App("web").ServiceEnvs, check.HasLen, 4)
+	c.Assert(d.App("worker").ServiceEnvs, check.HasLen, 2)
+}
+
+func (s *S) TestDatasetBindNotFound(c *check.C) {
+	d := Default()
+	err := d.Bind("api", "mysql", "web-db", nil)
+	c.Assert(err, check.ErrorMatches, `app "api" not found in dataset`)
+	err = d.Bind("web", "mysql", "api-db", nil)
+	c.Assert(err, check.ErrorMatches, `service instance "api-db" of service "mysql" not found in dataset`)
+}
+
+func (s *S) TestLoadAndRestore(c *check.C) {
+	existing := auth.User{Email: "existing@example.com"}
+	err := existing.Create()
+	c.Assert(err, check.IsNil)
+	snapshot, err := Load(Default())
+	c.Assert(err, check.IsNil)
+	n, err := s.conn.Users().Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 3)
+	var a app.App
+	err = s.conn.Apps().Find(bson.M{"name": "web"}).One(&a)
+	c.Assert(err, check.IsNil)
+	c.Assert(a.ServiceEnvs, check.HasLen, 4)
+	var si service.ServiceInstance
+	err = s.conn.ServiceInstances().Find(bson.M{"name": "reports-db"}).One(&si)
+	c.Assert(err, check.IsNil)
+	c.Assert(si.Apps, check.DeepEquals, []string{"web", "worker"})
+	teams, err := auth.ListTeams()
+	c.Assert(err, check.IsNil)
+	c.Assert(teams, check.HasLen, 2)
+	err = s.conn.Apps().Insert(app.App{Name: "created-by-test"})
+	c.Assert(err, check.IsNil)
+	err = snapshot.Restore()
+	c.Assert(err, check.IsNil)
+	var users []auth.User
+	err = s.conn.Users().Find(nil).All(&users)
+	c.Assert(err, check.IsNil)
+	c.Assert(users, check.HasLen, 1)
+	c.Assert(users[0].Email, check.Equals, "existing@example.com")
+	for _, name := range []string{"teams", "services", "service_instances", "apps"} {
+		n, err = s.conn.Collection(name).Count()
+		c.Assert(err, check.IsNil)
+		c.Assert(n, check.Equals, 0, check.Commentf("collection %s", name))
+	}
+}
+
+func (s *S) TestLoadSnapshotsOnlyDatasetCollections(c *check.C) {
+	snapshot, err := Load(&Dataset{Users: []auth.User{{Email: "dev@example.com"}}})
+	c.Assert(err, check.IsNil)
+	err = s.conn.Apps().Insert(app.App{Name: "created-by-test"})
+	c.Assert(err, check.IsNil)
+	err = snapshot.Restore()
+	c.Assert(err, check.IsNil)
+	n, err := s.conn.Users().Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 0)
+	n, err = s.conn.Apps().Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 1)
+}
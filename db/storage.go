@@ -13,6 +13,7 @@ package db
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/db/storage"
@@ -103,7 +104,10 @@ func (s *Storage) Services() *storage.Collection {
 
 // ServiceInstances returns the services_instances collection from MongoDB.
 func (s *Storage) ServiceInstances() *storage.Collection {
-	return s.Collection("service_instances")
+	nameIndex := mgo.Index{Key: []string{"service_name", "name"}, Unique: true}
+	c := s.Collection("service_instances")
+	c.EnsureIndex(nameIndex)
+	return c
 }
 
 // Pools returns the pool collection.
@@ -149,6 +153,30 @@ func (s *Storage) Quota() *storage.Collection {
 	return c
 }
 
+// LogRetentionRuns returns the collection used to coordinate the runs of the
+// log retention task among tsuru API instances.
+func (s *Storage) LogRetentionRuns() *storage.Collection {
+	return s.Collection("log_retention_runs")
+}
+
+// EnvDriftRuns returns the collection used to coordinate the runs of the env
+// drift check among tsuru API instances.
+func (s *Storage) EnvDriftRuns() *storage.Collection {
+	return s.Collection("env_drift_runs")
+}
+
+// AppPurgeRuns returns the collection used to coordinate the permanent
+// removal of deleted apps among tsuru API instances.
+func (s *Storage) AppPurgeRuns() *storage.Collection {
+	return s.Collection("app_purge_runs")
+}
+
+// EventPruneRuns returns the collection used to coordinate the pruning of
+// old events among tsuru API instances.
+func (s *Storage) EventPruneRuns() *storage.Collection {
+	return s.Collection("event_prune_runs")
+}
+
 // SAMLRequests returns the saml_requests from MongoDB.
 func (s *Storage) SAMLRequests() *storage.Collection {
 	id := mgo.Index{Key: []string{"id"}}
@@ -157,10 +185,26 @@ func (s *Storage) SAMLRequests() *storage.Collection {
 	return coll
 }
 
-var logCappedInfo = mgo.CollectionInfo{
-	Capped:   true,
-	MaxBytes: 200 * 5000,
-	MaxDocs:  5000,
+const defaultLogMaxDocs = 5000
+
+// LogsCappedInfo returns the options of the capped collection storing the
+// logs of an app. Its size is set by log:retention:max-docs, which may be
+// overridden for an app in log:retention:apps:<app>:max-docs. The options
+// only take effect when the collection is created, existing collections are
+// resized by the log retention task when their size differs.
+func LogsCappedInfo(appName string) *mgo.CollectionInfo {
+	maxDocs, err := config.GetInt("log:retention:apps:" + appName + ":max-docs")
+	if err != nil || maxDocs <= 0 {
+		maxDocs, err = config.GetInt("log:retention:max-docs")
+		if err != nil || maxDocs <= 0 {
+			maxDocs = defaultLogMaxDocs
+		}
+	}
+	return &mgo.CollectionInfo{
+		Capped:   true,
+		MaxBytes: 200 * maxDocs,
+		MaxDocs:  maxDocs,
+	}
 }
 
 // Logs returns the logs collection for one app from MongoDB.
@@ -169,7 +213,7 @@ func (s *LogStorage) Logs(appName string) *storage.Collection {
 		return nil
 	}
 	c := s.Collection("logs_" + appName)
-	c.Create(&logCappedInfo)
+	c.Create(LogsCappedInfo(appName))
 	return c
 }
 
@@ -224,6 +268,37 @@ func (s *Storage) EventBlocks() *storage.Collection {
 	return c
 }
 
+func (s *Storage) Maintenance() *storage.Collection {
+	return s.Collection("maintenance")
+}
+
+func (s *Storage) MaintenanceWindows() *storage.Collection {
+	index := mgo.Index{Key: []string{"end"}}
+	c := s.Collection("maintenance_windows")
+	c.EnsureIndex(index)
+	return c
+}
+
+func (s *Storage) FeatureFlags() *storage.Collection {
+	return s.Collection("feature_flags")
+}
+
+func (s *Storage) AppTemplates() *storage.Collection {
+	return s.Collection("app_templates")
+}
+
+func (s *Storage) Projects() *storage.Collection {
+	return s.Collection("projects")
+}
+
+func (s *Storage) TeamPolicies() *storage.Collection {
+	return s.Collection("team_policies")
+}
+
+func (s *Storage) DeployFreezes() *storage.Collection {
+	return s.Collection("deploy_freezes")
+}
+
 func (s *Storage) InstallHosts() *storage.Collection {
 	nameIndex := mgo.Index{Key: []string{"name"}, Unique: true}
 	c := s.Collection("install_hosts")
@@ -245,3 +320,46 @@ func (s *Storage) VolumeBinds() *storage.Collection {
 	c := s.Collection("volume_binds")
 	return c
 }
+
+func (s *Storage) Jobs() *storage.Collection {
+	index := mgo.Index{Key: []string{"app", "name"}, Unique: true}
+	nextRunIndex := mgo.Index{Key: []string{"nextrun"}}
+	c := s.Collection("jobs")
+	c.EnsureIndex(index)
+	c.EnsureIndex(nextRunIndex)
+	return c
+}
+
+func (s *Storage) JobRuns() *storage.Collection {
+	index := mgo.Index{Key: []string{"app", "job", "-enqueuedat"}}
+	c := s.Collection("job_runs")
+	c.EnsureIndex(index)
+	return c
+}
+
+func (s *Storage) DeployApprovals() *storage.Collection {
+	index := mgo.Index{Key: []string{"app", "-requestedat"}}
+	c := s.Collection("deploy_approvals")
+	c.EnsureIndex(index)
+	return c
+}
+
+func (s *Storage) NameReservations() *storage.Collection {
+	index := mgo.Index{Key: []string{"kind", "name"}, Unique: true}
+	c := s.Collection("name_reservations")
+	c.EnsureIndex(index)
+	return c
+}
+
+func (s *Storage) NamingPrefixes() *storage.Collection {
+	return s.Collection("naming_prefixes")
+}
+
+func (s *Storage) RequestNonces() *storage.Collection {
+	// Nonces are removed once their expireat is reached, see the comment
+	// about ExpireAfter in storage/mongodb/cache.go.
+	index := mgo.Index{Key: []string{"expireat"}, ExpireAfter: time.Second}
+	c := s.Collection("request_nonces")
+	c.EnsureIndex(index)
+	return c
+}
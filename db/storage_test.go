@@ -11,6 +11,7 @@ import (
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/db/storage"
 	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2"
 )
 
 type hasUniqueIndexChecker struct{}
@@ -168,6 +169,35 @@ func (s *S) TestLogs(c *check.C) {
 	c.Assert(logs, check.DeepEquals, logsc)
 }
 
+func (s *S) TestLogsCappedInfo(c *check.C) {
+	c.Assert(LogsCappedInfo("myapp"), check.DeepEquals, &mgo.CollectionInfo{Capped: true, MaxBytes: 200 * 5000, MaxDocs: 5000})
+	config.Set("log:retention:max-docs", 1000)
+	defer config.Unset("log:retention:max-docs")
+	c.Assert(LogsCappedInfo("myapp"), check.DeepEquals, &mgo.CollectionInfo{Capped: true, MaxBytes: 200 * 1000, MaxDocs: 1000})
+	config.Set("log:retention:apps:myapp:max-docs", 50)
+	defer config.Unset("log:retention:apps")
+	c.Assert(LogsCappedInfo("myapp"), check.DeepEquals, &mgo.CollectionInfo{Capped: true, MaxBytes: 200 * 50, MaxDocs: 50})
+	c.Assert(LogsCappedInfo("otherapp"), check.DeepEquals, &mgo.CollectionInfo{Capped: true, MaxBytes: 200 * 1000, MaxDocs: 1000})
+}
+
+func (s *S) TestLogRetentionRuns(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	runs := strg.LogRetentionRuns()
+	runsc := strg.Collection("log_retention_runs")
+	c.Assert(runs, check.DeepEquals, runsc)
+}
+
+func (s *S) TestEventPruneRuns(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	runs := strg.EventPruneRuns()
+	runsc := strg.Collection("event_prune_runs")
+	c.Assert(runs, check.DeepEquals, runsc)
+}
+
 func (s *S) TestRoles(c *check.C) {
 	strg, err := Conn()
 	c.Assert(err, check.IsNil)
@@ -177,6 +207,69 @@ func (s *S) TestRoles(c *check.C) {
 	c.Assert(roles, check.DeepEquals, rolesc)
 }
 
+func (s *S) TestMaintenance(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	maintenance := strg.Maintenance()
+	maintenancec := strg.Collection("maintenance")
+	c.Assert(maintenance, check.DeepEquals, maintenancec)
+}
+
+func (s *S) TestMaintenanceWindows(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	windows := strg.MaintenanceWindows()
+	windowsc := strg.Collection("maintenance_windows")
+	c.Assert(windows, check.DeepEquals, windowsc)
+}
+
+func (s *S) TestFeatureFlags(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	flags := strg.FeatureFlags()
+	flagsc := strg.Collection("feature_flags")
+	c.Assert(flags, check.DeepEquals, flagsc)
+}
+
+func (s *S) TestAppTemplates(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	templates := strg.AppTemplates()
+	templatesc := strg.Collection("app_templates")
+	c.Assert(templates, check.DeepEquals, templatesc)
+}
+
+func (s *S) TestTeamPolicies(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	policies := strg.TeamPolicies()
+	policiesc := strg.Collection("team_policies")
+	c.Assert(policies, check.DeepEquals, policiesc)
+}
+
+func (s *S) TestDeployFreezes(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	freezes := strg.DeployFreezes()
+	freezesc := strg.Collection("deploy_freezes")
+	c.Assert(freezes, check.DeepEquals, freezesc)
+}
+
+func (s *S) TestProjects(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	projects := strg.Projects()
+	projectsc := strg.Collection("projects")
+	c.Assert(projects, check.DeepEquals, projectsc)
+}
+
 func (s *S) TestInstallHosts(c *check.C) {
 	strg, err := Conn()
 	c.Assert(err, check.IsNil)
@@ -185,3 +278,49 @@ func (s *S) TestInstallHosts(c *check.C) {
 	hostsc := strg.Collection("install_hosts")
 	c.Assert(hosts, check.DeepEquals, hostsc)
 }
+
+func (s *S) TestDeployApprovals(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	approvals := strg.DeployApprovals()
+	approvalsc := strg.Collection("deploy_approvals")
+	c.Assert(approvals, check.DeepEquals, approvalsc)
+}
+
+func (s *S) TestNameReservations(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	reservations := strg.NameReservations()
+	reservationsc := strg.Collection("name_reservations")
+	c.Assert(reservations, check.DeepEquals, reservationsc)
+	c.Assert(reservations, HasUniqueIndex, []string{"kind", "name"})
+}
+
+func (s *S) TestNamingPrefixes(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	prefixes := strg.NamingPrefixes()
+	prefixesc := strg.Collection("naming_prefixes")
+	c.Assert(prefixes, check.DeepEquals, prefixesc)
+}
+
+func (s *S) TestRequestNonces(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	nonces := strg.RequestNonces()
+	noncesc := strg.Collection("request_nonces")
+	c.Assert(nonces, check.DeepEquals, noncesc)
+}
+
+func (s *S) TestAppPurgeRuns(c *check.C) {
+	strg, err := Conn()
+	c.Assert(err, check.IsNil)
+	defer strg.Close()
+	runs := strg.AppPurgeRuns()
+	runsc := strg.Collection("app_purge_runs")
+	c.Assert(runs, check.DeepEquals, runsc)
+}
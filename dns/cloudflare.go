@@ -0,0 +1,143 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dns
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	tsuruNet "github.com/tsuru/tsuru/net"
+)
+
+const defaultCloudflareURL = "https://api.cloudflare.com/client/v4"
+
+func init() {
+	Register("cloudflare", newCloudflareProvider)
+}
+
+type cloudflareProvider struct {
+	url    string
+	zoneID string
+	token  string
+}
+
+type cloudflareRecord struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int    `json:"ttl,omitempty"`
+}
+
+type cloudflareResponse struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+	Result json.RawMessage `json:"result"`
+}
+
+func newCloudflareProvider(prefix string) (Provider, error) {
+	zoneID, err := config.GetString(prefix + ":zone-id")
+	if err != nil {
+		return nil, err
+	}
+	token, err := config.GetString(prefix + ":token")
+	if err != nil {
+		return nil, err
+	}
+	apiURL, _ := config.GetString(prefix + ":api-url")
+	if apiURL == "" {
+		apiURL = defaultCloudflareURL
+	}
+	return &cloudflareProvider{
+		url:    strings.TrimSuffix(apiURL, "/"),
+		zoneID: zoneID,
+		token:  token,
+	}, nil
+}
+
+func (p *cloudflareProvider) findRecord(name string) (*cloudflareRecord, error) {
+	query := url.Values{"type": []string{"CNAME"}, "name": []string{name}}
+	var records []cloudflareRecord
+	err := p.do("GET", "/dns_records?"+query.Encode(), nil, &records)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return &records[0], nil
+}
+
+func (p *cloudflareProvider) GetCNAME(name string) (string, error) {
+	record, err := p.findRecord(name)
+	if err != nil || record == nil {
+		return "", err
+	}
+	return record.Content, nil
+}
+
+func (p *cloudflareProvider) AddCNAME(name, target string, ttl int) error {
+	return p.do("POST", "/dns_records", cloudflareRecord{
+		Type:    "CNAME",
+		Name:    name,
+		Content: target,
+		TTL:     ttl,
+	}, nil)
+}
+
+func (p *cloudflareProvider) RemoveCNAME(name, target string) error {
+	record, err := p.findRecord(name)
+	if err != nil || record == nil {
+		return err
+	}
+	return p.do("DELETE", "/dns_records/"+record.ID, nil, nil)
+}
+
+func (p *cloudflareProvider) do(method, path string, data, result interface{}) error {
+	var body io.Reader
+	if data != nil {
+		b, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, fmt.Sprintf("%s/zones/%s%s", p.url, p.zoneID, path), body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.token)
+	req.Header.Set("Content-Type", "application/json")
+	rsp, err := tsuruNet.Dial5Full60ClientNoKeepAlive.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	var cfRsp cloudflareResponse
+	err = json.NewDecoder(rsp.Body).Decode(&cfRsp)
+	if err != nil {
+		return errors.Wrapf(err, "invalid response from cloudflare (%d)", rsp.StatusCode)
+	}
+	if !cfRsp.Success {
+		msgs := make([]string, len(cfRsp.Errors))
+		for i, e := range cfRsp.Errors {
+			msgs[i] = e.Message
+		}
+		return errors.Errorf("cloudflare request failed (%d): %s", rsp.StatusCode, strings.Join(msgs, ", "))
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(cfRsp.Result, result)
+}
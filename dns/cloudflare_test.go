@@ -0,0 +1,103 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dns
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+)
+
+type fakeCloudflare struct {
+	records []cloudflareRecord
+}
+
+func (f *fakeCloudflare) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer mytoken" {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"success":false,"errors":[{"message":"invalid token"}]}`)
+		return
+	}
+	var result interface{}
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/zones/zone1/dns_records":
+		records := []cloudflareRecord{}
+		for _, rec := range f.records {
+			if rec.Name == r.URL.Query().Get("name") && rec.Type == r.URL.Query().Get("type") {
+				records = append(records, rec)
+			}
+		}
+		result = records
+	case r.Method == "POST" && r.URL.Path == "/zones/zone1/dns_records":
+		var rec cloudflareRecord
+		json.NewDecoder(r.Body).Decode(&rec)
+		rec.ID = fmt.Sprintf("id%d", len(f.records))
+		f.records = append(f.records, rec)
+		result = rec
+	case r.Method == "DELETE":
+		for i, rec := range f.records {
+			if r.URL.Path == "/zones/zone1/dns_records/"+rec.ID {
+				f.records = append(f.records[:i], f.records[i+1:]...)
+				break
+			}
+		}
+		result = map[string]string{}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"success":false,"errors":[{"message":"not found"}]}`)
+		return
+	}
+	data, _ := json.Marshal(result)
+	fmt.Fprintf(w, `{"success":true,"errors":[],"result":%s}`, data)
+}
+
+func (s *S) newCloudflare(c *check.C, fake *fakeCloudflare) (Provider, func()) {
+	srv := httptest.NewServer(fake)
+	config.Set("dns:cloudflare:api-url", srv.URL)
+	config.Set("dns:cloudflare:zone-id", "zone1")
+	config.Set("dns:cloudflare:token", "mytoken")
+	p, err := newCloudflareProvider("dns:cloudflare")
+	c.Assert(err, check.IsNil)
+	return p, srv.Close
+}
+
+func (s *S) TestCloudflareProvider(c *check.C) {
+	fake := &fakeCloudflare{}
+	p, stop := s.newCloudflare(c, fake)
+	defer stop()
+	target, err := p.GetCNAME("www.example.com")
+	c.Assert(err, check.IsNil)
+	c.Assert(target, check.Equals, "")
+	err = p.AddCNAME("www.example.com", "myapp.tsuru.io", 300)
+	c.Assert(err, check.IsNil)
+	c.Assert(fake.records, check.DeepEquals, []cloudflareRecord{
+		{ID: "id0", Type: "CNAME", Name: "www.example.com", Content: "myapp.tsuru.io", TTL: 300},
+	})
+	target, err = p.GetCNAME("www.example.com")
+	c.Assert(err, check.IsNil)
+	c.Assert(target, check.Equals, "myapp.tsuru.io")
+	err = p.RemoveCNAME("www.example.com", "myapp.tsuru.io")
+	c.Assert(err, check.IsNil)
+	c.Assert(fake.records, check.HasLen, 0)
+}
+
+func (s *S) TestCloudflareProviderError(c *check.C) {
+	_, stop := s.newCloudflare(c, &fakeCloudflare{})
+	defer stop()
+	config.Set("dns:cloudflare:token", "wrong")
+	p, err := newCloudflareProvider("dns:cloudflare")
+	c.Assert(err, check.IsNil)
+	_, err = p.GetCNAME("www.example.com")
+	c.Assert(err, check.ErrorMatches, `cloudflare request failed \(403\): invalid token`)
+}
+
+func (s *S) TestCloudflareProviderMissingConfig(c *check.C) {
+	_, err := newCloudflareProvider("dns:cloudflare")
+	c.Assert(err, check.NotNil)
+}
@@ -0,0 +1,124 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package dns provides integration with DNS providers, used to manage the
+// records of the CNAMEs added to apps. The provider is chosen by the
+// dns:provider config entry, when it's not set tsuru doesn't touch DNS
+// records at all.
+package dns
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+)
+
+const defaultTTL = 300
+
+// ErrRecordConflict is returned when a record already exists for a name
+// pointing to a target other than the expected one.
+type ErrRecordConflict struct {
+	Name   string
+	Target string
+}
+
+func (e *ErrRecordConflict) Error() string {
+	return fmt.Sprintf("DNS record %q already exists pointing to %q", e.Name, e.Target)
+}
+
+// Provider manages CNAME records in a DNS zone.
+type Provider interface {
+	// GetCNAME returns the target of the CNAME record for name, or an empty
+	// string if there's no such record.
+	GetCNAME(name string) (string, error)
+	AddCNAME(name, target string, ttl int) error
+	RemoveCNAME(name, target string) error
+}
+
+var providers = struct {
+	sync.Mutex
+	factories map[string]func(configPrefix string) (Provider, error)
+}{factories: map[string]func(configPrefix string) (Provider, error){}}
+
+// Register registers a new DNS provider, which can be later selected in the
+// dns:provider config entry.
+func Register(name string, factory func(configPrefix string) (Provider, error)) {
+	providers.Lock()
+	defer providers.Unlock()
+	providers.factories[name] = factory
+}
+
+// Enabled returns whether a DNS provider is configured.
+func Enabled() bool {
+	name, _ := config.GetString("dns:provider")
+	return name != ""
+}
+
+func getProvider() (Provider, error) {
+	name, _ := config.GetString("dns:provider")
+	providers.Lock()
+	factory, ok := providers.factories[name]
+	providers.Unlock()
+	if !ok {
+		return nil, errors.Errorf("unknown DNS provider %q", name)
+	}
+	return factory("dns:" + name)
+}
+
+// EnsureCNAME makes sure there's a CNAME record for name pointing to target,
+// creating it if necessary. An existing record pointing to a different target
+// is never overwritten, ErrRecordConflict is returned instead. It's a no-op
+// when no DNS provider is configured.
+func EnsureCNAME(name, target string) error {
+	if !Enabled() {
+		return nil
+	}
+	p, err := getProvider()
+	if err != nil {
+		return err
+	}
+	current, err := p.GetCNAME(name)
+	if err != nil {
+		return err
+	}
+	if current != "" {
+		if normalize(current) != normalize(target) {
+			return &ErrRecordConflict{Name: name, Target: current}
+		}
+		return nil
+	}
+	ttl, err := config.GetInt("dns:ttl")
+	if err != nil {
+		ttl = defaultTTL
+	}
+	return p.AddCNAME(name, target, ttl)
+}
+
+// RemoveCNAME removes the CNAME record for name, as long as it points to
+// target. It's a no-op when no DNS provider is configured or when there's no
+// such record.
+func RemoveCNAME(name, target string) error {
+	if !Enabled() {
+		return nil
+	}
+	p, err := getProvider()
+	if err != nil {
+		return err
+	}
+	current, err := p.GetCNAME(name)
+	if err != nil {
+		return err
+	}
+	if current == "" || normalize(current) != normalize(target) {
+		return nil
+	}
+	return p.RemoveCNAME(name, current)
+}
+
+func normalize(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
@@ -0,0 +1,116 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dns
+
+import (
+	"testing"
+
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type S struct {
+	provider *memoryProvider
+}
+
+var _ = check.Suite(&S{})
+
+type memoryProvider struct {
+	records map[string]string
+	ttls    map[string]int
+}
+
+func (p *memoryProvider) GetCNAME(name string) (string, error) {
+	return p.records[name], nil
+}
+
+func (p *memoryProvider) AddCNAME(name, target string, ttl int) error {
+	p.records[name] = target
+	p.ttls[name] = ttl
+	return nil
+}
+
+func (p *memoryProvider) RemoveCNAME(name, target string) error {
+	delete(p.records, name)
+	return nil
+}
+
+func (s *S) SetUpTest(c *check.C) {
+	s.provider = &memoryProvider{records: map[string]string{}, ttls: map[string]int{}}
+	Register("memory", func(prefix string) (Provider, error) {
+		c.Assert(prefix, check.Equals, "dns:memory")
+		return s.provider, nil
+	})
+	config.Set("dns:provider", "memory")
+}
+
+func (s *S) TearDownTest(c *check.C) {
+	config.Unset("dns")
+}
+
+func (s *S) TestEnabled(c *check.C) {
+	c.Assert(Enabled(), check.Equals, true)
+	config.Unset("dns:provider")
+	c.Assert(Enabled(), check.Equals, false)
+}
+
+func (s *S) TestEnsureCNAME(c *check.C) {
+	err := EnsureCNAME("www.example.com", "myapp.tsuru.io")
+	c.Assert(err, check.IsNil)
+	c.Assert(s.provider.records, check.DeepEquals, map[string]string{"www.example.com": "myapp.tsuru.io"})
+	c.Assert(s.provider.ttls["www.example.com"], check.Equals, defaultTTL)
+}
+
+func (s *S) TestEnsureCNAMECustomTTL(c *check.C) {
+	config.Set("dns:ttl", 60)
+	err := EnsureCNAME("www.example.com", "myapp.tsuru.io")
+	c.Assert(err, check.IsNil)
+	c.Assert(s.provider.ttls["www.example.com"], check.Equals, 60)
+}
+
+func (s *S) TestEnsureCNAMEExistingRecord(c *check.C) {
+	s.provider.records["www.example.com"] = "MyApp.tsuru.io."
+	err := EnsureCNAME("www.example.com", "myapp.tsuru.io")
+	c.Assert(err, check.IsNil)
+	c.Assert(s.provider.ttls, check.HasLen, 0)
+}
+
+func (s *S) TestEnsureCNAMEConflict(c *check.C) {
+	s.provider.records["www.example.com"] = "otherapp.tsuru.io"
+	err := EnsureCNAME("www.example.com", "myapp.tsuru.io")
+	c.Assert(err, check.DeepEquals, &ErrRecordConflict{Name: "www.example.com", Target: "otherapp.tsuru.io"})
+	c.Assert(s.provider.records["www.example.com"], check.Equals, "otherapp.tsuru.io")
+}
+
+func (s *S) TestEnsureCNAMEDisabled(c *check.C) {
+	config.Unset("dns:provider")
+	err := EnsureCNAME("www.example.com", "myapp.tsuru.io")
+	c.Assert(err, check.IsNil)
+	c.Assert(s.provider.records, check.HasLen, 0)
+}
+
+func (s *S) TestEnsureCNAMEUnknownProvider(c *check.C) {
+	config.Set("dns:provider", "unknown")
+	err := EnsureCNAME("www.example.com", "myapp.tsuru.io")
+	c.Assert(err, check.ErrorMatches, `unknown DNS provider "unknown"`)
+}
+
+func (s *S) TestRemoveCNAME(c *check.C) {
+	s.provider.records["www.example.com"] = "myapp.tsuru.io"
+	err := RemoveCNAME("www.example.com", "myapp.tsuru.io")
+	c.Assert(err, check.IsNil)
+	c.Assert(s.provider.records, check.HasLen, 0)
+	err = RemoveCNAME("www.example.com", "myapp.tsuru.io")
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestRemoveCNAMEOtherTarget(c *check.C) {
+	s.provider.records["www.example.com"] = "otherapp.tsuru.io"
+	err := RemoveCNAME("www.example.com", "myapp.tsuru.io")
+	c.Assert(err, check.IsNil)
+	c.Assert(s.provider.records, check.DeepEquals, map[string]string{"www.example.com": "otherapp.tsuru.io"})
+}
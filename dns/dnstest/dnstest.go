@@ -0,0 +1,65 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dnstest
+
+import (
+	"sync"
+
+	"github.com/tsuru/tsuru/dns"
+)
+
+// FakeProvider is the provider registered as "fake", it keeps records in
+// memory.
+var FakeProvider = &fakeProvider{records: map[string]string{}}
+
+func init() {
+	dns.Register("fake", func(prefix string) (dns.Provider, error) {
+		return FakeProvider, nil
+	})
+}
+
+type fakeProvider struct {
+	sync.Mutex
+	records map[string]string
+}
+
+func (p *fakeProvider) GetCNAME(name string) (string, error) {
+	p.Lock()
+	defer p.Unlock()
+	return p.records[name], nil
+}
+
+func (p *fakeProvider) AddCNAME(name, target string, ttl int) error {
+	p.Lock()
+	defer p.Unlock()
+	p.records[name] = target
+	return nil
+}
+
+func (p *fakeProvider) RemoveCNAME(name, target string) error {
+	p.Lock()
+	defer p.Unlock()
+	delete(p.records, name)
+	return nil
+}
+
+// Records returns a copy of the records in the provider, mapping names to
+// targets.
+func (p *fakeProvider) Records() map[string]string {
+	p.Lock()
+	defer p.Unlock()
+	result := make(map[string]string, len(p.records))
+	for k, v := range p.records {
+		result[k] = v
+	}
+	return result
+}
+
+// Reset removes all records from the provider.
+func (p *fakeProvider) Reset() {
+	p.Lock()
+	defer p.Unlock()
+	p.records = map[string]string{}
+}
@@ -0,0 +1,188 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dns
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	tsuruNet "github.com/tsuru/tsuru/net"
+)
+
+const (
+	defaultRoute53URL = "https://route53.amazonaws.com"
+	route53Namespace  = "https://route53.amazonaws.com/doc/2013-04-01/"
+	// Route 53 is a global service, its requests are always signed for
+	// us-east-1.
+	route53Region = "us-east-1"
+)
+
+func init() {
+	Register("route53", newRoute53Provider)
+}
+
+type route53Provider struct {
+	url    string
+	zoneID string
+	signer *v4.Signer
+}
+
+type route53RecordSet struct {
+	Name            string
+	Type            string
+	TTL             int
+	ResourceRecords []route53Record `xml:"ResourceRecords>ResourceRecord"`
+}
+
+type route53Record struct {
+	Value string
+}
+
+type route53Change struct {
+	Action            string
+	ResourceRecordSet route53RecordSet
+}
+
+type route53ChangeRequest struct {
+	XMLName xml.Name        `xml:"ChangeResourceRecordSetsRequest"`
+	XMLNS   string          `xml:"xmlns,attr"`
+	Changes []route53Change `xml:"ChangeBatch>Changes>Change"`
+}
+
+type route53ListResponse struct {
+	RecordSets []route53RecordSet `xml:"ResourceRecordSets>ResourceRecordSet"`
+}
+
+type route53ErrorResponse struct {
+	Code    string `xml:"Error>Code"`
+	Message string `xml:"Error>Message"`
+}
+
+func newRoute53Provider(prefix string) (Provider, error) {
+	zoneID, err := config.GetString(prefix + ":zone-id")
+	if err != nil {
+		return nil, err
+	}
+	apiURL, _ := config.GetString(prefix + ":api-url")
+	if apiURL == "" {
+		apiURL = defaultRoute53URL
+	}
+	var creds *credentials.Credentials
+	accessKey, _ := config.GetString(prefix + ":access-key-id")
+	secretKey, _ := config.GetString(prefix + ":secret-access-key")
+	if accessKey != "" {
+		creds = credentials.NewStaticCredentials(accessKey, secretKey, "")
+	} else {
+		creds = credentials.NewEnvCredentials()
+	}
+	return &route53Provider{
+		url:    strings.TrimSuffix(apiURL, "/"),
+		zoneID: strings.TrimPrefix(zoneID, "/hostedzone/"),
+		signer: v4.NewSigner(creds),
+	}, nil
+}
+
+func (p *route53Provider) findRecordSet(name string) (*route53RecordSet, error) {
+	query := url.Values{
+		"name":     []string{name},
+		"type":     []string{"CNAME"},
+		"maxitems": []string{"1"},
+	}
+	var result route53ListResponse
+	err := p.do("GET", "?"+query.Encode(), nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	for _, rs := range result.RecordSets {
+		if rs.Type == "CNAME" && normalize(rs.Name) == normalize(name) && len(rs.ResourceRecords) > 0 {
+			return &rs, nil
+		}
+	}
+	return nil, nil
+}
+
+func (p *route53Provider) GetCNAME(name string) (string, error) {
+	rs, err := p.findRecordSet(name)
+	if err != nil || rs == nil {
+		return "", err
+	}
+	return rs.ResourceRecords[0].Value, nil
+}
+
+func (p *route53Provider) AddCNAME(name, target string, ttl int) error {
+	return p.change("CREATE", route53RecordSet{
+		Name:            name,
+		Type:            "CNAME",
+		TTL:             ttl,
+		ResourceRecords: []route53Record{{Value: target}},
+	})
+}
+
+func (p *route53Provider) RemoveCNAME(name, target string) error {
+	// Route 53 only deletes record sets matching exactly the existing one,
+	// including its TTL.
+	rs, err := p.findRecordSet(name)
+	if err != nil || rs == nil {
+		return err
+	}
+	return p.change("DELETE", *rs)
+}
+
+func (p *route53Provider) change(action string, rs route53RecordSet) error {
+	body, err := xml.Marshal(route53ChangeRequest{
+		XMLNS:   route53Namespace,
+		Changes: []route53Change{{Action: action, ResourceRecordSet: rs}},
+	})
+	if err != nil {
+		return err
+	}
+	return p.do("POST", "", body, nil)
+}
+
+func (p *route53Provider) do(method, query string, body []byte, result interface{}) error {
+	reqURL := fmt.Sprintf("%s/2013-04-01/hostedzone/%s/rrset%s", p.url, p.zoneID, query)
+	req, err := http.NewRequest(method, reqURL, nil)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "text/xml")
+		req.ContentLength = int64(len(body))
+	}
+	_, err = p.signer.Sign(req, bytes.NewReader(body), "route53", route53Region, time.Now())
+	if err != nil {
+		return errors.Wrap(err, "unable to sign route53 request")
+	}
+	rsp, err := tsuruNet.Dial5Full60ClientNoKeepAlive.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	data, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return err
+	}
+	if rsp.StatusCode != http.StatusOK {
+		var errRsp route53ErrorResponse
+		if xml.Unmarshal(data, &errRsp) == nil && errRsp.Message != "" {
+			return errors.Errorf("route53 request failed (%d): %s: %s", rsp.StatusCode, errRsp.Code, errRsp.Message)
+		}
+		return errors.Errorf("route53 request failed (%d): %s", rsp.StatusCode, data)
+	}
+	if result == nil {
+		return nil
+	}
+	return xml.Unmarshal(data, result)
+}
@@ -0,0 +1,125 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dns
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+)
+
+type fakeRoute53 struct {
+	recordSets []route53RecordSet
+	requests   []route53ChangeRequest
+}
+
+func (f *fakeRoute53) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=mykey/") {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `<ErrorResponse><Error><Code>InvalidClientTokenId</Code><Message>invalid key</Message></Error></ErrorResponse>`)
+		return
+	}
+	if r.URL.Path != "/2013-04-01/hostedzone/Z123/rrset" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case "GET":
+		var result route53ListResponse
+		for _, rs := range f.recordSets {
+			if rs.Name >= r.URL.Query().Get("name") {
+				result.RecordSets = append(result.RecordSets, rs)
+				break
+			}
+		}
+		xml.NewEncoder(w).Encode(result)
+	case "POST":
+		var req route53ChangeRequest
+		xml.NewDecoder(r.Body).Decode(&req)
+		f.requests = append(f.requests, req)
+		for _, change := range req.Changes {
+			if change.Action == "CREATE" {
+				change.ResourceRecordSet.Name += "."
+				f.recordSets = append(f.recordSets, change.ResourceRecordSet)
+				continue
+			}
+			for i, rs := range f.recordSets {
+				if rs.Name == change.ResourceRecordSet.Name {
+					f.recordSets = append(f.recordSets[:i], f.recordSets[i+1:]...)
+					break
+				}
+			}
+		}
+		fmt.Fprint(w, `<ChangeResourceRecordSetsResponse/>`)
+	}
+}
+
+func (s *S) newRoute53(c *check.C, fake *fakeRoute53) (Provider, func()) {
+	srv := httptest.NewServer(fake)
+	config.Set("dns:route53:api-url", srv.URL)
+	config.Set("dns:route53:zone-id", "/hostedzone/Z123")
+	config.Set("dns:route53:access-key-id", "mykey")
+	config.Set("dns:route53:secret-access-key", "mysecret")
+	p, err := newRoute53Provider("dns:route53")
+	c.Assert(err, check.IsNil)
+	return p, srv.Close
+}
+
+func (s *S) TestRoute53Provider(c *check.C) {
+	fake := &fakeRoute53{}
+	p, stop := s.newRoute53(c, fake)
+	defer stop()
+	target, err := p.GetCNAME("www.example.com")
+	c.Assert(err, check.IsNil)
+	c.Assert(target, check.Equals, "")
+	err = p.AddCNAME("www.example.com", "myapp.tsuru.io", 300)
+	c.Assert(err, check.IsNil)
+	c.Assert(fake.requests, check.HasLen, 1)
+	c.Assert(fake.requests[0].XMLNS, check.Equals, route53Namespace)
+	c.Assert(fake.requests[0].Changes, check.DeepEquals, []route53Change{{
+		Action: "CREATE",
+		ResourceRecordSet: route53RecordSet{
+			Name:            "www.example.com",
+			Type:            "CNAME",
+			TTL:             300,
+			ResourceRecords: []route53Record{{Value: "myapp.tsuru.io"}},
+		},
+	}})
+	target, err = p.GetCNAME("www.example.com")
+	c.Assert(err, check.IsNil)
+	c.Assert(target, check.Equals, "myapp.tsuru.io")
+	err = p.RemoveCNAME("www.example.com", "myapp.tsuru.io")
+	c.Assert(err, check.IsNil)
+	c.Assert(fake.requests, check.HasLen, 2)
+	c.Assert(fake.requests[1].Changes[0].Action, check.Equals, "DELETE")
+	c.Assert(fake.requests[1].Changes[0].ResourceRecordSet.TTL, check.Equals, 300)
+	c.Assert(fake.recordSets, check.HasLen, 0)
+}
+
+func (s *S) TestRoute53ProviderIgnoresOtherRecords(c *check.C) {
+	fake := &fakeRoute53{recordSets: []route53RecordSet{
+		{Name: "zzz.example.com.", Type: "CNAME", TTL: 300, ResourceRecords: []route53Record{{Value: "other.tsuru.io"}}},
+	}}
+	p, stop := s.newRoute53(c, fake)
+	defer stop()
+	target, err := p.GetCNAME("www.example.com")
+	c.Assert(err, check.IsNil)
+	c.Assert(target, check.Equals, "")
+}
+
+func (s *S) TestRoute53ProviderError(c *check.C) {
+	_, stop := s.newRoute53(c, &fakeRoute53{})
+	defer stop()
+	config.Set("dns:route53:access-key-id", "wrong")
+	p, err := newRoute53Provider("dns:route53")
+	c.Assert(err, check.IsNil)
+	_, err = p.GetCNAME("www.example.com")
+	c.Assert(err, check.ErrorMatches, `route53 request failed \(403\): InvalidClientTokenId: invalid key`)
+}
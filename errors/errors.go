@@ -26,13 +26,70 @@ func (e *HTTP) Error() string {
 }
 
 // ValidationError is an error implementation used whenever a validation
-// failure occurs.
+// failure occurs. Failures of individual fields may be accumulated in Fields,
+// so all of them are reported at once.
 type ValidationError struct {
 	Message string
+	Fields  []FieldError `json:",omitempty"`
+}
+
+// FieldError is the validation failure of a single field.
+type FieldError struct {
+	Field   string
+	Message string
 }
 
 func (err *ValidationError) Error() string {
-	return err.Message
+	if len(err.Fields) == 0 {
+		return err.Message
+	}
+	lines := make([]string, 0, len(err.Fields)+1)
+	if err.Message != "" {
+		lines = append(lines, err.Message)
+	}
+	for _, f := range err.Fields {
+		lines = append(lines, f.Message)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Add records a validation failure of the given field.
+func (err *ValidationError) Add(field, message string) {
+	err.Fields = append(err.Fields, FieldError{Field: field, Message: message})
+}
+
+// Addf is like Add, with the message formatted according to a format
+// specifier.
+func (err *ValidationError) Addf(field, format string, args ...interface{}) {
+	err.Add(field, fmt.Sprintf(format, args...))
+}
+
+// Merge records the failures of a nested validation under the given field.
+// Errors that are not validation errors are returned unchanged, so the caller
+// may abort the validation.
+func (err *ValidationError) Merge(field string, nested error) error {
+	if nested == nil {
+		return nil
+	}
+	verr, ok := Cause(nested).(*ValidationError)
+	if !ok {
+		return nested
+	}
+	if verr.Message != "" || len(verr.Fields) == 0 {
+		err.Add(field, verr.Message)
+	}
+	for _, f := range verr.Fields {
+		err.Add(f.Field, f.Message)
+	}
+	return nil
+}
+
+// ToError returns err if any failure was recorded, nil otherwise.
+func (err *ValidationError) ToError() error {
+	if err.Message == "" && len(err.Fields) == 0 {
+		return nil
+	}
+	return err
 }
 
 type ConflictError ValidationError
@@ -118,3 +175,7 @@ func (err *CompositeError) Error() string {
 	}
 	return fmt.Sprintf("%s Caused by: %s", err.Message, err.Base.Error())
 }
+
+func (err *CompositeError) Cause() error {
+	return err.Base
+}
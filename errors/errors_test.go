@@ -5,8 +5,11 @@
 package errors
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
 	"testing"
 
 	pkgErrors "github.com/pkg/errors"
@@ -29,6 +32,37 @@ func (s *S) TestValidationError(c *check.C) {
 	c.Assert(e.Error(), check.Equals, "something")
 }
 
+func (s *S) TestValidationErrorFields(c *check.C) {
+	e := &ValidationError{}
+	c.Assert(e.ToError(), check.IsNil)
+	e.Add("name", "invalid name")
+	c.Assert(e.ToError(), check.Equals, e)
+	c.Assert(e.Error(), check.Equals, "invalid name")
+	e.Addf("team", "team %q not found", "myteam")
+	c.Assert(e.Error(), check.Equals, "invalid name\nteam \"myteam\" not found")
+	e.Message = "invalid manifest"
+	c.Assert(e.Error(), check.Equals, "invalid manifest\ninvalid name\nteam \"myteam\" not found")
+	c.Assert(e.Fields, check.DeepEquals, []FieldError{
+		{Field: "name", Message: "invalid name"},
+		{Field: "team", Message: "team \"myteam\" not found"},
+	})
+}
+
+func (s *S) TestValidationErrorMerge(c *check.C) {
+	e := &ValidationError{}
+	c.Assert(e.Merge("pool", nil), check.IsNil)
+	c.Assert(e.Merge("pool", pkgErrors.WithStack(&ValidationError{Message: "no access"})), check.IsNil)
+	nested := &ValidationError{}
+	nested.Add("router", "invalid router")
+	c.Assert(e.Merge("pool", nested), check.IsNil)
+	other := errors.New("db failure")
+	c.Assert(e.Merge("pool", other), check.Equals, other)
+	c.Assert(e.Fields, check.DeepEquals, []FieldError{
+		{Field: "pool", Message: "no access"},
+		{Field: "router", Message: "invalid router"},
+	})
+}
+
 func (s *S) TestMultiErrorFormat(c *check.C) {
 	cause := errors.New("root error")
 	e := NewMultiError(errors.New("error 1"), pkgErrors.WithStack(cause))
@@ -39,3 +73,124 @@ func (s *S) TestMultiErrorFormatSingle(c *check.C) {
 	e := NewMultiError(errors.New("error 1"))
 	c.Assert(fmt.Sprintf("%s", e), check.Equals, "error 1")
 }
+
+func (s *S) TestCompositeErrorCause(c *check.C) {
+	base := errors.New("base")
+	e := &CompositeError{Base: base, Message: "composite"}
+	c.Assert(Cause(e), check.Equals, base)
+	c.Assert(Cause(&CompositeError{Message: "composite"}), check.FitsTypeOf, &CompositeError{})
+}
+
+func (s *S) TestWrap(c *check.C) {
+	base := &NotFoundError{Message: "app not found"}
+	err := Wrap(base, "unable to deploy")
+	c.Assert(err.Error(), check.Equals, "unable to deploy: app not found")
+	c.Assert(Cause(err), check.Equals, base)
+	c.Assert(fmt.Sprintf("%+v", err), check.Matches, `(?s)app not found\nunable to deploy\n.*TestWrap.*`)
+	err = Wrapf(err, "app %s", "myapp")
+	c.Assert(err.Error(), check.Equals, "app myapp: unable to deploy: app not found")
+	c.Assert(Cause(err), check.Equals, base)
+	c.Assert(Wrap(nil, "msg"), check.IsNil)
+}
+
+func (s *S) TestWithStack(c *check.C) {
+	base := errors.New("root error")
+	err := WithStack(base)
+	c.Assert(err.Error(), check.Equals, "root error")
+	c.Assert(Cause(err), check.Equals, base)
+	c.Assert(fmt.Sprintf("%+v", err), check.Matches, `(?s)root error\n.*TestWithStack.*`)
+}
+
+type kindError struct {
+	notFound, forbidden, conflict bool
+}
+
+func (e kindError) Error() string   { return "kind error" }
+func (e kindError) NotFound() bool  { return e.notFound }
+func (e kindError) Forbidden() bool { return e.forbidden }
+func (e kindError) Conflict() bool  { return e.conflict }
+
+func (s *S) TestPredicates(c *check.C) {
+	tests := []struct {
+		err                                     error
+		notFound, forbidden, conflict, validate bool
+	}{
+		{err: errors.New("other")},
+		{err: nil},
+		{err: &NotFoundError{Message: "x"}, notFound: true},
+		{err: &HTTP{Code: http.StatusNotFound}, notFound: true},
+		{err: kindError{notFound: true}, notFound: true},
+		{err: &NotAuthorizedError{Message: "x"}, forbidden: true},
+		{err: &HTTP{Code: http.StatusForbidden}, forbidden: true},
+		{err: kindError{forbidden: true}, forbidden: true},
+		{err: &ConflictError{Message: "x"}, conflict: true},
+		{err: &HTTP{Code: http.StatusConflict}, conflict: true},
+		{err: kindError{conflict: true}, conflict: true},
+		{err: &ValidationError{Message: "x"}, validate: true},
+		{err: Wrap(Wrap(&NotFoundError{Message: "x"}, "a"), "b"), notFound: true},
+		{err: &CompositeError{Base: &ConflictError{Message: "x"}, Message: "y"}, conflict: true},
+		{err: Wrap(&HTTP{Code: http.StatusForbidden}, "a"), forbidden: true},
+	}
+	for i, tt := range tests {
+		c.Check(IsNotFound(tt.err), check.Equals, tt.notFound, check.Commentf("test %d", i))
+		c.Check(IsForbidden(tt.err), check.Equals, tt.forbidden, check.Commentf("test %d", i))
+		c.Check(IsConflict(tt.err), check.Equals, tt.conflict, check.Commentf("test %d", i))
+		c.Check(IsValidation(tt.err), check.Equals, tt.validate, check.Commentf("test %d", i))
+	}
+}
+
+func (s *S) TestStatusCode(c *check.C) {
+	c.Assert(StatusCode(errors.New("x")), check.Equals, http.StatusInternalServerError)
+	c.Assert(StatusCode(&ValidationError{Message: "x"}), check.Equals, http.StatusBadRequest)
+	c.Assert(StatusCode(Wrap(&NotFoundError{Message: "x"}, "a")), check.Equals, http.StatusNotFound)
+	c.Assert(StatusCode(&NotAuthorizedError{Message: "x"}), check.Equals, http.StatusForbidden)
+	c.Assert(StatusCode(&ConflictError{Message: "x"}), check.Equals, http.StatusConflict)
+	c.Assert(StatusCode(Wrap(&HTTP{Code: http.StatusTeapot}, "a")), check.Equals, http.StatusTeapot)
+	c.Assert(StatusCode(&HTTP{Code: http.StatusBadGateway, Message: "x"}), check.Equals, http.StatusBadGateway)
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func (s *S) TestIsRetryable(c *check.C) {
+	tests := []struct {
+		err       error
+		retryable bool
+	}{
+		{err: nil},
+		{err: errors.New("other"), retryable: true},
+		{err: timeoutError{}, retryable: true},
+		{err: &url.Error{Op: "Get", URL: "http://x", Err: timeoutError{}}, retryable: true},
+		{err: context.DeadlineExceeded, retryable: true},
+		{err: context.Canceled},
+		{err: &HTTP{Code: http.StatusInternalServerError}, retryable: true},
+		{err: &HTTP{Code: http.StatusTooManyRequests}, retryable: true},
+		{err: &HTTP{Code: http.StatusBadRequest}},
+		{err: &ValidationError{Message: "x"}},
+		{err: &NotFoundError{Message: "x"}},
+		{err: Wrap(&ConflictError{Message: "x"}, "a")},
+		{err: Permanent(timeoutError{})},
+		{err: Wrap(Permanent(errors.New("x")), "a")},
+		{err: Temporary(&ValidationError{Message: "x"}), retryable: true},
+		{err: ClassifyHTTPStatus(errors.New("x"), http.StatusBadGateway), retryable: true},
+		{err: ClassifyHTTPStatus(errors.New("x"), http.StatusRequestTimeout), retryable: true},
+		{err: ClassifyHTTPStatus(errors.New("x"), http.StatusUnprocessableEntity)},
+	}
+	for i, tt := range tests {
+		c.Check(IsRetryable(tt.err), check.Equals, tt.retryable, check.Commentf("test %d", i))
+	}
+}
+
+func (s *S) TestPermanentAndTemporaryKeepCause(c *check.C) {
+	c.Assert(Permanent(nil), check.IsNil)
+	c.Assert(Temporary(nil), check.IsNil)
+	base := &NotFoundError{Message: "app not found"}
+	err := Permanent(base)
+	c.Assert(err.Error(), check.Equals, "app not found")
+	c.Assert(Cause(err), check.Equals, base)
+	c.Assert(IsNotFound(Temporary(base)), check.Equals, true)
+	c.Assert(ClassifyHTTPStatus(base, http.StatusOK), check.Equals, base)
+}
@@ -0,0 +1,125 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errors
+
+import (
+	"context"
+	"net/http"
+)
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string   { return e.err.Error() }
+func (e *permanentError) Cause() error    { return e.err }
+func (e *permanentError) Permanent() bool { return true }
+
+type temporaryError struct {
+	err error
+}
+
+func (e *temporaryError) Error() string   { return e.err.Error() }
+func (e *temporaryError) Cause() error    { return e.err }
+func (e *temporaryError) Temporary() bool { return true }
+
+// Permanent marks err as permanent: retrying the operation that caused it
+// won't succeed. Permanent returns nil if err is nil.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Temporary marks err as temporary: the operation that caused it may succeed
+// if retried. Temporary returns nil if err is nil.
+func Temporary(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &temporaryError{err: err}
+}
+
+// ClassifyHTTPStatus marks err, caused by a response with the given status
+// code, as temporary for server errors, timeouts and rate limiting, or as
+// permanent for the remaining client errors. Errors caused by other status
+// codes are returned unchanged.
+func ClassifyHTTPStatus(err error, code int) error {
+	switch {
+	case retryableStatus(code):
+		return Temporary(err)
+	case code >= 400 && code < 500:
+		return Permanent(err)
+	}
+	return err
+}
+
+func retryableStatus(code int) bool {
+	return code >= 500 || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests
+}
+
+// IsRetryable reports whether the operation that caused err may succeed if
+// retried. The cause chain of err is inspected from the outermost error, the
+// first one matching a rule below decides:
+//
+//   - errors marked with Permanent, or implementing a Permanent() bool
+//     method returning true, are not retryable;
+//   - errors marked with Temporary, network timeouts and errors implementing
+//     a Temporary() bool method returning true are retryable;
+//   - HTTP errors are retryable for 5xx, 408 and 429 status codes only;
+//   - validation, not found, forbidden and conflict errors and canceled
+//     contexts are not retryable, exceeded context deadlines are.
+//
+// Errors not matching any rule are considered retryable.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	retryable := true
+	walk(err, func(e error) bool {
+		decided := true
+		switch t := e.(type) {
+		case interface {
+			Permanent() bool
+		}:
+			if decided = t.Permanent(); decided {
+				retryable = false
+			}
+		case *HTTP:
+			retryable = retryableStatus(t.Code)
+		case *ValidationError, *NotFoundError, *NotAuthorizedError, *ConflictError:
+			retryable = false
+		default:
+			decided = classifyTransient(e, &retryable)
+		}
+		return decided
+	})
+	return retryable
+}
+
+func classifyTransient(err error, retryable *bool) bool {
+	if t, ok := err.(interface {
+		Timeout() bool
+	}); ok && t.Timeout() {
+		*retryable = true
+		return true
+	}
+	if t, ok := err.(interface {
+		Temporary() bool
+	}); ok && t.Temporary() {
+		*retryable = true
+		return true
+	}
+	switch err {
+	case context.Canceled:
+		*retryable = false
+		return true
+	case context.DeadlineExceeded:
+		*retryable = true
+		return true
+	}
+	return false
+}
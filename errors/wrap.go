@@ -0,0 +1,182 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errors
+
+import (
+	"net/http"
+
+	pkgErrors "github.com/pkg/errors"
+)
+
+// NotFoundError is returned whenever a requested resource does not exist.
+type NotFoundError ValidationError
+
+func (err *NotFoundError) Error() string {
+	return err.Message
+}
+
+type causer interface {
+	Cause() error
+}
+
+// New returns an error with the given message, recording the stack trace at
+// the point it was called.
+func New(message string) error {
+	return pkgErrors.New(message)
+}
+
+// Errorf formats an error message according to a format specifier, recording
+// the stack trace at the point it was called.
+func Errorf(format string, args ...interface{}) error {
+	return pkgErrors.Errorf(format, args...)
+}
+
+// Wrap annotates err with message and the stack trace at the point Wrap is
+// called. The original error is still available through Cause and the
+// predicates in this package. Wrap returns nil if err is nil.
+func Wrap(err error, message string) error {
+	return pkgErrors.Wrap(err, message)
+}
+
+// Wrapf is like Wrap, with the message formatted according to a format
+// specifier.
+func Wrapf(err error, format string, args ...interface{}) error {
+	return pkgErrors.Wrapf(err, format, args...)
+}
+
+// WithStack annotates err with the stack trace at the point WithStack is
+// called, keeping its message. The stack trace is displayed when the error is
+// formatted with the %+v verb.
+func WithStack(err error) error {
+	return pkgErrors.WithStack(err)
+}
+
+// Cause returns the innermost error in the cause chain of err, which is
+// formed by errors implementing a Cause() error method.
+func Cause(err error) error {
+	for err != nil {
+		c, ok := err.(causer)
+		if !ok {
+			break
+		}
+		next := c.Cause()
+		if next == nil {
+			break
+		}
+		err = next
+	}
+	return err
+}
+
+// walk calls fn for each error in the cause chain of err, from the
+// outermost to the innermost, until fn returns true.
+func walk(err error, fn func(error) bool) bool {
+	for err != nil {
+		if fn(err) {
+			return true
+		}
+		c, ok := err.(causer)
+		if !ok {
+			return false
+		}
+		err = c.Cause()
+	}
+	return false
+}
+
+// IsNotFound reports whether any error in the cause chain of err is a
+// NotFoundError, an HTTP error with status 404 or an error implementing a
+// NotFound() bool method returning true.
+func IsNotFound(err error) bool {
+	return walk(err, func(e error) bool {
+		switch t := e.(type) {
+		case *NotFoundError:
+			return true
+		case *HTTP:
+			return t.Code == http.StatusNotFound
+		case interface {
+			NotFound() bool
+		}:
+			return t.NotFound()
+		}
+		return false
+	})
+}
+
+// IsForbidden reports whether any error in the cause chain of err is a
+// NotAuthorizedError, an HTTP error with status 403 or an error implementing
+// a Forbidden() bool method returning true.
+func IsForbidden(err error) bool {
+	return walk(err, func(e error) bool {
+		switch t := e.(type) {
+		case *NotAuthorizedError:
+			return true
+		case *HTTP:
+			return t.Code == http.StatusForbidden
+		case interface {
+			Forbidden() bool
+		}:
+			return t.Forbidden()
+		}
+		return false
+	})
+}
+
+// IsConflict reports whether any error in the cause chain of err is a
+// ConflictError, an HTTP error with status 409 or an error implementing a
+// Conflict() bool method returning true.
+func IsConflict(err error) bool {
+	return walk(err, func(e error) bool {
+		switch t := e.(type) {
+		case *ConflictError:
+			return true
+		case *HTTP:
+			return t.Code == http.StatusConflict
+		case interface {
+			Conflict() bool
+		}:
+			return t.Conflict()
+		}
+		return false
+	})
+}
+
+// IsValidation reports whether any error in the cause chain of err is a
+// ValidationError.
+func IsValidation(err error) bool {
+	return walk(err, func(e error) bool {
+		_, ok := e.(*ValidationError)
+		return ok
+	})
+}
+
+// StatusCode returns the HTTP status code matching err: the code of the
+// outermost HTTP error in its cause chain or, when there's none, the status
+// matching the kind of the error. http.StatusInternalServerError is returned
+// for errors of unknown kinds.
+func StatusCode(err error) int {
+	code := 0
+	walk(err, func(e error) bool {
+		if httpErr, ok := e.(*HTTP); ok {
+			code = httpErr.Code
+			return true
+		}
+		return false
+	})
+	switch {
+	case code != 0:
+	case IsValidation(err):
+		code = http.StatusBadRequest
+	case IsNotFound(err):
+		code = http.StatusNotFound
+	case IsForbidden(err):
+		code = http.StatusForbidden
+	case IsConflict(err):
+		code = http.StatusConflict
+	default:
+		code = http.StatusInternalServerError
+	}
+	return code
+}
@@ -62,6 +62,8 @@ var (
 		once:     &sync.Once{},
 	}
 	throttlingInfo  = map[string]ThrottlingSpec{}
+	throttlingMu    sync.RWMutex
+	configThrottled = map[string]struct{}{}
 	errInvalidQuery = errors.New("invalid query")
 
 	ErrNotCancelable          = errors.New("event is not cancelable")
@@ -103,6 +105,10 @@ var (
 	TargetTypeEventBlock      = TargetType("event-block")
 	TargetTypeCluster         = TargetType("cluster")
 	TargetTypeVolume          = TargetType("volume")
+	TargetTypeQueueMessage    = TargetType("queue-message")
+	TargetTypeFeatureFlag     = TargetType("feature-flag")
+	TargetTypeAppTemplate     = TargetType("app-template")
+	TargetTypeProject         = TargetType("project")
 )
 
 const (
@@ -305,15 +311,29 @@ func LoadThrottling() error {
 		}
 		return err
 	}
+	throttlingMu.Lock()
+	defer throttlingMu.Unlock()
+	// Entries loaded from a previous configuration are dropped so that a
+	// reload removes limits that are no longer configured, while the ones
+	// registered in code through SetThrottling are kept.
+	for key := range configThrottled {
+		delete(throttlingInfo, key)
+	}
+	configThrottled = map[string]struct{}{}
 	for _, spec := range specs {
-		SetThrottling(spec)
+		key := throttlingKey(spec.TargetType, spec.KindName, spec.AllTargets)
+		throttlingInfo[key] = spec
+		configThrottled[key] = struct{}{}
 	}
 	return nil
 }
 
 func SetThrottling(spec ThrottlingSpec) {
 	key := throttlingKey(spec.TargetType, spec.KindName, spec.AllTargets)
+	throttlingMu.Lock()
+	defer throttlingMu.Unlock()
 	throttlingInfo[key] = spec
+	delete(configThrottled, key)
 }
 
 func getThrottling(t *Target, k *Kind, allTargets bool) *ThrottlingSpec {
@@ -321,6 +341,8 @@ func getThrottling(t *Target, k *Kind, allTargets bool) *ThrottlingSpec {
 		throttlingKey(t.Type, k.Name, allTargets),
 		throttlingKey(t.Type, "", allTargets),
 	}
+	throttlingMu.RLock()
+	defer throttlingMu.RUnlock()
 	for _, key := range keys {
 		if s, ok := throttlingInfo[key]; ok {
 			return &s
@@ -923,6 +945,20 @@ func (e *Event) SetOtherCustomData(data interface{}) error {
 	})
 }
 
+// SetOtherCustomDataKey sets a single key in the other custom data of the
+// event, keeping the keys already set.
+func (e *Event) SetOtherCustomDataKey(key string, data interface{}) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	coll := conn.Events()
+	return coll.UpdateId(e.ID, bson.M{
+		"$set": bson.M{"othercustomdata." + key: data},
+	})
+}
+
 func (e *Event) Logf(format string, params ...interface{}) {
 	log.Debugf(fmt.Sprintf("%s(%s)[%s] %s", e.Target.Type, e.Target.Value, e.Kind, format), params...)
 	format += "\n"
@@ -1024,6 +1060,9 @@ func (e *Event) done(evtErr error, customData interface{}, abort bool) (err erro
 		if err != nil {
 			log.Errorf("[events] error marking event as done - %#v: %s", e, err)
 		}
+		if !abort {
+			runDoneHooks(e)
+		}
 	}()
 	updater.removeCh <- &e.Target
 	conn, err := db.Conn()
@@ -45,6 +45,7 @@ func setBaseConfig() {
 func (s *S) SetUpTest(c *check.C) {
 	setBaseConfig()
 	throttlingInfo = map[string]ThrottlingSpec{}
+	configThrottled = map[string]struct{}{}
 	conn, err := db.Conn()
 	c.Assert(err, check.IsNil)
 	defer conn.Close()
@@ -930,6 +931,27 @@ func (s *S) TestEventOtherCustomData(c *check.C) {
 	c.Assert(data, check.DeepEquals, map[string]string{"z": "h"})
 }
 
+func (s *S) TestEventOtherCustomDataKey(c *check.C) {
+	evt, err := New(&Opts{
+		Target:  Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppUpdateEnvSet,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.SetOtherCustomDataKey("z", "h")
+	c.Assert(err, check.IsNil)
+	err = evt.SetOtherCustomDataKey("x", "y")
+	c.Assert(err, check.IsNil)
+	evts, err := All()
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	var data map[string]string
+	err = evts[0].OtherData(&data)
+	c.Assert(err, check.IsNil)
+	c.Assert(data, check.DeepEquals, map[string]string{"z": "h", "x": "y"})
+}
+
 func (s *S) TestEventAsWriter(c *check.C) {
 	evt, err := New(&Opts{
 		Target:     Target{Type: "app", Value: "myapp"},
@@ -1188,3 +1210,47 @@ event:
 	c.Assert(err, check.ErrorMatches, `json: cannot unmarshal string into Go struct field throttlingSpecAlias.limit of type int`)
 	c.Assert(throttlingInfo, check.DeepEquals, map[string]ThrottlingSpec{})
 }
+
+func (s *S) TestLoadThrottlingReload(c *check.C) {
+	defer config.Unset("event:throttling")
+	SetThrottling(ThrottlingSpec{TargetType: TargetTypeNode, KindName: "healer", Max: 1, Time: time.Minute})
+	err := config.ReadConfigBytes([]byte(`
+event:
+  throttling:
+  - target-type: app
+    kind-name: app.update.env.set
+    limit: 1
+    window: 300
+`))
+	c.Assert(err, check.IsNil)
+	setBaseConfig()
+	err = LoadThrottling()
+	c.Assert(err, check.IsNil)
+	c.Assert(throttlingInfo, check.HasLen, 2)
+	err = config.ReadConfigBytes([]byte(`
+event:
+  throttling:
+  - target-type: container
+    kind-name: healer
+    limit: 5
+    window: 60
+`))
+	c.Assert(err, check.IsNil)
+	setBaseConfig()
+	err = LoadThrottling()
+	c.Assert(err, check.IsNil)
+	c.Assert(throttlingInfo, check.DeepEquals, map[string]ThrottlingSpec{
+		"node_healer": {
+			TargetType: TargetTypeNode,
+			KindName:   "healer",
+			Time:       time.Minute,
+			Max:        1,
+		},
+		"container_healer": {
+			TargetType: TargetTypeContainer,
+			KindName:   "healer",
+			Time:       time.Minute,
+			Max:        5,
+		},
+	})
+}
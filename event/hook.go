@@ -0,0 +1,30 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import "sync"
+
+var doneHooks struct {
+	sync.RWMutex
+	fns []func(*Event)
+}
+
+// OnDone registers fn to be called whenever an event started by this tsuru
+// API instance finishes, successfully or not. Aborted events are not
+// notified. Hooks run in the goroutine finishing the event, so they must be
+// fast and must not change the event.
+func OnDone(fn func(*Event)) {
+	doneHooks.Lock()
+	defer doneHooks.Unlock()
+	doneHooks.fns = append(doneHooks.fns, fn)
+}
+
+func runDoneHooks(e *Event) {
+	doneHooks.RLock()
+	defer doneHooks.RUnlock()
+	for _, fn := range doneHooks.fns {
+		fn(e)
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"errors"
+
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestOnDone(c *check.C) {
+	oldHooks := doneHooks.fns
+	defer func() { doneHooks.fns = oldHooks }()
+	var done []Target
+	OnDone(func(evt *Event) {
+		c.Assert(evt.Running, check.Equals, false)
+		done = append(done, evt.Target)
+	})
+	evt, err := New(&Opts{
+		Target:  Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppUpdateEnvSet,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(errors.New("myerr"))
+	c.Assert(err, check.IsNil)
+	evt, err = New(&Opts{
+		Target:  Target{Type: "app", Value: "otherapp"},
+		Kind:    permission.PermAppUpdateEnvSet,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Abort()
+	c.Assert(err, check.IsNil)
+	c.Assert(done, check.DeepEquals, []Target{{Type: "app", Value: "myapp"}})
+}
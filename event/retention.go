@@ -0,0 +1,239 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api/shutdown"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/storage"
+	"github.com/tsuru/tsuru/log"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const eventPruneRunID = "event-prune"
+
+// RetentionPolicy describes for how long finished events are kept, by kind
+// name. Zero means events are kept forever.
+type RetentionPolicy struct {
+	MaxAge time.Duration
+	Kinds  map[string]time.Duration
+}
+
+// PruneResult reports the events removed by Prune, by kind name, the running
+// events finished because their lock expired and the approximate number of
+// bytes reclaimed in the events collection.
+type PruneResult struct {
+	Removed   map[string]int `json:"removed"`
+	Expired   int            `json:"expired"`
+	Reclaimed int64          `json:"reclaimed"`
+}
+
+// GetRetentionPolicy returns the policy set in the event:retention config.
+// Deploy records are app.deploy events and may be given their own max age in
+// event:retention:kinds:app.deploy:max-age.
+func GetRetentionPolicy() RetentionPolicy {
+	policy := RetentionPolicy{Kinds: map[string]time.Duration{}}
+	policy.MaxAge, _ = config.GetDuration("event:retention:max-age")
+	kinds, _ := config.Get("event:retention:kinds")
+	entries, _ := kinds.(map[interface{}]interface{})
+	for name := range entries {
+		kindName, _ := name.(string)
+		maxAge, err := config.GetDuration("event:retention:kinds:" + kindName + ":max-age")
+		if err == nil {
+			policy.Kinds[kindName] = maxAge
+		}
+	}
+	return policy
+}
+
+// maxAge returns for how long finished events of the given kind are kept.
+func (p *RetentionPolicy) maxAge(kindName string) time.Duration {
+	if maxAge, ok := p.Kinds[kindName]; ok {
+		return maxAge
+	}
+	return p.MaxAge
+}
+
+// Prune removes the finished events older than the max age of their kind and
+// finishes, with an error, the running events whose lock hasn't been updated
+// in a while, like the ones left behind by a crashed API instance.
+func Prune(now time.Time) (*PruneResult, error) {
+	policy := GetRetentionPolicy()
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	coll := conn.Events()
+	sizeBefore, err := collectionSize(coll)
+	if err != nil {
+		return nil, err
+	}
+	result := &PruneResult{Removed: map[string]int{}}
+	result.Expired, err = finishExpired(coll, now)
+	if err != nil {
+		return nil, err
+	}
+	var kindNames []string
+	err = coll.Find(bson.M{"running": false}).Distinct("kind.name", &kindNames)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(kindNames)
+	for _, kindName := range kindNames {
+		maxAge := policy.maxAge(kindName)
+		if maxAge <= 0 {
+			continue
+		}
+		info, err := coll.RemoveAll(bson.M{
+			"kind.name": kindName,
+			"running":   false,
+			"starttime": bson.M{"$lt": now.Add(-maxAge)},
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to remove events of kind %q", kindName)
+		}
+		if info.Removed > 0 {
+			result.Removed[kindName] = info.Removed
+		}
+	}
+	sizeAfter, err := collectionSize(coll)
+	if err != nil {
+		return nil, err
+	}
+	if sizeBefore > sizeAfter {
+		result.Reclaimed = sizeBefore - sizeAfter
+	}
+	return result, nil
+}
+
+func finishExpired(coll *storage.Collection, now time.Time) (int, error) {
+	var allData []eventData
+	err := coll.Find(bson.M{
+		"running":        true,
+		"lockupdatetime": bson.M{"$lt": now.Add(-lockExpireTimeout)},
+	}).All(&allData)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	for i := range allData {
+		evt := Event{eventData: allData[i]}
+		lastUpdate := evt.LockUpdateTime.UTC()
+		err = evt.Done(errors.Errorf("event expired, no update for %v", now.Sub(lastUpdate)))
+		if err != nil {
+			log.Errorf("[event-prune] error finishing expired event %q: %s", evt.UniqueID.Hex(), err)
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+func collectionSize(coll *storage.Collection) (int64, error) {
+	var stats struct {
+		Size int64 `bson:"size"`
+	}
+	err := coll.Database.Run(bson.D{{Name: "collStats", Value: coll.Name}}, &stats)
+	return stats.Size, err
+}
+
+// InitializeRetention starts the task pruning old events, when
+// event:retention is configured.
+func InitializeRetention() error {
+	if _, err := config.Get("event:retention"); err != nil {
+		return nil
+	}
+	interval, _ := config.GetDuration("event:retention:interval")
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	r := &retention{
+		interval: interval,
+		shutdown: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go r.loop()
+	shutdown.Register(r)
+	return nil
+}
+
+type retention struct {
+	interval time.Duration
+	shutdown chan struct{}
+	done     chan struct{}
+}
+
+func (r *retention) loop() {
+	defer close(r.done)
+	for {
+		now := time.Now().UTC()
+		claimed, err := claimPruneRun(now, r.interval)
+		if err != nil {
+			log.Errorf("[event-prune] error claiming run: %s", err)
+		}
+		if claimed {
+			result, err := Prune(now)
+			if err != nil {
+				log.Errorf("[event-prune] error pruning events: %s", err)
+			} else {
+				log.Debugf("[event-prune] removed events: %v, expired: %d, reclaimed bytes: %d", result.Removed, result.Expired, result.Reclaimed)
+			}
+		}
+		select {
+		case <-time.After(r.interval):
+		case <-r.shutdown:
+			return
+		}
+	}
+}
+
+func (r *retention) Shutdown(ctx context.Context) error {
+	close(r.shutdown)
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
+
+func (r *retention) String() string {
+	return "event retention"
+}
+
+// claimPruneRun atomically sets the time of the next run, so only one of the
+// tsuru API instances prunes events in each interval.
+func claimPruneRun(now time.Time, interval time.Duration) (bool, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	coll := conn.EventPruneRuns()
+	next := now.Add(interval)
+	err = coll.Update(
+		bson.M{"_id": eventPruneRunID, "next": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"next": next}},
+	)
+	if err == nil {
+		return true, nil
+	}
+	if err != mgo.ErrNotFound {
+		return false, err
+	}
+	err = coll.Insert(bson.M{"_id": eventPruneRunID, "next": next})
+	if mgo.IsDup(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
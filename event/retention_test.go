@@ -0,0 +1,123 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) newDoneEvent(c *check.C, kind *permission.PermissionScheme, target string, startTime time.Time) *Event {
+	evt, err := New(&Opts{
+		Target:  Target{Type: "app", Value: target},
+		Kind:    kind,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	err = conn.Events().Update(bson.M{"uniqueid": evt.UniqueID}, bson.M{"$set": bson.M{"starttime": startTime}})
+	c.Assert(err, check.IsNil)
+	return evt
+}
+
+func (s *S) TestGetRetentionPolicy(c *check.C) {
+	policy := GetRetentionPolicy()
+	c.Assert(policy.MaxAge, check.Equals, time.Duration(0))
+	c.Assert(policy.maxAge("app.deploy"), check.Equals, time.Duration(0))
+	config.Set("event:retention:max-age", "720h")
+	config.Set("event:retention:kinds:app.deploy:max-age", "2160h")
+	defer config.Unset("event:retention")
+	policy = GetRetentionPolicy()
+	c.Assert(policy, check.DeepEquals, RetentionPolicy{
+		MaxAge: 720 * time.Hour,
+		Kinds:  map[string]time.Duration{"app.deploy": 2160 * time.Hour},
+	})
+	c.Assert(policy.maxAge("app.deploy"), check.Equals, 2160*time.Hour)
+	c.Assert(policy.maxAge("app.update.env.set"), check.Equals, 720*time.Hour)
+}
+
+func (s *S) TestPrune(c *check.C) {
+	config.Set("event:retention:max-age", "24h")
+	config.Set("event:retention:kinds:app.deploy:max-age", "72h")
+	defer config.Unset("event:retention")
+	now := time.Now().UTC()
+	s.newDoneEvent(c, permission.PermAppUpdateEnvSet, "app1", now.Add(-48*time.Hour))
+	recent := s.newDoneEvent(c, permission.PermAppUpdateEnvSet, "app2", now.Add(-time.Hour))
+	deploy := s.newDoneEvent(c, permission.PermAppDeploy, "app3", now.Add(-48*time.Hour))
+	s.newDoneEvent(c, permission.PermAppDeploy, "app4", now.Add(-96*time.Hour))
+	result, err := Prune(now)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Removed, check.DeepEquals, map[string]int{
+		"app.update.env.set": 1,
+		"app.deploy":         1,
+	})
+	c.Assert(result.Expired, check.Equals, 0)
+	evts, err := All()
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 2)
+	c.Assert(evts[0].UniqueID, check.Equals, recent.UniqueID)
+	c.Assert(evts[1].UniqueID, check.Equals, deploy.UniqueID)
+}
+
+func (s *S) TestPruneKeepsEventsWithoutMaxAge(c *check.C) {
+	config.Set("event:retention:kinds:app.deploy:max-age", "72h")
+	defer config.Unset("event:retention")
+	now := time.Now().UTC()
+	s.newDoneEvent(c, permission.PermAppUpdateEnvSet, "app1", now.Add(-480*time.Hour))
+	result, err := Prune(now)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Removed, check.DeepEquals, map[string]int{})
+	evts, err := All()
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+}
+
+func (s *S) TestPruneFinishesExpiredEvents(c *check.C) {
+	evt, err := New(&Opts{
+		Target:  Target{Type: "app", Value: "myapp"},
+		Kind:    permission.PermAppUpdateEnvSet,
+		Owner:   s.token,
+		Allowed: Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	updater.stop()
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	now := time.Now().UTC()
+	err = conn.Events().Update(bson.M{"uniqueid": evt.UniqueID}, bson.M{"$set": bson.M{"lockupdatetime": now.Add(-time.Hour)}})
+	c.Assert(err, check.IsNil)
+	result, err := Prune(now)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Expired, check.Equals, 1)
+	evts, err := All()
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].Running, check.Equals, false)
+	c.Assert(evts[0].Error, check.Matches, `event expired, no update for [\d.]+\w+`)
+}
+
+func (s *S) TestClaimPruneRun(c *check.C) {
+	now := time.Now().UTC()
+	claimed, err := claimPruneRun(now, time.Hour)
+	c.Assert(err, check.IsNil)
+	c.Assert(claimed, check.Equals, true)
+	claimed, err = claimPruneRun(now.Add(time.Minute), time.Hour)
+	c.Assert(err, check.IsNil)
+	c.Assert(claimed, check.Equals, false)
+	claimed, err = claimPruneRun(now.Add(time.Hour), time.Hour)
+	c.Assert(err, check.IsNil)
+	c.Assert(claimed, check.Equals, true)
+}
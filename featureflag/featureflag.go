@@ -0,0 +1,200 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package featureflag provides flags used to gate new behaviors of tsuru,
+// allowing them to be gradually rolled out to teams. Flags are stored in the
+// database and may be changed without restarting the API.
+package featureflag
+
+import (
+	"hash/fnv"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/db"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/log"
+	"gopkg.in/mgo.v2"
+)
+
+var (
+	ErrFlagNotFound = errors.New("feature flag not found")
+
+	nameRegexp = regexp.MustCompile(`^[a-z][a-z0-9._-]*$`)
+)
+
+// cacheTTL is how long flags read by IsEnabled are trusted before being read
+// again from the database.
+var cacheTTL = 5 * time.Second
+
+// Flag gates a behavior. The behavior is enabled for everyone when Enabled
+// is true, otherwise it's enabled for the teams listed in Teams and for a
+// stable share of the remaining teams defined by Percentage.
+type Flag struct {
+	Name        string `bson:"_id"`
+	Description string
+	Enabled     bool
+	Teams       []string
+	Percentage  int
+}
+
+func (f *Flag) validate() error {
+	var verr tsuruErrors.ValidationError
+	if !nameRegexp.MatchString(f.Name) {
+		verr.Add("name", "invalid feature flag name, it must start with a letter and contain only lowercase letters, numbers, dots, dashes and underscores")
+	}
+	if f.Percentage < 0 || f.Percentage > 100 {
+		verr.Add("percentage", "percentage must be between 0 and 100")
+	}
+	return verr.ToError()
+}
+
+// EnabledFor returns whether the flag is enabled for the given team.
+func (f *Flag) EnabledFor(team string) bool {
+	if f.Enabled {
+		return true
+	}
+	if team == "" {
+		return false
+	}
+	for _, t := range f.Teams {
+		if t == team {
+			return true
+		}
+	}
+	if f.Percentage <= 0 {
+		return false
+	}
+	h := fnv.New32a()
+	h.Write([]byte(f.Name + "/" + team))
+	return int(h.Sum32()%100) < f.Percentage
+}
+
+// Save creates or updates a flag.
+func Save(f Flag) error {
+	err := f.validate()
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.FeatureFlags().UpsertId(f.Name, f)
+	if err != nil {
+		return err
+	}
+	invalidateCache()
+	return nil
+}
+
+// Remove removes a flag, disabling the behavior it gates.
+func Remove(name string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.FeatureFlags().RemoveId(name)
+	if err == mgo.ErrNotFound {
+		return ErrFlagNotFound
+	}
+	if err != nil {
+		return err
+	}
+	invalidateCache()
+	return nil
+}
+
+// Get returns the flag with the given name.
+func Get(name string) (*Flag, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var f Flag
+	err = conn.FeatureFlags().FindId(name).One(&f)
+	if err == mgo.ErrNotFound {
+		return nil, ErrFlagNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// List returns all flags sorted by name.
+func List() ([]Flag, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var flags []Flag
+	err = conn.FeatureFlags().Find(nil).Sort("_id").All(&flags)
+	if err != nil {
+		return nil, err
+	}
+	return flags, nil
+}
+
+var cache struct {
+	sync.Mutex
+	flags      map[string]Flag
+	updatedAt  time.Time
+	refreshing bool
+	version    int
+}
+
+// IsEnabled returns whether the behavior gated by the named flag is enabled
+// for the given team. Unknown flags are disabled. Flags are read from the
+// database at most once every few seconds, by a single caller at a time,
+// while the others get the last known flags.
+func IsEnabled(name, team string) bool {
+	flags := cachedFlags()
+	f, ok := flags[name]
+	return ok && f.EnabledFor(team)
+}
+
+func cachedFlags() map[string]Flag {
+	cache.Lock()
+	if cache.refreshing || time.Since(cache.updatedAt) < cacheTTL {
+		flags := cache.flags
+		cache.Unlock()
+		return flags
+	}
+	cache.refreshing = true
+	version := cache.version
+	cache.Unlock()
+	flags, err := List()
+	cache.Lock()
+	defer cache.Unlock()
+	cache.refreshing = false
+	if err != nil {
+		log.Errorf("[feature-flag] unable to read feature flags, keeping last known flags: %s", err)
+		cache.updatedAt = time.Now()
+		return cache.flags
+	}
+	flagMap := make(map[string]Flag, len(flags))
+	for _, f := range flags {
+		flagMap[f.Name] = f
+	}
+	if cache.version == version {
+		cache.flags = flagMap
+		cache.updatedAt = time.Now()
+	}
+	return flagMap
+}
+
+func invalidateCache() {
+	cache.Lock()
+	defer cache.Unlock()
+	cache.flags = nil
+	cache.updatedAt = time.Time{}
+	cache.version++
+}
@@ -0,0 +1,126 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package featureflag
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/dbtest"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type S struct{}
+
+var _ = check.Suite(&S{})
+
+func (s *S) SetUpTest(c *check.C) {
+	config.Set("database:url", "127.0.0.1:27017")
+	config.Set("database:name", "tsuru_featureflag_tests")
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	err = dbtest.ClearAllCollections(conn.FeatureFlags().Database)
+	c.Assert(err, check.IsNil)
+	invalidateCache()
+}
+
+func (s *S) TestSaveAndGet(c *check.C) {
+	err := Save(Flag{Name: "new-bind-flow", Teams: []string{"team1"}, Percentage: 10})
+	c.Assert(err, check.IsNil)
+	f, err := Get("new-bind-flow")
+	c.Assert(err, check.IsNil)
+	c.Assert(*f, check.DeepEquals, Flag{Name: "new-bind-flow", Teams: []string{"team1"}, Percentage: 10})
+	err = Save(Flag{Name: "new-bind-flow", Enabled: true})
+	c.Assert(err, check.IsNil)
+	f, err = Get("new-bind-flow")
+	c.Assert(err, check.IsNil)
+	c.Assert(*f, check.DeepEquals, Flag{Name: "new-bind-flow", Enabled: true})
+}
+
+func (s *S) TestSaveInvalid(c *check.C) {
+	err := Save(Flag{Name: "Invalid Name", Percentage: 101})
+	c.Assert(err, check.NotNil)
+	verr, ok := err.(*tsuruErrors.ValidationError)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(verr.Fields, check.HasLen, 2)
+	c.Assert(verr.Fields[0].Field, check.Equals, "name")
+	c.Assert(verr.Fields[1].Field, check.Equals, "percentage")
+}
+
+func (s *S) TestGetNotFound(c *check.C) {
+	_, err := Get("unknown")
+	c.Assert(err, check.Equals, ErrFlagNotFound)
+}
+
+func (s *S) TestRemove(c *check.C) {
+	err := Save(Flag{Name: "new-bind-flow", Enabled: true})
+	c.Assert(err, check.IsNil)
+	c.Assert(IsEnabled("new-bind-flow", "team1"), check.Equals, true)
+	err = Remove("new-bind-flow")
+	c.Assert(err, check.IsNil)
+	c.Assert(IsEnabled("new-bind-flow", "team1"), check.Equals, false)
+	err = Remove("new-bind-flow")
+	c.Assert(err, check.Equals, ErrFlagNotFound)
+}
+
+func (s *S) TestList(c *check.C) {
+	err := Save(Flag{Name: "b-flag"})
+	c.Assert(err, check.IsNil)
+	err = Save(Flag{Name: "a-flag"})
+	c.Assert(err, check.IsNil)
+	flags, err := List()
+	c.Assert(err, check.IsNil)
+	c.Assert(flags, check.DeepEquals, []Flag{{Name: "a-flag"}, {Name: "b-flag"}})
+}
+
+func (s *S) TestIsEnabledIsCached(c *check.C) {
+	err := Save(Flag{Name: "new-scheduler", Enabled: true})
+	c.Assert(err, check.IsNil)
+	c.Assert(IsEnabled("new-scheduler", ""), check.Equals, true)
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	err = conn.FeatureFlags().RemoveId("new-scheduler")
+	c.Assert(err, check.IsNil)
+	c.Assert(IsEnabled("new-scheduler", ""), check.Equals, true)
+	defer func(ttl time.Duration) { cacheTTL = ttl }(cacheTTL)
+	cacheTTL = 0
+	c.Assert(IsEnabled("new-scheduler", ""), check.Equals, false)
+}
+
+func (s *S) TestFlagEnabledFor(c *check.C) {
+	f := Flag{Name: "new-bind-flow", Teams: []string{"team1"}}
+	c.Assert(f.EnabledFor("team1"), check.Equals, true)
+	c.Assert(f.EnabledFor("team2"), check.Equals, false)
+	c.Assert(f.EnabledFor(""), check.Equals, false)
+	f.Enabled = true
+	c.Assert(f.EnabledFor("team2"), check.Equals, true)
+	c.Assert(f.EnabledFor(""), check.Equals, true)
+}
+
+func (s *S) TestFlagEnabledForPercentage(c *check.C) {
+	f := Flag{Name: "new-bind-flow", Percentage: 30}
+	enabled := 0
+	for i := 0; i < 1000; i++ {
+		team := fmt.Sprintf("team%d", i)
+		result := f.EnabledFor(team)
+		c.Assert(f.EnabledFor(team), check.Equals, result)
+		if result {
+			enabled++
+		}
+	}
+	c.Assert(enabled > 200 && enabled < 400, check.Equals, true, check.Commentf("enabled for %d teams", enabled))
+	f.Percentage = 100
+	c.Assert(f.EnabledFor("team1"), check.Equals, true)
+	f.Percentage = 0
+	c.Assert(f.EnabledFor("team1"), check.Equals, false)
+}
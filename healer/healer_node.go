@@ -17,6 +17,7 @@ import (
 	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/iaas"
 	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/maintenance"
 	"github.com/tsuru/tsuru/net"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/provision"
@@ -184,6 +185,14 @@ func (h *NodeHealer) tryHealingNode(node provision.Node, reason string, lastChec
 		return nil
 	}
 	poolName := node.Pool()
+	window, err := maintenance.ActiveWindow(maintenance.ActionHealing, "", poolName)
+	if err != nil {
+		return errors.Wrap(err, "unable to check maintenance windows, healing aborted")
+	}
+	if window != nil {
+		log.Debugf("node %q healing (%s) deferred by maintenance window %q.", node.Address(), reason, window.Name)
+		return nil
+	}
 	evt, err := event.NewInternal(&event.Opts{
 		Target:       event.Target{Type: event.TargetTypeNode, Value: node.Address()},
 		InternalKind: "healer",
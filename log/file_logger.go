@@ -14,6 +14,7 @@ import (
 var (
 	errorPrefix = "ERROR: %s"
 	fatalPrefix = "FATAL: %s"
+	infoPrefix  = "INFO: %s"
 	debugPrefix = "DEBUG: %s"
 )
 
@@ -52,6 +53,14 @@ func (l *fileLogger) Fatalf(format string, o ...interface{}) {
 	l.Fatal(fmt.Sprintf(format, o...))
 }
 
+func (l *fileLogger) Info(o string) {
+	l.logger.Printf(infoPrefix, o)
+}
+
+func (l *fileLogger) Infof(format string, o ...interface{}) {
+	l.Info(fmt.Sprintf(format, o...))
+}
+
 func (l *fileLogger) Debug(o string) {
 	if l.debug {
 		l.logger.Printf(debugPrefix, o)
@@ -55,6 +55,11 @@ func (s *FileLoggerSuite) TestErrorfShouldFormatErrorAndPrefixMessage(c *check.C
 	c.Assert(s.b.String(), check.Matches, `.* ERROR: this is the error: "something bad happened"\n$`)
 }
 
+func (s *FileLoggerSuite) TestInfoShouldPrefixMessage(c *check.C) {
+	s.l.Infof("doing %s here", "stuff")
+	c.Assert(s.b.String(), check.Matches, ".* INFO: doing stuff here\n$")
+}
+
 func (s *FileLoggerSuite) TestDebugShouldPrefixMessage(c *check.C) {
 	s.l.Debug("doing some stuff here")
 	c.Assert(s.b.String(), check.Matches, ".* DEBUG: doing some stuff here\n$")
@@ -0,0 +1,133 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Entry is a single log message, as written by loggers with structured
+// output.
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Level     Level     `json:"level"`
+	Subsystem string    `json:"subsystem,omitempty"`
+	Message   string    `json:"message"`
+}
+
+// entryWriter is implemented by loggers that keep the level and the
+// subsystem of messages as separate fields instead of adding them to the
+// message text.
+type entryWriter interface {
+	WriteEntry(Entry)
+}
+
+func writeEntry(l Logger, e Entry) {
+	if w, ok := l.(entryWriter); ok {
+		w.WriteEntry(e)
+		return
+	}
+	msg := e.Message
+	if e.Subsystem != "" {
+		msg = fmt.Sprintf("[%s] %s", e.Subsystem, msg)
+	}
+	switch e.Level {
+	case DebugLevel:
+		l.Debug(msg)
+	case InfoLevel:
+		l.Info(msg)
+	default:
+		l.Error(msg)
+	}
+}
+
+var _ Logger = &jsonLogger{}
+
+// NewJSONLogger returns a logger that writes each message as a JSON object in
+// a line of the given writer.
+func NewJSONLogger(writer io.Writer, debug bool) Logger {
+	return &jsonLogger{w: writer, debug: debug}
+}
+
+type jsonLogger struct {
+	mu    sync.Mutex
+	w     io.Writer
+	debug bool
+}
+
+func (l *jsonLogger) WriteEntry(e Entry) {
+	if e.Level == DebugLevel && !l.debug {
+		return
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w.Write(append(data, '\n'))
+}
+
+func (l *jsonLogger) write(level Level, msg string) {
+	l.WriteEntry(Entry{Time: time.Now().UTC(), Level: level, Message: msg})
+}
+
+func (l *jsonLogger) Error(o string) {
+	l.write(ErrorLevel, o)
+}
+
+func (l *jsonLogger) Errorf(format string, o ...interface{}) {
+	l.write(ErrorLevel, fmt.Sprintf(format, o...))
+}
+
+func (l *jsonLogger) Fatal(o string) {
+	l.write(FatalLevel, o)
+	os.Exit(1)
+}
+
+func (l *jsonLogger) Fatalf(format string, o ...interface{}) {
+	l.Fatal(fmt.Sprintf(format, o...))
+}
+
+func (l *jsonLogger) Info(o string) {
+	l.write(InfoLevel, o)
+}
+
+func (l *jsonLogger) Infof(format string, o ...interface{}) {
+	l.write(InfoLevel, fmt.Sprintf(format, o...))
+}
+
+func (l *jsonLogger) Debug(o string) {
+	l.write(DebugLevel, o)
+}
+
+func (l *jsonLogger) Debugf(format string, o ...interface{}) {
+	l.write(DebugLevel, fmt.Sprintf(format, o...))
+}
+
+func (l *jsonLogger) GetStdLogger() *log.Logger {
+	return log.New(&jsonWriter{logger: l}, "", 0)
+}
+
+// jsonWriter turns the lines written by a standard logger into info
+// messages.
+type jsonWriter struct {
+	logger *jsonLogger
+}
+
+func (w *jsonWriter) Write(p []byte) (int, error) {
+	msg := string(p)
+	if n := len(msg); n > 0 && msg[n-1] == '\n' {
+		msg = msg[:n-1]
+	}
+	w.logger.Info(msg)
+	return len(p), nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+type JSONLoggerSuite struct {
+	buf bytes.Buffer
+}
+
+var _ = check.Suite(&JSONLoggerSuite{})
+
+func (s *JSONLoggerSuite) SetUpTest(c *check.C) {
+	s.buf.Reset()
+}
+
+func (s *JSONLoggerSuite) entries(c *check.C) []Entry {
+	var entries []Entry
+	for _, line := range strings.Split(strings.TrimSpace(s.buf.String()), "\n") {
+		var e Entry
+		err := json.Unmarshal([]byte(line), &e)
+		c.Assert(err, check.IsNil)
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func (s *JSONLoggerSuite) TestLevels(c *check.C) {
+	logger := NewJSONLogger(&s.buf, true)
+	logger.Debugf("debug %d", 1)
+	logger.Infof("info %d", 2)
+	logger.Errorf("error %d", 3)
+	entries := s.entries(c)
+	c.Assert(entries, check.HasLen, 3)
+	c.Assert(entries[0].Level, check.Equals, DebugLevel)
+	c.Assert(entries[0].Message, check.Equals, "debug 1")
+	c.Assert(entries[0].Time.IsZero(), check.Equals, false)
+	c.Assert(entries[1].Level, check.Equals, InfoLevel)
+	c.Assert(entries[1].Message, check.Equals, "info 2")
+	c.Assert(entries[2].Level, check.Equals, ErrorLevel)
+	c.Assert(entries[2].Message, check.Equals, "error 3")
+	c.Assert(s.buf.String(), check.Matches, `(?s)\{"time":"[^"]+","level":"debug","message":"debug 1"\}\n.*`)
+}
+
+func (s *JSONLoggerSuite) TestDebugDisabled(c *check.C) {
+	logger := NewJSONLogger(&s.buf, false)
+	logger.Debug("debug")
+	c.Assert(s.buf.String(), check.Equals, "")
+}
+
+func (s *JSONLoggerSuite) TestSubsystem(c *check.C) {
+	SetLogger(NewMultiLogger(NewJSONLogger(&s.buf, true)))
+	defer SetLogger(nil)
+	Subsystem("api").Errorf("something went wrong")
+	entries := s.entries(c)
+	c.Assert(entries, check.HasLen, 1)
+	c.Assert(entries[0].Subsystem, check.Equals, "api")
+	c.Assert(entries[0].Level, check.Equals, ErrorLevel)
+	c.Assert(entries[0].Message, check.Equals, "something went wrong")
+}
+
+func (s *JSONLoggerSuite) TestGetStdLogger(c *check.C) {
+	logger := NewJSONLogger(&s.buf, true)
+	logger.GetStdLogger().Printf("from std %s", "logger")
+	entries := s.entries(c)
+	c.Assert(entries, check.HasLen, 1)
+	c.Assert(entries[0].Level, check.Equals, InfoLevel)
+	c.Assert(entries[0].Message, check.Equals, "from std logger")
+}
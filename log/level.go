@@ -0,0 +1,137 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Level is the severity of a log message. Messages below the level set for
+// the default logger or for a subsystem are discarded.
+type Level int
+
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	ErrorLevel
+	FatalLevel
+)
+
+var levelNames = map[Level]string{
+	DebugLevel: "debug",
+	InfoLevel:  "info",
+	ErrorLevel: "error",
+	FatalLevel: "fatal",
+}
+
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+func (l *Level) UnmarshalText(text []byte) error {
+	level, err := ParseLevel(string(text))
+	if err != nil {
+		return err
+	}
+	*l = level
+	return nil
+}
+
+// ParseLevel returns the level with the given name, which must be one of
+// debug, info or error.
+func ParseLevel(name string) (Level, error) {
+	switch name {
+	case "debug":
+		return DebugLevel, nil
+	case "info":
+		return InfoLevel, nil
+	case "error":
+		return ErrorLevel, nil
+	}
+	return 0, errors.Errorf("invalid log level %q, it must be one of: debug, info, error", name)
+}
+
+var levels = struct {
+	sync.RWMutex
+	base       Level
+	subsystems map[string]Level
+}{subsystems: map[string]Level{}}
+
+// SetLevel sets the minimum level of the messages logged by the given
+// subsystem. An empty subsystem sets the default level, used by the default
+// logger and by subsystems without a level of their own.
+func SetLevel(subsystem string, level Level) {
+	levels.Lock()
+	defer levels.Unlock()
+	if subsystem == "" {
+		levels.base = level
+		return
+	}
+	levels.subsystems[subsystem] = level
+}
+
+// UnsetLevel removes the level of the given subsystem, which goes back to
+// using the default level.
+func UnsetLevel(subsystem string) {
+	levels.Lock()
+	defer levels.Unlock()
+	delete(levels.subsystems, subsystem)
+}
+
+// Levels returns the default level along with the levels set for each
+// subsystem.
+func Levels() (Level, map[string]Level) {
+	levels.RLock()
+	defer levels.RUnlock()
+	subsystems := make(map[string]Level, len(levels.subsystems))
+	for name, level := range levels.subsystems {
+		subsystems[name] = level
+	}
+	return levels.base, subsystems
+}
+
+func resetLevels(base Level, subsystems map[string]Level) {
+	levels.Lock()
+	defer levels.Unlock()
+	levels.base = base
+	levels.subsystems = subsystems
+}
+
+func enabled(subsystem string, level Level) bool {
+	levels.RLock()
+	defer levels.RUnlock()
+	min, ok := levels.subsystems[subsystem]
+	if !ok {
+		min = levels.base
+	}
+	return level >= min
+}
+
+var subsystems = struct {
+	sync.Mutex
+	loggers map[string]*SubsystemLogger
+}{loggers: map[string]*SubsystemLogger{}}
+
+// Subsystems returns the names of the subsystems with loggers, sorted.
+func Subsystems() []string {
+	subsystems.Lock()
+	defer subsystems.Unlock()
+	names := make([]string, 0, len(subsystems.loggers))
+	for name := range subsystems.loggers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
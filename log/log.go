@@ -15,6 +15,7 @@ import (
 	"log"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/tsuru/config"
@@ -25,37 +26,118 @@ type Logger interface {
 	Errorf(string, ...interface{})
 	Fatal(string)
 	Fatalf(string, ...interface{})
+	Info(string)
+	Infof(string, ...interface{})
 	Debug(string)
 	Debugf(string, ...interface{})
 	GetStdLogger() *log.Logger
 }
 
-func Init() error {
-	var loggers []Logger
-	debug, _ := config.GetBool("debug")
+// Init sets the default target and the log levels from the config. The
+// loggers write every message they get, leaving it to the levels to filter
+// them, so the levels may be changed at runtime.
+//
+// Init may be called again to reload the configuration: the previous target
+// is only replaced, and its file and syslog connection closed, once the new
+// one is successfully set up. On failure the previous target is kept.
+func Init() (err error) {
+	base, subsystemLevels, err := configLevels()
+	if err != nil {
+		return err
+	}
+	format, _ := config.GetString("log:format")
+	switch format {
+	case "", "text", "json":
+	default:
+		return errors.Errorf("invalid log format %q, it must be one of: text, json", format)
+	}
+	newWriterLogger := NewWriterLogger
+	if format == "json" {
+		newWriterLogger = NewJSONLogger
+	}
+	var (
+		loggers []Logger
+		closers []io.Closer
+	)
+	defer func() {
+		if err != nil {
+			closeAll(closers)
+		}
+	}()
 	if logFileName, err := config.GetString("log:file"); err == nil {
-		loggers = append(loggers, NewFileLogger(logFileName, debug))
+		file, err := os.OpenFile(logFileName, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return errors.Wrap(err, "unable to open log file")
+		}
+		closers = append(closers, file)
+		loggers = append(loggers, newWriterLogger(file, true))
 	} else if err == config.ErrMismatchConf {
-		panic(fmt.Sprintf("%s please see http://docs.tsuru.io/en/latest/reference/config.html#log-file", err))
+		return errors.Errorf("%s please see http://docs.tsuru.io/en/latest/reference/config.html#log-file", err)
 	}
 	if disableSyslog, _ := config.GetBool("log:disable-syslog"); !disableSyslog {
 		tag, _ := config.GetString("log:syslog-tag")
 		if tag == "" {
 			tag = "tsurud"
 		}
-		syslogLogger, err := NewSyslogLogger(tag, debug)
+		syslogLogger, err := NewSyslogLogger(tag, true)
 		if err != nil {
 			return err
 		}
+		if closer, ok := syslogLogger.(io.Closer); ok {
+			closers = append(closers, closer)
+		}
 		loggers = append(loggers, syslogLogger)
 	}
 	if useStderr, _ := config.GetBool("log:use-stderr"); useStderr {
-		loggers = append(loggers, NewWriterLogger(os.Stderr, debug))
+		loggers = append(loggers, newWriterLogger(os.Stderr, true))
 	}
+	resetLevels(base, subsystemLevels)
 	SetLogger(NewMultiLogger(loggers...))
+	initClosersMu.Lock()
+	previous := initClosers
+	initClosers = closers
+	initClosersMu.Unlock()
+	closeAll(previous)
 	return nil
 }
 
+var (
+	initClosersMu sync.Mutex
+	initClosers   []io.Closer
+)
+
+func closeAll(closers []io.Closer) {
+	for _, closer := range closers {
+		closer.Close()
+	}
+}
+
+func configLevels() (Level, map[string]Level, error) {
+	base := InfoLevel
+	if debug, _ := config.GetBool("debug"); debug {
+		base = DebugLevel
+	}
+	if name, err := config.GetString("log:level"); err == nil {
+		level, err := ParseLevel(name)
+		if err != nil {
+			return 0, nil, err
+		}
+		base = level
+	}
+	subsystemLevels := map[string]Level{}
+	names, _ := config.Get("log:levels")
+	if names, ok := names.(map[interface{}]interface{}); ok {
+		for subsystem, name := range names {
+			level, err := ParseLevel(fmt.Sprint(name))
+			if err != nil {
+				return 0, nil, errors.Wrapf(err, "invalid level for subsystem %v", subsystem)
+			}
+			subsystemLevels[fmt.Sprint(subsystem)] = level
+		}
+	}
+	return base, subsystemLevels, nil
+}
+
 // Target is the current target for the log package.
 type Target struct {
 	logger Logger
@@ -120,9 +202,38 @@ func (t *Target) Fatalf(format string, v ...interface{}) {
 	}
 }
 
+// Info writes the value to the Target
+// logger.
+func (t *Target) Info(v string) {
+	if !enabled("", InfoLevel) {
+		return
+	}
+	t.mut.RLock()
+	defer t.mut.RUnlock()
+	if t.logger != nil {
+		t.logger.Info(v)
+	}
+}
+
+// Infof writes the formatted string to the Target
+// logger.
+func (t *Target) Infof(format string, v ...interface{}) {
+	if !enabled("", InfoLevel) {
+		return
+	}
+	t.mut.RLock()
+	defer t.mut.RUnlock()
+	if t.logger != nil {
+		t.logger.Infof(format, v...)
+	}
+}
+
 // Debug writes the value to the Target
 // logger.
 func (t *Target) Debug(v string) {
+	if !enabled("", DebugLevel) {
+		return
+	}
 	t.mut.RLock()
 	defer t.mut.RUnlock()
 	if t.logger != nil {
@@ -133,6 +244,9 @@ func (t *Target) Debug(v string) {
 // Debugf writes the formatted string to the Target
 // logger.
 func (t *Target) Debugf(format string, v ...interface{}) {
+	if !enabled("", DebugLevel) {
+		return
+	}
 	t.mut.RLock()
 	defer t.mut.RUnlock()
 	if t.logger != nil {
@@ -140,6 +254,14 @@ func (t *Target) Debugf(format string, v ...interface{}) {
 	}
 }
 
+func (t *Target) write(level Level, subsystem, msg string) {
+	t.mut.RLock()
+	defer t.mut.RUnlock()
+	if t.logger != nil {
+		writeEntry(t.logger, Entry{Time: time.Now().UTC(), Level: level, Subsystem: subsystem, Message: msg})
+	}
+}
+
 // GetStdLogger returns a standard Logger instance
 // useful for configuring log in external packages.
 func (t *Target) GetStdLogger() *log.Logger {
@@ -173,6 +295,16 @@ func Fatalf(format string, v ...interface{}) {
 	DefaultTarget.Fatalf(format, v...)
 }
 
+// Info is a wrapper for DefaultTarget.Info.
+func Info(v string) {
+	DefaultTarget.Info(v)
+}
+
+// Infof is a wrapper for DefaultTarget.Infof.
+func Infof(format string, v ...interface{}) {
+	DefaultTarget.Infof(format, v...)
+}
+
 // Debug is a wrapper for DefaultTarget.Debug.
 func Debug(v string) {
 	DefaultTarget.Debug(v)
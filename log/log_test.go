@@ -8,6 +8,9 @@ import (
 	"bytes"
 	stderrors "errors"
 	"log"
+	"os"
+	"path/filepath"
+	"sort"
 	"testing"
 
 	"github.com/pkg/errors"
@@ -104,5 +107,154 @@ func (s *S) TestInitWithWrongConf(c *check.C) {
 	configFile := "testdata/wrongconfig.yml"
 	err := config.ReadConfigFile(configFile)
 	c.Assert(err, check.IsNil)
-	c.Assert(Init, check.PanicMatches, "Your conf is wrong: please see http://docs.tsuru.io/en/latest/reference/config.html#log-file")
+	err = Init()
+	c.Assert(err, check.ErrorMatches, "Your conf is wrong: please see http://docs.tsuru.io/en/latest/reference/config.html#log-file")
+}
+
+func (s *S) TestLogInfof(c *check.C) {
+	buf := newFakeLogger()
+	defer buf.Reset()
+	Infof("log anything %d", 1)
+	c.Assert(buf.String(), check.Equals, "INFO: log anything 1\n")
+}
+
+func (s *S) TestLogLevel(c *check.C) {
+	buf := newFakeLogger()
+	defer buf.Reset()
+	SetLevel("", InfoLevel)
+	defer SetLevel("", DebugLevel)
+	Debugf("log debug %d", 1)
+	Infof("log info %d", 1)
+	Errorf("log error %d", 1)
+	c.Assert(buf.String(), check.Equals, "INFO: log info 1\nERROR: log error 1\n")
+}
+
+func (s *S) TestSubsystem(c *check.C) {
+	buf := newFakeLogger()
+	defer buf.Reset()
+	logger := Subsystem("queue")
+	c.Assert(Subsystem("queue"), check.Equals, logger)
+	c.Assert(logger.Name(), check.Equals, "queue")
+	names := Subsystems()
+	c.Assert(sort.StringsAreSorted(names), check.Equals, true)
+	c.Assert(names[sort.SearchStrings(names, "queue")], check.Equals, "queue")
+	logger.Debugf("message %d", 1)
+	logger.Infof("message %d", 2)
+	logger.Errorf("message %d", 3)
+	c.Assert(buf.String(), check.Equals, "DEBUG: [queue] message 1\nINFO: [queue] message 2\nERROR: [queue] message 3\n")
+}
+
+func (s *S) TestSubsystemLevel(c *check.C) {
+	buf := newFakeLogger()
+	defer buf.Reset()
+	SetLevel("", ErrorLevel)
+	SetLevel("router", DebugLevel)
+	defer func() {
+		SetLevel("", DebugLevel)
+		UnsetLevel("router")
+	}()
+	Subsystem("router").Debugf("router message")
+	Subsystem("queue").Infof("queue message")
+	Debugf("default message")
+	c.Assert(buf.String(), check.Equals, "DEBUG: [router] router message\n")
+	base, levels := Levels()
+	c.Assert(base, check.Equals, ErrorLevel)
+	c.Assert(levels, check.DeepEquals, map[string]Level{"router": DebugLevel})
+}
+
+func (s *S) TestParseLevel(c *check.C) {
+	for _, name := range []string{"debug", "info", "error"} {
+		level, err := ParseLevel(name)
+		c.Check(err, check.IsNil)
+		c.Check(level.String(), check.Equals, name)
+	}
+	_, err := ParseLevel("verbose")
+	c.Assert(err, check.ErrorMatches, `invalid log level "verbose", it must be one of: debug, info, error`)
+}
+
+func (s *S) TestInitLevels(c *check.C) {
+	config.Set("log:disable-syslog", true)
+	config.Set("log:level", "error")
+	config.Set("log:levels", map[interface{}]interface{}{"queue": "debug"})
+	defer func() {
+		config.Unset("log")
+		resetLevels(DebugLevel, map[string]Level{})
+		SetLogger(nil)
+	}()
+	err := Init()
+	c.Assert(err, check.IsNil)
+	base, levels := Levels()
+	c.Assert(base, check.Equals, ErrorLevel)
+	c.Assert(levels, check.DeepEquals, map[string]Level{"queue": DebugLevel})
+}
+
+func (s *S) TestInitLevelsDebug(c *check.C) {
+	config.Set("log:disable-syslog", true)
+	defer func() {
+		config.Unset("log")
+		resetLevels(DebugLevel, map[string]Level{})
+		SetLogger(nil)
+	}()
+	err := Init()
+	c.Assert(err, check.IsNil)
+	base, _ := Levels()
+	c.Assert(base, check.Equals, InfoLevel)
+	config.Set("debug", true)
+	defer config.Unset("debug")
+	err = Init()
+	c.Assert(err, check.IsNil)
+	base, _ = Levels()
+	c.Assert(base, check.Equals, DebugLevel)
+}
+
+func (s *S) TestInitInvalidLevel(c *check.C) {
+	config.Set("log:disable-syslog", true)
+	config.Set("log:levels", map[interface{}]interface{}{"queue": "verbose"})
+	defer config.Unset("log")
+	err := Init()
+	c.Assert(err, check.ErrorMatches, `invalid level for subsystem queue: invalid log level "verbose".*`)
+}
+
+func (s *S) TestInitInvalidFormat(c *check.C) {
+	config.Set("log:disable-syslog", true)
+	config.Set("log:format", "xml")
+	defer config.Unset("log")
+	err := Init()
+	c.Assert(err, check.ErrorMatches, `invalid log format "xml", it must be one of: text, json`)
+}
+
+func (s *S) TestInitInvalidFileKeepsLogger(c *check.C) {
+	config.Set("log:disable-syslog", true)
+	config.Set("log:file", "/dev/null/tsuru.log")
+	defer config.Unset("log")
+	var buf bytes.Buffer
+	previous := NewWriterLogger(&buf, true)
+	SetLogger(previous)
+	defer SetLogger(nil)
+	err := Init()
+	c.Assert(err, check.ErrorMatches, `unable to open log file: .*`)
+	c.Assert(DefaultTarget.logger, check.Equals, previous)
+}
+
+func (s *S) TestInitClosesPreviousFile(c *check.C) {
+	dir := c.MkDir()
+	config.Set("log:disable-syslog", true)
+	config.Set("log:file", filepath.Join(dir, "first.log"))
+	defer func() {
+		config.Unset("log")
+		closeAll(initClosers)
+		initClosers = nil
+		SetLogger(nil)
+	}()
+	err := Init()
+	c.Assert(err, check.IsNil)
+	c.Assert(initClosers, check.HasLen, 1)
+	first := initClosers[0].(*os.File)
+	config.Set("log:file", filepath.Join(dir, "second.log"))
+	err = Init()
+	c.Assert(err, check.IsNil)
+	c.Assert(initClosers, check.HasLen, 1)
+	c.Assert(initClosers[0].(*os.File).Name(), check.Equals, filepath.Join(dir, "second.log"))
+	_, err = first.Write([]byte("x"))
+	c.Assert(err, check.NotNil)
 }
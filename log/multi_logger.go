@@ -23,6 +23,12 @@ func (m *multiLogger) Debug(message string) {
 	}
 }
 
+func (m *multiLogger) Info(message string) {
+	for _, logger := range m.loggers {
+		logger.Info(message)
+	}
+}
+
 func (m *multiLogger) Error(message string) {
 	for _, logger := range m.loggers {
 		logger.Error(message)
@@ -42,6 +48,12 @@ func (m *multiLogger) Debugf(format string, v ...interface{}) {
 	}
 }
 
+func (m *multiLogger) Infof(format string, v ...interface{}) {
+	for _, logger := range m.loggers {
+		logger.Infof(format, v...)
+	}
+}
+
 func (m *multiLogger) Errorf(format string, v ...interface{}) {
 	for _, logger := range m.loggers {
 		logger.Errorf(format, v...)
@@ -55,6 +67,12 @@ func (m *multiLogger) Fatalf(format string, v ...interface{}) {
 	os.Exit(1)
 }
 
+func (m *multiLogger) WriteEntry(e Entry) {
+	for _, logger := range m.loggers {
+		writeEntry(logger, e)
+	}
+}
+
 func (m *multiLogger) GetStdLogger() *log.Logger {
 	if len(m.loggers) == 0 {
 		return nil
@@ -0,0 +1,67 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import "fmt"
+
+// SubsystemLogger writes messages of a tsuru subsystem (like the api, the
+// provisioners, the work queues or the routers) to the default target. Each
+// subsystem may have its own log level and has its name attached to every
+// message.
+type SubsystemLogger struct {
+	name string
+}
+
+// Subsystem returns the logger of the subsystem with the given name.
+func Subsystem(name string) *SubsystemLogger {
+	subsystems.Lock()
+	defer subsystems.Unlock()
+	l, ok := subsystems.loggers[name]
+	if !ok {
+		l = &SubsystemLogger{name: name}
+		subsystems.loggers[name] = l
+	}
+	return l
+}
+
+// Name returns the name of the subsystem.
+func (l *SubsystemLogger) Name() string {
+	return l.name
+}
+
+// Error writes the given error to the default target.
+func (l *SubsystemLogger) Error(v error) {
+	l.Errorf("%+v", v)
+}
+
+// Errorf writes the formatted string to the default target, along with the
+// stack of the arguments that carry one.
+func (l *SubsystemLogger) Errorf(format string, v ...interface{}) {
+	if !enabled(l.name, ErrorLevel) {
+		return
+	}
+	DefaultTarget.write(ErrorLevel, l.name, fmt.Sprintf(format, v...))
+	for _, item := range v {
+		if _, hasStack := item.(withStack); hasStack {
+			DefaultTarget.write(ErrorLevel, l.name, fmt.Sprintf("stack for error: %+v", item))
+		}
+	}
+}
+
+// Infof writes the formatted string to the default target.
+func (l *SubsystemLogger) Infof(format string, v ...interface{}) {
+	if !enabled(l.name, InfoLevel) {
+		return
+	}
+	DefaultTarget.write(InfoLevel, l.name, fmt.Sprintf(format, v...))
+}
+
+// Debugf writes the formatted string to the default target.
+func (l *SubsystemLogger) Debugf(format string, v ...interface{}) {
+	if !enabled(l.name, DebugLevel) {
+		return
+	}
+	DefaultTarget.write(DebugLevel, l.name, fmt.Sprintf(format, v...))
+}
@@ -28,6 +28,10 @@ type syslogLogger struct {
 	debug bool
 }
 
+func (l *syslogLogger) Close() error {
+	return l.w.Close()
+}
+
 func (l *syslogLogger) Error(o string) {
 	l.w.Err(o)
 }
@@ -45,6 +49,14 @@ func (l *syslogLogger) Fatalf(format string, o ...interface{}) {
 	l.Fatal(fmt.Sprintf(format, o...))
 }
 
+func (l *syslogLogger) Info(o string) {
+	l.w.Info(o)
+}
+
+func (l *syslogLogger) Infof(format string, o ...interface{}) {
+	l.w.Info(fmt.Sprintf(format, o...))
+}
+
 func (l *syslogLogger) Debug(o string) {
 	if l.debug {
 		l.w.Debug(o)
@@ -0,0 +1,141 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package maintenance controls the platform-wide maintenance mode. While it's
+// enabled, the API rejects mutating requests from users that are not allowed
+// to manage the maintenance mode, but keeps serving reads.
+package maintenance
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/log"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	modeID         = "maintenance"
+	defaultMessage = "tsuru is under maintenance, please try again later"
+)
+
+// cacheTTL is how long the mode read by Current is trusted before being
+// read again from the database.
+var cacheTTL = 5 * time.Second
+
+// Mode describes the state of the maintenance mode.
+type Mode struct {
+	Enabled   bool
+	Message   string
+	Owner     string    `json:",omitempty"`
+	StartTime time.Time `json:",omitempty"`
+}
+
+var cache struct {
+	sync.Mutex
+	mode       Mode
+	updatedAt  time.Time
+	refreshing bool
+	version    int
+}
+
+// Enable turns the maintenance mode on. When message is empty, the message
+// from the maintenance:message config entry is used.
+func Enable(message, owner string) (*Mode, error) {
+	if message == "" {
+		message, _ = config.GetString("maintenance:message")
+		if message == "" {
+			message = defaultMessage
+		}
+	}
+	mode := Mode{
+		Enabled:   true,
+		Message:   message,
+		Owner:     owner,
+		StartTime: time.Now().UTC(),
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	_, err = conn.Maintenance().UpsertId(modeID, mode)
+	if err != nil {
+		return nil, err
+	}
+	setCache(mode)
+	return &mode, nil
+}
+
+// Disable turns the maintenance mode off.
+func Disable() error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Maintenance().RemoveId(modeID)
+	if err != nil && err != mgo.ErrNotFound {
+		return err
+	}
+	setCache(Mode{})
+	return nil
+}
+
+// Get returns the maintenance mode stored in the database.
+func Get() (*Mode, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var mode Mode
+	err = conn.Maintenance().Find(bson.M{"_id": modeID}).One(&mode)
+	if err != nil && err != mgo.ErrNotFound {
+		return nil, err
+	}
+	return &mode, nil
+}
+
+// Current returns the maintenance mode, read from the database at most once
+// every few seconds. The database is read by a single caller at a time,
+// without blocking the others, which get the last known mode. The last known
+// mode is also kept for a few seconds when the database is not reachable, so
+// the maintenance mode keeps working during database maintenance.
+func Current() Mode {
+	cache.Lock()
+	if cache.refreshing || time.Since(cache.updatedAt) < cacheTTL {
+		mode := cache.mode
+		cache.Unlock()
+		return mode
+	}
+	cache.refreshing = true
+	version := cache.version
+	cache.Unlock()
+	mode, err := Get()
+	cache.Lock()
+	defer cache.Unlock()
+	cache.refreshing = false
+	if err != nil {
+		log.Errorf("[maintenance] unable to read maintenance mode, keeping last known mode: %s", err)
+		cache.updatedAt = time.Now()
+		return cache.mode
+	}
+	if cache.version == version {
+		cache.mode = *mode
+		cache.updatedAt = time.Now()
+	}
+	return cache.mode
+}
+
+func setCache(mode Mode) {
+	cache.Lock()
+	defer cache.Unlock()
+	cache.mode = mode
+	cache.updatedAt = time.Now()
+	cache.version++
+}
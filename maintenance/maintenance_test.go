@@ -0,0 +1,101 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package maintenance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/dbtest"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type S struct{}
+
+var _ = check.Suite(&S{})
+
+func (s *S) SetUpTest(c *check.C) {
+	config.Set("database:url", "127.0.0.1:27017")
+	config.Set("database:name", "tsuru_maintenance_tests")
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	err = dbtest.ClearAllCollections(conn.Maintenance().Database)
+	c.Assert(err, check.IsNil)
+	setCache(Mode{})
+}
+
+func (s *S) TearDownTest(c *check.C) {
+	config.Unset("maintenance:message")
+}
+
+func (s *S) TestEnable(c *check.C) {
+	mode, err := Enable("upgrading mongodb", "admin@example.com")
+	c.Assert(err, check.IsNil)
+	c.Assert(mode.Enabled, check.Equals, true)
+	c.Assert(mode.Message, check.Equals, "upgrading mongodb")
+	c.Assert(mode.Owner, check.Equals, "admin@example.com")
+	dbMode, err := Get()
+	c.Assert(err, check.IsNil)
+	c.Assert(dbMode.Enabled, check.Equals, true)
+	c.Assert(dbMode.Message, check.Equals, "upgrading mongodb")
+	c.Assert(Current().Enabled, check.Equals, true)
+}
+
+func (s *S) TestEnableDefaultMessage(c *check.C) {
+	mode, err := Enable("", "admin@example.com")
+	c.Assert(err, check.IsNil)
+	c.Assert(mode.Message, check.Equals, defaultMessage)
+	config.Set("maintenance:message", "come back later")
+	mode, err = Enable("", "admin@example.com")
+	c.Assert(err, check.IsNil)
+	c.Assert(mode.Message, check.Equals, "come back later")
+}
+
+func (s *S) TestDisable(c *check.C) {
+	_, err := Enable("upgrading mongodb", "admin@example.com")
+	c.Assert(err, check.IsNil)
+	err = Disable()
+	c.Assert(err, check.IsNil)
+	dbMode, err := Get()
+	c.Assert(err, check.IsNil)
+	c.Assert(*dbMode, check.DeepEquals, Mode{})
+	c.Assert(Current().Enabled, check.Equals, false)
+	err = Disable()
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestCurrentIsCached(c *check.C) {
+	_, err := Enable("upgrading mongodb", "admin@example.com")
+	c.Assert(err, check.IsNil)
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	err = conn.Maintenance().RemoveId(modeID)
+	c.Assert(err, check.IsNil)
+	c.Assert(Current().Enabled, check.Equals, true)
+	defer func(ttl time.Duration) { cacheTTL = ttl }(cacheTTL)
+	cacheTTL = 0
+	c.Assert(Current().Enabled, check.Equals, false)
+}
+
+func (s *S) TestCurrentKeepsLastModeOnError(c *check.C) {
+	_, err := Enable("upgrading mongodb", "admin@example.com")
+	c.Assert(err, check.IsNil)
+	cache.Lock()
+	cache.updatedAt = time.Time{}
+	cache.Unlock()
+	config.Set("database:url", "127.0.0.1:1")
+	defer config.Set("database:url", "127.0.0.1:27017")
+	c.Assert(Current().Enabled, check.Equals, true)
+	cache.Lock()
+	defer cache.Unlock()
+	c.Assert(cache.refreshing, check.Equals, false)
+	c.Assert(time.Since(cache.updatedAt) < cacheTTL, check.Equals, true)
+}
@@ -0,0 +1,179 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package maintenance
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/db"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Automated actions deferred by maintenance windows.
+const (
+	ActionHealing       = "healing"
+	ActionAutoscaleDown = "autoscale-down"
+	ActionImageGC       = "image-gc"
+)
+
+// Actions are the automated actions that may be deferred by maintenance
+// windows.
+var Actions = []string{ActionHealing, ActionAutoscaleDown, ActionImageGC}
+
+var (
+	ErrWindowNotFound      = errors.New("maintenance window not found")
+	ErrWindowAlreadyExists = errors.New("maintenance window already exists")
+
+	windowNameRegexp = regexp.MustCompile(`^[a-z][a-z0-9-]{0,39}$`)
+)
+
+// Window is a period during which automated actions are deferred for the
+// given apps and pools, keeping change freezes from being broken by tsuru
+// itself. Actions requested during the window are skipped and run again by
+// their periodic checks once the window ends.
+type Window struct {
+	Name    string   `bson:"_id"`
+	Apps    []string `bson:",omitempty" json:",omitempty"`
+	Pools   []string `bson:",omitempty" json:",omitempty"`
+	Actions []string `bson:",omitempty" json:",omitempty"`
+	Start   time.Time
+	End     time.Time
+	Reason  string `json:",omitempty"`
+	Owner   string `json:",omitempty"`
+}
+
+func (w *Window) validate() error {
+	var msgs []string
+	if !windowNameRegexp.MatchString(w.Name) {
+		msgs = append(msgs, "invalid window name, it must start with a letter and contain only lower case letters, numbers and dashes")
+	}
+	if len(w.Apps) == 0 && len(w.Pools) == 0 {
+		msgs = append(msgs, "at least one app or pool is required")
+	}
+	if w.Start.IsZero() || w.End.IsZero() {
+		msgs = append(msgs, "start and end are required")
+	} else if !w.End.After(w.Start) {
+		msgs = append(msgs, "end must be after start")
+	}
+	for _, action := range w.Actions {
+		valid := false
+		for _, a := range Actions {
+			if a == action {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			msgs = append(msgs, fmt.Sprintf("invalid action %q, valid actions are %v", action, Actions))
+		}
+	}
+	if len(msgs) > 0 {
+		return &tsuruErrors.ValidationError{Message: strings.Join(msgs, "\n")}
+	}
+	return nil
+}
+
+// AddWindow schedules a new maintenance window.
+func AddWindow(w Window) error {
+	err := w.validate()
+	if err != nil {
+		return err
+	}
+	w.Start = w.Start.UTC()
+	w.End = w.End.UTC()
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.MaintenanceWindows().Insert(w)
+	if mgo.IsDup(err) {
+		return ErrWindowAlreadyExists
+	}
+	return err
+}
+
+// RemoveWindow removes a maintenance window, ending it if it's active.
+func RemoveWindow(name string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.MaintenanceWindows().RemoveId(name)
+	if err == mgo.ErrNotFound {
+		return ErrWindowNotFound
+	}
+	return err
+}
+
+// ListWindows returns the maintenance windows not yet finished, sorted by
+// their start.
+func ListWindows() ([]Window, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var windows []Window
+	err = conn.MaintenanceWindows().Find(bson.M{"end": bson.M{"$gt": time.Now().UTC()}}).Sort("start").All(&windows)
+	if err != nil {
+		return nil, err
+	}
+	return windows, nil
+}
+
+// ActiveWindow returns the window deferring the action for the app or the
+// pool, or nil when there's none. Either appName or pool may be empty, when
+// only appName is given windows of the app's pool are also considered.
+func ActiveWindow(action, appName, pool string) (*Window, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if pool == "" && appName != "" {
+		var a struct{ Pool string }
+		err = conn.Apps().Find(bson.M{"name": appName}).Select(bson.M{"pool": 1}).One(&a)
+		if err != nil && err != mgo.ErrNotFound {
+			return nil, err
+		}
+		pool = a.Pool
+	}
+	var targets []bson.M
+	if appName != "" {
+		targets = append(targets, bson.M{"apps": appName})
+	}
+	if pool != "" {
+		targets = append(targets, bson.M{"pools": pool})
+	}
+	if len(targets) == 0 {
+		return nil, nil
+	}
+	now := time.Now().UTC()
+	query := bson.M{
+		"start": bson.M{"$lte": now},
+		"end":   bson.M{"$gt": now},
+		"$and": []bson.M{
+			{"$or": targets},
+			{"$or": []bson.M{{"actions": action}, {"actions": bson.M{"$exists": false}}}},
+		},
+	}
+	var w Window
+	err = conn.MaintenanceWindows().Find(query).One(&w)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package maintenance
+
+import (
+	"time"
+
+	"github.com/tsuru/tsuru/db"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestAddWindowInvalid(c *check.C) {
+	now := time.Now()
+	err := AddWindow(Window{Name: "Freeze", Start: now, End: now.Add(-time.Hour), Actions: []string{"deploy"}})
+	c.Assert(err, check.FitsTypeOf, &tsuruErrors.ValidationError{})
+	c.Assert(err, check.ErrorMatches, `(?s)invalid window name.*at least one app or pool is required.*end must be after start.*invalid action "deploy".*`)
+}
+
+func (s *S) TestAddAndRemoveWindow(c *check.C) {
+	now := time.Now()
+	w := Window{Name: "freeze", Pools: []string{"pool1"}, Start: now, End: now.Add(time.Hour)}
+	err := AddWindow(w)
+	c.Assert(err, check.IsNil)
+	c.Assert(AddWindow(w), check.Equals, ErrWindowAlreadyExists)
+	err = AddWindow(Window{Name: "past", Pools: []string{"pool1"}, Start: now.Add(-2 * time.Hour), End: now.Add(-time.Hour)})
+	c.Assert(err, check.IsNil)
+	windows, err := ListWindows()
+	c.Assert(err, check.IsNil)
+	c.Assert(windows, check.HasLen, 1)
+	c.Assert(windows[0].Name, check.Equals, "freeze")
+	err = RemoveWindow("freeze")
+	c.Assert(err, check.IsNil)
+	c.Assert(RemoveWindow("freeze"), check.Equals, ErrWindowNotFound)
+}
+
+func (s *S) TestActiveWindow(c *check.C) {
+	now := time.Now()
+	err := AddWindow(Window{Name: "apps", Apps: []string{"myapp"}, Actions: []string{ActionHealing}, Start: now.Add(-time.Minute), End: now.Add(time.Hour)})
+	c.Assert(err, check.IsNil)
+	err = AddWindow(Window{Name: "pools", Pools: []string{"pool1"}, Start: now.Add(-time.Minute), End: now.Add(time.Hour)})
+	c.Assert(err, check.IsNil)
+	err = AddWindow(Window{Name: "future", Pools: []string{"pool2"}, Start: now.Add(time.Hour), End: now.Add(2 * time.Hour)})
+	c.Assert(err, check.IsNil)
+	w, err := ActiveWindow(ActionHealing, "myapp", "")
+	c.Assert(err, check.IsNil)
+	c.Assert(w, check.NotNil)
+	c.Assert(w.Name, check.Equals, "apps")
+	w, err = ActiveWindow(ActionImageGC, "myapp", "")
+	c.Assert(err, check.IsNil)
+	c.Assert(w, check.IsNil)
+	w, err = ActiveWindow(ActionAutoscaleDown, "", "pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(w, check.NotNil)
+	c.Assert(w.Name, check.Equals, "pools")
+	w, err = ActiveWindow(ActionAutoscaleDown, "", "pool2")
+	c.Assert(err, check.IsNil)
+	c.Assert(w, check.IsNil)
+}
+
+func (s *S) TestActiveWindowUsesAppPool(c *check.C) {
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	err = conn.Apps().Insert(bson.M{"name": "otherapp", "pool": "pool1"})
+	c.Assert(err, check.IsNil)
+	now := time.Now()
+	err = AddWindow(Window{Name: "pools", Pools: []string{"pool1"}, Start: now.Add(-time.Minute), End: now.Add(time.Hour)})
+	c.Assert(err, check.IsNil)
+	w, err := ActiveWindow(ActionImageGC, "otherapp", "")
+	c.Assert(err, check.IsNil)
+	c.Assert(w, check.NotNil)
+	c.Assert(w.Name, check.Equals, "pools")
+}
@@ -25,6 +25,7 @@ function missing_handlers {
 
     ignored=$(cat <<EOF
 github.com/tsuru/tsuru/api.authScheme
+github.com/tsuru/tsuru/api.deployWebhook
 github.com/tsuru/tsuru/api.healthcheck
 github.com/tsuru/tsuru/api.index
 github.com/tsuru/tsuru/api.info
@@ -45,6 +46,7 @@ github.com/tsuru/tsuru/api.removeKeyFromUser
 github.com/tsuru/tsuru/api.setNodeStatus
 github.com/tsuru/tsuru/api.kindList
 github.com/tsuru/tsuru/api.eventList
+github.com/tsuru/tsuru/api.eventStream
 github.com/tsuru/tsuru/api.eventInfo
 github.com/tsuru/tsuru/api.eventCancel
 github.com/tsuru/tsuru/api.listNodesHandler
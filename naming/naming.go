@@ -0,0 +1,241 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package naming enforces the naming policy of apps and service instances:
+// an optional pattern from the config, prefixes required in the names
+// created by each team and names reserved by platform administrators.
+package naming
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/db"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/validation"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Kinds of resources whose names are checked by the naming policy.
+const (
+	KindApp             = "app"
+	KindServiceInstance = "service-instance"
+)
+
+var Kinds = []string{KindApp, KindServiceInstance}
+
+var (
+	ErrReservationNotFound      = errors.New("name reservation not found")
+	ErrReservationAlreadyExists = errors.New("name already reserved")
+)
+
+// Reservation protects a name from being used by apps or service instances.
+// When Team is set, the team is still allowed to use the name.
+type Reservation struct {
+	Kind      string
+	Name      string
+	Team      string `json:",omitempty"`
+	Reason    string `json:",omitempty"`
+	Owner     string
+	CreatedAt time.Time
+}
+
+// TeamPrefixes are the prefixes required in the names of apps and service
+// instances created by a team.
+type TeamPrefixes struct {
+	Team     string `bson:"_id"`
+	Prefixes []string
+}
+
+func validKind(kind string) bool {
+	for _, k := range Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Check validates the name of a new resource of the given kind owned by
+// team against the naming policy. Violations are returned as a
+// ValidationError.
+func Check(kind, name, team string) error {
+	verr := &tsuruErrors.ValidationError{}
+	pattern, _ := config.GetString("naming:" + kind + "-pattern")
+	if pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return errors.Wrapf(err, "invalid naming:%s-pattern config entry", kind)
+		}
+		if !re.MatchString(name) {
+			verr.Addf("name", "name %q doesn't match the naming policy pattern %q", name, pattern)
+		}
+	}
+	prefixes, err := GetTeamPrefixes(team)
+	if err != nil {
+		return err
+	}
+	if len(prefixes) > 0 && !hasPrefix(name, prefixes) {
+		verr.Addf("name", "names created by team %q must start with one of: %s", team, strings.Join(prefixes, ", "))
+	}
+	r, err := getReservation(kind, name)
+	if err != nil && err != ErrReservationNotFound {
+		return err
+	}
+	if r != nil && (r.Team == "" || r.Team != team) {
+		verr.Addf("name", "name %q is reserved", name)
+	}
+	return verr.ToError()
+}
+
+func hasPrefix(name string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reserve protects a name from being used by new resources of its kind.
+// Resources already using the name are kept.
+func Reserve(r Reservation) error {
+	verr := &tsuruErrors.ValidationError{}
+	if !validKind(r.Kind) {
+		verr.Addf("kind", "invalid kind %q, valid kinds are: %s", r.Kind, strings.Join(Kinds, ", "))
+	}
+	if !validation.ValidateName(r.Name) {
+		verr.Addf("name", "invalid name %q", r.Name)
+	}
+	if r.Team != "" {
+		if _, err := auth.GetTeam(r.Team); err != nil {
+			verr.Addf("team", "team %q not found", r.Team)
+		}
+	}
+	err := verr.ToError()
+	if err != nil {
+		return err
+	}
+	r.CreatedAt = time.Now().UTC()
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.NameReservations().Insert(r)
+	if mgo.IsDup(err) {
+		return ErrReservationAlreadyExists
+	}
+	return err
+}
+
+// Release removes the reservation of a name.
+func Release(kind, name string) error {
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.NameReservations().Remove(bson.M{"kind": kind, "name": name})
+	if err == mgo.ErrNotFound {
+		return ErrReservationNotFound
+	}
+	return err
+}
+
+// ListReservations returns the reserved names, optionally filtered by kind.
+func ListReservations(kind string) ([]Reservation, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	query := bson.M{}
+	if kind != "" {
+		query["kind"] = kind
+	}
+	var reservations []Reservation
+	err = conn.NameReservations().Find(query).Sort("kind", "name").All(&reservations)
+	if err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
+
+func getReservation(kind, name string) (*Reservation, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var r Reservation
+	err = conn.NameReservations().Find(bson.M{"kind": kind, "name": name}).One(&r)
+	if err == mgo.ErrNotFound {
+		return nil, ErrReservationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// SetTeamPrefixes sets the prefixes required in names created by a team. An
+// empty list removes the requirement.
+func SetTeamPrefixes(team string, prefixes []string) error {
+	if _, err := auth.GetTeam(team); err != nil {
+		return err
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if len(prefixes) == 0 {
+		err = conn.NamingPrefixes().RemoveId(team)
+		if err == mgo.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+	_, err = conn.NamingPrefixes().UpsertId(team, TeamPrefixes{Team: team, Prefixes: prefixes})
+	return err
+}
+
+// GetTeamPrefixes returns the prefixes required in names created by a team.
+func GetTeamPrefixes(team string) ([]string, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var p TeamPrefixes
+	err = conn.NamingPrefixes().FindId(team).One(&p)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p.Prefixes, nil
+}
+
+// ListTeamPrefixes returns the prefixes of all teams that have them.
+func ListTeamPrefixes() ([]TeamPrefixes, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var prefixes []TeamPrefixes
+	err = conn.NamingPrefixes().Find(nil).Sort("_id").All(&prefixes)
+	if err != nil {
+		return nil, err
+	}
+	return prefixes, nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package naming
+
+import (
+	"github.com/tsuru/config"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestCheckPattern(c *check.C) {
+	config.Set("naming:app-pattern", "^[a-z]+-(dev|prod)$")
+	c.Assert(Check(KindApp, "billing-prod", "payments"), check.IsNil)
+	err := Check(KindApp, "billing", "payments")
+	c.Assert(err, check.FitsTypeOf, &tsuruErrors.ValidationError{})
+	c.Assert(err, check.ErrorMatches, `name "billing" doesn't match the naming policy pattern .*`)
+	c.Assert(Check(KindServiceInstance, "billing", "payments"), check.IsNil)
+}
+
+func (s *S) TestCheckTeamPrefixes(c *check.C) {
+	err := SetTeamPrefixes("payments", []string{"pay-", "billing-"})
+	c.Assert(err, check.IsNil)
+	c.Assert(Check(KindApp, "pay-api", "payments"), check.IsNil)
+	c.Assert(Check(KindServiceInstance, "billing-db", "payments"), check.IsNil)
+	err = Check(KindApp, "api", "payments")
+	c.Assert(err, check.ErrorMatches, `names created by team "payments" must start with one of: pay-, billing-`)
+	c.Assert(Check(KindApp, "api", "platform"), check.IsNil)
+	err = SetTeamPrefixes("payments", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(Check(KindApp, "api", "payments"), check.IsNil)
+	prefixes, err := ListTeamPrefixes()
+	c.Assert(err, check.IsNil)
+	c.Assert(prefixes, check.HasLen, 0)
+}
+
+func (s *S) TestCheckReservation(c *check.C) {
+	err := Reserve(Reservation{Kind: KindApp, Name: "www", Owner: "admin@example.com"})
+	c.Assert(err, check.IsNil)
+	err = Reserve(Reservation{Kind: KindApp, Name: "api", Team: "platform"})
+	c.Assert(err, check.IsNil)
+	c.Assert(Check(KindApp, "www", "platform"), check.ErrorMatches, `name "www" is reserved`)
+	c.Assert(Check(KindServiceInstance, "www", "platform"), check.IsNil)
+	c.Assert(Check(KindApp, "api", "platform"), check.IsNil)
+	c.Assert(Check(KindApp, "api", "payments"), check.ErrorMatches, `name "api" is reserved`)
+	err = Release(KindApp, "www")
+	c.Assert(err, check.IsNil)
+	c.Assert(Check(KindApp, "www", "platform"), check.IsNil)
+	c.Assert(Release(KindApp, "www"), check.Equals, ErrReservationNotFound)
+}
+
+func (s *S) TestReserveInvalid(c *check.C) {
+	err := Reserve(Reservation{Kind: "pool", Name: "WWW", Team: "unknown"})
+	c.Assert(err, check.FitsTypeOf, &tsuruErrors.ValidationError{})
+	c.Assert(err, check.ErrorMatches, `(?s)invalid kind "pool".*invalid name "WWW".*team "unknown" not found`)
+}
+
+func (s *S) TestReserveDuplicated(c *check.C) {
+	err := Reserve(Reservation{Kind: KindApp, Name: "www"})
+	c.Assert(err, check.IsNil)
+	err = Reserve(Reservation{Kind: KindApp, Name: "www"})
+	c.Assert(err, check.Equals, ErrReservationAlreadyExists)
+	err = Reserve(Reservation{Kind: KindServiceInstance, Name: "www"})
+	c.Assert(err, check.IsNil)
+	reservations, err := ListReservations(KindApp)
+	c.Assert(err, check.IsNil)
+	c.Assert(reservations, check.HasLen, 1)
+	reservations, err = ListReservations("")
+	c.Assert(err, check.IsNil)
+	c.Assert(reservations, check.HasLen, 2)
+}
@@ -39,6 +39,7 @@ var (
 	Dial5Full300Client, Dial5Dialer           = makeTimeoutHTTPClient(5*time.Second, 5*time.Minute, 5, true)
 	Dial5FullUnlimitedClient, _               = makeTimeoutHTTPClient(5*time.Second, 0, 5, true)
 	Dial5Full300ClientNoKeepAlive, _          = makeTimeoutHTTPClient(5*time.Second, 5*time.Minute, -1, true)
+	Dial5FullUnlimitedClientNoKeepAlive, _    = makeTimeoutHTTPClient(5*time.Second, 0, -1, true)
 	Dial5Full60ClientNoKeepAlive, _           = makeTimeoutHTTPClient(5*time.Second, 1*time.Minute, -1, true)
 	Dial5Full60ClientNoKeepAliveNoRedirect, _ = makeTimeoutHTTPClient(5*time.Second, 1*time.Minute, -1, false)
 )
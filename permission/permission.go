@@ -11,12 +11,21 @@ import (
 	"strings"
 
 	"github.com/pkg/errors"
+	"github.com/tsuru/config"
 	tsuruErrors "github.com/tsuru/tsuru/errors"
 )
 
 var ErrUnauthorized = &tsuruErrors.HTTP{Code: http.StatusForbidden, Message: "You don't have permission to do this action"}
 var ErrTooManyTeams = &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: "You must provide a team to execute this action."}
 
+// HardIsolation reports whether tsuru is running in the hard multi-tenancy
+// isolation mode, where users never see resources, like services and teams,
+// that aren't available to their own teams.
+func HardIsolation() bool {
+	enabled, _ := config.GetBool("multi-tenancy:hard-isolation")
+	return enabled
+}
+
 type PermissionScheme struct {
 	name     string
 	parent   *PermissionScheme
@@ -10,14 +10,23 @@ package permission
 var (
 	PermAll                              = PermissionRegistry.get("")                                    // [global]
 	PermApp                              = PermissionRegistry.get("app")                                 // [global app team pool]
+	PermAppTemplate                      = PermissionRegistry.get("app-template")                        // [global]
+	PermAppTemplateDelete                = PermissionRegistry.get("app-template.delete")                 // [global]
+	PermAppTemplateRead                  = PermissionRegistry.get("app-template.read")                   // [global]
+	PermAppTemplateReadEvents            = PermissionRegistry.get("app-template.read.events")            // [global]
+	PermAppTemplateUpdate                = PermissionRegistry.get("app-template.update")                 // [global]
 	PermAppAdmin                         = PermissionRegistry.get("app.admin")                           // [global app team pool]
+	PermAppAdminDeployFreeze             = PermissionRegistry.get("app.admin.deploy-freeze")             // [global app team pool]
+	PermAppAdminEnvdrift                 = PermissionRegistry.get("app.admin.envdrift")                  // [global app team pool]
 	PermAppAdminQuota                    = PermissionRegistry.get("app.admin.quota")                     // [global app team pool]
+	PermAppAdminResources                = PermissionRegistry.get("app.admin.resources")                 // [global app team pool]
 	PermAppAdminRoutes                   = PermissionRegistry.get("app.admin.routes")                    // [global app team pool]
 	PermAppAdminUnlock                   = PermissionRegistry.get("app.admin.unlock")                    // [global app team pool]
 	PermAppBuild                         = PermissionRegistry.get("app.build")                           // [global app team pool]
 	PermAppCreate                        = PermissionRegistry.get("app.create")                          // [global team]
 	PermAppDelete                        = PermissionRegistry.get("app.delete")                          // [global app team pool]
 	PermAppDeploy                        = PermissionRegistry.get("app.deploy")                          // [global app team pool]
+	PermAppDeployApprove                 = PermissionRegistry.get("app.deploy.approve")                  // [global app team pool]
 	PermAppDeployArchiveUrl              = PermissionRegistry.get("app.deploy.archive-url")              // [global app team pool]
 	PermAppDeployBuild                   = PermissionRegistry.get("app.deploy.build")                    // [global app team pool]
 	PermAppDeployGit                     = PermissionRegistry.get("app.deploy.git")                      // [global app team pool]
@@ -28,7 +37,10 @@ var (
 	PermAppReadCertificate               = PermissionRegistry.get("app.read.certificate")                // [global app team pool]
 	PermAppReadDeploy                    = PermissionRegistry.get("app.read.deploy")                     // [global app team pool]
 	PermAppReadEnv                       = PermissionRegistry.get("app.read.env")                        // [global app team pool]
+	PermAppReadEnvPublic                 = PermissionRegistry.get("app.read.env.public")                 // [global app team pool]
 	PermAppReadEvents                    = PermissionRegistry.get("app.read.events")                     // [global app team pool]
+	PermAppReadInfo                      = PermissionRegistry.get("app.read.info")                       // [global app team pool]
+	PermAppReadJob                       = PermissionRegistry.get("app.read.job")                        // [global app team pool]
 	PermAppReadLog                       = PermissionRegistry.get("app.read.log")                        // [global app team pool]
 	PermAppReadMetric                    = PermissionRegistry.get("app.read.metric")                     // [global app team pool]
 	PermAppReadRouter                    = PermissionRegistry.get("app.read.router")                     // [global app team pool]
@@ -44,7 +56,10 @@ var (
 	PermAppUpdateCnameAdd                = PermissionRegistry.get("app.update.cname.add")                // [global app team pool]
 	PermAppUpdateCnameRemove             = PermissionRegistry.get("app.update.cname.remove")             // [global app team pool]
 	PermAppUpdateDeploy                  = PermissionRegistry.get("app.update.deploy")                   // [global app team pool]
+	PermAppUpdateDeployApproval          = PermissionRegistry.get("app.update.deploy.approval")          // [global app team pool]
+	PermAppUpdateDeployCancel            = PermissionRegistry.get("app.update.deploy.cancel")            // [global app team pool]
 	PermAppUpdateDeployRollback          = PermissionRegistry.get("app.update.deploy.rollback")          // [global app team pool]
+	PermAppUpdateDeployWebhook           = PermissionRegistry.get("app.update.deploy.webhook")           // [global app team pool]
 	PermAppUpdateDescription             = PermissionRegistry.get("app.update.description")              // [global app team pool]
 	PermAppUpdateEnv                     = PermissionRegistry.get("app.update.env")                      // [global app team pool]
 	PermAppUpdateEnvSet                  = PermissionRegistry.get("app.update.env.set")                  // [global app team pool]
@@ -52,17 +67,26 @@ var (
 	PermAppUpdateEvents                  = PermissionRegistry.get("app.update.events")                   // [global app team pool]
 	PermAppUpdateGrant                   = PermissionRegistry.get("app.update.grant")                    // [global app team pool]
 	PermAppUpdateImageReset              = PermissionRegistry.get("app.update.image-reset")              // [global app team pool]
+	PermAppUpdateJob                     = PermissionRegistry.get("app.update.job")                      // [global app team pool]
+	PermAppUpdateJobAdd                  = PermissionRegistry.get("app.update.job.add")                  // [global app team pool]
+	PermAppUpdateJobRemove               = PermissionRegistry.get("app.update.job.remove")               // [global app team pool]
+	PermAppUpdateJobRun                  = PermissionRegistry.get("app.update.job.run")                  // [global app team pool]
 	PermAppUpdateLog                     = PermissionRegistry.get("app.update.log")                      // [global app team pool]
+	PermAppUpdateLogDrain                = PermissionRegistry.get("app.update.log-drain")                // [global app team pool]
+	PermAppUpdateLogDrainAdd             = PermissionRegistry.get("app.update.log-drain.add")            // [global app team pool]
+	PermAppUpdateLogDrainRemove          = PermissionRegistry.get("app.update.log-drain.remove")         // [global app team pool]
 	PermAppUpdatePlan                    = PermissionRegistry.get("app.update.plan")                     // [global app team pool]
 	PermAppUpdatePlatform                = PermissionRegistry.get("app.update.platform")                 // [global app team pool]
 	PermAppUpdatePool                    = PermissionRegistry.get("app.update.pool")                     // [global app team pool]
 	PermAppUpdateRestart                 = PermissionRegistry.get("app.update.restart")                  // [global app team pool]
+	PermAppUpdateRestore                 = PermissionRegistry.get("app.update.restore")                  // [global app team pool]
 	PermAppUpdateRevoke                  = PermissionRegistry.get("app.update.revoke")                   // [global app team pool]
 	PermAppUpdateRouter                  = PermissionRegistry.get("app.update.router")                   // [global app team pool]
 	PermAppUpdateRouterAdd               = PermissionRegistry.get("app.update.router.add")               // [global app team pool]
 	PermAppUpdateRouterRemove            = PermissionRegistry.get("app.update.router.remove")            // [global app team pool]
 	PermAppUpdateRouterUpdate            = PermissionRegistry.get("app.update.router.update")            // [global app team pool]
 	PermAppUpdateSleep                   = PermissionRegistry.get("app.update.sleep")                    // [global app team pool]
+	PermAppUpdateSpread                  = PermissionRegistry.get("app.update.spread")                   // [global app team pool]
 	PermAppUpdateStart                   = PermissionRegistry.get("app.update.start")                    // [global app team pool]
 	PermAppUpdateStop                    = PermissionRegistry.get("app.update.stop")                     // [global app team pool]
 	PermAppUpdateSwap                    = PermissionRegistry.get("app.update.swap")                     // [global app team pool]
@@ -87,6 +111,15 @@ var (
 	PermEventBlockRead                   = PermissionRegistry.get("event-block.read")                    // [global]
 	PermEventBlockReadEvents             = PermissionRegistry.get("event-block.read.events")             // [global]
 	PermEventBlockRemove                 = PermissionRegistry.get("event-block.remove")                  // [global]
+	PermEventRetention                   = PermissionRegistry.get("event-retention")                     // [global]
+	PermEventRetentionPrune              = PermissionRegistry.get("event-retention.prune")               // [global]
+	PermEventRetentionRead               = PermissionRegistry.get("event-retention.read")                // [global]
+	PermEventRetentionReadEvents         = PermissionRegistry.get("event-retention.read.events")         // [global]
+	PermFeatureFlag                      = PermissionRegistry.get("feature-flag")                        // [global]
+	PermFeatureFlagDelete                = PermissionRegistry.get("feature-flag.delete")                 // [global]
+	PermFeatureFlagRead                  = PermissionRegistry.get("feature-flag.read")                   // [global]
+	PermFeatureFlagReadEvents            = PermissionRegistry.get("feature-flag.read.events")            // [global]
+	PermFeatureFlagUpdate                = PermissionRegistry.get("feature-flag.update")                 // [global]
 	PermHealing                          = PermissionRegistry.get("healing")                             // [global pool]
 	PermHealingDelete                    = PermissionRegistry.get("healing.delete")                      // [global pool]
 	PermHealingRead                      = PermissionRegistry.get("healing.read")                        // [global pool]
@@ -102,6 +135,14 @@ var (
 	PermMachineTemplateDelete            = PermissionRegistry.get("machine.template.delete")             // [global iaas]
 	PermMachineTemplateRead              = PermissionRegistry.get("machine.template.read")               // [global iaas]
 	PermMachineTemplateUpdate            = PermissionRegistry.get("machine.template.update")             // [global iaas]
+	PermMaintenance                      = PermissionRegistry.get("maintenance")                         // [global]
+	PermMaintenanceRead                  = PermissionRegistry.get("maintenance.read")                    // [global]
+	PermMaintenanceReadEvents            = PermissionRegistry.get("maintenance.read.events")             // [global]
+	PermMaintenanceUpdate                = PermissionRegistry.get("maintenance.update")                  // [global]
+	PermNaming                           = PermissionRegistry.get("naming")                              // [global]
+	PermNamingRead                       = PermissionRegistry.get("naming.read")                         // [global]
+	PermNamingReadEvents                 = PermissionRegistry.get("naming.read.events")                  // [global]
+	PermNamingUpdate                     = PermissionRegistry.get("naming.update")                       // [global]
 	PermNode                             = PermissionRegistry.get("node")                                // [global pool]
 	PermNodeAutoscale                    = PermissionRegistry.get("node.autoscale")                      // [global]
 	PermNodeAutoscaleDelete              = PermissionRegistry.get("node.autoscale.delete")               // [global]
@@ -116,12 +157,17 @@ var (
 	PermNodeUpdateMoveContainer          = PermissionRegistry.get("node.update.move.container")          // [global pool]
 	PermNodeUpdateMoveContainers         = PermissionRegistry.get("node.update.move.containers")         // [global pool]
 	PermNodeUpdateRebalance              = PermissionRegistry.get("node.update.rebalance")               // [global pool]
+	PermNodeUpdateScript                 = PermissionRegistry.get("node.update.script")                  // [global pool]
 	PermNodecontainer                    = PermissionRegistry.get("nodecontainer")                       // [global pool]
 	PermNodecontainerCreate              = PermissionRegistry.get("nodecontainer.create")                // [global pool]
 	PermNodecontainerDelete              = PermissionRegistry.get("nodecontainer.delete")                // [global pool]
 	PermNodecontainerRead                = PermissionRegistry.get("nodecontainer.read")                  // [global pool]
 	PermNodecontainerUpdate              = PermissionRegistry.get("nodecontainer.update")                // [global pool]
 	PermNodecontainerUpdateUpgrade       = PermissionRegistry.get("nodecontainer.update.upgrade")        // [global pool]
+	PermOrphan                           = PermissionRegistry.get("orphan")                              // [global]
+	PermOrphanCleanup                    = PermissionRegistry.get("orphan.cleanup")                      // [global]
+	PermOrphanRead                       = PermissionRegistry.get("orphan.read")                         // [global]
+	PermOrphanReadEvents                 = PermissionRegistry.get("orphan.read.events")                  // [global]
 	PermPlan                             = PermissionRegistry.get("plan")                                // [global]
 	PermPlanCreate                       = PermissionRegistry.get("plan.create")                         // [global]
 	PermPlanDelete                       = PermissionRegistry.get("plan.delete")                         // [global]
@@ -142,10 +188,28 @@ var (
 	PermPoolUpdate                       = PermissionRegistry.get("pool.update")                         // [global pool]
 	PermPoolUpdateConstraints            = PermissionRegistry.get("pool.update.constraints")             // [global pool]
 	PermPoolUpdateConstraintsSet         = PermissionRegistry.get("pool.update.constraints.set")         // [global pool]
+	PermPoolUpdateContaineroptions       = PermissionRegistry.get("pool.update.containeroptions")        // [global pool]
 	PermPoolUpdateLogs                   = PermissionRegistry.get("pool.update.logs")                    // [global pool]
 	PermPoolUpdateTeam                   = PermissionRegistry.get("pool.update.team")                    // [global pool]
 	PermPoolUpdateTeamAdd                = PermissionRegistry.get("pool.update.team.add")                // [global pool]
 	PermPoolUpdateTeamRemove             = PermissionRegistry.get("pool.update.team.remove")             // [global pool]
+	PermProject                          = PermissionRegistry.get("project")                             // [global team]
+	PermProjectCreate                    = PermissionRegistry.get("project.create")                      // [global team]
+	PermProjectDelete                    = PermissionRegistry.get("project.delete")                      // [global team]
+	PermProjectRead                      = PermissionRegistry.get("project.read")                        // [global team]
+	PermProjectReadEvents                = PermissionRegistry.get("project.read.events")                 // [global team]
+	PermProjectUpdate                    = PermissionRegistry.get("project.update")                      // [global team]
+	PermProjectUpdateApp                 = PermissionRegistry.get("project.update.app")                  // [global team]
+	PermProjectUpdateEnv                 = PermissionRegistry.get("project.update.env")                  // [global team]
+	PermProjectUpdateGrant               = PermissionRegistry.get("project.update.grant")                // [global team]
+	PermProjectUpdateRevoke              = PermissionRegistry.get("project.update.revoke")               // [global team]
+	PermProjectUpdateServiceInstance     = PermissionRegistry.get("project.update.service-instance")     // [global team]
+	PermQueue                            = PermissionRegistry.get("queue")                               // [global]
+	PermQueueRead                        = PermissionRegistry.get("queue.read")                          // [global]
+	PermQueueReadEvents                  = PermissionRegistry.get("queue.read.events")                   // [global]
+	PermQueueUpdate                      = PermissionRegistry.get("queue.update")                        // [global]
+	PermQueueUpdateDiscard               = PermissionRegistry.get("queue.update.discard")                // [global]
+	PermQueueUpdateRetry                 = PermissionRegistry.get("queue.update.retry")                  // [global]
 	PermRole                             = PermissionRegistry.get("role")                                // [global]
 	PermRoleCreate                       = PermissionRegistry.get("role.create")                         // [global]
 	PermRoleDefault                      = PermissionRegistry.get("role.default")                        // [global]
@@ -169,7 +233,9 @@ var (
 	PermServiceInstanceCreate            = PermissionRegistry.get("service-instance.create")             // [global team]
 	PermServiceInstanceDelete            = PermissionRegistry.get("service-instance.delete")             // [global service-instance team]
 	PermServiceInstanceRead              = PermissionRegistry.get("service-instance.read")               // [global service-instance team]
+	PermServiceInstanceReadConnection    = PermissionRegistry.get("service-instance.read.connection")    // [global service-instance team]
 	PermServiceInstanceReadEvents        = PermissionRegistry.get("service-instance.read.events")        // [global service-instance team]
+	PermServiceInstanceReadMetrics       = PermissionRegistry.get("service-instance.read.metrics")       // [global service-instance team]
 	PermServiceInstanceReadStatus        = PermissionRegistry.get("service-instance.read.status")        // [global service-instance team]
 	PermServiceInstanceUpdate            = PermissionRegistry.get("service-instance.update")             // [global service-instance team]
 	PermServiceInstanceUpdateBind        = PermissionRegistry.get("service-instance.update.bind")        // [global service-instance team]
@@ -178,6 +244,8 @@ var (
 	PermServiceInstanceUpdatePlan        = PermissionRegistry.get("service-instance.update.plan")        // [global service-instance team]
 	PermServiceInstanceUpdateProxy       = PermissionRegistry.get("service-instance.update.proxy")       // [global service-instance team]
 	PermServiceInstanceUpdateRevoke      = PermissionRegistry.get("service-instance.update.revoke")      // [global service-instance team]
+	PermServiceInstanceUpdateRotate      = PermissionRegistry.get("service-instance.update.rotate")      // [global service-instance team]
+	PermServiceInstanceUpdateStatus      = PermissionRegistry.get("service-instance.update.status")      // [global service-instance team]
 	PermServiceInstanceUpdateTags        = PermissionRegistry.get("service-instance.update.tags")        // [global service-instance team]
 	PermServiceInstanceUpdateTeamowner   = PermissionRegistry.get("service-instance.update.teamowner")   // [global service-instance team]
 	PermServiceInstanceUpdateUnbind      = PermissionRegistry.get("service-instance.update.unbind")      // [global service-instance team]
@@ -187,17 +255,30 @@ var (
 	PermServiceReadDoc                   = PermissionRegistry.get("service.read.doc")                    // [global service team]
 	PermServiceReadEvents                = PermissionRegistry.get("service.read.events")                 // [global service team]
 	PermServiceReadPlans                 = PermissionRegistry.get("service.read.plans")                  // [global service team]
+	PermServiceReadStatus                = PermissionRegistry.get("service.read.status")                 // [global service team]
 	PermServiceUpdate                    = PermissionRegistry.get("service.update")                      // [global service team]
 	PermServiceUpdateDoc                 = PermissionRegistry.get("service.update.doc")                  // [global service team]
 	PermServiceUpdateGrantAccess         = PermissionRegistry.get("service.update.grant-access")         // [global service team]
 	PermServiceUpdateProxy               = PermissionRegistry.get("service.update.proxy")                // [global service team]
 	PermServiceUpdateRevokeAccess        = PermissionRegistry.get("service.update.revoke-access")        // [global service team]
+	PermServiceUpdateTeams               = PermissionRegistry.get("service.update.teams")                // [global service team]
+	PermServiceUpdateWebhook             = PermissionRegistry.get("service.update.webhook")              // [global service team]
 	PermTeam                             = PermissionRegistry.get("team")                                // [global team]
 	PermTeamCreate                       = PermissionRegistry.get("team.create")                         // [global]
 	PermTeamDelete                       = PermissionRegistry.get("team.delete")                         // [global team]
+	PermTeamDeployFreeze                 = PermissionRegistry.get("team.deploy-freeze")                  // [global team]
+	PermTeamDeployFreezeDelete           = PermissionRegistry.get("team.deploy-freeze.delete")           // [global team]
+	PermTeamDeployFreezeRead             = PermissionRegistry.get("team.deploy-freeze.read")             // [global team]
+	PermTeamDeployFreezeUpdate           = PermissionRegistry.get("team.deploy-freeze.update")           // [global team]
+	PermTeamPolicy                       = PermissionRegistry.get("team.policy")                         // [global team]
+	PermTeamPolicyDelete                 = PermissionRegistry.get("team.policy.delete")                  // [global team]
+	PermTeamPolicyRead                   = PermissionRegistry.get("team.policy.read")                    // [global team]
+	PermTeamPolicyUpdate                 = PermissionRegistry.get("team.policy.update")                  // [global team]
 	PermTeamRead                         = PermissionRegistry.get("team.read")                           // [global team]
 	PermTeamReadEvents                   = PermissionRegistry.get("team.read.events")                    // [global team]
 	PermTeamUpdate                       = PermissionRegistry.get("team.update")                         // [global team]
+	PermUsage                            = PermissionRegistry.get("usage")                               // [global]
+	PermUsageRead                        = PermissionRegistry.get("usage.read")                          // [global]
 	PermUser                             = PermissionRegistry.get("user")                                // [global user]
 	PermUserCreate                       = PermissionRegistry.get("user.create")                         // [global]
 	PermUserDelete                       = PermissionRegistry.get("user.delete")                         // [global user]
@@ -14,6 +14,8 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 	"app.update.description",
 	"app.update.tags",
 	"app.update.log",
+	"app.update.log-drain.add",
+	"app.update.log-drain.remove",
 	"app.update.pool",
 	"app.update.unit.add",
 	"app.update.unit.remove",
@@ -25,6 +27,7 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 	"app.update.sleep",
 	"app.update.start",
 	"app.update.stop",
+	"app.update.restore",
 	"app.update.swap",
 	"app.update.grant",
 	"app.update.revoke",
@@ -36,15 +39,22 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 	"app.update.bind",
 	"app.update.bind-volume",
 	"app.update.image-reset",
+	"app.update.spread",
 	"app.update.events",
 	"app.update.unbind",
 	"app.update.unbind-volume",
 	"app.update.certificate.set",
 	"app.update.certificate.unset",
+	"app.update.deploy.cancel",
 	"app.update.deploy.rollback",
+	"app.update.deploy.webhook",
+	"app.update.deploy.approval",
 	"app.update.router.add",
 	"app.update.router.update",
 	"app.update.router.remove",
+	"app.update.job.add",
+	"app.update.job.remove",
+	"app.update.job.run",
 	"app.deploy",
 	"app.deploy.archive-url",
 	"app.deploy.build",
@@ -52,20 +62,27 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 	"app.deploy.image",
 	"app.deploy.rollback",
 	"app.deploy.upload",
+	"app.deploy.approve",
 	"app.read",
+	"app.read.info",
 	"app.read.deploy",
 	"app.read.router",
 	"app.read.env",
+	"app.read.env.public",
 	"app.read.events",
 	"app.read.metric",
 	"app.read.log",
 	"app.read.certificate",
+	"app.read.job",
 	"app.delete",
 	"app.run",
 	"app.run.shell",
 	"app.admin.unlock",
 	"app.admin.routes",
 	"app.admin.quota",
+	"app.admin.envdrift",
+	"app.admin.resources",
+	"app.admin.deploy-freeze",
 	"app.build",
 ).addWithCtx(
 	"node", []contextType{CtxPool},
@@ -75,6 +92,7 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 	"node.update.move.container",
 	"node.update.move.containers",
 	"node.update.rebalance",
+	"node.update.script",
 	"node.delete",
 ).addWithCtx(
 	"node.autoscale", []contextType{},
@@ -101,6 +119,12 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 	"team.read.events",
 	"team.delete",
 	"team.update",
+	"team.policy.read",
+	"team.policy.update",
+	"team.policy.delete",
+	"team.deploy-freeze.read",
+	"team.deploy-freeze.update",
+	"team.deploy-freeze.delete",
 ).addWithCtx(
 	"user", []contextType{CtxUser},
 ).addWithCtx(
@@ -121,11 +145,14 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 ).add(
 	"service.read.doc",
 	"service.read.plans",
+	"service.read.status",
 	"service.read.events",
 	"service.update.proxy",
 	"service.update.revoke-access",
 	"service.update.grant-access",
+	"service.update.teams",
 	"service.update.doc",
+	"service.update.webhook",
 	"service.delete",
 ).addWithCtx(
 	"service-instance", []contextType{CtxServiceInstance, CtxTeam},
@@ -134,6 +161,8 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 ).add(
 	"service-instance.read.events",
 	"service-instance.read.status",
+	"service-instance.read.connection",
+	"service-instance.read.metrics",
 	"service-instance.delete",
 	"service-instance.update.proxy",
 	"service-instance.update.bind",
@@ -144,6 +173,8 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 	"service-instance.update.tags",
 	"service-instance.update.teamowner",
 	"service-instance.update.plan",
+	"service-instance.update.rotate",
+	"service-instance.update.status",
 ).add(
 	"role.create",
 	"role.delete",
@@ -177,6 +208,7 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 	"pool.update.constraints.set",
 	"pool.read.constraints",
 	"pool.update.logs",
+	"pool.update.containeroptions",
 	"pool.delete",
 ).add(
 	"debug",
@@ -203,6 +235,52 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 	"event-block.read.events",
 	"event-block.add",
 	"event-block.remove",
+).add(
+	"event-retention.read",
+	"event-retention.read.events",
+	"event-retention.prune",
+).add(
+	"maintenance.read",
+	"maintenance.read.events",
+	"maintenance.update",
+).add(
+	"feature-flag.read",
+	"feature-flag.read.events",
+	"feature-flag.update",
+	"feature-flag.delete",
+).add(
+	"usage.read",
+).add(
+	"orphan.read",
+	"orphan.read.events",
+	"orphan.cleanup",
+).add(
+	"app-template.read",
+	"app-template.read.events",
+	"app-template.update",
+	"app-template.delete",
+).add(
+	"naming.read",
+	"naming.read.events",
+	"naming.update",
+).addWithCtx(
+	"project", []contextType{CtxTeam},
+).addWithCtx(
+	"project.create", []contextType{CtxTeam},
+).add(
+	"project.read",
+	"project.read.events",
+	"project.update.app",
+	"project.update.service-instance",
+	"project.update.env",
+	"project.update.grant",
+	"project.update.revoke",
+	"project.delete",
+).add(
+	"queue.read",
+	"queue.read.events",
+	"queue.update.retry",
+	"queue.update.discard",
 ).add(
 	"cluster.read.events",
 	"cluster.create",
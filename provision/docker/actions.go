@@ -18,7 +18,6 @@ import (
 	"github.com/tsuru/tsuru/action"
 	"github.com/tsuru/tsuru/app/image"
 	"github.com/tsuru/tsuru/event"
-	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/docker/container"
 	"github.com/tsuru/tsuru/provision/docker/types"
@@ -123,7 +122,7 @@ func checkCanceled(evt *event.Event) error {
 	}
 	canceled, err := evt.AckCancel()
 	if err != nil {
-		log.Errorf("unable to check if event should be canceled, ignoring: %s", err)
+		logger.Errorf("unable to check if event should be canceled, ignoring: %s", err)
 		return nil
 	}
 	if canceled {
@@ -163,7 +162,7 @@ var insertEmptyContainerInDB = action.Action{
 		coll := args.provisioner.Collection()
 		defer coll.Close()
 		if err := coll.Insert(cont); err != nil {
-			log.Errorf("error on inserting container into database %s - %s", cont.Name, err)
+			logger.Errorf("error on inserting container into database %s - %s", cont.Name, err)
 			return nil, err
 		}
 		return cont, nil
@@ -189,7 +188,7 @@ var updateContainerInDB = action.Action{
 		cont := ctx.Previous.(container.Container)
 		err := coll.Update(bson.M{"name": cont.Name}, cont)
 		if err != nil {
-			log.Errorf("error on updating container into database %s - %s", cont.ID, err)
+			logger.Errorf("error on updating container into database %s - %s", cont.ID, err)
 			return nil, err
 		}
 		return cont, nil
@@ -206,7 +205,7 @@ var createContainer = action.Action{
 			return nil, err
 		}
 		cont := ctx.Previous.(container.Container)
-		log.Debugf("create container for app %s, based on image %s", args.app.GetName(), args.imageID)
+		logger.Debugf("create container for app %s, based on image %s", args.app.GetName(), args.imageID)
 		var building bool
 		if args.buildingImage != "" {
 			building = true
@@ -222,7 +221,7 @@ var createContainer = action.Action{
 			Building:         building,
 		})
 		if err != nil {
-			log.Errorf("error on create container for app %s - %s", args.app.GetName(), err)
+			logger.Errorf("error on create container for app %s - %s", args.app.GetName(), err)
 			return nil, err
 		}
 		return cont, nil
@@ -232,7 +231,7 @@ var createContainer = action.Action{
 		args := ctx.Params[0].(runContainerActionsArgs)
 		err := args.provisioner.Cluster().RemoveContainer(docker.RemoveContainerOptions{ID: c.ID})
 		if err != nil {
-			log.Errorf("Failed to remove the container %q: %s", c.ID, err)
+			logger.Errorf("Failed to remove the container %q: %s", c.ID, err)
 		}
 	},
 }
@@ -249,7 +248,7 @@ var setContainerID = action.Action{
 		cont := ctx.Previous.(container.Container)
 		err := coll.Update(bson.M{"name": cont.Name}, bson.M{"$set": bson.M{"id": cont.ID}})
 		if err != nil {
-			log.Errorf("error on setting container ID %s - %s", cont.Name, err)
+			logger.Errorf("error on setting container ID %s - %s", cont.Name, err)
 			return nil, err
 		}
 		return cont, nil
@@ -299,14 +298,14 @@ var startContainer = action.Action{
 			return nil, err
 		}
 		c := ctx.Previous.(container.Container)
-		log.Debugf("starting container %s", c.ID)
+		logger.Debugf("starting container %s", c.ID)
 		err := c.Start(&container.StartArgs{
 			Provisioner: args.provisioner,
 			App:         args.app,
 			Deploy:      args.isDeploy,
 		})
 		if err != nil {
-			log.Errorf("error on start container %s - %s", c.ID, err)
+			logger.Errorf("error on start container %s - %s", c.ID, err)
 			return nil, err
 		}
 		return c, nil
@@ -316,7 +315,7 @@ var startContainer = action.Action{
 		args := ctx.Params[0].(runContainerActionsArgs)
 		err := args.provisioner.Cluster().StopContainer(c.ID, 10)
 		if err != nil {
-			log.Errorf("Failed to stop the container %q: %s", c.ID, err)
+			logger.Errorf("Failed to stop the container %q: %s", c.ID, err)
 		}
 	},
 }
@@ -353,7 +352,7 @@ var provisionAddUnitsToHost = action.Action{
 		runInContainers(containers, func(cont *container.Container, _ chan *container.Container) error {
 			err := cont.Remove(args.provisioner)
 			if err != nil {
-				log.Errorf("Error removing added container %s: %s", cont.ID, err)
+				logger.Errorf("Error removing added container %s: %s", cont.ID, err)
 				return nil
 			}
 			fmt.Fprintf(w, " ---> Destroyed unit %s [%s]\n", cont.ShortID(), cont.ProcessName)
@@ -373,7 +372,7 @@ var bindAndHealthcheck = action.Action{
 		}
 		webProcessName, err := image.GetImageWebProcessName(args.imageID)
 		if err != nil {
-			log.Errorf("[WARNING] cannot get the name of the web process: %s", err)
+			logger.Errorf("[WARNING] cannot get the name of the web process: %s", err)
 		}
 		newContainers := ctx.Previous.([]container.Container)
 		writer := args.writer
@@ -411,7 +410,7 @@ var bindAndHealthcheck = action.Action{
 			unit := c.AsUnit(args.app)
 			err := args.app.UnbindUnit(&unit)
 			if err != nil {
-				log.Errorf("Unable to unbind unit %q: %s", c.ID, err)
+				logger.Errorf("Unable to unbind unit %q: %s", c.ID, err)
 			}
 		}, true)
 	},
@@ -428,7 +427,7 @@ var bindAndHealthcheck = action.Action{
 			unit := c.AsUnit(args.app)
 			err := args.app.UnbindUnit(&unit)
 			if err != nil {
-				log.Errorf("Removed binding for unit %q: %s", c.ID, err)
+				logger.Errorf("Removed binding for unit %q: %s", c.ID, err)
 				return nil
 			}
 			fmt.Fprintf(w, " ---> Removed bind for unit %s [%s]\n", c.ShortID(), c.ProcessName)
@@ -449,7 +448,7 @@ func runInRouters(app provision.App, fn inRouterFn, rollback inRouterFn) (err er
 		for _, r := range toRollback {
 			rollbackErr := rollback(r)
 			if rollbackErr != nil {
-				log.Errorf("Unable to rollback router change in %q: %s", r.GetName(), rollbackErr)
+				logger.Errorf("Unable to rollback router change in %q: %s", r.GetName(), rollbackErr)
 			}
 		}
 	}()
@@ -476,7 +475,7 @@ var addNewRoutes = action.Action{
 		}
 		webProcessName, err := image.GetImageWebProcessName(args.imageID)
 		if err != nil {
-			log.Errorf("[WARNING] cannot get the name of the web process: %s", err)
+			logger.Errorf("[WARNING] cannot get the name of the web process: %s", err)
 		}
 		newContainers := ctx.Previous.([]container.Container)
 		writer := args.writer
@@ -535,7 +534,7 @@ var addNewRoutes = action.Action{
 			return r.RemoveRoutes(args.app.GetName(), routesToRemove)
 		}, nil)
 		if err != nil {
-			log.Errorf("[add-new-routes:Backward] Error removing route for [%v]: %s", routesToRemove, err)
+			logger.Errorf("[add-new-routes:Backward] Error removing route for [%v]: %s", routesToRemove, err)
 			return
 		}
 		for _, c := range newContainers {
@@ -602,7 +601,7 @@ var setRouterHealthcheck = action.Action{
 		currentImageName, _ := image.AppCurrentImageName(args.app.GetName())
 		yamlData, err := image.GetImageTsuruYamlData(currentImageName)
 		if err != nil {
-			log.Errorf("[set-router-healthcheck:Backward] Error getting yaml data: %s", err)
+			logger.Errorf("[set-router-healthcheck:Backward] Error getting yaml data: %s", err)
 		}
 		hcData := yamlData.Healthcheck.ToRouterHC()
 		err = runInRouters(args.app, func(r router.Router) error {
@@ -613,7 +612,7 @@ var setRouterHealthcheck = action.Action{
 			return hcRouter.SetHealthcheck(args.app.GetName(), hcData)
 		}, nil)
 		if err != nil {
-			log.Errorf("[set-router-healthcheck:Backward] Error setting healthcheck: %s", err)
+			logger.Errorf("[set-router-healthcheck:Backward] Error setting healthcheck: %s", err)
 		}
 	},
 }
@@ -629,7 +628,7 @@ var removeOldRoutes = action.Action{
 		if args.appDestroy {
 			defer func() {
 				if err != nil {
-					log.Errorf("ignored error during remove routes in app destroy: %s", err)
+					logger.Errorf("ignored error during remove routes in app destroy: %s", err)
 				}
 				err = nil
 			}()
@@ -647,7 +646,7 @@ var removeOldRoutes = action.Action{
 		}
 		webProcessName, err := image.GetImageWebProcessName(currentImageName)
 		if err != nil {
-			log.Errorf("[WARNING] cannot get the name of the web process for route removal: %s", err)
+			logger.Errorf("[WARNING] cannot get the name of the web process for route removal: %s", err)
 		}
 		var routesToRemove []*url.URL
 		for i, c := range args.toRemove {
@@ -700,7 +699,7 @@ var removeOldRoutes = action.Action{
 			return r.AddRoutes(args.app.GetName(), routesToAdd)
 		}, nil)
 		if err != nil {
-			log.Errorf("[remove-old-routes:Backward] Error adding back route for [%v]: %s", routesToAdd, err)
+			logger.Errorf("[remove-old-routes:Backward] Error adding back route for [%v]: %s", routesToAdd, err)
 			return
 		}
 		for _, c := range args.toRemove {
@@ -726,7 +725,7 @@ var provisionRemoveOldUnits = action.Action{
 		runInContainers(args.toRemove, func(c *container.Container, toRollback chan *container.Container) error {
 			err := c.Remove(args.provisioner)
 			if err != nil {
-				log.Errorf("Ignored error trying to remove old container %q: %s", c.ID, err)
+				logger.Errorf("Ignored error trying to remove old container %q: %s", c.ID, err)
 			}
 			fmt.Fprintf(writer, " ---> Removed old unit %s [%s]\n", c.ShortID(), c.ProcessName)
 			return nil
@@ -753,7 +752,7 @@ var provisionUnbindOldUnits = action.Action{
 			unit := c.AsUnit(args.app)
 			err := args.app.UnbindUnit(&unit)
 			if err != nil {
-				log.Errorf("Ignored error trying to unbind old container %q: %s", c.ID, err)
+				logger.Errorf("Ignored error trying to unbind old container %q: %s", c.ID, err)
 			}
 			fmt.Fprintf(writer, " ---> Removed bind for old unit %s [%s]\n", c.ShortID(), c.ProcessName)
 			return nil
@@ -813,7 +812,7 @@ var followLogsAndCommit = action.Action{
 		case result := <-resultCh:
 			doneCh <- true
 			if result.err != nil {
-				log.Errorf("error on get logs for container %s - %s", c.ID, result.err)
+				logger.Errorf("error on get logs for container %s - %s", c.ID, result.err)
 				return nil, result.err
 			}
 			if result.status != 0 {
@@ -823,7 +822,7 @@ var followLogsAndCommit = action.Action{
 		fmt.Fprintf(args.writer, "\n---- Deploying application image ----\n")
 		imageID, err := c.Commit(args.provisioner, args.writer)
 		if err != nil {
-			log.Errorf("error on commit container %s - %s", c.ID, err)
+			logger.Errorf("error on commit container %s - %s", c.ID, err)
 			return nil, err
 		}
 		fmt.Fprintf(args.writer, " ---> Cleaning up\n")
@@ -852,14 +851,14 @@ var updateAppImage = action.Action{
 		imgHistorySize := image.ImageHistorySize()
 		allImages, err := image.ListAppImages(args.app.GetName())
 		if err != nil {
-			log.Errorf("Couldn't list images for cleaning: %s", err)
+			logger.Errorf("Couldn't list images for cleaning: %s", err)
 			return ctx.Previous, nil
 		}
 		for i, imgName := range allImages {
 			if i > len(allImages)-imgHistorySize-1 {
 				err := args.provisioner.Cluster().RemoveImageIgnoreLast(imgName)
 				if err != nil {
-					log.Debugf("Ignored error removing old image %q: %s", imgName, err.Error())
+					logger.Debugf("Ignored error removing old image %q: %s", imgName, err.Error())
 				}
 				continue
 			}
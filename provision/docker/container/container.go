@@ -42,6 +42,7 @@ type SchedulerOpts struct {
 	AppName       string
 	ProcessName   string
 	UpdateName    bool
+	LocalImage    string
 	ActionLimiter provision.ActionLimiter
 	LimiterDone   func()
 }
@@ -102,10 +103,15 @@ func (c *Container) Create(args *CreateArgs) error {
 	if args.Building {
 		user, _ = dockercommon.UserForContainer()
 	}
+	containerOpts, err := LoadContainerOptions(args.App.GetPool())
+	if err != nil {
+		return err
+	}
 	hostConf, err := c.hostConfig(args.App, args.Deploy)
 	if err != nil {
 		return err
 	}
+	hostConf.Ulimits = containerOpts.Ulimits
 	labelSet, err := provision.ProcessLabels(provision.ProcessLabelsOpts{
 		App:         args.App,
 		Process:     c.ProcessName,
@@ -140,10 +146,18 @@ func (c *Container) Create(args *CreateArgs) error {
 		}
 		nodeList = []string{node.Address}
 	}
+	var localImage string
+	if containerOpts.PullPolicy == PullPolicyIfNotPresent {
+		localImage = args.ImageID
+		if len(nodeList) > 0 {
+			UseLocalImage(args.Provisioner.Cluster(), &opts, localImage, nodeList[0])
+		}
+	}
 	schedulerOpts := &SchedulerOpts{
 		AppName:       args.App.GetName(),
 		ProcessName:   args.ProcessName,
 		UpdateName:    true,
+		LocalImage:    localImage,
 		ActionLimiter: args.Provisioner.ActionLimiter(),
 	}
 	addr, cont, err := args.Provisioner.Cluster().CreateContainerSchedulerOpts(opts, schedulerOpts, net.StreamInactivityTimeout, nodeList...)
@@ -239,6 +253,47 @@ func (c *Container) SetImage(p DockerProvisioner, imageID string) error {
 	return coll.Update(bson.M{"id": c.ID}, c)
 }
 
+// SetResources updates the memory and CPU share limits of the running
+// container, overriding the ones from the app plan. Zero resources set the
+// limits back to the plan. The override is kept in the database, so it's
+// shown while the container lives.
+func (c *Container) SetResources(p DockerProvisioner, app provision.App, r provision.UnitResources) error {
+	memory := r.Memory
+	if memory == 0 {
+		memory = app.GetMemory()
+	}
+	cpuShare := r.CpuShare
+	if cpuShare == 0 {
+		cpuShare = app.GetCpuShare()
+	}
+	opts := docker.UpdateContainerOptions{CPUShares: cpuShare}
+	if memory > 0 {
+		opts.Memory = int(memory)
+		opts.MemorySwap = int(memory + app.GetSwap())
+	}
+	node, err := p.GetNodeByHost(c.HostAddr)
+	if err != nil {
+		return err
+	}
+	client, err := node.Client()
+	if err != nil {
+		return err
+	}
+	err = client.UpdateContainer(c.ID, opts)
+	if err != nil {
+		return err
+	}
+	update := bson.M{"$unset": bson.M{"resourceoverride": ""}}
+	c.ResourceOverride = nil
+	if !r.IsZero() {
+		update = bson.M{"$set": bson.M{"resourceoverride": r}}
+		c.ResourceOverride = &r
+	}
+	coll := p.Collection()
+	defer coll.Close()
+	return coll.Update(bson.M{"id": c.ID}, update)
+}
+
 func (c *Container) Remove(p DockerProvisioner) error {
 	log.Debugf("Removing container %s from docker", c.ID)
 	err := c.Stop(p)
@@ -264,6 +319,7 @@ type Pty struct {
 	Width  int
 	Height int
 	Term   string
+	Resize <-chan provision.TerminalSize
 }
 
 func (c *Container) Shell(p DockerProvisioner, stdin io.Reader, stdout, stderr io.Writer, pty Pty) error {
@@ -304,7 +360,19 @@ func (c *Container) Shell(p DockerProvisioner, stdin io.Reader, stdout, stderr i
 		return err
 	}
 	p.Cluster().ResizeExecTTY(exec.ID, c.ID, pty.Height, pty.Width)
-	return <-errs
+	resize := pty.Resize
+	for {
+		select {
+		case err = <-errs:
+			return err
+		case size, ok := <-resize:
+			if !ok {
+				resize = nil
+				continue
+			}
+			p.Cluster().ResizeExecTTY(exec.ID, c.ID, size.Height, size.Width)
+		}
+	}
 }
 
 type execErr struct {
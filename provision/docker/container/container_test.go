@@ -284,6 +284,46 @@ func (s *S) TestContainerCreateSecurityOptions(c *check.C) {
 	c.Assert(container.Config.SecurityOpts, check.DeepEquals, []string{"label:type:svirt_apache", "ptrace peer=@unsecure"})
 }
 
+func (s *S) TestContainerCreateUlimits(c *check.C) {
+	s.server.CustomHandler("/images/.*/json", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := docker.Image{
+			Config: &docker.Config{
+				ExposedPorts: map[docker.Port]struct{}{},
+			},
+		}
+		j, _ := json.Marshal(response)
+		w.Write(j)
+	}))
+	opts := ContainerOptions{ContainerOptions: types.ContainerOptions{
+		Ulimits: []docker.ULimit{{Name: "nofile", Soft: 1024, Hard: 2048}},
+	}}
+	err := opts.Save("")
+	c.Assert(err, check.IsNil)
+	app := provisiontest.NewFakeApp("app-name", "brainfuck", 1)
+	routertest.FakeRouter.AddBackend(app.GetName())
+	defer routertest.FakeRouter.RemoveBackend(app.GetName())
+	img := "tsuru/brainfuck:latest"
+	s.p.Cluster().PullImage(docker.PullImageOptions{Repository: img}, docker.AuthConfiguration{})
+	cont := Container{Container: types.Container{
+		Name:    "myName",
+		AppName: app.GetName(),
+		Type:    app.GetPlatform(),
+		Status:  "created",
+	}}
+	err = cont.Create(&CreateArgs{
+		App:         app,
+		ImageID:     img,
+		Commands:    []string{"docker", "run"},
+		Provisioner: s.p,
+	})
+	c.Assert(err, check.IsNil)
+	defer s.removeTestContainer(&cont)
+	dcli, _ := docker.NewClient(s.server.URL())
+	container, err := dcli.InspectContainer(cont.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(container.HostConfig.Ulimits, check.DeepEquals, []docker.ULimit{{Name: "nofile", Soft: 1024, Hard: 2048}})
+}
+
 func (s *S) TestContainerCreateForDeploy(c *check.C) {
 	s.server.CustomHandler("/images/.*/json", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		response := docker.Image{
@@ -0,0 +1,118 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package container
+
+import (
+	"github.com/fsouza/go-dockerclient"
+	"github.com/pkg/errors"
+	"github.com/tsuru/docker-cluster/cluster"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/provision/docker/types"
+	"github.com/tsuru/tsuru/scopedconfig"
+)
+
+var (
+	ErrInvalidPullPolicy   = errors.New("invalid pull-policy, it must be one of: always, if-not-present")
+	ErrUlimitNameMandatory = errors.New("ulimit name is mandatory")
+	ErrInvalidUlimit       = errors.New("ulimit soft limit must not be greater than the hard limit")
+)
+
+const (
+	PullPolicyAlways       = "always"
+	PullPolicyIfNotPresent = "if-not-present"
+
+	containerOptionsCollection = "container-options"
+)
+
+type ContainerOptions struct {
+	types.ContainerOptions
+}
+
+func loadContainerOptionsConfig() *scopedconfig.ScopedConfig {
+	conf := scopedconfig.FindScopedConfig(containerOptionsCollection)
+	conf.ShallowMerge = true
+	return conf
+}
+
+// LoadContainerOptions returns the options used when creating the containers
+// of apps in the given pool, merged with the default options.
+func LoadContainerOptions(pool string) (types.ContainerOptions, error) {
+	conf := loadContainerOptionsConfig()
+	var opts types.ContainerOptions
+	err := conf.Load(pool, &opts)
+	if err != nil {
+		return opts, err
+	}
+	if opts.PullPolicy == "" {
+		opts.PullPolicy = PullPolicyAlways
+	}
+	return opts, nil
+}
+
+func ContainerOptionsLoadAll() (map[string]ContainerOptions, error) {
+	conf := loadContainerOptionsConfig()
+	var all map[string]types.ContainerOptions
+	err := conf.LoadAll(&all)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string]ContainerOptions, len(all))
+	for k, v := range all {
+		ret[k] = ContainerOptions{ContainerOptions: v}
+	}
+	return ret, nil
+}
+
+func (opts *ContainerOptions) validate() error {
+	switch opts.PullPolicy {
+	case "", PullPolicyAlways, PullPolicyIfNotPresent:
+	default:
+		return ErrInvalidPullPolicy
+	}
+	for _, ulimit := range opts.Ulimits {
+		if ulimit.Name == "" {
+			return ErrUlimitNameMandatory
+		}
+		if ulimit.Hard != 0 && ulimit.Soft > ulimit.Hard {
+			return ErrInvalidUlimit
+		}
+	}
+	return nil
+}
+
+func (opts *ContainerOptions) Save(pool string) error {
+	conf := loadContainerOptionsConfig()
+	err := opts.validate()
+	if err != nil {
+		return err
+	}
+	return conf.Save(pool, opts.ContainerOptions)
+}
+
+// UseLocalImage sets the image in the create options to the ID of the given
+// image when it's already present in the node, so the cluster doesn't pull it
+// from the registry again. Otherwise the image name is used and pulled as
+// usual.
+func UseLocalImage(c *cluster.Cluster, opts *docker.CreateContainerOptions, image, nodeAddr string) {
+	opts.Config.Image = image
+	node, err := c.GetNode(nodeAddr)
+	if err != nil {
+		log.Errorf("unable to find node %q to look for image %q: %s", nodeAddr, image, err)
+		return
+	}
+	client, err := node.Client()
+	if err != nil {
+		log.Errorf("unable to connect to node %q to look for image %q: %s", nodeAddr, image, err)
+		return
+	}
+	img, err := client.InspectImage(image)
+	if err != nil {
+		if err != docker.ErrNoSuchImage {
+			log.Errorf("unable to inspect image %q in node %q: %s", image, nodeAddr, err)
+		}
+		return
+	}
+	opts.Config.Image = img.ID
+}
@@ -0,0 +1,73 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package container
+
+import (
+	"github.com/fsouza/go-dockerclient"
+	"github.com/tsuru/tsuru/provision/docker/types"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestContainerOptionsSave(c *check.C) {
+	opts := ContainerOptions{ContainerOptions: types.ContainerOptions{
+		Ulimits: []docker.ULimit{{Name: "nofile", Soft: 1024, Hard: 2048}},
+	}}
+	err := opts.Save("")
+	c.Assert(err, check.IsNil)
+	opts = ContainerOptions{ContainerOptions: types.ContainerOptions{PullPolicy: PullPolicyIfNotPresent}}
+	err = opts.Save("p1")
+	c.Assert(err, check.IsNil)
+	all, err := ContainerOptionsLoadAll()
+	c.Assert(err, check.IsNil)
+	c.Assert(all, check.DeepEquals, map[string]ContainerOptions{
+		"":   {ContainerOptions: types.ContainerOptions{Ulimits: []docker.ULimit{{Name: "nofile", Soft: 1024, Hard: 2048}}}},
+		"p1": {ContainerOptions: types.ContainerOptions{PullPolicy: PullPolicyIfNotPresent}},
+	})
+	poolOpts, err := LoadContainerOptions("p1")
+	c.Assert(err, check.IsNil)
+	c.Assert(poolOpts, check.DeepEquals, types.ContainerOptions{
+		PullPolicy: PullPolicyIfNotPresent,
+		Ulimits:    []docker.ULimit{{Name: "nofile", Soft: 1024, Hard: 2048}},
+	})
+	defaultOpts, err := LoadContainerOptions("p2")
+	c.Assert(err, check.IsNil)
+	c.Assert(defaultOpts, check.DeepEquals, types.ContainerOptions{
+		PullPolicy: PullPolicyAlways,
+		Ulimits:    []docker.ULimit{{Name: "nofile", Soft: 1024, Hard: 2048}},
+	})
+}
+
+func (s *S) TestContainerOptionsSaveInvalid(c *check.C) {
+	testCases := []struct {
+		opts types.ContainerOptions
+		err  error
+	}{
+		{types.ContainerOptions{PullPolicy: "never"}, ErrInvalidPullPolicy},
+		{types.ContainerOptions{Ulimits: []docker.ULimit{{Soft: 10}}}, ErrUlimitNameMandatory},
+		{types.ContainerOptions{Ulimits: []docker.ULimit{{Name: "nproc", Soft: 20, Hard: 10}}}, ErrInvalidUlimit},
+	}
+	for _, tt := range testCases {
+		opts := ContainerOptions{ContainerOptions: tt.opts}
+		c.Check(opts.Save(""), check.Equals, tt.err)
+	}
+	all, err := ContainerOptionsLoadAll()
+	c.Assert(err, check.IsNil)
+	c.Assert(all, check.HasLen, 0)
+}
+
+func (s *S) TestUseLocalImage(c *check.C) {
+	img := "tsuru/python:latest"
+	err := s.p.Cluster().PullImage(docker.PullImageOptions{Repository: img}, docker.AuthConfiguration{})
+	c.Assert(err, check.IsNil)
+	dcli, err := docker.NewClient(s.server.URL())
+	c.Assert(err, check.IsNil)
+	localImg, err := dcli.InspectImage(img)
+	c.Assert(err, check.IsNil)
+	opts := docker.CreateContainerOptions{Config: &docker.Config{Image: "other"}}
+	UseLocalImage(s.p.Cluster(), &opts, img, s.server.URL())
+	c.Assert(opts.Config.Image, check.Equals, localImg.ID)
+	UseLocalImage(s.p.Cluster(), &opts, "localhost:5000/tsuru/notfound", s.server.URL())
+	c.Assert(opts.Config.Image, check.Equals, "localhost:5000/tsuru/notfound")
+}
@@ -19,7 +19,6 @@ import (
 	"github.com/tsuru/tsuru/app/image"
 	tsuruErrors "github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/event"
-	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/net"
 	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/docker/container"
@@ -72,7 +71,7 @@ func (p *dockerProvisioner) HandleMoveErrors(moveErrors chan error, writer io.Wr
 	for err := range moveErrors {
 		multiErr.Add(err)
 		err = errors.Wrap(err, "Error moving container")
-		log.Error(err)
+		logger.Error(err)
 		fmt.Fprintf(writer, "%s\n", err)
 	}
 	if multiErr.Len() > 0 {
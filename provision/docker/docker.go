@@ -95,7 +95,7 @@ func (p *dockerProvisioner) deployPipeline(app provision.App, imageID string, co
 	}
 	err = pipeline.Execute(args)
 	if err != nil {
-		log.Errorf("error on execute deploy pipeline for app %s - %s", app.GetName(), err)
+		logger.Errorf("error on execute deploy pipeline for app %s - %s", app.GetName(), err)
 		return "", err
 	}
 	return deployImage, nil
@@ -161,7 +161,7 @@ func (p *dockerProvisioner) PushImage(name, tag string) error {
 		}
 		err = p.Cluster().PushImage(pushOpts, dockercommon.RegistryAuthConfig())
 		if err != nil {
-			log.Errorf("[docker] Failed to push image %q (%s): %s", name, err, buf.String())
+			logger.Errorf("[docker] Failed to push image %q (%s): %s", name, err, buf.String())
 			return err
 		}
 	}
@@ -235,7 +235,7 @@ func (c *dbAwareClient) CreateContainer(opts docker.CreateContainerOptions) (*do
 	createErr := func() {
 		dbErr := coll.Remove(bson.M{"name": dbCont.Name})
 		if dbErr != nil {
-			log.Errorf("error trying to remove container in db after failure %#v: %v", cont, dbErr)
+			logger.Errorf("error trying to remove container in db after failure %#v: %v", cont, dbErr)
 		}
 	}
 	updateErr := func() {
@@ -246,7 +246,7 @@ func (c *dbAwareClient) CreateContainer(opts docker.CreateContainerOptions) (*do
 			Force:         true,
 		})
 		if removeErr != nil {
-			log.Errorf("error trying to remove container in docker after update failure %#v: %v", cont, removeErr)
+			logger.Errorf("error trying to remove container in docker after update failure %#v: %v", cont, removeErr)
 		}
 	}
 	err := coll.Insert(dbCont)
@@ -272,7 +272,7 @@ func (c *dbAwareClient) RemoveContainer(opts docker.RemoveContainerOptions) erro
 	defer coll.Close()
 	dbErr := coll.Remove(bson.M{"id": opts.ID})
 	if dbErr != nil && dbErr != mgo.ErrNotFound {
-		log.Errorf("error trying to remove container in db %q: %v", opts.ID, dbErr)
+		logger.Errorf("error trying to remove container in db %q: %v", opts.ID, dbErr)
 	}
 	return err
 }
@@ -5,7 +5,6 @@
 package docker
 
 import (
-	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/provision/docker/container"
 	"github.com/tsuru/tsuru/router/rebuild"
 	"gopkg.in/mgo.v2/bson"
@@ -20,7 +19,7 @@ func (p *dockerProvisioner) checkContainer(container *container.Container) error
 		if info.HTTPHostPort != container.HostPort || info.IP != container.IP {
 			err = p.fixContainer(container, info)
 			if err != nil {
-				log.Errorf("error on fix container hostport for [container %s]", container.ID)
+				logger.Errorf("error on fix container hostport for [container %s]", container.ID)
 				return err
 			}
 		}
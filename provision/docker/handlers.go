@@ -40,6 +40,10 @@ func init() {
 	api.RegisterHandler("/docker/bs", "GET", api.AuthorizationRequiredHandler(bsConfigGetHandler))
 	api.RegisterHandler("/docker/logs", "GET", api.AuthorizationRequiredHandler(logsConfigGetHandler))
 	api.RegisterHandler("/docker/logs", "POST", api.AuthorizationRequiredHandler(logsConfigSetHandler))
+	api.RegisterHandler("/docker/container-options", "GET", api.AuthorizationRequiredHandler(containerOptionsGetHandler))
+	api.RegisterHandler("/docker/container-options", "POST", api.AuthorizationRequiredHandler(containerOptionsSetHandler))
+	api.RegisterHandler("/docker/node/scripts", "GET", api.AuthorizationRequiredHandler(nodeScriptListHandler))
+	api.RegisterHandler("/docker/node/scripts/{name}/run", "POST", api.AuthorizationRequiredHandler(nodeScriptRunHandler))
 }
 
 // title: move container
@@ -180,6 +184,118 @@ func moveContainersPermissionContexts(from, to string) ([]permission.PermissionC
 	return permContexts, nil
 }
 
+// title: node script list
+// path: /docker/node/scripts
+// method: GET
+// produce: application/json
+// responses:
+//   200: Ok
+//   204: No content
+//   401: Unauthorized
+func nodeScriptListHandler(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	if !permission.Check(t, permission.PermNodeUpdateScript) {
+		pools, err := permission.ListContextValues(t, permission.PermNodeUpdateScript, true)
+		if err != nil {
+			return err
+		}
+		if len(pools) == 0 {
+			return permission.ErrUnauthorized
+		}
+	}
+	scripts, err := NodeScripts()
+	if err != nil {
+		return err
+	}
+	if len(scripts) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(scripts)
+}
+
+// title: node script run
+// path: /docker/node/scripts/{name}/run
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/x-json-stream
+// responses:
+//   200: Ok
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Not found
+func nodeScriptRunHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	err = r.ParseForm()
+	if err != nil {
+		return &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	var opts RunNodeScriptOptions
+	dec := form.NewDecoder(nil)
+	dec.IgnoreUnknownKeys(true)
+	dec.IgnoreCase(true)
+	err = dec.DecodeValues(&opts, r.Form)
+	if err != nil {
+		return &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	if opts.Concurrency > maxNodeScriptConcurrency {
+		return &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: fmt.Sprintf("concurrency must be at most %d", maxNodeScriptConcurrency)}
+	}
+	opts.Script = r.URL.Query().Get(":name")
+	if pool, ok := opts.Metadata[provision.PoolMetadataName]; ok {
+		delete(opts.Metadata, provision.PoolMetadataName)
+		opts.Pool = pool
+	}
+	var permContexts []permission.PermissionContext
+	evtTarget := event.Target{Type: event.TargetTypeGlobal}
+	if opts.Pool != "" {
+		permContexts = append(permContexts, permission.Context(permission.CtxPool, opts.Pool))
+		evtTarget = event.Target{Type: event.TargetTypePool, Value: opts.Pool}
+	}
+	if !permission.Check(t, permission.PermNodeUpdateScript, permContexts...) {
+		return permission.ErrUnauthorized
+	}
+	_, err = getNodeScript(opts.Script)
+	if err == ErrNodeScriptNotFound {
+		return &tsuruErrors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:      evtTarget,
+		Kind:        permission.PermNodeUpdateScript,
+		Owner:       t,
+		CustomData:  event.FormToCustomData(r.Form),
+		DisableLock: true,
+		Allowed:     event.Allowed(permission.PermPoolReadEvents, permContexts...),
+	})
+	if err != nil {
+		return err
+	}
+	var results []NodeScriptResult
+	defer func() { evt.DoneCustomData(err, results) }()
+	w.Header().Set("Content-Type", "application/x-json-stream")
+	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 15*time.Second, "")
+	defer keepAliveWriter.Stop()
+	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	opts.Writer = writer
+	results, err = mainDockerProvisioner.RunNodeScript(opts)
+	if err != nil {
+		return err
+	}
+	var failed int
+	for _, result := range results {
+		if result.Error != "" || result.ExitCode != 0 {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return errors.Errorf("script %q failed in %d of %d nodes", opts.Script, failed, len(results))
+	}
+	fmt.Fprintf(writer, "Script %q successfully run in %d nodes.\n", opts.Script, len(results))
+	return nil
+}
+
 func bsEnvSetHandler(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	return errors.New("this route is deprecated, please use POST /docker/nodecontainer/{name} (node-container-update command)")
 }
@@ -289,6 +405,107 @@ func logsConfigSetHandler(w http.ResponseWriter, r *http.Request, t auth.Token)
 	return nil
 }
 
+// title: container options
+// path: /docker/container-options
+// method: GET
+// produce: application/json
+// responses:
+//   200: Ok
+//   401: Unauthorized
+func containerOptionsGetHandler(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	pools, err := permission.ListContextValues(t, permission.PermPoolUpdateContaineroptions, true)
+	if err != nil {
+		return err
+	}
+	configEntries, err := container.ContainerOptionsLoadAll()
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if len(pools) == 0 {
+		return json.NewEncoder(w).Encode(configEntries)
+	}
+	newMap := map[string]container.ContainerOptions{}
+	for _, p := range pools {
+		if entry, ok := configEntries[p]; ok {
+			newMap[p] = entry
+		}
+	}
+	return json.NewEncoder(w).Encode(newMap)
+}
+
+// title: container options set
+// path: /docker/container-options
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/x-json-stream
+// responses:
+//   200: Ok
+//   400: Invalid data
+//   401: Unauthorized
+func containerOptionsSetHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	err = r.ParseForm()
+	if err != nil {
+		return &tsuruErrors.HTTP{
+			Code:    http.StatusBadRequest,
+			Message: fmt.Sprintf("unable to parse form values: %s", err),
+		}
+	}
+	pool := r.FormValue("pool")
+	restart, _ := strconv.ParseBool(r.FormValue("restart"))
+	var conf container.ContainerOptions
+	dec := form.NewDecoder(nil)
+	dec.IgnoreUnknownKeys(true)
+	err = dec.DecodeValues(&conf, r.Form)
+	if err != nil {
+		return &tsuruErrors.HTTP{
+			Code:    http.StatusBadRequest,
+			Message: fmt.Sprintf("unable to parse fields in container options: %s", err),
+		}
+	}
+	var ctxs []permission.PermissionContext
+	if pool != "" {
+		ctxs = append(ctxs, permission.Context(permission.CtxPool, pool))
+	}
+	hasPermission := permission.Check(t, permission.PermPoolUpdateContaineroptions, ctxs...)
+	if !hasPermission {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:      event.Target{Type: event.TargetTypePool, Value: pool},
+		Kind:        permission.PermPoolUpdateContaineroptions,
+		Owner:       t,
+		CustomData:  event.FormToCustomData(r.Form),
+		DisableLock: true,
+		Allowed:     event.Allowed(permission.PermPoolReadEvents, ctxs...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = conf.Save(pool)
+	switch err {
+	case nil:
+	case container.ErrInvalidPullPolicy, container.ErrUlimitNameMandatory, container.ErrInvalidUlimit:
+		return &tsuruErrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	default:
+		return err
+	}
+	w.Header().Set("Content-Type", "application/x-json-stream")
+	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 15*time.Second, "")
+	defer keepAliveWriter.Stop()
+	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	fmt.Fprintln(writer, "Container options successfully updated.")
+	if restart {
+		filter := &app.Filter{}
+		if pool != "" {
+			filter.Pools = []string{pool}
+		}
+		return tryRestartAppsByFilter(filter, writer)
+	}
+	return nil
+}
+
 func tryRestartAppsByFilter(filter *app.Filter, writer io.Writer) error {
 	apps, err := app.List(filter)
 	if err != nil {
@@ -16,6 +16,7 @@ import (
 
 	"golang.org/x/crypto/bcrypt"
 
+	"github.com/fsouza/go-dockerclient"
 	"github.com/fsouza/go-dockerclient/testing"
 	dtesting "github.com/fsouza/go-dockerclient/testing"
 	"github.com/tsuru/config"
@@ -386,3 +387,77 @@ func (s *HandlersSuite) TestDockerLogsInfoHandler(c *check.C) {
 		"p1": {DockerLogConfig: types.DockerLogConfig{Driver: "syslog", LogOpts: map[string]string{}}},
 	})
 }
+
+func (s *HandlersSuite) TestContainerOptionsUpdateHandler(c *check.C) {
+	values := url.Values{
+		"pool":           []string{"POOL1"},
+		"PullPolicy":     []string{"if-not-present"},
+		"Ulimits.0.Name": []string{"nofile"},
+		"Ulimits.0.Soft": []string{"1024"},
+		"Ulimits.0.Hard": []string{"2048"},
+	}
+	reader := strings.NewReader(values.Encode())
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("POST", "/docker/container-options", reader)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	server := api.RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), check.Equals, "{\"Message\":\"Container options successfully updated.\\n\"}\n")
+	entries, err := container.ContainerOptionsLoadAll()
+	c.Assert(err, check.IsNil)
+	c.Assert(entries, check.DeepEquals, map[string]container.ContainerOptions{
+		"POOL1": {ContainerOptions: types.ContainerOptions{
+			PullPolicy: "if-not-present",
+			Ulimits:    []docker.ULimit{{Name: "nofile", Soft: 1024, Hard: 2048}},
+		}},
+	})
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypePool, Value: "POOL1"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "pool.update.containeroptions",
+		StartCustomData: []map[string]interface{}{
+			{"name": "pool", "value": "POOL1"},
+			{"name": "PullPolicy", "value": "if-not-present"},
+			{"name": "Ulimits.0.Name", "value": "nofile"},
+			{"name": "Ulimits.0.Soft", "value": "1024"},
+			{"name": "Ulimits.0.Hard", "value": "2048"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *HandlersSuite) TestContainerOptionsUpdateHandlerInvalidPullPolicy(c *check.C) {
+	values := url.Values{"PullPolicy": []string{"never"}}
+	reader := strings.NewReader(values.Encode())
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("POST", "/docker/container-options", reader)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	server := api.RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, container.ErrInvalidPullPolicy.Error()+"\n")
+}
+
+func (s *HandlersSuite) TestContainerOptionsInfoHandler(c *check.C) {
+	opts := container.ContainerOptions{ContainerOptions: types.ContainerOptions{PullPolicy: "always"}}
+	err := opts.Save("p1")
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/docker/container-options", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	server := api.RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var conf map[string]container.ContainerOptions
+	err = json.Unmarshal(recorder.Body.Bytes(), &conf)
+	c.Assert(err, check.IsNil)
+	c.Assert(conf, check.DeepEquals, map[string]container.ContainerOptions{
+		"p1": {ContainerOptions: types.ContainerOptions{PullPolicy: "always"}},
+	})
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/maintenance"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/docker/container"
@@ -120,6 +121,14 @@ func (h *ContainerHealer) healContainerIfNeeded(cont container.Container) error
 	if err != nil {
 		return errors.Wrapf(err, "Containers healing: unable to heal %q couldn't get app %q", cont.ID, cont.AppName)
 	}
+	window, err := maintenance.ActiveWindow(maintenance.ActionHealing, a.Name, a.Pool)
+	if err != nil {
+		return errors.Wrapf(err, "Containers healing: unable to heal %q couldn't check maintenance windows", cont.ID)
+	}
+	if window != nil {
+		log.Debugf("Containers healing: healing of %q deferred by maintenance window %q.", cont.ID, window.Name)
+		return nil
+	}
 	log.Errorf("Initiating healing process for container %q, unresponsive since %s.", cont.ID, cont.LastSuccessStatusUpdate)
 	evt, err := event.NewInternal(&event.Opts{
 		Target:       event.Target{Type: event.TargetTypeContainer, Value: cont.ID},
@@ -5,15 +5,20 @@
 package docker
 
 import (
+	"time"
+
 	"github.com/fsouza/go-dockerclient"
+	"github.com/pkg/errors"
 	"github.com/tsuru/config"
 	"github.com/tsuru/docker-cluster/cluster"
 	"github.com/tsuru/docker-cluster/storage"
 	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/app/image"
-	"github.com/tsuru/tsuru/log"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/maintenance"
 	"github.com/tsuru/tsuru/net"
 	"github.com/tsuru/tsuru/provision/dockercommon"
+	"github.com/tsuru/tsuru/queue"
 	"gopkg.in/mgo.v2/bson"
 )
 
@@ -68,24 +73,76 @@ func MigrateImages() error {
 	return nil
 }
 
+var imageGCQueue = queue.NewWorkQueue("docker-image-gc", queue.WorkQueueOpts{RetryDelay: time.Minute})
+
+type imageGCMessage struct {
+	App   string
+	Image string
+}
+
+func (m imageGCMessage) Target() string {
+	return m.Image
+}
+
 func (p *dockerProvisioner) CleanImage(appName, imgName string) {
-	shouldRemove := true
+	if imageGCDeferred(appName, imgName) {
+		return
+	}
+	err := p.removeImage(appName, imgName)
+	if err == nil {
+		return
+	}
+	logger.Errorf("Ignored error removing old image %q: %s. Removal will be retried in background.", imgName, err)
+	_, err = imageGCQueue.Enqueue(imageGCMessage{App: appName, Image: imgName})
+	if err != nil {
+		logger.Errorf("Unable to enqueue removal of old image %q: %s. Image kept on list to retry later.", imgName, err)
+	}
+}
+
+func (p *dockerProvisioner) removeImage(appName, imgName string) error {
+	var removeErrs []error
 	err := p.Cluster().RemoveImage(imgName)
 	if err != nil && err != docker.ErrNoSuchImage {
-		shouldRemove = false
-		log.Errorf("Ignored error removing old image %q: %s. Image kept on list to retry later.",
-			imgName, err.Error())
+		removeErrs = append(removeErrs, errors.Wrap(err, "removing image from nodes"))
 	}
 	err = p.Cluster().RemoveFromRegistry(imgName)
 	if err != nil {
-		shouldRemove = false
-		log.Errorf("Ignored error removing old image from registry %q: %s. Image kept on list to retry later.",
-			imgName, err.Error())
+		removeErrs = append(removeErrs, errors.Wrap(err, "removing image from registry"))
 	}
-	if shouldRemove {
-		err = image.PullAppImageNames(appName, []string{imgName})
-		if err != nil {
-			log.Errorf("Ignored error pulling old images from database: %s", err)
-		}
+	if len(removeErrs) > 0 {
+		return tsuruErrors.NewMultiError(removeErrs...)
+	}
+	err = image.PullAppImageNames(appName, []string{imgName})
+	if err != nil {
+		logger.Errorf("Ignored error pulling old images from database: %s", err)
+	}
+	return nil
+}
+
+func retryImageGC(msg *queue.Message) error {
+	var data imageGCMessage
+	err := msg.Decode(&data)
+	if err != nil {
+		return err
+	}
+	if imageGCDeferred(data.App, data.Image) {
+		return nil
+	}
+	return mainDockerProvisioner.removeImage(data.App, data.Image)
+}
+
+// imageGCDeferred reports whether the removal of old images of the app is
+// deferred by a maintenance window. Deferred images are kept in the app's
+// image list, so they're removed by the first deploy after the window.
+func imageGCDeferred(appName, imgName string) bool {
+	window, err := maintenance.ActiveWindow(maintenance.ActionImageGC, appName, "")
+	if err != nil {
+		logger.Errorf("Unable to check maintenance windows, removing old image %q anyway: %s", imgName, err)
+		return false
+	}
+	if window != nil {
+		logger.Debugf("Removal of old image %q deferred by maintenance window %q.", imgName, window.Name)
+		return true
 	}
+	return false
 }
@@ -0,0 +1,243 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/docker-cluster/cluster"
+	"github.com/tsuru/tsuru/net"
+	"github.com/tsuru/tsuru/provision"
+)
+
+const (
+	nodeScriptsConfig = "docker:node-scripts"
+
+	defaultNodeScriptConcurrency = 1
+	maxNodeScriptConcurrency     = 20
+	defaultNodeScriptTimeout     = 10 * time.Minute
+)
+
+var ErrNodeScriptNotFound = errors.New("node script not found")
+
+// NodeScript is a command configured by operators to be run in the hosts of
+// docker nodes. The command runs in a privileged container, sharing the pid
+// and network namespaces of the host, whose root filesystem is mounted at
+// /host. The container is killed if the command doesn't finish within
+// Timeout.
+type NodeScript struct {
+	Name    string        `json:"name"`
+	Image   string        `json:"image"`
+	Command []string      `json:"command"`
+	Timeout time.Duration `json:"timeout"`
+}
+
+// RunNodeScriptOptions selects the nodes a script runs on: the nodes in Pool,
+// when set, matching all Metadata. At most Concurrency nodes run the script
+// at the same time, up to maxNodeScriptConcurrency.
+type RunNodeScriptOptions struct {
+	Script      string
+	Pool        string
+	Metadata    map[string]string
+	Concurrency int
+	Writer      io.Writer
+}
+
+// NodeScriptResult holds the outcome of running a script in a single node.
+type NodeScriptResult struct {
+	Address  string `json:"address"`
+	ExitCode int    `json:"exitCode"`
+	Output   string `json:"output"`
+	Error    string `json:"error,omitempty"`
+}
+
+// NodeScripts returns the scripts available in the docker:node-scripts
+// config entry, sorted by name.
+func NodeScripts() ([]NodeScript, error) {
+	data, err := config.Get(nodeScriptsConfig)
+	if err != nil {
+		return nil, nil
+	}
+	entries, _ := data.(map[interface{}]interface{})
+	scripts := make([]NodeScript, 0, len(entries))
+	for name := range entries {
+		script, err := getNodeScript(fmt.Sprint(name))
+		if err != nil {
+			return nil, err
+		}
+		scripts = append(scripts, *script)
+	}
+	sort.Slice(scripts, func(i, j int) bool { return scripts[i].Name < scripts[j].Name })
+	return scripts, nil
+}
+
+func getNodeScript(name string) (*NodeScript, error) {
+	prefix := fmt.Sprintf("%s:%s", nodeScriptsConfig, name)
+	if _, err := config.Get(prefix); err != nil {
+		return nil, ErrNodeScriptNotFound
+	}
+	image, _ := config.GetString(prefix + ":image")
+	command, _ := config.GetList(prefix + ":command")
+	if image == "" || len(command) == 0 {
+		return nil, errors.Errorf("node script %q must have both image and command", name)
+	}
+	timeout, _ := config.GetDuration(prefix + ":timeout")
+	if timeout <= 0 {
+		timeout = defaultNodeScriptTimeout
+	}
+	return &NodeScript{Name: name, Image: image, Command: command, Timeout: timeout}, nil
+}
+
+// RunNodeScript runs the script in the selected nodes, writing the output of
+// each node to opts.Writer as soon as the script finishes in it. Failures in
+// a node don't stop the script from running in the others.
+func (p *dockerProvisioner) RunNodeScript(opts RunNodeScriptOptions) ([]NodeScriptResult, error) {
+	script, err := getNodeScript(opts.Script)
+	if err != nil {
+		return nil, err
+	}
+	metadata := make(map[string]string, len(opts.Metadata)+1)
+	for k, v := range opts.Metadata {
+		metadata[k] = v
+	}
+	if opts.Pool != "" {
+		metadata[provision.PoolMetadataName] = opts.Pool
+	}
+	nodes, err := p.Cluster().UnfilteredNodesForMetadata(metadata)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, errors.New("no nodes matching the given filters")
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Address < nodes[j].Address })
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultNodeScriptConcurrency
+	}
+	if concurrency > maxNodeScriptConcurrency {
+		concurrency = maxNodeScriptConcurrency
+	}
+	writer := opts.Writer
+	var writerMu sync.Mutex
+	results := make([]NodeScriptResult, len(nodes))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := range nodes {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			results[i] = runScriptInNode(script, &nodes[i])
+			if writer == nil {
+				return
+			}
+			writerMu.Lock()
+			defer writerMu.Unlock()
+			writeNodeScriptResult(writer, &results[i])
+		}(i)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+func writeNodeScriptResult(w io.Writer, result *NodeScriptResult) {
+	if result.Error != "" {
+		fmt.Fprintf(w, "---- %s: error: %s ----\n", result.Address, result.Error)
+	} else {
+		fmt.Fprintf(w, "---- %s: exit status %d ----\n", result.Address, result.ExitCode)
+	}
+	if result.Output != "" {
+		fmt.Fprint(w, result.Output)
+		if result.Output[len(result.Output)-1] != '\n' {
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+func runScriptInNode(script *NodeScript, node *cluster.Node) NodeScriptResult {
+	result := NodeScriptResult{Address: node.Address}
+	var output bytes.Buffer
+	exitCode, err := runScriptContainer(script, node, &output)
+	result.ExitCode = exitCode
+	result.Output = output.String()
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func runScriptContainer(script *NodeScript, node *cluster.Node, output io.Writer) (int, error) {
+	client, err := node.Client()
+	if err != nil {
+		return 0, err
+	}
+	err = client.PullImage(docker.PullImageOptions{
+		Repository:        script.Image,
+		InactivityTimeout: net.StreamInactivityTimeout,
+	}, docker.AuthConfiguration{})
+	if err != nil {
+		return 0, errors.Wrapf(err, "unable to pull image %q", script.Image)
+	}
+	cont, err := client.CreateContainer(docker.CreateContainerOptions{
+		Config: &docker.Config{
+			Image: script.Image,
+			Cmd:   script.Command,
+			Labels: map[string]string{
+				"tsuru.node-script": script.Name,
+			},
+		},
+		HostConfig: &docker.HostConfig{
+			Privileged:  true,
+			PidMode:     "host",
+			NetworkMode: "host",
+			Binds:       []string{"/:/host"},
+		},
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to create container")
+	}
+	defer client.RemoveContainer(docker.RemoveContainerOptions{ID: cont.ID, Force: true})
+	err = client.StartContainer(cont.ID, nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to start container")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), script.Timeout)
+	defer cancel()
+	exitCode, waitErr := client.WaitContainerWithContext(cont.ID, ctx)
+	if waitErr != nil {
+		if ctx.Err() == nil {
+			return 0, errors.Wrap(waitErr, "unable to wait for container")
+		}
+		err = client.KillContainer(docker.KillContainerOptions{ID: cont.ID})
+		if err != nil {
+			return 0, errors.Wrapf(err, "unable to kill container after timeout of %s", script.Timeout)
+		}
+		waitErr = errors.Errorf("script killed after timeout of %s", script.Timeout)
+	}
+	err = client.Logs(docker.LogsOptions{
+		Container:    cont.ID,
+		OutputStream: output,
+		ErrorStream:  output,
+		Stdout:       true,
+		Stderr:       true,
+	})
+	if err != nil && waitErr == nil {
+		return exitCode, errors.Wrap(err, "unable to get container output")
+	}
+	return exitCode, waitErr
+}
@@ -0,0 +1,233 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/permission/permissiontest"
+	"gopkg.in/check.v1"
+)
+
+func (s *HandlersSuite) setNodeScriptHandlers(exitCode int, output string) {
+	s.server.CustomHandler("/containers/.*/wait", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]int{"StatusCode": exitCode})
+	}))
+	s.server.CustomHandler("/containers/.*/logs", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := make([]byte, 8)
+		header[0] = 1
+		binary.BigEndian.PutUint32(header[4:], uint32(len(output)))
+		w.Write(header)
+		w.Write([]byte(output))
+	}))
+}
+
+func (s *HandlersSuite) TestNodeScripts(c *check.C) {
+	config.Set("docker:node-scripts:upgrade:image", "tsuru/upgrade")
+	config.Set("docker:node-scripts:upgrade:command", []interface{}{"sh", "-c", "apt-get upgrade -y"})
+	config.Set("docker:node-scripts:clean:image", "tsuru/clean")
+	config.Set("docker:node-scripts:clean:command", []interface{}{"clean"})
+	config.Set("docker:node-scripts:clean:timeout", "30s")
+	defer config.Unset("docker:node-scripts")
+	scripts, err := NodeScripts()
+	c.Assert(err, check.IsNil)
+	c.Assert(scripts, check.DeepEquals, []NodeScript{
+		{Name: "clean", Image: "tsuru/clean", Command: []string{"clean"}, Timeout: 30 * time.Second},
+		{Name: "upgrade", Image: "tsuru/upgrade", Command: []string{"sh", "-c", "apt-get upgrade -y"}, Timeout: defaultNodeScriptTimeout},
+	})
+}
+
+func (s *HandlersSuite) TestNodeScriptsInvalid(c *check.C) {
+	config.Set("docker:node-scripts:upgrade:image", "tsuru/upgrade")
+	defer config.Unset("docker:node-scripts")
+	_, err := NodeScripts()
+	c.Assert(err, check.ErrorMatches, `node script "upgrade" must have both image and command`)
+}
+
+func (s *HandlersSuite) TestRunNodeScript(c *check.C) {
+	config.Set("docker:node-scripts:upgrade:image", "tsuru/upgrade")
+	config.Set("docker:node-scripts:upgrade:command", []interface{}{"upgrade"})
+	defer config.Unset("docker:node-scripts")
+	s.setNodeScriptHandlers(0, "upgraded\n")
+	var buf bytes.Buffer
+	results, err := s.p.RunNodeScript(RunNodeScriptOptions{Script: "upgrade", Pool: "test-default", Writer: &buf})
+	c.Assert(err, check.IsNil)
+	c.Assert(results, check.DeepEquals, []NodeScriptResult{
+		{Address: s.server.URL(), ExitCode: 0, Output: "upgraded\n"},
+	})
+	c.Assert(buf.String(), check.Equals, fmt.Sprintf("---- %s: exit status 0 ----\nupgraded\n", s.server.URL()))
+	client, err := s.p.Cluster().GetNode(s.server.URL())
+	c.Assert(err, check.IsNil)
+	dockerClient, err := client.Client()
+	c.Assert(err, check.IsNil)
+	containers, err := dockerClient.ListContainers(docker.ListContainersOptions{All: true})
+	c.Assert(err, check.IsNil)
+	c.Assert(containers, check.HasLen, 0)
+}
+
+func (s *HandlersSuite) TestRunNodeScriptTimeout(c *check.C) {
+	config.Set("docker:node-scripts:upgrade:image", "tsuru/upgrade")
+	config.Set("docker:node-scripts:upgrade:command", []interface{}{"upgrade"})
+	config.Set("docker:node-scripts:upgrade:timeout", "100ms")
+	defer config.Unset("docker:node-scripts")
+	s.setNodeScriptHandlers(0, "upgrading\n")
+	s.server.CustomHandler("/containers/.*/wait", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	results, err := s.p.RunNodeScript(RunNodeScriptOptions{Script: "upgrade", Pool: "test-default"})
+	c.Assert(err, check.IsNil)
+	c.Assert(results, check.DeepEquals, []NodeScriptResult{
+		{Address: s.server.URL(), Output: "upgrading\n", Error: "script killed after timeout of 100ms"},
+	})
+}
+
+func (s *HandlersSuite) TestRunNodeScriptNoNodes(c *check.C) {
+	config.Set("docker:node-scripts:upgrade:image", "tsuru/upgrade")
+	config.Set("docker:node-scripts:upgrade:command", []interface{}{"upgrade"})
+	defer config.Unset("docker:node-scripts")
+	_, err := s.p.RunNodeScript(RunNodeScriptOptions{Script: "upgrade", Pool: "other"})
+	c.Assert(err, check.ErrorMatches, "no nodes matching the given filters")
+}
+
+func (s *HandlersSuite) TestRunNodeScriptNotFound(c *check.C) {
+	_, err := s.p.RunNodeScript(RunNodeScriptOptions{Script: "upgrade"})
+	c.Assert(err, check.Equals, ErrNodeScriptNotFound)
+}
+
+func (s *HandlersSuite) TestNodeScriptListHandler(c *check.C) {
+	config.Set("docker:node-scripts:upgrade:image", "tsuru/upgrade")
+	config.Set("docker:node-scripts:upgrade:command", []interface{}{"upgrade"})
+	defer config.Unset("docker:node-scripts")
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/docker/node/scripts", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	server := api.RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var scripts []NodeScript
+	err = json.Unmarshal(recorder.Body.Bytes(), &scripts)
+	c.Assert(err, check.IsNil)
+	c.Assert(scripts, check.DeepEquals, []NodeScript{
+		{Name: "upgrade", Image: "tsuru/upgrade", Command: []string{"upgrade"}, Timeout: defaultNodeScriptTimeout},
+	})
+}
+
+func (s *HandlersSuite) TestNodeScriptListHandlerEmpty(c *check.C) {
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/docker/node/scripts", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	server := api.RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+}
+
+func (s *HandlersSuite) TestNodeScriptRunHandler(c *check.C) {
+	config.Set("docker:node-scripts:upgrade:image", "tsuru/upgrade")
+	config.Set("docker:node-scripts:upgrade:command", []interface{}{"upgrade"})
+	defer config.Unset("docker:node-scripts")
+	s.setNodeScriptHandlers(0, "upgraded\n")
+	v := url.Values{"Metadata.pool": []string{"test-default"}}
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("POST", "/docker/node/scripts/upgrade/run", strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	server := api.RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/x-json-stream")
+	c.Assert(recorder.Body.String(), check.Matches, `(?s).*exit status 0.*Script \\"upgrade\\" successfully run in 1 nodes.*`)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypePool, Value: "test-default"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "node.update.script",
+		StartCustomData: []map[string]interface{}{
+			{"name": "Metadata.pool", "value": "test-default"},
+		},
+	}, eventtest.HasEvent)
+}
+
+func (s *HandlersSuite) TestNodeScriptRunHandlerFailure(c *check.C) {
+	config.Set("docker:node-scripts:upgrade:image", "tsuru/upgrade")
+	config.Set("docker:node-scripts:upgrade:command", []interface{}{"upgrade"})
+	defer config.Unset("docker:node-scripts")
+	s.setNodeScriptHandlers(1, "failed\n")
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("POST", "/docker/node/scripts/upgrade/run", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	server := api.RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), check.Matches, `(?s).*exit status 1.*failed.*script \\"upgrade\\" failed in 1 of 1 nodes.*`)
+	c.Assert(eventtest.EventDesc{
+		Target:       event.Target{Type: event.TargetTypeGlobal},
+		Owner:        s.token.GetUserName(),
+		Kind:         "node.update.script",
+		ErrorMatches: `script "upgrade" failed in 1 of 1 nodes`,
+	}, eventtest.HasEvent)
+}
+
+func (s *HandlersSuite) TestNodeScriptRunHandlerConcurrencyTooHigh(c *check.C) {
+	config.Set("docker:node-scripts:upgrade:image", "tsuru/upgrade")
+	config.Set("docker:node-scripts:upgrade:command", []interface{}{"upgrade"})
+	defer config.Unset("docker:node-scripts")
+	v := url.Values{"Concurrency": []string{"21"}}
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("POST", "/docker/node/scripts/upgrade/run", strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	server := api.RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "concurrency must be at most 20\n")
+}
+
+func (s *HandlersSuite) TestNodeScriptRunHandlerNotFound(c *check.C) {
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("POST", "/docker/node/scripts/upgrade/run", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	server := api.RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *HandlersSuite) TestNodeScriptRunHandlerNoPermission(c *check.C) {
+	config.Set("docker:node-scripts:upgrade:image", "tsuru/upgrade")
+	config.Set("docker:node-scripts:upgrade:command", []interface{}{"upgrade"})
+	defer config.Unset("docker:node-scripts")
+	_, token := permissiontest.CustomUserWithPermission(c, nativeScheme, "scripter", permission.Permission{
+		Scheme:  permission.PermNodeUpdateScript,
+		Context: permission.Context(permission.CtxPool, "other"),
+	})
+	v := url.Values{"Metadata.pool": []string{"test-default"}}
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("POST", "/docker/node/scripts/upgrade/run", strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	server := api.RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
@@ -46,6 +46,7 @@ import (
 	_ "github.com/tsuru/tsuru/router/routertest"
 	_ "github.com/tsuru/tsuru/router/vulcand"
 	"github.com/tsuru/tsuru/safe"
+	"gopkg.in/mgo.v2/bson"
 )
 
 var (
@@ -54,6 +55,8 @@ var (
 	ErrDeployCanceled = errors.New("deploy canceled by user action")
 )
 
+var logger = log.Subsystem("provisioner")
+
 const (
 	provisionerName           = "docker"
 	provisionerCollectionName = "dockercluster"
@@ -85,6 +88,8 @@ var (
 	_ provision.InitializableProvisioner = &dockerProvisioner{}
 	_ provision.OptionalLogsProvisioner  = &dockerProvisioner{}
 	_ provision.UnitStatusProvisioner    = &dockerProvisioner{}
+	_ provision.UnitProvisioner          = &dockerProvisioner{}
+	_ provision.UnitResourcesProvisioner = &dockerProvisioner{}
 	_ provision.NodeProvisioner          = &dockerProvisioner{}
 	_ provision.NodeRebalanceProvisioner = &dockerProvisioner{}
 	_ provision.NodeContainerProvisioner = &dockerProvisioner{}
@@ -130,9 +135,11 @@ func (p *dockerProvisioner) initDockerCluster() error {
 	var nodes []cluster.Node
 	TotalMemoryMetadata, _ := config.GetString("docker:scheduler:total-memory-metadata")
 	maxUsedMemory, _ := config.GetFloat("docker:scheduler:max-used-memory")
+	zoneMetadata, _ := config.GetString("docker:scheduler:zone-metadata")
 	p.scheduler = &segregatedScheduler{
 		maxMemoryRatio:      float32(maxUsedMemory),
 		TotalMemoryMetadata: TotalMemoryMetadata,
+		ZoneMetadata:        zoneMetadata,
 		provisioner:         p,
 	}
 	caPath, _ := config.GetString("docker:tls:root-path")
@@ -188,6 +195,7 @@ func (p *dockerProvisioner) cloneProvisioner(ignoredContainers []container.Conta
 	overridenProvisioner.scheduler = &segregatedScheduler{
 		maxMemoryRatio:      p.scheduler.maxMemoryRatio,
 		TotalMemoryMetadata: p.scheduler.TotalMemoryMetadata,
+		ZoneMetadata:        p.scheduler.ZoneMetadata,
 		provisioner:         &overridenProvisioner,
 		ignoredContainers:   containerIds,
 	}
@@ -223,6 +231,7 @@ func (p *dockerProvisioner) dryMode(ignoredContainers []container.Container) (*d
 	overridenProvisioner.scheduler = &segregatedScheduler{
 		maxMemoryRatio:      p.scheduler.maxMemoryRatio,
 		TotalMemoryMetadata: p.scheduler.TotalMemoryMetadata,
+		ZoneMetadata:        p.scheduler.ZoneMetadata,
 		provisioner:         overridenProvisioner,
 		ignoredContainers:   containerIds,
 	}
@@ -275,6 +284,10 @@ func (p *dockerProvisioner) Initialize() error {
 	if err != nil {
 		return err
 	}
+	err = imageGCQueue.Consume(retryImageGC)
+	if err != nil && err != queue.ErrAlreadyConsuming {
+		return err
+	}
 	return p.initDockerCluster()
 }
 
@@ -331,13 +344,13 @@ func (p *dockerProvisioner) Start(app provision.App, process string) error {
 func (p *dockerProvisioner) Stop(app provision.App, process string) error {
 	containers, err := p.listContainersByProcess(app.GetName(), process)
 	if err != nil {
-		log.Errorf("Got error while getting app containers: %s", err)
+		logger.Errorf("Got error while getting app containers: %s", err)
 		return nil
 	}
 	return runInContainers(containers, func(c *container.Container, _ chan *container.Container) error {
 		err := c.Stop(p)
 		if err != nil {
-			log.Errorf("Failed to stop %q: %s", app.GetName(), err)
+			logger.Errorf("Failed to stop %q: %s", app.GetName(), err)
 		}
 		return err
 	}, nil, true)
@@ -346,13 +359,13 @@ func (p *dockerProvisioner) Stop(app provision.App, process string) error {
 func (p *dockerProvisioner) Sleep(app provision.App, process string) error {
 	containers, err := p.listContainersByProcess(app.GetName(), process)
 	if err != nil {
-		log.Errorf("Got error while getting app containers: %s", err)
+		logger.Errorf("Got error while getting app containers: %s", err)
 		return nil
 	}
 	return runInContainers(containers, func(c *container.Container, _ chan *container.Container) error {
 		err := c.Sleep(p)
 		if err != nil {
-			log.Errorf("Failed to sleep %q: %s", app.GetName(), err)
+			logger.Errorf("Failed to sleep %q: %s", app.GetName(), err)
 		}
 		return err
 	}, nil, true)
@@ -480,7 +493,7 @@ func getContainersToAdd(data image.ImageMetadata, oldContainers []container.Cont
 func (p *dockerProvisioner) Destroy(app provision.App) error {
 	containers, err := p.listContainersByApp(app.GetName())
 	if err != nil {
-		log.Errorf("Failed to list app containers: %s", err)
+		logger.Errorf("Failed to list app containers: %s", err)
 		return err
 	}
 	args := changeUnitsPipelineArgs{
@@ -501,13 +514,13 @@ func (p *dockerProvisioner) Destroy(app provision.App) error {
 	}
 	images, err := image.ListAppImages(app.GetName())
 	if err != nil {
-		log.Errorf("Failed to get image ids for app %s: %s", app.GetName(), err)
+		logger.Errorf("Failed to get image ids for app %s: %s", app.GetName(), err)
 	}
 	cluster := p.Cluster()
 	for _, imageID := range images {
 		err = cluster.RemoveImage(imageID)
 		if err != nil && err != docker.ErrNoSuchImage {
-			log.Errorf("Failed to remove image %s: %s", imageID, err)
+			logger.Errorf("Failed to remove image %s: %s", imageID, err)
 		}
 	}
 	return nil
@@ -561,10 +574,10 @@ func addContainersWithHost(args *changeUnitsPipelineArgs) ([]container.Container
 		}
 	}
 	rollbackCallback := func(c *container.Container) {
-		log.Errorf("Removing container %q due failed add units.", c.ID)
+		logger.Errorf("Removing container %q due failed add units.", c.ID)
 		errRem := c.Remove(args.provisioner)
 		if errRem != nil {
-			log.Errorf("Unable to destroy container %q: %s", c.ID, errRem)
+			logger.Errorf("Unable to destroy container %q: %s", c.ID, errRem)
 		}
 	}
 	var (
@@ -755,7 +768,7 @@ func (p *dockerProvisioner) ExecuteCommandIsolated(stdout, stderr io.Writer, app
 func (p *dockerProvisioner) Collection() *storage.Collection {
 	conn, err := db.Conn()
 	if err != nil {
-		log.Errorf("Failed to connect to the database: %s", err)
+		logger.Errorf("Failed to connect to the database: %s", err)
 	}
 	return conn.Collection(p.collectionName)
 }
@@ -825,6 +838,84 @@ func (p *dockerProvisioner) RegisterUnit(a provision.App, unitId string, customD
 	return p.checkContainer(cont)
 }
 
+func (p *dockerProvisioner) getAppContainer(a provision.App, unitID string) (*container.Container, error) {
+	cont, err := p.GetContainer(unitID)
+	if err != nil {
+		return nil, err
+	}
+	if cont.AppName != a.GetName() {
+		return nil, &provision.UnitNotFoundError{ID: unitID}
+	}
+	return cont, nil
+}
+
+func (p *dockerProvisioner) InspectUnit(a provision.App, unitID string) (*provision.UnitInfo, error) {
+	cont, err := p.getAppContainer(a, unitID)
+	if err != nil {
+		return nil, err
+	}
+	info := &provision.UnitInfo{
+		Unit:      cont.AsUnit(a),
+		Node:      cont.HostAddr,
+		Resources: cont.ResourceOverride,
+	}
+	if cont.MongoID.Valid() {
+		info.CreatedAt = cont.MongoID.Time()
+	}
+	lastHealing, err := lastContainerHealing(cont.ID)
+	if err != nil {
+		return nil, err
+	}
+	info.LastHealing = lastHealing
+	return info, nil
+}
+
+// lastContainerHealing returns when the container was last healed, either
+// being the failing container or the one created to replace it.
+func lastContainerHealing(contID string) (*time.Time, error) {
+	evts, err := event.List(&event.Filter{
+		Target:    event.Target{Type: event.TargetTypeContainer},
+		KindType:  event.KindTypeInternal,
+		KindNames: []string{"healer"},
+		Raw: bson.M{"$or": []bson.M{
+			{"target.value": contID},
+			{"endcustomdata.id": contID},
+		}},
+		Limit: 1,
+	})
+	if err != nil || len(evts) == 0 {
+		return nil, err
+	}
+	return &evts[0].StartTime, nil
+}
+
+func (p *dockerProvisioner) RestartUnit(a provision.App, unitID string, w io.Writer) error {
+	cont, err := p.getAppContainer(a, unitID)
+	if err != nil {
+		return err
+	}
+	imageID, err := image.AppCurrentImageName(a.GetName())
+	if err != nil {
+		return err
+	}
+	if w == nil {
+		w = ioutil.Discard
+	}
+	toAdd := map[string]*containersToAdd{
+		cont.ProcessName: {Quantity: 1, Status: provision.StatusStarted},
+	}
+	_, err = p.runReplaceUnitsPipeline(w, a, toAdd, []container.Container{*cont}, imageID)
+	return err
+}
+
+func (p *dockerProvisioner) SetUnitResources(a provision.App, unitID string, r provision.UnitResources) error {
+	cont, err := p.getAppContainer(a, unitID)
+	if err != nil {
+		return err
+	}
+	return cont.SetResources(p, a, r)
+}
+
 func (p *dockerProvisioner) Shell(opts provision.ShellOptions) error {
 	var (
 		c   *container.Container
@@ -838,7 +929,7 @@ func (p *dockerProvisioner) Shell(opts provision.ShellOptions) error {
 	if err != nil {
 		return err
 	}
-	return c.Shell(p, opts.Conn, opts.Conn, opts.Conn, container.Pty{Width: opts.Width, Height: opts.Height, Term: opts.Term})
+	return c.Shell(p, opts.Conn, opts.Conn, opts.Conn, container.Pty{Width: opts.Width, Height: opts.Height, Term: opts.Term, Resize: opts.Resize})
 }
 
 func (p *dockerProvisioner) Nodes(app provision.App) ([]cluster.Node, error) {
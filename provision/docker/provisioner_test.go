@@ -119,6 +119,124 @@ func (s *S) TestProvisionerRestart(c *check.C) {
 	c.Assert(dbConts[0].HostPort, check.Equals, expectedPort)
 }
 
+func (s *S) TestProvisionerRestartUnit(c *check.C) {
+	app := provisiontest.NewFakeApp("almah", "static", 1)
+	customData := map[string]interface{}{
+		"processes": map[string]interface{}{
+			"web": "python web.py",
+		},
+	}
+	cont1, err := s.newContainer(&newContainerOpts{
+		AppName:         app.GetName(),
+		ProcessName:     "web",
+		ImageCustomData: customData,
+		Image:           "tsuru/app-" + app.GetName(),
+	}, nil)
+	c.Assert(err, check.IsNil)
+	defer s.removeTestContainer(cont1)
+	cont2, err := s.newContainer(&newContainerOpts{
+		AppName:         app.GetName(),
+		ProcessName:     "web",
+		ImageCustomData: customData,
+		Image:           "tsuru/app-" + app.GetName(),
+	}, nil)
+	c.Assert(err, check.IsNil)
+	defer s.removeTestContainer(cont2)
+	err = s.p.RestartUnit(app, cont1.ID, nil)
+	c.Assert(err, check.IsNil)
+	dbConts, err := s.p.listAllContainers()
+	c.Assert(err, check.IsNil)
+	c.Assert(dbConts, check.HasLen, 2)
+	var ids []string
+	for _, cont := range dbConts {
+		ids = append(ids, cont.ID)
+		c.Assert(cont.ProcessName, check.Equals, "web")
+	}
+	c.Assert(ids, check.Not(check.DeepEquals), []string{cont1.ID, cont2.ID})
+	_, err = s.p.GetContainer(cont1.ID)
+	c.Assert(err, check.FitsTypeOf, &provision.UnitNotFoundError{})
+	_, err = s.p.GetContainer(cont2.ID)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestProvisionerRestartUnitOtherApp(c *check.C) {
+	app := provisiontest.NewFakeApp("almah", "static", 1)
+	cont, err := s.newContainer(&newContainerOpts{AppName: "otherapp"}, nil)
+	c.Assert(err, check.IsNil)
+	defer s.removeTestContainer(cont)
+	err = s.p.RestartUnit(app, cont.ID, nil)
+	c.Assert(err, check.DeepEquals, &provision.UnitNotFoundError{ID: cont.ID})
+}
+
+func (s *S) TestProvisionerInspectUnit(c *check.C) {
+	app := provisiontest.NewFakeApp("almah", "static", 1)
+	cont, err := s.newContainer(&newContainerOpts{AppName: app.GetName()}, nil)
+	c.Assert(err, check.IsNil)
+	defer s.removeTestContainer(cont)
+	info, err := s.p.InspectUnit(app, cont.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(info.Unit.ID, check.Equals, cont.ID)
+	c.Assert(info.Node, check.Equals, cont.HostAddr)
+	c.Assert(info.CreatedAt.IsZero(), check.Equals, false)
+	c.Assert(info.LastHealing, check.IsNil)
+	evt, err := event.NewInternal(&event.Opts{
+		Target:       event.Target{Type: event.TargetTypeContainer, Value: "oldcontainer"},
+		InternalKind: "healer",
+		Allowed:      event.Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	err = evt.DoneCustomData(nil, cont)
+	c.Assert(err, check.IsNil)
+	info, err = s.p.InspectUnit(app, cont.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(info.LastHealing, check.NotNil)
+	c.Assert(info.LastHealing.Unix(), check.Equals, evt.StartTime.Unix())
+}
+
+func (s *S) TestProvisionerSetUnitResources(c *check.C) {
+	app := provisiontest.NewFakeApp("almah", "static", 1)
+	app.Memory = 256 * 1024 * 1024
+	app.Swap = 64 * 1024 * 1024
+	app.CpuShare = 100
+	cont, err := s.newContainer(&newContainerOpts{AppName: app.GetName()}, nil)
+	c.Assert(err, check.IsNil)
+	defer s.removeTestContainer(cont)
+	var updates []docker.UpdateContainerOptions
+	s.server.CustomHandler("/containers/.*/update", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var opts docker.UpdateContainerOptions
+		json.NewDecoder(r.Body).Decode(&opts)
+		updates = append(updates, opts)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer s.server.CustomHandler("/containers/.*/update", s.server.DefaultHandler())
+	resources := provision.UnitResources{Memory: 1024 * 1024 * 1024}
+	err = s.p.SetUnitResources(app, cont.ID, resources)
+	c.Assert(err, check.IsNil)
+	info, err := s.p.InspectUnit(app, cont.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(info.Resources, check.DeepEquals, &resources)
+	err = s.p.SetUnitResources(app, cont.ID, provision.UnitResources{})
+	c.Assert(err, check.IsNil)
+	info, err = s.p.InspectUnit(app, cont.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(info.Resources, check.IsNil)
+	c.Assert(updates, check.HasLen, 2)
+	c.Assert(updates[0].Memory, check.Equals, 1024*1024*1024)
+	c.Assert(updates[0].MemorySwap, check.Equals, 1088*1024*1024)
+	c.Assert(updates[0].CPUShares, check.Equals, 100)
+	c.Assert(updates[1].Memory, check.Equals, 256*1024*1024)
+	c.Assert(updates[1].MemorySwap, check.Equals, 320*1024*1024)
+}
+
+func (s *S) TestProvisionerSetUnitResourcesOtherApp(c *check.C) {
+	app := provisiontest.NewFakeApp("almah", "static", 1)
+	cont, err := s.newContainer(&newContainerOpts{AppName: "otherapp"}, nil)
+	c.Assert(err, check.IsNil)
+	defer s.removeTestContainer(cont)
+	err = s.p.SetUnitResources(app, cont.ID, provision.UnitResources{CpuShare: 200})
+	c.Assert(err, check.DeepEquals, &provision.UnitNotFoundError{ID: cont.ID})
+}
+
 func (s *S) TestProvisionerRestartStoppedContainer(c *check.C) {
 	app := provisiontest.NewFakeApp("almah", "static", 1)
 	customData := map[string]interface{}{
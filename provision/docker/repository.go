@@ -8,7 +8,6 @@ import (
 	"fmt"
 
 	"github.com/tsuru/docker-cluster/cluster"
-	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/net"
 	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/docker/container"
@@ -29,7 +28,7 @@ func (p *dockerProvisioner) GetContainer(id string) (*container.Container, error
 		return nil, &provision.UnitNotFoundError{ID: id}
 	}
 	if lenContainers > 1 {
-		log.Debugf("ambiguous container id. found %d containers (%v) when looking for %q", lenContainers, containers, id)
+		logger.Debugf("ambiguous container id. found %d containers (%v) when looking for %q", lenContainers, containers, id)
 		return nil, &AmbiguousContainerError{ID: id}
 	}
 	return &containers[0], nil
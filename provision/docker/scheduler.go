@@ -15,10 +15,13 @@ import (
 	"github.com/tsuru/docker-cluster/cluster"
 	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/autoscale"
-	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/featureflag"
 	"github.com/tsuru/tsuru/net"
+	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/docker/container"
+	"github.com/tsuru/tsuru/provision/pool"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
@@ -27,7 +30,11 @@ type segregatedScheduler struct {
 	hostMutex           sync.Mutex
 	maxMemoryRatio      float32
 	TotalMemoryMetadata string
-	provisioner         *dockerProvisioner
+	// ZoneMetadata is the node metadata key holding the zone of the node.
+	// When set, units of an app are spread across zones before being spread
+	// across nodes.
+	ZoneMetadata string
+	provisioner  *dockerProvisioner
 	// ignored containers is only set in provisioner returned by
 	// cloneProvisioner which will set this field to exclude some container
 	// ids from balancing (containers being removed by rebalance usually).
@@ -46,7 +53,7 @@ func (s *segregatedScheduler) Schedule(c *cluster.Cluster, opts *docker.CreateCo
 	if err != nil {
 		return cluster.Node{}, &container.SchedulerError{Base: err}
 	}
-	nodes, err = s.filterByMemoryUsage(a, nodes, s.maxMemoryRatio, s.TotalMemoryMetadata)
+	nodes, err = s.filterByMemoryUsage(a, nodes, s.memoryRatio(a), s.TotalMemoryMetadata)
 	if err != nil {
 		return cluster.Node{}, &container.SchedulerError{Base: err}
 	}
@@ -63,6 +70,9 @@ func (s *segregatedScheduler) Schedule(c *cluster.Cluster, opts *docker.CreateCo
 			return cluster.Node{}, &container.SchedulerError{Base: err}
 		}
 	}
+	if schedOpts.LocalImage != "" {
+		container.UseLocalImage(c, opts, schedOpts.LocalImage, node)
+	}
 	return cluster.Node{Address: node}, nil
 }
 
@@ -81,6 +91,24 @@ func (s *segregatedScheduler) updateContainerName(opts *docker.CreateContainerOp
 	return nil
 }
 
+// memoryRatio returns the ratio of the memory of the nodes that may be
+// reserved by units, taking the memory overcommit of the app pool over the
+// one configured for the provisioner.
+func (s *segregatedScheduler) memoryRatio(a *app.App) float32 {
+	if a == nil || a.Pool == "" {
+		return s.maxMemoryRatio
+	}
+	p, err := pool.GetPoolByName(a.Pool)
+	if err != nil {
+		logger.Errorf("unable to get pool %q, using default memory ratio: %s", a.Pool, err)
+		return s.maxMemoryRatio
+	}
+	if p.MemoryOvercommit > 0 {
+		return float32(p.MemoryOvercommit)
+	}
+	return s.maxMemoryRatio
+}
+
 func (s *segregatedScheduler) filterByMemoryUsage(a *app.App, nodes []cluster.Node, maxMemoryRatio float32, TotalMemoryMetadata string) ([]cluster.Node, error) {
 	if maxMemoryRatio == 0 || TotalMemoryMetadata == "" {
 		return nodes, nil
@@ -115,7 +143,7 @@ func (s *segregatedScheduler) filterByMemoryUsage(a *app.App, nodes []cluster.No
 				tryingToReserveMB := float64(a.Plan.Memory) / megabyte
 				reservedMB := float64(hostReserved[host]) / megabyte
 				limitMB := maxMemory / megabyte
-				log.Errorf("Node %q has reached its memory limit. "+
+				logger.Errorf("Node %q has reached its memory limit. "+
 					"Limit %0.4fMB. Reserved: %0.4fMB. Needed additional %0.4fMB",
 					host, limitMB, reservedMB, tryingToReserveMB)
 			}
@@ -130,19 +158,38 @@ func (s *segregatedScheduler) filterByMemoryUsage(a *app.App, nodes []cluster.No
 		if rule != nil {
 			autoScaleEnabled = rule.Enabled
 		}
-		errMsg := fmt.Sprintf("no nodes found with enough memory for container of %q: %0.4fMB",
-			a.Name, float64(a.Plan.Memory)/megabyte)
+		fullErr := &pool.FullError{Pool: a.Pool, App: a.Name, Memory: a.Plan.Memory}
 		if autoScaleEnabled {
 			// Allow going over quota temporarily because auto-scale will be
 			// able to detect this and automatically add a new nodes.
-			log.Errorf("WARNING: %s. Will ignore memory restrictions.", errMsg)
+			logger.Errorf("WARNING: %s. Will ignore memory restrictions.", fullErr)
 			return nodes, nil
 		}
-		return nil, errors.New(errMsg)
+		notifyPoolFull(fullErr)
+		return nil, fullErr
 	}
 	return nodeList, nil
 }
 
+// notifyPoolFull registers an event for the pool, so operators are able to
+// find out placements refused due to lack of capacity.
+func notifyPoolFull(fullErr *pool.FullError) {
+	evt, err := event.NewInternal(&event.Opts{
+		Target:       event.Target{Type: event.TargetTypePool, Value: fullErr.Pool},
+		InternalKind: "pool-full",
+		CustomData:   fullErr,
+		Allowed:      event.Allowed(permission.PermPoolReadEvents, permission.Context(permission.CtxPool, fullErr.Pool)),
+	})
+	if err != nil {
+		logger.Errorf("unable to register pool full event for %q: %s", fullErr.Pool, err)
+		return
+	}
+	err = evt.Done(fullErr)
+	if err != nil {
+		logger.Errorf("unable to register pool full event for %q: %s", fullErr.Pool, err)
+	}
+}
+
 type nodeAggregate struct {
 	HostAddr string `bson:"_id"`
 	Count    int
@@ -251,14 +298,14 @@ func (s *segregatedScheduler) nodesToHosts(nodes []cluster.Node) ([]string, map[
 // chooseNodeToAdd finds which is the node with the minimum number of containers
 // and returns it
 func (s *segregatedScheduler) chooseNodeToAdd(nodes []cluster.Node, contName string, appName, process string) (string, error) {
-	log.Debugf("[scheduler] Possible nodes for container %s: %#v", contName, nodes)
+	logger.Debugf("[scheduler] Possible nodes for container %s: %#v", contName, nodes)
 	s.hostMutex.Lock()
 	defer s.hostMutex.Unlock()
 	chosenNode, _, err := s.minMaxNodes(nodes, appName, process)
 	if err != nil {
 		return "", err
 	}
-	log.Debugf("[scheduler] Chosen node for container %s: %#v", contName, chosenNode)
+	logger.Debugf("[scheduler] Chosen node for container %s: %#v", contName, chosenNode)
 	if contName != "" {
 		coll := s.provisioner.Collection()
 		defer coll.Close()
@@ -274,7 +321,7 @@ func (s *segregatedScheduler) chooseContainerToRemove(nodes []cluster.Node, appN
 	if err != nil {
 		return "", err
 	}
-	log.Debugf("[scheduler] Chosen node for remove a container: %#v", chosenNode)
+	logger.Debugf("[scheduler] Chosen node for remove a container: %#v", chosenNode)
 	containerID, err := s.getContainerPreferablyFromHost(chosenNode, appName, process)
 	if err != nil {
 		return "", err
@@ -294,34 +341,75 @@ func appGroupCount(hostGroups map[string]int, appCountHost map[string]int) map[s
 	return result
 }
 
-// Find the host with the minimum (good to add a new container) and maximum
-// (good to remove a container) value for the pair [(number of containers for
-// app-process), (number of containers in host)]
-func (s *segregatedScheduler) minMaxNodes(nodes []cluster.Node, appName, process string) (string, string, error) {
+// zoneSchedulerFlag is the feature flag gating the placement of units by
+// the zones read from ZoneMetadata, rolled out per team owning the app.
+const zoneSchedulerFlag = "docker.zone-scheduler"
+
+// appSpread returns whether the units of the given app should be spread
+// across nodes and zones, and whether zones should be read from
+// ZoneMetadata. Unknown apps are always spread.
+func appSpread(appName string) (spread bool, zones bool) {
+	if appName == "" {
+		return true, featureflag.IsEnabled(zoneSchedulerFlag, "")
+	}
+	a, err := app.GetByName(appName)
+	if err != nil {
+		return true, featureflag.IsEnabled(zoneSchedulerFlag, "")
+	}
+	return a.SpreadUnits(), featureflag.IsEnabled(zoneSchedulerFlag, a.TeamOwner)
+}
+
+// hostGroups maps each node host to the zone it belongs to. Zones are read
+// from the metadata named by ZoneMetadata, if set and zones is true, or
+// derived from the metadata values that differ among the nodes.
+func (s *segregatedScheduler) hostGroups(nodes []cluster.Node, zones bool) map[string]int {
+	hostGroupMap := map[string]int{}
+	if s.ZoneMetadata != "" && zones {
+		zoneIdx := map[string]int{}
+		for _, n := range nodes {
+			zone := n.Metadata[s.ZoneMetadata]
+			if _, ok := zoneIdx[zone]; !ok {
+				zoneIdx[zone] = len(zoneIdx)
+			}
+			hostGroupMap[net.URLToHost(n.Address)] = zoneIdx[zone]
+		}
+		return hostGroupMap
+	}
 	nodesList := make(provision.NodeList, len(nodes))
 	for i := range nodes {
 		nodesList[i] = &clusterNodeWrapper{Node: &nodes[i], prov: s.provisioner}
 	}
 	metaFreqList, _, err := nodesList.SplitMetadata()
 	if err != nil {
-		log.Debugf("[scheduler] ignoring metadata diff when selecting node: %s", err)
+		logger.Debugf("[scheduler] ignoring metadata diff when selecting node: %s", err)
 	}
-	hostGroupMap := map[string]int{}
 	for i, m := range metaFreqList {
 		for _, n := range m.Nodes {
 			hostGroupMap[net.URLToHost(n.Address())] = i
 		}
 	}
+	return hostGroupMap
+}
+
+// Find the host with the minimum (good to add a new container) and maximum
+// (good to remove a container) value for the tuple [(number of containers for
+// app-process in the zone), (number of containers for app-process), (number of
+// containers in host)]. Apps with spreading disabled only take into account
+// the number of containers in host.
+func (s *segregatedScheduler) minMaxNodes(nodes []cluster.Node, appName, process string) (string, string, error) {
 	hosts, hostsMap := s.nodesToHosts(nodes)
 	hostCountMap, err := s.aggregateContainersByHost(hosts)
 	if err != nil {
 		return "", "", err
 	}
-	appCountMap, err := s.aggregateContainersByHostAppProcess(hosts, appName, process)
-	if err != nil {
-		return "", "", err
+	priorityEntries := []map[string]int{hostCountMap}
+	if spread, zones := appSpread(appName); spread {
+		appCountMap, err := s.aggregateContainersByHostAppProcess(hosts, appName, process)
+		if err != nil {
+			return "", "", err
+		}
+		priorityEntries = []map[string]int{appGroupCount(s.hostGroups(nodes, zones), appCountMap), appCountMap, hostCountMap}
 	}
-	priorityEntries := []map[string]int{appGroupCount(hostGroupMap, appCountMap), appCountMap, hostCountMap}
 	var minHost, maxHost string
 	var minScore uint64 = math.MaxUint64
 	var maxScore uint64 = 0
@@ -18,6 +18,8 @@ import (
 	"github.com/tsuru/docker-cluster/cluster"
 	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/autoscale"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/featureflag"
 	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/provision/docker/container"
 	"github.com/tsuru/tsuru/provision/docker/types"
@@ -447,6 +449,50 @@ func (s *S) TestSchedulerScheduleWithMemoryAwarenessWithAutoScaleDisabledForPool
 	c.Assert(node, check.DeepEquals, cluster.Node{})
 }
 
+func (s *S) TestSchedulerScheduleWithPoolMemoryOvercommit(c *check.C) {
+	a := app.App{Name: "oblivion", Plan: appTypes.Plan{Memory: 20000}, Pool: "mypool"}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	segSched := segregatedScheduler{
+		maxMemoryRatio:      0.8,
+		TotalMemoryMetadata: "totalMemory",
+		provisioner:         s.p,
+	}
+	err = pool.AddPool(pool.AddPoolOptions{Name: "mypool", MemoryOvercommit: 0.5})
+	c.Assert(err, check.IsNil)
+	server, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	defer server.Stop()
+	clusterInstance, err := cluster.New(&segSched, &cluster.MapStorage{}, "",
+		cluster.Node{Address: server.URL(), Metadata: map[string]string{
+			"totalMemory": "100000",
+			"pool":        "mypool",
+		}},
+	)
+	c.Assert(err, check.IsNil)
+	s.p.cluster = clusterInstance
+	contColl := s.p.Collection()
+	defer contColl.Close()
+	for i := 0; i < 3; i++ {
+		cont := container.Container{Container: types.Container{ID: fmt.Sprintf("id%d", i), Name: fmt.Sprintf("unit%d", i), AppName: a.Name}}
+		err = contColl.Insert(cont)
+		c.Assert(err, check.IsNil)
+		opts := docker.CreateContainerOptions{Name: cont.Name}
+		node, schedErr := segSched.Schedule(clusterInstance, &opts, &container.SchedulerOpts{AppName: a.Name, ProcessName: "web"})
+		if i < 2 {
+			c.Assert(schedErr, check.IsNil)
+			c.Assert(node, check.NotNil)
+			continue
+		}
+		c.Assert(schedErr, check.ErrorMatches, `.*pool "mypool" is full: no nodes found with enough memory for container of "oblivion".*`)
+	}
+	evts, err := event.List(&event.Filter{KindNames: []string{"pool-full"}})
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 1)
+	c.Assert(evts[0].Target, check.Equals, event.Target{Type: event.TargetTypePool, Value: "mypool"})
+	c.Assert(evts[0].Error, check.Matches, `pool "mypool" is full.*`)
+}
+
 func (s *S) TestChooseNodeDistributesNodesEqually(c *check.C) {
 	originalMaxProcs := runtime.GOMAXPROCS(10)
 	defer runtime.GOMAXPROCS(originalMaxProcs)
@@ -633,6 +679,96 @@ func (s *S) TestChooseNodeDistributesNodesConsideringMetadata(c *check.C) {
 	c.Assert(n3, check.Equals, 1)
 }
 
+func (s *S) TestChooseNodeDistributesNodesConsideringZoneMetadata(c *check.C) {
+	nodes := []cluster.Node{
+		{Address: "http://server1:1234", Metadata: map[string]string{
+			"zone": "a",
+			"rack": "1",
+		}},
+		{Address: "http://server2:1234", Metadata: map[string]string{
+			"zone": "a",
+			"rack": "2",
+		}},
+		{Address: "http://server3:1234", Metadata: map[string]string{
+			"zone": "b",
+			"rack": "3",
+		}},
+	}
+	err := featureflag.Save(featureflag.Flag{Name: zoneSchedulerFlag, Enabled: true})
+	c.Assert(err, check.IsNil)
+	defer featureflag.Remove(zoneSchedulerFlag)
+	sched := segregatedScheduler{provisioner: s.p, ZoneMetadata: "zone"}
+	contColl := s.p.Collection()
+	defer contColl.Close()
+	for i := 0; i < 2; i++ {
+		cont := container.Container{Container: types.Container{Name: fmt.Sprintf("unit%d", i), AppName: "anomander", ProcessName: "rake"}}
+		err = contColl.Insert(cont)
+		c.Assert(err, check.IsNil)
+		_, err = sched.chooseNodeToAdd(nodes, cont.Name, "anomander", "rake")
+		c.Assert(err, check.IsNil)
+	}
+	n1, err := contColl.Find(bson.M{"hostaddr": "server1"}).Count()
+	c.Assert(err, check.IsNil)
+	n2, err := contColl.Find(bson.M{"hostaddr": "server2"}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n1+n2, check.Equals, 1)
+	n3, err := contColl.Find(bson.M{"hostaddr": "server3"}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n3, check.Equals, 1)
+}
+
+func (s *S) TestHostGroupsZoneMetadataGatedByFlag(c *check.C) {
+	nodes := []cluster.Node{
+		{Address: "http://server1:1234", Metadata: map[string]string{"zone": "a", "rack": "1"}},
+		{Address: "http://server2:1234", Metadata: map[string]string{"zone": "a", "rack": "2"}},
+	}
+	sched := segregatedScheduler{provisioner: s.p, ZoneMetadata: "zone"}
+	groups := sched.hostGroups(nodes, true)
+	c.Assert(groups["server1"], check.Equals, groups["server2"])
+	groups = sched.hostGroups(nodes, false)
+	c.Assert(groups["server1"], check.Not(check.Equals), groups["server2"])
+	a := app.App{Name: "anomander", TeamOwner: "bridgeburners"}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	spread, zones := appSpread(a.Name)
+	c.Assert(spread, check.Equals, true)
+	c.Assert(zones, check.Equals, false)
+	err = featureflag.Save(featureflag.Flag{Name: zoneSchedulerFlag, Teams: []string{"bridgeburners"}})
+	c.Assert(err, check.IsNil)
+	defer featureflag.Remove(zoneSchedulerFlag)
+	spread, zones = appSpread(a.Name)
+	c.Assert(spread, check.Equals, true)
+	c.Assert(zones, check.Equals, true)
+}
+
+func (s *S) TestChooseNodeWithSpreadDisabled(c *check.C) {
+	spread := false
+	a := app.App{Name: "anomander", Spread: &spread}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	nodes := []cluster.Node{
+		{Address: "http://server1:1234"},
+		{Address: "http://server2:1234"},
+	}
+	contColl := s.p.Collection()
+	defer contColl.Close()
+	err = contColl.Insert(
+		container.Container{Container: types.Container{Name: "other1", AppName: "karsa", HostAddr: "server1"}},
+		container.Container{Container: types.Container{Name: "other2", AppName: "karsa", HostAddr: "server1"}},
+	)
+	c.Assert(err, check.IsNil)
+	sched := segregatedScheduler{provisioner: s.p}
+	for i := 0; i < 2; i++ {
+		cont := container.Container{Container: types.Container{Name: fmt.Sprintf("unit%d", i), AppName: "anomander", ProcessName: "rake"}}
+		err = contColl.Insert(cont)
+		c.Assert(err, check.IsNil)
+		var node string
+		node, err = sched.chooseNodeToAdd(nodes, cont.Name, "anomander", "rake")
+		c.Assert(err, check.IsNil)
+		c.Assert(node, check.Equals, "http://server2:1234")
+	}
+}
+
 func (s *S) TestChooseContainerToBeRemoved(c *check.C) {
 	nodes := []cluster.Node{
 		{Address: "http://server1:1234"},
@@ -7,6 +7,7 @@ package types
 import (
 	"time"
 
+	"github.com/fsouza/go-dockerclient"
 	"github.com/tsuru/tsuru/provision"
 	"gopkg.in/mgo.v2/bson"
 )
@@ -33,6 +34,7 @@ type Container struct {
 	LockedUntil             time.Time
 	Routable                bool `bson:"-"`
 	ExposedPort             string
+	ResourceOverride        *provision.UnitResources `bson:",omitempty"`
 }
 
 type DockerLogConfig struct {
@@ -40,6 +42,11 @@ type DockerLogConfig struct {
 	LogOpts map[string]string
 }
 
+type ContainerOptions struct {
+	PullPolicy string
+	Ulimits    []docker.ULimit
+}
+
 type HealingEvent struct {
 	ID               interface{} `bson:"_id"`
 	StartTime        time.Time
@@ -108,6 +108,9 @@ func LeanContainerCmdsWithExtra(processName, imageID string, app provision.App,
 	if err != nil {
 		return nil, "", err
 	}
+	if metadataCmd := provision.MetadataFileCmd(app); metadataCmd != "" {
+		extraCmds = append([]string{metadataCmd}, extraCmds...)
+	}
 	extraCmds = append(extraCmds, yamlData.Hooks.Restart.Before...)
 	before := strings.Join(extraCmds, " && ")
 	if before != "" {
@@ -16,6 +16,9 @@ import (
 	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/dockercommon"
 	"github.com/tsuru/tsuru/provision/provisiontest"
+	"github.com/tsuru/tsuru/storage"
+	_ "github.com/tsuru/tsuru/storage/mongodb"
+	appTypes "github.com/tsuru/tsuru/types/app"
 	"gopkg.in/check.v1"
 )
 
@@ -135,6 +138,27 @@ func (s *S) TestRunLeanContainersCmdHooks(c *check.C) {
 	c.Assert(cmds, check.DeepEquals, expected)
 }
 
+func (s *S) TestRunLeanContainersCmdJSONEnvFormat(c *check.C) {
+	imageID := "tsuru/app-sample"
+	customData := map[string]interface{}{
+		"processes": map[string]interface{}{
+			"web": "python web.py",
+		},
+	}
+	err := image.SaveImageCustomData(imageID, customData)
+	c.Assert(err, check.IsNil)
+	driver, err := storage.GetCurrentDbDriver()
+	c.Assert(err, check.IsNil)
+	err = driver.PlatformService.Insert(appTypes.Platform{Name: "python", EnvFormat: provision.EnvFormatJSON})
+	c.Assert(err, check.IsNil)
+	app := provisiontest.NewFakeApp("app-name", "python", 1)
+	cmds, process, err := dockercommon.LeanContainerCmds("web", imageID, app)
+	c.Assert(err, check.IsNil)
+	c.Assert(process, check.Equals, "web")
+	expected := []string{"/bin/sh", "-lc", `[ -d /home/application/current ] && cd /home/application/current; printf '%s' "$TSURU_APP_METADATA" > /home/application/tsuru-metadata.json && exec python web.py`}
+	c.Assert(cmds, check.DeepEquals, expected)
+}
+
 func (s *S) TestRunLeanContainersCmdNoProcesses(c *check.C) {
 	imageID := "tsuru/app-sample"
 	customData := map[string]interface{}{}
@@ -5,12 +5,54 @@
 package provision
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"regexp"
+	"strings"
 
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/storage"
 )
 
+const (
+	// EnvFormatPlain is the original env injection format, units receive
+	// the app environment only as environment variables.
+	EnvFormatPlain = 1
+	// EnvFormatJSON adds the app metadata, including the environment and
+	// the service binds, as a JSON document written to MetadataFilePath
+	// before the unit starts.
+	EnvFormatJSON = 2
+
+	MetadataFilePath = "/home/application/tsuru-metadata.json"
+
+	metadataEnvVar = "TSURU_APP_METADATA"
+)
+
+var envRefNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// EnvCycleError is returned by InterpolateEnvs when environment variables
+// reference each other in a loop.
+type EnvCycleError struct {
+	Cycle []string
+}
+
+func (e *EnvCycleError) Error() string {
+	return "environment variables reference each other in a cycle: " + strings.Join(e.Cycle, " -> ")
+}
+
+// AppMetadata is the document given to units using EnvFormatJSON.
+type AppMetadata struct {
+	Version  int               `json:"version"`
+	App      string            `json:"app"`
+	Platform string            `json:"platform"`
+	Pool     string            `json:"pool"`
+	Process  string            `json:"process,omitempty"`
+	Envs     map[string]string `json:"envs"`
+	Services json.RawMessage   `json:"services,omitempty"`
+}
+
 func WebProcessDefaultPort() string {
 	port, err := config.Get("docker:run-cmd:port")
 	if err != nil {
@@ -19,13 +61,156 @@ func WebProcessDefaultPort() string {
 	return fmt.Sprint(port)
 }
 
+// EnvFormatForApp returns the env injection format supported by the platform
+// of the app. Platforms that don't declare a format use EnvFormatPlain.
+func EnvFormatForApp(a App) int {
+	if a == nil || a.GetPlatform() == "" {
+		return EnvFormatPlain
+	}
+	dbDriver, err := storage.GetCurrentDbDriver()
+	if err != nil {
+		dbDriver, err = storage.GetDefaultDbDriver()
+		if err != nil {
+			return EnvFormatPlain
+		}
+	}
+	platform, err := dbDriver.PlatformService.FindByName(a.GetPlatform())
+	if err != nil || platform.EnvFormat == 0 {
+		return EnvFormatPlain
+	}
+	return platform.EnvFormat
+}
+
+func appMetadata(a App, process string, envs map[string]bind.EnvVar) AppMetadata {
+	metadata := AppMetadata{
+		Version:  EnvFormatJSON,
+		App:      a.GetName(),
+		Platform: a.GetPlatform(),
+		Pool:     a.GetPool(),
+		Process:  process,
+		Envs:     make(map[string]string, len(envs)),
+	}
+	for name, envData := range envs {
+		if name == "TSURU_SERVICES" {
+			metadata.Services = json.RawMessage(envData.Value)
+			continue
+		}
+		metadata.Envs[name] = envData.Value
+	}
+	return metadata
+}
+
+// InterpolateEnvs returns a copy of envs where, in the values of the
+// variables with Interpolate set, references in the form ${NAME} are replaced
+// by the value of the variable NAME, which may be a variable exported by a
+// service instance. References to unknown variables are kept as is and
+// $${NAME} escapes a reference. Values of the other variables, including the
+// ones set before interpolation was available, are kept unchanged.
+func InterpolateEnvs(envs map[string]bind.EnvVar) (map[string]bind.EnvVar, error) {
+	resolved := make(map[string]bind.EnvVar, len(envs))
+	var path []string
+	visiting := map[string]bool{}
+	var resolve func(name string) (string, error)
+	resolve = func(name string) (string, error) {
+		if env, ok := resolved[name]; ok {
+			return env.Value, nil
+		}
+		env := envs[name]
+		if !env.Interpolate {
+			resolved[name] = env
+			return env.Value, nil
+		}
+		if visiting[name] {
+			cycle := append([]string{}, path...)
+			for i := range cycle {
+				if cycle[i] == name {
+					cycle = cycle[i:]
+					break
+				}
+			}
+			return "", &EnvCycleError{Cycle: append(cycle, name)}
+		}
+		visiting[name] = true
+		path = append(path, name)
+		value, err := expandEnvRefs(env.Value, envs, resolve)
+		if err != nil {
+			return "", err
+		}
+		path = path[:len(path)-1]
+		env.Value = value
+		resolved[name] = env
+		return value, nil
+	}
+	for name := range envs {
+		if _, err := resolve(name); err != nil {
+			return nil, err
+		}
+	}
+	return resolved, nil
+}
+
+func expandEnvRefs(value string, envs map[string]bind.EnvVar, resolve func(string) (string, error)) (string, error) {
+	if !strings.Contains(value, "${") {
+		return value, nil
+	}
+	var buf bytes.Buffer
+	for len(value) > 0 {
+		i := strings.Index(value, "${")
+		if i < 0 {
+			buf.WriteString(value)
+			break
+		}
+		if i > 0 && value[i-1] == '$' {
+			buf.WriteString(value[:i-1])
+			buf.WriteString("${")
+			value = value[i+2:]
+			continue
+		}
+		buf.WriteString(value[:i])
+		end := strings.Index(value[i:], "}")
+		if end < 0 {
+			buf.WriteString(value[i:])
+			break
+		}
+		ref := value[i+2 : i+end]
+		if _, ok := envs[ref]; !ok || !envRefNameRegexp.MatchString(ref) {
+			buf.WriteString(value[i : i+end+1])
+		} else {
+			refValue, err := resolve(ref)
+			if err != nil {
+				return "", err
+			}
+			buf.WriteString(refValue)
+		}
+		value = value[i+end+1:]
+	}
+	return buf.String(), nil
+}
+
 func EnvsForApp(a App, process string, isDeploy bool) []bind.EnvVar {
 	var envs []bind.EnvVar
 	if !isDeploy {
-		for _, envData := range a.Envs() {
+		appEnvs := a.Envs()
+		if interpolated, err := InterpolateEnvs(appEnvs); err != nil {
+			logger.Errorf("unable to interpolate envs for app %q: %s", a.GetName(), err)
+		} else {
+			appEnvs = interpolated
+		}
+		for _, envData := range appEnvs {
 			envs = append(envs, envData)
 		}
 		envs = append(envs, bind.EnvVar{Name: "TSURU_PROCESSNAME", Value: process})
+		if format := EnvFormatForApp(a); format != EnvFormatPlain {
+			data, err := json.Marshal(appMetadata(a, process, appEnvs))
+			if err != nil {
+				logger.Errorf("unable to encode metadata for app %q: %s", a.GetName(), err)
+			} else {
+				envs = append(envs, []bind.EnvVar{
+					{Name: "TSURU_ENV_FORMAT", Value: fmt.Sprint(format)},
+					{Name: metadataEnvVar, Value: string(data)},
+				}...)
+			}
+		}
 	}
 	host, _ := config.GetString("host")
 	envs = append(envs, bind.EnvVar{Name: "TSURU_HOST", Value: host})
@@ -38,3 +223,13 @@ func EnvsForApp(a App, process string, isDeploy bool) []bind.EnvVar {
 	}
 	return envs
 }
+
+// MetadataFileCmd returns the shell command that writes the app metadata to
+// MetadataFilePath, or an empty string when the platform of the app uses
+// EnvFormatPlain.
+func MetadataFileCmd(a App) string {
+	if EnvFormatForApp(a) == EnvFormatPlain {
+		return ""
+	}
+	return fmt.Sprintf(`printf '%%s' "$%s" > %s`, metadataEnvVar, MetadataFilePath)
+}
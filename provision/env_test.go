@@ -5,13 +5,53 @@
 package provision_test
 
 import (
+	"encoding/json"
+
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/dbtest"
 	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/provisiontest"
+	"github.com/tsuru/tsuru/storage"
+	_ "github.com/tsuru/tsuru/storage/mongodb"
+	appTypes "github.com/tsuru/tsuru/types/app"
 	"gopkg.in/check.v1"
 )
 
+type EnvSuite struct {
+	conn *db.Storage
+}
+
+var _ = check.Suite(&EnvSuite{})
+
+func (s *EnvSuite) SetUpSuite(c *check.C) {
+	config.Set("database:driver", "mongodb")
+	config.Set("database:url", "127.0.0.1:27017")
+	config.Set("database:name", "provision_env_tests")
+	var err error
+	s.conn, err = db.Conn()
+	c.Assert(err, check.IsNil)
+}
+
+func (s *EnvSuite) TearDownSuite(c *check.C) {
+	s.conn.Apps().Database.DropDatabase()
+	s.conn.Close()
+	config.Unset("database:driver")
+}
+
+func (s *EnvSuite) SetUpTest(c *check.C) {
+	err := dbtest.ClearAllCollections(s.conn.Apps().Database)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *EnvSuite) addPlatform(c *check.C, p appTypes.Platform) {
+	driver, err := storage.GetCurrentDbDriver()
+	c.Assert(err, check.IsNil)
+	err = driver.PlatformService.Insert(p)
+	c.Assert(err, check.IsNil)
+}
+
 func (s *S) TestWebProcessDefaultPort(c *check.C) {
 	port := provision.WebProcessDefaultPort()
 	c.Assert(port, check.Equals, "8888")
@@ -41,6 +81,70 @@ func (s *S) TestEnvsForApp(c *check.C) {
 	})
 }
 
+func (s *S) TestEnvsForAppInterpolated(c *check.C) {
+	a := provisiontest.NewFakeApp("myapp", "crystal", 1)
+	a.SetEnv(bind.EnvVar{Name: "DATABASE_URL", Value: "mysql://${DATABASE_HOST}:${DATABASE_PORT}/db", Interpolate: true})
+	a.SetEnv(bind.EnvVar{Name: "DATABASE_HOST", Value: "localhost"})
+	a.SetEnv(bind.EnvVar{Name: "DATABASE_PORT", Value: "3306"})
+	envs := provision.EnvsForApp(a, "p1", false)
+	c.Assert(envs, check.HasLen, 7)
+	var found bool
+	for _, e := range envs {
+		if e.Name == "DATABASE_URL" {
+			found = true
+			c.Assert(e.Value, check.Equals, "mysql://localhost:3306/db")
+		}
+	}
+	c.Assert(found, check.Equals, true)
+}
+
+func (s *S) TestInterpolateEnvs(c *check.C) {
+	envs := map[string]bind.EnvVar{
+		"HOST":      {Name: "HOST", Value: "localhost"},
+		"PORT":      {Name: "PORT", Value: "${BASE_PORT}1", Public: true, Interpolate: true},
+		"URL":       {Name: "URL", Value: "http://${HOST}:${PORT}/${UNKNOWN}", Interpolate: true},
+		"ESCAPED":   {Name: "ESCAPED", Value: "$${HOST} ${HOST", Interpolate: true},
+		"LITERAL":   {Name: "LITERAL", Value: "$${HOST}${PORT}"},
+		"BASE_PORT": {Name: "BASE_PORT", Value: "808"},
+	}
+	result, err := provision.InterpolateEnvs(envs)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, map[string]bind.EnvVar{
+		"HOST":      {Name: "HOST", Value: "localhost"},
+		"PORT":      {Name: "PORT", Value: "8081", Public: true, Interpolate: true},
+		"URL":       {Name: "URL", Value: "http://localhost:8081/${UNKNOWN}", Interpolate: true},
+		"ESCAPED":   {Name: "ESCAPED", Value: "${HOST} ${HOST", Interpolate: true},
+		"LITERAL":   {Name: "LITERAL", Value: "$${HOST}${PORT}"},
+		"BASE_PORT": {Name: "BASE_PORT", Value: "808"},
+	})
+	c.Assert(envs["URL"].Value, check.Equals, "http://${HOST}:${PORT}/${UNKNOWN}")
+}
+
+func (s *S) TestInterpolateEnvsCycle(c *check.C) {
+	envs := map[string]bind.EnvVar{
+		"A": {Name: "A", Value: "${B}", Interpolate: true},
+		"B": {Name: "B", Value: "x${C}", Interpolate: true},
+		"C": {Name: "C", Value: "${A}", Interpolate: true},
+	}
+	_, err := provision.InterpolateEnvs(envs)
+	c.Assert(err, check.FitsTypeOf, &provision.EnvCycleError{})
+	c.Assert(err.(*provision.EnvCycleError).Cycle, check.HasLen, 4)
+	_, err = provision.InterpolateEnvs(map[string]bind.EnvVar{
+		"A": {Name: "A", Value: "${A}", Interpolate: true},
+	})
+	c.Assert(err, check.ErrorMatches, `environment variables reference each other in a cycle: A -> A`)
+}
+
+func (s *S) TestInterpolateEnvsNotOptedIn(c *check.C) {
+	envs := map[string]bind.EnvVar{
+		"A": {Name: "A", Value: "${B}"},
+		"B": {Name: "B", Value: "${A}"},
+	}
+	result, err := provision.InterpolateEnvs(envs)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, envs)
+}
+
 func (s *S) TestEnvsForAppCustomConfig(c *check.C) {
 	config.Set("host", "cloud.tsuru.io")
 	config.Set("docker:run-cmd:port", "8989")
@@ -61,3 +165,48 @@ func (s *S) TestEnvsForAppCustomConfig(c *check.C) {
 		{Name: "TSURU_HOST", Value: "cloud.tsuru.io"},
 	})
 }
+
+func (s *EnvSuite) TestEnvFormatForApp(c *check.C) {
+	a := provisiontest.NewFakeApp("myapp", "crystal", 1)
+	c.Assert(provision.EnvFormatForApp(a), check.Equals, provision.EnvFormatPlain)
+	s.addPlatform(c, appTypes.Platform{Name: "crystal"})
+	c.Assert(provision.EnvFormatForApp(a), check.Equals, provision.EnvFormatPlain)
+	s.addPlatform(c, appTypes.Platform{Name: "elixir", EnvFormat: provision.EnvFormatJSON})
+	a = provisiontest.NewFakeApp("myapp", "elixir", 1)
+	c.Assert(provision.EnvFormatForApp(a), check.Equals, provision.EnvFormatJSON)
+}
+
+func (s *EnvSuite) TestEnvsForAppJSONFormat(c *check.C) {
+	s.addPlatform(c, appTypes.Platform{Name: "elixir", EnvFormat: provision.EnvFormatJSON})
+	a := provisiontest.NewFakeApp("myapp", "elixir", 1)
+	a.Pool = "mypool"
+	a.SetEnv(bind.EnvVar{Name: "e1", Value: "v1"})
+	a.SetEnv(bind.EnvVar{Name: "TSURU_SERVICES", Value: `{"mysql":[{"instance_name":"db","envs":{"DB_HOST":"localhost"}}]}`})
+	envs := provision.EnvsForApp(a, "web", false)
+	envMap := map[string]string{}
+	for _, e := range envs {
+		envMap[e.Name] = e.Value
+	}
+	c.Assert(envMap["TSURU_ENV_FORMAT"], check.Equals, "2")
+	var metadata provision.AppMetadata
+	err := json.Unmarshal([]byte(envMap["TSURU_APP_METADATA"]), &metadata)
+	c.Assert(err, check.IsNil)
+	c.Assert(metadata.Version, check.Equals, provision.EnvFormatJSON)
+	c.Assert(metadata.App, check.Equals, "myapp")
+	c.Assert(metadata.Platform, check.Equals, "elixir")
+	c.Assert(metadata.Pool, check.Equals, "mypool")
+	c.Assert(metadata.Process, check.Equals, "web")
+	c.Assert(metadata.Envs, check.DeepEquals, map[string]string{"e1": "v1"})
+	c.Assert(string(metadata.Services), check.Equals, `{"mysql":[{"instance_name":"db","envs":{"DB_HOST":"localhost"}}]}`)
+	envs = provision.EnvsForApp(a, "web", true)
+	c.Assert(envs, check.DeepEquals, []bind.EnvVar{
+		{Name: "TSURU_HOST", Value: ""},
+	})
+}
+
+func (s *EnvSuite) TestMetadataFileCmd(c *check.C) {
+	a := provisiontest.NewFakeApp("myapp", "elixir", 1)
+	c.Assert(provision.MetadataFileCmd(a), check.Equals, "")
+	s.addPlatform(c, appTypes.Platform{Name: "elixir", EnvFormat: provision.EnvFormatJSON})
+	c.Assert(provision.MetadataFileCmd(a), check.Equals, `printf '%s' "$TSURU_APP_METADATA" > /home/application/tsuru-metadata.json`)
+}
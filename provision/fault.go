@@ -0,0 +1,328 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+import (
+	"io"
+	"math/rand"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/event"
+)
+
+const (
+	faultInjectionConfig = "provision:fault-injection"
+
+	defaultFaultMaxDelay = 5 * time.Second
+)
+
+// ErrInjectedFault is the cause of the errors returned by operations failed
+// on purpose by the fault injection wrapper.
+var ErrInjectedFault = errors.New("injected fault")
+
+var (
+	faultRandFloat = rand.Float64
+	faultSleep     = time.Sleep
+)
+
+// FaultSettings describes the faults injected in provisioner operations,
+// meant to exercise healing, retries and queues in staging environments.
+// FailureRate and DelayRate are the fractions, between 0 and 1, of the
+// operations that fail or are delayed by up to MaxDelay. Empty Provisioners
+// and Operations mean all of them.
+type FaultSettings struct {
+	FailureRate  float64
+	DelayRate    float64
+	MaxDelay     time.Duration
+	Provisioners []string
+	Operations   []string
+}
+
+// GetFaultSettings returns the settings in the provision:fault-injection
+// config entry, or nil when fault injection is disabled.
+func GetFaultSettings() (*FaultSettings, error) {
+	if _, err := config.Get(faultInjectionConfig); err != nil {
+		return nil, nil
+	}
+	var settings FaultSettings
+	settings.FailureRate, _ = config.GetFloat(faultInjectionConfig + ":failure-rate")
+	settings.DelayRate, _ = config.GetFloat(faultInjectionConfig + ":delay-rate")
+	if settings.FailureRate < 0 || settings.FailureRate > 1 {
+		return nil, errors.Errorf("%s:failure-rate must be between 0 and 1", faultInjectionConfig)
+	}
+	if settings.DelayRate < 0 || settings.DelayRate > 1 {
+		return nil, errors.Errorf("%s:delay-rate must be between 0 and 1", faultInjectionConfig)
+	}
+	if settings.FailureRate == 0 && settings.DelayRate == 0 {
+		return nil, nil
+	}
+	settings.MaxDelay, _ = config.GetDuration(faultInjectionConfig + ":max-delay")
+	if settings.MaxDelay <= 0 {
+		settings.MaxDelay = defaultFaultMaxDelay
+	}
+	settings.Provisioners, _ = config.GetList(faultInjectionConfig + ":provisioners")
+	settings.Operations, _ = config.GetList(faultInjectionConfig + ":operations")
+	return &settings, nil
+}
+
+func (s *FaultSettings) affectsProvisioner(name string) bool {
+	return len(s.Provisioners) == 0 || containsString(s.Provisioners, name)
+}
+
+func (s *FaultSettings) affectsOperation(op string) bool {
+	return len(s.Operations) == 0 || containsString(s.Operations, op)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// faultInjectable is the set of interfaces a provisioner must implement to be
+// wrapped with faults, the same ones implemented by the docker provisioner.
+// Callers look for optional interfaces with type assertions, so wrapping a
+// provisioner implementing a different set would change its behavior.
+type faultInjectable interface {
+	Provisioner
+	BuilderDeploy
+	RollbackableDeployer
+	ShellProvisioner
+	ExecutableProvisioner
+	SleepableProvisioner
+	MessageProvisioner
+	InitializableProvisioner
+	OptionalLogsProvisioner
+	UnitStatusProvisioner
+	UnitProvisioner
+	UnitResourcesProvisioner
+	NodeProvisioner
+	NodeRebalanceProvisioner
+	NodeContainerProvisioner
+	UnitFinderProvisioner
+	AppFilterProvisioner
+}
+
+// withFaults wraps the provisioner with fault injection, when it's enabled
+// for the provisioner and the provisioner supports it.
+func withFaults(p Provisioner) (Provisioner, error) {
+	settings, err := GetFaultSettings()
+	if err != nil || settings == nil {
+		return p, err
+	}
+	if !settings.affectsProvisioner(p.GetName()) {
+		return p, nil
+	}
+	inner, ok := p.(faultInjectable)
+	if !ok {
+		return p, nil
+	}
+	return &faultProvisioner{faultInjectable: inner, settings: settings}, nil
+}
+
+// faultProvisioner randomly delays and fails the operations of the wrapped
+// provisioner. Operations only reading data used by the API itself, like
+// listing nodes, are never affected.
+type faultProvisioner struct {
+	faultInjectable
+	settings *FaultSettings
+}
+
+func (p *faultProvisioner) inject(op string) error {
+	if !p.settings.affectsOperation(op) {
+		return nil
+	}
+	if faultRandFloat() < p.settings.DelayRate {
+		delay := time.Duration(faultRandFloat() * float64(p.settings.MaxDelay))
+		logger.Debugf("injecting delay of %v in %s %s", delay, p.GetName(), op)
+		faultSleep(delay)
+	}
+	if faultRandFloat() < p.settings.FailureRate {
+		logger.Debugf("injecting failure in %s %s", p.GetName(), op)
+		return errors.Wrapf(ErrInjectedFault, "%s %s", p.GetName(), op)
+	}
+	return nil
+}
+
+func (p *faultProvisioner) Provision(a App) error {
+	if err := p.inject("Provision"); err != nil {
+		return err
+	}
+	return p.faultInjectable.Provision(a)
+}
+
+func (p *faultProvisioner) Destroy(a App) error {
+	if err := p.inject("Destroy"); err != nil {
+		return err
+	}
+	return p.faultInjectable.Destroy(a)
+}
+
+func (p *faultProvisioner) AddUnits(a App, units uint, process string, w io.Writer) error {
+	if err := p.inject("AddUnits"); err != nil {
+		return err
+	}
+	return p.faultInjectable.AddUnits(a, units, process, w)
+}
+
+func (p *faultProvisioner) RemoveUnits(a App, units uint, process string, w io.Writer) error {
+	if err := p.inject("RemoveUnits"); err != nil {
+		return err
+	}
+	return p.faultInjectable.RemoveUnits(a, units, process, w)
+}
+
+func (p *faultProvisioner) Restart(a App, process string, w io.Writer) error {
+	if err := p.inject("Restart"); err != nil {
+		return err
+	}
+	return p.faultInjectable.Restart(a, process, w)
+}
+
+func (p *faultProvisioner) Start(a App, process string) error {
+	if err := p.inject("Start"); err != nil {
+		return err
+	}
+	return p.faultInjectable.Start(a, process)
+}
+
+func (p *faultProvisioner) Stop(a App, process string) error {
+	if err := p.inject("Stop"); err != nil {
+		return err
+	}
+	return p.faultInjectable.Stop(a, process)
+}
+
+func (p *faultProvisioner) Sleep(a App, process string) error {
+	if err := p.inject("Sleep"); err != nil {
+		return err
+	}
+	return p.faultInjectable.Sleep(a, process)
+}
+
+func (p *faultProvisioner) Units(a App) ([]Unit, error) {
+	if err := p.inject("Units"); err != nil {
+		return nil, err
+	}
+	return p.faultInjectable.Units(a)
+}
+
+func (p *faultProvisioner) RoutableAddresses(a App) ([]url.URL, error) {
+	if err := p.inject("RoutableAddresses"); err != nil {
+		return nil, err
+	}
+	return p.faultInjectable.RoutableAddresses(a)
+}
+
+func (p *faultProvisioner) RegisterUnit(a App, unitID string, customData map[string]interface{}) error {
+	if err := p.inject("RegisterUnit"); err != nil {
+		return err
+	}
+	return p.faultInjectable.RegisterUnit(a, unitID, customData)
+}
+
+func (p *faultProvisioner) Deploy(a App, image string, evt *event.Event) (string, error) {
+	if err := p.inject("Deploy"); err != nil {
+		return "", err
+	}
+	return p.faultInjectable.Deploy(a, image, evt)
+}
+
+func (p *faultProvisioner) Rollback(a App, image string, evt *event.Event) (string, error) {
+	if err := p.inject("Rollback"); err != nil {
+		return "", err
+	}
+	return p.faultInjectable.Rollback(a, image, evt)
+}
+
+func (p *faultProvisioner) ExecuteCommand(stdout, stderr io.Writer, a App, cmd string, args ...string) error {
+	if err := p.inject("ExecuteCommand"); err != nil {
+		return err
+	}
+	return p.faultInjectable.ExecuteCommand(stdout, stderr, a, cmd, args...)
+}
+
+func (p *faultProvisioner) ExecuteCommandOnce(stdout, stderr io.Writer, a App, cmd string, args ...string) error {
+	if err := p.inject("ExecuteCommandOnce"); err != nil {
+		return err
+	}
+	return p.faultInjectable.ExecuteCommandOnce(stdout, stderr, a, cmd, args...)
+}
+
+func (p *faultProvisioner) ExecuteCommandIsolated(stdout, stderr io.Writer, a App, cmd string, args ...string) error {
+	if err := p.inject("ExecuteCommandIsolated"); err != nil {
+		return err
+	}
+	return p.faultInjectable.ExecuteCommandIsolated(stdout, stderr, a, cmd, args...)
+}
+
+func (p *faultProvisioner) SetUnitStatus(unit Unit, status Status) error {
+	if err := p.inject("SetUnitStatus"); err != nil {
+		return err
+	}
+	return p.faultInjectable.SetUnitStatus(unit, status)
+}
+
+func (p *faultProvisioner) RestartUnit(a App, unitID string, w io.Writer) error {
+	if err := p.inject("RestartUnit"); err != nil {
+		return err
+	}
+	return p.faultInjectable.RestartUnit(a, unitID, w)
+}
+
+func (p *faultProvisioner) SetUnitResources(a App, unitID string, resources UnitResources) error {
+	if err := p.inject("SetUnitResources"); err != nil {
+		return err
+	}
+	return p.faultInjectable.SetUnitResources(a, unitID, resources)
+}
+
+func (p *faultProvisioner) AddNode(opts AddNodeOptions) error {
+	if err := p.inject("AddNode"); err != nil {
+		return err
+	}
+	return p.faultInjectable.AddNode(opts)
+}
+
+func (p *faultProvisioner) RemoveNode(opts RemoveNodeOptions) error {
+	if err := p.inject("RemoveNode"); err != nil {
+		return err
+	}
+	return p.faultInjectable.RemoveNode(opts)
+}
+
+func (p *faultProvisioner) UpdateNode(opts UpdateNodeOptions) error {
+	if err := p.inject("UpdateNode"); err != nil {
+		return err
+	}
+	return p.faultInjectable.UpdateNode(opts)
+}
+
+func (p *faultProvisioner) RebalanceNodes(opts RebalanceNodesOptions) (bool, error) {
+	if err := p.inject("RebalanceNodes"); err != nil {
+		return false, err
+	}
+	return p.faultInjectable.RebalanceNodes(opts)
+}
+
+func (p *faultProvisioner) UpgradeNodeContainer(name string, pool string, w io.Writer) error {
+	if err := p.inject("UpgradeNodeContainer"); err != nil {
+		return err
+	}
+	return p.faultInjectable.UpgradeNodeContainer(name, pool, w)
+}
+
+func (p *faultProvisioner) RemoveNodeContainer(name string, pool string, w io.Writer) error {
+	if err := p.inject("RemoveNodeContainer"); err != nil {
+		return err
+	}
+	return p.faultInjectable.RemoveNodeContainer(name, pool, w)
+}
@@ -0,0 +1,155 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+import (
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+)
+
+type faultTestProvisioner struct {
+	faultInjectable
+	restarts int
+}
+
+func (p *faultTestProvisioner) GetName() string {
+	return "faulty"
+}
+
+func (p *faultTestProvisioner) Restart(App, string, io.Writer) error {
+	p.restarts++
+	return nil
+}
+
+type basicTestProvisioner struct {
+	Provisioner
+}
+
+func (p *basicTestProvisioner) GetName() string {
+	return "basic"
+}
+
+func setFaultRand(values ...float64) func() {
+	oldRand, oldSleep := faultRandFloat, faultSleep
+	faultRandFloat = func() float64 {
+		v := values[0]
+		values = values[1:]
+		return v
+	}
+	faultSleep = func(time.Duration) {}
+	return func() {
+		faultRandFloat, faultSleep = oldRand, oldSleep
+	}
+}
+
+func (ProvisionSuite) TestGetFaultSettings(c *check.C) {
+	settings, err := GetFaultSettings()
+	c.Assert(err, check.IsNil)
+	c.Assert(settings, check.IsNil)
+	config.Set("provision:fault-injection:failure-rate", 0.1)
+	config.Set("provision:fault-injection:delay-rate", 0.5)
+	config.Set("provision:fault-injection:max-delay", "30s")
+	config.Set("provision:fault-injection:provisioners", []interface{}{"docker"})
+	defer config.Unset("provision:fault-injection")
+	settings, err = GetFaultSettings()
+	c.Assert(err, check.IsNil)
+	c.Assert(settings, check.DeepEquals, &FaultSettings{
+		FailureRate:  0.1,
+		DelayRate:    0.5,
+		MaxDelay:     30 * time.Second,
+		Provisioners: []string{"docker"},
+	})
+}
+
+func (ProvisionSuite) TestGetFaultSettingsInvalidRate(c *check.C) {
+	config.Set("provision:fault-injection:failure-rate", 1.5)
+	defer config.Unset("provision:fault-injection")
+	_, err := GetFaultSettings()
+	c.Assert(err, check.ErrorMatches, "provision:fault-injection:failure-rate must be between 0 and 1")
+}
+
+func (ProvisionSuite) TestGetWithFaults(c *check.C) {
+	p := &faultTestProvisioner{}
+	Register("faulty", func() (Provisioner, error) { return p, nil })
+	got, err := Get("faulty")
+	c.Assert(err, check.IsNil)
+	c.Assert(got, check.Equals, p)
+	config.Set("provision:fault-injection:failure-rate", 0.5)
+	defer config.Unset("provision:fault-injection")
+	got, err = Get("faulty")
+	c.Assert(err, check.IsNil)
+	c.Assert(got, check.DeepEquals, &faultProvisioner{
+		faultInjectable: p,
+		settings:        &FaultSettings{FailureRate: 0.5, MaxDelay: defaultFaultMaxDelay},
+	})
+	_, ok := got.(NodeProvisioner)
+	c.Assert(ok, check.Equals, true)
+}
+
+func (ProvisionSuite) TestGetWithFaultsUnsupportedProvisioner(c *check.C) {
+	p := &basicTestProvisioner{}
+	Register("basic", func() (Provisioner, error) { return p, nil })
+	config.Set("provision:fault-injection:failure-rate", 0.5)
+	defer config.Unset("provision:fault-injection")
+	got, err := Get("basic")
+	c.Assert(err, check.IsNil)
+	c.Assert(got, check.Equals, p)
+}
+
+func (ProvisionSuite) TestGetWithFaultsOtherProvisioner(c *check.C) {
+	p := &faultTestProvisioner{}
+	Register("faulty", func() (Provisioner, error) { return p, nil })
+	config.Set("provision:fault-injection:failure-rate", 0.5)
+	config.Set("provision:fault-injection:provisioners", []interface{}{"docker"})
+	defer config.Unset("provision:fault-injection")
+	got, err := Get("faulty")
+	c.Assert(err, check.IsNil)
+	c.Assert(got, check.Equals, p)
+}
+
+func (ProvisionSuite) TestFaultProvisionerFailure(c *check.C) {
+	defer setFaultRand(0.9, 0.1, 0.9, 0.9)()
+	inner := &faultTestProvisioner{}
+	p := &faultProvisioner{faultInjectable: inner, settings: &FaultSettings{FailureRate: 0.5}}
+	err := p.Restart(nil, "", nil)
+	c.Assert(err, check.ErrorMatches, "faulty Restart: injected fault")
+	c.Assert(errors.Cause(err), check.Equals, ErrInjectedFault)
+	c.Assert(inner.restarts, check.Equals, 0)
+	err = p.Restart(nil, "", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(inner.restarts, check.Equals, 1)
+}
+
+func (ProvisionSuite) TestFaultProvisionerDelay(c *check.C) {
+	defer setFaultRand(0.1, 0.5, 0.9)()
+	var delays []time.Duration
+	faultSleep = func(d time.Duration) { delays = append(delays, d) }
+	inner := &faultTestProvisioner{}
+	p := &faultProvisioner{faultInjectable: inner, settings: &FaultSettings{
+		FailureRate: 0.5,
+		DelayRate:   0.2,
+		MaxDelay:    10 * time.Second,
+	}}
+	err := p.Restart(nil, "", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(delays, check.DeepEquals, []time.Duration{5 * time.Second})
+	c.Assert(inner.restarts, check.Equals, 1)
+}
+
+func (ProvisionSuite) TestFaultProvisionerOperations(c *check.C) {
+	defer setFaultRand()()
+	inner := &faultTestProvisioner{}
+	p := &faultProvisioner{faultInjectable: inner, settings: &FaultSettings{
+		FailureRate: 1,
+		Operations:  []string{"AddUnits"},
+	}}
+	err := p.Restart(nil, "", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(inner.restarts, check.Equals, 1)
+}
@@ -16,7 +16,6 @@ import (
 	"github.com/pkg/errors"
 	"github.com/tsuru/tsuru/app/image"
 	tsuruErrors "github.com/tsuru/tsuru/errors"
-	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/provision"
 	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -423,16 +422,29 @@ func labelSetFromMeta(meta *metav1.ObjectMeta) *provision.LabelSet {
 	return &provision.LabelSet{Labels: merged, Prefix: tsuruLabelPrefix}
 }
 
-type fixedSizeQueue struct {
-	sz *remotecommand.TerminalSize
+// terminalSizeQueue returns the initial terminal size followed by the sizes
+// received in resize, if any.
+type terminalSizeQueue struct {
+	sz     *remotecommand.TerminalSize
+	resize <-chan provision.TerminalSize
 }
 
-func (q *fixedSizeQueue) Next() *remotecommand.TerminalSize {
-	defer func() { q.sz = nil }()
-	return q.sz
+func (q *terminalSizeQueue) Next() *remotecommand.TerminalSize {
+	if q.sz != nil {
+		defer func() { q.sz = nil }()
+		return q.sz
+	}
+	if q.resize == nil {
+		return nil
+	}
+	size, ok := <-q.resize
+	if !ok {
+		return nil
+	}
+	return &remotecommand.TerminalSize{Width: uint16(size.Width), Height: uint16(size.Height)}
 }
 
-var _ remotecommand.TerminalSizeQueue = &fixedSizeQueue{}
+var _ remotecommand.TerminalSizeQueue = &terminalSizeQueue{}
 
 type execOpts struct {
 	app      provision.App
@@ -442,6 +454,7 @@ type execOpts struct {
 	stderr   io.Writer
 	stdin    io.Reader
 	termSize *remotecommand.TerminalSize
+	resize   <-chan provision.TerminalSize
 	tty      bool
 }
 
@@ -497,10 +510,11 @@ func execCommand(opts execOpts) error {
 	if err != nil {
 		return errors.WithStack(err)
 	}
-	var sizeQueue *fixedSizeQueue
+	var sizeQueue remotecommand.TerminalSizeQueue
 	if opts.termSize != nil {
-		sizeQueue = &fixedSizeQueue{
-			sz: opts.termSize,
+		sizeQueue = &terminalSizeQueue{
+			sz:     opts.termSize,
+			resize: opts.resize,
 		}
 	}
 	err = exec.Stream(remotecommand.StreamOptions{
@@ -632,7 +646,7 @@ func waitNodeReady(client *clusterClient, addr string, timeout time.Duration) (*
 func refreshNodeTaints(client *clusterClient, addr string) {
 	node, err := waitNodeReady(client, addr, 30*time.Minute)
 	if err != nil {
-		log.Errorf("error waiting for node ready: %v", err)
+		logger.Errorf("error waiting for node ready: %v", err)
 	}
 	tsuruTaintKey := "tsuru-refresh-node-container"
 	tsuruTempTaint := apiv1.Taint{
@@ -643,7 +657,7 @@ func refreshNodeTaints(client *clusterClient, addr string) {
 	node.Spec.Taints = append(node.Spec.Taints, tsuruTempTaint)
 	node, err = client.Core().Nodes().Update(node)
 	if err != nil {
-		log.Errorf("unable to add node taint %q: %v", tsuruTaintKey, err)
+		logger.Errorf("unable to add node taint %q: %v", tsuruTaintKey, err)
 	}
 	for i := 0; i < len(node.Spec.Taints); i++ {
 		if node.Spec.Taints[i].Key == tsuruTaintKey {
@@ -654,6 +668,6 @@ func refreshNodeTaints(client *clusterClient, addr string) {
 	}
 	_, err = client.Core().Nodes().Update(node)
 	if err != nil {
-		log.Errorf("unable to remove node taint %q: %v", tsuruTaintKey, err)
+		logger.Errorf("unable to remove node taint %q: %v", tsuruTaintKey, err)
 	}
 }
@@ -17,6 +17,7 @@ import (
 	"github.com/tsuru/tsuru/app/image"
 	tsuruErrors "github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
 	tsuruNet "github.com/tsuru/tsuru/net"
 	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/cluster"
@@ -40,6 +41,8 @@ const (
 	defaultDockerImageName           = "docker:1.11.2"
 )
 
+var logger = log.Subsystem("provisioner")
+
 type kubernetesProvisioner struct{}
 
 var (
@@ -744,7 +747,8 @@ func (p *kubernetesProvisioner) Shell(opts provision.ShellOptions) error {
 			Width:  uint16(opts.Width),
 			Height: uint16(opts.Height),
 		},
-		tty: true,
+		resize: opts.Resize,
+		tty:    true,
 	})
 }
 
@@ -16,6 +16,7 @@ import (
 	tsuruErrors "github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/router"
+	"github.com/tsuru/tsuru/scan"
 	"github.com/tsuru/tsuru/service"
 	"github.com/tsuru/tsuru/validation"
 	"gopkg.in/mgo.v2"
@@ -41,23 +42,45 @@ type Pool struct {
 	Default     bool
 	Provisioner string
 	Builder     string
+	ScanPolicy  string
+	// MemoryOvercommit is the ratio between the memory that may be reserved
+	// by units in each node of the pool and the memory of the node. Zero
+	// means the ratio configured for the provisioner is used.
+	MemoryOvercommit float64
 }
 
 type AddPoolOptions struct {
-	Name        string
-	Public      bool
-	Default     bool
-	Force       bool
-	Provisioner string
-	Builder     string
+	Name             string
+	Public           bool
+	Default          bool
+	Force            bool
+	Provisioner      string
+	Builder          string
+	ScanPolicy       string
+	MemoryOvercommit float64
 }
 
 type UpdatePoolOptions struct {
-	Default     *bool
-	Public      *bool
-	Force       bool
-	Provisioner string
-	Builder     string
+	Default          *bool
+	Public           *bool
+	Force            bool
+	Provisioner      string
+	Builder          string
+	ScanPolicy       *string
+	MemoryOvercommit *float64
+}
+
+// FullError is returned by schedulers when a unit can't be placed in any node
+// of the pool without exceeding its capacity.
+type FullError struct {
+	Pool   string
+	App    string
+	Memory int64
+}
+
+func (e *FullError) Error() string {
+	return fmt.Sprintf("pool %q is full: no nodes found with enough memory for container of %q: %0.4fMB",
+		e.Pool, e.App, float64(e.Memory)/(1024*1024))
 }
 
 func (p *Pool) GetProvisioner() (provision.Provisioner, error) {
@@ -212,6 +235,29 @@ func servicesNames() ([]string, error) {
 }
 
 func (p *Pool) MarshalJSON() ([]byte, error) {
+	return p.marshalJSON(nil)
+}
+
+// VisibleTo returns a view of the pool that, when encoded as JSON, only
+// lists the given teams and services among the ones allowed in the pool. It's
+// used to keep users from seeing the teams and services of other tenants.
+func (p *Pool) VisibleTo(teams, services []string) json.Marshaler {
+	return &poolView{pool: p, visible: map[string][]string{
+		"team":    teams,
+		"service": services,
+	}}
+}
+
+type poolView struct {
+	pool    *Pool
+	visible map[string][]string
+}
+
+func (v *poolView) MarshalJSON() ([]byte, error) {
+	return v.pool.marshalJSON(v.visible)
+}
+
+func (p *Pool) marshalJSON(visible map[string][]string) ([]byte, error) {
 	teams, err := getExactConstraintForPool(p.Name, "team")
 	if err != nil {
 		return nil, err
@@ -220,16 +266,35 @@ func (p *Pool) MarshalJSON() ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	for field, names := range visible {
+		resolvedConstraints[field] = filterNames(resolvedConstraints[field], names)
+	}
 	result := make(map[string]interface{})
 	result["name"] = p.Name
 	result["public"] = teams.AllowsAll()
 	result["default"] = p.Default
 	result["provisioner"] = p.Provisioner
+	result["scan_policy"] = p.ScanPolicy
+	result["memory_overcommit"] = p.MemoryOvercommit
 	result["teams"] = resolvedConstraints["team"]
 	result["allowed"] = resolvedConstraints
 	return json.Marshal(&result)
 }
 
+func filterNames(names, allowed []string) []string {
+	allowedSet := make(map[string]struct{}, len(allowed))
+	for _, n := range allowed {
+		allowedSet[n] = struct{}{}
+	}
+	var result []string
+	for _, n := range names {
+		if _, ok := allowedSet[n]; ok {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
 func (p *Pool) validate() error {
 	if p.Name == "" {
 		return ErrPoolNameIsRequired
@@ -240,11 +305,28 @@ func (p *Pool) validate() error {
 			"starting with a letter."
 		return &tsuruErrors.ValidationError{Message: msg}
 	}
+	err := validateMemoryOvercommit(p.MemoryOvercommit)
+	if err != nil {
+		return err
+	}
+	return scan.ValidatePolicy(p.ScanPolicy)
+}
+
+func validateMemoryOvercommit(ratio float64) error {
+	if ratio < 0 {
+		return &tsuruErrors.ValidationError{Message: "memory overcommit must be a positive number"}
+	}
 	return nil
 }
 
 func AddPool(opts AddPoolOptions) error {
-	pool := Pool{Name: opts.Name, Default: opts.Default, Provisioner: opts.Provisioner}
+	pool := Pool{
+		Name:             opts.Name,
+		Default:          opts.Default,
+		Provisioner:      opts.Provisioner,
+		ScanPolicy:       opts.ScanPolicy,
+		MemoryOvercommit: opts.MemoryOvercommit,
+	}
 	if err := pool.validate(); err != nil {
 		return err
 	}
@@ -452,6 +534,18 @@ func PoolUpdate(name string, opts UpdatePoolOptions) error {
 	if err != nil {
 		return err
 	}
+	if opts.ScanPolicy != nil {
+		err = scan.ValidatePolicy(*opts.ScanPolicy)
+		if err != nil {
+			return err
+		}
+	}
+	if opts.MemoryOvercommit != nil {
+		err = validateMemoryOvercommit(*opts.MemoryOvercommit)
+		if err != nil {
+			return err
+		}
+	}
 	if opts.Default != nil && *opts.Default {
 		err = changeDefaultPool(opts.Force)
 		if err != nil {
@@ -477,6 +571,12 @@ func PoolUpdate(name string, opts UpdatePoolOptions) error {
 	if opts.Provisioner != "" {
 		query["provisioner"] = opts.Provisioner
 	}
+	if opts.ScanPolicy != nil {
+		query["scanpolicy"] = *opts.ScanPolicy
+	}
+	if opts.MemoryOvercommit != nil {
+		query["memoryovercommit"] = *opts.MemoryOvercommit
+	}
 	if len(query) == 0 {
 		return nil
 	}
@@ -128,6 +128,26 @@ func (s *S) TestAddPoolWithoutNameShouldBreak(c *check.C) {
 	c.Assert(err.Error(), check.Equals, "Pool name is required.")
 }
 
+func (s *S) TestAddPoolWithScanPolicy(c *check.C) {
+	err := AddPool(AddPoolOptions{Name: "pool1", ScanPolicy: "block"})
+	c.Assert(err, check.IsNil)
+	pool, err := GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.ScanPolicy, check.Equals, "block")
+	err = AddPool(AddPoolOptions{Name: "pool2", ScanPolicy: "deny"})
+	c.Assert(err, check.ErrorMatches, `invalid scan policy "deny".*`)
+}
+
+func (s *S) TestAddPoolWithMemoryOvercommit(c *check.C) {
+	err := AddPool(AddPoolOptions{Name: "pool1", MemoryOvercommit: 1.5})
+	c.Assert(err, check.IsNil)
+	pool, err := GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.MemoryOvercommit, check.Equals, 1.5)
+	err = AddPool(AddPoolOptions{Name: "pool2", MemoryOvercommit: -1})
+	c.Assert(err, check.ErrorMatches, `memory overcommit must be a positive number`)
+}
+
 func (s *S) TestAddDefaultPool(c *check.C) {
 	opts := AddPoolOptions{
 		Name:    "pool1",
@@ -338,6 +358,40 @@ func (s *S) TestPoolUpdate(c *check.C) {
 	c.Assert(constraint.AllowsAll(), check.Equals, true)
 }
 
+func (s *S) TestPoolUpdateScanPolicy(c *check.C) {
+	err := AddPool(AddPoolOptions{Name: "pool1", ScanPolicy: "block"})
+	c.Assert(err, check.IsNil)
+	policy := "warn"
+	err = PoolUpdate("pool1", UpdatePoolOptions{ScanPolicy: &policy})
+	c.Assert(err, check.IsNil)
+	pool, err := GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.ScanPolicy, check.Equals, "warn")
+	policy = "deny"
+	err = PoolUpdate("pool1", UpdatePoolOptions{ScanPolicy: &policy})
+	c.Assert(err, check.ErrorMatches, `invalid scan policy "deny".*`)
+}
+
+func (s *S) TestPoolUpdateMemoryOvercommit(c *check.C) {
+	err := AddPool(AddPoolOptions{Name: "pool1", MemoryOvercommit: 1.5})
+	c.Assert(err, check.IsNil)
+	ratio := 2.0
+	err = PoolUpdate("pool1", UpdatePoolOptions{MemoryOvercommit: &ratio})
+	c.Assert(err, check.IsNil)
+	pool, err := GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.MemoryOvercommit, check.Equals, 2.0)
+	ratio = 0
+	err = PoolUpdate("pool1", UpdatePoolOptions{MemoryOvercommit: &ratio})
+	c.Assert(err, check.IsNil)
+	pool, err = GetPoolByName("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(pool.MemoryOvercommit, check.Equals, 0.0)
+	ratio = -0.5
+	err = PoolUpdate("pool1", UpdatePoolOptions{MemoryOvercommit: &ratio})
+	c.Assert(err, check.ErrorMatches, `memory overcommit must be a positive number`)
+}
+
 func (s *S) TestPoolUpdateToDefault(c *check.C) {
 	opts := AddPoolOptions{
 		Name:    "pool1",
@@ -18,6 +18,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/tsuru/tsuru/app/bind"
 	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/router"
 	appTypes "github.com/tsuru/tsuru/types/app"
 )
@@ -32,6 +33,8 @@ var (
 	DefaultProvisioner = defaultDockerProvisioner
 )
 
+var logger = log.Subsystem("provisioner")
+
 type UnitNotFoundError struct {
 	ID string
 }
@@ -248,6 +251,15 @@ type ShellOptions struct {
 	Height int
 	Unit   string
 	Term   string
+	// Resize receives the new size of the client terminal every time it
+	// changes, it's closed when the client goes away. It may be nil.
+	Resize <-chan TerminalSize
+}
+
+// TerminalSize is the size of a terminal, in characters.
+type TerminalSize struct {
+	Width  int
+	Height int
 }
 
 // ArchiveDeployer is a provisioner that can deploy archives.
@@ -414,6 +426,47 @@ type UnitStatusProvisioner interface {
 	SetUnitStatus(Unit, Status) error
 }
 
+// UnitInfo holds detailed information about a single unit.
+type UnitInfo struct {
+	Unit        Unit
+	Node        string
+	CreatedAt   time.Time
+	LastHealing *time.Time
+	Resources   *UnitResources `json:",omitempty"`
+}
+
+// UnitResources are the memory, in bytes, and CPU share of a unit overriding
+// the ones in the plan of the app. Zero values keep the plan value.
+type UnitResources struct {
+	Memory   int64 `json:",omitempty"`
+	CpuShare int   `json:",omitempty"`
+}
+
+// IsZero returns whether the resources don't override anything.
+func (r UnitResources) IsZero() bool {
+	return r.Memory == 0 && r.CpuShare == 0
+}
+
+// UnitProvisioner is a provisioner that allows inspecting and restarting a
+// single unit of an app.
+type UnitProvisioner interface {
+	// InspectUnit returns detailed information about a unit of the app.
+	InspectUnit(App, string) (*UnitInfo, error)
+
+	// RestartUnit restarts a single unit of the app.
+	RestartUnit(App, string, io.Writer) error
+}
+
+// UnitResourcesProvisioner is a provisioner that allows temporarily changing
+// the resources of a single running unit, e.g. giving more memory to a
+// worker during a backfill. Overrides only last while the unit is running,
+// units created by deploys, restarts or healing use the app plan again.
+type UnitResourcesProvisioner interface {
+	// SetUnitResources overrides the resources of a unit of the app. Zero
+	// resources set the unit back to the app plan.
+	SetUnitResources(App, string, UnitResources) error
+}
+
 type AddNodeOptions struct {
 	IaaSID     string
 	Address    string
@@ -597,7 +650,11 @@ func Get(name string) (Provisioner, error) {
 	if !ok {
 		return nil, errors.Errorf("unknown provisioner: %q", name)
 	}
-	return pFunc()
+	p, err := pFunc()
+	if err != nil {
+		return nil, err
+	}
+	return withFaults(p)
 }
 
 func GetDefault() (Provisioner, error) {
@@ -615,6 +672,10 @@ func Registry() ([]Provisioner, error) {
 		if err != nil {
 			return nil, err
 		}
+		p, err = withFaults(p)
+		if err != nil {
+			return nil, err
+		}
 		registry = append(registry, p)
 	}
 	return registry, nil
@@ -639,6 +700,9 @@ func InitializeAll() error {
 				fmt.Print(startupMessage)
 			}
 		}
+		if _, ok := p.(*faultProvisioner); ok {
+			logger.Errorf("WARNING: fault injection enabled for provisioner %q, operations will randomly fail", p.GetName())
+		}
 	}
 	return nil
 }
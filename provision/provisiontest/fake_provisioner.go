@@ -33,10 +33,12 @@ var (
 	errNotProvisioned         = &provision.Error{Reason: "App is not provisioned."}
 	uniqueIpCounter     int32 = 0
 
-	_ provision.NodeProvisioner = &FakeProvisioner{}
-	_ provision.Provisioner     = &FakeProvisioner{}
-	_ provision.App             = &FakeApp{}
-	_ bind.App                  = &FakeApp{}
+	_ provision.NodeProvisioner          = &FakeProvisioner{}
+	_ provision.Provisioner              = &FakeProvisioner{}
+	_ provision.UnitProvisioner          = &FakeProvisioner{}
+	_ provision.UnitResourcesProvisioner = &FakeProvisioner{}
+	_ provision.App                      = &FakeApp{}
+	_ bind.App                           = &FakeApp{}
 )
 
 const fakeAppImage = "app-image"
@@ -177,6 +179,15 @@ func (a *FakeApp) GetServiceEnvs() []bind.ServiceEnvVar {
 func (a *FakeApp) AddInstance(instanceArgs bind.AddInstanceArgs) error {
 	a.serviceLock.Lock()
 	defer a.serviceLock.Unlock()
+	for _, env := range instanceArgs.Envs {
+		for i := 0; i < len(a.serviceEnvs); i++ {
+			se := a.serviceEnvs[i]
+			if se.ServiceName == env.ServiceName && se.InstanceName == env.InstanceName {
+				a.serviceEnvs = append(a.serviceEnvs[:i], a.serviceEnvs[i+1:]...)
+				i--
+			}
+		}
+	}
 	a.serviceEnvs = append(a.serviceEnvs, instanceArgs.Envs...)
 	if instanceArgs.Writer != nil {
 		instanceArgs.Writer.Write([]byte("add instance"))
@@ -290,6 +301,13 @@ func (a *FakeApp) GetLock() provision.AppLock {
 	return nil
 }
 
+func (a *FakeApp) RestartIfUnits(w io.Writer) error {
+	if w != nil {
+		w.Write([]byte("restart"))
+	}
+	return nil
+}
+
 func (a *FakeApp) GetUnits() ([]bind.Unit, error) {
 	units := make([]bind.Unit, len(a.units))
 	for i := range a.units {
@@ -646,6 +664,20 @@ func (p *FakeProvisioner) Restarts(a provision.App, process string) int {
 	return p.apps[a.GetName()].restarts[process]
 }
 
+// UnitRestarts returns the number of restarts for a given unit.
+func (p *FakeProvisioner) UnitRestarts(a provision.App, unitID string) int {
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+	return p.apps[a.GetName()].unitRestarts[unitID]
+}
+
+// UnitResources returns the resources override of a given unit.
+func (p *FakeProvisioner) UnitResources(a provision.App, unitID string) provision.UnitResources {
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+	return p.apps[a.GetName()].unitResources[unitID]
+}
+
 // Starts returns the number of starts for a given app.
 func (p *FakeProvisioner) Starts(app provision.App, process string) int {
 	p.mut.RLock()
@@ -941,6 +973,91 @@ func (p *FakeProvisioner) Restart(app provision.App, process string, w io.Writer
 	return nil
 }
 
+func (p *FakeProvisioner) InspectUnit(app provision.App, unitID string) (*provision.UnitInfo, error) {
+	if err := p.getError("InspectUnit"); err != nil {
+		return nil, err
+	}
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+	pApp, ok := p.apps[app.GetName()]
+	if !ok {
+		return nil, errNotProvisioned
+	}
+	for _, u := range pApp.units {
+		if u.ID == unitID {
+			info := &provision.UnitInfo{Unit: u, Node: u.IP}
+			if r, ok := pApp.unitResources[unitID]; ok {
+				info.Resources = &r
+			}
+			return info, nil
+		}
+	}
+	return nil, &provision.UnitNotFoundError{ID: unitID}
+}
+
+func (p *FakeProvisioner) RestartUnit(app provision.App, unitID string, w io.Writer) error {
+	if err := p.getError("RestartUnit"); err != nil {
+		return err
+	}
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	pApp, ok := p.apps[app.GetName()]
+	if !ok {
+		return errNotProvisioned
+	}
+	found := false
+	for _, u := range pApp.units {
+		if u.ID == unitID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return &provision.UnitNotFoundError{ID: unitID}
+	}
+	if pApp.unitRestarts == nil {
+		pApp.unitRestarts = make(map[string]int)
+	}
+	pApp.unitRestarts[unitID]++
+	p.apps[app.GetName()] = pApp
+	if w != nil {
+		fmt.Fprintf(w, "restarting unit %s", unitID)
+	}
+	return nil
+}
+
+func (p *FakeProvisioner) SetUnitResources(app provision.App, unitID string, r provision.UnitResources) error {
+	if err := p.getError("SetUnitResources"); err != nil {
+		return err
+	}
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	pApp, ok := p.apps[app.GetName()]
+	if !ok {
+		return errNotProvisioned
+	}
+	found := false
+	for _, u := range pApp.units {
+		if u.ID == unitID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return &provision.UnitNotFoundError{ID: unitID}
+	}
+	if pApp.unitResources == nil {
+		pApp.unitResources = make(map[string]provision.UnitResources)
+	}
+	if r.IsZero() {
+		delete(pApp.unitResources, unitID)
+	} else {
+		pApp.unitResources[unitID] = r
+	}
+	p.apps[app.GetName()] = pApp
+	return nil
+}
+
 func (p *FakeProvisioner) Start(app provision.App, process string) error {
 	p.mut.Lock()
 	defer p.mut.Unlock()
@@ -1466,16 +1583,18 @@ func (p *PipelineErrorFakeProvisioner) DeployPipeline() *action.Pipeline {
 }
 
 type provisionedApp struct {
-	units       []provision.Unit
-	app         provision.App
-	restarts    map[string]int
-	starts      map[string]int
-	stops       map[string]int
-	sleeps      map[string]int
-	lastArchive string
-	lastFile    io.ReadCloser
-	cnames      []string
-	unitLen     int
-	lastData    map[string]interface{}
-	image       string
+	units         []provision.Unit
+	app           provision.App
+	restarts      map[string]int
+	unitRestarts  map[string]int
+	unitResources map[string]provision.UnitResources
+	starts        map[string]int
+	stops         map[string]int
+	sleeps        map[string]int
+	lastArchive   string
+	lastFile      io.ReadCloser
+	cnames        []string
+	unitLen       int
+	lastData      map[string]interface{}
+	image         string
 }
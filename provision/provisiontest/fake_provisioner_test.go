@@ -238,6 +238,28 @@ func (s *S) TestFakeAppAddInstance(c *check.C) {
 	})
 }
 
+func (s *S) TestFakeAppAddInstanceReplacesInstanceEnvs(c *check.C) {
+	app := NewFakeApp("sou", "otm", 0)
+	err := app.AddInstance(bind.AddInstanceArgs{
+		Envs: []bind.ServiceEnvVar{
+			{ServiceName: "mysql", InstanceName: "inst1", EnvVar: bind.EnvVar{Name: "env1", Value: "val1"}},
+			{ServiceName: "mysql", InstanceName: "inst1", EnvVar: bind.EnvVar{Name: "env2", Value: "val2"}},
+			{ServiceName: "mysql", InstanceName: "inst2", EnvVar: bind.EnvVar{Name: "env3", Value: "val3"}},
+		},
+	})
+	c.Assert(err, check.IsNil)
+	err = app.AddInstance(bind.AddInstanceArgs{
+		Envs: []bind.ServiceEnvVar{
+			{ServiceName: "mysql", InstanceName: "inst1", EnvVar: bind.EnvVar{Name: "env1", Value: "newval"}},
+		},
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(app.GetServiceEnvs(), check.DeepEquals, []bind.ServiceEnvVar{
+		{ServiceName: "mysql", InstanceName: "inst2", EnvVar: bind.EnvVar{Name: "env3", Value: "val3"}},
+		{ServiceName: "mysql", InstanceName: "inst1", EnvVar: bind.EnvVar{Name: "env1", Value: "newval"}},
+	})
+}
+
 func (s *S) TestFakeAppRemoveInstance(c *check.C) {
 	app := NewFakeApp("sou", "otm", 0)
 	err := app.AddInstance(bind.AddInstanceArgs{
@@ -694,6 +716,37 @@ func (s *S) TestDestroyNotProvisionedApp(c *check.C) {
 	c.Assert(err, check.Equals, errNotProvisioned)
 }
 
+func (s *S) TestInspectUnit(c *check.C) {
+	a := NewFakeApp("kid-gloves", "rush", 0)
+	p := NewFakeProvisioner()
+	p.Provision(a)
+	err := p.AddUnits(a, 1, "web", nil)
+	c.Assert(err, check.IsNil)
+	unit := p.GetUnits(a)[0]
+	info, err := p.InspectUnit(a, unit.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(info, check.DeepEquals, &provision.UnitInfo{Unit: unit, Node: unit.IP})
+	_, err = p.InspectUnit(a, "unknown")
+	c.Assert(err, check.DeepEquals, &provision.UnitNotFoundError{ID: "unknown"})
+}
+
+func (s *S) TestRestartUnit(c *check.C) {
+	a := NewFakeApp("kid-gloves", "rush", 0)
+	p := NewFakeProvisioner()
+	p.Provision(a)
+	err := p.AddUnits(a, 2, "web", nil)
+	c.Assert(err, check.IsNil)
+	units := p.GetUnits(a)
+	var buf bytes.Buffer
+	err = p.RestartUnit(a, units[1].ID, &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Equals, "restarting unit "+units[1].ID)
+	c.Assert(p.UnitRestarts(a, units[1].ID), check.Equals, 1)
+	c.Assert(p.UnitRestarts(a, units[0].ID), check.Equals, 0)
+	err = p.RestartUnit(a, "unknown", nil)
+	c.Assert(err, check.DeepEquals, &provision.UnitNotFoundError{ID: "unknown"})
+}
+
 func (s *S) TestAddUnits(c *check.C) {
 	app := NewFakeApp("mystic-rhythms", "rush", 0)
 	p := NewFakeProvisioner()
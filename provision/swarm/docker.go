@@ -21,7 +21,6 @@ import (
 	"github.com/pkg/errors"
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/app/image"
-	"github.com/tsuru/tsuru/log"
 	tsuruNet "github.com/tsuru/tsuru/net"
 	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/dockercommon"
@@ -330,7 +329,7 @@ func removeServiceAndLog(client *clusterClient, id string) {
 		ID: id,
 	})
 	if err != nil {
-		log.Errorf("error removing service: %+v", errors.WithStack(err))
+		logger.Errorf("error removing service: %+v", errors.WithStack(err))
 	}
 }
 
@@ -532,7 +531,7 @@ func toLabelSelectors(m map[string]string) []string {
 func (p *swarmProvisioner) cleanImageInNodes(imgName string) {
 	nodes, err := p.ListNodes(nil)
 	if err != nil {
-		log.Errorf("ignored error removing image %q: %s. image kept on list to retry later.",
+		logger.Errorf("ignored error removing image %q: %s. image kept on list to retry later.",
 			imgName, errors.WithStack(err))
 		return
 	}
@@ -540,19 +539,19 @@ func (p *swarmProvisioner) cleanImageInNodes(imgName string) {
 		nodeWrapper := n.(*swarmNodeWrapper)
 		tls, err := tlsConfigForCluster(nodeWrapper.client.Cluster)
 		if err != nil {
-			log.Errorf("ignored error removing image %q: %s. image kept on list to retry later.",
+			logger.Errorf("ignored error removing image %q: %s. image kept on list to retry later.",
 				imgName, errors.WithStack(err))
 			continue
 		}
 		client, err := newClient(n.Address(), tls)
 		if err != nil {
-			log.Errorf("ignored error removing image %q: %s. image kept on list to retry later.",
+			logger.Errorf("ignored error removing image %q: %s. image kept on list to retry later.",
 				imgName, errors.WithStack(err))
 			continue
 		}
 		err = client.RemoveImage(imgName)
 		if err != nil && err != docker.ErrNoSuchImage {
-			log.Errorf("ignored error removing image %q: %s. image kept on list to retry later.",
+			logger.Errorf("ignored error removing image %q: %s. image kept on list to retry later.",
 				imgName, errors.WithStack(err))
 		}
 	}
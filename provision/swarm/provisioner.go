@@ -34,6 +34,8 @@ const (
 
 var swarmConfig swarmProvisionerConfig
 
+var logger = log.Subsystem("provisioner")
+
 type swarmProvisioner struct{}
 
 var (
@@ -302,28 +304,28 @@ func (p *swarmProvisioner) RoutableAddresses(a provision.App) ([]url.URL, error)
 	var pubPort uint32
 	for retries < 5 && pubPort == 0 {
 		if retries > 0 {
-			log.Debugf("[swarm-routable-addresses] sleeping for 3 seconds")
+			logger.Debugf("[swarm-routable-addresses] sleeping for 3 seconds")
 			time.Sleep(time.Second * 3)
 		}
 		srv, errInspect := client.InspectService(srvName)
 		if errInspect != nil {
 			return nil, errInspect
 		}
-		log.Debugf("[swarm-routable-addresses] service for app %q: %#+v", a.GetName(), srv)
+		logger.Debugf("[swarm-routable-addresses] service for app %q: %#+v", a.GetName(), srv)
 		if len(srv.Endpoint.Ports) > 0 {
 			pubPort = srv.Endpoint.Ports[0].PublishedPort
 		}
 		retries++
 	}
 	if pubPort == 0 {
-		log.Debugf("[swarm-routable-addresses] no exposed ports for app %q", a.GetName())
+		logger.Debugf("[swarm-routable-addresses] no exposed ports for app %q", a.GetName())
 		return nil, nil
 	}
 	nodes, err := client.ListNodes(docker.ListNodesOptions{})
 	if err != nil {
 		return nil, err
 	}
-	log.Debugf("[swarm-routable-addresses] valid nodes for app %q: %#+v", a.GetName(), nodes)
+	logger.Debugf("[swarm-routable-addresses] valid nodes for app %q: %#+v", a.GetName(), nodes)
 	for i := len(nodes) - 1; i >= 0; i-- {
 		l := provision.LabelSet{Labels: nodes[i].Spec.Annotations.Labels, Prefix: tsuruLabelPrefix}
 		if l.NodePool() != a.GetPool() {
@@ -703,7 +705,19 @@ func (p *swarmProvisioner) Shell(opts provision.ShellOptions) error {
 		return errors.WithStack(err)
 	}
 	nodeClient.ResizeExecTTY(exec.ID, opts.Height, opts.Width)
-	return <-errs
+	resize := opts.Resize
+	for {
+		select {
+		case err = <-errs:
+			return err
+		case size, ok := <-resize:
+			if !ok {
+				resize = nil
+				continue
+			}
+			nodeClient.ResizeExecTTY(exec.ID, size.Height, size.Width)
+		}
+	}
 }
 
 func (p *swarmProvisioner) ExecuteCommand(stdout, stderr io.Writer, a provision.App, cmd string, args ...string) error {
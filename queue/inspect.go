@@ -0,0 +1,164 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// WorkerPool describes the workers consuming a work queue in the current
+// tsuru API instance.
+type WorkerPool struct {
+	Queue       string
+	Concurrency int
+	Busy        int
+}
+
+// MessageFilter selects messages from work queues. Empty fields match any
+// message.
+type MessageFilter struct {
+	Queue  string
+	Target string
+	State  string
+}
+
+// MessageInfo is a message along with its delivery state and decoded
+// payload.
+type MessageInfo struct {
+	Message
+	State string
+	Data  bson.M
+}
+
+var consumers = struct {
+	sync.Mutex
+	queues map[string]*WorkQueue
+}{queues: map[string]*WorkQueue{}}
+
+func registerConsumer(q *WorkQueue) {
+	consumers.Lock()
+	defer consumers.Unlock()
+	consumers.queues[q.name] = q
+}
+
+func unregisterConsumer(q *WorkQueue) {
+	consumers.Lock()
+	defer consumers.Unlock()
+	if consumers.queues[q.name] == q {
+		delete(consumers.queues, q.name)
+	}
+}
+
+// WorkerPools returns the worker pools running in the current tsuru API
+// instance, sorted by queue name.
+func WorkerPools() []WorkerPool {
+	consumers.Lock()
+	defer consumers.Unlock()
+	pools := make([]WorkerPool, 0, len(consumers.queues))
+	for _, q := range consumers.queues {
+		pools = append(pools, WorkerPool{
+			Queue:       q.name,
+			Concurrency: q.workers,
+			Busy:        int(atomic.LoadInt32(&q.busy)),
+		})
+	}
+	sort.Slice(pools, func(i, j int) bool {
+		return pools[i].Queue < pools[j].Queue
+	})
+	return pools
+}
+
+// ListMessages returns the messages stored in work queues matching the
+// filter, oldest first.
+func ListMessages(filter MessageFilter) ([]MessageInfo, error) {
+	coll, err := workCollection()
+	if err != nil {
+		return nil, err
+	}
+	defer coll.Close()
+	now := time.Now().UTC()
+	query := bson.M{}
+	if filter.Queue != "" {
+		query["queue"] = filter.Queue
+	}
+	if filter.Target != "" {
+		query["target"] = filter.Target
+	}
+	switch filter.State {
+	case MessageStateDead:
+		query["dead"] = true
+	case MessageStateInFlight:
+		query["dead"] = false
+		query["inflight"] = true
+		query["visibleat"] = bson.M{"$gt": now}
+	case MessageStateExpired:
+		query["dead"] = false
+		query["inflight"] = true
+		query["visibleat"] = bson.M{"$lte": now}
+	case MessageStateWaiting:
+		query["dead"] = false
+		query["inflight"] = bson.M{"$ne": true}
+	}
+	var msgs []Message
+	err = coll.Find(query).Sort("enqueuedat").All(&msgs)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]MessageInfo, len(msgs))
+	for i := range msgs {
+		infos[i] = MessageInfo{Message: msgs[i], State: msgs[i].State(now)}
+		msgs[i].Decode(&infos[i].Data)
+	}
+	return infos, nil
+}
+
+// RetryMessage makes a message visible again, so it's delivered as soon as
+// possible. Dead messages are moved back to their queue with their attempts
+// reset. If the message is currently being processed, its consumer won't be
+// able to acknowledge it.
+func RetryMessage(id bson.ObjectId) error {
+	coll, err := workCollection()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	var msg Message
+	err = coll.FindId(id).One(&msg)
+	if err == mgo.ErrNotFound {
+		return ErrMessageNotFound
+	}
+	if err != nil {
+		return err
+	}
+	update := bson.M{"dead": false, "inflight": false, "visibleat": time.Now().UTC()}
+	if msg.Dead {
+		update["attempts"] = 0
+	}
+	err = coll.Update(bson.M{"_id": id, "attempts": msg.Attempts}, bson.M{"$set": update})
+	if err == mgo.ErrNotFound {
+		return ErrMessageNotFound
+	}
+	return err
+}
+
+// DiscardMessage removes a message from its queue without processing it.
+func DiscardMessage(id bson.ObjectId) error {
+	coll, err := workCollection()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	err = coll.RemoveId(id)
+	if err == mgo.ErrNotFound {
+		return ErrMessageNotFound
+	}
+	return err
+}
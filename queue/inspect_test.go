@@ -0,0 +1,140 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type targetedPayload struct {
+	Name string
+}
+
+func (p targetedPayload) Target() string {
+	return "target-" + p.Name
+}
+
+func (s *S) TestWorkQueueConcurrency(c *check.C) {
+	q := NewWorkQueue("q1", WorkQueueOpts{})
+	c.Assert(q.concurrency(), check.Equals, 1)
+	config.Set("queue:work-concurrency", 3)
+	defer config.Unset("queue:work-concurrency")
+	c.Assert(q.concurrency(), check.Equals, 3)
+	q = NewWorkQueue("q1", WorkQueueOpts{Concurrency: 2})
+	c.Assert(q.concurrency(), check.Equals, 2)
+	config.Set("queue:work-queues:q1:concurrency", 5)
+	defer config.Unset("queue:work-queues")
+	c.Assert(q.concurrency(), check.Equals, 5)
+}
+
+func (s *S) TestWorkerPools(c *check.C) {
+	config.Set("queue:mongo-polling-interval", 0.01)
+	defer config.Unset("queue:mongo-polling-interval")
+	q := NewWorkQueue("q1", WorkQueueOpts{Concurrency: 2})
+	running := make(chan struct{})
+	release := make(chan struct{})
+	err := q.Consume(func(msg *Message) error {
+		running <- struct{}{}
+		<-release
+		return nil
+	})
+	c.Assert(err, check.IsNil)
+	_, err = q.Enqueue(workPayload{Name: "a"})
+	c.Assert(err, check.IsNil)
+	select {
+	case <-running:
+	case <-time.After(5 * time.Second):
+		c.Fatal("timeout waiting for message")
+	}
+	c.Assert(WorkerPools(), check.DeepEquals, []WorkerPool{{Queue: "q1", Concurrency: 2, Busy: 1}})
+	close(release)
+	err = q.Shutdown(context.Background())
+	c.Assert(err, check.IsNil)
+	c.Assert(WorkerPools(), check.HasLen, 0)
+}
+
+func (s *S) TestListMessages(c *check.C) {
+	q1 := NewWorkQueue("q1", WorkQueueOpts{MaxAttempts: 1})
+	q2 := NewWorkQueue("q2", WorkQueueOpts{})
+	dead, err := q1.Enqueue(targetedPayload{Name: "a"})
+	c.Assert(err, check.IsNil)
+	msg, err := q1.Receive()
+	c.Assert(err, check.IsNil)
+	err = q1.Fail(msg, errors.New("failed"))
+	c.Assert(err, check.IsNil)
+	inFlight, err := q2.Enqueue(workPayload{Name: "b"})
+	c.Assert(err, check.IsNil)
+	_, err = q2.Receive()
+	c.Assert(err, check.IsNil)
+	waiting, err := q2.Enqueue(workPayload{Name: "c"})
+	c.Assert(err, check.IsNil)
+	msgs, err := ListMessages(MessageFilter{})
+	c.Assert(err, check.IsNil)
+	c.Assert(msgs, check.HasLen, 3)
+	c.Assert(msgs[0].ID, check.Equals, dead.ID)
+	c.Assert(msgs[0].Target, check.Equals, "target-a")
+	c.Assert(msgs[0].State, check.Equals, MessageStateDead)
+	c.Assert(msgs[0].LastError, check.Equals, "failed")
+	c.Assert(msgs[0].Data, check.DeepEquals, bson.M{"name": "a"})
+	c.Assert(msgs[1].ID, check.Equals, inFlight.ID)
+	c.Assert(msgs[1].State, check.Equals, MessageStateInFlight)
+	c.Assert(msgs[1].Attempts, check.Equals, 1)
+	c.Assert(msgs[2].ID, check.Equals, waiting.ID)
+	c.Assert(msgs[2].State, check.Equals, MessageStateWaiting)
+	msgs, err = ListMessages(MessageFilter{Queue: "q2"})
+	c.Assert(err, check.IsNil)
+	c.Assert(msgs, check.HasLen, 2)
+	msgs, err = ListMessages(MessageFilter{State: MessageStateInFlight})
+	c.Assert(err, check.IsNil)
+	c.Assert(msgs, check.HasLen, 1)
+	c.Assert(msgs[0].ID, check.Equals, inFlight.ID)
+	msgs, err = ListMessages(MessageFilter{Target: "target-a"})
+	c.Assert(err, check.IsNil)
+	c.Assert(msgs, check.HasLen, 1)
+	c.Assert(msgs[0].ID, check.Equals, dead.ID)
+}
+
+func (s *S) TestRetryMessage(c *check.C) {
+	q := NewWorkQueue("q1", WorkQueueOpts{MaxAttempts: 1})
+	_, err := q.Enqueue(workPayload{Name: "a"})
+	c.Assert(err, check.IsNil)
+	msg, err := q.Receive()
+	c.Assert(err, check.IsNil)
+	err = q.Fail(msg, errors.New("failed"))
+	c.Assert(err, check.IsNil)
+	err = RetryMessage(msg.ID)
+	c.Assert(err, check.IsNil)
+	retried, err := q.Receive()
+	c.Assert(err, check.IsNil)
+	c.Assert(retried.ID, check.Equals, msg.ID)
+	c.Assert(retried.Attempts, check.Equals, 1)
+	err = RetryMessage(retried.ID)
+	c.Assert(err, check.IsNil)
+	again, err := q.Receive()
+	c.Assert(err, check.IsNil)
+	c.Assert(again.Attempts, check.Equals, 2)
+	err = q.Ack(retried)
+	c.Assert(err, check.Equals, ErrMessageNotFound)
+	err = RetryMessage(bson.NewObjectId())
+	c.Assert(err, check.Equals, ErrMessageNotFound)
+}
+
+func (s *S) TestDiscardMessage(c *check.C) {
+	q := NewWorkQueue("q1", WorkQueueOpts{})
+	msg, err := q.Enqueue(workPayload{Name: "a"})
+	c.Assert(err, check.IsNil)
+	err = DiscardMessage(msg.ID)
+	c.Assert(err, check.IsNil)
+	_, err = q.Receive()
+	c.Assert(err, check.Equals, ErrNoMessage)
+	err = DiscardMessage(msg.ID)
+	c.Assert(err, check.Equals, ErrMessageNotFound)
+}
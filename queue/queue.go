@@ -4,7 +4,9 @@
 
 // Package queue implements a Pub/Sub channel in tsuru. It abstracts
 // which server is being used and handles connection pooling and
-// data transmiting
+// data transmiting. It also provides persistent named work queues, with
+// visibility timeouts, retries and dead-lettering, and serial queues, which
+// order concurrent work sharing the same key.
 package queue
 
 import (
@@ -13,7 +15,6 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
-	"github.com/tsuru/config"
 	"github.com/tsuru/monsterqueue"
 	"github.com/tsuru/monsterqueue/mongodb"
 	"github.com/tsuru/tsuru/api/shutdown"
@@ -48,6 +49,14 @@ func ResetQueue() {
 		queueData.instance.ResetStorage()
 		queueData.instance = nil
 	}
+	if coll, err := workCollection(); err == nil {
+		coll.DropCollection()
+		coll.Close()
+	}
+	if coll, err := serialCollection(); err == nil {
+		coll.DropCollection()
+		coll.Close()
+	}
 }
 
 func TestingWaitQueueTasks(n int, timeout time.Duration) error {
@@ -91,20 +100,12 @@ func Queue() (monsterqueue.Queue, error) {
 	if queueData.instance != nil {
 		return queueData.instance, nil
 	}
-	queueMongoURL, _ := config.GetString("queue:mongo-url")
-	if queueMongoURL == "" {
-		queueMongoURL = "localhost:27017"
-	}
-	queueMongoDB, _ := config.GetString("queue:mongo-database")
-	pollingInterval, _ := config.GetFloat("queue:mongo-polling-interval")
-	if pollingInterval == 0.0 {
-		pollingInterval = 1.0
-	}
+	queueMongoURL, queueMongoDB := mongoConfig()
 	conf := mongodb.QueueConfig{
 		CollectionPrefix: "tsuru",
 		Url:              queueMongoURL,
 		Database:         queueMongoDB,
-		PollingInterval:  time.Duration(pollingInterval * float64(time.Second)),
+		PollingInterval:  pollingInterval(),
 	}
 	var err error
 	queueData.instance, err = mongodb.NewQueue(conf)
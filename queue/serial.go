@@ -0,0 +1,329 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/db/storage"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	serialCollectionName = "tsuru_serial_slots"
+
+	defaultStaleTimeout = time.Minute
+)
+
+var (
+	ErrSlotNotFound = errors.New("queue slot not found")
+	ErrSlotActive   = errors.New("queue slot is already active")
+	ErrSlotCanceled = errors.New("queue slot canceled")
+)
+
+// SerialQueueOpts holds the concurrency settings of a serial queue.
+type SerialQueueOpts struct {
+	// MaxActive is the maximum number of active slots in the queue, across
+	// all keys. Zero means no global limit.
+	MaxActive int
+
+	// StaleTimeout is the time after which a slot whose holder stopped
+	// sending heartbeats is discarded, so a crashed tsuru API instance does
+	// not block the queue forever.
+	StaleTimeout time.Duration
+}
+
+// Slot is a place in a serial queue.
+type Slot struct {
+	ID         bson.ObjectId `bson:"_id"`
+	Queue      string
+	Key        string
+	Owner      string
+	EnqueuedAt time.Time
+	Heartbeat  time.Time
+	Active     bool
+	Canceled   bool
+	CanceledBy string `bson:",omitempty"`
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// SerialQueue serializes work sharing the same key, optionally limiting the
+// number of concurrent active slots across all keys. Slots are stored in the
+// same MongoDB server configured for the queue package, so the ordering is
+// shared by all tsuru API instances.
+//
+// The global limit is checked before activating a slot, instances
+// activating slots at the same time may briefly exceed it.
+type SerialQueue struct {
+	name string
+	opts SerialQueueOpts
+}
+
+// NewSerialQueue returns the serial queue with the given name, unset options
+// are replaced by their default values.
+func NewSerialQueue(name string, opts SerialQueueOpts) *SerialQueue {
+	if opts.StaleTimeout <= 0 {
+		opts.StaleTimeout = defaultStaleTimeout
+	}
+	return &SerialQueue{name: name, opts: opts}
+}
+
+func (q *SerialQueue) Name() string {
+	return q.name
+}
+
+// Join adds a new slot to the end of the queue for the given key. Stale
+// slots are removed from the queue.
+func (q *SerialQueue) Join(key, owner string) (*Slot, error) {
+	coll, err := serialCollection()
+	if err != nil {
+		return nil, err
+	}
+	defer coll.Close()
+	now := time.Now().UTC()
+	_, err = coll.RemoveAll(bson.M{"queue": q.name, "heartbeat": bson.M{"$lt": now.Add(-q.opts.StaleTimeout)}})
+	if err != nil {
+		return nil, err
+	}
+	slot := &Slot{
+		ID:         bson.NewObjectId(),
+		Queue:      q.name,
+		Key:        key,
+		Owner:      owner,
+		EnqueuedAt: now,
+		Heartbeat:  now,
+	}
+	err = coll.Insert(slot)
+	if err != nil {
+		return nil, err
+	}
+	return slot, nil
+}
+
+// Position returns the number of slots ahead of the given slot, counting both
+// slots with the same key and, when the queue has a global limit, active
+// slots with other keys exceeding it. A position of zero means the slot may
+// be activated.
+func (q *SerialQueue) Position(slot *Slot) (int, error) {
+	coll, err := serialCollection()
+	if err != nil {
+		return 0, err
+	}
+	defer coll.Close()
+	return q.position(coll, slot)
+}
+
+func (q *SerialQueue) position(coll *storage.Collection, slot *Slot) (int, error) {
+	var current Slot
+	err := coll.FindId(slot.ID).One(&current)
+	if err == mgo.ErrNotFound {
+		return 0, ErrSlotNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	if current.Canceled {
+		return 0, ErrSlotCanceled
+	}
+	if current.Active {
+		return 0, nil
+	}
+	alive := bson.M{"$gte": time.Now().UTC().Add(-q.opts.StaleTimeout)}
+	ahead, err := coll.Find(bson.M{
+		"queue":     q.name,
+		"key":       current.Key,
+		"canceled":  false,
+		"heartbeat": alive,
+		"$or": []bson.M{
+			{"active": true},
+			{"enqueuedat": bson.M{"$lt": current.EnqueuedAt}},
+			{"enqueuedat": current.EnqueuedAt, "_id": bson.M{"$lt": current.ID}},
+		},
+	}).Count()
+	if err != nil {
+		return 0, err
+	}
+	if ahead > 0 || q.opts.MaxActive <= 0 {
+		return ahead, nil
+	}
+	active, err := coll.Find(bson.M{"queue": q.name, "active": true, "heartbeat": alive}).Count()
+	if err != nil {
+		return 0, err
+	}
+	if active >= q.opts.MaxActive {
+		return active - q.opts.MaxActive + 1, nil
+	}
+	return 0, nil
+}
+
+// Acquire blocks until the slot is activated, calling notify whenever its
+// position in the queue changes. The slot is kept alive with heartbeats
+// until it's released. ErrSlotCanceled is returned if the slot is canceled
+// while waiting, in which case it's removed from the queue.
+func (q *SerialQueue) Acquire(ctx context.Context, slot *Slot, notify func(position int)) error {
+	slot.stop = make(chan struct{})
+	slot.done = make(chan struct{})
+	go q.heartbeat(slot)
+	err := q.waitTurn(ctx, slot, notify)
+	if err != nil {
+		q.Release(slot)
+		return err
+	}
+	return nil
+}
+
+func (q *SerialQueue) waitTurn(ctx context.Context, slot *Slot, notify func(position int)) error {
+	lastPosition := -1
+	interval := pollingInterval()
+	for {
+		activated, position, err := q.tryActivate(slot)
+		if err != nil {
+			return err
+		}
+		if activated {
+			slot.Active = true
+			return nil
+		}
+		if position != lastPosition && notify != nil {
+			notify(position)
+		}
+		lastPosition = position
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (q *SerialQueue) tryActivate(slot *Slot) (bool, int, error) {
+	coll, err := serialCollection()
+	if err != nil {
+		return false, 0, err
+	}
+	defer coll.Close()
+	position, err := q.position(coll, slot)
+	if err != nil || position > 0 {
+		return false, position, err
+	}
+	err = coll.Update(
+		bson.M{"_id": slot.ID, "canceled": false},
+		bson.M{"$set": bson.M{"active": true, "heartbeat": time.Now().UTC()}},
+	)
+	if err == mgo.ErrNotFound {
+		return false, 0, ErrSlotCanceled
+	}
+	if err != nil {
+		return false, 0, err
+	}
+	return true, 0, nil
+}
+
+func (q *SerialQueue) heartbeat(slot *Slot) {
+	defer close(slot.done)
+	interval := q.opts.StaleTimeout / 3
+	for {
+		select {
+		case <-slot.stop:
+			return
+		case <-time.After(interval):
+		}
+		coll, err := serialCollection()
+		if err != nil {
+			logger.Errorf("[serial-queue %s] unable to send heartbeat for slot %s: %s", q.name, slot.ID.Hex(), err)
+			continue
+		}
+		err = coll.UpdateId(slot.ID, bson.M{"$set": bson.M{"heartbeat": time.Now().UTC()}})
+		coll.Close()
+		if err != nil && err != mgo.ErrNotFound {
+			logger.Errorf("[serial-queue %s] unable to send heartbeat for slot %s: %s", q.name, slot.ID.Hex(), err)
+		}
+	}
+}
+
+// Release removes the slot from the queue, allowing the next slot with the
+// same key to be activated.
+func (q *SerialQueue) Release(slot *Slot) error {
+	if slot.stop != nil {
+		close(slot.stop)
+		<-slot.done
+		slot.stop = nil
+	}
+	coll, err := serialCollection()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	err = coll.RemoveId(slot.ID)
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// Cancel marks a waiting slot as canceled, causing Acquire to return
+// ErrSlotCanceled. Active slots cannot be canceled.
+func (q *SerialQueue) Cancel(key string, id bson.ObjectId, owner string) error {
+	coll, err := serialCollection()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	err = coll.Update(
+		bson.M{"_id": id, "queue": q.name, "key": key, "active": false},
+		bson.M{"$set": bson.M{"canceled": true, "canceledby": owner}},
+	)
+	if err != mgo.ErrNotFound {
+		return err
+	}
+	n, err := coll.Find(bson.M{"_id": id, "queue": q.name, "key": key}).Count()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrSlotActive
+	}
+	return ErrSlotNotFound
+}
+
+// List returns the live slots for the given key, in queue order. An empty key
+// lists the slots for all keys.
+func (q *SerialQueue) List(key string) ([]Slot, error) {
+	coll, err := serialCollection()
+	if err != nil {
+		return nil, err
+	}
+	defer coll.Close()
+	query := bson.M{
+		"queue":     q.name,
+		"canceled":  false,
+		"heartbeat": bson.M{"$gte": time.Now().UTC().Add(-q.opts.StaleTimeout)},
+	}
+	if key != "" {
+		query["key"] = key
+	}
+	var slots []Slot
+	err = coll.Find(query).Sort("-active", "enqueuedat", "_id").All(&slots)
+	if err != nil {
+		return nil, err
+	}
+	return slots, nil
+}
+
+func serialCollection() (*storage.Collection, error) {
+	url, dbName := mongoConfig()
+	conn, err := storage.Open(url, dbName)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not connect to serial queue storage, please check queue:mongo-url and queue:mongo-database config entries. error")
+	}
+	coll := conn.Collection(serialCollectionName)
+	coll.EnsureIndex(mgo.Index{Key: []string{"queue", "key", "enqueuedat"}})
+	return coll, nil
+}
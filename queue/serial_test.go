@@ -0,0 +1,136 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"time"
+
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestSerialQueueSameKeyIsSerialized(c *check.C) {
+	config.Set("queue:mongo-polling-interval", 0.01)
+	defer config.Unset("queue:mongo-polling-interval")
+	q := NewSerialQueue("q1", SerialQueueOpts{})
+	first, err := q.Join("app1", "me")
+	c.Assert(err, check.IsNil)
+	second, err := q.Join("app1", "you")
+	c.Assert(err, check.IsNil)
+	err = q.Acquire(context.Background(), first, nil)
+	c.Assert(err, check.IsNil)
+	defer q.Release(first)
+	position, err := q.Position(second)
+	c.Assert(err, check.IsNil)
+	c.Assert(position, check.Equals, 1)
+	positions := make(chan int, 10)
+	acquired := make(chan error)
+	go func() {
+		acquired <- q.Acquire(context.Background(), second, func(p int) { positions <- p })
+	}()
+	c.Assert(<-positions, check.Equals, 1)
+	select {
+	case <-acquired:
+		c.Fatal("second slot acquired while first is active")
+	case <-time.After(100 * time.Millisecond):
+	}
+	err = q.Release(first)
+	c.Assert(err, check.IsNil)
+	select {
+	case err = <-acquired:
+		c.Assert(err, check.IsNil)
+	case <-time.After(5 * time.Second):
+		c.Fatal("timeout waiting for second slot")
+	}
+	err = q.Release(second)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestSerialQueueDifferentKeysAreIndependent(c *check.C) {
+	q := NewSerialQueue("q1", SerialQueueOpts{})
+	first, err := q.Join("app1", "me")
+	c.Assert(err, check.IsNil)
+	second, err := q.Join("app2", "me")
+	c.Assert(err, check.IsNil)
+	err = q.Acquire(context.Background(), first, nil)
+	c.Assert(err, check.IsNil)
+	defer q.Release(first)
+	err = q.Acquire(context.Background(), second, nil)
+	c.Assert(err, check.IsNil)
+	defer q.Release(second)
+}
+
+func (s *S) TestSerialQueueMaxActive(c *check.C) {
+	q := NewSerialQueue("q1", SerialQueueOpts{MaxActive: 1})
+	first, err := q.Join("app1", "me")
+	c.Assert(err, check.IsNil)
+	second, err := q.Join("app2", "me")
+	c.Assert(err, check.IsNil)
+	err = q.Acquire(context.Background(), first, nil)
+	c.Assert(err, check.IsNil)
+	defer q.Release(first)
+	position, err := q.Position(second)
+	c.Assert(err, check.IsNil)
+	c.Assert(position, check.Equals, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = q.Acquire(ctx, second, nil)
+	c.Assert(err, check.Equals, context.DeadlineExceeded)
+	slots, err := q.List("")
+	c.Assert(err, check.IsNil)
+	c.Assert(slots, check.HasLen, 1)
+}
+
+func (s *S) TestSerialQueueCancel(c *check.C) {
+	config.Set("queue:mongo-polling-interval", 0.01)
+	defer config.Unset("queue:mongo-polling-interval")
+	q := NewSerialQueue("q1", SerialQueueOpts{})
+	first, err := q.Join("app1", "me")
+	c.Assert(err, check.IsNil)
+	second, err := q.Join("app1", "you")
+	c.Assert(err, check.IsNil)
+	err = q.Acquire(context.Background(), first, nil)
+	c.Assert(err, check.IsNil)
+	defer q.Release(first)
+	err = q.Cancel("app1", first.ID, "me")
+	c.Assert(err, check.Equals, ErrSlotActive)
+	err = q.Cancel("app2", second.ID, "me")
+	c.Assert(err, check.Equals, ErrSlotNotFound)
+	acquired := make(chan error)
+	go func() {
+		acquired <- q.Acquire(context.Background(), second, nil)
+	}()
+	err = q.Cancel("app1", second.ID, "me")
+	c.Assert(err, check.IsNil)
+	select {
+	case err = <-acquired:
+		c.Assert(err, check.Equals, ErrSlotCanceled)
+	case <-time.After(5 * time.Second):
+		c.Fatal("timeout waiting for canceled slot")
+	}
+	slots, err := q.List("app1")
+	c.Assert(err, check.IsNil)
+	c.Assert(slots, check.HasLen, 1)
+	c.Assert(slots[0].ID, check.Equals, first.ID)
+}
+
+func (s *S) TestSerialQueueStaleSlotsAreIgnored(c *check.C) {
+	q := NewSerialQueue("q1", SerialQueueOpts{StaleTimeout: 100 * time.Millisecond})
+	first, err := q.Join("app1", "me")
+	c.Assert(err, check.IsNil)
+	second, err := q.Join("app1", "you")
+	c.Assert(err, check.IsNil)
+	position, err := q.Position(second)
+	c.Assert(err, check.IsNil)
+	c.Assert(position, check.Equals, 1)
+	time.Sleep(200 * time.Millisecond)
+	err = q.Acquire(context.Background(), second, nil)
+	c.Assert(err, check.IsNil)
+	defer q.Release(second)
+	position, err = q.Position(first)
+	c.Assert(err, check.IsNil)
+	c.Assert(position, check.Equals, 1)
+}
@@ -0,0 +1,451 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api/shutdown"
+	"github.com/tsuru/tsuru/db/storage"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/log"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	workCollectionName = "tsuru_work_messages"
+
+	defaultVisibilityTimeout = 5 * time.Minute
+	defaultMaxAttempts       = 5
+	defaultRetryDelay        = 10 * time.Second
+)
+
+var (
+	ErrNoMessage        = errors.New("no message available in queue")
+	ErrMessageNotFound  = errors.New("message not found, it may have been delivered again after its visibility timeout")
+	ErrAlreadyConsuming = errors.New("work queue already being consumed")
+)
+
+var logger = log.Subsystem("queue")
+
+// WorkQueueOpts holds the delivery settings of a named work queue.
+type WorkQueueOpts struct {
+	// VisibilityTimeout is the time a received message is hidden from other
+	// consumers. Messages not acknowledged within this time are delivered
+	// again.
+	VisibilityTimeout time.Duration
+
+	// MaxAttempts is the number of times a message is delivered before
+	// being moved to the dead letters.
+	MaxAttempts int
+
+	// RetryDelay is the base delay before a failed message is delivered
+	// again, it's doubled on each subsequent failure.
+	RetryDelay time.Duration
+
+	// Concurrency is the number of messages processed at the same time by
+	// Consume. The queue:work-queues:<name>:concurrency config entry takes
+	// precedence over it and, when both are unset, the
+	// queue:work-concurrency config entry is used, defaulting to 1.
+	Concurrency int
+
+	// RetryPolicy decides whether a failed message is delivered again,
+	// messages not retried are moved straight to the dead letters. It
+	// defaults to RetryTransient.
+	RetryPolicy RetryPolicy
+}
+
+// RetryPolicy reports whether a message that failed with err should be
+// delivered again. It's only called while the message has delivery attempts
+// left.
+type RetryPolicy func(msg *Message, err error) bool
+
+// RetryTransient retries messages failing with errors classified as
+// retryable by errors.IsRetryable, such as network timeouts and server
+// errors, failing fast on validation and other client errors.
+func RetryTransient(msg *Message, err error) bool {
+	return tsuruErrors.IsRetryable(err)
+}
+
+// RetryAlways retries messages regardless of the error.
+func RetryAlways(msg *Message, err error) bool {
+	return true
+}
+
+// Targeter may be implemented by message payloads to describe the object
+// affected by the message, which is displayed when inspecting queues.
+type Targeter interface {
+	Target() string
+}
+
+const (
+	MessageStateWaiting  = "waiting"
+	MessageStateInFlight = "in-flight"
+	MessageStateExpired  = "expired"
+	MessageStateDead     = "dead"
+)
+
+// Message is a unit of work stored in a work queue.
+type Message struct {
+	ID         bson.ObjectId `bson:"_id"`
+	Queue      string
+	Target     string   `bson:",omitempty"`
+	Payload    bson.Raw `json:"-"`
+	Attempts   int
+	EnqueuedAt time.Time
+	VisibleAt  time.Time
+	ReceivedAt time.Time
+	InFlight   bool
+	LastError  string `bson:",omitempty"`
+	Dead       bool
+}
+
+// State returns the delivery state of the message at the given time.
+// Expired messages were received but not acknowledged within the visibility
+// timeout and will be delivered again.
+func (m *Message) State(now time.Time) string {
+	switch {
+	case m.Dead:
+		return MessageStateDead
+	case m.InFlight && m.VisibleAt.After(now):
+		return MessageStateInFlight
+	case m.InFlight:
+		return MessageStateExpired
+	}
+	return MessageStateWaiting
+}
+
+// Decode unmarshals the message payload into v.
+func (m *Message) Decode(v interface{}) error {
+	return m.Payload.Unmarshal(v)
+}
+
+// WorkHandler processes a message received from a work queue. Returning an
+// error causes the message to be retried, or moved to the dead letters once
+// the maximum number of attempts is reached or when the queue's retry policy
+// doesn't retry the error. Wrap errors with errors.Permanent to fail fast.
+type WorkHandler func(msg *Message) error
+
+// WorkQueue is a persistent named queue, stored in the same MongoDB server
+// configured for the queue package. Messages are delivered at least once:
+// consumers must acknowledge them within the visibility timeout, otherwise
+// they're delivered again.
+type WorkQueue struct {
+	name string
+	opts WorkQueueOpts
+
+	mu      sync.Mutex
+	stop    chan struct{}
+	done    chan struct{}
+	workers int
+	busy    int32
+}
+
+// NewWorkQueue returns the work queue with the given name, unset options are
+// replaced by their default values.
+func NewWorkQueue(name string, opts WorkQueueOpts) *WorkQueue {
+	if opts.VisibilityTimeout <= 0 {
+		opts.VisibilityTimeout = defaultVisibilityTimeout
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultMaxAttempts
+	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = defaultRetryDelay
+	}
+	if opts.RetryPolicy == nil {
+		opts.RetryPolicy = RetryTransient
+	}
+	return &WorkQueue{name: name, opts: opts}
+}
+
+func (q *WorkQueue) Name() string {
+	return q.name
+}
+
+// Enqueue adds a new message to the queue. The payload must be serializable
+// to BSON.
+func (q *WorkQueue) Enqueue(payload interface{}) (*Message, error) {
+	data, err := bson.Marshal(payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to serialize message payload")
+	}
+	coll, err := workCollection()
+	if err != nil {
+		return nil, err
+	}
+	defer coll.Close()
+	now := time.Now().UTC()
+	msg := &Message{
+		ID:         bson.NewObjectId(),
+		Queue:      q.name,
+		Payload:    bson.Raw{Kind: 0x03, Data: data},
+		EnqueuedAt: now,
+		VisibleAt:  now,
+	}
+	if t, ok := payload.(Targeter); ok {
+		msg.Target = t.Target()
+	}
+	err = coll.Insert(msg)
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// Receive returns the next visible message in the queue, hiding it from
+// other consumers for the duration of the visibility timeout. ErrNoMessage is
+// returned when there are no visible messages.
+func (q *WorkQueue) Receive() (*Message, error) {
+	coll, err := workCollection()
+	if err != nil {
+		return nil, err
+	}
+	defer coll.Close()
+	now := time.Now().UTC()
+	var msg Message
+	_, err = coll.Find(bson.M{
+		"queue":     q.name,
+		"dead":      false,
+		"visibleat": bson.M{"$lte": now},
+	}).Sort("visibleat").Apply(mgo.Change{
+		Update: bson.M{
+			"$set": bson.M{
+				"visibleat":  now.Add(q.opts.VisibilityTimeout),
+				"receivedat": now,
+				"inflight":   true,
+			},
+			"$inc": bson.M{"attempts": 1},
+		},
+		ReturnNew: true,
+	}, &msg)
+	if err == mgo.ErrNotFound {
+		return nil, ErrNoMessage
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// Ack removes a processed message from the queue.
+func (q *WorkQueue) Ack(msg *Message) error {
+	coll, err := workCollection()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	err = coll.Remove(bson.M{"_id": msg.ID, "attempts": msg.Attempts})
+	if err == mgo.ErrNotFound {
+		return ErrMessageNotFound
+	}
+	return err
+}
+
+// Fail records a failed attempt to process a message. The message is
+// delivered again after the retry delay or moved to the dead letters if it
+// reached the maximum number of attempts or the queue's retry policy rejects
+// msgErr.
+func (q *WorkQueue) Fail(msg *Message, msgErr error) error {
+	coll, err := workCollection()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	update := bson.M{"lasterror": fmt.Sprintf("%v", msgErr), "inflight": false}
+	if msg.Attempts >= q.opts.MaxAttempts || !q.opts.RetryPolicy(msg, msgErr) {
+		update["dead"] = true
+	} else {
+		delay := q.opts.RetryDelay << uint(msg.Attempts-1)
+		update["visibleat"] = time.Now().UTC().Add(delay)
+	}
+	err = coll.Update(bson.M{"_id": msg.ID, "attempts": msg.Attempts}, bson.M{"$set": update})
+	if err == mgo.ErrNotFound {
+		return ErrMessageNotFound
+	}
+	return err
+}
+
+// DeadLetters returns the messages that failed in all delivery attempts.
+func (q *WorkQueue) DeadLetters() ([]Message, error) {
+	coll, err := workCollection()
+	if err != nil {
+		return nil, err
+	}
+	defer coll.Close()
+	var msgs []Message
+	err = coll.Find(bson.M{"queue": q.name, "dead": true}).Sort("enqueuedat").All(&msgs)
+	if err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+// Requeue moves a message from the dead letters back to the queue,
+// resetting its attempts.
+func (q *WorkQueue) Requeue(id bson.ObjectId) error {
+	coll, err := workCollection()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	err = coll.Update(bson.M{"_id": id, "queue": q.name, "dead": true}, bson.M{
+		"$set": bson.M{"dead": false, "inflight": false, "attempts": 0, "visibleat": time.Now().UTC()},
+	})
+	if err == mgo.ErrNotFound {
+		return ErrMessageNotFound
+	}
+	return err
+}
+
+// Consume starts processing messages from the queue in background, calling
+// handler for each received message. Processing stops on tsuru's shutdown.
+func (q *WorkQueue) Consume(handler WorkHandler) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.stop != nil {
+		return ErrAlreadyConsuming
+	}
+	q.stop = make(chan struct{})
+	q.done = make(chan struct{})
+	q.workers = q.concurrency()
+	interval := pollingInterval()
+	var wg sync.WaitGroup
+	for i := 0; i < q.workers; i++ {
+		wg.Add(1)
+		go func(stop chan struct{}) {
+			defer wg.Done()
+			q.consumeLoop(handler, interval, stop)
+		}(q.stop)
+	}
+	go func(done chan struct{}) {
+		wg.Wait()
+		close(done)
+	}(q.done)
+	registerConsumer(q)
+	shutdown.Register(q)
+	return nil
+}
+
+func (q *WorkQueue) concurrency() int {
+	n, _ := config.GetInt("queue:work-queues:" + q.name + ":concurrency")
+	if n <= 0 {
+		n = q.opts.Concurrency
+	}
+	if n <= 0 {
+		n, _ = config.GetInt("queue:work-concurrency")
+	}
+	if n <= 0 {
+		n = 1
+	}
+	return n
+}
+
+func (q *WorkQueue) consumeLoop(handler WorkHandler, interval time.Duration, stop chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		msg, err := q.Receive()
+		if err == nil {
+			q.handle(handler, msg)
+			continue
+		}
+		if err != ErrNoMessage {
+			logger.Errorf("[work-queue %s] unable to receive message: %s", q.name, err)
+		}
+		select {
+		case <-stop:
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (q *WorkQueue) handle(handler WorkHandler, msg *Message) {
+	atomic.AddInt32(&q.busy, 1)
+	defer atomic.AddInt32(&q.busy, -1)
+	err := safeHandle(handler, msg)
+	if err == nil {
+		err = q.Ack(msg)
+		if err != nil {
+			logger.Errorf("[work-queue %s] unable to ack message %s: %s", q.name, msg.ID.Hex(), err)
+		}
+		return
+	}
+	logger.Errorf("[work-queue %s] error processing message %s (attempt %d of %d): %s", q.name, msg.ID.Hex(), msg.Attempts, q.opts.MaxAttempts, err)
+	err = q.Fail(msg, err)
+	if err != nil {
+		logger.Errorf("[work-queue %s] unable to record failure of message %s: %s", q.name, msg.ID.Hex(), err)
+	}
+}
+
+func safeHandle(handler WorkHandler, msg *Message) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Errorf("panic processing message: %v", r)
+		}
+	}()
+	return handler(msg)
+}
+
+func (q *WorkQueue) Shutdown(ctx context.Context) error {
+	q.mu.Lock()
+	stop, done := q.stop, q.done
+	q.stop, q.done = nil, nil
+	q.mu.Unlock()
+	if stop == nil {
+		return nil
+	}
+	unregisterConsumer(q)
+	close(stop)
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
+
+func (q *WorkQueue) String() string {
+	return fmt.Sprintf("work queue %q", q.name)
+}
+
+func workCollection() (*storage.Collection, error) {
+	url, dbName := mongoConfig()
+	conn, err := storage.Open(url, dbName)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not connect to work queue storage, please check queue:mongo-url and queue:mongo-database config entries. error")
+	}
+	coll := conn.Collection(workCollectionName)
+	coll.EnsureIndex(mgo.Index{Key: []string{"queue", "dead", "visibleat"}})
+	return coll, nil
+}
+
+func mongoConfig() (string, string) {
+	queueMongoURL, _ := config.GetString("queue:mongo-url")
+	if queueMongoURL == "" {
+		queueMongoURL = "localhost:27017"
+	}
+	queueMongoDB, _ := config.GetString("queue:mongo-database")
+	return queueMongoURL, queueMongoDB
+}
+
+func pollingInterval() time.Duration {
+	pollingInterval, _ := config.GetFloat("queue:mongo-polling-interval")
+	if pollingInterval == 0.0 {
+		pollingInterval = 1.0
+	}
+	return time.Duration(pollingInterval * float64(time.Second))
+}
@@ -0,0 +1,182 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"io/ioutil"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api/shutdown"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"gopkg.in/check.v1"
+)
+
+type workPayload struct {
+	Name string
+}
+
+func (s *S) TestWorkQueueEnqueueReceiveAck(c *check.C) {
+	q := NewWorkQueue("q1", WorkQueueOpts{})
+	_, err := q.Enqueue(workPayload{Name: "a"})
+	c.Assert(err, check.IsNil)
+	msg, err := q.Receive()
+	c.Assert(err, check.IsNil)
+	c.Assert(msg.Queue, check.Equals, "q1")
+	c.Assert(msg.Attempts, check.Equals, 1)
+	var payload workPayload
+	err = msg.Decode(&payload)
+	c.Assert(err, check.IsNil)
+	c.Assert(payload, check.DeepEquals, workPayload{Name: "a"})
+	_, err = q.Receive()
+	c.Assert(err, check.Equals, ErrNoMessage)
+	err = q.Ack(msg)
+	c.Assert(err, check.IsNil)
+	err = q.Ack(msg)
+	c.Assert(err, check.Equals, ErrMessageNotFound)
+}
+
+func (s *S) TestWorkQueueNamedQueuesAreIsolated(c *check.C) {
+	q1 := NewWorkQueue("q1", WorkQueueOpts{})
+	q2 := NewWorkQueue("q2", WorkQueueOpts{})
+	_, err := q1.Enqueue(workPayload{Name: "a"})
+	c.Assert(err, check.IsNil)
+	_, err = q2.Receive()
+	c.Assert(err, check.Equals, ErrNoMessage)
+	msg, err := q1.Receive()
+	c.Assert(err, check.IsNil)
+	c.Assert(msg.Queue, check.Equals, "q1")
+}
+
+func (s *S) TestWorkQueueVisibilityTimeout(c *check.C) {
+	q := NewWorkQueue("q1", WorkQueueOpts{VisibilityTimeout: 100 * time.Millisecond})
+	_, err := q.Enqueue(workPayload{Name: "a"})
+	c.Assert(err, check.IsNil)
+	first, err := q.Receive()
+	c.Assert(err, check.IsNil)
+	_, err = q.Receive()
+	c.Assert(err, check.Equals, ErrNoMessage)
+	time.Sleep(200 * time.Millisecond)
+	second, err := q.Receive()
+	c.Assert(err, check.IsNil)
+	c.Assert(second.ID, check.Equals, first.ID)
+	c.Assert(second.Attempts, check.Equals, 2)
+	err = q.Ack(first)
+	c.Assert(err, check.Equals, ErrMessageNotFound)
+	err = q.Ack(second)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestWorkQueueFailRetriesAndDeadLetters(c *check.C) {
+	q := NewWorkQueue("q1", WorkQueueOpts{MaxAttempts: 2, RetryDelay: 50 * time.Millisecond})
+	_, err := q.Enqueue(workPayload{Name: "a"})
+	c.Assert(err, check.IsNil)
+	msg, err := q.Receive()
+	c.Assert(err, check.IsNil)
+	err = q.Fail(msg, errors.New("failed once"))
+	c.Assert(err, check.IsNil)
+	_, err = q.Receive()
+	c.Assert(err, check.Equals, ErrNoMessage)
+	time.Sleep(100 * time.Millisecond)
+	msg, err = q.Receive()
+	c.Assert(err, check.IsNil)
+	c.Assert(msg.Attempts, check.Equals, 2)
+	c.Assert(msg.LastError, check.Equals, "failed once")
+	err = q.Fail(msg, errors.New("failed twice"))
+	c.Assert(err, check.IsNil)
+	time.Sleep(200 * time.Millisecond)
+	_, err = q.Receive()
+	c.Assert(err, check.Equals, ErrNoMessage)
+	dead, err := q.DeadLetters()
+	c.Assert(err, check.IsNil)
+	c.Assert(dead, check.HasLen, 1)
+	c.Assert(dead[0].ID, check.Equals, msg.ID)
+	c.Assert(dead[0].LastError, check.Equals, "failed twice")
+	err = q.Requeue(msg.ID)
+	c.Assert(err, check.IsNil)
+	msg, err = q.Receive()
+	c.Assert(err, check.IsNil)
+	c.Assert(msg.Attempts, check.Equals, 1)
+	dead, err = q.DeadLetters()
+	c.Assert(err, check.IsNil)
+	c.Assert(dead, check.HasLen, 0)
+}
+
+func (s *S) TestWorkQueueFailPermanentErrorGoesToDeadLetters(c *check.C) {
+	q := NewWorkQueue("q1", WorkQueueOpts{MaxAttempts: 5})
+	_, err := q.Enqueue(workPayload{Name: "a"})
+	c.Assert(err, check.IsNil)
+	msg, err := q.Receive()
+	c.Assert(err, check.IsNil)
+	err = q.Fail(msg, &tsuruErrors.ValidationError{Message: "invalid payload"})
+	c.Assert(err, check.IsNil)
+	dead, err := q.DeadLetters()
+	c.Assert(err, check.IsNil)
+	c.Assert(dead, check.HasLen, 1)
+	c.Assert(dead[0].Attempts, check.Equals, 1)
+	c.Assert(dead[0].LastError, check.Equals, "invalid payload")
+}
+
+func (s *S) TestWorkQueueFailCustomRetryPolicy(c *check.C) {
+	var policyErr error
+	q := NewWorkQueue("q1", WorkQueueOpts{
+		MaxAttempts: 5,
+		RetryDelay:  time.Millisecond,
+		RetryPolicy: func(msg *Message, err error) bool {
+			policyErr = err
+			return true
+		},
+	})
+	_, err := q.Enqueue(workPayload{Name: "a"})
+	c.Assert(err, check.IsNil)
+	msg, err := q.Receive()
+	c.Assert(err, check.IsNil)
+	failure := tsuruErrors.Permanent(errors.New("failed"))
+	err = q.Fail(msg, failure)
+	c.Assert(err, check.IsNil)
+	c.Assert(policyErr, check.Equals, failure)
+	dead, err := q.DeadLetters()
+	c.Assert(err, check.IsNil)
+	c.Assert(dead, check.HasLen, 0)
+	time.Sleep(10 * time.Millisecond)
+	msg, err = q.Receive()
+	c.Assert(err, check.IsNil)
+	c.Assert(msg.Attempts, check.Equals, 2)
+}
+
+func (s *S) TestWorkQueueConsume(c *check.C) {
+	config.Set("queue:mongo-polling-interval", 0.01)
+	defer config.Unset("queue:mongo-polling-interval")
+	q := NewWorkQueue("q1", WorkQueueOpts{MaxAttempts: 3, RetryDelay: time.Millisecond})
+	received := make(chan string, 10)
+	calls := 0
+	err := q.Consume(func(msg *Message) error {
+		calls++
+		var payload workPayload
+		decodeErr := msg.Decode(&payload)
+		c.Assert(decodeErr, check.IsNil)
+		if calls == 1 {
+			panic("first call fails")
+		}
+		received <- payload.Name
+		return nil
+	})
+	c.Assert(err, check.IsNil)
+	err = q.Consume(func(msg *Message) error { return nil })
+	c.Assert(err, check.Equals, ErrAlreadyConsuming)
+	_, err = q.Enqueue(workPayload{Name: "a"})
+	c.Assert(err, check.IsNil)
+	select {
+	case name := <-received:
+		c.Assert(name, check.Equals, "a")
+	case <-time.After(5 * time.Second):
+		c.Fatal("timeout waiting for message")
+	}
+	c.Assert(calls, check.Equals, 2)
+	shutdown.Do(context.Background(), ioutil.Discard)
+	c.Assert(q.stop, check.IsNil)
+}
@@ -19,6 +19,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/tsuru/config"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/net"
 	"github.com/tsuru/tsuru/router"
@@ -26,6 +27,8 @@ import (
 
 const routerType = "api"
 
+var logger = log.Subsystem("router")
+
 var (
 	_ router.OptsRouter              = &apiRouter{}
 	_ router.Router                  = &apiRouter{}
@@ -131,7 +134,7 @@ func createRouter(routerName, configPrefix string) (router.Router, error) {
 		var err error
 		supports[i], err = baseRouter.checkSupports(s)
 		if err != nil {
-			log.Errorf("failed to fetch %q support from router %q: %s", s, routerName, err)
+			logger.Errorf("failed to fetch %q support from router %q: %s", s, routerName, err)
 		}
 	}
 	if r, ok := ifMap[supports]; ok {
@@ -328,7 +331,7 @@ func (r *apiRouter) do(method, path string, body io.Reader) (data []byte, code i
 		if err == nil {
 			code = resp.StatusCode
 		}
-		log.Debugf("%s %s %s %s: %d", r.routerName, method, url, string(bodyData), code)
+		logger.Debugf("%s %s %s %s: %d", r.routerName, method, url, string(bodyData), code)
 	}
 	if err != nil {
 		return nil, 0, err
@@ -340,7 +343,8 @@ func (r *apiRouter) do(method, path string, body io.Reader) (data []byte, code i
 		return data, code, errors.Errorf("failed to read response body for %s: %s", url, err)
 	}
 	if resp.StatusCode >= 300 {
-		return data, code, errors.Errorf("failed to request %s - %d - %s", url, code, data)
+		err = errors.Errorf("failed to request %s - %d - %s", url, code, data)
+		return data, code, tsuruErrors.ClassifyHTTPStatus(err, code)
 	}
 	return data, code, nil
 }
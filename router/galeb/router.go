@@ -21,6 +21,8 @@ import (
 
 const routerType = "galeb"
 
+var logger = log.Subsystem("router")
+
 var clientCache struct {
 	sync.Mutex
 	cache map[string]*galebClient.GalebClient
@@ -131,7 +133,7 @@ func (r *galebRouter) AddBackend(name string) (err error) {
 	if err != nil {
 		cleanupErr := r.forceCleanupBackend(name)
 		if cleanupErr != nil {
-			log.Errorf("unable to cleanup router after failure %+v", cleanupErr)
+			logger.Errorf("unable to cleanup router after failure %+v", cleanupErr)
 		}
 		return err
 	}
@@ -139,7 +141,7 @@ func (r *galebRouter) AddBackend(name string) (err error) {
 	if err != nil {
 		cleanupErr := r.forceCleanupBackend(name)
 		if cleanupErr != nil {
-			log.Errorf("unable to cleanup router after failure %+v", cleanupErr)
+			logger.Errorf("unable to cleanup router after failure %+v", cleanupErr)
 		}
 		return err
 	}
@@ -147,7 +149,7 @@ func (r *galebRouter) AddBackend(name string) (err error) {
 	if err != nil {
 		cleanupErr := r.forceCleanupBackend(name)
 		if cleanupErr != nil {
-			log.Errorf("unable to cleanup router after failure %+v", cleanupErr)
+			logger.Errorf("unable to cleanup router after failure %+v", cleanupErr)
 		}
 		return err
 	}
@@ -155,7 +157,7 @@ func (r *galebRouter) AddBackend(name string) (err error) {
 	if err != nil {
 		cleanupErr := r.forceCleanupBackend(name)
 		if cleanupErr != nil {
-			log.Errorf("unable to cleanup router after failure %+v", cleanupErr)
+			logger.Errorf("unable to cleanup router after failure %+v", cleanupErr)
 		}
 		return err
 	}
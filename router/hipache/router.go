@@ -36,6 +36,8 @@ var (
 	redisClientsMut sync.RWMutex
 )
 
+var logger = log.Subsystem("router")
+
 func init() {
 	router.Register(routerType, createHipacheRouter)
 	router.Register("planb", createPlanbRouter)
@@ -183,7 +185,7 @@ func (r *hipacheRouter) AddRoutes(name string, addresses []*url.URL) (err error)
 	}
 	domain, err := config.GetString(r.prefix + ":domain")
 	if err != nil {
-		log.Errorf("error on getting hipache domain in add route for %s - %v", backendName, addresses)
+		logger.Errorf("error on getting hipache domain in add route for %s - %v", backendName, addresses)
 		return &router.RouterError{Op: "add", Err: err}
 	}
 	routes, err := r.Routes(name)
@@ -202,7 +204,7 @@ addresses:
 		toAdd = append(toAdd, addr.String())
 	}
 	if len(toAdd) == 0 {
-		log.Debugf("[add-routes] no new routes to add for %q", name)
+		logger.Debugf("[add-routes] no new routes to add for %q", name)
 		return nil
 	}
 	frontend := "frontend:" + backendName + "." + domain
@@ -211,7 +213,7 @@ addresses:
 	}
 	cnames, err := r.getCNames(backendName)
 	if err != nil {
-		log.Errorf("error on get cname in add route for %s - %v", backendName, addresses)
+		logger.Errorf("error on get cname in add route for %s - %v", backendName, addresses)
 		return err
 	}
 	if cnames == nil {
@@ -233,7 +235,7 @@ func (r *hipacheRouter) addRoutes(name string, addresses []string) error {
 	}
 	err = conn.RPush(name, addresses...).Err()
 	if err != nil {
-		log.Errorf("error on store in redis in add route for %s - %v", name, addresses)
+		logger.Errorf("error on store in redis in add route for %s - %v", name, addresses)
 		return &router.RouterError{Op: "add", Err: err}
 	}
 	return nil
@@ -13,6 +13,8 @@ import (
 	appTypes "github.com/tsuru/tsuru/types/app"
 )
 
+var logger = log.Subsystem("router")
+
 type RebuildRoutesResult struct {
 	Added   []string
 	Removed []string
@@ -40,7 +42,7 @@ func RebuildRoutes(app RebuildApp, dry bool) (map[string]RebuildRoutesResult, er
 }
 
 func rebuildRoutesInRouter(app RebuildApp, dry bool, appRouter appTypes.AppRouter) (*RebuildRoutesResult, error) {
-	log.Debugf("[rebuild-routes] rebuilding routes for app %q", app.GetName())
+	logger.Debugf("[rebuild-routes] rebuilding routes for app %q", app.GetName())
 	r, err := router.Get(appRouter.Name)
 	if err != nil {
 		return nil, err
@@ -65,13 +67,13 @@ func rebuildRoutesInRouter(app RebuildApp, dry bool, appRouter appTypes.AppRoute
 	if err != nil {
 		return nil, err
 	}
-	log.Debugf("[rebuild-routes] old routes for app %q: %v", app.GetName(), oldRoutes)
+	logger.Debugf("[rebuild-routes] old routes for app %q: %v", app.GetName(), oldRoutes)
 	expectedMap := make(map[string]*url.URL)
 	addresses, err := app.RoutableAddresses()
 	if err != nil {
 		return nil, err
 	}
-	log.Debugf("[rebuild-routes] addresses for app %q: %v", app.GetName(), addresses)
+	logger.Debugf("[rebuild-routes] addresses for app %q: %v", app.GetName(), addresses)
 	for i, addr := range addresses {
 		expectedMap[addr.Host] = &addresses[i]
 	}
@@ -93,7 +95,7 @@ func rebuildRoutesInRouter(app RebuildApp, dry bool, appRouter appTypes.AppRoute
 		result.Removed = append(result.Removed, toRemoveURL.String())
 	}
 	if dry {
-		log.Debugf("[rebuild-routes] nothing to do. DRY mode for app: %q", app.GetName())
+		logger.Debugf("[rebuild-routes] nothing to do. DRY mode for app: %q", app.GetName())
 		return &result, nil
 	}
 	err = r.AddRoutes(app.GetName(), toAdd)
@@ -104,7 +106,7 @@ func rebuildRoutesInRouter(app RebuildApp, dry bool, appRouter appTypes.AppRoute
 	if err != nil {
 		return nil, err
 	}
-	log.Debugf("[rebuild-routes] routes added for app %q: %s", app.GetName(), strings.Join(result.Added, ", "))
-	log.Debugf("[rebuild-routes] routes removed for app %q: %s", app.GetName(), strings.Join(result.Removed, ", "))
+	logger.Debugf("[rebuild-routes] routes added for app %q: %s", app.GetName(), strings.Join(result.Added, ", "))
+	logger.Debugf("[rebuild-routes] routes removed for app %q: %s", app.GetName(), strings.Join(result.Removed, ", "))
 	return &result, nil
 }
@@ -9,7 +9,6 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/tsuru/monsterqueue"
-	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/queue"
 )
 
@@ -52,11 +51,11 @@ func runRoutesRebuildOnce(appName string, lock bool) bool {
 	}
 	a, err := appFinder(appName)
 	if err != nil {
-		log.Errorf("[routes-rebuild-task] error getting app %q: %s", appName, err)
+		logger.Errorf("[routes-rebuild-task] error getting app %q: %s", appName, err)
 		return false
 	}
 	if a == nil {
-		log.Errorf("[routes-rebuild-task] app %q not found, aborting", appName)
+		logger.Errorf("[routes-rebuild-task] app %q not found, aborting", appName)
 		return true
 	}
 	if lock {
@@ -69,7 +68,7 @@ func runRoutesRebuildOnce(appName string, lock bool) bool {
 	}
 	_, err = RebuildRoutes(a, false)
 	if err != nil {
-		log.Errorf("[routes-rebuild-task] error rebuilding app %q: %s", appName, err)
+		logger.Errorf("[routes-rebuild-task] error rebuilding app %q: %s", appName, err)
 		return false
 	}
 	return true
@@ -89,14 +88,14 @@ func routesRebuildOrEnqueueOptionalLock(appName string, lock bool) {
 	}
 	q, err := queue.Queue()
 	if err != nil {
-		log.Errorf("unable to enqueue rebuild routes task: %s", err)
+		logger.Errorf("unable to enqueue rebuild routes task: %s", err)
 		return
 	}
 	_, err = q.Enqueue(routesRebuildTaskName, monsterqueue.JobParams{
 		"appName": appName,
 	})
 	if err != nil {
-		log.Errorf("unable to enqueue rebuild routes task: %s", err)
+		logger.Errorf("unable to enqueue rebuild routes task: %s", err)
 		return
 	}
 }
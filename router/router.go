@@ -35,6 +35,8 @@ var (
 	ErrDefaultRouterNotFound = errors.New("No default router found")
 )
 
+var logger = log.Subsystem("router")
+
 type ErrRouterNotFound struct {
 	Name string
 }
@@ -60,7 +62,7 @@ func Type(name string) (string, string, error) {
 		if name != "hipache" {
 			return "", "", errors.New(msg)
 		}
-		log.Errorf("WARNING: %s, fallback to top level '%s:*' router config", msg, name)
+		logger.Errorf("WARNING: %s, fallback to top level '%s:*' router config", msg, name)
 		return name, name, nil
 	}
 	return routerType, prefix, nil
@@ -0,0 +1,95 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scan
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/exec"
+)
+
+const defaultKlarBin = "klar"
+
+func init() {
+	Register("clair", newClairScanner)
+}
+
+// clairScanner sends images to a clair server using the klar command line
+// client.
+type clairScanner struct {
+	bin     string
+	address string
+}
+
+type klarReport struct {
+	Vulnerabilities map[string][]struct {
+		Name           string
+		Description    string
+		Severity       string
+		FixedBy        string
+		FeatureName    string
+		FeatureVersion string
+	}
+}
+
+func newClairScanner(prefix string) (Scanner, error) {
+	address, err := config.GetString(prefix + ":address")
+	if err != nil {
+		return nil, err
+	}
+	bin, _ := config.GetString(prefix + ":bin")
+	if bin == "" {
+		bin = defaultKlarBin
+	}
+	return &clairScanner{bin: bin, address: address}, nil
+}
+
+func (s *clairScanner) Scan(image string) ([]Vulnerability, error) {
+	envs := append(os.Environ(), "CLAIR_ADDR="+s.address, "CLAIR_OUTPUT=Unknown", "JSON_OUTPUT=true")
+	if user, _ := config.GetString("docker:registry-auth:username"); user != "" {
+		password, _ := config.GetString("docker:registry-auth:password")
+		envs = append(envs, "DOCKER_USER="+user, "DOCKER_PASSWORD="+password)
+	}
+	var stdout, stderr bytes.Buffer
+	err := executor().Execute(exec.ExecuteOptions{
+		Cmd:    s.bin,
+		Args:   []string{image},
+		Envs:   envs,
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
+	// klar exits with a non zero status whenever vulnerabilities are found,
+	// so the command only failed if it didn't output a report.
+	var report klarReport
+	if jsonErr := json.Unmarshal(stdout.Bytes(), &report); jsonErr != nil {
+		if err == nil {
+			err = jsonErr
+		}
+		return nil, errors.Wrapf(err, "klar failed: %s", strings.TrimSpace(stderr.String()))
+	}
+	var vulns []Vulnerability
+	for _, list := range report.Vulnerabilities {
+		for _, v := range list {
+			severity := v.Severity
+			if strings.EqualFold(severity, "Defcon1") {
+				severity = SeverityCritical
+			}
+			vulns = append(vulns, Vulnerability{
+				ID:               v.Name,
+				Package:          v.FeatureName,
+				InstalledVersion: v.FeatureVersion,
+				FixedVersion:     v.FixedBy,
+				Severity:         severity,
+				Title:            v.Description,
+			})
+		}
+	}
+	return vulns, nil
+}
@@ -0,0 +1,265 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package scan provides integration with image vulnerability scanners, used
+// to check the images built during deploys. The scanner is chosen by the
+// scan:driver config entry, when it's not set images are never scanned.
+//
+// What happens when vulnerabilities are found depends on the scan policy of
+// the pool of the app: PolicyWarn only reports them in the deploy output,
+// while PolicyBlock fails deploys of images with critical vulnerabilities.
+package scan
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/storage"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/exec"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	PolicyDisabled = "disabled"
+	PolicyWarn     = "warn"
+	PolicyBlock    = "block"
+
+	SeverityCritical = "CRITICAL"
+)
+
+var execut exec.Executor
+
+func executor() exec.Executor {
+	if execut == nil {
+		execut = exec.OsExecutor{}
+	}
+	return execut
+}
+
+var severityOrder = []string{SeverityCritical, "HIGH", "MEDIUM", "LOW", "UNKNOWN"}
+
+// ErrBlocked is returned when the policy of the pool doesn't allow the
+// deploy of the scanned image.
+type ErrBlocked struct {
+	Image    string
+	Critical int
+}
+
+func (e *ErrBlocked) Error() string {
+	return fmt.Sprintf("deploy blocked by pool scan policy: image %s has %d critical vulnerabilities", e.Image, e.Critical)
+}
+
+// Vulnerability is a vulnerability found in a package installed in the
+// image.
+type Vulnerability struct {
+	ID               string
+	Package          string
+	InstalledVersion string
+	FixedVersion     string
+	Severity         string
+	Title            string
+}
+
+// Scanner checks images for known vulnerabilities.
+type Scanner interface {
+	Scan(image string) ([]Vulnerability, error)
+}
+
+// Result is the outcome of the scan of the image built by a deploy.
+type Result struct {
+	Scanner         string
+	Image           string
+	Policy          string
+	Date            time.Time
+	Blocked         bool
+	Error           string
+	Summary         map[string]int
+	Vulnerabilities []Vulnerability
+}
+
+var scanners = struct {
+	sync.Mutex
+	factories map[string]func(configPrefix string) (Scanner, error)
+}{factories: map[string]func(configPrefix string) (Scanner, error){}}
+
+// Register registers a new scanner driver, which can be later selected in
+// the scan:driver config entry.
+func Register(name string, factory func(configPrefix string) (Scanner, error)) {
+	scanners.Lock()
+	defer scanners.Unlock()
+	scanners.factories[name] = factory
+}
+
+// Enabled returns whether a scanner is configured.
+func Enabled() bool {
+	name, _ := config.GetString("scan:driver")
+	return name != ""
+}
+
+// ValidatePolicy checks whether policy is a valid pool scan policy, an empty
+// policy means the default one, from the scan:default-policy config entry.
+func ValidatePolicy(policy string) error {
+	switch policy {
+	case "", PolicyDisabled, PolicyWarn, PolicyBlock:
+		return nil
+	}
+	return &tsuruErrors.ValidationError{
+		Message: fmt.Sprintf("invalid scan policy %q, valid policies are: %s, %s, %s", policy, PolicyDisabled, PolicyWarn, PolicyBlock),
+	}
+}
+
+func getScanner() (string, Scanner, error) {
+	name, _ := config.GetString("scan:driver")
+	scanners.Lock()
+	factory, ok := scanners.factories[name]
+	scanners.Unlock()
+	if !ok {
+		return "", nil, errors.Errorf("unknown image scanner %q", name)
+	}
+	s, err := factory("scan:" + name)
+	return name, s, err
+}
+
+// ScanImage scans the image with the configured scanner, reporting the
+// vulnerabilities found to w and applying the given pool policy. A nil result
+// is returned when no scanner is configured or the policy disables scanning.
+// Failures of the scanner are only reported when the policy is PolicyWarn.
+func ScanImage(image, policy string, w io.Writer) (*Result, error) {
+	if !Enabled() {
+		return nil, nil
+	}
+	if policy == "" {
+		policy, _ = config.GetString("scan:default-policy")
+		if policy == "" {
+			policy = PolicyWarn
+		}
+	}
+	if policy == PolicyDisabled {
+		return nil, nil
+	}
+	name, scanner, err := getScanner()
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintln(w, "---- Scanning image for vulnerabilities ----")
+	result := &Result{
+		Scanner: name,
+		Image:   image,
+		Policy:  policy,
+		Date:    time.Now().UTC(),
+		Summary: map[string]int{},
+	}
+	vulns, err := scanner.Scan(image)
+	if err != nil {
+		result.Error = err.Error()
+		if policy == PolicyBlock {
+			result.Blocked = true
+			return result, errors.Wrap(err, "unable to scan image")
+		}
+		fmt.Fprintf(w, " ---> WARNING: unable to scan image: %s\n", err)
+		return result, nil
+	}
+	for i := range vulns {
+		vulns[i].Severity = strings.ToUpper(vulns[i].Severity)
+		result.Summary[vulns[i].Severity]++
+	}
+	sort.SliceStable(vulns, func(i, j int) bool {
+		return severityRank(vulns[i].Severity) < severityRank(vulns[j].Severity)
+	})
+	result.Vulnerabilities = vulns
+	fmt.Fprintf(w, " ---> %s\n", summaryLine(result.Summary))
+	critical := result.Summary[SeverityCritical]
+	if critical == 0 {
+		return result, nil
+	}
+	for _, v := range vulns {
+		if v.Severity != SeverityCritical {
+			break
+		}
+		fmt.Fprintf(w, "      %s in %s %s\n", v.ID, v.Package, v.InstalledVersion)
+	}
+	if policy == PolicyBlock {
+		result.Blocked = true
+		return result, &ErrBlocked{Image: image, Critical: critical}
+	}
+	fmt.Fprintf(w, " ---> WARNING: image has %d critical vulnerabilities\n", critical)
+	return result, nil
+}
+
+func severityRank(severity string) int {
+	for i, s := range severityOrder {
+		if s == severity {
+			return i
+		}
+	}
+	return len(severityOrder)
+}
+
+func summaryLine(summary map[string]int) string {
+	var total int
+	var parts []string
+	for _, severity := range severityOrder {
+		if count := summary[severity]; count > 0 {
+			total += count
+			parts = append(parts, fmt.Sprintf("%d %s", count, severity))
+		}
+	}
+	if total == 0 {
+		return "No vulnerabilities found"
+	}
+	return fmt.Sprintf("Found %d vulnerabilities: %s", total, strings.Join(parts, ", "))
+}
+
+func scansColl() (*storage.Collection, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	return conn.Collection("image_scans"), nil
+}
+
+type scanRecord struct {
+	DeployID bson.ObjectId `bson:"_id"`
+	Result   Result
+}
+
+// SaveResult stores the result of the scan of the image built by the deploy
+// with the given id.
+func SaveResult(deployID bson.ObjectId, result *Result) error {
+	coll, err := scansColl()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	_, err = coll.UpsertId(deployID, scanRecord{DeployID: deployID, Result: *result})
+	return err
+}
+
+// GetResult returns the result of the scan of the image built by the deploy
+// with the given id, or nil if the image wasn't scanned.
+func GetResult(deployID bson.ObjectId) (*Result, error) {
+	coll, err := scansColl()
+	if err != nil {
+		return nil, err
+	}
+	defer coll.Close()
+	var record scanRecord
+	err = coll.FindId(deployID).One(&record)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &record.Result, nil
+}
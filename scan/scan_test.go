@@ -0,0 +1,251 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scan
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/dbtest"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/exec/exectest"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type S struct {
+	scanner *fakeScanner
+}
+
+var _ = check.Suite(&S{})
+
+type fakeScanner struct {
+	images []string
+	vulns  []Vulnerability
+	err    error
+}
+
+func (f *fakeScanner) Scan(image string) ([]Vulnerability, error) {
+	f.images = append(f.images, image)
+	return f.vulns, f.err
+}
+
+func (s *S) SetUpSuite(c *check.C) {
+	config.Set("database:url", "127.0.0.1:27017")
+	config.Set("database:name", "tsuru_scan_tests")
+}
+
+func (s *S) SetUpTest(c *check.C) {
+	s.scanner = &fakeScanner{}
+	Register("fake", func(prefix string) (Scanner, error) {
+		c.Assert(prefix, check.Equals, "scan:fake")
+		return s.scanner, nil
+	})
+	config.Set("scan:driver", "fake")
+}
+
+func (s *S) TearDownTest(c *check.C) {
+	config.Unset("scan")
+	config.Unset("docker:registry-auth")
+	execut = nil
+}
+
+func (s *S) TestScanImageNotEnabled(c *check.C) {
+	config.Unset("scan:driver")
+	var buf bytes.Buffer
+	result, err := ScanImage("tsuru/app-myapp:v1", PolicyBlock, &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.IsNil)
+	c.Assert(s.scanner.images, check.IsNil)
+	c.Assert(buf.String(), check.Equals, "")
+}
+
+func (s *S) TestScanImagePolicyDisabled(c *check.C) {
+	var buf bytes.Buffer
+	result, err := ScanImage("tsuru/app-myapp:v1", PolicyDisabled, &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.IsNil)
+	c.Assert(s.scanner.images, check.IsNil)
+	config.Set("scan:default-policy", PolicyDisabled)
+	result, err = ScanImage("tsuru/app-myapp:v1", "", &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.IsNil)
+	c.Assert(s.scanner.images, check.IsNil)
+}
+
+func (s *S) TestScanImageWarn(c *check.C) {
+	s.scanner.vulns = []Vulnerability{
+		{ID: "CVE-2018-2", Package: "zlib", InstalledVersion: "1.2", Severity: "low"},
+		{ID: "CVE-2018-1", Package: "openssl", InstalledVersion: "1.0.1", Severity: "critical"},
+		{ID: "CVE-2018-3", Package: "bash", InstalledVersion: "4.3", Severity: "HIGH"},
+	}
+	var buf bytes.Buffer
+	result, err := ScanImage("tsuru/app-myapp:v1", "", &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.scanner.images, check.DeepEquals, []string{"tsuru/app-myapp:v1"})
+	c.Assert(result.Scanner, check.Equals, "fake")
+	c.Assert(result.Policy, check.Equals, PolicyWarn)
+	c.Assert(result.Blocked, check.Equals, false)
+	c.Assert(result.Summary, check.DeepEquals, map[string]int{"CRITICAL": 1, "HIGH": 1, "LOW": 1})
+	c.Assert(result.Vulnerabilities, check.DeepEquals, []Vulnerability{
+		{ID: "CVE-2018-1", Package: "openssl", InstalledVersion: "1.0.1", Severity: "CRITICAL"},
+		{ID: "CVE-2018-3", Package: "bash", InstalledVersion: "4.3", Severity: "HIGH"},
+		{ID: "CVE-2018-2", Package: "zlib", InstalledVersion: "1.2", Severity: "LOW"},
+	})
+	c.Assert(buf.String(), check.Equals, `---- Scanning image for vulnerabilities ----
+ ---> Found 3 vulnerabilities: 1 CRITICAL, 1 HIGH, 1 LOW
+      CVE-2018-1 in openssl 1.0.1
+ ---> WARNING: image has 1 critical vulnerabilities
+`)
+}
+
+func (s *S) TestScanImageBlock(c *check.C) {
+	s.scanner.vulns = []Vulnerability{
+		{ID: "CVE-2018-1", Package: "openssl", InstalledVersion: "1.0.1", Severity: "CRITICAL"},
+	}
+	var buf bytes.Buffer
+	result, err := ScanImage("tsuru/app-myapp:v1", PolicyBlock, &buf)
+	c.Assert(err, check.DeepEquals, &ErrBlocked{Image: "tsuru/app-myapp:v1", Critical: 1})
+	c.Assert(err, check.ErrorMatches, "deploy blocked by pool scan policy: image tsuru/app-myapp:v1 has 1 critical vulnerabilities")
+	c.Assert(result.Blocked, check.Equals, true)
+}
+
+func (s *S) TestScanImageBlockWithoutCritical(c *check.C) {
+	s.scanner.vulns = []Vulnerability{
+		{ID: "CVE-2018-3", Package: "bash", InstalledVersion: "4.3", Severity: "HIGH"},
+	}
+	var buf bytes.Buffer
+	result, err := ScanImage("tsuru/app-myapp:v1", PolicyBlock, &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Blocked, check.Equals, false)
+	c.Assert(buf.String(), check.Equals, `---- Scanning image for vulnerabilities ----
+ ---> Found 1 vulnerabilities: 1 HIGH
+`)
+}
+
+func (s *S) TestScanImageScannerFailure(c *check.C) {
+	s.scanner.err = errors.New("connection refused")
+	var buf bytes.Buffer
+	result, err := ScanImage("tsuru/app-myapp:v1", PolicyWarn, &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Error, check.Equals, "connection refused")
+	c.Assert(buf.String(), check.Equals, `---- Scanning image for vulnerabilities ----
+ ---> WARNING: unable to scan image: connection refused
+`)
+	result, err = ScanImage("tsuru/app-myapp:v1", PolicyBlock, &buf)
+	c.Assert(err, check.ErrorMatches, "unable to scan image: connection refused")
+	c.Assert(result.Blocked, check.Equals, true)
+}
+
+func (s *S) TestScanImageUnknownDriver(c *check.C) {
+	config.Set("scan:driver", "unknown")
+	_, err := ScanImage("tsuru/app-myapp:v1", PolicyWarn, &bytes.Buffer{})
+	c.Assert(err, check.ErrorMatches, `unknown image scanner "unknown"`)
+}
+
+func (s *S) TestValidatePolicy(c *check.C) {
+	for _, p := range []string{"", PolicyDisabled, PolicyWarn, PolicyBlock} {
+		c.Assert(ValidatePolicy(p), check.IsNil)
+	}
+	err := ValidatePolicy("deny")
+	c.Assert(err, check.FitsTypeOf, &tsuruErrors.ValidationError{})
+	c.Assert(err, check.ErrorMatches, `invalid scan policy "deny", valid policies are: disabled, warn, block`)
+}
+
+func (s *S) TestTrivyScanner(c *check.C) {
+	fexec := &exectest.FakeExecutor{
+		Output: map[string][][]byte{
+			"*": {[]byte(`{"Results":[{"Target":"debian","Vulnerabilities":[
+{"VulnerabilityID":"CVE-2018-1","PkgName":"openssl","InstalledVersion":"1.0.1","FixedVersion":"1.0.2","Severity":"CRITICAL","Title":"heartbleed"}]},
+{"Target":"app/requirements.txt","Vulnerabilities":null}]}`)},
+		},
+	}
+	execut = fexec
+	config.Set("scan:trivy:server", "http://trivy:4954")
+	scanner, err := newTrivyScanner("scan:trivy")
+	c.Assert(err, check.IsNil)
+	vulns, err := scanner.Scan("tsuru/app-myapp:v1")
+	c.Assert(err, check.IsNil)
+	c.Assert(vulns, check.DeepEquals, []Vulnerability{
+		{ID: "CVE-2018-1", Package: "openssl", InstalledVersion: "1.0.1", FixedVersion: "1.0.2", Severity: "CRITICAL", Title: "heartbleed"},
+	})
+	args := []string{"image", "--quiet", "--format", "json", "--server", "http://trivy:4954", "tsuru/app-myapp:v1"}
+	c.Assert(fexec.ExecutedCmd("trivy", args), check.Equals, true)
+}
+
+func (s *S) TestTrivyScannerRegistryAuth(c *check.C) {
+	fexec := &exectest.FakeExecutor{Output: map[string][][]byte{"*": {[]byte(`{}`)}}}
+	execut = fexec
+	config.Set("scan:trivy:bin", "/usr/local/bin/trivy")
+	config.Set("docker:registry-auth:username", "user")
+	config.Set("docker:registry-auth:password", "pass")
+	scanner, err := newTrivyScanner("scan:trivy")
+	c.Assert(err, check.IsNil)
+	vulns, err := scanner.Scan("tsuru/app-myapp:v1")
+	c.Assert(err, check.IsNil)
+	c.Assert(vulns, check.IsNil)
+	cmds := fexec.GetCommands("/usr/local/bin/trivy")
+	c.Assert(cmds, check.HasLen, 1)
+	envs := cmds[0].GetEnvs()
+	c.Assert(envs[len(envs)-2:], check.DeepEquals, []string{"TRIVY_USERNAME=user", "TRIVY_PASSWORD=pass"})
+}
+
+func (s *S) TestClairScanner(c *check.C) {
+	fexec := &exectest.FakeExecutor{
+		Output: map[string][][]byte{
+			"*": {[]byte(`{"LayerCount":3,"Vulnerabilities":{"Defcon1":[
+{"Name":"CVE-2018-1","Description":"heartbleed","Severity":"Defcon1","FixedBy":"1.0.2","FeatureName":"openssl","FeatureVersion":"1.0.1"}]}}`)},
+		},
+	}
+	execut = fexec
+	config.Set("scan:clair:address", "http://clair:6060")
+	scanner, err := newClairScanner("scan:clair")
+	c.Assert(err, check.IsNil)
+	vulns, err := scanner.Scan("tsuru/app-myapp:v1")
+	c.Assert(err, check.IsNil)
+	c.Assert(vulns, check.DeepEquals, []Vulnerability{
+		{ID: "CVE-2018-1", Package: "openssl", InstalledVersion: "1.0.1", FixedVersion: "1.0.2", Severity: "CRITICAL", Title: "heartbleed"},
+	})
+	cmds := fexec.GetCommands("klar")
+	c.Assert(cmds, check.HasLen, 1)
+	c.Assert(cmds[0].GetArgs(), check.DeepEquals, []string{"tsuru/app-myapp:v1"})
+	envs := cmds[0].GetEnvs()
+	c.Assert(envs[len(envs)-3:], check.DeepEquals, []string{"CLAIR_ADDR=http://clair:6060", "CLAIR_OUTPUT=Unknown", "JSON_OUTPUT=true"})
+}
+
+func (s *S) TestClairScannerRequiresAddress(c *check.C) {
+	_, err := newClairScanner("scan:clair")
+	c.Assert(err, check.NotNil)
+}
+
+func (s *S) TestSaveAndGetResult(c *check.C) {
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	defer dbtest.ClearAllCollections(conn.Apps().Database)
+	id := bson.NewObjectId()
+	result, err := GetResult(id)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.IsNil)
+	expected := Result{
+		Scanner: "fake",
+		Image:   "tsuru/app-myapp:v1",
+		Policy:  PolicyWarn,
+		Summary: map[string]int{"HIGH": 1},
+		Vulnerabilities: []Vulnerability{
+			{ID: "CVE-2018-3", Package: "bash", InstalledVersion: "4.3", Severity: "HIGH"},
+		},
+	}
+	err = SaveResult(id, &expected)
+	c.Assert(err, check.IsNil)
+	result, err = GetResult(id)
+	c.Assert(err, check.IsNil)
+	c.Assert(*result, check.DeepEquals, expected)
+}
@@ -0,0 +1,95 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scan
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/exec"
+)
+
+const defaultTrivyBin = "trivy"
+
+func init() {
+	Register("trivy", newTrivyScanner)
+}
+
+// trivyScanner runs the trivy command line client, optionally talking to a
+// trivy server, which keeps the vulnerability database.
+type trivyScanner struct {
+	bin    string
+	server string
+}
+
+type trivyReport struct {
+	Results []struct {
+		Target          string
+		Vulnerabilities []struct {
+			VulnerabilityID  string
+			PkgName          string
+			InstalledVersion string
+			FixedVersion     string
+			Severity         string
+			Title            string
+		}
+	}
+}
+
+func newTrivyScanner(prefix string) (Scanner, error) {
+	bin, _ := config.GetString(prefix + ":bin")
+	if bin == "" {
+		bin = defaultTrivyBin
+	}
+	server, _ := config.GetString(prefix + ":server")
+	return &trivyScanner{bin: bin, server: server}, nil
+}
+
+func (s *trivyScanner) Scan(image string) ([]Vulnerability, error) {
+	args := []string{"image", "--quiet", "--format", "json"}
+	if s.server != "" {
+		args = append(args, "--server", s.server)
+	}
+	args = append(args, image)
+	var envs []string
+	if user, _ := config.GetString("docker:registry-auth:username"); user != "" {
+		password, _ := config.GetString("docker:registry-auth:password")
+		envs = append(os.Environ(), "TRIVY_USERNAME="+user, "TRIVY_PASSWORD="+password)
+	}
+	var stdout, stderr bytes.Buffer
+	err := executor().Execute(exec.ExecuteOptions{
+		Cmd:    s.bin,
+		Args:   args,
+		Envs:   envs,
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "trivy failed: %s", strings.TrimSpace(stderr.String()))
+	}
+	var report trivyReport
+	err = json.Unmarshal(stdout.Bytes(), &report)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse trivy report")
+	}
+	var vulns []Vulnerability
+	for _, r := range report.Results {
+		for _, v := range r.Vulnerabilities {
+			vulns = append(vulns, Vulnerability{
+				ID:               v.VulnerabilityID,
+				Package:          v.PkgName,
+				InstalledVersion: v.InstalledVersion,
+				FixedVersion:     v.FixedVersion,
+				Severity:         v.Severity,
+				Title:            v.Title,
+			})
+		}
+	}
+	return vulns, nil
+}
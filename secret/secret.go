@@ -0,0 +1,157 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package secret provides encryption of sensitive values stored by tsuru,
+// like secret environment variables of apps. The cipher used is chosen by the
+// secrets:provider config entry, the builtin "config" provider uses
+// AES-GCM with the key set in the secrets:key config entry. Other providers,
+// like key management services, may be registered with Register.
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+)
+
+const defaultProvider = "config"
+
+var (
+	ErrKeyNotConfigured = errors.New("secrets:key config entry must be set to store secrets")
+	ErrInvalidKey       = errors.New("secrets:key must be a base64 encoded key with 16, 24 or 32 bytes")
+	ErrInvalidValue     = errors.New("invalid encrypted value")
+)
+
+// Cipher encrypts and decrypts secret values.
+type Cipher interface {
+	Encrypt(plaintext []byte) ([]byte, error)
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+var providers = struct {
+	sync.Mutex
+	factories map[string]func() (Cipher, error)
+}{factories: map[string]func() (Cipher, error){}}
+
+func init() {
+	Register(defaultProvider, newConfigCipher)
+}
+
+// Register registers a new cipher provider, which can be later selected in
+// the secrets:provider config entry.
+func Register(name string, factory func() (Cipher, error)) {
+	providers.Lock()
+	defer providers.Unlock()
+	providers.factories[name] = factory
+}
+
+func getCipher() (Cipher, error) {
+	name, _ := config.GetString("secrets:provider")
+	if name == "" {
+		name = defaultProvider
+	}
+	providers.Lock()
+	factory, ok := providers.factories[name]
+	providers.Unlock()
+	if !ok {
+		return nil, errors.Errorf("unknown secrets provider %q", name)
+	}
+	return factory()
+}
+
+// Encrypt encrypts value with the configured cipher, returning the result
+// encoded in base64.
+func Encrypt(value string) (string, error) {
+	c, err := getCipher()
+	if err != nil {
+		return "", err
+	}
+	data, err := c.Encrypt([]byte(value))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// Decrypt decrypts a value returned by Encrypt.
+func Decrypt(value string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", ErrInvalidValue
+	}
+	c, err := getCipher()
+	if err != nil {
+		return "", err
+	}
+	data, err = c.Decrypt(data)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+type aesCipher struct {
+	aead cipher.AEAD
+}
+
+func newConfigCipher() (Cipher, error) {
+	key, err := configKey()
+	if err != nil {
+		return nil, err
+	}
+	return NewAESCipher(key)
+}
+
+func configKey() ([]byte, error) {
+	encodedKey, _ := config.GetString("secrets:key")
+	if encodedKey == "" {
+		return nil, ErrKeyNotConfigured
+	}
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+	return key, nil
+}
+
+// NewAESCipher returns a cipher using AES-GCM with the given key, which must
+// have 16, 24 or 32 bytes. The nonce is prepended to encrypted values.
+func NewAESCipher(key []byte) (Cipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &aesCipher{aead: aead}, nil
+}
+
+func (c *aesCipher) Encrypt(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	_, err := io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to generate nonce")
+	}
+	return c.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (c *aesCipher) Decrypt(ciphertext []byte) ([]byte, error) {
+	size := c.aead.NonceSize()
+	if len(ciphertext) < size {
+		return nil, ErrInvalidValue
+	}
+	plaintext, err := c.aead.Open(nil, ciphertext[:size], ciphertext[size:], nil)
+	if err != nil {
+		return nil, ErrInvalidValue
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type S struct{}
+
+var _ = check.Suite(&S{})
+
+var testKey = base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+
+func (s *S) SetUpTest(c *check.C) {
+	config.Set("secrets:key", testKey)
+}
+
+func (s *S) TearDownTest(c *check.C) {
+	config.Unset("secrets:key")
+	config.Unset("secrets:provider")
+}
+
+func (s *S) TestEncryptDecrypt(c *check.C) {
+	encrypted, err := Encrypt("my password")
+	c.Assert(err, check.IsNil)
+	c.Assert(encrypted, check.Not(check.Equals), "my password")
+	other, err := Encrypt("my password")
+	c.Assert(err, check.IsNil)
+	c.Assert(other, check.Not(check.Equals), encrypted)
+	value, err := Decrypt(encrypted)
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, "my password")
+}
+
+func (s *S) TestDecryptWithOtherKey(c *check.C) {
+	encrypted, err := Encrypt("my password")
+	c.Assert(err, check.IsNil)
+	config.Set("secrets:key", base64.StdEncoding.EncodeToString([]byte("fedcba9876543210fedcba9876543210")))
+	_, err = Decrypt(encrypted)
+	c.Assert(err, check.Equals, ErrInvalidValue)
+}
+
+func (s *S) TestDecryptInvalidValue(c *check.C) {
+	_, err := Decrypt("not base64!")
+	c.Assert(err, check.Equals, ErrInvalidValue)
+	_, err = Decrypt(base64.StdEncoding.EncodeToString([]byte("short")))
+	c.Assert(err, check.Equals, ErrInvalidValue)
+}
+
+func (s *S) TestEncryptWithoutKey(c *check.C) {
+	config.Unset("secrets:key")
+	_, err := Encrypt("my password")
+	c.Assert(err, check.Equals, ErrKeyNotConfigured)
+	config.Set("secrets:key", base64.StdEncoding.EncodeToString([]byte("short")))
+	_, err = Encrypt("my password")
+	c.Assert(err, check.Equals, ErrInvalidKey)
+}
+
+type reverseCipher struct{}
+
+func (reverseCipher) Encrypt(data []byte) ([]byte, error) { return reverse(data), nil }
+func (reverseCipher) Decrypt(data []byte) ([]byte, error) { return reverse(data), nil }
+
+func reverse(data []byte) []byte {
+	result := make([]byte, len(data))
+	for i := range data {
+		result[len(data)-1-i] = data[i]
+	}
+	return result
+}
+
+func (s *S) TestRegisteredProvider(c *check.C) {
+	Register("reverse", func() (Cipher, error) { return reverseCipher{}, nil })
+	config.Set("secrets:provider", "reverse")
+	encrypted, err := Encrypt("abc")
+	c.Assert(err, check.IsNil)
+	c.Assert(encrypted, check.Equals, base64.StdEncoding.EncodeToString([]byte("cba")))
+	value, err := Decrypt(encrypted)
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, "abc")
+	config.Set("secrets:provider", "unknown")
+	_, err = Encrypt("abc")
+	c.Assert(err, check.ErrorMatches, `unknown secrets provider "unknown"`)
+}
@@ -0,0 +1,99 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"github.com/tsuru/config"
+)
+
+const (
+	// sealedPrefix marks values encrypted by Seal, so values stored before
+	// encryption was enabled can still be read.
+	sealedPrefix = "enc:v1:"
+	// hashedPrefix marks values hashed by Hash.
+	hashedPrefix = "sha256:"
+	// fingerprintPrefix marks values returned by Fingerprint.
+	fingerprintPrefix = "hmac-sha256:"
+)
+
+// StorageEncryptionEnabled returns whether sensitive fields are encrypted
+// before being stored, as set in the secrets:encrypt-storage config entry.
+func StorageEncryptionEnabled() bool {
+	enabled, _ := config.GetBool("secrets:encrypt-storage")
+	return enabled
+}
+
+// IsSealed returns whether the value was encrypted by Seal.
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, sealedPrefix)
+}
+
+// Seal encrypts a value about to be stored, when storage encryption is
+// enabled. Empty and already encrypted values are returned unchanged.
+func Seal(value string) (string, error) {
+	if value == "" || IsSealed(value) || !StorageEncryptionEnabled() {
+		return value, nil
+	}
+	encrypted, err := Encrypt(value)
+	if err != nil {
+		return "", err
+	}
+	return sealedPrefix + encrypted, nil
+}
+
+// Open decrypts a value encrypted by Seal. Values stored in plain text are
+// returned unchanged, regardless of storage encryption being enabled.
+func Open(value string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+	return Decrypt(strings.TrimPrefix(value, sealedPrefix))
+}
+
+// Hash returns the form in which a value that is only looked up, and never
+// read back, like a session token, is stored. When storage encryption is
+// enabled the value is replaced by its SHA-256 digest, otherwise it's
+// returned unchanged.
+func Hash(value string) string {
+	if value == "" || strings.HasPrefix(value, hashedPrefix) || !StorageEncryptionEnabled() {
+		return value
+	}
+	return digest(value)
+}
+
+// LookupValues returns the forms in which a value stored with Hash may be
+// found: in plain text, when stored before storage encryption was enabled,
+// and hashed.
+func LookupValues(value string) []string {
+	return []string{value, digest(value)}
+}
+
+func digest(value string) string {
+	return hashedPrefix + fmt.Sprintf("%x", sha256.Sum256([]byte(value)))
+}
+
+// Fingerprint returns an HMAC-SHA256 of the value keyed with the secrets:key
+// config entry, so values can be compared without storing them, or digests
+// that could be brute-forced, where other users may read them.
+// ErrKeyNotConfigured is returned when there's no key.
+func Fingerprint(value string) (string, error) {
+	key, err := configKey()
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(value))
+	return fingerprintPrefix + fmt.Sprintf("%x", mac.Sum(nil)), nil
+}
+
+// IsFingerprint returns whether the value was returned by Fingerprint.
+func IsFingerprint(value string) bool {
+	return strings.HasPrefix(value, fingerprintPrefix)
+}
@@ -0,0 +1,93 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package secret
+
+import (
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestSealDisabled(c *check.C) {
+	value, err := Seal("my password")
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, "my password")
+}
+
+func (s *S) TestSealOpen(c *check.C) {
+	config.Set("secrets:encrypt-storage", true)
+	defer config.Unset("secrets:encrypt-storage")
+	sealed, err := Seal("my password")
+	c.Assert(err, check.IsNil)
+	c.Assert(sealed, check.Matches, `enc:v1:.+`)
+	c.Assert(IsSealed(sealed), check.Equals, true)
+	again, err := Seal(sealed)
+	c.Assert(err, check.IsNil)
+	c.Assert(again, check.Equals, sealed)
+	value, err := Open(sealed)
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, "my password")
+	empty, err := Seal("")
+	c.Assert(err, check.IsNil)
+	c.Assert(empty, check.Equals, "")
+}
+
+func (s *S) TestOpenPlainValue(c *check.C) {
+	value, err := Open("my password")
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, "my password")
+}
+
+func (s *S) TestOpenAfterDisabling(c *check.C) {
+	config.Set("secrets:encrypt-storage", true)
+	sealed, err := Seal("my password")
+	config.Unset("secrets:encrypt-storage")
+	c.Assert(err, check.IsNil)
+	value, err := Open(sealed)
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, "my password")
+}
+
+func (s *S) TestSealWithoutKey(c *check.C) {
+	config.Set("secrets:encrypt-storage", true)
+	defer config.Unset("secrets:encrypt-storage")
+	config.Unset("secrets:key")
+	_, err := Seal("my password")
+	c.Assert(err, check.Equals, ErrKeyNotConfigured)
+}
+
+func (s *S) TestHash(c *check.C) {
+	c.Assert(Hash("my token"), check.Equals, "my token")
+	config.Set("secrets:encrypt-storage", true)
+	defer config.Unset("secrets:encrypt-storage")
+	hashed := Hash("my token")
+	c.Assert(hashed, check.Matches, `sha256:[0-9a-f]{64}`)
+	c.Assert(Hash(hashed), check.Equals, hashed)
+	c.Assert(Hash(""), check.Equals, "")
+	c.Assert(LookupValues("my token"), check.DeepEquals, []string{"my token", hashed})
+}
+
+func (s *S) TestFingerprint(c *check.C) {
+	fp, err := Fingerprint("my password")
+	c.Assert(err, check.IsNil)
+	c.Assert(fp, check.Matches, `hmac-sha256:[0-9a-f]{64}`)
+	c.Assert(IsFingerprint(fp), check.Equals, true)
+	again, err := Fingerprint("my password")
+	c.Assert(err, check.IsNil)
+	c.Assert(again, check.Equals, fp)
+	other, err := Fingerprint("other password")
+	c.Assert(err, check.IsNil)
+	c.Assert(other, check.Not(check.Equals), fp)
+	c.Assert(fp, check.Not(check.Equals), digest("my password"))
+	config.Set("secrets:key", "MDEyMzQ1Njc4OWFiY2RlZg==")
+	otherKey, err := Fingerprint("my password")
+	c.Assert(err, check.IsNil)
+	c.Assert(otherKey, check.Not(check.Equals), fp)
+}
+
+func (s *S) TestFingerprintWithoutKey(c *check.C) {
+	config.Unset("secrets:key")
+	_, err := Fingerprint("my password")
+	c.Assert(err, check.Equals, ErrKeyNotConfigured)
+}
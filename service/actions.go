@@ -6,7 +6,6 @@ package service
 
 import (
 	"io"
-	"sort"
 	"sync"
 
 	"github.com/pkg/errors"
@@ -75,6 +74,51 @@ var notifyCreateServiceInstance = action.Action{
 	MinParams: 3,
 }
 
+// notifyCloneServiceInstance is an action that calls the service endpoint
+// to create a service instance as a copy of another one.
+//
+// The first argument in the context must be a Service.
+// The second argument in the context must be a ServiceInstance.
+// The third argument in the context must be the user email.
+// The fourth argument in the context must be a request ID.
+// The fifth argument in the context must be the source ServiceInstance.
+var notifyCloneServiceInstance = action.Action{
+	Name: "notify-clone-service-instance",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		service, ok := ctx.Params[0].(Service)
+		if !ok {
+			return nil, errors.New("First parameter must be a Service.")
+		}
+		endpoint, err := service.getClient("production")
+		if err != nil {
+			return nil, err
+		}
+		instance, ok := ctx.Params[1].(ServiceInstance)
+		if !ok {
+			return nil, errors.New("Second parameter must be a ServiceInstance.")
+		}
+		user, ok := ctx.Params[2].(string)
+		if !ok {
+			return nil, errors.New("Third parameter must be a string.")
+		}
+		requestID, ok := ctx.Params[3].(string)
+		if !ok {
+			return nil, errors.New("RequestID should be a string.")
+		}
+		source, ok := ctx.Params[4].(ServiceInstance)
+		if !ok {
+			return nil, errors.New("Fifth parameter must be a ServiceInstance.")
+		}
+		err = endpoint.Clone(&source, &instance, user, requestID)
+		if err != nil {
+			return nil, err
+		}
+		return instance, nil
+	},
+	Backward:  notifyCreateServiceInstance.Backward,
+	MinParams: 5,
+}
+
 // createServiceInstance is an action that inserts an instance in the database.
 //
 // The second argument in the context must be a Service Instance.
@@ -90,7 +134,11 @@ var createServiceInstance = action.Action{
 			return nil, err
 		}
 		defer conn.Close()
-		return nil, conn.ServiceInstances().Insert(&instance)
+		err = conn.ServiceInstances().Insert(&instance)
+		if mgo.IsDup(err) {
+			return nil, ErrInstanceNameAlreadyExists
+		}
+		return nil, err
 	},
 	Backward: func(ctx action.BWContext) {
 		instance, ok := ctx.Params[1].(ServiceInstance)
@@ -251,7 +299,16 @@ var bindAppEndpointAction = &action.Action{
 		if err != nil {
 			return nil, err
 		}
-		return endpoint.BindApp(args.serviceInstance, args.app)
+		envs, err := endpoint.BindApp(args.serviceInstance, args.app)
+		if _, ok := err.(*InvalidEnvsResponseError); ok {
+			if unbindErr := endpoint.UnbindApp(args.serviceInstance, args.app); unbindErr != nil {
+				log.Errorf("[bind-app-endpoint] failed to unbind app after invalid response: %s", unbindErr)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		return envs, nil
 	},
 	Backward: func(ctx action.BWContext) {
 		args, _ := ctx.Params[0].(*bindPipelineArgs)
@@ -275,22 +332,7 @@ var setBoundEnvsAction = &action.Action{
 		if args == nil {
 			return nil, errors.New("invalid arguments for pipeline, expected *bindPipelineArgs.")
 		}
-		envMap := ctx.Previous.(map[string]string)
-		envs := make([]bind.ServiceEnvVar, 0, len(envMap))
-		for k, v := range envMap {
-			envs = append(envs, bind.ServiceEnvVar{
-				ServiceName:  args.serviceInstance.ServiceName,
-				InstanceName: args.serviceInstance.Name,
-				EnvVar: bind.EnvVar{
-					Public: false,
-					Name:   k,
-					Value:  v,
-				},
-			})
-		}
-		sort.Slice(envs, func(i, j int) bool {
-			return envs[i].Name < envs[j].Name
-		})
+		envs := args.serviceInstance.boundEnvs(ctx.Previous.(map[string]string))
 		addArgs := bind.AddInstanceArgs{
 			Envs:          envs,
 			ShouldRestart: args.shouldRestart,
@@ -128,6 +128,18 @@ func (s *S) TestCreateServiceInstanceForward(c *check.C) {
 	c.Assert(err, check.IsNil)
 }
 
+func (s *S) TestCreateServiceInstanceForwardDuplicated(c *check.C) {
+	srv := Service{Name: "mongodb"}
+	instance := ServiceInstance{Name: "mysql", ServiceName: "mongodb"}
+	err := s.conn.ServiceInstances().Insert(&instance)
+	c.Assert(err, check.IsNil)
+	ctx := action.FWContext{
+		Params: []interface{}{srv, instance},
+	}
+	_, err = createServiceInstance.Forward(ctx)
+	c.Assert(err, check.Equals, ErrInstanceNameAlreadyExists)
+}
+
 func (s *S) TestCreateServiceInstanceForwardParams(c *check.C) {
 	ctx := action.FWContext{Params: []interface{}{"", ""}}
 	_, err := createServiceInstance.Forward(ctx)
@@ -366,6 +378,35 @@ func (s *S) TestBindAppEndpointActionForwardReturnsEnvVars(c *check.C) {
 	})
 }
 
+func (s *S) TestBindAppEndpointActionForwardInvalidEnvsUnbinds(c *check.C) {
+	var unbindCalled bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			unbindCalled = true
+			return
+		}
+		w.Write([]byte(`{"DATABASE_USER":{"name":"root"}}`))
+	}))
+	defer ts.Close()
+	service := Service{Name: "mysql", Endpoint: map[string]string{"production": ts.URL}, Password: "s3cr3t", OwnerTeams: []string{s.team.Name}}
+	err := service.Create()
+	c.Assert(err, check.IsNil)
+	si := ServiceInstance{
+		Name:        "my-mysql",
+		ServiceName: "mysql",
+		Teams:       []string{s.team.Name},
+	}
+	err = s.conn.ServiceInstances().Insert(si)
+	c.Assert(err, check.IsNil)
+	a := provisiontest.NewFakeApp("myapp", "static", 1)
+	ctx := action.FWContext{
+		Params: []interface{}{&bindPipelineArgs{app: a, serviceInstance: &si}},
+	}
+	_, err = bindAppEndpointAction.Forward(ctx)
+	c.Assert(err, check.FitsTypeOf, &InvalidEnvsResponseError{})
+	c.Assert(unbindCalled, check.Equals, true)
+}
+
 func (s *S) TestBindAppEndpointActionBackward(c *check.C) {
 	var called bool
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -7,6 +7,7 @@ package service_test
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -18,6 +19,7 @@ import (
 	"github.com/tsuru/tsuru/auth/native"
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/db/dbtest"
+	"github.com/tsuru/tsuru/db/dbtest/fixture"
 	"github.com/tsuru/tsuru/provision/pool"
 	"github.com/tsuru/tsuru/provision/provisiontest"
 	"github.com/tsuru/tsuru/router/routertest"
@@ -30,9 +32,10 @@ import (
 )
 
 type BindSuite struct {
-	conn *db.Storage
-	user auth.User
-	team authTypes.Team
+	conn     *db.Storage
+	user     auth.User
+	team     authTypes.Team
+	snapshot *fixture.Snapshot
 }
 
 var _ = check.Suite(&BindSuite{})
@@ -56,17 +59,23 @@ func (s *BindSuite) SetUpTest(c *check.C) {
 	provisiontest.ProvisionerInstance.Reset()
 	routertest.FakeRouter.Reset()
 	dbtest.ClearAllCollections(s.conn.Apps().Database)
-	s.user = auth.User{Email: "sad-but-true@metallica.com"}
-	err := s.user.Create()
-	c.Assert(err, check.IsNil)
-	s.team = authTypes.Team{Name: "metallica"}
-	err = auth.TeamService().Insert(s.team)
+	d := &fixture.Dataset{
+		Users: []auth.User{{Email: "sad-but-true@metallica.com"}},
+		Teams: []authTypes.Team{{Name: "metallica"}},
+	}
+	var err error
+	s.snapshot, err = fixture.Load(d)
 	c.Assert(err, check.IsNil)
+	s.user, s.team = d.Users[0], d.Teams[0]
 	opts := pool.AddPoolOptions{Name: "pool1", Default: true, Provisioner: "fake"}
 	err = pool.AddPool(opts)
 	c.Assert(err, check.IsNil)
 }
 
+func (s *BindSuite) TearDownTest(c *check.C) {
+	c.Assert(s.snapshot.Restore(), check.IsNil)
+}
+
 func (s *BindSuite) TearDownSuite(c *check.C) {
 	s.conn.Apps().Database.DropDatabase()
 	s.conn.Close()
@@ -357,6 +366,99 @@ func (s *BindSuite) TestUnbindMultiUnits(c *check.C) {
 	c.Assert(envs, check.DeepEquals, map[string]bind.EnvVar{"TSURU_SERVICES": {Name: "TSURU_SERVICES", Value: "{}"}})
 }
 
+func (s *BindSuite) TestRotateCredentials(c *check.C) {
+	var rotated int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/resources/my-mysql/bind-app/rotate" {
+			atomic.AddInt32(&rotated, 1)
+			w.Write([]byte(`{"DATABASE_USER":"root","DATABASE_PASSWORD":"n3w"}`))
+			return
+		}
+		w.Write([]byte(`{"DATABASE_USER":"root","DATABASE_PASSWORD":"s3cr3t"}`))
+	}))
+	defer ts.Close()
+	srvc := service.Service{Name: "mysql", Endpoint: map[string]string{"production": ts.URL}, Password: "s3cr3t", OwnerTeams: []string{s.team.Name}}
+	err := srvc.Create()
+	c.Assert(err, check.IsNil)
+	instance := service.ServiceInstance{Name: "my-mysql", ServiceName: "mysql", Teams: []string{s.team.Name}}
+	err = s.conn.ServiceInstances().Insert(instance)
+	c.Assert(err, check.IsNil)
+	a := &app.App{Name: "painkiller", Platform: "python", TeamOwner: s.team.Name}
+	err = app.CreateApp(a, &s.user)
+	c.Assert(err, check.IsNil)
+	err = a.AddUnits(1, "", nil)
+	c.Assert(err, check.IsNil)
+	err = instance.BindApp(a, false, nil)
+	c.Assert(err, check.IsNil)
+	err = s.conn.ServiceInstances().Find(bson.M{"name": instance.Name}).One(&instance)
+	c.Assert(err, check.IsNil)
+	err = instance.RotateCredentials([]bind.App{a}, true, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(atomic.LoadInt32(&rotated), check.Equals, int32(1))
+	a, err = app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	envs := a.InstanceEnvs("mysql", "my-mysql")
+	c.Assert(envs, check.HasLen, 2)
+	c.Assert(envs["DATABASE_PASSWORD"].Value, check.Equals, "n3w")
+	c.Assert(provisiontest.ProvisionerInstance.Restarts(a, ""), check.Equals, 1)
+}
+
+func (s *BindSuite) TestRotateCredentialsStoresRotatedAppsOnFailure(c *check.C) {
+	var rotated int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/bind-app/rotate") {
+			if atomic.AddInt32(&rotated, 1) > 1 {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.Write([]byte(`{"DATABASE_USER":"root","DATABASE_PASSWORD":"n3w"}`))
+			return
+		}
+		w.Write([]byte(`{"DATABASE_USER":"root","DATABASE_PASSWORD":"s3cr3t"}`))
+	}))
+	defer ts.Close()
+	srvc := service.Service{Name: "mysql", Endpoint: map[string]string{"production": ts.URL}, Password: "s3cr3t", OwnerTeams: []string{s.team.Name}}
+	err := srvc.Create()
+	c.Assert(err, check.IsNil)
+	instance := service.ServiceInstance{Name: "my-mysql", ServiceName: "mysql", Teams: []string{s.team.Name}}
+	err = s.conn.ServiceInstances().Insert(instance)
+	c.Assert(err, check.IsNil)
+	a1 := &app.App{Name: "painkiller", Platform: "python", TeamOwner: s.team.Name}
+	err = app.CreateApp(a1, &s.user)
+	c.Assert(err, check.IsNil)
+	err = a1.AddUnits(1, "", nil)
+	c.Assert(err, check.IsNil)
+	a2 := &app.App{Name: "analgesic", Platform: "python", TeamOwner: s.team.Name}
+	err = app.CreateApp(a2, &s.user)
+	c.Assert(err, check.IsNil)
+	err = instance.BindApp(a1, false, nil)
+	c.Assert(err, check.IsNil)
+	err = s.conn.ServiceInstances().Find(bson.M{"name": instance.Name}).One(&instance)
+	c.Assert(err, check.IsNil)
+	err = instance.BindApp(a2, false, nil)
+	c.Assert(err, check.IsNil)
+	err = s.conn.ServiceInstances().Find(bson.M{"name": instance.Name}).One(&instance)
+	c.Assert(err, check.IsNil)
+	err = instance.RotateCredentials([]bind.App{a1, a2}, true, nil)
+	c.Assert(err, check.NotNil)
+	a1, err = app.GetByName(a1.Name)
+	c.Assert(err, check.IsNil)
+	envs := a1.InstanceEnvs("mysql", "my-mysql")
+	c.Assert(envs["DATABASE_PASSWORD"].Value, check.Equals, "n3w")
+	c.Assert(provisiontest.ProvisionerInstance.Restarts(a1, ""), check.Equals, 1)
+	a2, err = app.GetByName(a2.Name)
+	c.Assert(err, check.IsNil)
+	envs = a2.InstanceEnvs("mysql", "my-mysql")
+	c.Assert(envs["DATABASE_PASSWORD"].Value, check.Equals, "s3cr3t")
+}
+
+func (s *BindSuite) TestRotateCredentialsAppNotBound(c *check.C) {
+	instance := service.ServiceInstance{Name: "my-mysql", ServiceName: "mysql", Teams: []string{s.team.Name}}
+	a := provisiontest.NewFakeApp("painkiller", "python", 1)
+	err := instance.RotateCredentials([]bind.App{a}, true, nil)
+	c.Assert(err, check.Equals, service.ErrAppNotBound)
+}
+
 func (s *BindSuite) TestUnbindRemovesAppFromServiceInstance(c *check.C) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNoContent)
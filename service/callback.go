@@ -0,0 +1,98 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	CallbackStatusPending = "pending"
+	CallbackStatusReady   = "ready"
+	CallbackStatusError   = "error"
+)
+
+var ErrInvalidCallbackStatus = errors.New("invalid status, it must be one of: pending, ready, error")
+
+// InstanceStatus is the status of an asynchronous operation, as reported by
+// the service API through the instance callback.
+type InstanceStatus struct {
+	Status    string
+	Message   string    `json:",omitempty"`
+	UpdatedAt time.Time `bson:"updated_at"`
+}
+
+func generateCallbackToken() (string, error) {
+	var data [20]byte
+	_, err := rand.Read(data[:])
+	if err != nil {
+		return "", errors.Wrap(err, "unable to generate callback token")
+	}
+	return hex.EncodeToString(data[:]), nil
+}
+
+// callbackURL returns the URL the service API should call when an
+// asynchronous operation on the instance finishes. It's empty when the tsuru
+// host is not configured.
+func (si *ServiceInstance) callbackURL() string {
+	host, _ := config.GetString("host")
+	if host == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/1.6/services/instances/%s/callback?service=%s",
+		strings.TrimRight(host, "/"), url.PathEscape(si.Name), url.QueryEscape(si.ServiceName))
+}
+
+// ValidCallbackToken checks whether token matches the callback token of the
+// instance. Instances created before callbacks were supported have no token
+// and never match.
+func (si *ServiceInstance) ValidCallbackToken(token string) bool {
+	if si.CallbackToken == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(si.CallbackToken)) == 1
+}
+
+// SetCallbackStatus stores the status reported by the service API. When
+// appName is set the status refers to the bind of the app, otherwise it
+// refers to the instance provisioning.
+func (si *ServiceInstance) SetCallbackStatus(appName string, status InstanceStatus) error {
+	switch status.Status {
+	case CallbackStatusPending, CallbackStatusReady, CallbackStatusError:
+	default:
+		return ErrInvalidCallbackStatus
+	}
+	status.UpdatedAt = time.Now().UTC()
+	field := "provision_status"
+	if appName != "" {
+		if si.FindApp(appName) == -1 {
+			return ErrAppNotBound
+		}
+		field = "bind_status." + appName
+	}
+	err := si.updateData(bson.M{"$set": bson.M{field: status}})
+	if err != nil {
+		return err
+	}
+	if appName == "" {
+		si.ProvisionStatus = &status
+		return nil
+	}
+	if si.BindStatus == nil {
+		si.BindStatus = map[string]InstanceStatus{}
+	}
+	si.BindStatus[appName] = status
+	return nil
+}
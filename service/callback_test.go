@@ -0,0 +1,110 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+
+	"github.com/tsuru/config"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestValidCallbackToken(c *check.C) {
+	si := ServiceInstance{Name: "my-mysql", ServiceName: "mysql", CallbackToken: "abc123"}
+	c.Assert(si.ValidCallbackToken("abc123"), check.Equals, true)
+	c.Assert(si.ValidCallbackToken("abc124"), check.Equals, false)
+	c.Assert(si.ValidCallbackToken(""), check.Equals, false)
+	si.CallbackToken = ""
+	c.Assert(si.ValidCallbackToken(""), check.Equals, false)
+}
+
+func (s *S) TestServiceInstanceBSONEncryptsCallbackToken(c *check.C) {
+	config.Set("secrets:key", "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	config.Set("secrets:encrypt-storage", true)
+	defer config.Unset("secrets")
+	data, err := bson.Marshal(ServiceInstance{Name: "my-mysql", ServiceName: "mysql", CallbackToken: "abc123"})
+	c.Assert(err, check.IsNil)
+	var raw bson.M
+	err = bson.Unmarshal(data, &raw)
+	c.Assert(err, check.IsNil)
+	c.Assert(raw["name"], check.Equals, "my-mysql")
+	c.Assert(raw["callback_token"], check.Matches, `enc:v1:.+`)
+	var result ServiceInstance
+	err = bson.Unmarshal(data, &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Name, check.Equals, "my-mysql")
+	c.Assert(result.CallbackToken, check.Equals, "abc123")
+}
+
+func (s *S) TestCallbackURL(c *check.C) {
+	si := ServiceInstance{Name: "my-mysql", ServiceName: "mysql"}
+	c.Assert(si.callbackURL(), check.Equals, "")
+	config.Set("host", "https://tsuru.example.com/")
+	defer config.Unset("host")
+	c.Assert(si.callbackURL(), check.Equals, "https://tsuru.example.com/1.6/services/instances/my-mysql/callback?service=mysql")
+}
+
+func (s *S) TestSetCallbackStatus(c *check.C) {
+	si := ServiceInstance{Name: "my-mysql", ServiceName: "mysql", CallbackToken: "abc123"}
+	err := s.conn.ServiceInstances().Insert(&si)
+	c.Assert(err, check.IsNil)
+	err = si.SetCallbackStatus("", InstanceStatus{Status: CallbackStatusError, Message: "disk full"})
+	c.Assert(err, check.IsNil)
+	dbInstance, err := GetServiceInstance("mysql", "my-mysql")
+	c.Assert(err, check.IsNil)
+	c.Assert(dbInstance.ProvisionStatus, check.NotNil)
+	c.Assert(dbInstance.ProvisionStatus.Status, check.Equals, CallbackStatusError)
+	c.Assert(dbInstance.ProvisionStatus.Message, check.Equals, "disk full")
+	c.Assert(dbInstance.ProvisionStatus.UpdatedAt.IsZero(), check.Equals, false)
+	c.Assert(dbInstance.CallbackToken, check.Equals, "abc123")
+}
+
+func (s *S) TestSetCallbackStatusForApp(c *check.C) {
+	si := ServiceInstance{Name: "my-mysql", ServiceName: "mysql", Apps: []string{"myapp"}}
+	err := s.conn.ServiceInstances().Insert(&si)
+	c.Assert(err, check.IsNil)
+	err = si.SetCallbackStatus("myapp", InstanceStatus{Status: CallbackStatusReady})
+	c.Assert(err, check.IsNil)
+	dbInstance, err := GetServiceInstance("mysql", "my-mysql")
+	c.Assert(err, check.IsNil)
+	c.Assert(dbInstance.ProvisionStatus, check.IsNil)
+	c.Assert(dbInstance.BindStatus, check.HasLen, 1)
+	c.Assert(dbInstance.BindStatus["myapp"].Status, check.Equals, CallbackStatusReady)
+	err = si.SetCallbackStatus("otherapp", InstanceStatus{Status: CallbackStatusReady})
+	c.Assert(err, check.Equals, ErrAppNotBound)
+}
+
+func (s *S) TestSetCallbackStatusInvalid(c *check.C) {
+	si := ServiceInstance{Name: "my-mysql", ServiceName: "mysql"}
+	err := si.SetCallbackStatus("", InstanceStatus{Status: "done"})
+	c.Assert(err, check.Equals, ErrInvalidCallbackStatus)
+}
+
+func (s *S) TestStatusPendingFromCallback(c *check.C) {
+	si := ServiceInstance{Name: "my-mysql", ServiceName: "mysql", ProvisionStatus: &InstanceStatus{Status: CallbackStatusPending}}
+	result, err := si.Status("")
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.Equals, "pending")
+}
+
+func (s *S) TestStatusAfterCallbackQueriesServiceAPI(c *check.C) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+	srv := Service{Name: "mysql", Endpoint: map[string]string{"production": ts.URL}, Password: "s3cr3t", OwnerTeams: []string{s.team.Name}}
+	err := s.conn.Services().Insert(srv)
+	c.Assert(err, check.IsNil)
+	si := ServiceInstance{Name: "my-mysql", ServiceName: "mysql", ProvisionStatus: &InstanceStatus{Status: CallbackStatusReady}}
+	result, err := si.Status("")
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.Equals, "down")
+	c.Assert(atomic.LoadInt32(&requests), check.Equals, int32(1))
+}
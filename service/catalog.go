@@ -0,0 +1,63 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"strings"
+
+	"github.com/tsuru/tsuru/log"
+)
+
+// CatalogEntry describes a service for users deciding which service to use.
+type CatalogEntry struct {
+	Service     string `json:"service"`
+	Description string `json:"description"`
+	Plans       []Plan `json:"plans"`
+	DocSummary  string `json:"doc_summary"`
+	Restricted  bool   `json:"restricted"`
+}
+
+// DocSummary returns the first paragraph of the service documentation,
+// joined in a single line.
+func (s *Service) DocSummary() string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(s.Doc), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, " ")
+}
+
+// Catalog returns the catalog entries for the given services. Plans are
+// fetched from each service API, services failing to list their plans are
+// still listed, without plans.
+func Catalog(services []Service, requestID string) []CatalogEntry {
+	entries := make([]CatalogEntry, len(services))
+	for i, s := range services {
+		entries[i] = CatalogEntry{
+			Service:     s.Name,
+			Description: s.Description,
+			DocSummary:  s.DocSummary(),
+			Restricted:  s.IsRestricted,
+			Plans:       []Plan{},
+		}
+		endpoint, err := s.getClient("production")
+		if err != nil {
+			continue
+		}
+		plans, err := endpoint.Plans(requestID)
+		if err != nil {
+			log.Errorf("[service catalog] unable to list plans for service %q: %s", s.Name, err)
+			continue
+		}
+		if plans != nil {
+			entries[i].Plans = plans
+		}
+	}
+	return entries
+}
@@ -0,0 +1,44 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestServiceDocSummary(c *check.C) {
+	srvc := Service{Doc: "\n  My service.\n  Use it wisely.\n\nDetails follow."}
+	c.Assert(srvc.DocSummary(), check.Equals, "My service. Use it wisely.")
+	srvc = Service{}
+	c.Assert(srvc.DocSummary(), check.Equals, "")
+}
+
+func (s *S) TestCatalog(c *check.C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"name": "ignite", "description": "some value"}]`))
+	}))
+	defer ts.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	services := []Service{
+		{Name: "mysql", Description: "Relational database", Doc: "MySQL database.", Endpoint: map[string]string{"production": ts.URL}},
+		{Name: "redis", Endpoint: map[string]string{"production": failing.URL}, IsRestricted: true},
+	}
+	catalog := Catalog(services, "")
+	c.Assert(catalog, check.DeepEquals, []CatalogEntry{
+		{
+			Service:     "mysql",
+			Description: "Relational database",
+			DocSummary:  "MySQL database.",
+			Plans:       []Plan{{Name: "ignite", Description: "some value"}},
+		},
+		{Service: "redis", Plans: []Plan{}, Restricted: true},
+	})
+}
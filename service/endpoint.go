@@ -6,11 +6,14 @@ package service
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -18,6 +21,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/app/bind"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/net"
 )
@@ -26,6 +30,10 @@ var (
 	ErrInstanceAlreadyExistsInAPI = errors.New("instance already exists in the service API")
 	ErrInstanceNotFoundInAPI      = errors.New("instance does not exist in the service API")
 	ErrInstanceNotReady           = errors.New("instance is not ready yet")
+	ErrCloneNotSupported          = errors.New("service API does not support cloning instances")
+	ErrMetricsNotSupported        = errors.New("service API does not report instance metrics")
+
+	envNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
 
 	requestLatencies = prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Name: "tsuru_service_request_duration_seconds",
@@ -37,6 +45,30 @@ var (
 	}, []string{"service"})
 )
 
+const (
+	maxEnvsResponseSize = 1 << 20
+	maxEnvsCount        = 100
+	maxEnvValueSize     = 64 << 10
+
+	defaultRequestTimeout = 5 * time.Minute
+	maxRequestRetries     = 5
+)
+
+// requestRetryInterval is how long the client waits before retrying a failed
+// request.
+var requestRetryInterval = time.Second
+
+// InvalidEnvsResponseError is returned when the service API answers a bind
+// request with something other than a flat map of valid environment
+// variables.
+type InvalidEnvsResponseError struct {
+	Reason string
+}
+
+func (e *InvalidEnvsResponseError) Error() string {
+	return "invalid environment variables returned by the service API: " + e.Reason
+}
+
 func init() {
 	prometheus.MustRegister(requestLatencies)
 	prometheus.MustRegister(requestErrors)
@@ -47,6 +79,42 @@ type Client struct {
 	endpoint    string
 	username    string
 	password    string
+	timeout     time.Duration
+	retries     int
+}
+
+// NewClient returns a client of the API of the named service, available at
+// endpoint and authenticated with username and password. Requests time out
+// after five minutes and aren't retried.
+func NewClient(serviceName, endpoint, username, password string) *Client {
+	return &Client{serviceName: serviceName, endpoint: endpoint, username: username, password: password}
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.timeout <= 0 || c.timeout == defaultRequestTimeout {
+		return net.Dial5Full300ClientNoKeepAlive
+	}
+	return &http.Client{
+		Transport: net.Dial5FullUnlimitedClientNoKeepAlive.Transport,
+		Timeout:   c.timeout,
+	}
+}
+
+// shouldRetry tells whether a request may be sent again after failing with
+// the given response or error. Only idempotent requests are retried, when
+// the service API can't be reached or its gateway fails.
+func shouldRetry(method string, resp *http.Response, err error) bool {
+	if method != "GET" && method != "PUT" && method != "DELETE" {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
 }
 
 func (c *Client) buildErrorMessage(err error, resp *http.Response) error {
@@ -56,7 +124,8 @@ func (c *Client) buildErrorMessage(err error, resp *http.Response) error {
 	if resp != nil {
 		defer resp.Body.Close()
 		b, _ := ioutil.ReadAll(resp.Body)
-		return errors.Errorf("invalid response: %s (code: %d)", string(b), resp.StatusCode)
+		err = errors.Errorf("invalid response: %s (code: %d)", string(b), resp.StatusCode)
+		return tsuruErrors.ClassifyHTTPStatus(err, resp.StatusCode)
 	}
 	return nil
 }
@@ -72,15 +141,32 @@ func (c *Client) issueRequest(path, method string, params map[string][]string) (
 		delete(params, "requestID")
 	}
 	v := url.Values(params)
-	var suffix string
-	var body io.Reader
+	var suffix, body string
 	if method == "GET" {
 		suffix = "?" + v.Encode()
 	} else {
-		body = strings.NewReader(v.Encode())
+		body = v.Encode()
 	}
 	url := strings.TrimRight(c.endpoint, "/") + "/" + strings.Trim(path, "/") + suffix
-	req, err := http.NewRequest(method, url, body)
+	for attempt := 0; ; attempt++ {
+		resp, err := c.doRequest(method, url, body, requestID)
+		if attempt >= c.retries || !shouldRetry(method, resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		log.Errorf("[service] request %s %s to service %q failed, retrying (%d/%d)", method, path, c.serviceName, attempt+1, c.retries)
+		time.Sleep(requestRetryInterval)
+	}
+}
+
+func (c *Client) doRequest(method, url, body, requestID string) (*http.Response, error) {
+	var bodyReader io.Reader
+	if body != "" {
+		bodyReader = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, url, bodyReader)
 	if err != nil {
 		log.Errorf("Got error while creating request: %s", err)
 		return nil, err
@@ -94,7 +180,7 @@ func (c *Client) issueRequest(path, method string, params map[string][]string) (
 	req.SetBasicAuth(c.username, c.password)
 	req.Close = true
 	t0 := time.Now()
-	resp, err := net.Dial5Full300ClientNoKeepAlive.Do(req)
+	resp, err := c.httpClient().Do(req)
 	requestLatencies.WithLabelValues(c.serviceName).Observe(time.Since(t0).Seconds())
 	if err != nil {
 		requestErrors.WithLabelValues(c.serviceName).Inc()
@@ -112,6 +198,46 @@ func (c *Client) jsonFromResponse(resp *http.Response, v interface{}) error {
 	return json.Unmarshal(body, &v)
 }
 
+func (c *Client) envsFromResponse(resp *http.Response) (map[string]string, error) {
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxEnvsResponseSize+1))
+	if err != nil {
+		log.Errorf("Got error while parsing service json: %s", err)
+		return nil, err
+	}
+	if len(body) > maxEnvsResponseSize {
+		return nil, &InvalidEnvsResponseError{Reason: fmt.Sprintf("response larger than %d bytes", maxEnvsResponseSize)}
+	}
+	var raw map[string]interface{}
+	err = json.Unmarshal(body, &raw)
+	if err != nil {
+		return nil, &InvalidEnvsResponseError{Reason: "expected a JSON object mapping names to string values"}
+	}
+	if len(raw) > maxEnvsCount {
+		return nil, &InvalidEnvsResponseError{Reason: fmt.Sprintf("%d variables returned, the limit is %d", len(raw), maxEnvsCount)}
+	}
+	names := make([]string, 0, len(raw))
+	for name := range raw {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	envs := make(map[string]string, len(raw))
+	for _, name := range names {
+		if !envNameRegexp.MatchString(name) {
+			return nil, &InvalidEnvsResponseError{Reason: fmt.Sprintf("invalid variable name %q", name)}
+		}
+		value, ok := raw[name].(string)
+		if !ok {
+			return nil, &InvalidEnvsResponseError{Reason: fmt.Sprintf("value of %q is not a string", name)}
+		}
+		if len(value) > maxEnvValueSize {
+			return nil, &InvalidEnvsResponseError{Reason: fmt.Sprintf("value of %q is larger than %d bytes", name, maxEnvValueSize)}
+		}
+		envs[name] = value
+	}
+	return envs, nil
+}
+
 func (c *Client) Create(instance *ServiceInstance, user, requestID string) error {
 	var err error
 	var resp *http.Response
@@ -127,6 +253,7 @@ func (c *Client) Create(instance *ServiceInstance, user, requestID string) error
 	if instance.Description != "" {
 		params["description"] = []string{instance.Description}
 	}
+	addCallbackParams(instance, params)
 	log.Debugf("Attempting to call creation of service instance for %q, params: %#v", instance.ServiceName, params)
 	resp, err = c.issueRequest("/resources", "POST", params)
 	if err == nil {
@@ -142,6 +269,54 @@ func (c *Client) Create(instance *ServiceInstance, user, requestID string) error
 	return log.WrapError(err)
 }
 
+// Clone asks the service API to create instance as a copy of the resource
+// backing source.
+func (c *Client) Clone(source, instance *ServiceInstance, user, requestID string) error {
+	params := map[string][]string{
+		"name":      {instance.Name},
+		"user":      {user},
+		"team":      {instance.TeamOwner},
+		"requestID": {requestID},
+	}
+	if instance.PlanName != "" {
+		params["plan"] = []string{instance.PlanName}
+	}
+	if instance.Description != "" {
+		params["description"] = []string{instance.Description}
+	}
+	addCallbackParams(instance, params)
+	log.Debugf("Attempting to call clone of service instance %q for %q, params: %#v", source.Name, instance.ServiceName, params)
+	resp, err := c.issueRequest("/resources/"+source.GetIdentifier()+"/clone", "POST", params)
+	if err == nil {
+		defer resp.Body.Close()
+		switch resp.StatusCode {
+		case http.StatusNotFound:
+			return ErrInstanceNotFoundInAPI
+		case http.StatusConflict:
+			return ErrInstanceAlreadyExistsInAPI
+		case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+			return ErrCloneNotSupported
+		}
+		if resp.StatusCode < 300 {
+			return nil
+		}
+	}
+	err = errors.Wrapf(c.buildErrorMessage(err, resp), "Failed to clone the instance %s", source.Name)
+	return log.WrapError(err)
+}
+
+// addCallbackParams includes the data the service API needs to notify tsuru
+// when the provisioning of instance finishes.
+func addCallbackParams(instance *ServiceInstance, params map[string][]string) {
+	if instance.CallbackToken == "" {
+		return
+	}
+	params["callback-token"] = []string{instance.CallbackToken}
+	if u := instance.callbackURL(); u != "" {
+		params["callback-url"] = []string{u}
+	}
+}
+
 func (c *Client) Update(instance *ServiceInstance, requestID string) error {
 	log.Debugf("Attempting to call update of service instance %q at %q api", instance.Name, instance.ServiceName)
 	params := map[string][]string{
@@ -210,12 +385,7 @@ func (c *Client) BindApp(instance *ServiceInstance, app bind.App) (map[string]st
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode < 300 {
-		var result map[string]string
-		err = c.jsonFromResponse(resp, &result)
-		if err != nil {
-			return nil, err
-		}
-		return result, nil
+		return c.envsFromResponse(resp)
 	}
 	switch resp.StatusCode {
 	case http.StatusPreconditionFailed:
@@ -313,6 +483,39 @@ func (c *Client) UnbindUnit(instance *ServiceInstance, app bind.App, unit bind.U
 	return err
 }
 
+// RotateCredentials asks the service API for fresh credentials of the
+// binding between the instance and the app, returning the new environment
+// variables. Previous credentials are expected to be revoked by the service.
+func (c *Client) RotateCredentials(instance *ServiceInstance, app bind.App) (map[string]string, error) {
+	log.Debugf("Calling credential rotation of instance %q and %q app at %q API", instance.Name, app.GetName(), instance.ServiceName)
+	appAddrs, err := app.GetAddresses()
+	if err != nil {
+		return nil, err
+	}
+	params := map[string][]string{
+		"app-hosts": appAddrs,
+	}
+	if len(appAddrs) > 0 {
+		params["app-host"] = []string{appAddrs[0]}
+	}
+	resp, err := c.issueRequest("/resources/"+instance.GetIdentifier()+"/bind-app/rotate", "POST", params)
+	if err != nil {
+		return nil, log.WrapError(errors.Wrapf(err, `Failed to rotate credentials of app %q in service instance "%s/%s"`, app.GetName(), instance.ServiceName, instance.Name))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 300 {
+		return c.envsFromResponse(resp)
+	}
+	switch resp.StatusCode {
+	case http.StatusPreconditionFailed:
+		return nil, ErrInstanceNotReady
+	case http.StatusNotFound:
+		return nil, ErrInstanceNotFoundInAPI
+	}
+	err = errors.Wrapf(c.buildErrorMessage(err, resp), `Failed to rotate credentials of app %q in service instance "%s/%s"`, app.GetName(), instance.ServiceName, instance.Name)
+	return nil, log.WrapError(err)
+}
+
 func (c *Client) Status(instance *ServiceInstance, requestID string) (string, error) {
 	log.Debugf("Attempting to call status of service instance %q at %q api", instance.Name, instance.ServiceName)
 	var (
@@ -370,6 +573,35 @@ func (c *Client) Info(instance *ServiceInstance, requestID string) ([]map[string
 	return result, nil
 }
 
+// Metrics returns the usage metrics of a service instance. Reporting metrics
+// is optional, the api implementing it should be prepared to receive the
+// request, like below:
+// GET /resources/<name>/metrics
+func (c *Client) Metrics(instance *ServiceInstance, requestID string) ([]InstanceMetric, error) {
+	log.Debugf("Attempting to call metrics of service instance %q at %q api", instance.Name, instance.ServiceName)
+	url := "/resources/" + instance.GetIdentifier() + "/metrics"
+	params := map[string][]string{
+		"requestID": {requestID},
+	}
+	resp, err := c.issueRequest(url, "GET", params)
+	if err == nil {
+		defer resp.Body.Close()
+		switch resp.StatusCode {
+		case http.StatusOK:
+			var metrics []InstanceMetric
+			err = c.jsonFromResponse(resp, &metrics)
+			if err != nil {
+				return nil, err
+			}
+			return metrics, nil
+		case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+			return nil, ErrMetricsNotSupported
+		}
+	}
+	err = errors.Wrapf(c.buildErrorMessage(err, resp), "Failed to get metrics of instance %s", instance.Name)
+	return nil, log.WrapError(err)
+}
+
 // Plans returns the service plans.
 // The api should be prepared to receive the request,
 // like below:
@@ -395,6 +627,27 @@ func (c *Client) Plans(requestID string) ([]Plan, error) {
 	return result, nil
 }
 
+// Ping checks whether the service api is reachable, returning the time taken
+// to get a response. The api is reached through the plans endpoint, which
+// every service api must implement:
+// GET /resources/plans
+func (c *Client) Ping(requestID string) (time.Duration, error) {
+	params := map[string][]string{
+		"requestID": {requestID},
+	}
+	t0 := time.Now()
+	resp, err := c.issueRequest("/resources/plans", "GET", params)
+	latency := time.Since(t0)
+	if err != nil {
+		return latency, err
+	}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return latency, c.buildErrorMessage(nil, resp)
+	}
+	resp.Body.Close()
+	return latency, nil
+}
+
 // Proxy is a proxy between tsuru and the service.
 // This method allow customized service methods.
 func (c *Client) Proxy(path string, w http.ResponseWriter, r *http.Request) error {
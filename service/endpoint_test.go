@@ -6,7 +6,9 @@ package service
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -14,8 +16,10 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/tsuru/config"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/provision/provisiontest"
 	"gopkg.in/check.v1"
 )
@@ -167,6 +171,29 @@ func (s *S) TestCreateShouldSendTheNameOfTheResourceToTheEndpoint(c *check.C) {
 	c.Assert("close", check.Equals, h.request.Header.Get("Connection"))
 }
 
+func (s *S) TestCreateShouldSendTheCallbackParams(c *check.C) {
+	config.Set("host", "https://tsuru.example.com")
+	defer config.Unset("host")
+	h := TestHandler{}
+	ts := httptest.NewServer(&h)
+	defer ts.Close()
+	instance := ServiceInstance{Name: "my-redis", ServiceName: "redis", TeamOwner: "myteam", CallbackToken: "abc123"}
+	client := &Client{endpoint: ts.URL, username: "user", password: "abcde"}
+	err := client.Create(&instance, "my@user", "")
+	c.Assert(err, check.IsNil)
+	h.Lock()
+	defer h.Unlock()
+	v, err := url.ParseQuery(string(h.body))
+	c.Assert(err, check.IsNil)
+	c.Assert(map[string][]string(v), check.DeepEquals, map[string][]string{
+		"name":           {"my-redis"},
+		"user":           {"my@user"},
+		"team":           {"myteam"},
+		"callback-token": {"abc123"},
+		"callback-url":   {"https://tsuru.example.com/1.6/services/instances/my-redis/callback?service=redis"},
+	})
+}
+
 func (s *S) TestCreateDuplicate(c *check.C) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusConflict)
@@ -188,6 +215,103 @@ func (s *S) TestCreateShouldReturnErrorIfTheRequestFail(c *check.C) {
 	c.Assert(err, check.ErrorMatches, `^Failed to create the instance `+instance.Name+`: invalid response: Server failed to do its job. \(code: 500\)$`)
 }
 
+func (s *S) TestCloneShouldSendThePostRequestToTheSourceResource(c *check.C) {
+	var requests int32
+	source := ServiceInstance{Name: "prod-db", ServiceName: "mysql", TeamOwner: "myteam"}
+	instance := ServiceInstance{Name: "staging-db", ServiceName: "mysql", TeamOwner: "otherteam", PlanName: "small", Description: "staging"}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		c.Check(r.Method, check.Equals, http.MethodPost)
+		c.Check(r.URL.Path, check.Equals, "/resources/prod-db/clone")
+		r.ParseForm()
+		c.Check(map[string][]string(r.PostForm), check.DeepEquals, map[string][]string{
+			"name":        {"staging-db"},
+			"user":        {"my@user"},
+			"team":        {"otherteam"},
+			"plan":        {"small"},
+			"description": {"staging"},
+		})
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer ts.Close()
+	client := &Client{endpoint: ts.URL, username: "user", password: "abcde"}
+	err := client.Clone(&source, &instance, "my@user", "")
+	c.Assert(err, check.IsNil)
+	c.Assert(atomic.LoadInt32(&requests), check.Equals, int32(1))
+}
+
+func (s *S) TestCloneErrors(c *check.C) {
+	tests := []struct {
+		code int
+		err  error
+	}{
+		{http.StatusNotFound, ErrInstanceNotFoundInAPI},
+		{http.StatusConflict, ErrInstanceAlreadyExistsInAPI},
+		{http.StatusMethodNotAllowed, ErrCloneNotSupported},
+		{http.StatusNotImplemented, ErrCloneNotSupported},
+	}
+	source := ServiceInstance{Name: "prod-db", ServiceName: "mysql"}
+	instance := ServiceInstance{Name: "staging-db", ServiceName: "mysql"}
+	for _, tt := range tests {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.code)
+		}))
+		client := &Client{endpoint: ts.URL, username: "user", password: "abcde"}
+		err := client.Clone(&source, &instance, "my@user", "")
+		c.Check(err, check.Equals, tt.err, check.Commentf("code %d", tt.code))
+		ts.Close()
+	}
+}
+
+func (s *S) TestCloneShouldReturnErrorIfTheRequestFail(c *check.C) {
+	ts := httptest.NewServer(http.HandlerFunc(failHandler))
+	defer ts.Close()
+	source := ServiceInstance{Name: "prod-db", ServiceName: "mysql"}
+	instance := ServiceInstance{Name: "staging-db", ServiceName: "mysql"}
+	client := &Client{endpoint: ts.URL, username: "user", password: "abcde"}
+	err := client.Clone(&source, &instance, "my@user", "")
+	c.Assert(err, check.ErrorMatches, `^Failed to clone the instance prod-db: invalid response: Server failed to do its job. \(code: 500\)$`)
+}
+
+func (s *S) TestMetrics(c *check.C) {
+	instance := ServiceInstance{Name: "my-redis", ServiceName: "redis"}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, http.MethodGet)
+		c.Check(r.URL.Path, check.Equals, "/resources/my-redis/metrics")
+		w.Write([]byte(`[{"name":"connections","value":12},{"name":"storage","value":1024,"unit":"bytes"}]`))
+	}))
+	defer ts.Close()
+	client := &Client{endpoint: ts.URL, username: "user", password: "abcde"}
+	metrics, err := client.Metrics(&instance, "")
+	c.Assert(err, check.IsNil)
+	c.Assert(metrics, check.DeepEquals, []InstanceMetric{
+		{Name: "connections", Value: 12},
+		{Name: "storage", Value: 1024, Unit: "bytes"},
+	})
+}
+
+func (s *S) TestMetricsNotSupported(c *check.C) {
+	instance := ServiceInstance{Name: "my-redis", ServiceName: "redis"}
+	for _, code := range []int{http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented} {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(code)
+		}))
+		client := &Client{endpoint: ts.URL, username: "user", password: "abcde"}
+		_, err := client.Metrics(&instance, "")
+		c.Check(err, check.Equals, ErrMetricsNotSupported, check.Commentf("code %d", code))
+		ts.Close()
+	}
+}
+
+func (s *S) TestMetricsShouldReturnErrorIfTheRequestFail(c *check.C) {
+	ts := httptest.NewServer(http.HandlerFunc(failHandler))
+	defer ts.Close()
+	instance := ServiceInstance{Name: "my-redis", ServiceName: "redis"}
+	client := &Client{endpoint: ts.URL, username: "user", password: "abcde"}
+	_, err := client.Metrics(&instance, "")
+	c.Assert(err, check.ErrorMatches, `^Failed to get metrics of instance my-redis: invalid response: Server failed to do its job. \(code: 500\)$`)
+}
+
 func (s *S) TestUpdateShouldSendAPutRequestToTheResourceURL(c *check.C) {
 	requestIDHeader := "request-id"
 	requestIDValue := "request-id-value"
@@ -344,6 +468,80 @@ func (s *S) TestBindAppShouldReturnMapWithTheEnvironmentVariable(c *check.C) {
 	c.Assert(env, check.DeepEquals, expected)
 }
 
+func (s *S) TestBindAppInvalidEnvsResponse(c *check.C) {
+	var tests = []struct {
+		body   string
+		reason string
+	}{
+		{`["a", "b"]`, `expected a JSON object mapping names to string values`},
+		{`{"MYSQL_PORT": 3306}`, `value of "MYSQL_PORT" is not a string`},
+		{`{"MYSQL-HOST": "localhost"}`, `invalid variable name "MYSQL-HOST"`},
+		{`{"1HOST": "localhost"}`, `invalid variable name "1HOST"`},
+		{`{"HOST": "` + strings.Repeat("x", maxEnvValueSize+1) + `"}`, `value of "HOST" is larger than 65536 bytes`},
+	}
+	instance := ServiceInstance{Name: "her-redis", ServiceName: "redis"}
+	a := provisiontest.NewFakeApp("her-app", "python", 1)
+	for _, tt := range tests {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(tt.body))
+		}))
+		client := &Client{endpoint: ts.URL, username: "user", password: "abcde"}
+		env, err := client.BindApp(&instance, a)
+		ts.Close()
+		c.Assert(env, check.IsNil)
+		c.Assert(err, check.DeepEquals, &InvalidEnvsResponseError{Reason: tt.reason})
+	}
+}
+
+func (s *S) TestBindAppTooManyEnvs(c *check.C) {
+	envs := map[string]string{}
+	for i := 0; i <= maxEnvsCount; i++ {
+		envs[fmt.Sprintf("VAR_%d", i)] = "value"
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(envs)
+	}))
+	defer ts.Close()
+	instance := ServiceInstance{Name: "her-redis", ServiceName: "redis"}
+	a := provisiontest.NewFakeApp("her-app", "python", 1)
+	client := &Client{endpoint: ts.URL, username: "user", password: "abcde"}
+	_, err := client.BindApp(&instance, a)
+	c.Assert(err, check.ErrorMatches, `invalid environment variables returned by the service API: 101 variables returned, the limit is 100`)
+}
+
+func (s *S) TestRotateCredentials(c *check.C) {
+	h := TestHandler{}
+	ts := httptest.NewServer(&h)
+	defer ts.Close()
+	instance := ServiceInstance{Name: "her-redis", ServiceName: "redis"}
+	a := provisiontest.NewFakeApp("her-app", "python", 1)
+	client := &Client{endpoint: ts.URL, username: "user", password: "abcde"}
+	env, err := client.RotateCredentials(&instance, a)
+	c.Assert(err, check.IsNil)
+	c.Assert(env, check.DeepEquals, map[string]string{
+		"MYSQL_DATABASE_NAME": "CHICO",
+		"MYSQL_HOST":          "localhost",
+		"MYSQL_PORT":          "3306",
+	})
+	h.Lock()
+	defer h.Unlock()
+	c.Assert(h.method, check.Equals, "POST")
+	c.Assert(h.url, check.Equals, "/resources/her-redis/bind-app/rotate")
+	v, err := url.ParseQuery(string(h.body))
+	c.Assert(err, check.IsNil)
+	c.Assert(v.Get("app-host"), check.Equals, "her-app.fakerouter.com")
+}
+
+func (s *S) TestRotateCredentialsFailure(c *check.C) {
+	ts := httptest.NewServer(http.HandlerFunc(failHandler))
+	defer ts.Close()
+	instance := ServiceInstance{Name: "her-redis", ServiceName: "redis"}
+	a := provisiontest.NewFakeApp("her-app", "python", 1)
+	client := &Client{endpoint: ts.URL, username: "user", password: "abcde"}
+	_, err := client.RotateCredentials(&instance, a)
+	c.Assert(err, check.ErrorMatches, `^Failed to rotate credentials of app "her-app" in service instance "redis/her-redis": invalid response: Server failed to do its job. \(code: 500\)$`)
+}
+
 func (s *S) TestBindAppShouldReturnErrorIfTheRequestFail(c *check.C) {
 	ts := httptest.NewServer(http.HandlerFunc(failHandler))
 	defer ts.Close()
@@ -563,6 +761,14 @@ func (s *S) TestBuildErrorMessageWithNonNilResponseAndNilError(c *check.C) {
 	c.Assert(cli.buildErrorMessage(nil, resp), check.ErrorMatches, `invalid response: something went wrong \(code: 0\)`)
 }
 
+func (s *S) TestBuildErrorMessageClassifiesStatus(c *check.C) {
+	cli := Client{}
+	resp := &http.Response{StatusCode: http.StatusServiceUnavailable, Body: ioutil.NopCloser(strings.NewReader("unavailable"))}
+	c.Assert(tsuruErrors.IsRetryable(cli.buildErrorMessage(nil, resp)), check.Equals, true)
+	resp = &http.Response{StatusCode: http.StatusBadRequest, Body: ioutil.NopCloser(strings.NewReader("invalid plan"))}
+	c.Assert(tsuruErrors.IsRetryable(cli.buildErrorMessage(nil, resp)), check.Equals, false)
+}
+
 func (s *S) TestBuildErrorMessageWithNonNilResponseAndNonNilError(c *check.C) {
 	cli := Client{}
 	err := errors.New("epic fail")
@@ -684,3 +890,72 @@ func (s *S) TestProxyWithBodyAndHeaders(c *check.C) {
 	c.Assert(proxiedRequest.Host, check.Equals, tsURL.Host)
 	c.Assert(string(readBodyStr), check.Equals, `{"bla": "bla"}`)
 }
+
+func (s *S) TestIssueRequestRetries(c *check.C) {
+	oldInterval := requestRetryInterval
+	requestRetryInterval = 0
+	defer func() { requestRetryInterval = oldInterval }()
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer ts.Close()
+	client := &Client{endpoint: ts.URL, username: "user", password: "abcde", retries: 2}
+	resp, err := client.issueRequest("/resources/plans", "GET", nil)
+	c.Assert(err, check.IsNil)
+	defer resp.Body.Close()
+	c.Assert(resp.StatusCode, check.Equals, http.StatusOK)
+	c.Assert(atomic.LoadInt32(&calls), check.Equals, int32(3))
+}
+
+func (s *S) TestIssueRequestRetriesExhausted(c *check.C) {
+	oldInterval := requestRetryInterval
+	requestRetryInterval = 0
+	defer func() { requestRetryInterval = oldInterval }()
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer ts.Close()
+	client := &Client{endpoint: ts.URL, username: "user", password: "abcde", retries: 1}
+	resp, err := client.issueRequest("/resources/plans", "GET", nil)
+	c.Assert(err, check.IsNil)
+	defer resp.Body.Close()
+	c.Assert(resp.StatusCode, check.Equals, http.StatusBadGateway)
+	c.Assert(atomic.LoadInt32(&calls), check.Equals, int32(2))
+}
+
+func (s *S) TestIssueRequestDoesNotRetryPost(c *check.C) {
+	oldInterval := requestRetryInterval
+	requestRetryInterval = 0
+	defer func() { requestRetryInterval = oldInterval }()
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+	client := &Client{endpoint: ts.URL, username: "user", password: "abcde", retries: 3}
+	resp, err := client.issueRequest("/resources", "POST", map[string][]string{"name": {"db"}})
+	c.Assert(err, check.IsNil)
+	defer resp.Body.Close()
+	c.Assert(resp.StatusCode, check.Equals, http.StatusServiceUnavailable)
+	c.Assert(atomic.LoadInt32(&calls), check.Equals, int32(1))
+}
+
+func (s *S) TestIssueRequestTimeout(c *check.C) {
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer ts.Close()
+	defer close(done)
+	client := &Client{endpoint: ts.URL, username: "user", password: "abcde", timeout: 50 * time.Millisecond}
+	_, err := client.issueRequest("/resources/plans", "GET", nil)
+	c.Assert(err, check.NotNil)
+}
@@ -8,13 +8,19 @@ import (
 	"fmt"
 	"net/http"
 	"regexp"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
+	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/db"
 	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/secret"
 	authTypes "github.com/tsuru/tsuru/types/auth"
 	"github.com/tsuru/tsuru/validation"
+	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
 
@@ -25,12 +31,44 @@ type Service struct {
 	Endpoint     map[string]string
 	OwnerTeams   []string `bson:"owner_teams"`
 	Teams        []string
+	Description  string
 	Doc          string
-	IsRestricted bool `bson:"is_restricted"`
+	DocUpdatedAt time.Time `bson:"doc_updated_at,omitempty" json:"-"`
+	IsRestricted bool      `bson:"is_restricted"`
+	Webhook      *Webhook  `bson:",omitempty" json:"-"`
+	// Timeout and Retries override, when set, the service:timeout and
+	// service:retries config entries for the requests sent to the service
+	// API. Timeout is in seconds.
+	Timeout int `bson:",omitempty"`
+	Retries int `bson:",omitempty"`
+}
+
+// GetBSON encrypts the service password before storing it, when
+// secrets:encrypt-storage is enabled.
+func (s Service) GetBSON() (interface{}, error) {
+	type plain Service
+	password, err := secret.Seal(s.Password)
+	if err != nil {
+		return nil, err
+	}
+	s.Password = password
+	return plain(s), nil
+}
+
+// SetBSON decrypts the service password read from the storage.
+func (s *Service) SetBSON(raw bson.Raw) error {
+	type plain Service
+	err := raw.Unmarshal((*plain)(s))
+	if err != nil {
+		return err
+	}
+	s.Password, err = secret.Open(s.Password)
+	return err
 }
 
 var (
 	ErrServiceAlreadyExists = errors.New("Service already exists.")
+	ErrServiceTeamsChanged  = errors.New("Service teams were changed by another request, please try again.")
 )
 
 func (s *Service) Get() error {
@@ -89,13 +127,40 @@ func (s *Service) getClient(endpoint string) (cli *Client, err error) {
 		if p, _ := regexp.MatchString("^https?://", e); !p {
 			e = "http://" + e
 		}
-		cli = &Client{serviceName: s.Name, endpoint: e, username: s.GetUsername(), password: s.Password}
+		cli = NewClient(s.Name, e, s.GetUsername(), s.Password)
+		cli.timeout = s.requestTimeout()
+		cli.retries = s.requestRetries()
 	} else {
 		err = errors.New("Unknown endpoint: " + endpoint)
 	}
 	return
 }
 
+// requestTimeout returns the timeout of the requests sent to the service API.
+func (s *Service) requestTimeout() time.Duration {
+	if s.Timeout > 0 {
+		return time.Duration(s.Timeout) * time.Second
+	}
+	seconds, err := config.GetInt("service:timeout")
+	if err != nil || seconds <= 0 {
+		return defaultRequestTimeout
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// requestRetries returns how many times idempotent requests to the service
+// API are retried when they fail.
+func (s *Service) requestRetries() int {
+	if s.Retries > 0 {
+		return s.Retries
+	}
+	retries, _ := config.GetInt("service:retries")
+	if retries < 0 {
+		return 0
+	}
+	return retries
+}
+
 func (s *Service) GetUsername() string {
 	if s.Username != "" {
 		return s.Username
@@ -134,39 +199,128 @@ func (s *Service) RevokeAccess(team *authTypes.Team) error {
 	return nil
 }
 
-func (s *Service) validate(skipName bool) (err error) {
-	defer func() {
-		if err != nil {
-			err = &tsuruErrors.ValidationError{Message: err.Error()}
+// TeamsDiff describes the changes applied to the teams with access to a
+// service.
+type TeamsDiff struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+// SetTeams replaces the list of teams with access to the service, returning
+// the teams that were added and removed. The whole list is saved in a single
+// update, which fails with ErrServiceTeamsChanged if the teams were changed
+// since the service was loaded.
+func (s *Service) SetTeams(teamNames []string) (*TeamsDiff, error) {
+	teamNames = uniqueSortedStrings(teamNames)
+	teams, err := auth.TeamService().FindByNames(teamNames)
+	if err != nil {
+		return nil, err
+	}
+	if len(teams) != len(teamNames) {
+		found := make(map[string]bool, len(teams))
+		for _, t := range teams {
+			found[t.Name] = true
+		}
+		var missing []string
+		for _, name := range teamNames {
+			if !found[name] {
+				missing = append(missing, name)
+			}
+		}
+		return nil, &tsuruErrors.ValidationError{Message: fmt.Sprintf("teams not found: %s", strings.Join(missing, ", "))}
+	}
+	current := make(map[string]bool, len(s.Teams))
+	for _, name := range s.Teams {
+		current[name] = true
+	}
+	wanted := make(map[string]bool, len(teamNames))
+	diff := TeamsDiff{Added: []string{}, Removed: []string{}}
+	for _, name := range teamNames {
+		wanted[name] = true
+		if !current[name] {
+			diff.Added = append(diff.Added, name)
 		}
-	}()
-	if s.Name == "" {
-		return fmt.Errorf("Service id is required")
 	}
-	if !skipName && !validation.ValidateName(s.Name) {
-		return fmt.Errorf("Invalid service id, should have at most 63 " +
-			"characters, containing only lower case letters, numbers or dashes, " +
+	for _, name := range s.Teams {
+		if !wanted[name] {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+	if len(diff.Added) == 0 && len(diff.Removed) == 0 {
+		return &diff, nil
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	query := bson.M{"_id": s.Name, "teams": s.Teams}
+	if len(s.Teams) == 0 {
+		query["teams"] = bson.M{"$in": []interface{}{nil, []string{}}}
+	}
+	err = conn.Services().Update(query, bson.M{"$set": bson.M{"teams": teamNames}})
+	if err == mgo.ErrNotFound {
+		return nil, ErrServiceTeamsChanged
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.Teams = teamNames
+	return &diff, nil
+}
+
+func uniqueSortedStrings(values []string) []string {
+	set := make(map[string]struct{}, len(values))
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if _, ok := set[v]; ok || v == "" {
+			continue
+		}
+		set[v] = struct{}{}
+		result = append(result, v)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (s *Service) validate(skipName bool) error {
+	verr := &tsuruErrors.ValidationError{}
+	if s.Name == "" {
+		verr.Add("id", "Service id is required")
+	} else if !skipName && !validation.ValidateName(s.Name) {
+		verr.Add("id", "Invalid service id, should have at most 63 "+
+			"characters, containing only lower case letters, numbers or dashes, "+
 			"starting with a letter.")
 	}
 	if s.Password == "" {
-		return fmt.Errorf("Service password is required")
+		verr.Add("password", "Service password is required")
 	}
 	if endpoint, ok := s.Endpoint["production"]; !ok || endpoint == "" {
-		return fmt.Errorf("Service production endpoint is required")
+		verr.Add("endpoint", "Service production endpoint is required")
 	}
-	return s.validateOwnerTeams()
+	if err := s.validateOwnerTeams(); err != nil {
+		verr.Add("team", err.Error())
+	}
+	if s.Timeout < 0 {
+		verr.Add("timeout", "Service timeout must not be negative")
+	}
+	if s.Retries < 0 || s.Retries > maxRequestRetries {
+		verr.Add("retries", fmt.Sprintf("Service retries must be between 0 and %d", maxRequestRetries))
+	}
+	return verr.ToError()
 }
 
 func (s *Service) validateOwnerTeams() error {
 	if len(s.OwnerTeams) == 0 {
-		return fmt.Errorf("At least one service team owner is required")
+		return errors.New("At least one service team owner is required")
 	}
 	teams, err := auth.TeamService().FindByNames(s.OwnerTeams)
 	if err != nil {
 		return nil
 	}
 	if len(teams) != len(s.OwnerTeams) {
-		return fmt.Errorf("Team owner doesn't exist")
+		return errors.New("Team owner doesn't exist")
 	}
 	return nil
 }
@@ -204,6 +358,23 @@ func GetServicesByTeamsAndServices(teams []string, services []string) ([]Service
 	return GetServicesByFilter(filter)
 }
 
+// GetServicesGrantedToTeamsAndServices is like GetServicesByTeamsAndServices,
+// but leaves out unrestricted services that aren't granted to nor owned by any
+// of the teams.
+func GetServicesGrantedToTeamsAndServices(teams []string, services []string) ([]Service, error) {
+	var filter bson.M
+	if teams != nil || services != nil {
+		filter = bson.M{
+			"$or": []bson.M{
+				{"teams": bson.M{"$in": teams}},
+				{"owner_teams": bson.M{"$in": teams}},
+				{"_id": bson.M{"$in": services}},
+			},
+		}
+	}
+	return GetServicesByFilter(filter)
+}
+
 func GetServicesByOwnerTeamsAndServices(teams []string, services []string) ([]Service, error) {
 	var filter bson.M
 	if teams != nil || services != nil {
@@ -248,7 +419,7 @@ func RenameServiceTeam(oldName, newName string) error {
 	fields := []string{"owner_teams", "teams"}
 	bulk := conn.Services().Bulk()
 	for _, f := range fields {
-		bulk.UpdateAll(bson.M{f: oldName}, bson.M{"$push": bson.M{f: newName}})
+		bulk.UpdateAll(bson.M{f: oldName}, bson.M{"$addToSet": bson.M{f: newName}})
 		bulk.UpdateAll(bson.M{f: oldName}, bson.M{"$pull": bson.M{f: oldName}})
 	}
 	_, err = bulk.Run()
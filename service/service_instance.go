@@ -8,24 +8,29 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/tsuru/tsuru/action"
 	"github.com/tsuru/tsuru/app/bind"
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/db"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/naming"
+	"github.com/tsuru/tsuru/secret"
 	authTypes "github.com/tsuru/tsuru/types/auth"
+	"github.com/tsuru/tsuru/validation"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
 
 var (
 	ErrServiceInstanceNotFound   = errors.New("service instance not found")
-	ErrInvalidInstanceName       = errors.New("invalid service instance name")
+	ErrInvalidInstanceName       = errors.New("Invalid service instance name, the name should have at most 63 characters, containing only lower case letters, numbers or dashes, starting with a letter.")
 	ErrInstanceNameAlreadyExists = errors.New("instance name already exists.")
 	ErrAccessNotAllowed          = errors.New("user does not have access to this service instance")
 	ErrTeamMandatory             = errors.New("please specify the team that owns the service instance")
@@ -33,7 +38,9 @@ var (
 	ErrAppNotBound               = errors.New("app is not bound to this service instance")
 	ErrUnitNotBound              = errors.New("unit is not bound to this service instance")
 	ErrServiceInstanceBound      = errors.New("This service instance is bound to at least one app. Unbind them before removing it")
-	instanceNameRegexp           = regexp.MustCompile(`^[A-Za-z][-a-zA-Z0-9_]+$`)
+	errTeamOwnerNotFound         = errors.New("Team owner doesn't exist")
+
+	bindReadyPollInterval = 2 * time.Second
 )
 
 type ServiceInstance struct {
@@ -47,6 +54,35 @@ type ServiceInstance struct {
 	TeamOwner   string
 	Description string
 	Tags        []string
+	Project     string `bson:",omitempty" json:",omitempty"`
+
+	CallbackToken   string                    `bson:"callback_token,omitempty" json:"-"`
+	ProvisionStatus *InstanceStatus           `bson:"provision_status,omitempty" json:",omitempty"`
+	BindStatus      map[string]InstanceStatus `bson:"bind_status,omitempty" json:",omitempty"`
+}
+
+// GetBSON encrypts the callback token before storing it, when
+// secrets:encrypt-storage is enabled. The token is also the key of signed
+// callbacks, so it can't be hashed.
+func (si ServiceInstance) GetBSON() (interface{}, error) {
+	type plain ServiceInstance
+	token, err := secret.Seal(si.CallbackToken)
+	if err != nil {
+		return nil, err
+	}
+	si.CallbackToken = token
+	return plain(si), nil
+}
+
+// SetBSON decrypts the callback token read from the storage.
+func (si *ServiceInstance) SetBSON(raw bson.Raw) error {
+	type plain ServiceInstance
+	err := raw.Unmarshal((*plain)(si))
+	if err != nil {
+		return err
+	}
+	si.CallbackToken, err = secret.Open(si.CallbackToken)
+	return err
 }
 
 type Unit struct {
@@ -75,7 +111,12 @@ func DeleteInstance(si *ServiceInstance, requestID string) error {
 		return err
 	}
 	defer conn.Close()
-	return conn.ServiceInstances().Remove(bson.M{"name": si.Name, "service_name": si.ServiceName})
+	err = conn.ServiceInstances().Remove(bson.M{"name": si.Name, "service_name": si.ServiceName})
+	if err != nil {
+		return err
+	}
+	notifyWebhook(si, WebhookEventRemove, "")
+	return nil
 }
 
 func (si *ServiceInstance) GetIdentifier() string {
@@ -120,6 +161,30 @@ func (si *ServiceInstance) Info(requestID string) (map[string]string, error) {
 	return info, nil
 }
 
+// InstanceMetric is a usage metric of a service instance reported by the
+// service API, like the number of open connections or the storage used.
+type InstanceMetric struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit,omitempty"`
+}
+
+// Metrics returns the usage metrics of the instance reported by the service
+// API, sorted by name. ErrMetricsNotSupported is returned when the service
+// API doesn't report metrics.
+func (si *ServiceInstance) Metrics(requestID string) ([]InstanceMetric, error) {
+	endpoint, err := si.Service().getClient("production")
+	if err != nil {
+		return nil, err
+	}
+	metrics, err := endpoint.Metrics(si, requestID)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Name < metrics[j].Name })
+	return metrics, nil
+}
+
 func (si *ServiceInstance) Service() *Service {
 	conn, err := db.Conn()
 	if err != nil {
@@ -189,7 +254,55 @@ func (si *ServiceInstance) BindApp(app bind.App, shouldRestart bool, writer io.W
 		bindUnitsAction,
 	}
 	pipeline := action.NewPipeline(actions...)
-	return pipeline.Execute(&args)
+	err := pipeline.Execute(&args)
+	if err != nil {
+		return err
+	}
+	notifyWebhook(si, WebhookEventBind, app.GetName())
+	return nil
+}
+
+// BindAppWaitReady makes the bind between the service instance and an app
+// like BindApp. When the service API reports the instance as not ready, the
+// instance status is polled and the bind is retried once the instance is up,
+// giving up with ErrInstanceNotReady after timeout.
+func (si *ServiceInstance) BindAppWaitReady(app bind.App, shouldRestart bool, writer io.Writer, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := si.BindApp(app, shouldRestart, writer)
+		if err != ErrInstanceNotReady || timeout <= 0 {
+			return err
+		}
+		fmt.Fprintf(writer, "Instance %q is not ready yet, waiting...\n", si.Name)
+		err = si.waitReady(deadline)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// waitReady polls the status of the instance until it's up or the deadline
+// is reached. Services not implementing the status endpoint are considered
+// ready on every poll, leaving the service API to decide on the next bind.
+func (si *ServiceInstance) waitReady(deadline time.Time) error {
+	for {
+		if time.Now().Add(bindReadyPollInterval).After(deadline) {
+			return ErrInstanceNotReady
+		}
+		time.Sleep(bindReadyPollInterval)
+		instance, err := GetServiceInstance(si.ServiceName, si.Name)
+		if err != nil {
+			return err
+		}
+		status, err := instance.Status("")
+		if err != nil {
+			log.Errorf("[bind wait] unable to get status of instance %q: %s", si.Name, err)
+			continue
+		}
+		if status == "up" || status == "not implemented for this service" {
+			return nil
+		}
+	}
 }
 
 // BindUnit makes the bind between the binder and an unit.
@@ -257,7 +370,12 @@ func (si *ServiceInstance) UnbindApp(app bind.App, shouldRestart bool, writer io
 		&removeBoundEnvs,
 	}
 	pipeline := action.NewPipeline(actions...)
-	return pipeline.Execute(&args)
+	err := pipeline.Execute(&args)
+	if err != nil {
+		return err
+	}
+	notifyWebhook(si, WebhookEventUnbind, app.GetName())
+	return nil
 }
 
 // UnbindUnit makes the unbind between the service instance and an unit.
@@ -307,8 +425,79 @@ func (si *ServiceInstance) UnbindUnit(app bind.App, unit bind.Unit) error {
 	return nil
 }
 
-// Status returns the service instance status.
+// RotateCredentials asks the service API for fresh credentials for each of
+// the given apps bound to the instance and replaces the environment variables
+// of the instance in the apps. The service revokes the previous credentials
+// when it returns new ones, so the variables of each app are stored as soon as
+// its credentials are rotated. If rotating fails for an app, the apps already
+// rotated keep their new credentials and are still restarted.
+func (si *ServiceInstance) RotateCredentials(apps []bind.App, shouldRestart bool, writer io.Writer) error {
+	for _, app := range apps {
+		if si.FindApp(app.GetName()) == -1 {
+			return ErrAppNotBound
+		}
+	}
+	endpoint, err := si.Service().getClient("production")
+	if err != nil {
+		return err
+	}
+	rotated := make([]bind.App, 0, len(apps))
+	for _, app := range apps {
+		var envMap map[string]string
+		envMap, err = endpoint.RotateCredentials(si, app)
+		if err != nil {
+			break
+		}
+		err = app.AddInstance(bind.AddInstanceArgs{Envs: si.boundEnvs(envMap)})
+		if err != nil {
+			err = errors.Wrapf(err, "unable to store the rotated credentials of app %q", app.GetName())
+			break
+		}
+		rotated = append(rotated, app)
+	}
+	if !shouldRestart {
+		return err
+	}
+	for _, app := range rotated {
+		if writer != nil {
+			fmt.Fprintf(writer, "---- Rotating credentials of app %q ----\n", app.GetName())
+		}
+		restartErr := app.RestartIfUnits(writer)
+		if restartErr != nil {
+			return restartErr
+		}
+	}
+	return err
+}
+
+func (si *ServiceInstance) boundEnvs(envMap map[string]string) []bind.ServiceEnvVar {
+	envs := make([]bind.ServiceEnvVar, 0, len(envMap))
+	for k, v := range envMap {
+		envs = append(envs, bind.ServiceEnvVar{
+			ServiceName:  si.ServiceName,
+			InstanceName: si.Name,
+			EnvVar: bind.EnvVar{
+				Public: false,
+				Name:   k,
+				Value:  v,
+			},
+		})
+	}
+	sort.Slice(envs, func(i, j int) bool {
+		return envs[i].Name < envs[j].Name
+	})
+	return envs
+}
+
+// Status returns the service instance status. While the service API reports
+// through the callback that the provisioning is pending, the instance is
+// pending and the service API is not queried. Once the provisioning finishes,
+// the status is always asked to the service API, as the instance may change
+// afterwards.
 func (si *ServiceInstance) Status(requestID string) (string, error) {
+	if si.ProvisionStatus != nil && si.ProvisionStatus.Status == CallbackStatusPending {
+		return "pending", nil
+	}
 	endpoint, err := si.Service().getClient("production")
 	if err != nil {
 		return "", err
@@ -348,15 +537,30 @@ func genericServiceInstancesFilter(services interface{}, teams []string) bson.M
 }
 
 func validateServiceInstance(si ServiceInstance, s *Service) error {
-	err := validateServiceInstanceName(s.Name, si.Name)
+	verr := &tsuruErrors.ValidationError{}
+	switch err := validateServiceInstanceName(s.Name, si.Name); err {
+	case nil:
+	case ErrInvalidInstanceName:
+		verr.Add("name", err.Error())
+	default:
+		return err
+	}
+	err := verr.Merge("name", naming.Check(naming.KindServiceInstance, si.Name, si.TeamOwner))
 	if err != nil {
 		return err
 	}
-	return validateServiceInstanceTeamOwner(si)
+	switch err := validateServiceInstanceTeamOwner(si); err {
+	case nil:
+	case ErrTeamMandatory, errTeamOwnerNotFound:
+		verr.Add("team", err.Error())
+	default:
+		return err
+	}
+	return verr.ToError()
 }
 
 func validateServiceInstanceName(service, instance string) error {
-	if !instanceNameRegexp.MatchString(instance) {
+	if !validation.ValidateName(instance) {
 		return ErrInvalidInstanceName
 	}
 	conn, err := db.Conn()
@@ -381,7 +585,7 @@ func validateServiceInstanceTeamOwner(si ServiceInstance) error {
 	}
 	_, err := auth.TeamService().FindByName(si.TeamOwner)
 	if err == authTypes.ErrTeamNotFound {
-		return fmt.Errorf("Team owner doesn't exist")
+		return errTeamOwnerNotFound
 	}
 	return err
 }
@@ -394,11 +598,45 @@ func CreateServiceInstance(instance ServiceInstance, service *Service, user *aut
 	instance.ServiceName = service.Name
 	instance.Teams = []string{instance.TeamOwner}
 	instance.Tags = processTags(instance.Tags)
-	actions := []*action.Action{&notifyCreateServiceInstance, &createServiceInstance}
+	instance.CallbackToken, err = generateCallbackToken()
+	if err != nil {
+		return err
+	}
+	actions := []*action.Action{&createServiceInstance, &notifyCreateServiceInstance}
 	pipeline := action.NewPipeline(actions...)
 	return pipeline.Execute(*service, instance, user.Email, requestID)
 }
 
+// CloneServiceInstance creates a new instance named name, asking the service
+// API to copy the resource backing source. Plan, description and tags are
+// copied from source, the team owner is kept unless teamOwner is set.
+func CloneServiceInstance(source ServiceInstance, name, teamOwner string, service *Service, user *auth.User, requestID string) error {
+	instance := ServiceInstance{
+		Name:        name,
+		ServiceName: service.Name,
+		PlanName:    source.PlanName,
+		Description: source.Description,
+		Tags:        source.Tags,
+		TeamOwner:   source.TeamOwner,
+	}
+	if teamOwner != "" {
+		instance.TeamOwner = teamOwner
+	}
+	err := validateServiceInstance(instance, service)
+	if err != nil {
+		return err
+	}
+	instance.Teams = []string{instance.TeamOwner}
+	instance.Tags = processTags(instance.Tags)
+	instance.CallbackToken, err = generateCallbackToken()
+	if err != nil {
+		return err
+	}
+	actions := []*action.Action{&createServiceInstance, &notifyCloneServiceInstance}
+	pipeline := action.NewPipeline(actions...)
+	return pipeline.Execute(*service, instance, user.Email, requestID, source)
+}
+
 func GetServiceInstancesByServices(services []Service) ([]ServiceInstance, error) {
 	var instances []ServiceInstance
 	conn, err := db.Conn()
@@ -452,6 +690,19 @@ func GetServiceInstance(serviceName string, instanceName string) (*ServiceInstan
 	return &instance, nil
 }
 
+// GetServiceInstancesByName returns the instances with the given name in
+// every service.
+func GetServiceInstancesByName(instanceName string) ([]ServiceInstance, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var instances []ServiceInstance
+	err = conn.ServiceInstances().Find(bson.M{"name": instanceName}).All(&instances)
+	return instances, err
+}
+
 func GetServiceInstancesBoundToApp(appName string) ([]ServiceInstance, error) {
 	conn, err := db.Conn()
 	if err != nil {
@@ -491,7 +742,7 @@ func RenameServiceInstanceTeam(oldName, newName string) error {
 	defer conn.Close()
 	bulk := conn.ServiceInstances().Bulk()
 	bulk.UpdateAll(bson.M{"teamowner": oldName}, bson.M{"$set": bson.M{"teamowner": newName}})
-	bulk.UpdateAll(bson.M{"teams": oldName}, bson.M{"$push": bson.M{"teams": newName}})
+	bulk.UpdateAll(bson.M{"teams": oldName}, bson.M{"$addToSet": bson.M{"teams": newName}})
 	bulk.UpdateAll(bson.M{"teams": oldName}, bson.M{"$pull": bson.M{"teams": oldName}})
 	_, err = bulk.Run()
 	return err
@@ -8,13 +8,17 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
 	"runtime"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/action"
@@ -22,6 +26,7 @@ import (
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/db/dbtest"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
 	"github.com/tsuru/tsuru/provision/provisiontest"
 	"github.com/tsuru/tsuru/router/routertest"
 	_ "github.com/tsuru/tsuru/storage/mongodb"
@@ -145,6 +150,66 @@ func (s *InstanceSuite) TestBindApp(c *check.C) {
 	c.Assert(buf.String(), check.Equals, "")
 }
 
+func (s *InstanceSuite) TestBindAppWaitReady(c *check.C) {
+	oldBindAppEndpointAction := bindAppEndpointAction
+	oldInterval := bindReadyPollInterval
+	defer func() {
+		bindAppEndpointAction = oldBindAppEndpointAction
+		bindReadyPollInterval = oldInterval
+	}()
+	bindReadyPollInterval = time.Millisecond
+	var calls int
+	bindAppEndpointAction = &action.Action{
+		Forward: func(ctx action.FWContext) (action.Result, error) {
+			calls++
+			if calls == 1 {
+				return nil, ErrInstanceNotReady
+			}
+			return nil, nil
+		},
+	}
+	si := ServiceInstance{
+		Name:            "mydb",
+		ServiceName:     "mysql",
+		ProvisionStatus: &InstanceStatus{Status: CallbackStatusReady},
+	}
+	err := s.conn.ServiceInstances().Insert(si)
+	c.Assert(err, check.IsNil)
+	a := provisiontest.NewFakeApp("myapp", "python", 1)
+	var buf bytes.Buffer
+	err = si.BindAppWaitReady(a, false, &buf, time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(calls, check.Equals, 2)
+	c.Assert(buf.String(), check.Equals, "Instance \"mydb\" is not ready yet, waiting...\n")
+}
+
+func (s *InstanceSuite) TestBindAppWaitReadyTimeout(c *check.C) {
+	oldBindAppEndpointAction := bindAppEndpointAction
+	oldInterval := bindReadyPollInterval
+	defer func() {
+		bindAppEndpointAction = oldBindAppEndpointAction
+		bindReadyPollInterval = oldInterval
+	}()
+	bindReadyPollInterval = time.Millisecond
+	bindAppEndpointAction = &action.Action{
+		Forward: func(ctx action.FWContext) (action.Result, error) {
+			return nil, ErrInstanceNotReady
+		},
+	}
+	si := ServiceInstance{
+		Name:            "mydb",
+		ServiceName:     "mysql",
+		ProvisionStatus: &InstanceStatus{Status: CallbackStatusPending},
+	}
+	err := s.conn.ServiceInstances().Insert(si)
+	c.Assert(err, check.IsNil)
+	a := provisiontest.NewFakeApp("myapp", "python", 1)
+	err = si.BindAppWaitReady(a, false, ioutil.Discard, 20*time.Millisecond)
+	c.Assert(err, check.Equals, ErrInstanceNotReady)
+	err = si.BindAppWaitReady(a, false, ioutil.Discard, 0)
+	c.Assert(err, check.Equals, ErrInstanceNotReady)
+}
+
 func (s *InstanceSuite) TestGetServiceInstancesBoundToApp(c *check.C) {
 	srvc := Service{Name: "mysql"}
 	err := s.conn.Services().Insert(&srvc)
@@ -270,6 +335,23 @@ func (s *InstanceSuite) TestAdditionalInfo(c *check.C) {
 	c.Assert(info, check.DeepEquals, expected)
 }
 
+func (s *InstanceSuite) TestMetrics(c *check.C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"name": "storage", "value": 2048, "unit": "bytes"}, {"name": "connections", "value": 3}]`))
+	}))
+	defer ts.Close()
+	srvc := Service{Name: "mysql", Endpoint: map[string]string{"production": ts.URL}, Password: "s3cr3t"}
+	err := s.conn.Services().Insert(&srvc)
+	c.Assert(err, check.IsNil)
+	si := ServiceInstance{Name: "ql", ServiceName: srvc.Name}
+	metrics, err := si.Metrics("")
+	c.Assert(err, check.IsNil)
+	c.Assert(metrics, check.DeepEquals, []InstanceMetric{
+		{Name: "connections", Value: 3},
+		{Name: "storage", Value: 2048, Unit: "bytes"},
+	})
+}
+
 func (s *InstanceSuite) TestMarshalJSON(c *check.C) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`[{"label": "key", "value": "value"}]`))
@@ -393,6 +475,50 @@ func (s *InstanceSuite) TestCreateServiceInstance(c *check.C) {
 	c.Assert(si.TeamOwner, check.Equals, s.team.Name)
 	c.Assert(si.Teams, check.DeepEquals, []string{s.team.Name})
 	c.Assert(si.Tags, check.DeepEquals, []string{"tag1", "tag2"})
+	c.Assert(si.CallbackToken, check.HasLen, 40)
+}
+
+func (s *InstanceSuite) TestCloneServiceInstance(c *check.C) {
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer ts.Close()
+	srv := Service{Name: "mongodb", Endpoint: map[string]string{"production": ts.URL}, Password: "s3cr3t"}
+	err := s.conn.Services().Insert(&srv)
+	c.Assert(err, check.IsNil)
+	source := ServiceInstance{Name: "prod", ServiceName: "mongodb", PlanName: "small", TeamOwner: s.team.Name, Description: "production db", Tags: []string{"tag1"}, Apps: []string{"myapp"}}
+	err = s.conn.ServiceInstances().Insert(&source)
+	c.Assert(err, check.IsNil)
+	err = CloneServiceInstance(source, "staging", "", &srv, s.user, "")
+	c.Assert(err, check.IsNil)
+	c.Assert(paths, check.DeepEquals, []string{"/resources/prod/clone"})
+	si, err := GetServiceInstance("mongodb", "staging")
+	c.Assert(err, check.IsNil)
+	c.Assert(si.PlanName, check.Equals, "small")
+	c.Assert(si.Description, check.Equals, "production db")
+	c.Assert(si.TeamOwner, check.Equals, s.team.Name)
+	c.Assert(si.Teams, check.DeepEquals, []string{s.team.Name})
+	c.Assert(si.Tags, check.DeepEquals, []string{"tag1"})
+	c.Assert(si.Apps, check.HasLen, 0)
+}
+
+func (s *InstanceSuite) TestCloneServiceInstanceNotSupported(c *check.C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotImplemented)
+	}))
+	defer ts.Close()
+	srv := Service{Name: "mongodb", Endpoint: map[string]string{"production": ts.URL}, Password: "s3cr3t"}
+	err := s.conn.Services().Insert(&srv)
+	c.Assert(err, check.IsNil)
+	source := ServiceInstance{Name: "prod", ServiceName: "mongodb", TeamOwner: s.team.Name}
+	err = s.conn.ServiceInstances().Insert(&source)
+	c.Assert(err, check.IsNil)
+	err = CloneServiceInstance(source, "staging", "", &srv, s.user, "")
+	c.Assert(err, check.Equals, ErrCloneNotSupported)
+	_, err = GetServiceInstance("mongodb", "staging")
+	c.Assert(err, check.Equals, ErrServiceInstanceNotFound)
 }
 
 func (s *InstanceSuite) TestCreateServiceInstanceValidatesTeamOwner(c *check.C) {
@@ -474,7 +600,9 @@ func (s *InstanceSuite) TestCreateServiceInstanceNoTeamOwner(c *check.C) {
 	c.Assert(err, check.IsNil)
 	instance := ServiceInstance{Name: "instance", PlanName: "small"}
 	err = CreateServiceInstance(instance, &srv, s.user, "")
-	c.Assert(err, check.Equals, ErrTeamMandatory)
+	c.Assert(err, check.DeepEquals, &tsuruErrors.ValidationError{
+		Fields: []tsuruErrors.FieldError{{Field: "team", Message: ErrTeamMandatory.Error()}},
+	})
 }
 
 func (s *InstanceSuite) TestCreateServiceInstanceNameShouldBeUnique(c *check.C) {
@@ -514,12 +642,15 @@ func (s *InstanceSuite) TestCreateServiceInstanceValidatesTheName(c *check.C) {
 		err   error
 	}{
 		{"my-service", nil},
-		{"my_service", nil},
-		{"MyService", nil},
 		{"a1", nil},
+		{"a", nil},
+		{strings.Repeat("a", 63), nil},
+		{strings.Repeat("a", 64), ErrInvalidInstanceName},
+		{"my_service", ErrInvalidInstanceName},
+		{"MyService", ErrInvalidInstanceName},
+		{"my.service", ErrInvalidInstanceName},
 		{"--app", ErrInvalidInstanceName},
 		{"123servico", ErrInvalidInstanceName},
-		{"a", ErrInvalidInstanceName},
 		{"a@123", ErrInvalidInstanceName},
 	}
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -532,7 +663,12 @@ func (s *InstanceSuite) TestCreateServiceInstanceValidatesTheName(c *check.C) {
 	for _, t := range tests {
 		instance := ServiceInstance{Name: t.input, TeamOwner: s.team.Name}
 		err := CreateServiceInstance(instance, &srv, s.user, "")
-		c.Check(err, check.Equals, t.err, check.Commentf(t.input))
+		if t.err == nil {
+			c.Check(err, check.IsNil, check.Commentf(t.input))
+		} else {
+			c.Check(err, check.ErrorMatches, regexp.QuoteMeta(t.err.Error()), check.Commentf(t.input))
+			c.Check(tsuruErrors.IsValidation(err), check.Equals, true, check.Commentf(t.input))
+		}
 	}
 }
 
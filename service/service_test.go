@@ -9,8 +9,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"sort"
+	"time"
 
+	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/auth"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
 	authTypes "github.com/tsuru/tsuru/types/auth"
 
 	"gopkg.in/check.v1"
@@ -22,6 +25,24 @@ func (s *S) createService() {
 	s.service.Create()
 }
 
+func (s *S) TestServiceBSONEncryptsPassword(c *check.C) {
+	config.Set("secrets:key", "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	config.Set("secrets:encrypt-storage", true)
+	defer config.Unset("secrets")
+	data, err := bson.Marshal(Service{Name: "mysql", Password: "s3cr3t"})
+	c.Assert(err, check.IsNil)
+	var raw bson.M
+	err = bson.Unmarshal(data, &raw)
+	c.Assert(err, check.IsNil)
+	c.Assert(raw["_id"], check.Equals, "mysql")
+	c.Assert(raw["password"], check.Matches, `enc:v1:.+`)
+	var result Service
+	err = bson.Unmarshal(data, &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Name, check.Equals, "mysql")
+	c.Assert(result.Password, check.Equals, "s3cr3t")
+}
+
 func (s *S) TestGetService(c *check.C) {
 	s.createService()
 	anotherService := Service{Name: s.service.Name}
@@ -93,6 +114,22 @@ func (s *S) TestCreateServiceMissingFields(c *check.C) {
 	c.Assert(err, check.ErrorMatches, "Team owner doesn't exist")
 }
 
+func (s *S) TestCreateServiceInvalidTimeoutAndRetries(c *check.C) {
+	service := &Service{
+		Name:       "servicename",
+		Endpoint:   map[string]string{"production": "somehost.com"},
+		OwnerTeams: []string{s.team.Name},
+		Password:   "abcde",
+		Timeout:    -1,
+	}
+	err := service.Create()
+	c.Assert(err, check.ErrorMatches, "Service timeout must not be negative")
+	service.Timeout = 0
+	service.Retries = 6
+	err = service.Create()
+	c.Assert(err, check.ErrorMatches, "Service retries must be between 0 and 5")
+}
+
 func (s *S) TestDeleteService(c *check.C) {
 	s.createService()
 	err := s.service.Delete()
@@ -113,6 +150,7 @@ func (s *S) TestGetClient(c *check.C) {
 		endpoint:    endpoints["production"],
 		username:    "redis",
 		password:    "abcde",
+		timeout:     defaultRequestTimeout,
 	}
 	c.Assert(err, check.IsNil)
 	c.Assert(cli, check.DeepEquals, expected)
@@ -129,11 +167,30 @@ func (s *S) TestGetClientWithServiceUsername(c *check.C) {
 		endpoint:    endpoints["production"],
 		username:    "redis_test",
 		password:    "abcde",
+		timeout:     defaultRequestTimeout,
 	}
 	c.Assert(err, check.IsNil)
 	c.Assert(cli, check.DeepEquals, expected)
 }
 
+func (s *S) TestGetClientWithTimeoutAndRetries(c *check.C) {
+	service := Service{Name: "redis", Endpoint: map[string]string{"production": "http://mysql.api.com"}}
+	config.Set("service:timeout", 30)
+	config.Set("service:retries", 2)
+	defer config.Unset("service:timeout")
+	defer config.Unset("service:retries")
+	cli, err := service.getClient("production")
+	c.Assert(err, check.IsNil)
+	c.Assert(cli.timeout, check.Equals, 30*time.Second)
+	c.Assert(cli.retries, check.Equals, 2)
+	service.Timeout = 600
+	service.Retries = 3
+	cli, err = service.getClient("production")
+	c.Assert(err, check.IsNil)
+	c.Assert(cli.timeout, check.Equals, 10*time.Minute)
+	c.Assert(cli.retries, check.Equals, 3)
+}
+
 func (s *S) TestGetClientWithouHTTP(c *check.C) {
 	endpoints := map[string]string{
 		"production": "mysql.api.com",
@@ -204,6 +261,55 @@ func (s *S) TestRevokeAcessShouldReturnErrorIfTheTeamDoesNotHaveAccessToTheServi
 	c.Assert(err, check.ErrorMatches, "^This team does not have access to this service$")
 }
 
+func (s *S) TestSetTeams(c *check.C) {
+	for _, name := range []string{"team1", "team2", "team3"} {
+		err := auth.TeamService().Insert(authTypes.Team{Name: name})
+		c.Assert(err, check.IsNil)
+	}
+	srv := Service{Name: "mysql"}
+	err := s.conn.Services().Insert(&srv)
+	c.Assert(err, check.IsNil)
+	diff, err := srv.SetTeams([]string{"team2", "team1", "team2", ""})
+	c.Assert(err, check.IsNil)
+	c.Assert(diff, check.DeepEquals, &TeamsDiff{Added: []string{"team1", "team2"}, Removed: []string{}})
+	c.Assert(srv.Teams, check.DeepEquals, []string{"team1", "team2"})
+	diff, err = srv.SetTeams([]string{"team3", "team2"})
+	c.Assert(err, check.IsNil)
+	c.Assert(diff, check.DeepEquals, &TeamsDiff{Added: []string{"team3"}, Removed: []string{"team1"}})
+	dbSrv := Service{Name: "mysql"}
+	err = dbSrv.Get()
+	c.Assert(err, check.IsNil)
+	c.Assert(dbSrv.Teams, check.DeepEquals, []string{"team2", "team3"})
+	diff, err = dbSrv.SetTeams([]string{"team2", "team3"})
+	c.Assert(err, check.IsNil)
+	c.Assert(diff, check.DeepEquals, &TeamsDiff{Added: []string{}, Removed: []string{}})
+}
+
+func (s *S) TestSetTeamsTeamNotFound(c *check.C) {
+	srv := Service{Name: "mysql", Teams: []string{s.team.Name}}
+	err := s.conn.Services().Insert(&srv)
+	c.Assert(err, check.IsNil)
+	_, err = srv.SetTeams([]string{s.team.Name, "ghost", "zombie"})
+	c.Assert(err, check.ErrorMatches, "teams not found: ghost, zombie")
+	c.Assert(tsuruErrors.IsValidation(err), check.Equals, true)
+	dbSrv := Service{Name: "mysql"}
+	err = dbSrv.Get()
+	c.Assert(err, check.IsNil)
+	c.Assert(dbSrv.Teams, check.DeepEquals, []string{s.team.Name})
+}
+
+func (s *S) TestSetTeamsConcurrentChange(c *check.C) {
+	err := auth.TeamService().Insert(authTypes.Team{Name: "team1"})
+	c.Assert(err, check.IsNil)
+	srv := Service{Name: "mysql", Teams: []string{s.team.Name}}
+	err = s.conn.Services().Insert(&srv)
+	c.Assert(err, check.IsNil)
+	err = s.conn.Services().UpdateId("mysql", bson.M{"$push": bson.M{"teams": "team1"}})
+	c.Assert(err, check.IsNil)
+	_, err = srv.SetTeams([]string{"team1"})
+	c.Assert(err, check.Equals, ErrServiceTeamsChanged)
+}
+
 func (s *S) TestGetServicesNames(c *check.C) {
 	s1 := Service{Name: "Foo"}
 	s2 := Service{Name: "Bar"}
@@ -263,6 +369,42 @@ func (s *S) TestGetServicesByOwnerTeamsAndServices(c *check.C) {
 	c.Assert(services, check.DeepEquals, expected)
 }
 
+func (s *S) TestGetServicesGrantedToTeamsAndServices(c *check.C) {
+	granted := Service{
+		Name:       "mysql",
+		OwnerTeams: []string{"other-team"},
+		Endpoint:   map[string]string{"production": "url"},
+		Teams:      []string{s.team.Name},
+		Password:   "abcde",
+	}
+	err := granted.Create()
+	c.Assert(err, check.IsNil)
+	owned := Service{
+		Name:       "mongodb",
+		OwnerTeams: []string{s.team.Name},
+		Endpoint:   map[string]string{"production": "url"},
+		Teams:      []string{},
+		Password:   "abcde",
+	}
+	err = owned.Create()
+	c.Assert(err, check.IsNil)
+	unrestricted := Service{
+		Name:       "redis",
+		OwnerTeams: []string{"other-team"},
+		Endpoint:   map[string]string{"production": "url"},
+		Teams:      []string{},
+		Password:   "abcde",
+	}
+	err = unrestricted.Create()
+	c.Assert(err, check.IsNil)
+	services, err := GetServicesGrantedToTeamsAndServices([]string{s.team.Name}, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(services, check.DeepEquals, []Service{granted, owned})
+	services, err = GetServicesByTeamsAndServices([]string{s.team.Name}, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(services, check.HasLen, 3)
+}
+
 func (s *S) TestGetServicesByOwnerTeamsAndServicesWithServices(c *check.C) {
 	srvc := Service{
 		Name:       "mongodb",
@@ -0,0 +1,222 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package servicetest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FakeServiceAPI is an in-memory service API, implementing the contract
+// checked by ServiceAPISuite. Use it with net/http/httptest:
+//
+//	api := servicetest.NewFakeServiceAPI("mysql", "secret")
+//	server := httptest.NewServer(api)
+//	defer server.Close()
+type FakeServiceAPI struct {
+	// Plans are returned by GET /resources/plans.
+	Plans []map[string]string
+
+	username  string
+	password  string
+	mu        sync.Mutex
+	instances map[string]*fakeInstance
+}
+
+type fakeInstance struct {
+	team  string
+	plan  string
+	apps  map[string]bool
+	units map[string]bool
+}
+
+// NewFakeServiceAPI returns a service API requiring the given credentials.
+func NewFakeServiceAPI(username, password string) *FakeServiceAPI {
+	return &FakeServiceAPI{
+		username:  username,
+		password:  password,
+		instances: make(map[string]*fakeInstance),
+	}
+}
+
+// Instances returns the names of the instances in the API.
+func (api *FakeServiceAPI) Instances() []string {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	names := make([]string, 0, len(api.instances))
+	for name := range api.instances {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BoundApps returns the hosts of the apps bound to the instance.
+func (api *FakeServiceAPI) BoundApps(instance string) []string {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	return api.bindings(instance, func(i *fakeInstance) map[string]bool { return i.apps })
+}
+
+// BoundUnits returns the hosts of the units bound to the instance.
+func (api *FakeServiceAPI) BoundUnits(instance string) []string {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	return api.bindings(instance, func(i *fakeInstance) map[string]bool { return i.units })
+}
+
+func (api *FakeServiceAPI) bindings(instance string, get func(*fakeInstance) map[string]bool) []string {
+	i, ok := api.instances[instance]
+	if !ok {
+		return nil
+	}
+	var hosts []string
+	for host := range get(i) {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+func (api *FakeServiceAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	username, password, ok := r.BasicAuth()
+	if !ok || username != api.username || password != api.password {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	r.ParseForm()
+	if r.Method == "DELETE" {
+		// the body of DELETE requests isn't parsed by net/http, but tsuru
+		// sends the app and unit hosts in it
+		body, _ := ioutil.ReadAll(r.Body)
+		values, _ := url.ParseQuery(string(body))
+		for k, v := range values {
+			r.Form[k] = append(r.Form[k], v...)
+		}
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) == 0 || parts[0] != "resources" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	switch {
+	case len(parts) == 1 && r.Method == "POST":
+		api.create(w, r)
+	case len(parts) == 2 && parts[1] == "plans" && r.Method == "GET":
+		api.plans(w)
+	case len(parts) == 2:
+		api.handleInstance(w, r, parts[1])
+	case len(parts) == 3 && parts[2] == "status" && r.Method == "GET":
+		api.status(w, parts[1])
+	case len(parts) == 3 && parts[2] == "bind-app":
+		api.handleAppBind(w, r, parts[1])
+	case len(parts) == 3 && parts[2] == "bind":
+		api.handleUnitBind(w, r, parts[1])
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (api *FakeServiceAPI) create(w http.ResponseWriter, r *http.Request) {
+	name := r.FormValue("name")
+	if name == "" {
+		http.Error(w, "missing instance name", http.StatusBadRequest)
+		return
+	}
+	if _, ok := api.instances[name]; ok {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+	api.instances[name] = &fakeInstance{
+		team:  r.FormValue("team"),
+		plan:  r.FormValue("plan"),
+		apps:  make(map[string]bool),
+		units: make(map[string]bool),
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (api *FakeServiceAPI) plans(w http.ResponseWriter) {
+	plans := api.Plans
+	if plans == nil {
+		plans = []map[string]string{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(plans)
+}
+
+func (api *FakeServiceAPI) handleInstance(w http.ResponseWriter, r *http.Request, name string) {
+	instance, ok := api.instances[name]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case "GET":
+		info := []map[string]string{{"label": "Plan", "value": instance.plan}}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(info)
+	case "PUT":
+		instance.team = r.FormValue("team")
+		instance.plan = r.FormValue("plan")
+	case "DELETE":
+		delete(api.instances, name)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (api *FakeServiceAPI) status(w http.ResponseWriter, name string) {
+	if _, ok := api.instances[name]; !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (api *FakeServiceAPI) handleAppBind(w http.ResponseWriter, r *http.Request, name string) {
+	instance, ok := api.instances[name]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	host := r.FormValue("app-host")
+	switch r.Method {
+	case "POST":
+		instance.apps[host] = true
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"SERVICETEST_INSTANCE": name})
+	case "DELETE":
+		delete(instance.apps, host)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (api *FakeServiceAPI) handleUnitBind(w http.ResponseWriter, r *http.Request, name string) {
+	instance, ok := api.instances[name]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	host := r.FormValue("unit-host")
+	switch r.Method {
+	case "POST":
+		instance.units[host] = true
+		w.WriteHeader(http.StatusCreated)
+	case "DELETE":
+		delete(instance.units, host)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,295 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package servicetest helps authors of service APIs to check whether their
+// APIs comply with the contract tsuru relies on, without a tsuru
+// installation. ServiceAPISuite plays the role of tsuru, creating, binding,
+// unbinding and removing instances just like tsuru does:
+//
+//	var _ = check.Suite(&servicetest.ServiceAPISuite{
+//		Endpoint: "http://localhost:8080",
+//		Service:  "mysql",
+//		Password: "secret",
+//	})
+//
+// FakeServiceAPI is an in-memory service API complying with the contract,
+// useful in tests of code calling service APIs.
+package servicetest
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/service"
+	"gopkg.in/check.v1"
+)
+
+const (
+	defaultServiceName  = "servicetest"
+	defaultReadyTimeout = time.Minute
+	readyPollInterval   = 500 * time.Millisecond
+)
+
+var instanceCounter int64
+
+// ServiceAPISuite is a gocheck suite checking the contract of the service
+// API available at Endpoint. Every test creates its own instances, removing
+// them at the end of the test.
+type ServiceAPISuite struct {
+	// Endpoint is the address of the service API.
+	Endpoint string
+	// Service is the name of the service, also used as the username when
+	// Username is empty. Defaults to "servicetest".
+	Service  string
+	Username string
+	Password string
+	// Plan is the plan of the instances created by the suite, if any.
+	Plan string
+	// Team is the team owning the instances created by the suite.
+	Team string
+	// ReadyTimeout is how long to wait for new instances to be up, defaults
+	// to one minute.
+	ReadyTimeout time.Duration
+
+	SetUpSuiteFunc    func(c *check.C)
+	SetUpTestFunc     func(c *check.C)
+	TearDownSuiteFunc func(c *check.C)
+	TearDownTestFunc  func(c *check.C)
+
+	client    *service.Client
+	instances []*service.ServiceInstance
+}
+
+func (s *ServiceAPISuite) SetUpSuite(c *check.C) {
+	if s.SetUpSuiteFunc != nil {
+		s.SetUpSuiteFunc(c)
+	}
+	if s.Service == "" {
+		s.Service = defaultServiceName
+	}
+	if s.Team == "" {
+		s.Team = defaultServiceName
+	}
+	if s.ReadyTimeout == 0 {
+		s.ReadyTimeout = defaultReadyTimeout
+	}
+	svc := service.Service{Name: s.Service, Username: s.Username}
+	s.client = service.NewClient(s.Service, s.Endpoint, svc.GetUsername(), s.Password)
+}
+
+func (s *ServiceAPISuite) SetUpTest(c *check.C) {
+	if s.SetUpTestFunc != nil {
+		s.SetUpTestFunc(c)
+	}
+	c.Logf("service API contract test for %s", s.Endpoint)
+}
+
+func (s *ServiceAPISuite) TearDownTest(c *check.C) {
+	for _, instance := range s.instances {
+		err := s.client.Destroy(instance, "")
+		if err != nil && err != service.ErrInstanceNotFoundInAPI {
+			c.Logf("unable to remove instance %q: %s", instance.Name, err)
+		}
+	}
+	s.instances = nil
+	if s.TearDownTestFunc != nil {
+		s.TearDownTestFunc(c)
+	}
+}
+
+func (s *ServiceAPISuite) TearDownSuite(c *check.C) {
+	if s.TearDownSuiteFunc != nil {
+		s.TearDownSuiteFunc(c)
+	}
+}
+
+func (s *ServiceAPISuite) newInstance() *service.ServiceInstance {
+	n := atomic.AddInt64(&instanceCounter, 1)
+	return &service.ServiceInstance{
+		Name:        fmt.Sprintf("servicetest-%d-%d", time.Now().Unix(), n),
+		ServiceName: s.Service,
+		PlanName:    s.Plan,
+		TeamOwner:   s.Team,
+		Description: "instance created by the service API contract tests",
+	}
+}
+
+// createInstance creates an instance and waits for it to be ready.
+func (s *ServiceAPISuite) createInstance(c *check.C) *service.ServiceInstance {
+	instance := s.newInstance()
+	err := s.client.Create(instance, "servicetest@tsuru.io", "")
+	c.Assert(err, check.IsNil)
+	s.instances = append(s.instances, instance)
+	s.waitReady(c, instance)
+	return instance
+}
+
+func (s *ServiceAPISuite) waitReady(c *check.C, instance *service.ServiceInstance) {
+	deadline := time.Now().Add(s.ReadyTimeout)
+	for {
+		status, err := s.client.Status(instance, "")
+		c.Assert(err, check.IsNil)
+		if status == "up" || status == "not implemented for this service" {
+			return
+		}
+		if time.Now().After(deadline) {
+			c.Fatalf("instance %q not ready after %s, last status: %q", instance.Name, s.ReadyTimeout, status)
+		}
+		time.Sleep(readyPollInterval)
+	}
+}
+
+func newFakeApp() *fakeApp {
+	n := atomic.AddInt64(&instanceCounter, 1)
+	return &fakeApp{
+		name: fmt.Sprintf("servicetest-app-%d", n),
+		units: []bind.Unit{
+			fakeUnit{id: fmt.Sprintf("unit-%d-1", n), ip: "10.10.10.1"},
+			fakeUnit{id: fmt.Sprintf("unit-%d-2", n), ip: "10.10.10.2"},
+		},
+	}
+}
+
+func (s *ServiceAPISuite) TestPlans(c *check.C) {
+	plans, err := s.client.Plans("")
+	c.Assert(err, check.IsNil)
+	for _, plan := range plans {
+		c.Check(plan.Name, check.Not(check.Equals), "")
+	}
+}
+
+func (s *ServiceAPISuite) TestCreateAndDestroy(c *check.C) {
+	instance := s.createInstance(c)
+	err := s.client.Destroy(instance, "")
+	c.Assert(err, check.IsNil)
+	err = s.client.Destroy(instance, "")
+	c.Assert(err, check.Equals, service.ErrInstanceNotFoundInAPI)
+}
+
+func (s *ServiceAPISuite) TestCreateDuplicated(c *check.C) {
+	instance := s.createInstance(c)
+	err := s.client.Create(instance, "servicetest@tsuru.io", "")
+	c.Assert(err, check.Equals, service.ErrInstanceAlreadyExistsInAPI)
+}
+
+func (s *ServiceAPISuite) TestUpdate(c *check.C) {
+	instance := s.createInstance(c)
+	instance.Description = "updated by the service API contract tests"
+	err := s.client.Update(instance, "")
+	c.Assert(err, check.IsNil)
+}
+
+func (s *ServiceAPISuite) TestStatus(c *check.C) {
+	instance := s.createInstance(c)
+	status, err := s.client.Status(instance, "")
+	c.Assert(err, check.IsNil)
+	c.Assert(status, check.Not(check.Equals), "")
+}
+
+func (s *ServiceAPISuite) TestBindAndUnbindApp(c *check.C) {
+	instance := s.createInstance(c)
+	app := newFakeApp()
+	envs, err := s.client.BindApp(instance, app)
+	c.Assert(err, check.IsNil)
+	c.Logf("environment variables returned by bind: %v", envNames(envs))
+	err = s.client.UnbindApp(instance, app)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *ServiceAPISuite) TestBindAndUnbindUnits(c *check.C) {
+	instance := s.createInstance(c)
+	app := newFakeApp()
+	_, err := s.client.BindApp(instance, app)
+	c.Assert(err, check.IsNil)
+	for _, unit := range app.units {
+		err = s.client.BindUnit(instance, app, unit)
+		c.Assert(err, check.IsNil)
+	}
+	for _, unit := range app.units {
+		err = s.client.UnbindUnit(instance, app, unit)
+		c.Assert(err, check.IsNil)
+	}
+	err = s.client.UnbindApp(instance, app)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *ServiceAPISuite) TestBindAppInstanceNotFound(c *check.C) {
+	_, err := s.client.BindApp(s.newInstance(), newFakeApp())
+	c.Assert(err, check.Equals, service.ErrInstanceNotFoundInAPI)
+}
+
+func (s *ServiceAPISuite) TestBindUnitInstanceNotFound(c *check.C) {
+	app := newFakeApp()
+	err := s.client.BindUnit(s.newInstance(), app, app.units[0])
+	c.Assert(err, check.Equals, service.ErrInstanceNotFoundInAPI)
+}
+
+func (s *ServiceAPISuite) TestUnbindAppInstanceNotFound(c *check.C) {
+	err := s.client.UnbindApp(s.newInstance(), newFakeApp())
+	c.Assert(err, check.Equals, service.ErrInstanceNotFoundInAPI)
+}
+
+func (s *ServiceAPISuite) TestDestroyInstanceNotFound(c *check.C) {
+	err := s.client.Destroy(s.newInstance(), "")
+	c.Assert(err, check.Equals, service.ErrInstanceNotFoundInAPI)
+}
+
+func envNames(envs map[string]string) []string {
+	names := make([]string, 0, len(envs))
+	for name := range envs {
+		names = append(names, name)
+	}
+	return names
+}
+
+type fakeUnit struct {
+	id string
+	ip string
+}
+
+func (u fakeUnit) GetID() string {
+	return u.id
+}
+
+func (u fakeUnit) GetIp() string {
+	return u.ip
+}
+
+// fakeApp is the app bound to instances by the suite. Its units are only
+// known to the service API, no environment variable is ever injected.
+type fakeApp struct {
+	name  string
+	units []bind.Unit
+}
+
+func (a *fakeApp) GetAddresses() ([]string, error) {
+	return []string{a.name + ".servicetest.tsuru.io"}, nil
+}
+
+func (a *fakeApp) GetName() string {
+	return a.name
+}
+
+func (a *fakeApp) GetUnits() ([]bind.Unit, error) {
+	return a.units, nil
+}
+
+func (a *fakeApp) GetServiceEnvs() []bind.ServiceEnvVar {
+	return nil
+}
+
+func (a *fakeApp) AddInstance(args bind.AddInstanceArgs) error {
+	return nil
+}
+
+func (a *fakeApp) RemoveInstance(args bind.RemoveInstanceArgs) error {
+	return nil
+}
+
+func (a *fakeApp) RestartIfUnits(w io.Writer) error {
+	return nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package servicetest
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tsuru/tsuru/service"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type S struct {
+	api    *FakeServiceAPI
+	server *httptest.Server
+}
+
+var _ = check.Suite(&S{})
+
+func init() {
+	api := NewFakeServiceAPI("mysql", "secret")
+	api.Plans = []map[string]string{{"name": "small", "description": "small instances"}}
+	server := httptest.NewServer(api)
+	check.Suite(&ServiceAPISuite{
+		Endpoint: server.URL,
+		Service:  "mysql",
+		Password: "secret",
+		Plan:     "small",
+		SetUpTestFunc: func(c *check.C) {
+			c.Assert(api.Instances(), check.HasLen, 0)
+		},
+		TearDownTestFunc: func(c *check.C) {
+			c.Assert(api.Instances(), check.HasLen, 0)
+		},
+	})
+}
+
+func (s *S) SetUpTest(c *check.C) {
+	s.api = NewFakeServiceAPI("mysql", "secret")
+	s.server = httptest.NewServer(s.api)
+}
+
+func (s *S) TearDownTest(c *check.C) {
+	s.server.Close()
+}
+
+func (s *S) TestFakeServiceAPIBindings(c *check.C) {
+	client := service.NewClient("mysql", s.server.URL, "mysql", "secret")
+	instance := &service.ServiceInstance{Name: "db", ServiceName: "mysql"}
+	err := client.Create(instance, "me@tsuru.io", "")
+	c.Assert(err, check.IsNil)
+	c.Assert(s.api.Instances(), check.DeepEquals, []string{"db"})
+	app := newFakeApp()
+	envs, err := client.BindApp(instance, app)
+	c.Assert(err, check.IsNil)
+	c.Assert(envs, check.DeepEquals, map[string]string{"SERVICETEST_INSTANCE": "db"})
+	err = client.BindUnit(instance, app, app.units[0])
+	c.Assert(err, check.IsNil)
+	addrs, _ := app.GetAddresses()
+	c.Assert(s.api.BoundApps("db"), check.DeepEquals, addrs)
+	c.Assert(s.api.BoundUnits("db"), check.DeepEquals, []string{"10.10.10.1"})
+	err = client.UnbindUnit(instance, app, app.units[0])
+	c.Assert(err, check.IsNil)
+	err = client.UnbindApp(instance, app)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.api.BoundApps("db"), check.HasLen, 0)
+	c.Assert(s.api.BoundUnits("db"), check.HasLen, 0)
+}
+
+func (s *S) TestFakeServiceAPIInvalidCredentials(c *check.C) {
+	client := service.NewClient("mysql", s.server.URL, "mysql", "wrong")
+	err := client.Create(&service.ServiceInstance{Name: "db", ServiceName: "mysql"}, "me@tsuru.io", "")
+	c.Assert(err, check.NotNil)
+	c.Assert(s.api.Instances(), check.HasLen, 0)
+}
@@ -0,0 +1,107 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/log"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const statusCheckConcurrency = 10
+
+// EndpointStatus describes the reachability of a service api endpoint.
+type EndpointStatus struct {
+	Environment string `json:"environment"`
+	Reachable   bool   `json:"reachable"`
+	LatencyMS   int64  `json:"latency_ms"`
+	Error       string `json:"error,omitempty"`
+}
+
+// ProviderStatus describes the health of a service provider, the reachability
+// of each of its endpoints and the number of instances in each state, as
+// reported by the service api.
+type ProviderStatus struct {
+	Service   string           `json:"service"`
+	Endpoints []EndpointStatus `json:"endpoints"`
+	Instances map[string]int   `json:"instances"`
+}
+
+// ProviderStatus pings every endpoint of the service and collects the status
+// of its instances. Instances whose status can't be retrieved are counted in
+// the "error" state.
+func (s *Service) ProviderStatus(requestID string) (*ProviderStatus, error) {
+	status := ProviderStatus{
+		Service:   s.Name,
+		Endpoints: []EndpointStatus{},
+		Instances: map[string]int{},
+	}
+	envs := make([]string, 0, len(s.Endpoint))
+	for env := range s.Endpoint {
+		envs = append(envs, env)
+	}
+	sort.Strings(envs)
+	for _, env := range envs {
+		endpointStatus := EndpointStatus{Environment: env}
+		endpoint, err := s.getClient(env)
+		if err == nil {
+			var latency time.Duration
+			latency, err = endpoint.Ping(requestID)
+			endpointStatus.LatencyMS = int64(latency / time.Millisecond)
+		}
+		if err != nil {
+			endpointStatus.Error = err.Error()
+		} else {
+			endpointStatus.Reachable = true
+		}
+		status.Endpoints = append(status.Endpoints, endpointStatus)
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var instances []ServiceInstance
+	err = conn.ServiceInstances().Find(bson.M{"service_name": s.Name}).All(&instances)
+	if err != nil {
+		return nil, err
+	}
+	endpoint, err := s.getClient("production")
+	if err != nil {
+		if len(instances) > 0 {
+			status.Instances["error"] = len(instances)
+		}
+		return &status, nil
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, statusCheckConcurrency)
+	for i := range instances {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(si *ServiceInstance) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			state, err := endpoint.Status(si, requestID)
+			if err != nil {
+				log.Errorf("[service status] unable to get status of instance %q of service %q: %s", si.Name, s.Name, err)
+				state = "error"
+			}
+			state = strings.TrimSpace(state)
+			mu.Lock()
+			status.Instances[state]++
+			mu.Unlock()
+		}(&instances[i])
+	}
+	wg.Wait()
+	return &status, nil
+}
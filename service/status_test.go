@@ -0,0 +1,92 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestClientPing(c *check.C) {
+	var path string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Write([]byte(`[]`))
+	}))
+	defer ts.Close()
+	client := &Client{endpoint: ts.URL, username: "user", password: "abcde"}
+	_, err := client.Ping("")
+	c.Assert(err, check.IsNil)
+	c.Assert(path, check.Equals, "/resources/plans")
+}
+
+func (s *S) TestClientPingServerError(c *check.C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte("bad gateway"))
+	}))
+	defer ts.Close()
+	client := &Client{endpoint: ts.URL, username: "user", password: "abcde"}
+	_, err := client.Ping("")
+	c.Assert(err, check.ErrorMatches, `invalid response: bad gateway \(code: 502\)`)
+}
+
+func (s *S) TestServiceProviderStatus(c *check.C) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/resources/plans":
+			w.Write([]byte(`[]`))
+		case strings.HasPrefix(r.URL.Path, "/resources/db-down"):
+			w.WriteHeader(http.StatusInternalServerError)
+		case strings.HasPrefix(r.URL.Path, "/resources/db-pending"):
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer ts.Close()
+	srvc := Service{
+		Name: "mysql",
+		Endpoint: map[string]string{
+			"production": ts.URL,
+			"test":       "http://127.0.0.1:1",
+		},
+		Password: "abcde",
+	}
+	for _, name := range []string{"db-up1", "db-up2", "db-down", "db-pending"} {
+		err := s.conn.ServiceInstances().Insert(ServiceInstance{Name: name, ServiceName: "mysql"})
+		c.Assert(err, check.IsNil)
+	}
+	err := s.conn.ServiceInstances().Insert(ServiceInstance{Name: "other", ServiceName: "redis"})
+	c.Assert(err, check.IsNil)
+	status, err := srvc.ProviderStatus("")
+	c.Assert(err, check.IsNil)
+	c.Assert(status.Service, check.Equals, "mysql")
+	c.Assert(status.Endpoints, check.HasLen, 2)
+	c.Assert(status.Endpoints[0].Environment, check.Equals, "production")
+	c.Assert(status.Endpoints[0].Reachable, check.Equals, true)
+	c.Assert(status.Endpoints[0].Error, check.Equals, "")
+	c.Assert(status.Endpoints[1].Environment, check.Equals, "test")
+	c.Assert(status.Endpoints[1].Reachable, check.Equals, false)
+	c.Assert(status.Endpoints[1].Error, check.Not(check.Equals), "")
+	c.Assert(status.Instances, check.DeepEquals, map[string]int{
+		"up":      2,
+		"down":    1,
+		"pending": 1,
+	})
+}
+
+func (s *S) TestServiceProviderStatusWithoutProductionEndpoint(c *check.C) {
+	srvc := Service{Name: "mysql", Endpoint: map[string]string{}}
+	err := s.conn.ServiceInstances().Insert(ServiceInstance{Name: "db1", ServiceName: "mysql"})
+	c.Assert(err, check.IsNil)
+	status, err := srvc.ProviderStatus("")
+	c.Assert(err, check.IsNil)
+	c.Assert(status.Endpoints, check.DeepEquals, []EndpointStatus{})
+	c.Assert(status.Instances, check.DeepEquals, map[string]int{"error": 1})
+}
@@ -52,6 +52,8 @@ func (s *S) SetUpSuite(c *check.C) {
 	config.Set("log:disable-syslog", true)
 	config.Set("database:url", "127.0.0.1:27017")
 	config.Set("database:name", "tsuru_service_test")
+	config.Set("queue:mongo-url", "127.0.0.1:27017")
+	config.Set("queue:mongo-database", "tsuru_service_test_queue")
 	s.conn, err = db.Conn()
 	c.Assert(err, check.IsNil)
 }
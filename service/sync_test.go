@@ -21,6 +21,7 @@ import (
 	"github.com/tsuru/tsuru/auth/native"
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/db/dbtest"
+	"github.com/tsuru/tsuru/db/dbtest/fixture"
 	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/event/eventtest"
 	"github.com/tsuru/tsuru/log"
@@ -33,9 +34,10 @@ import (
 )
 
 type SyncSuite struct {
-	conn *db.Storage
-	user auth.User
-	team authTypes.Team
+	conn     *db.Storage
+	user     auth.User
+	team     authTypes.Team
+	snapshot *fixture.Snapshot
 }
 
 var _ = check.Suite(&SyncSuite{})
@@ -55,17 +57,23 @@ func (s *SyncSuite) SetUpTest(c *check.C) {
 	provisiontest.ProvisionerInstance.Reset()
 	routertest.FakeRouter.Reset()
 	dbtest.ClearAllCollections(s.conn.Apps().Database)
-	s.user = auth.User{Email: "sad-but-true@metallica.com"}
-	err := s.user.Create()
-	c.Assert(err, check.IsNil)
-	s.team = authTypes.Team{Name: "metallica"}
-	err = auth.TeamService().Insert(s.team)
+	d := &fixture.Dataset{
+		Users: []auth.User{{Email: "sad-but-true@metallica.com"}},
+		Teams: []authTypes.Team{{Name: "metallica"}},
+	}
+	var err error
+	s.snapshot, err = fixture.Load(d)
 	c.Assert(err, check.IsNil)
+	s.user, s.team = d.Users[0], d.Teams[0]
 	opts := pool.AddPoolOptions{Name: "pool1", Default: true, Provisioner: "fake"}
 	err = pool.AddPool(opts)
 	c.Assert(err, check.IsNil)
 }
 
+func (s *SyncSuite) TearDownTest(c *check.C) {
+	c.Assert(s.snapshot.Restore(), check.IsNil)
+}
+
 func (s *SyncSuite) TearDownSuite(c *check.C) {
 	s.conn.Apps().Database.DropDatabase()
 	s.conn.Close()
@@ -0,0 +1,223 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/db"
+	tsuruErrors "github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/queue"
+	"github.com/tsuru/tsuru/secret"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Events notified to service webhooks.
+const (
+	WebhookEventBind   = "bind"
+	WebhookEventUnbind = "unbind"
+	WebhookEventRemove = "remove"
+)
+
+var (
+	ErrWebhookNotFound = errors.New("webhook not configured for this service")
+
+	webhookClient = &http.Client{Timeout: 10 * time.Second}
+	webhookQueue  = queue.NewWorkQueue("service-webhooks", queue.WorkQueueOpts{RetryPolicy: queue.RetryAlways})
+)
+
+// Webhook is an URL notified when instances of the service are bound,
+// unbound or removed, in addition to the calls to the service API. It lets
+// the service reconcile its bookkeeping after failed API calls. Notifications
+// are signed with Secret like signed instance callbacks.
+type Webhook struct {
+	URL    string
+	Secret string
+}
+
+// GetBSON encrypts the webhook secret before storing it, when
+// secrets:encrypt-storage is enabled.
+func (h Webhook) GetBSON() (interface{}, error) {
+	type plain Webhook
+	sealed, err := secret.Seal(h.Secret)
+	if err != nil {
+		return nil, err
+	}
+	h.Secret = sealed
+	return plain(h), nil
+}
+
+// SetBSON decrypts the webhook secret read from the storage.
+func (h *Webhook) SetBSON(raw bson.Raw) error {
+	type plain Webhook
+	err := raw.Unmarshal((*plain)(h))
+	if err != nil {
+		return err
+	}
+	h.Secret, err = secret.Open(h.Secret)
+	return err
+}
+
+// WebhookNotification is the JSON body sent to service webhooks. App is
+// empty for instance removals.
+type WebhookNotification struct {
+	Event    string
+	Service  string
+	Instance string
+	App      string `json:",omitempty"`
+	Time     time.Time
+}
+
+func (n WebhookNotification) Target() string {
+	return n.Service + "/" + n.Instance
+}
+
+// SetWebhook configures the webhook of the service. A random secret is
+// generated when none is given.
+func (s *Service) SetWebhook(hook Webhook) (*Webhook, error) {
+	u, err := url.Parse(hook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, &tsuruErrors.ValidationError{Message: "webhook url must be an absolute http or https URL"}
+	}
+	if hook.Secret == "" {
+		hook.Secret, err = generateWebhookSecret()
+		if err != nil {
+			return nil, err
+		}
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	err = conn.Services().Update(bson.M{"_id": s.Name}, bson.M{"$set": bson.M{"webhook": hook}})
+	if err != nil {
+		return nil, err
+	}
+	s.Webhook = &hook
+	return &hook, nil
+}
+
+// RemoveWebhook stops the notifications sent to the webhook of the service.
+func (s *Service) RemoveWebhook() error {
+	if s.Webhook == nil {
+		return ErrWebhookNotFound
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Services().Update(bson.M{"_id": s.Name}, bson.M{"$unset": bson.M{"webhook": ""}})
+	if err != nil {
+		return err
+	}
+	s.Webhook = nil
+	return nil
+}
+
+func generateWebhookSecret() (string, error) {
+	var data [20]byte
+	_, err := rand.Read(data[:])
+	if err != nil {
+		return "", errors.Wrap(err, "unable to generate webhook secret")
+	}
+	return hex.EncodeToString(data[:]), nil
+}
+
+// InitializeWebhooks starts the delivery of the notifications enqueued for
+// service webhooks.
+func InitializeWebhooks() error {
+	return webhookQueue.Consume(deliverWebhook)
+}
+
+// notifyWebhook enqueues the event for the webhook of the instance's service,
+// if there's one. Failed deliveries are retried by the service-webhooks work
+// queue and then moved to its dead letters, they never affect the operation
+// being notified.
+func notifyWebhook(si *ServiceInstance, event, appName string) {
+	s := si.Service()
+	if s == nil || s.Webhook == nil {
+		return
+	}
+	n := WebhookNotification{
+		Event:    event,
+		Service:  si.ServiceName,
+		Instance: si.Name,
+		App:      appName,
+		Time:     time.Now().UTC(),
+	}
+	_, err := webhookQueue.Enqueue(n)
+	if err != nil {
+		log.Errorf("[service-webhook] unable to enqueue notification of %s on instance %q of service %q: %s", n.Event, n.Instance, n.Service, err)
+	}
+}
+
+// deliverWebhook posts a queued notification to the current webhook of the
+// service, so notifications aren't delivered once the webhook is removed and
+// its secret isn't stored in the queue.
+func deliverWebhook(msg *queue.Message) error {
+	var n WebhookNotification
+	err := msg.Decode(&n)
+	if err != nil {
+		return err
+	}
+	s := Service{Name: n.Service}
+	err = s.Get()
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if s.Webhook == nil {
+		return nil
+	}
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	return postWebhook(*s.Webhook, body)
+}
+
+func postWebhook(hook Webhook, body []byte) error {
+	nonce, err := generateWebhookSecret()
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(hook.Secret))
+	mac.Write([]byte(timestamp + "." + nonce + "."))
+	mac.Write(body)
+	req, err := http.NewRequest("POST", hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Tsuru-Timestamp", timestamp)
+	req.Header.Set("X-Tsuru-Nonce", nonce)
+	req.Header.Set("X-Tsuru-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return errors.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2018 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/queue"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestSetWebhook(c *check.C) {
+	srv := Service{Name: "mysql", OwnerTeams: []string{s.team.Name}}
+	err := s.conn.Services().Insert(srv)
+	c.Assert(err, check.IsNil)
+	hook, err := srv.SetWebhook(Webhook{URL: "https://provider.example.com/events"})
+	c.Assert(err, check.IsNil)
+	c.Assert(hook.Secret, check.HasLen, 40)
+	var dbService Service
+	err = s.conn.Services().FindId("mysql").One(&dbService)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbService.Webhook, check.DeepEquals, hook)
+	_, err = srv.SetWebhook(Webhook{URL: "provider.example.com"})
+	c.Assert(err, check.ErrorMatches, "webhook url must be an absolute http or https URL")
+	err = srv.RemoveWebhook()
+	c.Assert(err, check.IsNil)
+	err = srv.RemoveWebhook()
+	c.Assert(err, check.Equals, ErrWebhookNotFound)
+}
+
+func (s *S) TestSetWebhookEncryptsSecret(c *check.C) {
+	config.Set("secrets:key", "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	config.Set("secrets:encrypt-storage", true)
+	defer config.Unset("secrets")
+	srv := Service{Name: "mysql", OwnerTeams: []string{s.team.Name}}
+	err := s.conn.Services().Insert(srv)
+	c.Assert(err, check.IsNil)
+	_, err = srv.SetWebhook(Webhook{URL: "https://provider.example.com/events", Secret: "s3cr3t"})
+	c.Assert(err, check.IsNil)
+	var raw bson.M
+	err = s.conn.Services().FindId("mysql").One(&raw)
+	c.Assert(err, check.IsNil)
+	c.Assert(raw["webhook"].(bson.M)["secret"], check.Matches, `enc:v1:.+`)
+	var dbService Service
+	err = s.conn.Services().FindId("mysql").One(&dbService)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbService.Webhook.Secret, check.Equals, "s3cr3t")
+}
+
+func (s *S) TestDeleteInstanceNotifiesWebhook(c *check.C) {
+	defer queue.ResetQueue()
+	received := make(chan WebhookNotification, 1)
+	var attempts int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, []byte("s3cr3t"))
+		mac.Write([]byte(r.Header.Get("X-Tsuru-Timestamp") + "." + r.Header.Get("X-Tsuru-Nonce") + "."))
+		mac.Write(body)
+		c.Check(r.Header.Get("X-Tsuru-Signature"), check.Equals, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		var n WebhookNotification
+		c.Check(json.Unmarshal(body, &n), check.IsNil)
+		received <- n
+	}))
+	defer ts.Close()
+	srv := Service{Name: "mysql", OwnerTeams: []string{s.team.Name}}
+	err := s.conn.Services().Insert(srv)
+	c.Assert(err, check.IsNil)
+	_, err = srv.SetWebhook(Webhook{URL: ts.URL, Secret: "s3cr3t"})
+	c.Assert(err, check.IsNil)
+	si := ServiceInstance{Name: "mydb", ServiceName: "mysql"}
+	err = s.conn.ServiceInstances().Insert(si)
+	c.Assert(err, check.IsNil)
+	err = DeleteInstance(&si, "")
+	c.Assert(err, check.IsNil)
+	msg, err := webhookQueue.Receive()
+	c.Assert(err, check.IsNil)
+	c.Assert(msg.Target, check.Equals, "mysql/mydb")
+	err = deliverWebhook(msg)
+	c.Assert(err, check.ErrorMatches, "unexpected status code 500")
+	err = deliverWebhook(msg)
+	c.Assert(err, check.IsNil)
+	n := <-received
+	c.Assert(n.Event, check.Equals, WebhookEventRemove)
+	c.Assert(n.Service, check.Equals, "mysql")
+	c.Assert(n.Instance, check.Equals, "mydb")
+	c.Assert(n.App, check.Equals, "")
+	c.Assert(attempts, check.Equals, 2)
+}
+
+func (s *S) TestDeliverWebhookRemoved(c *check.C) {
+	defer queue.ResetQueue()
+	srv := Service{Name: "mysql", OwnerTeams: []string{s.team.Name}}
+	err := s.conn.Services().Insert(srv)
+	c.Assert(err, check.IsNil)
+	_, err = srv.SetWebhook(Webhook{URL: "http://localhost:1/events"})
+	c.Assert(err, check.IsNil)
+	si := ServiceInstance{Name: "mydb", ServiceName: "mysql"}
+	notifyWebhook(&si, WebhookEventRemove, "")
+	err = srv.RemoveWebhook()
+	c.Assert(err, check.IsNil)
+	msg, err := webhookQueue.Receive()
+	c.Assert(err, check.IsNil)
+	err = deliverWebhook(msg)
+	c.Assert(err, check.IsNil)
+}
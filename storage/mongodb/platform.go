@@ -15,8 +15,9 @@ import (
 type PlatformService struct{}
 
 type platform struct {
-	Name     string `bson:"_id"`
-	Disabled bool   `bson:",omitempty"`
+	Name      string `bson:"_id"`
+	Disabled  bool   `bson:",omitempty"`
+	EnvFormat int    `bson:"env_format,omitempty"`
 }
 
 func platformsCollection(conn *db.Storage) *dbStorage.Collection {
@@ -87,7 +88,7 @@ func (s *PlatformService) Update(p app.Platform) error {
 		return err
 	}
 	defer conn.Close()
-	return platformsCollection(conn).Update(bson.M{"_id": p.Name}, bson.M{"$set": bson.M{"disabled": p.Disabled}})
+	return platformsCollection(conn).Update(bson.M{"_id": p.Name}, bson.M{"$set": bson.M{"disabled": p.Disabled, "env_format": p.EnvFormat}})
 }
 
 func (s *PlatformService) Delete(p app.Platform) error {
@@ -81,11 +81,13 @@ func (s *PlatformSuite) TestUpdatePlatform(c *check.C) {
 	err := s.PlatformService.Insert(platform)
 	c.Assert(err, check.IsNil)
 	platform.Disabled = true
+	platform.EnvFormat = 2
 	err = s.PlatformService.Update(platform)
 	c.Assert(err, check.IsNil)
 	p, err := s.PlatformService.FindByName("static")
 	c.Assert(err, check.IsNil)
 	c.Assert(p.Disabled, check.Equals, true)
+	c.Assert(p.EnvFormat, check.Equals, 2)
 }
 
 func (s *PlatformSuite) TestUpdatePlatformNotFound(c *check.C) {
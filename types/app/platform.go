@@ -11,8 +11,9 @@ import (
 )
 
 type Platform struct {
-	Name     string
-	Disabled bool
+	Name      string
+	Disabled  bool
+	EnvFormat int
 }
 
 type PlatformService interface {
@@ -35,4 +36,7 @@ var (
 			"characters, containing only lower case letters, numbers or dashes, " +
 			"starting with a letter.",
 	}
+	ErrInvalidPlatformEnvFormat = &tsuruErrors.ValidationError{
+		Message: "Invalid env format, supported formats are 1 (environment variables) and 2 (environment variables and JSON metadata file).",
+	}
 )